@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prehisle/yapi/internal/health"
+)
+
+// maxDBRetryBackoff 是连接主库的重试间隔上限，间隔从 DB_STARTUP_RETRY_BACKOFF 起逐次翻倍。
+const maxDBRetryBackoff = 30 * time.Second
+
+// nextDBBackoff 返回下一次重试的间隔。
+func nextDBBackoff(backoff time.Duration) time.Duration {
+	if backoff <= 0 {
+		return time.Second
+	}
+	return min(2*backoff, maxDBRetryBackoff)
+}
+
+// waitForDatabase 探测主库，失败时按退避间隔最多重试 retries 次，返回最后一次探测的错误。
+func waitForDatabase(ctx context.Context, sqlDB *sql.DB, retries int, backoff time.Duration) error {
+	for attempt := 0; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := sqlDB.PingContext(pingCtx)
+		cancel()
+		if err == nil || attempt >= retries {
+			return err
+		}
+		log.Printf("database unreachable (attempt %d/%d), retrying in %s: %v", attempt+1, retries+1, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = nextDBBackoff(backoff)
+	}
+}
+
+// dbStartup 跟踪依赖主库的启动步骤（表结构迁移、加载预算等）。主库可用时步骤立即执行，失败即退出；
+// 以降级模式启动时推迟这些步骤，后台重连成功后依次补做，全部完成后退出降级模式。
+type dbStartup struct {
+	mu      sync.Mutex
+	pending []startupStep
+	// degraded 保存降级原因，nil 表示主库可用；单独存放以免就绪探针等待正在执行的迁移。
+	degraded atomic.Pointer[error]
+}
+
+type startupStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// run 执行或（降级时）推迟名为 name 的启动步骤。
+func (s *dbStartup) run(ctx context.Context, name string, step func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.degraded.Load() != nil {
+		s.pending = append(s.pending, startupStep{name: name, run: step})
+		return
+	}
+	if err := step(ctx); err != nil {
+		log.Fatalf("%s failed: %v", name, err)
+	}
+}
+
+// recover 在后台按退避间隔重连主库，成功后补做推迟的启动步骤；步骤失败时保留剩余步骤，下次重连后继续。
+func (s *dbStartup) recover(ctx context.Context, sqlDB *sql.DB, backoff time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = nextDBBackoff(backoff)
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := sqlDB.PingContext(pingCtx)
+		cancel()
+		if err == nil && s.finishPending(ctx) {
+			log.Printf("database recovered, leaving degraded mode")
+			return
+		}
+	}
+}
+
+// finishPending 依次执行推迟的启动步骤，全部成功时退出降级模式并返回 true。
+func (s *dbStartup) finishPending(ctx context.Context) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.pending) > 0 {
+		step := s.pending[0]
+		if err := step.run(ctx); err != nil {
+			cause := fmt.Errorf("%s: %w", step.name, err)
+			s.degraded.Store(&cause)
+			log.Printf("deferred %s failed, still degraded: %v", step.name, err)
+			return false
+		}
+		s.pending = s.pending[1:]
+	}
+	s.degraded.Store(nil)
+	return true
+}
+
+// check 供就绪探针使用：降级期间报告 degraded 及原因，否则探测主库连接。
+func (s *dbStartup) check(ctx context.Context, sqlDB *sql.DB) error {
+	if cause := s.degraded.Load(); cause != nil {
+		return fmt.Errorf("%w: database unavailable, serving cached rules: %v", health.ErrDegraded, *cause)
+	}
+	return sqlDB.PingContext(ctx)
+}
@@ -4,51 +4,130 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 	"github.com/redis/go-redis/v9/maintnotifications"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 
+	"github.com/prehisle/yapi/internal/accesslog"
 	"github.com/prehisle/yapi/internal/admin"
+	"github.com/prehisle/yapi/internal/adminusers"
+	"github.com/prehisle/yapi/internal/analytics"
+	"github.com/prehisle/yapi/internal/archive"
+	"github.com/prehisle/yapi/internal/audit"
+	"github.com/prehisle/yapi/internal/bodylog"
+	"github.com/prehisle/yapi/internal/bootstrap"
+	"github.com/prehisle/yapi/internal/budget"
+	"github.com/prehisle/yapi/internal/dialer"
+	"github.com/prehisle/yapi/internal/dispatch"
+	"github.com/prehisle/yapi/internal/dnscache"
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/features"
+	"github.com/prehisle/yapi/internal/health"
+	"github.com/prehisle/yapi/internal/httpserver"
+	"github.com/prehisle/yapi/internal/leader"
+	"github.com/prehisle/yapi/internal/metricsserver"
 	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/modelalias"
+	"github.com/prehisle/yapi/internal/oidc"
 	"github.com/prehisle/yapi/internal/proxy"
+	"github.com/prehisle/yapi/internal/ratelimit"
+	"github.com/prehisle/yapi/internal/redisconn"
+	"github.com/prehisle/yapi/internal/reload"
+	"github.com/prehisle/yapi/internal/reqtrace"
+	"github.com/prehisle/yapi/internal/respcache"
+	"github.com/prehisle/yapi/internal/servicetokens"
+	"github.com/prehisle/yapi/internal/telemetry"
+	"github.com/prehisle/yapi/internal/tlsconfig"
+	"github.com/prehisle/yapi/internal/tokenizer"
+	"github.com/prehisle/yapi/internal/traceexport"
+	"github.com/prehisle/yapi/internal/upstreams"
+	"github.com/prehisle/yapi/internal/usage"
 	"github.com/prehisle/yapi/pkg/accounts"
 	"github.com/prehisle/yapi/pkg/config"
+	"github.com/prehisle/yapi/pkg/dbreplica"
+	"github.com/prehisle/yapi/pkg/metrics"
 	"github.com/prehisle/yapi/pkg/rules"
+	"github.com/prehisle/yapi/pkg/secrets"
 )
 
 func main() {
 	loadEnvFiles()
+	configPath := flag.String("config", os.Getenv(config.FileEnv), "path to a YAML or TOML config file; environment variables take precedence")
+	validateOnly := flag.Bool("validate-config", false, "check the configuration without starting the gateway; exits non-zero when it is invalid")
+	flag.Parse()
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	cfg := config.Load()
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	cfg, err := config.LoadFile(*configPath)
+	if *validateOnly {
+		os.Exit(validateConfig(os.Stdout, os.Stderr, cfg, err))
+	}
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+	if err := checkConfig(cfg); err != nil {
+		if cfg.StrictConfig {
+			log.Fatalf("invalid config (STRICT_CONFIG=true):\n%v", err)
+		}
+		for _, problem := range strings.Split(err.Error(), "\n") {
+			log.Printf("warning: %s", problem)
+		}
+	}
+	if profile := os.Getenv(config.ProfileEnv); *configPath != "" && profile != "" {
+		log.Printf("config loaded from %s (profile %s)", *configPath, profile)
+	} else if *configPath != "" {
+		log.Printf("config loaded from %s", *configPath)
+	}
+	logLevel := new(slog.LevelVar)
+	if err := logLevel.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		log.Fatalf("invalid LOG_LEVEL %q: want debug, info, warn or error", cfg.LogLevel)
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}))
+	if err := metrics.Configure(metrics.Options{
+		Namespace:       cfg.MetricsNamespace,
+		Subsystem:       cfg.MetricsSubsystem,
+		HTTPBuckets:     cfg.MetricsHTTPBuckets,
+		UpstreamBuckets: cfg.MetricsUpstreamBuckets,
+		MaxRuleLabels:   cfg.MetricsMaxRuleLabels,
+		MaxModelLabels:  cfg.MetricsMaxModelLabels,
+	}); err != nil {
+		log.Fatalf("invalid metrics config: %v", err)
+	}
+	featureFlags, traceToggle, bodyLogToggle := setupFeatureFlags(cfg)
+	accessLogger, accessLogCloser := setupAccessLog(cfg, logger)
+	defer func() {
+		if err := accessLogCloser(); err != nil {
+			log.Printf("access log close error: %v", err)
+		}
+	}()
 
-	store, db, dbCloser := setupStore(ctx, cfg)
+	shutdownTracing := setupTracing(ctx, cfg)
 	defer func() {
-		if dbCloser != nil {
-			if err := dbCloser(); err != nil {
-				log.Printf("database close error: %v", err)
-			}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			log.Printf("tracing shutdown error: %v", err)
 		}
 	}()
 
-	redisClient, cache, eventBus := setupRedis(ctx, cfg)
+	redisClient, cache, eventBus, redisErr := setupRedis(ctx, cfg)
 	if redisClient != nil {
 		defer func() {
 			if err := redisClient.Close(); err != nil {
@@ -57,76 +136,352 @@ func main() {
 		}()
 	}
 
+	store, db, startup, dbCloser := setupStore(ctx, cfg, cache != nil)
+	defer func() {
+		if dbCloser != nil {
+			if err := dbCloser(); err != nil {
+				log.Printf("database close error: %v", err)
+			}
+		}
+	}()
+
 	var serviceOpts []rules.ServiceOption
 	if cache != nil {
 		serviceOpts = append(serviceOpts, rules.WithCache(cache))
 	}
-	if eventBus != nil {
-		serviceOpts = append(serviceOpts, rules.WithEventBus(eventBus))
+	if eventBus == nil {
+		// 无 Redis 时改用进程内事件总线，管理端事件流仍可推送本实例的变更。
+		eventBus = rules.NewMemoryEventBus()
 	}
+	serviceOpts = append(serviceOpts, rules.WithEventBus(eventBus))
 
 	var accountService accounts.Service
 	if db != nil {
-		accountService = accounts.NewService(db)
-		if err := accountService.AutoMigrate(ctx); err != nil {
-			log.Fatalf("accounts migration failed: %v", err)
+		hasher, err := accounts.NewSecretHasher(accounts.HashConfig{
+			Algorithm:  cfg.APIKeyHashAlgorithm,
+			BcryptCost: cfg.APIKeyBcryptCost,
+			HMACKey:    []byte(cfg.APIKeyHMACSecret),
+		})
+		if err != nil {
+			log.Fatalf("invalid api key hash config: %v", err)
 		}
+		accountService = accounts.NewService(db, accounts.WithSecretHasher(hasher))
+		startup.run(ctx, "accounts migration", accountService.AutoMigrate)
 	}
 
 	ruleService := rules.NewService(store, serviceOpts...)
 	ruleService.StartBackgroundSync(ctx)
 
-	if err := seedDefaultRule(ctx, ruleService); err != nil {
-		log.Printf("failed to seed default rule: %v", err)
+	applyBootstrapManifest(ctx, cfg, startup, ruleService, accountService)
+
+	corsOrigins := middleware.NewCORSOrigins(cfg.AdminAllowedOrigins)
+	internalHeaders := middleware.NewInternalHeaders(cfg.InternalHeaders)
+	rateLimitDefaults := middleware.NewRateLimitDefaults(cfg.RateLimitDefaultRPM, cfg.RateLimitDefaultConcurrency)
+	requestLimits := middleware.NewRequestLimits(requestSizeLimits(cfg))
+	proxyInflight := middleware.NewInflightLimit("proxy", cfg.MaxInflightRequests)
+	adminInflight := middleware.NewInflightLimit("admin", cfg.AdminMaxInflightRequests)
+	ipFilters, err := newIPFilters(cfg)
+	if err != nil {
+		log.Fatalf("failed to configure ip filters: %v", err)
+	}
+	// 未配置可信代理时 IP 名单按 TCP 对端地址过滤，客户端伪造的 X-Forwarded-For 不起作用。
+	var restrictIPsOpts []middleware.RestrictIPsOption
+	if len(cfg.TrustedProxies) > 0 {
+		restrictIPsOpts = append(restrictIPsOpts, middleware.WithForwardedClientIP())
 	}
 
 	gin.SetMode(gin.ReleaseMode)
-	router := gin.New()
-	router.Use(gin.Recovery())
-	router.Use(middleware.RequestID(), middleware.AccessLogger(logger), middleware.CORS(cfg.AdminAllowedOrigins))
+	// 内部请求头在其余中间件之前移除，客户端无法伪造网关注入给上游的身份与路由信息。
+	commonMiddleware := []gin.HandlerFunc{gin.Recovery(), middleware.StripInternalHeaders(internalHeaders), middleware.RequestID(), telemetry.Middleware(), middleware.AccessLogger(accessLogger,
+		middleware.WithSuccessSampling(cfg.AccessLogSampleN),
+		middleware.WithSlowThreshold(cfg.AccessLogSlowThreshold),
+		middleware.WithSlowOnly(cfg.AccessLogSlowOnly),
+	), middleware.RestrictIPs(ipFilters.global, restrictIPsOpts...), middleware.LimitRequestSize(requestLimits), middleware.DynamicCORS(corsOrigins)}
+	router := newRouter(cfg)
+	router.Use(commonMiddleware...)
+	// 设置 ADMIN_PORT 时管理 API 只在独立监听上提供，网关端口上的 /admin 路径返回 404 而不转发给上游。
+	adminRouter := router
+	if cfg.AdminPort != "" {
+		adminRouter = newRouter(cfg)
+		adminRouter.Use(commonMiddleware...)
+		router.Any(admin.LegacyPrefix+"/*path", func(c *gin.Context) {
+			errcode.Respond(c, http.StatusNotFound, errcode.NotFound, "admin api is served on a separate listener")
+		})
+	}
+	// 代理范围的 IP 过滤与并发上限只作用于交给代理转发的请求，并在 API Key 认证之前执行。
+	router.Use(middleware.Unrouted(middleware.RestrictIPs(ipFilters.proxy, restrictIPsOpts...)), middleware.Unrouted(middleware.LimitInflight(proxyInflight)))
 	if accountService != nil {
-		router.Use(middleware.APIKeyAuth(accountService))
+		var limiter ratelimit.Limiter = ratelimit.NewMemoryLimiter()
+		if redisClient != nil {
+			limiter = ratelimit.NewRedisLimiter(redisClient, "yapi:ratelimit")
+		}
+		router.Use(middleware.APIKeyAuth(accountService), middleware.RateLimit(limiter, middleware.WithDefaultLimits(rateLimitDefaults)))
 	}
-	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
-
-	adminAuth := admin.NewAuthenticator(cfg.AdminUsername, cfg.AdminPassword, cfg.AdminTokenSecret, cfg.AdminTokenTTL)
-	adminService := admin.NewService(ruleService, accountService)
-	adminHandler := admin.NewHandler(adminService, adminAuth, admin.WithLogger(logger))
-	adminGroup := router.Group("/admin")
-	admin.RegisterPublicRoutes(adminGroup, adminHandler)
-	protected := adminGroup.Group("")
-	protected.Use(adminAuth.Middleware())
-	admin.RegisterProtectedRoutes(protected, adminHandler)
+	setupMetricsEndpoints(ctx, cfg, router)
+	health.RegisterRoutes(router, setupHealthChecker(db, startup, redisClient, ruleService))
 
+	authOpts := []admin.AuthOption{admin.WithRefreshTTL(cfg.AdminRefreshTokenTTL)}
+	if len(cfg.TrustedProxies) > 0 {
+		authOpts = append(authOpts, admin.WithForwardedClientIP())
+	}
+	var loginAttempts admin.LoginAttemptStore
+	if redisClient != nil {
+		authOpts = append(authOpts, admin.WithRevocationStore(admin.NewRedisRevocationStore(redisClient, "yapi:admin:revoked")))
+		loginAttempts = admin.NewRedisLoginAttemptStore(redisClient, "yapi:admin:login")
+	}
+	authOpts = append(authOpts, admin.WithLoginGuard(admin.NewLoginGuard(loginAttempts, admin.LoginPolicy{
+		MaxAttempts:   cfg.AdminLoginMaxAttempts,
+		IPMaxAttempts: cfg.AdminLoginIPMaxAttempts,
+		Window:        cfg.AdminLoginFailureWindow,
+		Lockout:       cfg.AdminLoginLockout,
+		MaxLockout:    cfg.AdminLoginMaxLockout,
+	})))
+	adminUsers := setupAdminUsers(ctx, startup, db)
+	oidcProvider := setupOIDC(cfg)
+	traceStore := setupTraceStore(cfg, redisClient)
+	handlerOpts := []admin.Option{
+		admin.WithLogger(logger),
+		admin.WithAuditStore(setupAuditStore(ctx, startup, db)),
+		admin.WithEventBus(eventBus),
+		admin.WithSessionCookies(setupSessionCookies(cfg)),
+		admin.WithLogLevel(logLevel),
+		admin.WithFeatureFlags(featureFlags),
+	}
+	if cfg.AdminRequireIfMatch {
+		handlerOpts = append(handlerOpts, admin.WithRequireIfMatch())
+	}
+	if adminUsers != nil {
+		authOpts = append(authOpts, admin.WithUserStore(adminUsers))
+		handlerOpts = append(handlerOpts, admin.WithAdminUsers(adminUsers))
+	}
+	if serviceTokens := setupServiceTokens(ctx, startup, db); serviceTokens != nil {
+		authOpts = append(authOpts, admin.WithServiceTokenStore(serviceTokens))
+		handlerOpts = append(handlerOpts, admin.WithServiceTokens(serviceTokens))
+	}
+	if traceStore != nil {
+		handlerOpts = append(handlerOpts, admin.WithTraceStore(traceStore))
+	}
+	elector := setupLeaderElection(ctx, cfg, db, redisClient, logger)
+	conversations := setupArchive(ctx, cfg, startup, elector, db, logger)
+	if conversations != nil {
+		handlerOpts = append(handlerOpts, admin.WithConversationArchive(conversations))
+	}
+	modelAliases := setupModelAliases(ctx, startup, db, eventBus)
+	handlerOpts = append(handlerOpts, admin.WithModelAliases(modelAliases))
+	budgets := setupBudgets(ctx, cfg, startup, db, logger)
+	handlerOpts = append(handlerOpts, admin.WithBudgets(budgets))
+	if oidcProvider != nil {
+		authOpts = append(authOpts, admin.WithExternalLogin(cfg.AdminOIDCSessionTTL))
+		handlerOpts = append(handlerOpts, admin.WithOIDC(oidcProvider, cfg.AdminOIDCPostLoginURL))
+	}
+	adminAuth := admin.NewAuthenticator(cfg.AdminUsername, cfg.AdminPassword, cfg.AdminTokenSecret, cfg.AdminTokenTTL, authOpts...)
+	secretResolver := setupSecrets(cfg)
+	adminServiceOpts := []admin.ServiceOption{admin.WithSecretRefChecker(secretResolver)}
+	if accountService != nil {
+		checker := upstreams.NewChecker(accountService, upstreams.WithSecretResolver(secretResolver), upstreams.WithLogger(logger))
+		elector.Every(ctx, "upstream_health_check", cfg.UpstreamHealthCheckInterval, checker.CheckAll)
+		adminServiceOpts = append(adminServiceOpts, admin.WithUpstreamVerifier(checker))
+	}
 	defaultTarget := mustParseURL(cfg.UpstreamBaseURL)
 	proxyOptions := []proxy.Option{
 		proxy.WithDefaultTarget(defaultTarget),
+		proxy.WithRequireClientAuth(cfg.RequireClientAuth),
+		proxy.WithDefaultRulePolicy(defaultRulePolicy(cfg)),
 		proxy.WithLogger(logger),
+		proxy.WithSLOLatencyThreshold(cfg.SLOLatencyThreshold),
+		proxy.WithRetryAfterMax(cfg.UpstreamRetryAfterMax),
+		proxy.WithStreamHeartbeat(cfg.StreamHeartbeatInterval),
+		proxy.WithStreamIdleTimeout(cfg.StreamIdleTimeout),
+		proxy.WithModelAliases(modelAliases),
 	}
 	if accountService != nil {
 		proxyOptions = append(proxyOptions, proxy.WithAccountsService(accountService))
 	}
+	proxyOptions = append(proxyOptions, proxy.WithSecretResolver(secretResolver))
+	dialOptions := dialer.Options{
+		Preference:    cfg.UpstreamIPPreference,
+		FallbackDelay: cfg.UpstreamDialFallbackDelay,
+		Timeout:       cfg.UpstreamDialTimeout,
+		KeepAlive:     30 * time.Second,
+	}
+	if cfg.UpstreamDNSCacheTTL > 0 {
+		dialOptions.Resolver = dnscache.New(cfg.UpstreamDNSCacheTTL)
+	}
+	dial, err := dialer.New(dialOptions)
+	if err != nil {
+		log.Fatalf("invalid upstream dialer settings: %v", err)
+	}
+	proxyOptions = append(proxyOptions, proxy.WithDialContext(dial))
+	if len(cfg.UpstreamWarmTargets) > 0 {
+		warm := proxy.WarmOptions{Conns: cfg.UpstreamWarmConnections, Interval: cfg.UpstreamWarmInterval}
+		for _, raw := range cfg.UpstreamWarmTargets {
+			target, err := url.Parse(raw)
+			if err != nil {
+				log.Fatalf("invalid UPSTREAM_WARM_TARGETS: %v", err)
+			}
+			warm.Targets = append(warm.Targets, target)
+		}
+		proxyOptions = append(proxyOptions, proxy.WithWarmConnections(warm))
+	}
+	pricing, err := usage.ParsePricing(cfg.ModelPricing)
+	if err != nil {
+		log.Fatalf("invalid MODEL_PRICING: %v", err)
+	}
+	if cfg.TokenizerBPEFile != "" {
+		tok, err := tokenizer.Load(cfg.TokenizerBPEFile)
+		if err != nil {
+			log.Fatalf("invalid TOKENIZER_BPE_FILE: %v", err)
+		}
+		proxyOptions = append(proxyOptions, proxy.WithTokenizer(tok))
+	}
+	proxyOptions = append(proxyOptions, proxy.WithModelPricing(pricing), proxy.WithBudgets(budgets), proxy.WithBodyLogging(setupBodyLogging(cfg, bodyLogToggle)))
+	if cfg.ResponseCacheMaxEntries > 0 {
+		proxyOptions = append(proxyOptions, proxy.WithResponseCache(respcache.New(cfg.ResponseCacheMaxEntries, int64(cfg.ResponseCacheMaxBytes))))
+	}
+	if traceStore != nil {
+		proxyOptions = append(proxyOptions, proxy.WithTraceStore(traceStore), proxy.WithTraceToggle(traceToggle))
+	}
+	if conversations != nil {
+		proxyOptions = append(proxyOptions, proxy.WithArchive(conversations, cfg.ArchiveMaxBytes))
+	}
+	traceExports, closeTraceExports := setupTraceExport(cfg, logger)
+	defer closeTraceExports()
+	if traceExports != nil {
+		proxyOptions = append(proxyOptions, proxy.WithTraceExport(traceExports, cfg.TraceExportMaxBytes))
+	}
+	if queue, tiers := setupDispatchQueue(cfg); queue != nil {
+		proxyOptions = append(proxyOptions, proxy.WithDispatchQueue(queue, tiers))
+	}
+	analyticsSink, closeAnalytics := setupAnalytics(ctx, cfg, startup, db, logger)
+	defer closeAnalytics()
+	if analyticsSink != nil {
+		proxyOptions = append(proxyOptions, proxy.WithAnalyticsSink(analyticsSink))
+	}
 	proxyHandler := proxy.NewHandler(ruleService, proxyOptions...)
+	proxyHandler.StartWarming(ctx)
+	reloader := setupReloader(*configPath, cfg, logger, logLevel, corsOrigins, internalHeaders, ipFilters, requestLimits, rateLimitDefaults, proxyInflight, adminInflight, proxyHandler)
+	reloader.WatchSignals(ctx)
+	handlerOpts = append(handlerOpts, admin.WithConfigReloader(reloader), admin.WithRequestReplayer(proxyHandler))
+	handlerOpts = append(handlerOpts, admin.WithRuntimeConfig(runtimeConfig(cfg, db, redisClient, redisErr, traceStore)))
+	adminService := admin.NewService(ruleService, accountService, adminServiceOpts...)
+	adminHandler := admin.NewHandler(adminService, adminAuth, handlerOpts...)
+	adminV1 := adminRouter.Group(admin.V1Prefix)
+	adminV1.Use(admin.Envelope(), middleware.RestrictIPs(ipFilters.admin, restrictIPsOpts...), middleware.LimitInflight(adminInflight))
+	admin.Mount(adminV1, adminHandler, adminAuth.Middleware())
+	adminLegacy := adminRouter.Group(admin.LegacyPrefix)
+	adminLegacy.Use(admin.DeprecationHeaders(admin.LegacyPrefix, admin.V1Prefix), middleware.RestrictIPs(ipFilters.admin, restrictIPsOpts...), middleware.LimitInflight(adminInflight))
+	admin.Mount(adminLegacy, adminHandler, adminAuth.Middleware())
+
 	proxy.RegisterRoutes(router, proxyHandler)
 
-	server := &http.Server{
-		Addr:              ":" + cfg.GatewayPort,
-		Handler:           router,
-		ReadHeaderTimeout: 5 * time.Second,
+	tlsConfig, err := tlsconfig.New(ctx, tlsOptions(cfg, logger))
+	if err != nil {
+		log.Fatalf("failed to configure tls: %v", err)
+	}
+	serverOpts := serverOptions(cfg, logger)
+	adminDone := make(chan struct{})
+	if adminRouter != router {
+		go serveAdmin(ctx, httpserver.New("admin api", listenAddr(cfg.AdminPort), adminRouter, tlsConfig, serverOpts), adminDone)
+	} else {
+		close(adminDone)
 	}
+	server := httpserver.New("gateway", listenAddr(cfg.GatewayPort), router, tlsConfig, serverOpts)
+	if err := server.Run(ctx); err != nil {
+		log.Fatalf("server error: %v", err)
+	}
+	// 等待管理端也完成关闭后再释放数据库、Redis 等资源。
+	<-adminDone
+}
 
-	go func() {
-		<-ctx.Done()
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		if err := server.Shutdown(shutdownCtx); err != nil {
-			log.Printf("server shutdown error: %v", err)
-		}
-	}()
+// newRouter 创建 gin 引擎，只信任 TRUSTED_PROXIES 中的代理转发的 X-Forwarded-For；
+// 未配置时不信任任何代理，ClientIP 即 TCP 对端地址，客户端无法伪造访问日志与登录锁定所用的来源地址。
+func newRouter(cfg config.Config) *gin.Engine {
+	engine := gin.New()
+	if err := engine.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	return engine
+}
 
-	log.Printf("gateway listening on %s", server.Addr)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("server error: %v", err)
+// requestSizeLimits 汇总单个请求的请求头与请求体上限。
+func requestSizeLimits(cfg config.Config) middleware.SizeLimits {
+	return middleware.SizeLimits{
+		MaxHeaders:     cfg.MaxRequestHeaders,
+		MaxHeaderBytes: cfg.MaxRequestHeaderBytes,
+		MaxBodyBytes:   cfg.MaxRequestBodyBytes,
+	}
+}
+
+// ipFilters 是全局、管理端与代理三个范围的客户端 IP 过滤，均支持配置热更新。
+type ipFilters struct {
+	global *middleware.IPFilter
+	admin  *middleware.IPFilter
+	proxy  *middleware.IPFilter
+}
+
+func newIPFilters(cfg config.Config) (ipFilters, error) {
+	filters := ipFilters{
+		global: middleware.NewIPFilter("global"),
+		admin:  middleware.NewIPFilter("admin"),
+		proxy:  middleware.NewIPFilter("proxy"),
+	}
+	return filters, filters.set(cfg)
+}
+
+// set 按配置替换三个范围的名单，任一名单无效时返回错误。
+func (f ipFilters) set(cfg config.Config) error {
+	if err := f.global.Set(cfg.IPAllowlist, cfg.IPDenylist); err != nil {
+		return fmt.Errorf("IP_ALLOWLIST/IP_DENYLIST: %w", err)
+	}
+	if err := f.admin.Set(cfg.AdminIPAllowlist, cfg.AdminIPDenylist); err != nil {
+		return fmt.Errorf("ADMIN_IP_ALLOWLIST/ADMIN_IP_DENYLIST: %w", err)
+	}
+	if err := f.proxy.Set(cfg.ProxyIPAllowlist, cfg.ProxyIPDenylist); err != nil {
+		return fmt.Errorf("PROXY_IP_ALLOWLIST/PROXY_IP_DENYLIST: %w", err)
+	}
+	return nil
+}
+
+// serverOptions 汇总网关与管理端监听的超时、请求头上限与关闭宽限期设置。
+func serverOptions(cfg config.Config, logger *slog.Logger) httpserver.Options {
+	return httpserver.Options{
+		ReadTimeout:         cfg.ServerReadTimeout,
+		ReadHeaderTimeout:   cfg.ServerReadHeaderTimeout,
+		WriteTimeout:        cfg.ServerWriteTimeout,
+		IdleTimeout:         cfg.ServerIdleTimeout,
+		MaxHeaderBytes:      cfg.ServerMaxHeaderBytes,
+		ShutdownGracePeriod: cfg.ShutdownGracePeriod,
+		Logger:              logger,
+	}
+}
+
+// listenAddr 把端口转换为监听地址，已包含主机部分（如 127.0.0.1:9091）时原样使用。
+func listenAddr(port string) string {
+	if strings.Contains(port, ":") {
+		return port
+	}
+	return ":" + port
+}
+
+// serveAdmin 在独立的监听地址上提供管理 API（如仅绑定 127.0.0.1），与网关端口共用 TLS 配置与超时设置，关闭完成后关闭 done。
+func serveAdmin(ctx context.Context, server *httpserver.Server, done chan<- struct{}) {
+	defer close(done)
+	if err := server.Run(ctx); err != nil {
+		log.Fatalf("admin server error: %v", err)
+	}
+}
+
+// tlsOptions 汇总网关端口的 TLS 设置，未配置证书时网关以明文 HTTP 监听。
+func tlsOptions(cfg config.Config, logger *slog.Logger) tlsconfig.Options {
+	return tlsconfig.Options{
+		CertFile:         cfg.TLSCertFile,
+		KeyFile:          cfg.TLSKeyFile,
+		ReloadInterval:   cfg.TLSCertReloadInterval,
+		ACMEDomains:      cfg.TLSACMEDomains,
+		ACMEEmail:        cfg.TLSACMEEmail,
+		ACMECacheDir:     cfg.TLSACMECacheDir,
+		ACMEDirectoryURL: cfg.TLSACMEDirectoryURL,
+		Logger:           logger,
 	}
 }
 
@@ -167,39 +522,46 @@ func mustParseURL(raw string) *url.URL {
 	return u
 }
 
-func seedDefaultRule(ctx context.Context, svc rules.Service) error {
-	defaultRule := rules.Rule{
-		ID:       "bootstrap-openai",
-		Priority: 100,
-		Matcher: rules.Matcher{
-			PathPrefix: "/v1",
-			Methods:    []string{"POST"},
-		},
-		Actions: rules.Actions{
-			SetTargetURL: "https://api.openai.com",
-		},
-		Enabled: false,
+// applyBootstrapManifest 创建 BOOTSTRAP_MANIFEST（或 BOOTSTRAP_MANIFEST_FILE）中缺失的规则、用户与 API Key，
+// 未配置时只创建默认停用的示例规则。清单不合法时拒绝启动；应用失败只记录日志，下次启动时重试。
+// 降级模式下推迟到主库恢复后应用。
+func applyBootstrapManifest(ctx context.Context, cfg config.Config, startup *dbStartup, ruleService rules.Service, accountService accounts.Service) {
+	manifest := bootstrap.DefaultManifest()
+	if cfg.BootstrapManifest != "" {
+		var err error
+		if manifest, err = bootstrap.Parse([]byte(cfg.BootstrapManifest)); err != nil {
+			log.Fatalf("failed to load bootstrap manifest: %v", err)
+		}
 	}
-	_, err := svc.GetRule(ctx, defaultRule.ID)
-	if err == nil {
+	startup.run(ctx, "bootstrap manifest", func(ctx context.Context) error {
+		result, err := bootstrap.Apply(ctx, manifest, ruleService, accountService)
+		if err != nil {
+			log.Printf("failed to apply bootstrap manifest: %v", err)
+			return nil
+		}
+		if created := result.RulesCreated + result.UsersCreated + result.APIKeysCreated; created > 0 {
+			log.Printf("bootstrap manifest applied: %d rules, %d users, %d api keys created", result.RulesCreated, result.UsersCreated, result.APIKeysCreated)
+		}
 		return nil
-	}
-	if !errors.Is(err, rules.ErrRuleNotFound) {
-		return err
-	}
-	return svc.UpsertRule(ctx, defaultRule)
+	})
 }
 
-func setupStore(ctx context.Context, cfg config.Config) (rules.Store, *gorm.DB, func() error) {
+// setupStore 连接主库并迁移规则表，主库不可达时按 DB_STARTUP_RETRIES 重试。重试耗尽后若开启 DB_DEGRADED_MODE
+// 且 Redis 规则缓存可用（rulesCached），以降级模式继续启动：规则从 Redis 缓存读取，依赖主库的启动步骤推迟到后台重连成功后执行；
+// 否则拒绝启动。
+func setupStore(ctx context.Context, cfg config.Config, rulesCached bool) (rules.Store, *gorm.DB, *dbStartup, func() error) {
+	startup := &dbStartup{}
 	if cfg.DatabaseDSN == "" {
-		return rules.NewMemoryStore(), nil, nil
+		return rules.NewMemoryStore(), nil, startup, nil
 	}
 	gormLogger := logger.New(log.New(os.Stdout, "gorm: ", log.LstdFlags), logger.Config{
 		SlowThreshold: time.Second,
 		LogLevel:      logger.Warn,
 	})
+	// 连接由 waitForDatabase 探测，以便重试或进入降级模式。
 	db, err := gorm.Open(postgres.Open(cfg.DatabaseDSN), &gorm.Config{
-		Logger: gormLogger,
+		Logger:               gormLogger,
+		DisableAutomaticPing: true,
 	})
 	if err != nil {
 		log.Fatalf("failed to connect database: %v", err)
@@ -208,23 +570,560 @@ func setupStore(ctx context.Context, cfg config.Config) (rules.Store, *gorm.DB,
 	if err != nil {
 		log.Fatalf("failed to get database connection: %v", err)
 	}
-	configureSQLDB(sqlDB)
+	configureSQLDB(sqlDB, cfg)
+	if err := waitForDatabase(ctx, sqlDB, cfg.DBStartupRetries, cfg.DBStartupRetryBackoff); err != nil {
+		if !cfg.DBDegradedMode || !rulesCached {
+			log.Fatalf("failed to connect database: %v", err)
+		}
+		cause := fmt.Errorf("connect: %w", err)
+		startup.degraded.Store(&cause)
+		log.Printf("warning: database unreachable, starting in degraded mode with rules from the redis cache: %v", err)
+		go startup.recover(ctx, sqlDB, cfg.DBStartupRetryBackoff)
+	}
+	if len(cfg.DatabaseReplicaDSNs) > 0 {
+		replicas := make([]gorm.Dialector, len(cfg.DatabaseReplicaDSNs))
+		for i, dsn := range cfg.DatabaseReplicaDSNs {
+			replicas[i] = postgres.Open(dsn)
+		}
+		if err := dbreplica.Register(db, replicas, dbPool(cfg)); err != nil {
+			log.Fatalf("failed to configure database replicas: %v", err)
+		}
+		log.Printf("database read replicas enabled (%d)", len(replicas))
+	}
 
 	store := rules.NewDBStore(db)
-	if err := store.AutoMigrate(ctx); err != nil {
-		log.Fatalf("database migration failed: %v", err)
+	startup.run(ctx, "database migration", store.AutoMigrate)
+	return store, db, startup, sqlDB.Close
+}
+
+// setupLeaderElection 创建周期任务的领导者选举：优先以 Redis 租约选举，其次使用主库的 advisory lock；
+// 两者都未启用时视为单实例部署，本实例始终是领导者。
+func setupLeaderElection(ctx context.Context, cfg config.Config, db *gorm.DB, redisClient redis.UniversalClient, logger *slog.Logger) *leader.Elector {
+	var backend leader.Backend
+	switch {
+	case redisClient != nil:
+		backend = leader.NewRedisBackend(redisClient, "yapi:leader", cfg.LeaderLeaseTTL)
+	case db != nil:
+		sqlDB, err := db.DB()
+		if err != nil {
+			log.Fatalf("failed to get database connection: %v", err)
+		}
+		backend = leader.NewPostgresBackend(sqlDB, "yapi:leader")
 	}
-	return store, db, sqlDB.Close
+	elector := leader.New(backend, leader.WithLeaseTTL(cfg.LeaderLeaseTTL), leader.WithLogger(logger))
+	elector.Start(ctx)
+	return elector
 }
 
-func configureSQLDB(db *sql.DB) {
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(30 * time.Minute)
+// setupAuditStore 优先将审计日志写入数据库，未配置数据库时退化为进程内存储。
+func setupAuditStore(ctx context.Context, startup *dbStartup, db *gorm.DB) audit.Store {
+	if db == nil {
+		return audit.NewMemoryStore()
+	}
+	store := audit.NewDBStore(db)
+	startup.run(ctx, "audit log migration", store.AutoMigrate)
+	return store
 }
 
-func setupRedis(ctx context.Context, cfg config.Config) (*redis.Client, rules.Cache, rules.EventBus) {
-	options := &redis.Options{Addr: cfg.RedisAddr}
+// setupArchive 在配置 ARCHIVE_ENCRYPTION_KEY 时启用对话归档，优先写入数据库，未配置数据库时退化为进程内存储；
+// 过期记录每小时清理一次。
+func setupArchive(ctx context.Context, cfg config.Config, startup *dbStartup, elector *leader.Elector, db *gorm.DB, logger *slog.Logger) *archive.Archive {
+	if cfg.ArchiveEncryptionKey == "" {
+		return nil
+	}
+	key, err := archive.ParseKey(cfg.ArchiveEncryptionKey)
+	if err != nil {
+		log.Fatalf("invalid ARCHIVE_ENCRYPTION_KEY: %v", err)
+	}
+	var store archive.Store = archive.NewMemoryStore()
+	if db != nil {
+		dbStore := archive.NewDBStore(db)
+		startup.run(ctx, "conversation archive migration", dbStore.AutoMigrate)
+		store = dbStore
+	}
+	conversations, err := archive.New(store, key, cfg.ArchiveRetention, logger)
+	if err != nil {
+		log.Fatalf("conversation archive init failed: %v", err)
+	}
+	if db == nil {
+		// 内存存储属于本实例，每个实例各自清理。
+		elector = leader.New(nil)
+	}
+	elector.Every(ctx, "archive_purge", time.Hour, conversations.PurgeExpired)
+	return conversations
+}
+
+// setupTraceExport 按已配置凭据的平台（Langfuse、LangSmith）创建提示词与补全导出服务，两者都未配置时返回 nil；
+// 返回的函数在退出前导出队列中剩余的记录。
+func setupTraceExport(cfg config.Config, logger *slog.Logger) (*traceexport.Service, func()) {
+	exporters := map[string]traceexport.Exporter{}
+	if cfg.LangfusePublicKey != "" {
+		exporters[traceexport.ProviderLangfuse] = traceexport.NewLangfuse(cfg.LangfuseHost, cfg.LangfusePublicKey, cfg.LangfuseSecretKey, nil)
+	}
+	if cfg.LangSmithAPIKey != "" {
+		exporters[traceexport.ProviderLangSmith] = traceexport.NewLangSmith(cfg.LangSmithEndpoint, cfg.LangSmithAPIKey, cfg.LangSmithProject, nil)
+	}
+	if len(exporters) == 0 {
+		return nil, func() {}
+	}
+	exports := traceexport.NewService(exporters, traceexport.Options{
+		QueueSize:     cfg.TraceExportQueueSize,
+		BatchSize:     cfg.TraceExportBatchSize,
+		FlushInterval: cfg.TraceExportFlushInterval,
+		Logger:        logger,
+	})
+	return exports, func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := exports.Close(closeCtx); err != nil {
+			log.Printf("trace export flush error: %v", err)
+		}
+	}
+}
+
+// setupDispatchQueue 在设置 UPSTREAM_MAX_CONCURRENCY 时创建上游转发的排队队列，UPSTREAM_QUEUE_TIERS 无法解析时拒绝启动。
+func setupDispatchQueue(cfg config.Config) (*dispatch.Queue, dispatch.Tiers) {
+	if cfg.UpstreamMaxConcurrency <= 0 {
+		return nil, nil
+	}
+	tiers, err := dispatch.ParseTiers(cfg.UpstreamQueueTiers)
+	if err != nil {
+		log.Fatalf("invalid UPSTREAM_QUEUE_TIERS: %v", err)
+	}
+	return dispatch.New(dispatch.Options{
+		MaxConcurrency: cfg.UpstreamMaxConcurrency,
+		MaxQueue:       cfg.UpstreamQueueDepth,
+		Timeout:        cfg.UpstreamQueueTimeout,
+	}), tiers
+}
+
+// setupAdminUsers 在启用数据库时创建管理员账号库；未启用数据库时仅支持环境变量配置的单一管理员。
+func setupAdminUsers(ctx context.Context, startup *dbStartup, db *gorm.DB) adminusers.Service {
+	if db == nil {
+		return nil
+	}
+	users := adminusers.NewService(db)
+	startup.run(ctx, "admin users migration", users.AutoMigrate)
+	return users
+}
+
+// setupSessionCookies 解析管理端 Cookie 会话的属性，SameSite 取值非法时拒绝启动。
+func setupSessionCookies(cfg config.Config) admin.SessionCookieOptions {
+	sameSite, ok := admin.ParseSameSite(cfg.AdminSessionCookieSameSite)
+	if !ok {
+		log.Fatalf("invalid ADMIN_SESSION_COOKIE_SAMESITE %q: want strict, lax or none", cfg.AdminSessionCookieSameSite)
+	}
+	return admin.SessionCookieOptions{
+		Domain:   cfg.AdminSessionCookieDomain,
+		Insecure: cfg.AdminSessionCookieInsecure,
+		SameSite: sameSite,
+	}
+}
+
+// setupServiceTokens 在启用数据库时创建服务令牌存储，供 CI 等自动化场景使用长期令牌访问管理端。
+func setupServiceTokens(ctx context.Context, startup *dbStartup, db *gorm.DB) servicetokens.Service {
+	if db == nil {
+		return nil
+	}
+	tokens := servicetokens.NewService(db)
+	startup.run(ctx, "service tokens migration", tokens.AutoMigrate)
+	return tokens
+}
+
+// setupModelAliases 创建模型别名服务：启用数据库时别名持久化并经事件总线在实例间同步，否则只保存在本实例内存中。
+func setupModelAliases(ctx context.Context, startup *dbStartup, db *gorm.DB, bus rules.EventBus) modelalias.Service {
+	var store modelalias.Store = modelalias.NewMemoryStore()
+	if db != nil {
+		dbStore := modelalias.NewDBStore(db)
+		startup.run(ctx, "model aliases migration", dbStore.AutoMigrate)
+		store = dbStore
+	}
+	aliases := modelalias.NewService(store, modelalias.WithEventBus(bus))
+	startup.run(ctx, "load model aliases", aliases.Load)
+	aliases.StartBackgroundSync(ctx)
+	return aliases
+}
+
+// setupBudgets 创建用户与组织预算服务：启用数据库时花费持久化并由多实例共享，本实例的预算表每隔 BUDGET_SYNC_INTERVAL 重新加载；
+// 否则只保存在本实例内存中。配置 SMTP_ADDR 时预算告警同时发送邮件。
+func setupBudgets(ctx context.Context, cfg config.Config, startup *dbStartup, db *gorm.DB, logger *slog.Logger) *budget.Service {
+	var store budget.Store = budget.NewMemoryStore()
+	if db != nil {
+		dbStore := budget.NewDBStore(db)
+		startup.run(ctx, "budgets migration", dbStore.AutoMigrate)
+		store = dbStore
+	}
+	opts := []budget.Option{budget.WithLogger(logger)}
+	if cfg.SMTPAddr != "" {
+		opts = append(opts, budget.WithMailer(budget.SMTPMailer{
+			Addr:     cfg.SMTPAddr,
+			From:     cfg.SMTPFrom,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+		}))
+	}
+	budgets := budget.NewService(store, opts...)
+	startup.run(ctx, "load budgets", budgets.Load)
+	if db != nil {
+		budgets.Start(ctx, cfg.BudgetSyncInterval)
+	}
+	return budgets
+}
+
+// setupTraceStore 创建代理请求轨迹存储：启用 Redis 时多实例共享并按 TTL 过期，否则在本实例内存中保留最近的轨迹。
+// REQUEST_TRACE_CAPACITY 为 0 时不记录轨迹。
+func setupTraceStore(cfg config.Config, redisClient redis.UniversalClient) reqtrace.Store {
+	if cfg.RequestTraceCapacity <= 0 {
+		return nil
+	}
+	if redisClient != nil {
+		return reqtrace.NewRedisStore(redisClient, "yapi:trace", cfg.RequestTraceTTL)
+	}
+	return reqtrace.NewMemoryStore(cfg.RequestTraceCapacity)
+}
+
+// setupMetricsEndpoints 注册 /metrics 与可选的 /debug/pprof：设置 METRICS_LISTEN_ADDR 时在独立的内部监听地址上提供，
+// 不再经过网关端口；METRICS_AUTH_TOKEN 非空时两类端点都要求 Bearer Token。
+// setupReloader 注册支持热更新的子系统：收到 SIGHUP 或 POST /admin/config/reload 时重新读取配置文件并应用变更。
+func setupReloader(path string, cfg config.Config, logger *slog.Logger, logLevel *slog.LevelVar, corsOrigins *middleware.CORSOrigins, internalHeaders *middleware.InternalHeaders, ipFilters ipFilters, requestLimits *middleware.RequestLimits, rateLimitDefaults *middleware.RateLimitDefaults, proxyInflight, adminInflight *middleware.InflightLimit, proxyHandler *proxy.Handler) *reload.Reloader {
+	return reload.New(path, cfg,
+		reload.WithLogger(logger),
+		reload.WithSubsystem("log_level", []string{"LOG_LEVEL"}, func(next config.Config) error {
+			var level slog.Level
+			if err := level.UnmarshalText([]byte(next.LogLevel)); err != nil {
+				return fmt.Errorf("invalid LOG_LEVEL: %w", err)
+			}
+			logLevel.Set(level)
+			return nil
+		}),
+		reload.WithSubsystem("cors", []string{"ADMIN_ALLOWED_ORIGINS"}, func(next config.Config) error {
+			corsOrigins.Set(next.AdminAllowedOrigins)
+			return nil
+		}),
+		reload.WithSubsystem("internal_headers", []string{"INTERNAL_HEADERS"}, func(next config.Config) error {
+			internalHeaders.Set(next.InternalHeaders)
+			return nil
+		}),
+		reload.WithSubsystem("ip_filter", []string{"IP_ALLOWLIST", "IP_DENYLIST", "ADMIN_IP_ALLOWLIST", "ADMIN_IP_DENYLIST", "PROXY_IP_ALLOWLIST", "PROXY_IP_DENYLIST"}, ipFilters.set),
+		reload.WithSubsystem("rate_limit", []string{"RATE_LIMIT_DEFAULT_RPM", "RATE_LIMIT_DEFAULT_CONCURRENCY"}, func(next config.Config) error {
+			rateLimitDefaults.Set(next.RateLimitDefaultRPM, next.RateLimitDefaultConcurrency)
+			return nil
+		}),
+		reload.WithSubsystem("request_limits", []string{"MAX_REQUEST_HEADERS", "MAX_REQUEST_HEADER_BYTES", "MAX_REQUEST_BODY_BYTES"}, func(next config.Config) error {
+			requestLimits.Set(requestSizeLimits(next))
+			return nil
+		}),
+		reload.WithSubsystem("inflight_limit", []string{"MAX_INFLIGHT_REQUESTS", "ADMIN_MAX_INFLIGHT_REQUESTS"}, func(next config.Config) error {
+			proxyInflight.Set(next.MaxInflightRequests)
+			adminInflight.Set(next.AdminMaxInflightRequests)
+			return nil
+		}),
+		reload.WithSubsystem("default_target", []string{"UPSTREAM_BASE_URL", "DEFAULT_RULE_MODE", "DEFAULT_RULE_METHODS", "DEFAULT_RULE_PATH_PREFIXES"}, func(next config.Config) error {
+			var target *url.URL
+			if next.UpstreamBaseURL != "" {
+				var err error
+				if target, err = url.Parse(next.UpstreamBaseURL); err != nil {
+					return fmt.Errorf("invalid UPSTREAM_BASE_URL: %w", err)
+				}
+			}
+			proxyHandler.SetDefaultRulePolicy(defaultRulePolicy(next))
+			proxyHandler.SetDefaultTarget(target)
+			return nil
+		}),
+		reload.WithSubsystem("client_auth", []string{"REQUIRE_CLIENT_AUTH"}, func(next config.Config) error {
+			proxyHandler.SetRequireClientAuth(next.RequireClientAuth)
+			return nil
+		}),
+	)
+}
+
+// defaultRulePolicy 把 DEFAULT_RULE_* 配置转换为代理的默认规则策略。
+func defaultRulePolicy(cfg config.Config) proxy.DefaultRulePolicy {
+	return proxy.DefaultRulePolicy{
+		Disabled:     cfg.DefaultRuleMode == config.DefaultRuleDisabled,
+		RequireAuth:  cfg.DefaultRuleMode == config.DefaultRuleAuthenticated,
+		Methods:      cfg.DefaultRuleMethods,
+		PathPrefixes: cfg.DefaultRulePathPrefixes,
+	}
+}
+
+func setupMetricsEndpoints(ctx context.Context, cfg config.Config, router *gin.Engine) {
+	opts := []metricsserver.Option{
+		metricsserver.WithBearerToken(cfg.MetricsAuthToken),
+		metricsserver.WithPprof(cfg.PprofEnabled),
+	}
+	if cfg.MetricsListenAddr == "" {
+		if cfg.PprofEnabled && cfg.MetricsAuthToken == "" {
+			log.Println("warning: PPROF_ENABLED 已开启但未设置 METRICS_LISTEN_ADDR 或 METRICS_AUTH_TOKEN，/debug/pprof 将在网关端口上公开")
+		}
+		metricsserver.RegisterRoutes(router, opts...)
+		return
+	}
+	metricsRouter := gin.New()
+	metricsRouter.Use(gin.Recovery())
+	metricsserver.RegisterRoutes(metricsRouter, opts...)
+	server := &http.Server{
+		Addr:              cfg.MetricsListenAddr,
+		Handler:           metricsRouter,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("metrics server shutdown error: %v", err)
+		}
+	}()
+	go func() {
+		log.Printf("metrics listening on %s", server.Addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("metrics server error: %v", err)
+		}
+	}()
+}
+
+// setupFeatureFlags 注册运行时功能开关并应用 FEATURE_FLAGS 中的初始值，返回注册表与各开关句柄。
+func setupFeatureFlags(cfg config.Config) (registry *features.Registry, traces, bodyLogging *features.Toggle) {
+	registry, traces, bodyLogging = registerFeatureFlags(cfg)
+	if err := registry.Apply(cfg.FeatureFlags); err != nil {
+		log.Fatalf("invalid FEATURE_FLAGS: %v", err)
+	}
+	return registry, traces, bodyLogging
+}
+
+// registerFeatureFlags 注册运行时功能开关及其默认值。
+func registerFeatureFlags(cfg config.Config) (registry *features.Registry, traces, bodyLogging *features.Toggle) {
+	registry = features.NewRegistry()
+	traces = registry.Register(features.RequestTraces, "record per-request decision traces", true)
+	bodyLogging = registry.Register(features.BodyLogging, "log redacted proxy request and response bodies", cfg.BodyLogEnabled)
+	return registry, traces, bodyLogging
+}
+
+// setupBodyLogging 解析请求/响应体日志的脱敏路径；是否记录由 body_logging 功能开关控制。
+func setupBodyLogging(cfg config.Config, toggle *features.Toggle) proxy.BodyLogOptions {
+	policy, err := bodylog.ParsePolicy(cfg.BodyLogRedactPaths)
+	if err != nil {
+		log.Fatalf("invalid BODY_LOG_REDACT_PATHS: %v", err)
+	}
+	return proxy.BodyLogOptions{Policy: policy, MaxBytes: cfg.BodyLogMaxBytes, Toggle: toggle}
+}
+
+// setupAnalytics 按 ANALYTICS_SINK 创建分析事件写出器，未配置时返回 nil。
+// Postgres 目标未设置 ANALYTICS_DSN 时复用主数据库。返回的函数刷新剩余事件并释放独立连接。
+func setupAnalytics(ctx context.Context, cfg config.Config, startup *dbStartup, db *gorm.DB, eventLogger *slog.Logger) (*analytics.Sink, func()) {
+	if cfg.AnalyticsSink == "" {
+		return nil, func() {}
+	}
+	policy, err := analytics.ParseDropPolicy(cfg.AnalyticsDropPolicy)
+	if err != nil {
+		log.Fatalf("invalid ANALYTICS_DROP_POLICY: %v", err)
+	}
+	var (
+		writer  analytics.Writer
+		closeDB func() error
+	)
+	switch cfg.AnalyticsSink {
+	case "clickhouse":
+		if cfg.AnalyticsDSN == "" {
+			log.Fatalf("ANALYTICS_DSN is required for the clickhouse analytics sink")
+		}
+		writer, err = analytics.NewClickHouseWriter(cfg.AnalyticsDSN, cfg.AnalyticsTable, nil)
+		if err != nil {
+			log.Fatalf("invalid clickhouse analytics config: %v", err)
+		}
+	case "postgres":
+		analyticsDB := db
+		if cfg.AnalyticsDSN != "" {
+			analyticsDB, err = gorm.Open(postgres.Open(cfg.AnalyticsDSN), &gorm.Config{Logger: logger.Default.LogMode(logger.Warn)})
+			if err != nil {
+				log.Fatalf("failed to connect analytics database: %v", err)
+			}
+			sqlDB, err := analyticsDB.DB()
+			if err != nil {
+				log.Fatalf("failed to get analytics database connection: %v", err)
+			}
+			configureSQLDB(sqlDB, cfg)
+			closeDB = sqlDB.Close
+		}
+		if analyticsDB == nil {
+			log.Fatalf("ANALYTICS_DSN or DATABASE_DSN is required for the postgres analytics sink")
+		}
+		pgWriter, err := analytics.NewPostgresWriter(analyticsDB, cfg.AnalyticsTable)
+		if err != nil {
+			log.Fatalf("invalid postgres analytics config: %v", err)
+		}
+		if analyticsDB == db {
+			// 与主库共用时随主库推迟迁移；独立的分析库不参与降级模式。
+			startup.run(ctx, "analytics migration", pgWriter.AutoMigrate)
+		} else if err := pgWriter.AutoMigrate(ctx); err != nil {
+			log.Fatalf("analytics migration failed: %v", err)
+		}
+		writer = pgWriter
+	default:
+		log.Fatalf("invalid ANALYTICS_SINK %q: want clickhouse or postgres", cfg.AnalyticsSink)
+	}
+	sink := analytics.NewSink(writer, analytics.Options{
+		QueueSize:     cfg.AnalyticsQueueSize,
+		BatchSize:     cfg.AnalyticsBatchSize,
+		FlushInterval: cfg.AnalyticsFlushInterval,
+		DropPolicy:    policy,
+		BlockTimeout:  cfg.AnalyticsBlockTimeout,
+		Logger:        eventLogger,
+	})
+	return sink, func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := sink.Close(closeCtx); err != nil {
+			log.Printf("analytics flush error: %v", err)
+		}
+		if closeDB != nil {
+			if err := closeDB(); err != nil {
+				log.Printf("analytics database close error: %v", err)
+			}
+		}
+	}
+}
+
+// setupOIDC 在配置了 ADMIN_OIDC_ISSUER_URL 时创建 OIDC 提供方，配置不完整时拒绝启动。
+func setupOIDC(cfg config.Config) *oidc.Provider {
+	if cfg.AdminOIDCIssuerURL == "" {
+		return nil
+	}
+	mapping, err := oidc.ParseRoleMapping(cfg.AdminOIDCRoleMapping)
+	if err != nil {
+		log.Fatalf("invalid ADMIN_OIDC_ROLE_MAPPING: %v", err)
+	}
+	provider, err := oidc.NewProvider(oidc.Config{
+		IssuerURL:    cfg.AdminOIDCIssuerURL,
+		ClientID:     cfg.AdminOIDCClientID,
+		ClientSecret: cfg.AdminOIDCClientSecret,
+		RedirectURL:  cfg.AdminOIDCRedirectURL,
+		Scopes:       cfg.AdminOIDCScopes,
+		GroupsClaim:  cfg.AdminOIDCGroupsClaim,
+		RoleMapping:  mapping,
+	})
+	if err != nil {
+		log.Fatalf("oidc setup failed: %v", err)
+	}
+	if cfg.AdminTokenSecret == "" {
+		log.Fatalf("oidc login requires ADMIN_TOKEN_SECRET")
+	}
+	return provider
+}
+
+// setupHealthChecker 为已启用的依赖注册就绪检查：数据库与 Redis 连通性，以及规则缓存同步状态。
+func setupHealthChecker(db *gorm.DB, startup *dbStartup, redisClient redis.UniversalClient, ruleService rules.Service) *health.Checker {
+	opts := []health.Option{
+		health.WithCheck("rules", func(ctx context.Context) error {
+			return rules.CheckSynced(ctx, ruleService)
+		}),
+	}
+	if db != nil {
+		opts = append(opts, health.WithCheck("database", func(ctx context.Context) error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return startup.check(ctx, sqlDB)
+		}))
+	}
+	if redisClient != nil {
+		opts = append(opts, health.WithCheck("redis", func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		}))
+	}
+	return health.NewChecker(opts...)
+}
+
+// dbPool 返回 DB_* 连接池设置，主库与只读副本共用。
+func dbPool(cfg config.Config) dbreplica.Pool {
+	return dbreplica.Pool{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+	}
+}
+
+func configureSQLDB(db *sql.DB, cfg config.Config) {
+	pool := dbPool(cfg)
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+}
+
+func setupSecrets(cfg config.Config) *secrets.CachingResolver {
+	providers := []secrets.Provider{secrets.EnvProvider{Allow: cfg.SecretsEnvAllowlist}}
+	if cfg.VaultAddr != "" {
+		providers = append(providers, secrets.NewVaultProvider(cfg.VaultAddr, cfg.VaultToken, cfg.VaultNamespace, cfg.SecretsVaultAllowlist))
+	}
+	if cfg.AWSRegion != "" {
+		providers = append(providers, secrets.NewAWSSecretsManagerProvider(cfg.AWSRegion, cfg.AWSSecretsEndpoint, secrets.AWSCredentials{
+			AccessKeyID:     cfg.AWSAccessKeyID,
+			SecretAccessKey: cfg.AWSSecretAccessKey,
+			SessionToken:    cfg.AWSSessionToken,
+		}, cfg.SecretsAWSAllowlist))
+	}
+	return secrets.NewCachingResolver(cfg.SecretsCacheTTL, providers...)
+}
+
+// setupAccessLog 按 ACCESS_LOG_SINKS 创建访问日志 logger；未配置时沿用应用日志（标准输出，受 LOG_LEVEL 控制）。
+func setupAccessLog(cfg config.Config, logger *slog.Logger) (*slog.Logger, func() error) {
+	sinks, err := accesslog.Parse(cfg.AccessLogSinks)
+	if err != nil {
+		log.Fatalf("invalid ACCESS_LOG_SINKS: %v", err)
+	}
+	if len(sinks) == 0 {
+		return logger, func() error { return nil }
+	}
+	accessLogger, closer, err := accesslog.Open(sinks)
+	if err != nil {
+		log.Fatalf("open access log sinks failed: %v", err)
+	}
+	return accessLogger, closer.Close
+}
+
+// setupTracing 安装 OpenTelemetry 传播器与 OTLP 导出器，返回退出时刷新 span 的函数。
+func setupTracing(ctx context.Context, cfg config.Config) func(context.Context) error {
+	shutdown, err := telemetry.Setup(ctx, tracingConfig(cfg))
+	if err != nil {
+		log.Fatalf("invalid tracing config: %v", err)
+	}
+	return shutdown
+}
+
+func tracingConfig(cfg config.Config) telemetry.Config {
+	return telemetry.Config{
+		Endpoint:       cfg.OTelExporterEndpoint,
+		TracesEndpoint: cfg.OTelTracesEndpoint,
+		ServiceName:    cfg.OTelServiceName,
+		Disabled:       cfg.OTelSDKDisabled,
+	}
+}
+
+// setupRedis 连接 Redis 并创建规则缓存与事件总线；Redis 不可达时返回 nil 与 Ping 错误，调用方回退到进程内实现。
+// redisSettings 汇总 Redis 连接设置，REDIS_URL 优先于 REDIS_ADDR。
+func redisSettings(cfg config.Config) redisconn.Settings {
+	return redisconn.Settings{
+		URL:              cfg.RedisURL,
+		Addr:             cfg.RedisAddr,
+		Username:         cfg.RedisUsername,
+		Password:         cfg.RedisPassword,
+		DB:               cfg.RedisDB,
+		TLS:              cfg.RedisTLS,
+		SentinelMaster:   cfg.RedisSentinelMaster,
+		SentinelPassword: cfg.RedisSentinelPassword,
+		Cluster:          cfg.RedisCluster,
+	}
+}
+
+func setupRedis(ctx context.Context, cfg config.Config) (redis.UniversalClient, rules.Cache, rules.EventBus, error) {
+	options, err := redisconn.Options(redisSettings(cfg))
+	if err != nil {
+		log.Fatalf("failed to configure redis: %v", err)
+	}
 	switch cfg.RedisMaintMode {
 	case config.RedisMaintModeAuto:
 		options.MaintNotificationsConfig = maintnotifications.DefaultConfig()
@@ -234,15 +1133,113 @@ func setupRedis(ctx context.Context, cfg config.Config) (*redis.Client, rules.Ca
 		options.MaintNotificationsConfig = &maintnotifications.Config{Mode: maintnotifications.ModeDisabled}
 	}
 
-	client := redis.NewClient(options)
+	client := redis.NewUniversalClient(options)
 	pingCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 	if err := client.Ping(pingCtx).Err(); err != nil {
 		log.Printf("redis ping failed, fallback to in-memory cache: %v", err)
 		_ = client.Close()
-		return nil, nil, nil
+		return nil, nil, nil, err
 	}
+	log.Printf("redis connected: %s", redisTarget(cfg))
 	cache := rules.NewRedisCache(client, "rules:all", 0)
 	eventBus := rules.NewRedisEventBus(client, cfg.RedisChannel)
-	return client, cache, eventBus
+	return client, cache, eventBus, nil
+}
+
+// redisTarget 描述 Redis 部署形态与地址（不含凭据），如 sentinel mymaster@10.0.0.1:26379。
+func redisTarget(cfg config.Config) string {
+	options, err := redisconn.Options(redisSettings(cfg))
+	if err != nil {
+		return "invalid"
+	}
+	target := strings.Join(options.Addrs, ",")
+	if options.MasterName != "" {
+		target = options.MasterName + "@" + target
+	}
+	return redisconn.Mode(options) + " " + target
+}
+
+// runtimeConfig 汇总生效配置与各组件实际使用的后端，供 GET /admin/config 排查降级原因。
+// 各项取值与上文 setup* 函数的选择逻辑保持一致。
+func runtimeConfig(cfg config.Config, db *gorm.DB, redisClient redis.UniversalClient, redisErr error, traceStore reqtrace.Store) admin.RuntimeConfig {
+	pick := func(enabled bool, yes, no string) string {
+		if enabled {
+			return yes
+		}
+		return no
+	}
+	hasDB, hasRedis := db != nil, redisClient != nil
+	secretProviders := []string{"env"}
+	if cfg.VaultAddr != "" {
+		secretProviders = append(secretProviders, "vault")
+	}
+	if cfg.AWSRegion != "" {
+		secretProviders = append(secretProviders, "aws_secrets_manager")
+	}
+	report := admin.RuntimeConfig{
+		Settings: cfg.Settings(),
+		Backends: map[string]string{
+			"rules_store":      pick(hasDB, "postgres", "memory"),
+			"db_read_replicas": pick(hasDB && len(cfg.DatabaseReplicaDSNs) > 0, fmt.Sprint(len(cfg.DatabaseReplicaDSNs)), "none"),
+			"redis":            pick(hasRedis, redisTarget(cfg), "none"),
+			"rules_cache":      pick(hasRedis, "redis", "none"),
+			"event_bus":        pick(hasRedis, "redis", "memory"),
+			"accounts":         pick(hasDB, "postgres", "disabled"),
+			"audit_store":      pick(hasDB, "postgres", "memory"),
+			"admin_users":      pick(hasDB, "postgres", "env"),
+			"service_tokens":   pick(hasDB, "postgres", "disabled"),
+			"rate_limiter":     pick(!hasDB, "disabled", pick(hasRedis, "redis", "memory")),
+			"token_revocation": pick(hasRedis, "redis", "memory"),
+			"login_attempts":   pick(hasRedis, "redis", "memory"),
+			"request_traces":   pick(traceStore == nil, "disabled", pick(hasRedis, "redis", "memory")),
+			"conversations":    pick(cfg.ArchiveEncryptionKey == "", "disabled", pick(hasDB, "postgres", "memory")),
+			"dispatch_queue":   pick(cfg.UpstreamMaxConcurrency > 0, "memory", "disabled"),
+			"budgets":          pick(hasDB, "postgres", "memory"),
+			"budget_email":     pick(cfg.SMTPAddr == "", "disabled", "smtp"),
+			"trace_export":     traceExportBackend(cfg),
+			"secrets":          strings.Join(secretProviders, ","),
+			"tracing":          pick(tracingConfig(cfg).Enabled(), "otlp", "disabled"),
+			"access_log":       accessLogBackend(cfg),
+			"analytics":        pick(cfg.AnalyticsSink == "", "disabled", cfg.AnalyticsSink),
+			"metrics_listener": pick(cfg.MetricsListenAddr == "", "gateway", cfg.MetricsListenAddr),
+			"admin_listener":   pick(cfg.AdminPort == "", "gateway", listenAddr(cfg.AdminPort)),
+			"tls":              pick(len(cfg.TLSACMEDomains) > 0, "acme", pick(cfg.TLSCertFile == "", "disabled", "files")),
+		},
+	}
+	if !hasDB {
+		report.Warnings = append(report.Warnings, "DATABASE_DSN not set: rules are kept in memory and account management is disabled")
+	}
+	if redisErr != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("redis %s unavailable at startup, using in-memory cache, event bus and limiters: %v", redisTarget(cfg), redisErr))
+	}
+	return report
+}
+
+// traceExportBackend 列出已配置凭据的导出平台，与 setupTraceExport 的选择保持一致。
+func traceExportBackend(cfg config.Config) string {
+	var providers []string
+	if cfg.LangfusePublicKey != "" {
+		providers = append(providers, traceexport.ProviderLangfuse)
+	}
+	if cfg.LangSmithAPIKey != "" {
+		providers = append(providers, traceexport.ProviderLangSmith)
+	}
+	if len(providers) == 0 {
+		return "disabled"
+	}
+	return strings.Join(providers, ",")
+}
+
+// accessLogBackend 描述访问日志的输出目标，与 setupAccessLog 的选择保持一致。
+func accessLogBackend(cfg config.Config) string {
+	sinks, _ := accesslog.Parse(cfg.AccessLogSinks)
+	if len(sinks) == 0 {
+		return "stdout"
+	}
+	names := make([]string, len(sinks))
+	for i, sink := range sinks {
+		names[i] = sink.String()
+	}
+	return strings.Join(names, ",")
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/prehisle/yapi/internal/accesslog"
+	"github.com/prehisle/yapi/internal/admin"
+	"github.com/prehisle/yapi/internal/analytics"
+	"github.com/prehisle/yapi/internal/archive"
+	"github.com/prehisle/yapi/internal/bodylog"
+	"github.com/prehisle/yapi/internal/dispatch"
+	"github.com/prehisle/yapi/internal/oidc"
+	"github.com/prehisle/yapi/internal/redisconn"
+	"github.com/prehisle/yapi/internal/tlsconfig"
+	"github.com/prehisle/yapi/internal/usage"
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/config"
+)
+
+// validateConfig 实现 --validate-config：输出全部配置问题并返回进程退出码，配置有效时为 0。
+func validateConfig(stdout, stderr io.Writer, cfg config.Config, loadErr error) int {
+	if loadErr != nil {
+		fmt.Fprintf(stderr, "load config: %v\n", loadErr)
+		return 1
+	}
+	if err := checkConfig(cfg); err != nil {
+		fmt.Fprintf(stderr, "config is invalid:\n%v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, "config is valid")
+	return 0
+}
+
+// checkConfig 汇总 config.Validate 与各子系统解析器发现的问题，不连接数据库或 Redis；
+// 配置了证书文件时会读取证书以确认文件存在且与私钥匹配。
+func checkConfig(cfg config.Config) error {
+	errs := []error{config.Validate(cfg)}
+	add := func(name string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	registry, _, _ := registerFeatureFlags(cfg)
+	add("FEATURE_FLAGS", registry.Apply(cfg.FeatureFlags))
+	_, err := accesslog.Parse(cfg.AccessLogSinks)
+	add("ACCESS_LOG_SINKS", err)
+	_, err = bodylog.ParsePolicy(cfg.BodyLogRedactPaths)
+	add("BODY_LOG_REDACT_PATHS", err)
+	_, err = usage.ParsePricing(cfg.ModelPricing)
+	add("MODEL_PRICING", err)
+	_, err = dispatch.ParseTiers(cfg.UpstreamQueueTiers)
+	add("UPSTREAM_QUEUE_TIERS", err)
+	_, err = accounts.NewSecretHasher(accounts.HashConfig{
+		Algorithm:  cfg.APIKeyHashAlgorithm,
+		BcryptCost: cfg.APIKeyBcryptCost,
+		HMACKey:    []byte(cfg.APIKeyHMACSecret),
+	})
+	add("API_KEY_HASH_ALGORITHM", err)
+	if _, ok := admin.ParseSameSite(cfg.AdminSessionCookieSameSite); !ok {
+		add("ADMIN_SESSION_COOKIE_SAMESITE", fmt.Errorf("%q: want strict, lax or none", cfg.AdminSessionCookieSameSite))
+	}
+	if cfg.AnalyticsSink != "" {
+		_, err = analytics.ParseDropPolicy(cfg.AnalyticsDropPolicy)
+		add("ANALYTICS_DROP_POLICY", err)
+	}
+	if cfg.AdminOIDCIssuerURL != "" {
+		_, err = oidc.ParseRoleMapping(cfg.AdminOIDCRoleMapping)
+		add("ADMIN_OIDC_ROLE_MAPPING", err)
+	}
+	if cfg.ArchiveEncryptionKey != "" {
+		_, err = archive.ParseKey(cfg.ArchiveEncryptionKey)
+		add("ARCHIVE_ENCRYPTION_KEY", err)
+	}
+	_, err = redisconn.Options(redisSettings(cfg))
+	add("REDIS_URL", err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = tlsconfig.New(ctx, tlsOptions(cfg, slog.New(slog.DiscardHandler)))
+	add("TLS", err)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,313 @@
+// Package accesslog 根据 ACCESS_LOG_SINKS 构建访问日志的输出目标。
+// 每个目标独立设置最低级别与采样率，记录以 JSON 写出：标准输出、按大小轮转的文件或 syslog。
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// ErrInvalidSink 表示目标配置格式错误。
+var ErrInvalidSink = errors.New("invalid access log sink")
+
+// Kind 是目标类型。
+type Kind string
+
+const (
+	KindStdout Kind = "stdout"
+	KindStderr Kind = "stderr"
+	KindFile   Kind = "file"
+	KindSyslog Kind = "syslog"
+)
+
+// SinkConfig 描述单个输出目标。
+type SinkConfig struct {
+	Kind Kind
+	// Path 为文件目标的路径。
+	Path string
+	// Network 与 Address 为远程 syslog 的协议与地址，均为空时写入本机 syslog。
+	Network string
+	Address string
+	// Tag 为 syslog 消息的标识，默认 yapi。
+	Tag string
+	// Level 为最低记录级别；访问日志按状态码分级，5xx 为 error、4xx 为 warn、其余为 info。
+	Level slog.Level
+	// SampleRate 为 (0, 1] 区间的采样率，1 表示全部记录。
+	SampleRate float64
+	// MaxSizeMB、MaxBackups、MaxAgeDays 与 Compress 控制文件轮转。
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+	Compress   bool
+}
+
+// String 返回目标的简短描述，用于运行时配置报告。
+func (s SinkConfig) String() string {
+	switch s.Kind {
+	case KindFile:
+		return "file:" + s.Path
+	case KindSyslog:
+		if s.Address != "" {
+			return "syslog:" + s.Network + "://" + s.Address
+		}
+	}
+	return string(s.Kind)
+}
+
+// Parse 解析以逗号分隔的目标列表，每项形如 `类型[:参数][?选项]`：
+//
+//	stdout?level=info&sample=0.1
+//	file:/var/log/yapi/access.log?max_size_mb=100&max_backups=7&max_age_days=30&compress=true
+//	syslog、syslog://host:514（UDP）、syslog+tcp://host:514，可加 tag=yapi
+//
+// 所有目标都支持 level（debug / info / warn / error，默认 info）与 sample（默认 1）。
+func Parse(spec string) ([]SinkConfig, error) {
+	var sinks []SinkConfig
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		sink, err := parseSink(entry)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+func parseSink(entry string) (SinkConfig, error) {
+	target, rawQuery, _ := strings.Cut(entry, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return SinkConfig{}, fmt.Errorf("%w %q: %v", ErrInvalidSink, entry, err)
+	}
+	sink := SinkConfig{Level: slog.LevelInfo, SampleRate: 1}
+	switch {
+	case target == "stdout":
+		sink.Kind = KindStdout
+	case target == "stderr":
+		sink.Kind = KindStderr
+	case strings.HasPrefix(target, "file:"):
+		sink.Kind = KindFile
+		sink.Path = strings.TrimPrefix(strings.TrimPrefix(target, "file:"), "//")
+		if sink.Path == "" {
+			return SinkConfig{}, fmt.Errorf("%w %q: file path required", ErrInvalidSink, entry)
+		}
+		sink.MaxSizeMB, sink.MaxBackups, sink.MaxAgeDays = 100, 7, 30
+	case target == "syslog":
+		sink.Kind = KindSyslog
+	case strings.HasPrefix(target, "syslog://"), strings.HasPrefix(target, "syslog+udp://"), strings.HasPrefix(target, "syslog+tcp://"):
+		sink.Kind = KindSyslog
+		scheme, address, _ := strings.Cut(target, "://")
+		sink.Network = "udp"
+		if scheme == "syslog+tcp" {
+			sink.Network = "tcp"
+		}
+		if address == "" {
+			return SinkConfig{}, fmt.Errorf("%w %q: syslog address required", ErrInvalidSink, entry)
+		}
+		sink.Address = address
+	default:
+		return SinkConfig{}, fmt.Errorf("%w %q: want stdout, stderr, file:<path> or syslog", ErrInvalidSink, entry)
+	}
+	for key, values := range query {
+		value := values[len(values)-1]
+		var err error
+		switch key {
+		case "level":
+			err = sink.Level.UnmarshalText([]byte(value))
+		case "sample":
+			sink.SampleRate, err = strconv.ParseFloat(value, 64)
+			if err == nil && (sink.SampleRate <= 0 || sink.SampleRate > 1) {
+				err = errors.New("sample must be in (0, 1]")
+			}
+		case "tag":
+			sink.Tag = value
+		case "max_size_mb":
+			sink.MaxSizeMB, err = strconv.Atoi(value)
+		case "max_backups":
+			sink.MaxBackups, err = strconv.Atoi(value)
+		case "max_age_days":
+			sink.MaxAgeDays, err = strconv.Atoi(value)
+		case "compress":
+			sink.Compress, err = strconv.ParseBool(value)
+		default:
+			err = errors.New("unknown option")
+		}
+		if err != nil {
+			return SinkConfig{}, fmt.Errorf("%w %q: option %s: %v", ErrInvalidSink, entry, key, err)
+		}
+	}
+	return sink, nil
+}
+
+// Open 打开所有目标并返回写入它们的 logger。返回的 io.Closer 关闭文件与 syslog 连接。
+func Open(sinks []SinkConfig) (*slog.Logger, io.Closer, error) {
+	handlers := make([]slog.Handler, 0, len(sinks))
+	closers := make(closers, 0, len(sinks))
+	for _, sink := range sinks {
+		writer, err := openWriter(sink)
+		if err != nil {
+			_ = closers.Close()
+			return nil, nil, fmt.Errorf("open access log sink %s: %w", sink, err)
+		}
+		closers = append(closers, writer)
+		handlers = append(handlers, newSinkHandler(writer, sink.Level, sink.SampleRate))
+	}
+	return slog.New(fanout(handlers)), closers, nil
+}
+
+// levelWriter 按记录级别写出一条已编码的日志，syslog 目标据此选择消息优先级。
+type levelWriter interface {
+	WriteLevel(level slog.Level, p []byte) error
+	io.Closer
+}
+
+func openWriter(sink SinkConfig) (levelWriter, error) {
+	switch sink.Kind {
+	case KindStdout:
+		return plainWriter{Writer: os.Stdout}, nil
+	case KindStderr:
+		return plainWriter{Writer: os.Stderr}, nil
+	case KindFile:
+		return plainWriter{Writer: &lumberjack.Logger{
+			Filename:   sink.Path,
+			MaxSize:    sink.MaxSizeMB,
+			MaxBackups: sink.MaxBackups,
+			MaxAge:     sink.MaxAgeDays,
+			Compress:   sink.Compress,
+		}}, nil
+	case KindSyslog:
+		tag := sink.Tag
+		if tag == "" {
+			tag = "yapi"
+		}
+		return dialSyslog(sink.Network, sink.Address, tag)
+	}
+	return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidSink, sink.Kind)
+}
+
+// plainWriter 忽略级别直接写出；标准输出与标准错误不应被关闭。
+type plainWriter struct {
+	io.Writer
+}
+
+func (w plainWriter) WriteLevel(_ slog.Level, p []byte) error {
+	_, err := w.Write(p)
+	return err
+}
+
+func (w plainWriter) Close() error {
+	if closer, ok := w.Writer.(io.Closer); ok && w.Writer != os.Stdout && w.Writer != os.Stderr {
+		return closer.Close()
+	}
+	return nil
+}
+
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var errs []error
+	for _, closer := range c {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// sinkState 是同一目标派生出的各 handler 共享的编码缓冲与写出器。
+type sinkState struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	writer levelWriter
+	sample float64
+	random func() float64
+}
+
+// sinkHandler 先把记录编码为 JSON 写入共享缓冲，再按级别交给写出器。
+type sinkHandler struct {
+	state *sinkState
+	json  slog.Handler
+}
+
+func newSinkHandler(writer levelWriter, level slog.Level, sample float64) *sinkHandler {
+	state := &sinkState{writer: writer, sample: sample, random: rand.Float64}
+	return &sinkHandler{state: state, json: slog.NewJSONHandler(&state.buf, &slog.HandlerOptions{Level: level})}
+}
+
+func (h *sinkHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.json.Enabled(ctx, level)
+}
+
+func (h *sinkHandler) Handle(ctx context.Context, record slog.Record) error {
+	state := h.state
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.sample < 1 && state.random() >= state.sample {
+		return nil
+	}
+	state.buf.Reset()
+	if err := h.json.Handle(ctx, record); err != nil {
+		return err
+	}
+	return state.writer.WriteLevel(record.Level, state.buf.Bytes())
+}
+
+func (h *sinkHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sinkHandler{state: h.state, json: h.json.WithAttrs(attrs)}
+}
+
+func (h *sinkHandler) WithGroup(name string) slog.Handler {
+	return &sinkHandler{state: h.state, json: h.json.WithGroup(name)}
+}
+
+// fanout 把记录分发给所有启用了该级别的目标。
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, record.Level) {
+			errs = append(errs, h.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
@@ -0,0 +1,92 @@
+package accesslog
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Sinks(t *testing.T) {
+	sinks, err := Parse("stdout?sample=0.25, file:/var/log/yapi/access.log?level=warn&max_backups=3&compress=true,syslog+tcp://logs:601?tag=gw&level=error")
+	require.NoError(t, err)
+	require.Equal(t, []SinkConfig{
+		{Kind: KindStdout, Level: slog.LevelInfo, SampleRate: 0.25},
+		{Kind: KindFile, Path: "/var/log/yapi/access.log", Level: slog.LevelWarn, SampleRate: 1, MaxSizeMB: 100, MaxBackups: 3, MaxAgeDays: 30, Compress: true},
+		{Kind: KindSyslog, Network: "tcp", Address: "logs:601", Tag: "gw", Level: slog.LevelError, SampleRate: 1},
+	}, sinks)
+	require.Equal(t, "syslog:tcp://logs:601", sinks[2].String())
+
+	empty, err := Parse(" ")
+	require.NoError(t, err)
+	require.Empty(t, empty)
+
+	for _, spec := range []string{"kafka://broker", "file:", "stdout?level=loud", "stdout?sample=0", "stdout?sample=2", "stdout?color=true", "syslog://"} {
+		_, err := Parse(spec)
+		require.ErrorIs(t, err, ErrInvalidSink, spec)
+	}
+}
+
+func TestOpen_FileSinkFiltersByLevel(t *testing.T) {
+	dir := t.TempDir()
+	all, warn := filepath.Join(dir, "all.log"), filepath.Join(dir, "warn.log")
+	sinks, err := Parse("file:" + all + ",file:" + warn + "?level=warn")
+	require.NoError(t, err)
+	logger, closer, err := Open(sinks)
+	require.NoError(t, err)
+
+	logger.With("component", "access").Info("http request", "status", 200)
+	logger.Warn("http request", "status", 404)
+	logger.Error("http request", "status", 502)
+	require.NoError(t, closer.Close())
+
+	readStatuses := func(path string) []float64 {
+		file, err := os.Open(path)
+		require.NoError(t, err)
+		defer file.Close()
+		var statuses []float64
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var record map[string]any
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+			require.Equal(t, "http request", record["msg"])
+			statuses = append(statuses, record["status"].(float64))
+		}
+		return statuses
+	}
+	require.Equal(t, []float64{200, 404, 502}, readStatuses(all))
+	require.Equal(t, []float64{404, 502}, readStatuses(warn))
+}
+
+func TestSinkHandler_Samples(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sampled.log")
+	sinks, err := Parse("file:" + path + "?sample=0.5")
+	require.NoError(t, err)
+	logger, closer, err := Open(sinks)
+	require.NoError(t, err)
+	draws := []float64{0.1, 0.7, 0.49, 0.5}
+	logger.Handler().(fanout)[0].(*sinkHandler).state.random = func() float64 {
+		draw := draws[0]
+		draws = draws[1:]
+		return draw
+	}
+	for range 4 {
+		logger.Info("http request")
+	}
+	require.NoError(t, closer.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := 0
+	for _, b := range data {
+		if b == '\n' {
+			lines++
+		}
+	}
+	require.Equal(t, 2, lines)
+}
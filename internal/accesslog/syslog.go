@@ -0,0 +1,35 @@
+//go:build !windows && !plan9
+
+package accesslog
+
+import (
+	"log/slog"
+	"log/syslog"
+)
+
+// syslogWriter 按记录级别选择 syslog 优先级。
+type syslogWriter struct {
+	*syslog.Writer
+}
+
+func dialSyslog(network, address, tag string) (levelWriter, error) {
+	writer, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_LOCAL0, tag)
+	if err != nil {
+		return nil, err
+	}
+	return syslogWriter{Writer: writer}, nil
+}
+
+func (w syslogWriter) WriteLevel(level slog.Level, p []byte) error {
+	message := string(p)
+	switch {
+	case level >= slog.LevelError:
+		return w.Err(message)
+	case level >= slog.LevelWarn:
+		return w.Warning(message)
+	case level >= slog.LevelInfo:
+		return w.Info(message)
+	default:
+		return w.Debug(message)
+	}
+}
@@ -0,0 +1,507 @@
+package admin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// ErrInvalidDesiredState 表示期望状态文档不合法，如 ID 重复或引用了不属于该用户的 API Key。
+var ErrInvalidDesiredState = errors.New("invalid desired state")
+
+// ErrPlanChanged 表示确认执行时计算出的变更计划与预览时的指纹不一致。
+var ErrPlanChanged = errors.New("plan changed since preview")
+
+// apply 计划中的变更动作。
+const (
+	ApplyActionCreate = "create"
+	ApplyActionUpdate = "update"
+	ApplyActionDelete = "delete"
+)
+
+// DesiredState 是声明式 apply 的期望状态文档。
+type DesiredState struct {
+	Rules    []rules.Rule     `json:"rules"`
+	Users    []DesiredUser    `json:"users"`
+	Bindings []DesiredBinding `json:"bindings"`
+	// Prune 为 true 时删除文档中未声明的规则，以及已声明用户名下未声明的绑定；用户本身不会被删除。
+	Prune bool `json:"prune"`
+}
+
+// DesiredUser 以用户名标识用户，其余字段为期望值。
+type DesiredUser struct {
+	Name                  string         `json:"name"`
+	Description           string         `json:"description,omitempty"`
+	Metadata              map[string]any `json:"metadata,omitempty"`
+	MaxRequestsPerMinute  int            `json:"max_requests_per_minute,omitempty"`
+	MaxConcurrentRequests int            `json:"max_concurrent_requests,omitempty"`
+	AllowedModels         []string       `json:"allowed_models,omitempty"`
+	DeniedModels          []string       `json:"denied_models,omitempty"`
+}
+
+// DesiredBinding 描述 API Key 在某个 service + position 上应绑定的上游凭据。
+// User 为用户名，必须同时出现在 DesiredState.Users 中；Service 为空时取凭据的 Provider。
+type DesiredBinding struct {
+	User       string `json:"user"`
+	APIKeyID   string `json:"api_key_id"`
+	UpstreamID string `json:"upstream_id"`
+	Service    string `json:"service,omitempty"`
+	Position   int    `json:"position"`
+}
+
+// ApplyChange 是计划中的单项变更；ID 为规则 ID、用户名或 "<api_key_id>/<service>/<position>"。
+type ApplyChange struct {
+	ResourceType string `json:"resource_type"`
+	Action       string `json:"action"`
+	ID           string `json:"id"`
+	Before       any    `json:"before,omitempty"`
+	After        any    `json:"after,omitempty"`
+}
+
+// ApplyPlan 是期望状态与当前状态的差异。Fingerprint 由变更内容计算，确认执行时据此判断状态是否已漂移。
+type ApplyPlan struct {
+	Fingerprint string        `json:"fingerprint"`
+	Changes     []ApplyChange `json:"changes"`
+	Applied     bool          `json:"applied"`
+
+	steps []applyStep
+}
+
+// ApplyOptions 控制 apply 的执行。
+type ApplyOptions struct {
+	// Actor 记录为规则的修改人。
+	Actor string
+	// Fingerprint 非空时必须与重新计算的计划一致，否则返回 ErrPlanChanged。
+	Fingerprint string
+}
+
+type applyStep struct {
+	do   func(ctx context.Context) error
+	undo func(ctx context.Context) error
+}
+
+// PlanApply 计算期望状态与当前状态的差异，不做任何修改。
+func (s *service) PlanApply(ctx context.Context, desired DesiredState) (ApplyPlan, error) {
+	return s.plan(ctx, desired, "")
+}
+
+// plan 生成变更与对应的执行步骤；actor 只写入执行步骤，不参与指纹计算。
+func (s *service) plan(ctx context.Context, desired DesiredState, actor string) (ApplyPlan, error) {
+	var plan ApplyPlan
+	if err := s.planRules(ctx, desired, actor, &plan); err != nil {
+		return ApplyPlan{}, err
+	}
+	if err := s.planAccounts(ctx, desired, &plan); err != nil {
+		return ApplyPlan{}, err
+	}
+	fingerprint, err := planFingerprint(plan.Changes)
+	if err != nil {
+		return ApplyPlan{}, err
+	}
+	plan.Fingerprint = fingerprint
+	return plan, nil
+}
+
+// applyRollbackTimeout 限制回滚的总耗时。回滚不随请求取消，客户端断开后仍会执行完毕。
+const applyRollbackTimeout = 30 * time.Second
+
+// Apply 计算并执行计划。任一步骤失败时按相反顺序撤销已完成的步骤，使规则与账户回到执行前的状态。
+// 规则与账户分属不同的存储，无法放进同一个事务，因此同一实例上的 Apply 串行执行，避免两次 apply
+// 交错时各自的回滚相互覆盖；回滚使用与请求取消无关的上下文，客户端在执行中途断开时也能撤销完整。
+func (s *service) Apply(ctx context.Context, desired DesiredState, opts ApplyOptions) (ApplyPlan, error) {
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+	plan, err := s.plan(ctx, desired, opts.Actor)
+	if err != nil {
+		return ApplyPlan{}, err
+	}
+	if opts.Fingerprint != "" && opts.Fingerprint != plan.Fingerprint {
+		return ApplyPlan{}, ErrPlanChanged
+	}
+	for i, step := range plan.steps {
+		if err := step.do(ctx); err != nil {
+			change := plan.Changes[i]
+			err = fmt.Errorf("apply %s %s %s: %w", change.Action, change.ResourceType, change.ID, err)
+			rollbackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), applyRollbackTimeout)
+			for j := i - 1; j >= 0; j-- {
+				if undoErr := plan.steps[j].undo(rollbackCtx); undoErr != nil {
+					err = errors.Join(err, fmt.Errorf("rollback %s %s: %w", plan.Changes[j].ResourceType, plan.Changes[j].ID, undoErr))
+				}
+			}
+			cancel()
+			return ApplyPlan{}, err
+		}
+	}
+	plan.Applied = true
+	return plan, nil
+}
+
+func (s *service) planRules(ctx context.Context, desired DesiredState, actor string, plan *ApplyPlan) error {
+	current, err := s.rules.ListRules(ctx)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]rules.Rule, len(current))
+	for _, rule := range current {
+		existing[rule.ID] = rule
+	}
+	wanted := make(map[string]bool, len(desired.Rules))
+	sorted := append([]rules.Rule(nil), desired.Rules...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	for _, rule := range sorted {
+		rule = ruleSpec(rule)
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("%w: rule %q: %v", ErrInvalidDesiredState, rule.ID, err)
+		}
+		if wanted[rule.ID] {
+			return fmt.Errorf("%w: duplicate rule %q", ErrInvalidDesiredState, rule.ID)
+		}
+		wanted[rule.ID] = true
+		target := rule
+		target.UpdatedBy = actor
+		before, ok := existing[rule.ID]
+		if !ok {
+			plan.add(ApplyChange{ResourceType: auditResourceRule, Action: ApplyActionCreate, ID: rule.ID, After: rule}, applyStep{
+				do:   func(ctx context.Context) error { return s.rules.UpsertRule(ctx, target) },
+				undo: func(ctx context.Context) error { return s.rules.DeleteRule(ctx, target.ID) },
+			})
+			continue
+		}
+		if sameJSON(ruleSpec(before), rule) {
+			continue
+		}
+		plan.add(ApplyChange{ResourceType: auditResourceRule, Action: ApplyActionUpdate, ID: rule.ID, Before: ruleSpec(before), After: rule}, applyStep{
+			do:   func(ctx context.Context) error { return s.rules.UpsertRule(ctx, target) },
+			undo: func(ctx context.Context) error { return s.rules.UpsertRule(ctx, before) },
+		})
+	}
+	if !desired.Prune {
+		return nil
+	}
+	sort.Slice(current, func(i, j int) bool { return current[i].ID < current[j].ID })
+	for _, rule := range current {
+		if wanted[rule.ID] {
+			continue
+		}
+		before := rule
+		plan.add(ApplyChange{ResourceType: auditResourceRule, Action: ApplyActionDelete, ID: rule.ID, Before: ruleSpec(rule)}, applyStep{
+			do:   func(ctx context.Context) error { return s.rules.DeleteRule(ctx, before.ID) },
+			undo: func(ctx context.Context) error { return s.rules.UpsertRule(ctx, before) },
+		})
+	}
+	return nil
+}
+
+func (s *service) planAccounts(ctx context.Context, desired DesiredState, plan *ApplyPlan) error {
+	if len(desired.Users) == 0 && len(desired.Bindings) == 0 {
+		return nil
+	}
+	if s.accounts == nil {
+		return ErrAccountsUnavailable
+	}
+	current, _, err := s.accounts.ListUsers(ctx, accounts.ListOptions{})
+	if err != nil {
+		return err
+	}
+	usersByName := make(map[string]accounts.User, len(current))
+	for _, user := range current {
+		usersByName[user.Name] = user
+	}
+
+	declared := make(map[string]bool, len(desired.Users))
+	sortedUsers := append([]DesiredUser(nil), desired.Users...)
+	sort.Slice(sortedUsers, func(i, j int) bool { return sortedUsers[i].Name < sortedUsers[j].Name })
+	for _, user := range sortedUsers {
+		user.Name = strings.TrimSpace(user.Name)
+		user.Description = strings.TrimSpace(user.Description)
+		if len(user.Metadata) == 0 {
+			user.Metadata = nil
+		}
+		if err := toAccountUser(user).Validate(); err != nil {
+			return fmt.Errorf("%w: user %q: %v", ErrInvalidDesiredState, user.Name, err)
+		}
+		if declared[user.Name] {
+			return fmt.Errorf("%w: duplicate user %q", ErrInvalidDesiredState, user.Name)
+		}
+		declared[user.Name] = true
+		target := user
+		existing, ok := usersByName[user.Name]
+		if !ok {
+			var createdID string
+			plan.add(ApplyChange{ResourceType: auditResourceUser, Action: ApplyActionCreate, ID: user.Name, After: user}, applyStep{
+				do: func(ctx context.Context) error {
+					created, err := s.accounts.CreateUser(ctx, accounts.CreateUserParams{
+						Name:        target.Name,
+						Description: target.Description,
+						Metadata:    target.Metadata,
+						RateLimits:  toAccountUser(target).RateLimits,
+						ModelAccess: toAccountUser(target).ModelAccess,
+					})
+					createdID = created.ID
+					return err
+				},
+				undo: func(ctx context.Context) error { return s.accounts.DeleteUser(ctx, createdID) },
+			})
+			continue
+		}
+		before := fromAccountUser(existing)
+		if sameJSON(before, user) {
+			continue
+		}
+		userID := existing.ID
+		plan.add(ApplyChange{ResourceType: auditResourceUser, Action: ApplyActionUpdate, ID: user.Name, Before: before, After: user}, applyStep{
+			do: func(ctx context.Context) error {
+				_, err := s.accounts.UpdateUser(ctx, toUpdateUserParams(userID, target))
+				return err
+			},
+			undo: func(ctx context.Context) error {
+				_, err := s.accounts.UpdateUser(ctx, toUpdateUserParams(userID, before))
+				return err
+			},
+		})
+	}
+	return s.planBindings(ctx, desired, declared, usersByName, plan)
+}
+
+func (s *service) planBindings(ctx context.Context, desired DesiredState, declared map[string]bool, usersByName map[string]accounts.User, plan *ApplyPlan) error {
+	type currentBinding struct {
+		id       string
+		userID   string
+		metadata map[string]any
+		desired  DesiredBinding
+	}
+	existing := make(map[string]currentBinding)
+	var managed []string
+	for name := range declared {
+		user, ok := usersByName[name]
+		if !ok {
+			continue
+		}
+		managed = append(managed, name)
+		bindings, err := s.accounts.ListBindingsByUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		for _, item := range bindings {
+			binding := DesiredBinding{
+				User:       name,
+				APIKeyID:   item.Binding.UserAPIKeyID,
+				UpstreamID: item.Binding.UpstreamKeyID,
+				Service:    item.Binding.Service,
+				Position:   item.Binding.Position,
+			}
+			existing[binding.key()] = currentBinding{id: item.Binding.ID, userID: user.ID, metadata: item.Binding.Metadata, desired: binding}
+		}
+	}
+
+	wanted := make(map[string]bool, len(desired.Bindings))
+	var upserts []ApplyChange
+	var upsertSteps []applyStep
+	for _, binding := range desired.Bindings {
+		binding.User = strings.TrimSpace(binding.User)
+		if !declared[binding.User] {
+			return fmt.Errorf("%w: binding user %q must be declared in users", ErrInvalidDesiredState, binding.User)
+		}
+		user, ok := usersByName[binding.User]
+		if !ok {
+			return fmt.Errorf("%w: binding user %q does not exist yet; create it and its api keys first", ErrInvalidDesiredState, binding.User)
+		}
+		key, err := s.accounts.GetUserAPIKey(ctx, binding.APIKeyID)
+		if err != nil || key.UserID != user.ID {
+			return fmt.Errorf("%w: api key %q does not belong to user %q", ErrInvalidDesiredState, binding.APIKeyID, binding.User)
+		}
+		upstream, err := s.accounts.GetUpstreamCredential(ctx, binding.UpstreamID)
+		if err != nil || upstream.UserID != user.ID {
+			return fmt.Errorf("%w: upstream %q does not belong to user %q", ErrInvalidDesiredState, binding.UpstreamID, binding.User)
+		}
+		binding.Service = strings.TrimSpace(binding.Service)
+		if binding.Service == "" {
+			binding.Service = upstream.Service
+		}
+		id := binding.key()
+		if wanted[id] {
+			return fmt.Errorf("%w: duplicate binding %q", ErrInvalidDesiredState, id)
+		}
+		wanted[id] = true
+		target := binding
+		params := accounts.BindAPIKeyParams{
+			UserID:               user.ID,
+			UserAPIKeyID:         binding.APIKeyID,
+			UpstreamCredentialID: binding.UpstreamID,
+			Service:              binding.Service,
+			Position:             binding.Position,
+		}
+		before, ok := existing[id]
+		if !ok {
+			var createdID string
+			upserts = append(upserts, ApplyChange{ResourceType: auditResourceAPIBinding, Action: ApplyActionCreate, ID: id, After: target})
+			upsertSteps = append(upsertSteps, applyStep{
+				do: func(ctx context.Context) error {
+					created, err := s.accounts.BindAPIKey(ctx, params)
+					createdID = created.ID
+					return err
+				},
+				undo: func(ctx context.Context) error { return s.accounts.DeleteBinding(ctx, createdID) },
+			})
+			continue
+		}
+		if before.desired.UpstreamID == binding.UpstreamID {
+			continue
+		}
+		restore := params
+		restore.UpstreamCredentialID = before.desired.UpstreamID
+		upserts = append(upserts, ApplyChange{ResourceType: auditResourceAPIBinding, Action: ApplyActionUpdate, ID: id, Before: before.desired, After: target})
+		upsertSteps = append(upsertSteps, applyStep{
+			do: func(ctx context.Context) error {
+				_, err := s.accounts.BindAPIKey(ctx, params)
+				return err
+			},
+			undo: func(ctx context.Context) error {
+				_, err := s.accounts.BindAPIKey(ctx, restore)
+				return err
+			},
+		})
+	}
+	sortChanges(upserts, upsertSteps)
+
+	// 先删除再创建/更新，避免同一用户在过渡期间出现多余的绑定。
+	if desired.Prune {
+		var deletes []ApplyChange
+		var deleteSteps []applyStep
+		for id, current := range existing {
+			if wanted[id] {
+				continue
+			}
+			current := current
+			deletes = append(deletes, ApplyChange{ResourceType: auditResourceAPIBinding, Action: ApplyActionDelete, ID: id, Before: current.desired})
+			deleteSteps = append(deleteSteps, applyStep{
+				do: func(ctx context.Context) error { return s.accounts.DeleteBinding(ctx, current.id) },
+				undo: func(ctx context.Context) error {
+					_, err := s.accounts.BindAPIKey(ctx, accounts.BindAPIKeyParams{
+						UserID:               current.userID,
+						UserAPIKeyID:         current.desired.APIKeyID,
+						UpstreamCredentialID: current.desired.UpstreamID,
+						Service:              current.desired.Service,
+						Position:             current.desired.Position,
+						Metadata:             current.metadata,
+					})
+					return err
+				},
+			})
+		}
+		sortChanges(deletes, deleteSteps)
+		for i := range deletes {
+			plan.add(deletes[i], deleteSteps[i])
+		}
+	}
+	for i := range upserts {
+		plan.add(upserts[i], upsertSteps[i])
+	}
+	return nil
+}
+
+func (p *ApplyPlan) add(change ApplyChange, step applyStep) {
+	p.Changes = append(p.Changes, change)
+	p.steps = append(p.steps, step)
+}
+
+func (b DesiredBinding) key() string {
+	return fmt.Sprintf("%s/%s/%d", b.APIKeyID, b.Service, b.Position)
+}
+
+// sortChanges 按 ID 排序变更并保持步骤一一对应，使计划与指纹稳定。
+func sortChanges(changes []ApplyChange, steps []applyStep) {
+	order := make([]int, len(changes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return changes[order[i]].ID < changes[order[j]].ID })
+	sortedChanges := make([]ApplyChange, len(changes))
+	sortedSteps := make([]applyStep, len(steps))
+	for i, idx := range order {
+		sortedChanges[i] = changes[idx]
+		sortedSteps[i] = steps[idx]
+	}
+	copy(changes, sortedChanges)
+	copy(steps, sortedSteps)
+}
+
+// ruleSpec 去掉版本、时间与修改人等由服务维护的字段，只保留用于比较的规则内容。
+func ruleSpec(rule rules.Rule) rules.Rule {
+	rule.Version = 0
+	rule.CreatedBy = ""
+	rule.UpdatedBy = ""
+	rule.CreatedAt = time.Time{}
+	rule.UpdatedAt = time.Time{}
+	return rule
+}
+
+func toAccountUser(user DesiredUser) accounts.User {
+	return accounts.User{
+		Name:        user.Name,
+		Description: user.Description,
+		RateLimits: accounts.RateLimits{
+			MaxRequestsPerMinute:  user.MaxRequestsPerMinute,
+			MaxConcurrentRequests: user.MaxConcurrentRequests,
+		},
+		ModelAccess: accounts.ModelAccess{
+			AllowedModels: user.AllowedModels,
+			DeniedModels:  user.DeniedModels,
+		},
+	}
+}
+
+func fromAccountUser(user accounts.User) DesiredUser {
+	desired := DesiredUser{
+		Name:                  user.Name,
+		Description:           user.Description,
+		MaxRequestsPerMinute:  user.MaxRequestsPerMinute,
+		MaxConcurrentRequests: user.MaxConcurrentRequests,
+		AllowedModels:         user.AllowedModels,
+		DeniedModels:          user.DeniedModels,
+	}
+	if len(user.Metadata) > 0 {
+		desired.Metadata = map[string]any(user.Metadata)
+	}
+	return desired
+}
+
+func toUpdateUserParams(userID string, user DesiredUser) accounts.UpdateUserParams {
+	return accounts.UpdateUserParams{
+		UserID:      userID,
+		Description: user.Description,
+		Metadata:    user.Metadata,
+		RateLimits:  toAccountUser(user).RateLimits,
+		ModelAccess: toAccountUser(user).ModelAccess,
+	}
+}
+
+func sameJSON(a, b any) bool {
+	left, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	right, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(left) == string(right)
+}
+
+func planFingerprint(changes []ApplyChange) (string, error) {
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,208 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+func setupApplyService(t *testing.T, dsn string, ruleSvc rules.Service) (Service, accounts.Service) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	accountSvc := accounts.NewService(db)
+	require.NoError(t, accountSvc.AutoMigrate(context.Background()))
+	return NewService(ruleSvc, accountSvc), accountSvc
+}
+
+func applyTestRule(id string, priority int) rules.Rule {
+	return rules.Rule{
+		ID:       id,
+		Priority: priority,
+		Enabled:  true,
+		Matcher:  rules.Matcher{PathPrefix: "/" + id},
+		Actions:  rules.Actions{SetTargetURL: "https://example.com"},
+	}
+}
+
+func TestService_Apply_PlansAndConverges(t *testing.T) {
+	ctx := context.Background()
+	ruleSvc := rules.NewService(rules.NewMemoryStore())
+	require.NoError(t, ruleSvc.UpsertRule(ctx, applyTestRule("keep", 1)))
+	require.NoError(t, ruleSvc.UpsertRule(ctx, applyTestRule("old", 1)))
+	svc, accountSvc := setupApplyService(t, "file:apply_converge?mode=memory&cache=shared", ruleSvc)
+
+	alice, err := accountSvc.CreateUser(ctx, accounts.CreateUserParams{Name: "alice"})
+	require.NoError(t, err)
+	key, _, err := accountSvc.CreateUserAPIKey(ctx, accounts.CreateAPIKeyParams{UserID: alice.ID})
+	require.NoError(t, err)
+	primary, err := accountSvc.CreateUpstreamCredential(ctx, accounts.CreateUpstreamCredentialParams{UserID: alice.ID, Provider: "openai", Plaintext: "sk-1"})
+	require.NoError(t, err)
+	secondary, err := accountSvc.CreateUpstreamCredential(ctx, accounts.CreateUpstreamCredentialParams{UserID: alice.ID, Provider: "openai", Plaintext: "sk-2"})
+	require.NoError(t, err)
+	_, err = accountSvc.BindAPIKey(ctx, accounts.BindAPIKeyParams{UserID: alice.ID, UserAPIKeyID: key.ID, UpstreamCredentialID: primary.ID})
+	require.NoError(t, err)
+
+	desired := DesiredState{
+		Rules: []rules.Rule{applyTestRule("new", 5), applyTestRule("keep", 9)},
+		Users: []DesiredUser{{Name: "alice", MaxRequestsPerMinute: 60}, {Name: "bob"}},
+		Bindings: []DesiredBinding{
+			{User: "alice", APIKeyID: key.ID, UpstreamID: secondary.ID},
+		},
+		Prune: true,
+	}
+	plan, err := svc.PlanApply(ctx, desired)
+	require.NoError(t, err)
+	type summary struct{ resource, action, id string }
+	var got []summary
+	for _, change := range plan.Changes {
+		got = append(got, summary{change.ResourceType, change.Action, change.ID})
+	}
+	require.Equal(t, []summary{
+		{"rule", ApplyActionUpdate, "keep"},
+		{"rule", ApplyActionCreate, "new"},
+		{"rule", ApplyActionDelete, "old"},
+		{"user", ApplyActionUpdate, "alice"},
+		{"user", ApplyActionCreate, "bob"},
+		{"binding", ApplyActionUpdate, key.ID + "/openai/0"},
+	}, got)
+	require.NotEmpty(t, plan.Fingerprint)
+	require.False(t, plan.Applied)
+
+	_, err = svc.Apply(ctx, desired, ApplyOptions{Fingerprint: "stale"})
+	require.ErrorIs(t, err, ErrPlanChanged)
+	_, err = ruleSvc.GetRule(ctx, "new")
+	require.ErrorIs(t, err, rules.ErrRuleNotFound)
+
+	applied, err := svc.Apply(ctx, desired, ApplyOptions{Actor: "ops", Fingerprint: plan.Fingerprint})
+	require.NoError(t, err)
+	require.True(t, applied.Applied)
+
+	created, err := ruleSvc.GetRule(ctx, "new")
+	require.NoError(t, err)
+	require.Equal(t, "ops", created.UpdatedBy)
+	_, err = ruleSvc.GetRule(ctx, "old")
+	require.ErrorIs(t, err, rules.ErrRuleNotFound)
+	reloaded, err := accountSvc.GetUser(ctx, alice.ID)
+	require.NoError(t, err)
+	require.Equal(t, 60, reloaded.MaxRequestsPerMinute)
+	binding, _, err := accountSvc.GetBindingByAPIKeyID(ctx, key.ID)
+	require.NoError(t, err)
+	require.Equal(t, secondary.ID, binding.UpstreamKeyID)
+
+	again, err := svc.PlanApply(ctx, desired)
+	require.NoError(t, err)
+	require.Empty(t, again.Changes)
+}
+
+func TestService_Apply_RejectsInvalidDocument(t *testing.T) {
+	ctx := context.Background()
+	svc, accountSvc := setupApplyService(t, "file:apply_invalid?mode=memory&cache=shared", rules.NewService(rules.NewMemoryStore()))
+	alice, err := accountSvc.CreateUser(ctx, accounts.CreateUserParams{Name: "alice"})
+	require.NoError(t, err)
+	other, err := accountSvc.CreateUser(ctx, accounts.CreateUserParams{Name: "carol"})
+	require.NoError(t, err)
+	foreignKey, _, err := accountSvc.CreateUserAPIKey(ctx, accounts.CreateAPIKeyParams{UserID: other.ID})
+	require.NoError(t, err)
+	upstream, err := accountSvc.CreateUpstreamCredential(ctx, accounts.CreateUpstreamCredentialParams{UserID: alice.ID, Provider: "openai", Plaintext: "sk"})
+	require.NoError(t, err)
+
+	cases := map[string]DesiredState{
+		"duplicate rule":     {Rules: []rules.Rule{applyTestRule("a", 1), applyTestRule("a", 2)}},
+		"invalid rule":       {Rules: []rules.Rule{{ID: "empty"}}},
+		"undeclared user":    {Bindings: []DesiredBinding{{User: "alice", APIKeyID: foreignKey.ID, UpstreamID: upstream.ID}}},
+		"foreign api key":    {Users: []DesiredUser{{Name: "alice"}}, Bindings: []DesiredBinding{{User: "alice", APIKeyID: foreignKey.ID, UpstreamID: upstream.ID}}},
+		"user not yet exist": {Users: []DesiredUser{{Name: "dave"}}, Bindings: []DesiredBinding{{User: "dave", APIKeyID: foreignKey.ID, UpstreamID: upstream.ID}}},
+	}
+	for name, desired := range cases {
+		_, err := svc.PlanApply(ctx, desired)
+		require.ErrorIs(t, err, ErrInvalidDesiredState, name)
+	}
+}
+
+// failingDeleteRules 在删除指定规则时返回错误，用于验证 apply 的回滚。
+type failingDeleteRules struct {
+	rules.Service
+	id string
+}
+
+func (f failingDeleteRules) DeleteRule(ctx context.Context, id string) error {
+	if id == f.id {
+		return errors.New("store unavailable")
+	}
+	return f.Service.DeleteRule(ctx, id)
+}
+
+func TestService_Apply_RollsBackOnFailure(t *testing.T) {
+	ctx := context.Background()
+	ruleSvc := rules.NewService(rules.NewMemoryStore())
+	require.NoError(t, ruleSvc.UpsertRule(ctx, applyTestRule("keep", 1)))
+	require.NoError(t, ruleSvc.UpsertRule(ctx, applyTestRule("stuck", 1)))
+	svc := NewService(failingDeleteRules{Service: ruleSvc, id: "stuck"}, nil)
+
+	_, err := svc.Apply(ctx, DesiredState{
+		Rules: []rules.Rule{applyTestRule("keep", 7), applyTestRule("added", 1)},
+		Prune: true,
+	}, ApplyOptions{})
+	require.ErrorContains(t, err, "store unavailable")
+
+	_, err = ruleSvc.GetRule(ctx, "added")
+	require.ErrorIs(t, err, rules.ErrRuleNotFound)
+	keep, err := ruleSvc.GetRule(ctx, "keep")
+	require.NoError(t, err)
+	require.Equal(t, 1, keep.Priority)
+	_, err = ruleSvc.GetRule(ctx, "stuck")
+	require.NoError(t, err)
+}
+
+// cancelingRules 在写入 id 时取消请求上下文，并像真实存储一样拒绝已取消上下文上的写入。
+type cancelingRules struct {
+	rules.Service
+	id     string
+	cancel context.CancelFunc
+}
+
+func (f cancelingRules) UpsertRule(ctx context.Context, rule rules.Rule) error {
+	if rule.ID == f.id {
+		f.cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.Service.UpsertRule(ctx, rule)
+}
+
+func (f cancelingRules) DeleteRule(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.Service.DeleteRule(ctx, id)
+}
+
+func TestService_Apply_RollsBackAfterContextCanceled(t *testing.T) {
+	ruleSvc := rules.NewService(rules.NewMemoryStore())
+	require.NoError(t, ruleSvc.UpsertRule(context.Background(), applyTestRule("b", 1)))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc := NewService(cancelingRules{Service: ruleSvc, id: "c", cancel: cancel}, nil)
+
+	// 规则按 ID 排序执行：a 创建、b 更新成功后客户端断开，c 写入失败，a 与 b 须在已取消的请求之外撤销。
+	_, err := svc.Apply(ctx, DesiredState{
+		Rules: []rules.Rule{applyTestRule("a", 1), applyTestRule("b", 5), applyTestRule("c", 1)},
+	}, ApplyOptions{})
+	require.ErrorIs(t, err, context.Canceled)
+	require.NotContains(t, err.Error(), "rollback")
+
+	_, err = ruleSvc.GetRule(context.Background(), "a")
+	require.ErrorIs(t, err, rules.ErrRuleNotFound)
+	b, err := ruleSvc.GetRule(context.Background(), "b")
+	require.NoError(t, err)
+	require.Equal(t, 1, b.Priority)
+}
@@ -1,15 +1,26 @@
 package admin
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/subtle"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/prehisle/yapi/internal/adminusers"
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/servicetokens"
+	"github.com/prehisle/yapi/pkg/metrics"
 )
 
 var (
@@ -17,24 +28,173 @@ var (
 	ErrInvalidCredential = errors.New("invalid credential")
 	// ErrTokenNotConfigured 当未配置签发密钥时返回。
 	ErrTokenNotConfigured = errors.New("token signing secret not configured")
+	// ErrTokenRevoked 当令牌已通过登出或刷新被吊销时返回。
+	ErrTokenRevoked = errors.New("token revoked")
+	// ErrExternalSessionExpired 当外部登录会话超过绝对有效期时返回，需重新经身份提供方登录。
+	ErrExternalSessionExpired = errors.New("external login session expired")
+	// ErrUserStoreUnavailable 当账号库查询失败时返回，与凭据错误区分以便返回 503。
+	ErrUserStoreUnavailable = errors.New("admin user store unavailable")
+)
+
+// 令牌类型，写入 typ 声明以区分访问令牌与刷新令牌。
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+	// tokenTypeLoginState 保存在外部登录的 state Cookie 中，其 jti 同时作为 OIDC nonce。
+	tokenTypeLoginState = "login_state"
 )
 
+// loginStateTTL 是外部登录从跳转到回调允许的最长时间。
+const loginStateTTL = 10 * time.Minute
+
+// defaultRefreshTTL 是刷新令牌的默认有效期。
+const defaultRefreshTTL = 7 * 24 * time.Hour
+
+// defaultExternalSessionTTL 是外部登录会话自登录起的默认绝对有效期。
+const defaultExternalSessionTTL = 12 * time.Hour
+
+// tokenClaims 在标准声明之外记录令牌类型与权限范围；Permissions 为空表示沿用账号角色的全部权限。
+// Role 仅在外部身份提供方登录的令牌中出现，此类账号不在本地账号库，角色随令牌携带。
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Type        string          `json:"typ,omitempty"`
+	Permissions []Permission    `json:"perms,omitempty"`
+	Role        adminusers.Role `json:"role,omitempty"`
+	// Session 是 Cookie 会话 ID，用于派生 CSRF 令牌，刷新时沿用。
+	Session string `json:"sid,omitempty"`
+	// AuthTime 是外部登录的时间，刷新时沿用，外部会话据此计算绝对有效期。
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	// State 与 Verifier 仅出现在外部登录的 state Cookie 中，分别是跳转参数 state 与 PKCE 的 code_verifier。
+	State    string `json:"state,omitempty"`
+	Verifier string `json:"cv,omitempty"`
+}
+
+// TokenPair 是登录或刷新后签发的一组令牌。
+type TokenPair struct {
+	AccessToken      string
+	AccessExpiresIn  time.Duration
+	RefreshToken     string
+	RefreshExpiresIn time.Duration
+	// Permissions 是令牌签发时的有效权限。
+	Permissions []Permission
+	// CSRFToken 仅 Cookie 会话令牌携带，写请求需通过 X-CSRF-Token 回传。
+	CSRFToken string
+}
+
 // Authenticator 负责管理员认证与令牌签发。
 type Authenticator struct {
-	username string
-	password string
-	secret   []byte
-	ttl      time.Duration
+	username   string
+	password   string
+	secret     []byte
+	ttl        time.Duration
+	refreshTTL time.Duration
+	revoked    RevocationStore
+	users      adminusers.Service
+	tokens     servicetokens.Service
+	guard      *LoginGuard
+	external   bool
+	// forwardedIP 为 true 时来源 IP 采信可信代理转发的 X-Forwarded-For，见 WithForwardedClientIP。
+	forwardedIP bool
+	// externalTTL 是外部登录会话的绝对有效期，刷新不会延长。
+	externalTTL time.Duration
+}
+
+// Identity 是通过认证的管理员、角色及当前请求的有效权限。
+type Identity struct {
+	Username    string
+	Role        adminusers.Role
+	Permissions []Permission
+	// External 表示身份来自外部身份提供方（如 OIDC），角色写入令牌而非每次从账号库读取。
+	External bool
+	// Session 非空表示令牌属于 Cookie 会话。
+	Session string
+	// AuthTime 是外部身份在身份提供方处登录的时间，本地账号为零值。
+	AuthTime time.Time
+}
+
+func newIdentity(username string, role adminusers.Role) Identity {
+	return Identity{Username: username, Role: role, Permissions: PermissionsForRole(role)}
+}
+
+// AuthOption 配置 Authenticator。
+type AuthOption func(*Authenticator)
+
+// WithRefreshTTL 设置刷新令牌有效期。
+func WithRefreshTTL(ttl time.Duration) AuthOption {
+	return func(a *Authenticator) {
+		if ttl > 0 {
+			a.refreshTTL = ttl
+		}
+	}
+}
+
+// WithRevocationStore 设置令牌吊销列表，默认使用进程内存储。
+func WithRevocationStore(store RevocationStore) AuthOption {
+	return func(a *Authenticator) {
+		if store != nil {
+			a.revoked = store
+		}
+	}
+}
+
+// WithLoginGuard 设置登录失败锁定策略，默认使用进程内存储与 DefaultLoginPolicy；传入 nil 关闭锁定。
+func WithLoginGuard(guard *LoginGuard) AuthOption {
+	return func(a *Authenticator) {
+		a.guard = guard
+	}
+}
+
+// WithUserStore 启用数据库中的管理员账号；环境变量配置的账号仍作为 owner 保留，用于初始化与应急。
+func WithUserStore(users adminusers.Service) AuthOption {
+	return func(a *Authenticator) {
+		a.users = users
+	}
+}
+
+// WithServiceTokenStore 启用服务令牌认证：以 yst_ 开头的 Bearer 令牌按服务令牌校验，权限为令牌创建时指定的范围。
+func WithServiceTokenStore(tokens servicetokens.Service) AuthOption {
+	return func(a *Authenticator) {
+		a.tokens = tokens
+	}
+}
+
+// WithExternalLogin 声明已启用外部身份提供方登录；此时即使未配置本地账号也要求认证。
+// sessionTTL 为外部登录会话自登录起的绝对有效期，非正数时使用默认的 12 小时；
+// 外部身份的角色随令牌携带，超过该期限后刷新被拒绝，须重新登录以从身份提供方取得最新的角色。
+func WithExternalLogin(sessionTTL time.Duration) AuthOption {
+	return func(a *Authenticator) {
+		a.external = true
+		if sessionTTL > 0 {
+			a.externalTTL = sessionTTL
+		}
+	}
+}
+
+// WithForwardedClientIP 让登录锁定与登录审计按 gin 的 ClientIP 识别来源 IP，即采信引擎可信代理转发的
+// X-Forwarded-For；仅应在引擎配置了 SetTrustedProxies 时使用。默认使用 TCP 对端地址，
+// 客户端无法通过伪造请求头轮换来源 IP 绕过按 IP 的失败锁定。
+func WithForwardedClientIP() AuthOption {
+	return func(a *Authenticator) {
+		a.forwardedIP = true
+	}
 }
 
 // NewAuthenticator 创建认证器。
-func NewAuthenticator(username, password, tokenSecret string, ttl time.Duration) *Authenticator {
-	return &Authenticator{
-		username: strings.TrimSpace(username),
-		password: password,
-		secret:   []byte(tokenSecret),
-		ttl:      ttl,
+func NewAuthenticator(username, password, tokenSecret string, ttl time.Duration, opts ...AuthOption) *Authenticator {
+	a := &Authenticator{
+		username:    strings.TrimSpace(username),
+		password:    password,
+		secret:      []byte(tokenSecret),
+		ttl:         ttl,
+		refreshTTL:  defaultRefreshTTL,
+		revoked:     NewMemoryRevocationStore(),
+		guard:       NewLoginGuard(nil, DefaultLoginPolicy()),
+		externalTTL: defaultExternalSessionTTL,
+	}
+	for _, opt := range opts {
+		opt(a)
 	}
+	return a
 }
 
 // CredentialsConfigured 判断是否配置了用户名密码。
@@ -42,78 +202,554 @@ func (a *Authenticator) CredentialsConfigured() bool {
 	return a != nil && a.username != "" && a.password != ""
 }
 
+// LoginEnabled 判断是否存在可登录的账号来源（环境变量凭据、账号库或外部身份提供方）。
+func (a *Authenticator) LoginEnabled() bool {
+	return a != nil && (a.CredentialsConfigured() || a.users != nil || a.external)
+}
+
 // TokenEnabled 判断是否可签发 JWT。
 func (a *Authenticator) TokenEnabled() bool {
 	return a != nil && len(a.secret) > 0
 }
 
 // IssueToken 验证凭证并签发短期访问令牌。
-func (a *Authenticator) IssueToken(username, password string) (string, error) {
-	if !a.CredentialsConfigured() {
-		return "", ErrInvalidCredential
+func (a *Authenticator) IssueToken(ctx context.Context, username, password string) (string, error) {
+	pair, err := a.IssueTokenPair(ctx, username, password)
+	return pair.AccessToken, err
+}
+
+// IssueTokenPair 验证凭证并签发访问令牌与刷新令牌。scope 非空时令牌仅携带这些权限，
+// 且必须是账号角色权限的子集，否则返回 ErrInvalidPermissions。
+func (a *Authenticator) IssueTokenPair(ctx context.Context, username, password string, scope ...Permission) (TokenPair, error) {
+	return a.issueTokenPair(ctx, username, password, "", scope)
+}
+
+// IssueSessionTokenPair 与 IssueTokenPair 相同，但令牌绑定新的 Cookie 会话并附带 CSRF 令牌。
+func (a *Authenticator) IssueSessionTokenPair(ctx context.Context, username, password string, scope ...Permission) (TokenPair, error) {
+	return a.issueTokenPair(ctx, username, password, uuid.NewString(), scope)
+}
+
+func (a *Authenticator) issueTokenPair(ctx context.Context, username, password, session string, scope []Permission) (TokenPair, error) {
+	if !a.LoginEnabled() {
+		return TokenPair{}, ErrInvalidCredential
+	}
+	identity, err := a.authenticate(ctx, username, password, true)
+	if err != nil {
+		return TokenPair{}, err
 	}
-	if !a.matchCredential(username, password) {
-		return "", ErrInvalidCredential
+	for _, perm := range scope {
+		if !slices.Contains(identity.Permissions, perm) {
+			return TokenPair{}, fmt.Errorf("%w: role %s does not grant %s", ErrInvalidPermissions, identity.Role, perm)
+		}
+	}
+	if !a.TokenEnabled() {
+		return TokenPair{}, ErrTokenNotConfigured
+	}
+	identity.Session = session
+	return a.issuePair(identity, scope)
+}
+
+// RefreshToken 校验刷新令牌并签发新的令牌对；旧刷新令牌随即吊销，重复使用会被拒绝。
+// 新令牌沿用原令牌的权限范围；账号已删除或停用时拒绝刷新。
+func (a *Authenticator) RefreshToken(ctx context.Context, refreshToken string) (TokenPair, error) {
+	claims, err := a.parseToken(ctx, refreshToken, tokenTypeRefresh)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return a.refresh(ctx, claims)
+}
+
+// RefreshSessionToken 刷新 Cookie 会话，要求请求携带该会话的 CSRF 令牌。
+func (a *Authenticator) RefreshSessionToken(ctx context.Context, refreshToken, csrf string) (TokenPair, error) {
+	claims, err := a.parseToken(ctx, refreshToken, tokenTypeRefresh)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !a.verifyCSRF(claims.Session, csrf) {
+		return TokenPair{}, ErrInvalidCSRF
+	}
+	return a.refresh(ctx, claims)
+}
+
+func (a *Authenticator) refresh(ctx context.Context, claims tokenClaims) (TokenPair, error) {
+	identity, err := a.resolveClaims(ctx, claims)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	// 吊销与检查原子完成：并发使用同一刷新令牌时只有一个请求换得新令牌，其余视为重放。
+	revoked, err := a.revoked.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !revoked {
+		return TokenPair{}, ErrTokenRevoked
+	}
+	return a.issuePair(identity, claims.Permissions)
+}
+
+// Revoke 吊销一个仍有效的访问令牌或刷新令牌，已过期或无效的令牌直接忽略。
+func (a *Authenticator) Revoke(ctx context.Context, token string) error {
+	claims, err := a.parseToken(ctx, token, "")
+	if err != nil || claims.ID == "" {
+		return nil
+	}
+	_, err = a.revoked.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+	return err
+}
+
+// ValidateToken 校验访问令牌的签名、有效期与吊销状态。
+func (a *Authenticator) ValidateToken(ctx context.Context, token string) error {
+	_, err := a.parseToken(ctx, token, tokenTypeAccess)
+	return err
+}
+
+// Identify 校验访问令牌并返回其所属管理员的当前角色；角色每次从账号库读取，变更即时生效。
+// 有效权限为角色权限与令牌权限范围的交集。
+func (a *Authenticator) Identify(ctx context.Context, token string) (Identity, error) {
+	claims, err := a.parseToken(ctx, token, tokenTypeAccess)
+	if err != nil {
+		return Identity{}, err
 	}
+	identity, err := a.resolveClaims(ctx, claims)
+	if err != nil {
+		return Identity{}, err
+	}
+	identity.Permissions = narrowPermissions(identity.Permissions, claims.Permissions)
+	return identity, nil
+}
+
+// IssueExternalTokenPair 为已由外部身份提供方认证的用户签发令牌，角色与登录时间写入令牌；
+// 令牌及其刷新得到的令牌都不会超过 WithExternalLogin 设置的会话有效期。
+func (a *Authenticator) IssueExternalTokenPair(username string, role adminusers.Role) (TokenPair, error) {
 	if !a.TokenEnabled() {
-		return "", ErrTokenNotConfigured
+		return TokenPair{}, ErrTokenNotConfigured
+	}
+	if !role.Valid() {
+		return TokenPair{}, fmt.Errorf("%w: unknown role %q", ErrInvalidCredential, role)
 	}
+	identity := newIdentity(username, role)
+	identity.External = true
+	identity.AuthTime = time.Now()
+	return a.issuePair(identity, nil)
+}
+
+// LoginState 是一次外部登录的状态。State 随跳转发给身份提供方，Cookie 写入浏览器的 HttpOnly Cookie，
+// 回调时二者必须匹配，防止攻击者把自己的授权码交给受害者的浏览器完成登录（登录 CSRF）；
+// Verifier 是 PKCE 的 code_verifier，只保存在 Cookie 中，截获授权码的一方无法用它换取令牌。
+type LoginState struct {
+	State    string
+	Nonce    string
+	Verifier string
+	Cookie   string
+}
+
+// IssueLoginState 签发外部登录的 state、nonce 与 PKCE verifier，回调时由 ConsumeLoginState 校验。
+func (a *Authenticator) IssueLoginState() (LoginState, error) {
+	if !a.TokenEnabled() {
+		return LoginState{}, ErrTokenNotConfigured
+	}
+	state, err := randomToken()
+	if err != nil {
+		return LoginState{}, err
+	}
+	verifier, err := randomToken()
+	if err != nil {
+		return LoginState{}, err
+	}
+	login := LoginState{State: state, Nonce: uuid.NewString(), Verifier: verifier}
+	login.Cookie, err = a.signClaims(tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        login.Nonce,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(loginStateTTL)),
+		},
+		Type:     tokenTypeLoginState,
+		State:    login.State,
+		Verifier: login.Verifier,
+	})
+	return login, err
+}
+
+// ConsumeLoginState 校验 state Cookie 并要求其与回调携带的 state 一致，返回对应的登录状态；
+// 每个 Cookie 只能使用一次。
+func (a *Authenticator) ConsumeLoginState(ctx context.Context, state, cookie string) (LoginState, error) {
+	claims, err := a.parseToken(ctx, cookie, tokenTypeLoginState)
+	if err != nil {
+		return LoginState{}, err
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(claims.State), []byte(state)) != 1 {
+		return LoginState{}, fmt.Errorf("%w: state mismatch", ErrInvalidCredential)
+	}
+	consumed, err := a.revoked.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return LoginState{}, err
+	}
+	if !consumed {
+		return LoginState{}, ErrTokenRevoked
+	}
+	return LoginState{State: claims.State, Nonce: claims.ID, Verifier: claims.Verifier, Cookie: cookie}, nil
+}
+
+// randomToken 返回 32 字节随机数的 base64url 编码，长度满足 PKCE code_verifier 的 43 个字符下限。
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// resolveClaims 根据令牌声明确定身份：外部身份使用令牌中的角色，但自登录起超过会话有效期即失效；
+// 本地账号重新查询。
+func (a *Authenticator) resolveClaims(ctx context.Context, claims tokenClaims) (Identity, error) {
+	var identity Identity
+	if claims.Role != "" {
+		if !claims.Role.Valid() {
+			return Identity{}, ErrInvalidCredential
+		}
+		if claims.AuthTime == nil || time.Since(claims.AuthTime.Time) >= a.externalTTL {
+			return Identity{}, ErrExternalSessionExpired
+		}
+		identity = newIdentity(claims.Subject, claims.Role)
+		identity.External = true
+		identity.AuthTime = claims.AuthTime.Time
+	} else {
+		var err error
+		if identity, err = a.resolve(ctx, claims.Subject); err != nil {
+			return Identity{}, err
+		}
+	}
+	identity.Session = claims.Session
+	return identity, nil
+}
+
+// authenticate 校验用户名密码，并按来源 IP 与用户名实施失败锁定。login 表示来自显式的 /login 请求：
+// 成功后总是清除用户名的失败计数；每个请求都携带的 Basic 认证只在存在失败记录时才清除，避免每个请求都写一次锁定存储。
+// 锁定存储不可用时放行，避免 Redis 故障导致所有管理员无法登录。
+func (a *Authenticator) authenticate(ctx context.Context, username, password string, login bool) (Identity, error) {
+	username = strings.TrimSpace(username)
+	ip := clientIPFromContext(ctx)
+	if a.guard != nil {
+		if locked, err := a.guard.Check(ctx, ip, username); err == nil && locked > 0 {
+			metrics.ObserveAdminLoginFailure("locked")
+			return Identity{}, &LoginLockedError{RetryAfter: locked}
+		}
+	}
+	identity, err := a.verifyCredential(ctx, username, password)
+	switch {
+	case errors.Is(err, ErrInvalidCredential):
+		metrics.ObserveAdminLoginFailure("invalid_credential")
+		if a.guard != nil {
+			_, _ = a.guard.Fail(ctx, ip, username)
+		}
+	case err == nil && a.guard != nil && (login || a.guard.HasFailures(ctx, username)):
+		_ = a.guard.Succeed(ctx, username)
+	}
+	return identity, err
+}
+
+// verifyCredential 校验用户名密码：环境变量账号优先，其次查询账号库。
+func (a *Authenticator) verifyCredential(ctx context.Context, username, password string) (Identity, error) {
+	if a.CredentialsConfigured() && username == a.username {
+		if !a.matchCredential(username, password) {
+			return Identity{}, ErrInvalidCredential
+		}
+		return newIdentity(a.username, adminusers.RoleOwner), nil
+	}
+	if a.users == nil {
+		return Identity{}, ErrInvalidCredential
+	}
+	user, err := a.users.Authenticate(ctx, username, password)
+	if err != nil {
+		if errors.Is(err, adminusers.ErrInvalidCredential) {
+			return Identity{}, ErrInvalidCredential
+		}
+		return Identity{}, fmt.Errorf("%w: %v", ErrUserStoreUnavailable, err)
+	}
+	return newIdentity(user.Username, user.Role), nil
+}
+
+// resolve 根据令牌主体查出管理员的当前角色，账号不存在或已停用时返回 ErrInvalidCredential。
+func (a *Authenticator) resolve(ctx context.Context, username string) (Identity, error) {
+	if a.CredentialsConfigured() && username == a.username {
+		return newIdentity(a.username, adminusers.RoleOwner), nil
+	}
+	if a.users == nil {
+		return Identity{}, ErrInvalidCredential
+	}
+	user, err := a.users.GetByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, adminusers.ErrNotFound) {
+			return Identity{}, ErrInvalidCredential
+		}
+		return Identity{}, fmt.Errorf("%w: %v", ErrUserStoreUnavailable, err)
+	}
+	if user.Disabled {
+		return Identity{}, ErrInvalidCredential
+	}
+	return newIdentity(user.Username, user.Role), nil
+}
+
+// anonymousAllowed 判断是否无需认证：未配置环境变量凭据与外部登录，账号库也为空（或未启用）。
+func (a *Authenticator) anonymousAllowed(ctx context.Context) (bool, error) {
+	if a.CredentialsConfigured() || a.external {
+		return false, nil
+	}
+	if a.users == nil {
+		return true, nil
+	}
+	count, err := a.users.Count(ctx)
+	if err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
+
+func (a *Authenticator) issuePair(identity Identity, scope []Permission) (TokenPair, error) {
+	accessTTL, refreshTTL := a.ttl, a.refreshTTL
+	if identity.External {
+		remaining := time.Until(identity.AuthTime.Add(a.externalTTL))
+		accessTTL, refreshTTL = min(accessTTL, remaining), min(refreshTTL, remaining)
+	}
+	access, err := a.sign(identity, tokenTypeAccess, scope, accessTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refresh, err := a.sign(identity, tokenTypeRefresh, scope, refreshTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	pair := TokenPair{
+		AccessToken:      access,
+		AccessExpiresIn:  accessTTL,
+		RefreshToken:     refresh,
+		RefreshExpiresIn: refreshTTL,
+		Permissions:      narrowPermissions(identity.Permissions, scope),
+	}
+	if identity.Session != "" {
+		pair.CSRFToken = a.csrfToken(identity.Session)
+	}
+	return pair, nil
+}
+
+func (a *Authenticator) sign(identity Identity, tokenType string, scope []Permission, ttl time.Duration) (string, error) {
 	now := time.Now()
-	claims := jwt.RegisteredClaims{
-		Subject:   a.username,
-		IssuedAt:  jwt.NewNumericDate(now),
-		ExpiresAt: jwt.NewNumericDate(now.Add(a.ttl)),
+	claims := tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   identity.Username,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Type:        tokenType,
+		Permissions: scope,
+		Session:     identity.Session,
+	}
+	if identity.External {
+		claims.Role = identity.Role
+		claims.AuthTime = jwt.NewNumericDate(identity.AuthTime)
 	}
+	return a.signClaims(claims)
+}
+
+func (a *Authenticator) signClaims(claims tokenClaims) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(a.secret)
 }
 
-// ValidateToken 校验 JWT 且确认未过期。
-func (a *Authenticator) ValidateToken(token string) error {
+// parseToken 校验令牌并返回声明；expectedType 为空时接受任意类型。缺少 typ 的旧令牌视为访问令牌。
+func (a *Authenticator) parseToken(ctx context.Context, token, expectedType string) (tokenClaims, error) {
 	if !a.TokenEnabled() {
-		return ErrTokenNotConfigured
+		return tokenClaims{}, ErrTokenNotConfigured
 	}
 	if strings.TrimSpace(token) == "" {
-		return ErrInvalidCredential
+		return tokenClaims{}, ErrInvalidCredential
 	}
-	_, err := jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, func(t *jwt.Token) (any, error) {
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
 		}
 		return a.secret, nil
-	})
-	return err
+	}, jwt.WithExpirationRequired())
+	if err != nil {
+		return tokenClaims{}, err
+	}
+	if claims.Type == "" {
+		claims.Type = tokenTypeAccess
+	}
+	if expectedType != "" && claims.Type != expectedType {
+		return tokenClaims{}, ErrInvalidCredential
+	}
+	if claims.ID != "" {
+		revoked, err := a.revoked.IsRevoked(ctx, claims.ID)
+		if err != nil {
+			return tokenClaims{}, err
+		}
+		if revoked {
+			return tokenClaims{}, ErrTokenRevoked
+		}
+	}
+	return claims, nil
 }
 
-// Middleware 返回 Gin 中间件验证 Bearer / Basic。
+// Middleware 返回 Gin 中间件验证 Bearer / Basic / 会话 Cookie，并将有效权限写入上下文供 RequirePermission 检查。
 func (a *Authenticator) Middleware() gin.HandlerFunc {
-	if a == nil || !a.CredentialsConfigured() {
+	if a == nil || !a.LoginEnabled() {
 		// 未配置凭据则跳过认证。
 		return func(c *gin.Context) {
 			c.Next()
 		}
 	}
-	expectedBasic := "Basic " + basicHeader(a.username, a.password)
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if strings.HasPrefix(authHeader, "Bearer ") && a.TokenEnabled() {
-			token := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
-			if err := a.ValidateToken(token); err == nil {
-				c.Set("admin_user", a.username)
-				c.Next()
-				return
-			}
+		c.Request = c.Request.WithContext(withClientIP(c.Request.Context(), a.clientIP(c)))
+		ctx := c.Request.Context()
+		anonymous, err := a.anonymousAllowed(ctx)
+		if err != nil {
+			errcode.Abort(c, http.StatusServiceUnavailable, errcode.ServiceUnavailable, "admin user store unavailable")
+			return
 		}
-		if subtle.ConstantTimeCompare([]byte(authHeader), []byte(expectedBasic)) == 1 {
-			c.Set("admin_user", a.username)
+		if anonymous {
+			c.Set(adminPermissionsKey, PermissionsForRole(adminusers.RoleOwner))
 			c.Next()
 			return
 		}
-		c.Header("WWW-Authenticate", `Basic realm="admin", Bearer realm="admin"`)
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		identity, err := a.identifyRequest(c)
+		if err != nil {
+			var locked *LoginLockedError
+			if errors.As(err, &locked) {
+				c.Header("Retry-After", strconv.Itoa(int(locked.RetryAfter.Round(time.Second).Seconds())))
+				errcode.Abort(c, http.StatusTooManyRequests, errcode.LoginLocked, err.Error())
+				return
+			}
+			if isStoreError(err) {
+				errcode.Abort(c, http.StatusServiceUnavailable, errcode.ServiceUnavailable, "admin user store unavailable")
+				return
+			}
+			if errors.Is(err, ErrInvalidCSRF) {
+				errcode.Abort(c, http.StatusForbidden, errcode.Forbidden, err.Error())
+				return
+			}
+			c.Header("WWW-Authenticate", `Basic realm="admin", Bearer realm="admin"`)
+			errcode.Abort(c, http.StatusUnauthorized, errcode.Unauthorized, "unauthorized")
+			return
+		}
+		c.Set("admin_user", identity.Username)
+		c.Set(adminPermissionsKey, identity.Permissions)
+		c.Next()
 	}
 }
 
+// basicAuthFailureKey 保存认证中间件中 Basic 认证失败的用户名与原因，由 Handler.auditBasicAuthFailure 记入审计日志。
+const basicAuthFailureKey = "yapi_admin_basic_auth_failure"
+
+type basicAuthFailure struct {
+	username string
+	reason   string
+}
+
+// identifyRequest 从 Authorization 头识别管理员，凭据缺失或无效时返回 ErrInvalidCredential，
+// Basic 凭据因多次失败被锁定时返回 *LoginLockedError，Cookie 会话的写请求 CSRF 校验失败时返回 ErrInvalidCSRF。
+func (a *Authenticator) identifyRequest(c *gin.Context) (Identity, error) {
+	authHeader := c.GetHeader("Authorization")
+	ctx := c.Request.Context()
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		token := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+		if servicetokens.IsServiceToken(token) && a.tokens != nil {
+			return a.identifyServiceToken(ctx, token)
+		}
+	}
+	if strings.HasPrefix(authHeader, "Bearer ") && a.TokenEnabled() {
+		token := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+		identity, err := a.Identify(ctx, token)
+		if err == nil || isStoreError(err) {
+			return identity, err
+		}
+	}
+	if username, password, ok := c.Request.BasicAuth(); ok {
+		identity, err := a.authenticate(ctx, username, password, false)
+		var locked *LoginLockedError
+		switch {
+		case errors.As(err, &locked):
+			c.Set(basicAuthFailureKey, basicAuthFailure{username: username, reason: "locked"})
+		case errors.Is(err, ErrInvalidCredential):
+			c.Set(basicAuthFailureKey, basicAuthFailure{username: username, reason: "invalid_credential"})
+		}
+		if !errors.Is(err, ErrInvalidCredential) {
+			return identity, err
+		}
+	}
+	if token, err := c.Cookie(SessionCookie); err == nil && authHeader == "" && a.TokenEnabled() {
+		return a.identifySession(c, token)
+	}
+	return Identity{}, ErrInvalidCredential
+}
+
+// identifySession 校验会话 Cookie 中的访问令牌；写请求还需在 X-CSRF-Token 头中携带该会话的 CSRF 令牌。
+func (a *Authenticator) identifySession(c *gin.Context, token string) (Identity, error) {
+	identity, err := a.Identify(c.Request.Context(), token)
+	if err != nil {
+		if isStoreError(err) {
+			return Identity{}, err
+		}
+		return Identity{}, ErrInvalidCredential
+	}
+	if identity.Session == "" {
+		return Identity{}, ErrInvalidCredential
+	}
+	if !safeMethod(c.Request.Method) && !a.verifyCSRF(identity.Session, c.GetHeader(CSRFHeader)) {
+		return Identity{}, ErrInvalidCSRF
+	}
+	return identity, nil
+}
+
+// identifyServiceToken 校验服务令牌，身份名为 service-token:<名称>，便于在审计日志中与管理员区分。
+// 令牌中已不再存在的权限名称被忽略。
+func (a *Authenticator) identifyServiceToken(ctx context.Context, raw string) (Identity, error) {
+	token, err := a.tokens.Authenticate(ctx, raw)
+	if err != nil {
+		if errors.Is(err, servicetokens.ErrInvalidToken) {
+			return Identity{}, ErrInvalidCredential
+		}
+		return Identity{}, fmt.Errorf("%w: %v", ErrUserStoreUnavailable, err)
+	}
+	perms := make([]Permission, 0, len(token.Permissions))
+	for _, value := range token.Permissions {
+		if perm := Permission(value); slices.Contains(ownerPermissions, perm) {
+			perms = append(perms, perm)
+		}
+	}
+	return Identity{Username: serviceTokenIdentity(token.Name), Permissions: perms}, nil
+}
+
+// serviceTokenIdentity 返回服务令牌在审计日志与 admin_user 上下文中的身份名。
+func serviceTokenIdentity(name string) string {
+	return "service-token:" + name
+}
+
+type clientIPKey struct{}
+
+// clientIP 返回登录锁定与审计使用的来源 IP：默认为 TCP 对端地址，启用 WithForwardedClientIP 信任代理时取 gin 的 ClientIP。
+func (a *Authenticator) clientIP(c *gin.Context) string {
+	if a != nil && a.forwardedIP {
+		return c.ClientIP()
+	}
+	return c.RemoteIP()
+}
+
+// withClientIP 将请求来源 IP 写入上下文，供登录失败锁定按 IP 计数。
+func withClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func clientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+func isStoreError(err error) bool {
+	return errors.Is(err, ErrUserStoreUnavailable)
+}
+
 // matchCredential 验证用户名密码是否匹配。
 func (a *Authenticator) matchCredential(username, password string) bool {
 	if a.username == "" && a.password == "" {
@@ -127,8 +763,3 @@ func (a *Authenticator) matchCredential(username, password string) bool {
 	}
 	return true
 }
-
-func basicHeader(username, password string) string {
-	raw := username + ":" + password
-	return base64.StdEncoding.EncodeToString([]byte(raw))
-}
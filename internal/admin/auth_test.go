@@ -1,28 +1,173 @@
 package admin
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/adminusers"
 )
 
 func TestAuthenticator_IssueAndValidate(t *testing.T) {
 	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
-	token, err := auth.IssueToken("admin", "secret")
+	token, err := auth.IssueToken(context.Background(), "admin", "secret")
 	require.NoError(t, err)
-	require.NoError(t, auth.ValidateToken(token))
+	require.NoError(t, auth.ValidateToken(context.Background(), token))
 }
 
 func TestAuthenticator_IssueToken_Invalid(t *testing.T) {
 	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
-	_, err := auth.IssueToken("admin", "wrong")
+	_, err := auth.IssueToken(context.Background(), "admin", "wrong")
 	require.ErrorIs(t, err, ErrInvalidCredential)
 }
 
 func TestAuthenticator_TokenDisabled(t *testing.T) {
 	auth := NewAuthenticator("admin", "secret", "", time.Minute)
-	_, err := auth.IssueToken("admin", "secret")
+	_, err := auth.IssueToken(context.Background(), "admin", "secret")
 	require.ErrorIs(t, err, ErrTokenNotConfigured)
-	require.Error(t, auth.ValidateToken(""))
+	require.Error(t, auth.ValidateToken(context.Background(), ""))
+}
+
+func TestAuthenticator_RefreshRotatesAndRevokes(t *testing.T) {
+	ctx := context.Background()
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute, WithRefreshTTL(time.Hour))
+	pair, err := auth.IssueTokenPair(ctx, "admin", "secret")
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, pair.RefreshExpiresIn)
+
+	require.ErrorIs(t, auth.ValidateToken(ctx, pair.RefreshToken), ErrInvalidCredential)
+	_, err = auth.RefreshToken(ctx, pair.AccessToken)
+	require.ErrorIs(t, err, ErrInvalidCredential)
+
+	next, err := auth.RefreshToken(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+	require.NoError(t, auth.ValidateToken(ctx, next.AccessToken))
+	_, err = auth.RefreshToken(ctx, pair.RefreshToken)
+	require.ErrorIs(t, err, ErrTokenRevoked)
+
+	require.NoError(t, auth.Revoke(ctx, next.AccessToken))
+	require.ErrorIs(t, auth.ValidateToken(ctx, next.AccessToken), ErrTokenRevoked)
+	require.NoError(t, auth.Revoke(ctx, "not-a-token"))
+}
+
+func TestAuthenticator_ConcurrentRefreshOnlyOneSucceeds(t *testing.T) {
+	ctx := context.Background()
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute, WithRefreshTTL(time.Hour))
+	pair, err := auth.IssueTokenPair(ctx, "admin", "secret")
+	require.NoError(t, err)
+
+	const attempts = 16
+	var wins, replays atomic.Int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := auth.RefreshToken(ctx, pair.RefreshToken)
+			switch {
+			case err == nil:
+				wins.Add(1)
+			case errors.Is(err, ErrTokenRevoked):
+				replays.Add(1)
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	require.EqualValues(t, 1, wins.Load())
+	require.EqualValues(t, attempts-1, replays.Load())
+}
+
+func TestMemoryRevocationStore_ExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRevocationStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	newly, err := store.Revoke(ctx, "a", now.Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, newly)
+	newly, err = store.Revoke(ctx, "a", now.Add(time.Minute))
+	require.NoError(t, err)
+	require.False(t, newly, "revoking twice reports the token as already revoked")
+	newly, err = store.Revoke(ctx, "expired", now.Add(-time.Second))
+	require.NoError(t, err)
+	require.False(t, newly)
+	revoked, err := store.IsRevoked(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, revoked)
+	revoked, err = store.IsRevoked(ctx, "expired")
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	now = now.Add(2 * time.Minute)
+	revoked, err = store.IsRevoked(ctx, "a")
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
+
+func TestAuthenticator_ExternalSessionHasAbsoluteLifetime(t *testing.T) {
+	ctx := context.Background()
+	auth := NewAuthenticator("", "", "sign-key", time.Minute, WithExternalLogin(time.Hour), WithRefreshTTL(24*time.Hour))
+	pair, err := auth.IssueExternalTokenPair("alice", adminusers.RoleEditor)
+	require.NoError(t, err)
+	require.LessOrEqual(t, pair.RefreshExpiresIn, time.Hour)
+	next, err := auth.RefreshToken(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+	identity, err := auth.Identify(ctx, next.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, adminusers.RoleEditor, identity.Role)
+
+	// 刷新令牌本身仍未过期，但距 OIDC 登录已超过会话有效期，角色不能再沿用。
+	refreshAt := func(authTime *jwt.NumericDate) string {
+		token, err := auth.signClaims(tokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        uuid.NewString(),
+				Subject:   "alice",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+			Type:     tokenTypeRefresh,
+			Role:     adminusers.RoleOwner,
+			AuthTime: authTime,
+		})
+		require.NoError(t, err)
+		return token
+	}
+	_, err = auth.RefreshToken(ctx, refreshAt(jwt.NewNumericDate(time.Now().Add(-2*time.Hour))))
+	require.ErrorIs(t, err, ErrExternalSessionExpired)
+	_, err = auth.RefreshToken(ctx, refreshAt(nil))
+	require.ErrorIs(t, err, ErrExternalSessionExpired)
+}
+
+func TestAuthenticator_ConsumeLoginStateOnce(t *testing.T) {
+	ctx := context.Background()
+	auth := NewAuthenticator("", "", "sign-key", time.Minute, WithExternalLogin(0))
+	login, err := auth.IssueLoginState()
+	require.NoError(t, err)
+	require.NotContains(t, login.State, login.Verifier)
+
+	other, err := auth.IssueLoginState()
+	require.NoError(t, err)
+	_, err = auth.ConsumeLoginState(ctx, login.State, other.Cookie)
+	require.ErrorIs(t, err, ErrInvalidCredential)
+	_, err = auth.ConsumeLoginState(ctx, login.State, "")
+	require.Error(t, err)
+
+	got, err := auth.ConsumeLoginState(ctx, login.State, login.Cookie)
+	require.NoError(t, err)
+	require.Equal(t, login.Nonce, got.Nonce)
+	require.Equal(t, login.Verifier, got.Verifier)
+	_, err = auth.ConsumeLoginState(ctx, login.State, login.Cookie)
+	require.ErrorIs(t, err, ErrTokenRevoked)
 }
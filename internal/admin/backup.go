@@ -0,0 +1,467 @@
+package admin
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+	"gorm.io/datatypes"
+
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// BackupVersion 是当前备份文档的格式版本，恢复时拒绝其他版本。
+const BackupVersion = 1
+
+// backupCipher 标识上游密钥的加密方式：scrypt 由口令派生密钥，AES-256-GCM 加密。
+const backupCipher = "scrypt-aes-256-gcm"
+
+// ErrInvalidBackup 表示备份文档不合法，如版本不支持、缺少或提供了错误的口令。
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// Backup 是灾备与环境克隆使用的完整备份文档。记录保留原始 ID 与 API Key 哈希，
+// 恢复后客户端原有的 API Key 仍然可用；上游凭据的明文密钥只在提供口令时加密写入。
+type Backup struct {
+	Version    int               `json:"version"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Encryption *BackupEncryption `json:"encryption,omitempty"`
+	Rules      []rules.Rule      `json:"rules"`
+	Users      []BackupUser      `json:"users"`
+	APIKeys    []BackupAPIKey    `json:"api_keys"`
+	Upstreams  []BackupUpstream  `json:"upstreams"`
+	Pools      []BackupPool      `json:"pools"`
+	Bindings   []BackupBinding   `json:"bindings"`
+}
+
+// BackupEncryption 描述上游密钥的加密参数，Salt 为 base64 编码。
+type BackupEncryption struct {
+	Cipher string `json:"cipher"`
+	Salt   string `json:"salt"`
+}
+
+// BackupUser 是备份中的用户。
+type BackupUser struct {
+	ID                    string         `json:"id"`
+	Name                  string         `json:"name"`
+	Description           string         `json:"description,omitempty"`
+	Metadata              map[string]any `json:"metadata,omitempty"`
+	MaxRequestsPerMinute  int            `json:"max_requests_per_minute,omitempty"`
+	MaxConcurrentRequests int            `json:"max_concurrent_requests,omitempty"`
+	AllowedModels         []string       `json:"allowed_models,omitempty"`
+	DeniedModels          []string       `json:"denied_models,omitempty"`
+	CreatedAt             time.Time      `json:"created_at"`
+}
+
+// BackupAPIKey 是备份中的 API Key，SecretHash 为密钥哈希而非明文。
+type BackupAPIKey struct {
+	ID                    string         `json:"id"`
+	UserID                string         `json:"user_id"`
+	Label                 string         `json:"label,omitempty"`
+	Prefix                string         `json:"prefix"`
+	SecretHash            string         `json:"secret_hash"`
+	Enabled               bool           `json:"enabled"`
+	Metadata              map[string]any `json:"metadata,omitempty"`
+	MaxRequestsPerMinute  int            `json:"max_requests_per_minute,omitempty"`
+	MaxConcurrentRequests int            `json:"max_concurrent_requests,omitempty"`
+	AllowedModels         []string       `json:"allowed_models,omitempty"`
+	DeniedModels          []string       `json:"denied_models,omitempty"`
+	CreatedAt             time.Time      `json:"created_at"`
+}
+
+// BackupUpstream 是备份中的上游凭据。EncryptedAPIKey 仅在备份时提供口令才会写入。
+type BackupUpstream struct {
+	ID              string         `json:"id"`
+	UserID          string         `json:"user_id"`
+	Service         string         `json:"service"`
+	Name            string         `json:"name,omitempty"`
+	EncryptedAPIKey string         `json:"encrypted_api_key,omitempty"`
+	SecretRef       string         `json:"secret_ref,omitempty"`
+	Endpoints       []string       `json:"endpoints,omitempty"`
+	Metadata        map[string]any `json:"metadata,omitempty"`
+	Enabled         bool           `json:"enabled"`
+	IsDefault       bool           `json:"is_default,omitempty"`
+	PoolID          string         `json:"pool_id,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+// BackupPool 是备份中的上游 Key 池，成员关系记录在 BackupUpstream.PoolID 上。
+type BackupPool struct {
+	ID        string         `json:"id"`
+	UserID    string         `json:"user_id"`
+	Name      string         `json:"name"`
+	Service   string         `json:"service"`
+	Strategy  string         `json:"strategy"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// BackupBinding 是备份中的 API Key 绑定。
+type BackupBinding struct {
+	ID         string         `json:"id"`
+	UserID     string         `json:"user_id"`
+	APIKeyID   string         `json:"api_key_id"`
+	UpstreamID string         `json:"upstream_id"`
+	Service    string         `json:"service"`
+	Position   int            `json:"position"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// BackupOptions 控制备份内容。
+type BackupOptions struct {
+	// Passphrase 非空时上游凭据的明文密钥以该口令加密写入备份，否则不包含明文密钥。
+	Passphrase string
+}
+
+// RestoreOptions 控制恢复。
+type RestoreOptions struct {
+	// Actor 记录为恢复规则的修改人。
+	Actor string
+	// Passphrase 用于解密备份中的上游密钥，备份已加密时必填。
+	Passphrase string
+}
+
+// RestoreResult 汇总恢复的记录数。MissingSecrets 列出备份中没有密钥、且当前也不存在的上游凭据，
+// 这些凭据以停用状态恢复，需补充密钥后再启用。
+type RestoreResult struct {
+	Rules          int      `json:"rules"`
+	Users          int      `json:"users"`
+	APIKeys        int      `json:"api_keys"`
+	Upstreams      int      `json:"upstreams"`
+	Pools          int      `json:"pools"`
+	Bindings       int      `json:"bindings"`
+	MissingSecrets []string `json:"missing_secrets"`
+}
+
+// Backup 导出全部规则与账户数据；未启用账户服务时只包含规则。
+func (s *service) Backup(ctx context.Context, opts BackupOptions) (Backup, error) {
+	allRules, err := s.rules.ListRules(ctx)
+	if err != nil {
+		return Backup{}, err
+	}
+	backup := Backup{
+		Version:   BackupVersion,
+		CreatedAt: time.Now().UTC(),
+		Rules:     allRules,
+		Users:     []BackupUser{},
+		APIKeys:   []BackupAPIKey{},
+		Upstreams: []BackupUpstream{},
+		Pools:     []BackupPool{},
+		Bindings:  []BackupBinding{},
+	}
+	if backup.Rules == nil {
+		backup.Rules = []rules.Rule{}
+	}
+	if s.accounts == nil {
+		return backup, nil
+	}
+	snapshot, err := s.accounts.ExportSnapshot(ctx)
+	if err != nil {
+		return Backup{}, err
+	}
+	var box *secretBox
+	if opts.Passphrase != "" {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return Backup{}, err
+		}
+		if box, err = newSecretBox(opts.Passphrase, salt); err != nil {
+			return Backup{}, err
+		}
+		backup.Encryption = &BackupEncryption{Cipher: backupCipher, Salt: base64.StdEncoding.EncodeToString(salt)}
+	}
+	for _, user := range snapshot.Users {
+		backup.Users = append(backup.Users, BackupUser{
+			ID:                    user.ID,
+			Name:                  user.Name,
+			Description:           user.Description,
+			Metadata:              user.Metadata,
+			MaxRequestsPerMinute:  user.MaxRequestsPerMinute,
+			MaxConcurrentRequests: user.MaxConcurrentRequests,
+			AllowedModels:         user.AllowedModels,
+			DeniedModels:          user.DeniedModels,
+			CreatedAt:             user.CreatedAt,
+		})
+	}
+	for _, key := range snapshot.APIKeys {
+		backup.APIKeys = append(backup.APIKeys, BackupAPIKey{
+			ID:                    key.ID,
+			UserID:                key.UserID,
+			Label:                 key.Label,
+			Prefix:                key.Prefix,
+			SecretHash:            key.SecretHash,
+			Enabled:               key.Enabled,
+			Metadata:              key.Metadata,
+			MaxRequestsPerMinute:  key.MaxRequestsPerMinute,
+			MaxConcurrentRequests: key.MaxConcurrentRequests,
+			AllowedModels:         key.AllowedModels,
+			DeniedModels:          key.DeniedModels,
+			CreatedAt:             key.CreatedAt,
+		})
+	}
+	for _, upstream := range snapshot.Upstreams {
+		item := BackupUpstream{
+			ID:        upstream.ID,
+			UserID:    upstream.UserID,
+			Service:   upstream.Service,
+			Name:      upstream.Name,
+			SecretRef: upstream.SecretRef,
+			Endpoints: decodeEndpoints(upstream.Endpoints),
+			Metadata:  upstream.Metadata,
+			Enabled:   upstream.Enabled,
+			IsDefault: upstream.IsDefault,
+			PoolID:    upstream.PoolID,
+			CreatedAt: upstream.CreatedAt,
+		}
+		if box != nil && upstream.APIKey != "" {
+			if item.EncryptedAPIKey, err = box.seal(upstream.ID, upstream.APIKey); err != nil {
+				return Backup{}, err
+			}
+		}
+		backup.Upstreams = append(backup.Upstreams, item)
+	}
+	for _, pool := range snapshot.Pools {
+		backup.Pools = append(backup.Pools, BackupPool{
+			ID:        pool.ID,
+			UserID:    pool.UserID,
+			Name:      pool.Name,
+			Service:   pool.Service,
+			Strategy:  pool.Strategy,
+			Metadata:  pool.Metadata,
+			CreatedAt: pool.CreatedAt,
+		})
+	}
+	for _, binding := range snapshot.Bindings {
+		backup.Bindings = append(backup.Bindings, BackupBinding{
+			ID:         binding.ID,
+			UserID:     binding.UserID,
+			APIKeyID:   binding.UserAPIKeyID,
+			UpstreamID: binding.UpstreamKeyID,
+			Service:    binding.Service,
+			Position:   binding.Position,
+			Metadata:   binding.Metadata,
+			CreatedAt:  binding.CreatedAt,
+		})
+	}
+	return backup, nil
+}
+
+// Restore 按 ID 写入备份中的账户数据与规则，已存在的同 ID 记录被覆盖，备份之外的记录保持不变。
+// 账户数据在单个事务内写入；规则随后逐条写入，内容未变化的规则不会递增版本。
+func (s *service) Restore(ctx context.Context, backup Backup, opts RestoreOptions) (RestoreResult, error) {
+	if backup.Version != BackupVersion {
+		return RestoreResult{}, fmt.Errorf("%w: unsupported version %d", ErrInvalidBackup, backup.Version)
+	}
+	for _, rule := range backup.Rules {
+		if err := rule.Validate(); err != nil {
+			return RestoreResult{}, fmt.Errorf("%w: rule %q: %v", ErrInvalidBackup, rule.ID, err)
+		}
+	}
+	result := RestoreResult{MissingSecrets: []string{}}
+	hasAccounts := len(backup.Users) > 0 || len(backup.APIKeys) > 0 || len(backup.Upstreams) > 0 || len(backup.Pools) > 0 || len(backup.Bindings) > 0
+	if hasAccounts {
+		if s.accounts == nil {
+			return RestoreResult{}, ErrAccountsUnavailable
+		}
+		snapshot, missing, err := s.restoreSnapshot(ctx, backup, opts.Passphrase)
+		if err != nil {
+			return RestoreResult{}, err
+		}
+		if err := s.accounts.ImportSnapshot(ctx, snapshot); err != nil {
+			return RestoreResult{}, err
+		}
+		result.Users = len(snapshot.Users)
+		result.APIKeys = len(snapshot.APIKeys)
+		result.Upstreams = len(snapshot.Upstreams)
+		result.Pools = len(snapshot.Pools)
+		result.Bindings = len(snapshot.Bindings)
+		result.MissingSecrets = missing
+	}
+	for _, rule := range backup.Rules {
+		existing, err := s.rules.GetRule(ctx, rule.ID)
+		if err == nil && sameJSON(ruleSpec(existing), ruleSpec(rule)) {
+			result.Rules++
+			continue
+		}
+		if err != nil && !errors.Is(err, rules.ErrRuleNotFound) {
+			return result, err
+		}
+		rule.UpdatedBy = opts.Actor
+		if err := s.rules.UpsertRule(ctx, rule); err != nil {
+			return result, fmt.Errorf("restore rule %s: %w", rule.ID, err)
+		}
+		result.Rules++
+	}
+	return result, nil
+}
+
+// restoreSnapshot 将备份转换为账户快照并解密上游密钥。没有密钥的上游凭据沿用当前同 ID 记录的密钥，
+// 当前也不存在时以停用状态恢复并返回其 ID。
+func (s *service) restoreSnapshot(ctx context.Context, backup Backup, passphrase string) (accounts.Snapshot, []string, error) {
+	var box *secretBox
+	if backup.Encryption != nil {
+		if backup.Encryption.Cipher != backupCipher {
+			return accounts.Snapshot{}, nil, fmt.Errorf("%w: unsupported cipher %q", ErrInvalidBackup, backup.Encryption.Cipher)
+		}
+		if passphrase == "" {
+			return accounts.Snapshot{}, nil, fmt.Errorf("%w: passphrase required for encrypted secrets", ErrInvalidBackup)
+		}
+		salt, err := base64.StdEncoding.DecodeString(backup.Encryption.Salt)
+		if err != nil {
+			return accounts.Snapshot{}, nil, fmt.Errorf("%w: malformed salt", ErrInvalidBackup)
+		}
+		if box, err = newSecretBox(passphrase, salt); err != nil {
+			return accounts.Snapshot{}, nil, err
+		}
+	}
+	var snapshot accounts.Snapshot
+	missing := []string{}
+	for _, user := range backup.Users {
+		snapshot.Users = append(snapshot.Users, accounts.User{
+			ID:          user.ID,
+			Name:        user.Name,
+			Description: user.Description,
+			Metadata:    user.Metadata,
+			RateLimits:  accounts.RateLimits{MaxRequestsPerMinute: user.MaxRequestsPerMinute, MaxConcurrentRequests: user.MaxConcurrentRequests},
+			ModelAccess: accounts.ModelAccess{AllowedModels: user.AllowedModels, DeniedModels: user.DeniedModels},
+			CreatedAt:   user.CreatedAt,
+		})
+	}
+	for _, key := range backup.APIKeys {
+		snapshot.APIKeys = append(snapshot.APIKeys, accounts.APIKey{
+			ID:          key.ID,
+			UserID:      key.UserID,
+			Label:       key.Label,
+			Prefix:      key.Prefix,
+			SecretHash:  key.SecretHash,
+			Enabled:     key.Enabled,
+			Metadata:    key.Metadata,
+			RateLimits:  accounts.RateLimits{MaxRequestsPerMinute: key.MaxRequestsPerMinute, MaxConcurrentRequests: key.MaxConcurrentRequests},
+			ModelAccess: accounts.ModelAccess{AllowedModels: key.AllowedModels, DeniedModels: key.DeniedModels},
+			CreatedAt:   key.CreatedAt,
+		})
+	}
+	for _, upstream := range backup.Upstreams {
+		if err := s.checkSecretRef(upstream.SecretRef); err != nil {
+			return accounts.Snapshot{}, nil, fmt.Errorf("%w: upstream %q: %v", ErrInvalidBackup, upstream.ID, err)
+		}
+		cred := accounts.UpstreamCredential{
+			ID:        upstream.ID,
+			UserID:    upstream.UserID,
+			Service:   upstream.Service,
+			Name:      upstream.Name,
+			SecretRef: upstream.SecretRef,
+			Metadata:  upstream.Metadata,
+			Enabled:   upstream.Enabled,
+			IsDefault: upstream.IsDefault,
+			PoolID:    upstream.PoolID,
+			CreatedAt: upstream.CreatedAt,
+		}
+		if len(upstream.Endpoints) > 0 {
+			encoded, err := json.Marshal(upstream.Endpoints)
+			if err != nil {
+				return accounts.Snapshot{}, nil, err
+			}
+			cred.Endpoints = datatypes.JSON(encoded)
+		}
+		switch {
+		case upstream.EncryptedAPIKey != "":
+			if box == nil {
+				return accounts.Snapshot{}, nil, fmt.Errorf("%w: upstream %q has encrypted secret but backup has no encryption parameters", ErrInvalidBackup, upstream.ID)
+			}
+			plaintext, err := box.open(upstream.ID, upstream.EncryptedAPIKey)
+			if err != nil {
+				return accounts.Snapshot{}, nil, err
+			}
+			cred.APIKey = plaintext
+		case upstream.SecretRef == "":
+			current, err := s.accounts.GetUpstreamCredential(ctx, upstream.ID)
+			switch {
+			case err == nil && current.APIKey != "":
+				cred.APIKey = current.APIKey
+			case err == nil || errors.Is(err, accounts.ErrNotFound):
+				cred.Enabled = false
+				cred.IsDefault = false
+				missing = append(missing, upstream.ID)
+			default:
+				return accounts.Snapshot{}, nil, err
+			}
+		}
+		snapshot.Upstreams = append(snapshot.Upstreams, cred)
+	}
+	for _, pool := range backup.Pools {
+		snapshot.Pools = append(snapshot.Pools, accounts.UpstreamKeyPool{
+			ID:        pool.ID,
+			UserID:    pool.UserID,
+			Name:      pool.Name,
+			Service:   pool.Service,
+			Strategy:  pool.Strategy,
+			Metadata:  pool.Metadata,
+			CreatedAt: pool.CreatedAt,
+		})
+	}
+	for _, binding := range backup.Bindings {
+		snapshot.Bindings = append(snapshot.Bindings, accounts.UserAPIKeyBinding{
+			ID:            binding.ID,
+			UserID:        binding.UserID,
+			UserAPIKeyID:  binding.APIKeyID,
+			UpstreamKeyID: binding.UpstreamID,
+			Service:       binding.Service,
+			Position:      binding.Position,
+			Metadata:      binding.Metadata,
+			CreatedAt:     binding.CreatedAt,
+		})
+	}
+	return snapshot, missing, nil
+}
+
+// secretBox 以口令派生的密钥加解密上游密钥，凭据 ID 作为附加数据，密文不能挪用到其他凭据。
+type secretBox struct {
+	aead cipher.AEAD
+}
+
+func newSecretBox(passphrase string, salt []byte) (*secretBox, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &secretBox{aead: aead}, nil
+}
+
+func (b *secretBox) seal(id, plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), []byte(id))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (b *secretBox) open(id, encoded string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < b.aead.NonceSize() {
+		return "", fmt.Errorf("%w: malformed secret for upstream %q", ErrInvalidBackup, id)
+	}
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("%w: wrong passphrase or corrupted secret for upstream %q", ErrInvalidBackup, id)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,132 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/rules"
+	"github.com/prehisle/yapi/pkg/secrets"
+)
+
+func setupBackupRouter(t *testing.T, dsn string) (*gin.Engine, rules.Service, accounts.Service) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	accountSvc := accounts.NewService(db)
+	require.NoError(t, accountSvc.AutoMigrate(context.Background()))
+	ruleSvc := rules.NewService(rules.NewMemoryStore())
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(NewService(ruleSvc, accountSvc), auth), auth.Middleware())
+	return router, ruleSvc, accountSvc
+}
+
+func TestHandler_BackupRestore_ClonesEnvironment(t *testing.T) {
+	ctx := context.Background()
+	source, sourceRules, sourceAccounts := setupBackupRouter(t, "file:admin_backup_source?mode=memory&cache=shared")
+	require.NoError(t, sourceRules.UpsertRule(ctx, rules.Rule{ID: "chat", Priority: 10, Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"}, Actions: rules.Actions{SetTargetURL: "https://api.openai.com"}}))
+	user, err := sourceAccounts.CreateUser(ctx, accounts.CreateUserParams{Name: "alice"})
+	require.NoError(t, err)
+	key, plain, err := sourceAccounts.CreateUserAPIKey(ctx, accounts.CreateAPIKeyParams{UserID: user.ID})
+	require.NoError(t, err)
+	cred, err := sourceAccounts.CreateUpstreamCredential(ctx, accounts.CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", Plaintext: "sk-upstream"})
+	require.NoError(t, err)
+	_, err = sourceAccounts.BindAPIKey(ctx, accounts.BindAPIKeyParams{UserID: user.ID, UserAPIKeyID: key.ID, UpstreamCredentialID: cred.ID})
+	require.NoError(t, err)
+
+	withPassphrase := func(passphrase string) func(*http.Request) {
+		return func(req *http.Request) {
+			req.SetBasicAuth("admin", "secret")
+			req.Header.Set(BackupPassphraseHeader, passphrase)
+		}
+	}
+	rec := doAdminRequest(source, http.MethodGet, "/admin/backup", "", withPassphrase("correct horse"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Header().Get("Content-Disposition"), "yapi-backup-")
+	require.NotContains(t, rec.Body.String(), "sk-upstream")
+	encrypted := rec.Body.String()
+
+	target, targetRules, targetAccounts := setupBackupRouter(t, "file:admin_backup_target?mode=memory&cache=shared")
+	rec = doAdminRequest(target, http.MethodPost, "/admin/restore", encrypted, basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = doAdminRequest(target, http.MethodPost, "/admin/restore", encrypted, withPassphrase("wrong"))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = doAdminRequest(target, http.MethodPost, "/admin/restore", encrypted, withPassphrase("correct horse"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var result RestoreResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	require.Equal(t, RestoreResult{Rules: 1, Users: 1, APIKeys: 1, Upstreams: 1, Bindings: 1, MissingSecrets: []string{}}, result)
+
+	rule, err := targetRules.GetRule(ctx, "chat")
+	require.NoError(t, err)
+	require.Equal(t, "https://api.openai.com", rule.Actions.SetTargetURL)
+	_, upstream, err := targetAccounts.ResolveBindingByRawKey(ctx, plain)
+	require.NoError(t, err)
+	require.Equal(t, "sk-upstream", upstream.APIKey)
+
+	// 不含密钥的备份恢复到新环境时，上游凭据以停用状态恢复并在结果中列出。
+	rec = doAdminRequest(source, http.MethodGet, "/admin/backup", "", basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	plainBackup := rec.Body.String()
+	fresh, _, freshAccounts := setupBackupRouter(t, "file:admin_backup_fresh?mode=memory&cache=shared")
+	rec = doAdminRequest(fresh, http.MethodPost, "/admin/restore", plainBackup, basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	require.Equal(t, []string{cred.ID}, result.MissingSecrets)
+	restored, err := freshAccounts.GetUpstreamCredential(ctx, cred.ID)
+	require.NoError(t, err)
+	require.False(t, restored.Enabled)
+
+	// 恢复到已有密钥的环境时沿用当前密钥。
+	rec = doAdminRequest(target, http.MethodPost, "/admin/restore", plainBackup, basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	require.Empty(t, result.MissingSecrets)
+	_, upstream, err = targetAccounts.ResolveBindingByRawKey(ctx, plain)
+	require.NoError(t, err)
+	require.Equal(t, "sk-upstream", upstream.APIKey)
+}
+
+func TestService_RejectsForbiddenSecretRefs(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open("file:admin_secret_refs?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	accountSvc := accounts.NewService(db)
+	require.NoError(t, accountSvc.AutoMigrate(ctx))
+	checker := secrets.NewCachingResolver(0, secrets.NewVaultProvider("http://vault.invalid", "root-token", "", []string{"secret/yapi/*"}))
+	svc := NewService(rules.NewService(rules.NewMemoryStore()), accountSvc, WithSecretRefChecker(checker))
+
+	user, err := accountSvc.CreateUser(ctx, accounts.CreateUserParams{Name: "alice"})
+	require.NoError(t, err)
+	_, err = svc.CreateUpstreamCredential(ctx, accounts.CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", SecretRef: "vault://secret/admin/root#token"})
+	require.ErrorIs(t, err, accounts.ErrInvalidInput)
+	require.ErrorIs(t, err, secrets.ErrSecretForbidden)
+
+	cred, err := svc.CreateUpstreamCredential(ctx, accounts.CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", SecretRef: "vault://secret/yapi/openai#api_key"})
+	require.NoError(t, err)
+	forbidden := "vault://secret/admin/root#token"
+	_, err = svc.UpdateUpstreamCredential(ctx, accounts.UpdateUpstreamCredentialParams{CredentialID: cred.ID, SecretRef: &forbidden})
+	require.ErrorIs(t, err, secrets.ErrSecretForbidden)
+
+	_, err = svc.Restore(ctx, Backup{
+		Version:   BackupVersion,
+		Users:     []BackupUser{{ID: user.ID, Name: "alice"}},
+		Upstreams: []BackupUpstream{{ID: cred.ID, UserID: user.ID, Service: "openai", SecretRef: forbidden, Enabled: true}},
+	}, RestoreOptions{})
+	require.ErrorIs(t, err, ErrInvalidBackup)
+
+	stored, err := accountSvc.GetUpstreamCredential(ctx, cred.ID)
+	require.NoError(t, err)
+	require.Equal(t, "vault://secret/yapi/openai#api_key", stored.SecretRef)
+}
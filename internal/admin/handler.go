@@ -1,26 +1,63 @@
 package admin
 
 import (
+	"cmp"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/prehisle/yapi/internal/adminusers"
+	"github.com/prehisle/yapi/internal/archive"
+	"github.com/prehisle/yapi/internal/audit"
+	"github.com/prehisle/yapi/internal/budget"
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/features"
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/modelalias"
+	"github.com/prehisle/yapi/internal/oidc"
+	"github.com/prehisle/yapi/internal/reqtrace"
+	"github.com/prehisle/yapi/internal/servicetokens"
 	"github.com/prehisle/yapi/pkg/accounts"
 	"github.com/prehisle/yapi/pkg/metrics"
 	"github.com/prehisle/yapi/pkg/rules"
 )
 
+const (
+	defaultAccountsPageLimit = 100
+	maxAccountsPageLimit     = 1000
+)
+
 // Handler 暴露管理端的 REST API。
 type Handler struct {
 	service Service
 	auth    *Authenticator
 	logger  *slog.Logger
+	audit   audit.Store
+	events  rules.EventBus
+
+	adminUsers       adminusers.Service
+	serviceTokens    servicetokens.Service
+	oidc             *oidc.Provider
+	oidcPostLoginURL string
+	cookies          SessionCookieOptions
+	traces           reqtrace.Store
+	replayer         RequestReplayer
+	runtimeConfig    *RuntimeConfig
+	logLevel         *slog.LevelVar
+	features         *features.Registry
+	reloader         ConfigReloader
+	modelAliases     modelalias.Service
+	conversations    *archive.Archive
+	budgets          *budget.Service
+	requireIfMatch   bool
 }
 
 // NewHandler 创建管理端处理器。
@@ -48,44 +85,119 @@ func WithLogger(logger *slog.Logger) Option {
 	}
 }
 
-// RegisterProtectedRoutes 将受保护的管理路由挂载到给定分组。
+// RegisterProtectedRoutes 将受保护的管理路由挂载到给定分组，每条路由声明所需权限。
 func RegisterProtectedRoutes(group *gin.RouterGroup, handler *Handler) {
-	group.GET("/rules", handler.listRules)
-	group.POST("/rules", handler.createOrUpdateRule)
-	group.PUT("/rules/:id", handler.createOrUpdateRule)
-	group.DELETE("/rules/:id", handler.deleteRule)
+	require := handler.auth.RequirePermission
+	rulesRead, rulesWrite := require(PermRulesRead), require(PermRulesWrite)
+	accountsRead, accountsWrite := require(PermAccountsRead), require(PermAccountsWrite)
+
+	group.GET("/rules", rulesRead, handler.listRules)
+	group.POST("/rules", rulesWrite, handler.createOrUpdateRule)
+	group.POST("/rules/diff", rulesRead, handler.diffRules)
+	group.GET("/rules/:id", rulesRead, handler.getRule)
+	group.POST("/rules/:id/explain", rulesRead, handler.explainRule)
+	group.PUT("/rules/:id", rulesWrite, handler.createOrUpdateRule)
+	group.PATCH("/rules/:id", rulesWrite, handler.patchRule)
+	group.POST("/rules/:id/enable", rulesWrite, handler.enableRule)
+	group.POST("/rules/:id/disable", rulesWrite, handler.disableRule)
+	group.DELETE("/rules/:id", rulesWrite, handler.deleteRule)
+
+	group.GET("/users", accountsRead, handler.listUsers)
+	group.POST("/users", accountsWrite, handler.createUser)
+	group.GET("/users/:id", accountsRead, handler.getUser)
+	group.PATCH("/users/:id", accountsWrite, handler.patchUser)
+	group.DELETE("/users/:id", accountsWrite, handler.deleteUser)
+
+	group.GET("/users/:id/api-keys", accountsRead, handler.listUserAPIKeys)
+	group.POST("/users/:id/api-keys", accountsWrite, handler.createUserAPIKey)
+	group.PATCH("/api-keys/:id", accountsWrite, handler.patchUserAPIKey)
+	group.DELETE("/api-keys/:id", accountsWrite, handler.deleteUserAPIKey)
+
+	group.GET("/users/:id/upstreams", accountsRead, handler.listUpstreamCredentials)
+	group.POST("/users/:id/upstreams", accountsWrite, handler.createUpstreamCredential)
+	group.PUT("/upstreams/:id", accountsWrite, handler.updateUpstreamCredential)
+	group.PATCH("/upstreams/:id", accountsWrite, handler.patchUpstreamCredential)
+	group.DELETE("/upstreams/:id", accountsWrite, handler.deleteUpstreamCredential)
+	group.POST("/upstreams/:id/verify", accountsWrite, handler.verifyUpstreamCredential)
+
+	group.GET("/users/:id/upstream-pools", accountsRead, handler.listUpstreamKeyPools)
+	group.POST("/users/:id/upstream-pools", accountsWrite, handler.createUpstreamKeyPool)
+	group.PUT("/upstream-pools/:id", accountsWrite, handler.updateUpstreamKeyPool)
+	group.DELETE("/upstream-pools/:id", accountsWrite, handler.deleteUpstreamKeyPool)
+
+	group.POST("/api-keys/:id/binding", accountsWrite, handler.bindAPIKey)
+	group.GET("/api-keys/:id/binding", accountsRead, handler.getAPIKeyBinding)
+	group.GET("/api-keys/:id/bindings", accountsRead, handler.listAPIKeyBindings)
+	group.GET("/users/:id/bindings", accountsRead, handler.listUserBindings)
+	group.DELETE("/bindings/:id", accountsWrite, handler.deleteBinding)
 
-	group.GET("/users", handler.listUsers)
-	group.POST("/users", handler.createUser)
-	group.DELETE("/users/:id", handler.deleteUser)
+	group.GET("/audit-logs", require(PermAuditRead), handler.listAuditLogs)
+	group.POST("/apply", require(PermRulesWrite, PermAccountsWrite), handler.apply)
+	group.GET("/events", require(PermEventsRead), handler.streamEvents)
+	group.GET("/requests/:request_id", require(PermRequestsRead), handler.getRequestTrace)
+	group.POST("/requests/:request_id/replay", require(PermRequestsReplay), handler.replayRequest)
 
-	group.GET("/users/:id/api-keys", handler.listUserAPIKeys)
-	group.POST("/users/:id/api-keys", handler.createUserAPIKey)
-	group.DELETE("/api-keys/:id", handler.deleteUserAPIKey)
+	group.GET("/conversations", require(PermConversationsRead), handler.listConversations)
+	group.GET("/conversations/export", require(PermConversationsRead), handler.exportConversations)
+	group.GET("/conversations/:id", require(PermConversationsRead), handler.getConversation)
+	group.DELETE("/conversations", require(PermConversationsWrite), handler.deleteUserConversations)
+	group.DELETE("/conversations/:id", require(PermConversationsWrite), handler.deleteConversation)
 
-	group.GET("/users/:id/upstreams", handler.listUpstreamCredentials)
-	group.POST("/users/:id/upstreams", handler.createUpstreamCredential)
-	group.PUT("/upstreams/:id", handler.updateUpstreamCredential)
-	group.DELETE("/upstreams/:id", handler.deleteUpstreamCredential)
+	group.GET("/admin-users", require(PermAdminUsersRead), handler.listAdminUsers)
+	group.POST("/admin-users", require(PermAdminUsersWrite), handler.createAdminUser)
+	group.PATCH("/admin-users/:id", require(PermAdminUsersWrite), handler.patchAdminUser)
+	group.DELETE("/admin-users/:id", require(PermAdminUsersWrite), handler.deleteAdminUser)
 
-	group.POST("/api-keys/:id/binding", handler.bindAPIKey)
-	group.GET("/api-keys/:id/binding", handler.getAPIKeyBinding)
+	group.GET("/model-aliases", rulesRead, handler.listModelAliases)
+	group.GET("/model-aliases/:name", rulesRead, handler.getModelAlias)
+	group.PUT("/model-aliases/:name", rulesWrite, handler.putModelAlias)
+	group.DELETE("/model-aliases/:name", rulesWrite, handler.deleteModelAlias)
+
+	group.GET("/budgets", accountsRead, handler.listBudgets)
+	group.GET("/budgets/:scope/:subject", accountsRead, handler.getBudget)
+	group.PUT("/budgets/:scope/:subject", accountsWrite, handler.putBudget)
+	group.DELETE("/budgets/:scope/:subject", accountsWrite, handler.deleteBudget)
+	group.POST("/budgets/:scope/:subject/reset", accountsWrite, handler.resetBudget)
+
+	group.GET("/service-tokens", require(PermSvcTokensRead), handler.listServiceTokens)
+	group.POST("/service-tokens", require(PermSvcTokensWrite), handler.createServiceToken)
+	group.DELETE("/service-tokens/:id", require(PermSvcTokensWrite), handler.revokeServiceToken)
+
+	group.GET("/backup", require(PermBackupRead), handler.backup)
+	group.POST("/restore", require(PermBackupWrite), handler.restore)
+
+	group.GET("/config", require(PermConfigRead), handler.getConfig)
+	group.POST("/config/reload", require(PermConfigWrite), handler.reloadConfig)
+	group.GET("/loglevel", require(PermConfigRead), handler.getLogLevel)
+	group.PUT("/loglevel", require(PermConfigWrite), handler.setLogLevel)
+	group.GET("/features", require(PermConfigRead), handler.listFeatureFlags)
+	group.PUT("/features/:name", require(PermConfigWrite), handler.setFeatureFlag)
+	group.GET("/observability/dashboards", require(PermConfigRead), handler.getObservabilityDashboards)
 }
 
 // RegisterPublicRoutes 注册无需认证的公共路由。
 func RegisterPublicRoutes(group *gin.RouterGroup, handler *Handler) {
 	group.GET("/healthz", handler.healthz)
 	group.POST("/login", handler.login)
+	group.POST("/token/refresh", handler.refreshToken)
+	group.POST("/logout", handler.logout)
+	group.GET("/oidc/login", handler.oidcLogin)
+	group.GET("/oidc/callback", handler.oidcCallback)
+	group.GET("/openapi.json", handler.openAPI)
 }
 
 type createUserRequest struct {
 	Name        string         `json:"name" binding:"required"`
 	Description string         `json:"description"`
 	Metadata    map[string]any `json:"metadata"`
+	rateLimitsPayload
+	modelAccessPayload
 }
 
 type createAPIKeyRequest struct {
 	Label string `json:"label"`
+	rateLimitsPayload
+	modelAccessPayload
 }
 
 type createUpstreamCredentialRequest struct {
@@ -93,14 +205,18 @@ type createUpstreamCredentialRequest struct {
 	Service   string         `json:"service"`
 	Label     string         `json:"label"`
 	Name      string         `json:"name"`
-	Plaintext string         `json:"plaintext" binding:"required"`
+	Plaintext string         `json:"plaintext"`
+	SecretRef string         `json:"secret_ref"`
 	Endpoints []string       `json:"endpoints"`
 	Metadata  map[string]any `json:"metadata"`
 }
 
 type bindAPIKeyRequest struct {
-	UserID               string `json:"user_id" binding:"required"`
-	UpstreamCredentialID string `json:"upstream_credential_id" binding:"required"`
+	UserID               string         `json:"user_id" binding:"required"`
+	UpstreamCredentialID string         `json:"upstream_credential_id" binding:"required"`
+	Service              string         `json:"service"`
+	Position             int            `json:"position" binding:"min=0"`
+	Metadata             map[string]any `json:"metadata"`
 }
 
 type updateUpstreamCredentialRequest struct {
@@ -110,6 +226,7 @@ type updateUpstreamCredentialRequest struct {
 	Label     *string        `json:"label"`
 	Name      *string        `json:"name"`
 	Plaintext *string        `json:"plaintext"`
+	SecretRef *string        `json:"secret_ref"`
 	Endpoints *[]string      `json:"endpoints"`
 	Metadata  map[string]any `json:"metadata"`
 	Enabled   *bool          `json:"enabled"`
@@ -120,8 +237,10 @@ type userResponse struct {
 	Name        string         `json:"name"`
 	Description string         `json:"description"`
 	Metadata    map[string]any `json:"metadata,omitempty"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
+	rateLimitsPayload
+	modelAccessPayload
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type apiKeyResponse struct {
@@ -129,22 +248,30 @@ type apiKeyResponse struct {
 	UserID     string     `json:"user_id"`
 	Label      string     `json:"label"`
 	Prefix     string     `json:"prefix"`
+	Enabled    bool       `json:"enabled"`
 	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
+	rateLimitsPayload
+	modelAccessPayload
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type upstreamCredentialResponse struct {
-	ID        string         `json:"id"`
-	UserID    string         `json:"user_id"`
-	Provider  string         `json:"provider"`
-	Service   string         `json:"service"`
-	Label     string         `json:"label"`
-	Name      string         `json:"name"`
-	Endpoints []string       `json:"endpoints,omitempty"`
-	Metadata  map[string]any `json:"metadata,omitempty"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
+	ID        string                  `json:"id"`
+	UserID    string                  `json:"user_id"`
+	Provider  string                  `json:"provider"`
+	Service   string                  `json:"service"`
+	Label     string                  `json:"label"`
+	Name      string                  `json:"name"`
+	SecretRef string                  `json:"secret_ref,omitempty"`
+	PoolID    string                  `json:"pool_id,omitempty"`
+	Enabled   bool                    `json:"enabled"`
+	IsDefault bool                    `json:"is_default"`
+	Endpoints []string                `json:"endpoints,omitempty"`
+	Metadata  map[string]any          `json:"metadata,omitempty"`
+	Health    *upstreamHealthResponse `json:"health,omitempty"`
+	CreatedAt time.Time               `json:"created_at"`
+	UpdatedAt time.Time               `json:"updated_at"`
 }
 
 type apiKeyBindingResponse struct {
@@ -152,6 +279,8 @@ type apiKeyBindingResponse struct {
 	UserID               string                     `json:"user_id"`
 	UserAPIKeyID         string                     `json:"user_api_key_id"`
 	UpstreamCredentialID string                     `json:"upstream_credential_id"`
+	Service              string                     `json:"service"`
+	Position             int                        `json:"position"`
 	Metadata             map[string]any             `json:"metadata,omitempty"`
 	CreatedAt            time.Time                  `json:"created_at"`
 	UpdatedAt            time.Time                  `json:"updated_at"`
@@ -164,24 +293,29 @@ func toUserResponse(user accounts.User) userResponse {
 		metadata = map[string]any(user.Metadata)
 	}
 	return userResponse{
-		ID:          user.ID,
-		Name:        user.Name,
-		Description: user.Description,
-		Metadata:    metadata,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
+		ID:                 user.ID,
+		Name:               user.Name,
+		Description:        user.Description,
+		Metadata:           metadata,
+		rateLimitsPayload:  toRateLimitsPayload(user.RateLimits),
+		modelAccessPayload: toModelAccessPayload(user.ModelAccess),
+		CreatedAt:          user.CreatedAt,
+		UpdatedAt:          user.UpdatedAt,
 	}
 }
 
 func toAPIKeyResponse(key accounts.APIKey) apiKeyResponse {
 	return apiKeyResponse{
-		ID:         key.ID,
-		UserID:     key.UserID,
-		Label:      key.Label,
-		Prefix:     key.Prefix,
-		LastUsedAt: key.LastUsedAt,
-		CreatedAt:  key.CreatedAt,
-		UpdatedAt:  key.UpdatedAt,
+		ID:                 key.ID,
+		UserID:             key.UserID,
+		Label:              key.Label,
+		Prefix:             key.Prefix,
+		Enabled:            key.Enabled,
+		LastUsedAt:         key.LastUsedAt,
+		rateLimitsPayload:  toRateLimitsPayload(key.RateLimits),
+		modelAccessPayload: toModelAccessPayload(key.ModelAccess),
+		CreatedAt:          key.CreatedAt,
+		UpdatedAt:          key.UpdatedAt,
 	}
 }
 
@@ -200,8 +334,13 @@ func toUpstreamCredentialResponse(cred accounts.UpstreamCredential, endpoints []
 		Service:   cred.Service,
 		Label:     cred.Name,
 		Name:      cred.Name,
+		SecretRef: cred.SecretRef,
+		PoolID:    cred.PoolID,
+		Enabled:   cred.Enabled,
+		IsDefault: cred.IsDefault,
 		Endpoints: endpoints,
 		Metadata:  metadata,
+		Health:    toStoredHealthResponse(cred),
 		CreatedAt: cred.CreatedAt,
 		UpdatedAt: cred.UpdatedAt,
 	}
@@ -217,6 +356,8 @@ func toBindingResponse(binding accounts.UserAPIKeyBinding, upstream upstreamCred
 		UserID:               binding.UserID,
 		UserAPIKeyID:         binding.UserAPIKeyID,
 		UpstreamCredentialID: binding.UpstreamKeyID,
+		Service:              binding.Service,
+		Position:             binding.Position,
 		Metadata:             metadata,
 		CreatedAt:            binding.CreatedAt,
 		UpdatedAt:            binding.UpdatedAt,
@@ -254,7 +395,7 @@ func (h *Handler) handleAccountsError(c *gin.Context, action string, err error,
 	}
 	status := http.StatusInternalServerError
 	switch {
-	case errors.Is(err, ErrAccountsUnavailable):
+	case errors.Is(err, ErrAccountsUnavailable), errors.Is(err, ErrVerifierUnavailable):
 		status = http.StatusNotImplemented
 	case errors.Is(err, accounts.ErrInvalidInput):
 		status = http.StatusBadRequest
@@ -268,8 +409,8 @@ func (h *Handler) handleAccountsError(c *gin.Context, action string, err error,
 		"user":   currentAdminUser(c),
 		"action": action,
 	}, attrs)
-	h.logError("accounts action failed", err, attrCopy)
-	c.JSON(status, gin.H{"error": err.Error()})
+	h.logError(c, "accounts action failed", err, attrCopy)
+	errcode.Respond(c, status, errcode.ForStatus(status), err.Error())
 	return true
 }
 
@@ -278,28 +419,32 @@ func (h *Handler) createUser(c *gin.Context) {
 	var req createUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		metrics.ObserveAdminAction(action, false)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
 		return
 	}
 	user, err := h.service.CreateUser(c.Request.Context(), accounts.CreateUserParams{
 		Name:        req.Name,
 		Description: req.Description,
 		Metadata:    req.Metadata,
+		RateLimits:  req.rateLimitsPayload.toRateLimits(),
+		ModelAccess: req.modelAccessPayload.toModelAccess(),
 	})
 	if h.handleAccountsError(c, action, err, nil) {
 		return
 	}
 	metrics.ObserveAdminAction(action, true)
-	h.logInfo("user created", map[string]any{
+	h.logInfo(c, "user created", map[string]any{
 		"user":   currentAdminUser(c),
 		"userID": user.ID,
 	})
+	h.recordAudit(c, action, auditResourceUser, user.ID, nil, toUserResponse(user))
 	c.JSON(http.StatusCreated, toUserResponse(user))
 }
 
 func (h *Handler) listUsers(c *gin.Context) {
 	action := "accounts.users.list"
-	users, err := h.service.ListUsers(c.Request.Context())
+	opts := parseAccountsListQuery(c)
+	users, total, err := h.service.ListUsers(c.Request.Context(), opts)
 	if h.handleAccountsError(c, action, err, nil) {
 		return
 	}
@@ -308,25 +453,30 @@ func (h *Handler) listUsers(c *gin.Context) {
 		resp = append(resp, toUserResponse(user))
 	}
 	metrics.ObserveAdminAction(action, true)
-	c.JSON(http.StatusOK, gin.H{"items": resp})
+	c.JSON(http.StatusOK, listAccountsResponse(resp, total, opts))
 }
 
 func (h *Handler) deleteUser(c *gin.Context) {
 	action := "accounts.users.delete"
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "id is required")
 		return
 	}
+	before := auditSnapshot(h, func() (userResponse, error) {
+		user, err := h.service.GetUser(c.Request.Context(), id)
+		return toUserResponse(user), err
+	})
 	err := h.service.DeleteUser(c.Request.Context(), id)
 	if h.handleAccountsError(c, action, err, map[string]any{"target_user": id}) {
 		return
 	}
 	metrics.ObserveAdminAction(action, true)
-	h.logInfo("user deleted", map[string]any{
+	h.logInfo(c, "user deleted", map[string]any{
 		"user":        currentAdminUser(c),
 		"target_user": id,
 	})
+	h.recordAudit(c, action, auditResourceUser, id, before, nil)
 	c.Status(http.StatusNoContent)
 }
 
@@ -334,28 +484,31 @@ func (h *Handler) createUserAPIKey(c *gin.Context) {
 	action := "accounts.api_keys.create"
 	userID := c.Param("id")
 	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user id is required"})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "user id is required")
 		return
 	}
 	var req createAPIKeyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		metrics.ObserveAdminAction(action, false)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
 		return
 	}
 	key, secret, err := h.service.CreateUserAPIKey(c.Request.Context(), accounts.CreateAPIKeyParams{
-		UserID: userID,
-		Label:  req.Label,
+		UserID:      userID,
+		Label:       req.Label,
+		RateLimits:  req.rateLimitsPayload.toRateLimits(),
+		ModelAccess: req.modelAccessPayload.toModelAccess(),
 	})
 	if h.handleAccountsError(c, action, err, map[string]any{"target_user": userID}) {
 		return
 	}
 	metrics.ObserveAdminAction(action, true)
-	h.logInfo("api key created", map[string]any{
+	h.logInfo(c, "api key created", map[string]any{
 		"user":        currentAdminUser(c),
 		"target_user": userID,
 		"api_key_id":  key.ID,
 	})
+	h.recordAudit(c, action, auditResourceAPIKey, key.ID, nil, toAPIKeyResponse(key))
 	c.JSON(http.StatusCreated, gin.H{
 		"api_key": toAPIKeyResponse(key),
 		"secret":  secret,
@@ -366,10 +519,11 @@ func (h *Handler) listUserAPIKeys(c *gin.Context) {
 	action := "accounts.api_keys.list"
 	userID := c.Param("id")
 	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user id is required"})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "user id is required")
 		return
 	}
-	keys, err := h.service.ListUserAPIKeys(c.Request.Context(), userID)
+	opts := parseAccountsListQuery(c)
+	keys, total, err := h.service.ListUserAPIKeys(c.Request.Context(), userID, opts)
 	if h.handleAccountsError(c, action, err, map[string]any{"target_user": userID}) {
 		return
 	}
@@ -378,25 +532,30 @@ func (h *Handler) listUserAPIKeys(c *gin.Context) {
 		resp = append(resp, toAPIKeyResponse(key))
 	}
 	metrics.ObserveAdminAction(action, true)
-	c.JSON(http.StatusOK, gin.H{"items": resp})
+	c.JSON(http.StatusOK, listAccountsResponse(resp, total, opts))
 }
 
 func (h *Handler) deleteUserAPIKey(c *gin.Context) {
 	action := "accounts.api_keys.delete"
 	apiKeyID := c.Param("id")
 	if apiKeyID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "api key id is required"})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "api key id is required")
 		return
 	}
+	before := auditSnapshot(h, func() (apiKeyResponse, error) {
+		key, err := h.service.GetUserAPIKey(c.Request.Context(), apiKeyID)
+		return toAPIKeyResponse(key), err
+	})
 	err := h.service.RevokeUserAPIKey(c.Request.Context(), apiKeyID)
 	if h.handleAccountsError(c, action, err, map[string]any{"api_key_id": apiKeyID}) {
 		return
 	}
 	metrics.ObserveAdminAction(action, true)
-	h.logInfo("api key revoked", map[string]any{
+	h.logInfo(c, "api key revoked", map[string]any{
 		"user":       currentAdminUser(c),
 		"api_key_id": apiKeyID,
 	})
+	h.recordAudit(c, action, auditResourceAPIKey, apiKeyID, before, nil)
 	c.Status(http.StatusNoContent)
 }
 
@@ -404,13 +563,13 @@ func (h *Handler) createUpstreamCredential(c *gin.Context) {
 	action := "accounts.upstreams.create"
 	userID := c.Param("id")
 	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user id is required"})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "user id is required")
 		return
 	}
 	var req createUpstreamCredentialRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		metrics.ObserveAdminAction(action, false)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
 		return
 	}
 	cred, err := h.service.CreateUpstreamCredential(c.Request.Context(), accounts.CreateUpstreamCredentialParams{
@@ -420,6 +579,7 @@ func (h *Handler) createUpstreamCredential(c *gin.Context) {
 		Label:     req.Label,
 		Name:      req.Name,
 		Plaintext: req.Plaintext,
+		SecretRef: req.SecretRef,
 		Endpoints: req.Endpoints,
 		Metadata:  req.Metadata,
 	})
@@ -428,7 +588,7 @@ func (h *Handler) createUpstreamCredential(c *gin.Context) {
 	}
 	endpoints := decodeEndpoints(cred.Endpoints)
 	metrics.ObserveAdminAction(action, true)
-	h.logInfo("upstream credential created", map[string]any{
+	h.logInfo(c, "upstream credential created", map[string]any{
 		"user":       currentAdminUser(c),
 		"credential": cred.ID,
 		"service":    cred.Service,
@@ -437,6 +597,7 @@ func (h *Handler) createUpstreamCredential(c *gin.Context) {
 	if trimmed := strings.TrimSpace(req.Service); trimmed != "" {
 		resp.Service = trimmed
 	}
+	h.recordAudit(c, action, auditResourceUpstream, cred.ID, nil, resp)
 	c.JSON(http.StatusCreated, resp)
 }
 
@@ -444,13 +605,13 @@ func (h *Handler) updateUpstreamCredential(c *gin.Context) {
 	action := "accounts.upstreams.update"
 	credentialID := c.Param("id")
 	if credentialID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "credential id is required"})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "credential id is required")
 		return
 	}
 	var req updateUpstreamCredentialRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		metrics.ObserveAdminAction(action, false)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
 		return
 	}
 	params := accounts.UpdateUpstreamCredentialParams{
@@ -475,6 +636,10 @@ func (h *Handler) updateUpstreamCredential(c *gin.Context) {
 		trimmed := strings.TrimSpace(*req.Plaintext)
 		params.Plaintext = &trimmed
 	}
+	if req.SecretRef != nil {
+		trimmed := strings.TrimSpace(*req.SecretRef)
+		params.SecretRef = &trimmed
+	}
 	if req.Endpoints != nil {
 		cleaned := make([]string, 0, len(*req.Endpoints))
 		for _, endpoint := range *req.Endpoints {
@@ -491,12 +656,15 @@ func (h *Handler) updateUpstreamCredential(c *gin.Context) {
 	if req.Enabled != nil {
 		params.Enabled = req.Enabled
 	}
+	before := auditSnapshot(h, func() (upstreamCredentialResponse, error) {
+		return h.upstreamCredentialSnapshot(c, credentialID)
+	})
 	cred, err := h.service.UpdateUpstreamCredential(c.Request.Context(), params)
 	if h.handleAccountsError(c, action, err, map[string]any{"credential": credentialID}) {
 		return
 	}
 	metrics.ObserveAdminAction(action, true)
-	h.logInfo("upstream credential updated", map[string]any{
+	h.logInfo(c, "upstream credential updated", map[string]any{
 		"user":       currentAdminUser(c),
 		"credential": credentialID,
 		"service":    cred.Service,
@@ -507,6 +675,7 @@ func (h *Handler) updateUpstreamCredential(c *gin.Context) {
 			resp.Service = trimmed
 		}
 	}
+	h.recordAudit(c, action, auditResourceUpstream, credentialID, before, resp)
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -514,10 +683,11 @@ func (h *Handler) listUpstreamCredentials(c *gin.Context) {
 	action := "accounts.upstreams.list"
 	userID := c.Param("id")
 	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user id is required"})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "user id is required")
 		return
 	}
-	creds, err := h.service.ListUpstreamCredentials(c.Request.Context(), userID)
+	opts := parseAccountsListQuery(c)
+	creds, total, err := h.service.ListUpstreamCredentials(c.Request.Context(), userID, opts)
 	if h.handleAccountsError(c, action, err, map[string]any{"target_user": userID}) {
 		return
 	}
@@ -526,25 +696,29 @@ func (h *Handler) listUpstreamCredentials(c *gin.Context) {
 		resp = append(resp, toUpstreamCredentialResponse(cred, decodeEndpoints(cred.Endpoints)))
 	}
 	metrics.ObserveAdminAction(action, true)
-	c.JSON(http.StatusOK, gin.H{"items": resp})
+	c.JSON(http.StatusOK, listAccountsResponse(resp, total, opts))
 }
 
 func (h *Handler) deleteUpstreamCredential(c *gin.Context) {
 	action := "accounts.upstreams.delete"
 	credentialID := c.Param("id")
 	if credentialID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "credential id is required"})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "credential id is required")
 		return
 	}
+	before := auditSnapshot(h, func() (upstreamCredentialResponse, error) {
+		return h.upstreamCredentialSnapshot(c, credentialID)
+	})
 	err := h.service.DeleteUpstreamCredential(c.Request.Context(), credentialID)
 	if h.handleAccountsError(c, action, err, map[string]any{"credential": credentialID}) {
 		return
 	}
 	metrics.ObserveAdminAction(action, true)
-	h.logInfo("upstream credential deleted", map[string]any{
+	h.logInfo(c, "upstream credential deleted", map[string]any{
 		"user":       currentAdminUser(c),
 		"credential": credentialID,
 	})
+	h.recordAudit(c, action, auditResourceUpstream, credentialID, before, nil)
 	c.Status(http.StatusNoContent)
 }
 
@@ -552,34 +726,47 @@ func (h *Handler) bindAPIKey(c *gin.Context) {
 	action := "accounts.api_keys.bind"
 	apiKeyID := c.Param("id")
 	if apiKeyID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "api key id is required"})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "api key id is required")
 		return
 	}
 	var req bindAPIKeyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		metrics.ObserveAdminAction(action, false)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
 		return
 	}
 	binding, err := h.service.BindAPIKey(c.Request.Context(), accounts.BindAPIKeyParams{
 		UserID:               req.UserID,
 		UserAPIKeyID:         apiKeyID,
 		UpstreamCredentialID: req.UpstreamCredentialID,
+		Service:              req.Service,
+		Position:             req.Position,
+		Metadata:             req.Metadata,
 	})
 	if h.handleAccountsError(c, action, err, map[string]any{"api_key_id": apiKeyID}) {
 		return
 	}
-	_, cred, err := h.service.GetBindingByAPIKeyID(c.Request.Context(), apiKeyID)
+	bindings, err := h.service.ListBindingsByAPIKey(c.Request.Context(), apiKeyID)
 	if h.handleAccountsError(c, action, err, map[string]any{"api_key_id": apiKeyID}) {
 		return
 	}
+	var cred accounts.UpstreamCredential
+	for _, item := range bindings {
+		if item.Binding.ID == binding.ID {
+			cred = item.Upstream
+			break
+		}
+	}
 	resp := toBindingResponse(binding, toUpstreamCredentialResponse(cred, decodeEndpoints(cred.Endpoints)))
 	metrics.ObserveAdminAction(action, true)
-	h.logInfo("api key bound", map[string]any{
+	h.logInfo(c, "api key bound", map[string]any{
 		"user":       currentAdminUser(c),
 		"api_key_id": apiKeyID,
 		"credential": cred.ID,
+		"service":    binding.Service,
+		"position":   binding.Position,
 	})
+	h.recordAudit(c, action, auditResourceAPIBinding, binding.ID, nil, resp)
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -587,7 +774,7 @@ func (h *Handler) getAPIKeyBinding(c *gin.Context) {
 	action := "accounts.api_keys.binding.get"
 	apiKeyID := c.Param("id")
 	if apiKeyID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "api key id is required"})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "api key id is required")
 		return
 	}
 	binding, cred, err := h.service.GetBindingByAPIKeyID(c.Request.Context(), apiKeyID)
@@ -601,15 +788,21 @@ func (h *Handler) getAPIKeyBinding(c *gin.Context) {
 
 func (h *Handler) listRules(c *gin.Context) {
 	start := time.Now()
-	query := parseListRulesQuery(c)
+	query, err := parseListRulesQuery(c)
+	if err != nil {
+		metrics.ObserveAdminAction("rules.list", false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
 	result, err := h.service.ListRules(c.Request.Context())
 	if err != nil {
-		h.logError("list rules failed", err, nil)
+		h.logError(c, "list rules failed", err, nil)
 		metrics.ObserveAdminAction("rules.list", false)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, err.Error())
 		return
 	}
 	filtered := filterRules(result, query)
+	sortRules(filtered, query.Sort, query.Order)
 	total := len(filtered)
 	enabledTotal := countEnabled(filtered)
 	page := query.Page
@@ -624,7 +817,11 @@ func (h *Handler) listRules(c *gin.Context) {
 			page = maxPage
 		}
 	}
-	items := paginateRules(filtered, page, query.PageSize)
+	paged := paginateRules(filtered, page, query.PageSize)
+	items := make([]ruleListItem, len(paged))
+	for i, rule := range paged {
+		items[i] = ruleListItem{Rule: rule, ETag: ruleETag(rule)}
+	}
 	resp := listRulesResponse{
 		Items:        items,
 		Total:        total,
@@ -632,12 +829,14 @@ func (h *Handler) listRules(c *gin.Context) {
 		Page:         page,
 		PageSize:     query.PageSize,
 	}
-	h.logInfo("list rules success", map[string]any{
+	h.logInfo(c, "list rules success", map[string]any{
 		"user":          currentAdminUser(c),
 		"page":          page,
 		"page_size":     query.PageSize,
 		"search":        query.Search,
 		"enabled":       query.Enabled,
+		"sort":          query.Sort,
+		"order":         query.Order,
 		"count":         len(items),
 		"enabled_total": enabledTotal,
 		"total":         total,
@@ -655,42 +854,70 @@ func (h *Handler) createOrUpdateRule(c *gin.Context) {
 	var rule rules.Rule
 	if err := c.ShouldBindJSON(&rule); err != nil {
 		metrics.ObserveAdminAction(action, false)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
 		return
 	}
 	if id := c.Param("id"); id != "" && rule.ID == "" {
 		rule.ID = id
 	}
-	if err := h.service.CreateOrUpdateRule(c.Request.Context(), rule); err != nil {
+	var pre rulePrecondition
+	if c.Request.Method == http.MethodPut {
+		var ok bool
+		if pre, ok = h.checkRuleIfMatch(c, action, rule.ID, true); !ok {
+			return
+		}
+	}
+	rule.UpdatedBy = currentAdminUser(c)
+	before := auditSnapshot(h, func() (rules.Rule, error) {
+		return h.service.GetRule(c.Request.Context(), rule.ID)
+	})
+	if err := h.saveRule(c.Request.Context(), rule, pre); err != nil {
 		status := http.StatusInternalServerError
 		if errors.Is(err, rules.ErrInvalidRule) {
 			status = http.StatusBadRequest
 		}
-		h.logError("save rule failed", err, map[string]any{
+		if errors.Is(err, rules.ErrVersionConflict) {
+			status = http.StatusPreconditionFailed
+		}
+		h.logError(c, "save rule failed", err, map[string]any{
 			"user":   currentAdminUser(c),
 			"rule":   rule.ID,
 			"action": action,
 		})
 		metrics.ObserveAdminAction(action, false)
-		c.JSON(status, gin.H{"error": err.Error()})
+		errcode.Respond(c, status, errcode.ForStatus(status), err.Error())
 		return
 	}
-	h.logInfo("rule saved", map[string]any{
+	h.logInfo(c, "rule saved", map[string]any{
 		"user":   currentAdminUser(c),
 		"rule":   rule.ID,
 		"action": action,
 	})
+	after := auditSnapshot(h, func() (rules.Rule, error) {
+		return h.service.GetRule(c.Request.Context(), rule.ID)
+	})
+	h.recordAudit(c, action, auditResourceRule, rule.ID, before, after)
 	metrics.ObserveAdminAction(action, true)
+	if saved, err := h.service.GetRule(c.Request.Context(), rule.ID); err == nil {
+		c.Header("ETag", ruleETag(saved))
+	}
 	c.JSON(http.StatusOK, rule)
 }
 
 func (h *Handler) deleteRule(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "id is required")
+		return
+	}
+	pre, ok := h.checkRuleIfMatch(c, "rules.delete", id, false)
+	if !ok {
 		return
 	}
-	err := h.service.DeleteRule(c.Request.Context(), id)
+	before := auditSnapshot(h, func() (rules.Rule, error) {
+		return h.service.GetRule(c.Request.Context(), id)
+	})
+	err := h.removeRule(c.Request.Context(), id, pre)
 	if err != nil {
 		status := http.StatusInternalServerError
 		if errors.Is(err, rules.ErrInvalidRule) {
@@ -699,18 +926,22 @@ func (h *Handler) deleteRule(c *gin.Context) {
 		if errors.Is(err, rules.ErrRuleNotFound) {
 			status = http.StatusNotFound
 		}
-		h.logError("delete rule failed", err, map[string]any{
+		if errors.Is(err, rules.ErrVersionConflict) {
+			status = http.StatusPreconditionFailed
+		}
+		h.logError(c, "delete rule failed", err, map[string]any{
 			"user": currentAdminUser(c),
 			"rule": id,
 		})
 		metrics.ObserveAdminAction("rules.delete", false)
-		c.JSON(status, gin.H{"error": err.Error()})
+		errcode.Respond(c, status, errcode.ForStatus(status), err.Error())
 		return
 	}
-	h.logInfo("rule deleted", map[string]any{
+	h.logInfo(c, "rule deleted", map[string]any{
 		"user": currentAdminUser(c),
 		"rule": id,
 	})
+	h.recordAudit(c, "rules.delete", auditResourceRule, id, before, nil)
 	metrics.ObserveAdminAction("rules.delete", true)
 	c.Status(http.StatusNoContent)
 }
@@ -722,34 +953,66 @@ func (h *Handler) healthz(c *gin.Context) {
 type loginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	// Permissions 可选，仅为令牌申请角色权限的子集。
+	Permissions []string `json:"permissions"`
+	// Cookie 为 true 时令牌写入 HttpOnly Cookie，响应只返回 CSRF 令牌，供内嵌管理界面使用。
+	Cookie bool `json:"cookie"`
 }
 
 func (h *Handler) login(c *gin.Context) {
-	if h.auth == nil || !h.auth.CredentialsConfigured() {
-		c.JSON(http.StatusNotImplemented, gin.H{"error": "login disabled"})
+	if h.auth == nil || !h.auth.LoginEnabled() {
+		errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "login disabled")
 		return
 	}
 	action := "auth.login"
 	var req loginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		metrics.ObserveAdminAction(action, false)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
 		return
 	}
-	token, err := h.auth.IssueToken(req.Username, req.Password)
+	scope, err := ParsePermissions(req.Permissions)
+	if err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	ctx := withClientIP(c.Request.Context(), h.auth.clientIP(c))
+	issue := h.auth.IssueTokenPair
+	if req.Cookie {
+		issue = h.auth.IssueSessionTokenPair
+	}
+	pair, err := issue(ctx, req.Username, req.Password, scope...)
 	if err != nil {
 		status := http.StatusUnauthorized
-		if errors.Is(err, ErrTokenNotConfigured) {
-			status = http.StatusNotImplemented
+		code := errcode.Unauthorized
+		var locked *LoginLockedError
+		switch {
+		case errors.As(err, &locked):
+			status, code = http.StatusTooManyRequests, errcode.LoginLocked
+			c.Header("Retry-After", strconv.Itoa(int(locked.RetryAfter.Round(time.Second).Seconds())))
+			h.recordLoginFailure(c, req.Username, "locked")
+		case errors.Is(err, ErrInvalidCredential):
+			h.recordLoginFailure(c, req.Username, "invalid_credential")
+		case errors.Is(err, ErrInvalidPermissions):
+			status, code = http.StatusForbidden, errcode.PermissionDenied
+		case errors.Is(err, ErrTokenNotConfigured):
+			status, code = http.StatusNotImplemented, errcode.NotImplemented
+		case errors.Is(err, ErrUserStoreUnavailable):
+			status, code = http.StatusServiceUnavailable, errcode.ServiceUnavailable
 		}
-		h.logError("login failed", err, map[string]any{"username": req.Username})
+		h.logError(c, "login failed", err, map[string]any{"username": req.Username})
 		metrics.ObserveAdminAction(action, false)
-		c.JSON(status, gin.H{"error": err.Error()})
+		errcode.Respond(c, status, code, err.Error())
 		return
 	}
-	h.logInfo("login success", map[string]any{"username": req.Username})
+	h.logInfo(c, "login success", map[string]any{"username": req.Username, "cookie": req.Cookie})
 	metrics.ObserveAdminAction(action, true)
-	c.JSON(http.StatusOK, gin.H{"access_token": token, "token_type": "Bearer", "expires_in": int(h.auth.ttl.Seconds())})
+	if req.Cookie {
+		h.writeSession(c, pair)
+		return
+	}
+	c.JSON(http.StatusOK, toTokenResponse(pair))
 }
 
 type listRulesQuery struct {
@@ -757,17 +1020,37 @@ type listRulesQuery struct {
 	PageSize int
 	Search   string
 	Enabled  *bool
+	// Sort 为排序字段，空值保持服务返回的顺序（按优先级降序）。
+	Sort  string
+	Order string
 }
 
+// 规则列表支持的排序字段与方向。
+const (
+	ruleSortPriority  = "priority"
+	ruleSortUpdatedAt = "updated_at"
+	ruleSortID        = "id"
+	sortOrderAsc      = "asc"
+	sortOrderDesc     = "desc"
+)
+
 type listRulesResponse struct {
-	Items        []rules.Rule `json:"items"`
-	Total        int          `json:"total"`
-	EnabledTotal int          `json:"enabled_total"`
-	Page         int          `json:"page"`
-	PageSize     int          `json:"page_size"`
+	Items        []ruleListItem `json:"items"`
+	Total        int            `json:"total"`
+	EnabledTotal int            `json:"enabled_total"`
+	Page         int            `json:"page"`
+	PageSize     int            `json:"page_size"`
 }
 
-func parseListRulesQuery(c *gin.Context) listRulesQuery {
+// ruleListItem 为列表中的规则附带 ETag，管理界面可直接用于修改或删除时的 If-Match。
+type ruleListItem struct {
+	rules.Rule
+	ETag string `json:"etag"`
+}
+
+// parseListRulesQuery 解析规则列表参数；sort 或 order 取值不受支持时返回错误。
+// 未指定 order 时 priority 与 updated_at 默认降序，id 默认升序。
+func parseListRulesQuery(c *gin.Context) (listRulesQuery, error) {
 	page := parsePositiveInt(c.Query("page"), 1)
 	pageSize := parsePositiveInt(c.Query("page_size"), 20)
 	if pageSize > 100 {
@@ -780,11 +1063,59 @@ func parseListRulesQuery(c *gin.Context) listRulesQuery {
 			enabled = &parsed
 		}
 	}
+	sortBy := strings.ToLower(strings.TrimSpace(c.Query("sort")))
+	order := strings.ToLower(strings.TrimSpace(c.Query("order")))
+	switch sortBy {
+	case "", ruleSortPriority, ruleSortUpdatedAt:
+		if order == "" {
+			order = sortOrderDesc
+		}
+	case ruleSortID:
+		if order == "" {
+			order = sortOrderAsc
+		}
+	default:
+		return listRulesQuery{}, fmt.Errorf("invalid sort %q: want priority, updated_at or id", sortBy)
+	}
+	if order != sortOrderAsc && order != sortOrderDesc {
+		return listRulesQuery{}, fmt.Errorf("invalid order %q: want asc or desc", order)
+	}
+	if sortBy == "" {
+		order = ""
+	}
 	return listRulesQuery{
 		Page:     page,
 		PageSize: pageSize,
 		Search:   search,
 		Enabled:  enabled,
+		Sort:     sortBy,
+		Order:    order,
+	}, nil
+}
+
+// parseAccountsListQuery 解析账户类列表的 limit/offset/q 参数。
+func parseAccountsListQuery(c *gin.Context) accounts.ListOptions {
+	limit := parsePositiveInt(c.Query("limit"), defaultAccountsPageLimit)
+	if limit > maxAccountsPageLimit {
+		limit = maxAccountsPageLimit
+	}
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	search := strings.TrimSpace(c.Query("q"))
+	if search == "" {
+		search = strings.TrimSpace(c.Query("search"))
+	}
+	return accounts.ListOptions{Limit: limit, Offset: offset, Search: search}
+}
+
+func listAccountsResponse[T any](items []T, total int64, opts accounts.ListOptions) gin.H {
+	return gin.H{
+		"items":  items,
+		"total":  total,
+		"limit":  opts.Limit,
+		"offset": opts.Offset,
 	}
 }
 
@@ -830,6 +1161,32 @@ func matchesKeyword(rule rules.Rule, keyword string) bool {
 	return false
 }
 
+// sortRules 按字段原地排序，取值相同时按 ID 升序，保证分页结果稳定。
+func sortRules(items []rules.Rule, sortBy, order string) {
+	if sortBy == "" {
+		return
+	}
+	slices.SortStableFunc(items, func(a, b rules.Rule) int {
+		var result int
+		switch sortBy {
+		case ruleSortPriority:
+			result = cmp.Compare(a.Priority, b.Priority)
+		case ruleSortUpdatedAt:
+			result = a.UpdatedAt.Compare(b.UpdatedAt)
+		}
+		if order == sortOrderDesc {
+			result = -result
+		}
+		if result == 0 {
+			result = strings.Compare(a.ID, b.ID)
+			if sortBy == ruleSortID && order == sortOrderDesc {
+				result = -result
+			}
+		}
+		return result
+	})
+}
+
 func paginateRules(items []rules.Rule, page, pageSize int) []rules.Rule {
 	if pageSize <= 0 || len(items) == 0 {
 		return []rules.Rule{}
@@ -864,22 +1221,23 @@ func currentAdminUser(c *gin.Context) string {
 	return ""
 }
 
-func (h *Handler) logError(msg string, err error, attrs map[string]any) {
+func (h *Handler) logError(c *gin.Context, msg string, err error, attrs map[string]any) {
 	if h.logger == nil {
 		return
 	}
-	args := []any{"error", err}
+	args := []any{"request_id", middleware.RequestIDFromContext(c), "error", err}
 	for k, v := range attrs {
 		args = append(args, k, v)
 	}
 	h.logger.Error(msg, args...)
 }
 
-func (h *Handler) logInfo(msg string, attrs map[string]any) {
+func (h *Handler) logInfo(c *gin.Context, msg string, attrs map[string]any) {
 	if h.logger == nil {
 		return
 	}
-	args := make([]any, 0, len(attrs)*2)
+	args := make([]any, 0, len(attrs)*2+2)
+	args = append(args, "request_id", middleware.RequestIDFromContext(c))
 	for k, v := range attrs {
 		args = append(args, k, v)
 	}
@@ -0,0 +1,209 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/adminusers"
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// WithAdminUsers 设置管理员账号服务，未设置时账号管理接口返回 501。
+func WithAdminUsers(users adminusers.Service) Option {
+	return func(h *Handler) {
+		h.adminUsers = users
+	}
+}
+
+// adminUserResponse 是管理员账号的对外表示，不包含密码哈希。
+type adminUserResponse struct {
+	ID        string          `json:"id"`
+	Username  string          `json:"username"`
+	Role      adminusers.Role `json:"role"`
+	Disabled  bool            `json:"disabled"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+func toAdminUserResponse(user adminusers.User) adminUserResponse {
+	return adminUserResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		Disabled:  user.Disabled,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+type createAdminUserRequest struct {
+	Username string          `json:"username" binding:"required"`
+	Password string          `json:"password" binding:"required"`
+	Role     adminusers.Role `json:"role" binding:"required"`
+}
+
+type patchAdminUserRequest struct {
+	Password *string          `json:"password"`
+	Role     *adminusers.Role `json:"role"`
+	Disabled *bool            `json:"disabled"`
+}
+
+func (h *Handler) listAdminUsers(c *gin.Context) {
+	action := "admin_users.list"
+	if !h.adminUsersAvailable(c, action) {
+		return
+	}
+	users, err := h.adminUsers.List(c.Request.Context())
+	if err != nil {
+		h.handleAdminUsersError(c, action, "list admin users failed", err)
+		return
+	}
+	items := make([]adminUserResponse, 0, len(users))
+	for _, user := range users {
+		items = append(items, toAdminUserResponse(user))
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *Handler) createAdminUser(c *gin.Context) {
+	action := "admin_users.create"
+	if !h.adminUsersAvailable(c, action) {
+		return
+	}
+	var req createAdminUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	if !h.authorizeAdminRole(c, action, req.Role) {
+		return
+	}
+	user, err := h.adminUsers.Create(c.Request.Context(), adminusers.CreateParams{
+		Username: req.Username,
+		Password: req.Password,
+		Role:     req.Role,
+	})
+	if err != nil {
+		h.handleAdminUsersError(c, action, "create admin user failed", err)
+		return
+	}
+	resp := toAdminUserResponse(user)
+	h.logInfo(c, "admin user created", map[string]any{"user": currentAdminUser(c), "admin_user": user.Username, "role": user.Role})
+	h.recordAudit(c, action, auditResourceAdminUser, user.ID, nil, resp)
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *Handler) patchAdminUser(c *gin.Context) {
+	action := "admin_users.update"
+	if !h.adminUsersAvailable(c, action) {
+		return
+	}
+	var req patchAdminUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	if req.Password == nil && req.Role == nil && req.Disabled == nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "no fields to update")
+		return
+	}
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	before, err := h.adminUsers.Get(ctx, id)
+	if err != nil {
+		h.handleAdminUsersError(c, action, "get admin user failed", err)
+		return
+	}
+	// 修改账号相当于获得其登录能力：原角色与新角色都不得超出调用者自身的权限。
+	if !h.authorizeAdminRole(c, action, before.Role) {
+		return
+	}
+	if req.Role != nil && !h.authorizeAdminRole(c, action, *req.Role) {
+		return
+	}
+	user, err := h.adminUsers.Update(ctx, adminusers.UpdateParams{
+		ID:       id,
+		Password: req.Password,
+		Role:     req.Role,
+		Disabled: req.Disabled,
+	})
+	if err != nil {
+		h.handleAdminUsersError(c, action, "update admin user failed", err)
+		return
+	}
+	resp := toAdminUserResponse(user)
+	h.logInfo(c, "admin user updated", map[string]any{"user": currentAdminUser(c), "admin_user": user.Username, "password_changed": req.Password != nil})
+	h.recordAudit(c, action, auditResourceAdminUser, user.ID, toAdminUserResponse(before), resp)
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) deleteAdminUser(c *gin.Context) {
+	action := "admin_users.delete"
+	if !h.adminUsersAvailable(c, action) {
+		return
+	}
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	before, err := h.adminUsers.Get(ctx, id)
+	if err != nil {
+		h.handleAdminUsersError(c, action, "get admin user failed", err)
+		return
+	}
+	if err := h.adminUsers.Delete(ctx, id); err != nil {
+		h.handleAdminUsersError(c, action, "delete admin user failed", err)
+		return
+	}
+	h.logInfo(c, "admin user deleted", map[string]any{"user": currentAdminUser(c), "admin_user": before.Username})
+	h.recordAudit(c, action, auditResourceAdminUser, id, toAdminUserResponse(before), nil)
+	metrics.ObserveAdminAction(action, true)
+	c.Status(http.StatusNoContent)
+}
+
+// authorizeAdminRole 拒绝创建或修改权限超出调用者自身有效权限的账号，例如只有 admin_users:write 的服务令牌
+// 不能创建 owner 或重置 owner 的密码后以 owner 身份登录。
+func (h *Handler) authorizeAdminRole(c *gin.Context, action string, role adminusers.Role) bool {
+	perm, missing := ungrantedPermission(c, PermissionsForRole(role))
+	if !missing {
+		return true
+	}
+	metrics.ObserveAdminAction(action, false)
+	errcode.Respond(c, http.StatusForbidden, errcode.PermissionDenied, fmt.Sprintf("forbidden: role %s requires permission %s", role, perm))
+	return false
+}
+
+func (h *Handler) adminUsersAvailable(c *gin.Context, action string) bool {
+	if h.adminUsers != nil {
+		return true
+	}
+	metrics.ObserveAdminAction(action, false)
+	errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "admin user store unavailable")
+	return false
+}
+
+// handleAdminUsersError 将账号服务错误映射为 HTTP 状态码。
+func (h *Handler) handleAdminUsersError(c *gin.Context, action, msg string, err error) {
+	metrics.ObserveAdminAction(action, false)
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, adminusers.ErrInvalidInput):
+		status = http.StatusBadRequest
+	case errors.Is(err, adminusers.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, adminusers.ErrConflict):
+		status = http.StatusConflict
+	default:
+		h.logError(c, msg, err, map[string]any{"user": currentAdminUser(c), "admin_user_id": c.Param("id")})
+	}
+	errcode.Respond(c, status, errcode.ForStatus(status), err.Error())
+}
@@ -0,0 +1,170 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/prehisle/yapi/internal/adminusers"
+	"github.com/prehisle/yapi/internal/servicetokens"
+)
+
+func setupAdminUsersRouter(t *testing.T, dsn, envUser, envPassword string) (*gin.Engine, adminusers.Service) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	users := adminusers.NewService(db)
+	require.NoError(t, users.AutoMigrate(context.Background()))
+	auth := NewAuthenticator(envUser, envPassword, "sign-key", time.Minute, WithUserStore(users))
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth, WithAdminUsers(users)), auth.Middleware())
+	return router, users
+}
+
+func doAdminRequest(router *gin.Engine, method, path, body string, setAuth func(*http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if setAuth != nil {
+		setAuth(req)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func basicAuth(username, password string) func(*http.Request) {
+	return func(req *http.Request) { req.SetBasicAuth(username, password) }
+}
+
+func TestHandler_AdminUsers_RoleEnforcement(t *testing.T) {
+	router, users := setupAdminUsersRouter(t, "file:admin_users_roles?mode=memory&cache=shared", "root", "root-secret")
+	ctx := context.Background()
+	_, err := users.Create(ctx, adminusers.CreateParams{Username: "viewer", Password: "viewer-pass", Role: adminusers.RoleViewer})
+	require.NoError(t, err)
+
+	rec := doAdminRequest(router, http.MethodPost, "/admin/admin-users", `{"username":"editor","password":"editor-pass","role":"editor"}`, basicAuth("root", "root-secret"))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.NotContains(t, rec.Body.String(), "password")
+	var created adminUserResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.Equal(t, adminusers.RoleEditor, created.Role)
+
+	rec = doAdminRequest(router, http.MethodGet, "/admin/rules", "", basicAuth("viewer", "viewer-pass"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = doAdminRequest(router, http.MethodDelete, "/admin/rules/r1", "", basicAuth("viewer", "viewer-pass"))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	var login tokenResponse
+	rec = doAdminRequest(router, http.MethodPost, "/admin/login", `{"username":"editor","password":"editor-pass"}`, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	bearer := func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+login.AccessToken) }
+	rec = doAdminRequest(router, http.MethodDelete, "/admin/rules/r1", "", bearer)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = doAdminRequest(router, http.MethodGet, "/admin/admin-users", "", bearer)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	// 角色与停用状态在每次请求时重新读取，已签发的令牌随之生效或失效。
+	rec = doAdminRequest(router, http.MethodPatch, "/admin/admin-users/"+created.ID, `{"disabled":true}`, basicAuth("root", "root-secret"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = doAdminRequest(router, http.MethodGet, "/admin/rules", "", bearer)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = doAdminRequest(router, http.MethodPost, "/admin/token/refresh", `{"refresh_token":"`+login.RefreshToken+`"}`, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = doAdminRequest(router, http.MethodGet, "/admin/admin-users", "", basicAuth("root", "root-secret"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list struct {
+		Items []adminUserResponse `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Items, 2)
+
+	rec = doAdminRequest(router, http.MethodDelete, "/admin/admin-users/"+created.ID, "", basicAuth("root", "root-secret"))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = doAdminRequest(router, http.MethodDelete, "/admin/admin-users/"+created.ID, "", basicAuth("root", "root-secret"))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_AdminUsers_ScopedTokenCannotEscalate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file:admin_users_scoped?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	ctx := context.Background()
+	users := adminusers.NewService(db)
+	require.NoError(t, users.AutoMigrate(ctx))
+	tokens := servicetokens.NewService(db)
+	require.NoError(t, tokens.AutoMigrate(ctx))
+	owner, err := users.Create(ctx, adminusers.CreateParams{Username: "owner", Password: "owner-pass", Role: adminusers.RoleOwner})
+	require.NoError(t, err)
+	auth := NewAuthenticator("root", "root-secret", "", time.Minute, WithUserStore(users), WithServiceTokenStore(tokens))
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth, WithAdminUsers(users), WithServiceTokens(tokens)), auth.Middleware())
+
+	rec := doAdminRequest(router, http.MethodPost, "/admin/service-tokens",
+		`{"name":"onboarding","permissions":["admin_users:write","rules:read","accounts:read","audit:read","events:read","requests:read"]}`, basicAuth("root", "root-secret"))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var token createServiceTokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &token))
+	bearer := func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token.Token) }
+
+	// 令牌只能创建权限不超出自身范围的账号，不能创建 owner 或改写 owner 的密码与角色。
+	rec = doAdminRequest(router, http.MethodPost, "/admin/admin-users", `{"username":"evil","password":"evil-pass","role":"owner"}`, bearer)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doAdminRequest(router, http.MethodPost, "/admin/admin-users", `{"username":"evil","password":"evil-pass","role":"editor"}`, bearer)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doAdminRequest(router, http.MethodPatch, "/admin/admin-users/"+owner.ID, `{"password":"taken-over"}`, bearer)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doAdminRequest(router, http.MethodGet, "/admin/rules", "", basicAuth("owner", "owner-pass"))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doAdminRequest(router, http.MethodPost, "/admin/admin-users", `{"username":"viewer","password":"viewer-pass","role":"viewer"}`, bearer)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var viewer adminUserResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &viewer))
+	rec = doAdminRequest(router, http.MethodPatch, "/admin/admin-users/"+viewer.ID, `{"role":"owner"}`, bearer)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doAdminRequest(router, http.MethodPatch, "/admin/admin-users/"+viewer.ID, `{"disabled":true}`, bearer)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestHandler_AdminUsers_BootstrapWithoutEnvCredentials(t *testing.T) {
+	router, _ := setupAdminUsersRouter(t, "file:admin_users_bootstrap?mode=memory&cache=shared", "", "")
+
+	rec := doAdminRequest(router, http.MethodPost, "/admin/admin-users", `{"username":"owner","password":"owner-pass","role":"owner"}`, nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = doAdminRequest(router, http.MethodGet, "/admin/rules", "", nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = doAdminRequest(router, http.MethodGet, "/admin/rules", "", basicAuth("owner", "owner-pass"))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var created adminUserResponse
+	rec = doAdminRequest(router, http.MethodGet, "/admin/admin-users", "", basicAuth("owner", "owner-pass"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list struct {
+		Items []adminUserResponse `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	created = list.Items[0]
+	rec = doAdminRequest(router, http.MethodPatch, "/admin/admin-users/"+created.ID, `{"role":"viewer"}`, basicAuth("owner", "owner-pass"))
+	require.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestHandler_AdminUsers_Unavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterProtectedRoutes(router.Group("/admin"), NewHandler(&serviceStub{}, nil))
+	rec := doAdminRequest(router, http.MethodGet, "/admin/admin-users", "", nil)
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+}
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// patchUserAPIKeyRequest 描述 API Key 的局部更新，缺省字段保持不变。
+type patchUserAPIKeyRequest struct {
+	Enabled *bool `json:"enabled"`
+	patchRateLimitsRequest
+	patchModelAccessRequest
+}
+
+func (h *Handler) patchUserAPIKey(c *gin.Context) {
+	action := "accounts.api_keys.patch"
+	apiKeyID := c.Param("id")
+	if apiKeyID == "" {
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "api key id is required")
+		return
+	}
+	var req patchUserAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	if req.Enabled == nil && req.patchRateLimitsRequest.empty() && req.patchModelAccessRequest.empty() {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "no patchable field provided")
+		return
+	}
+	ctx := c.Request.Context()
+	before := auditSnapshot(h, func() (apiKeyResponse, error) {
+		key, err := h.service.GetUserAPIKey(ctx, apiKeyID)
+		return toAPIKeyResponse(key), err
+	})
+	if req.Enabled != nil {
+		err := h.service.SetUserAPIKeyEnabled(ctx, apiKeyID, *req.Enabled)
+		if h.handleAccountsError(c, action, err, map[string]any{"api_key_id": apiKeyID}) {
+			return
+		}
+	}
+	key, err := h.service.GetUserAPIKey(ctx, apiKeyID)
+	if h.handleAccountsError(c, action, err, map[string]any{"api_key_id": apiKeyID}) {
+		return
+	}
+	if !req.patchRateLimitsRequest.empty() {
+		key, err = h.service.SetAPIKeyRateLimits(ctx, apiKeyID, req.patchRateLimitsRequest.apply(key.RateLimits))
+		if h.handleAccountsError(c, action, err, map[string]any{"api_key_id": apiKeyID}) {
+			return
+		}
+	}
+	if !req.patchModelAccessRequest.empty() {
+		key, err = h.service.SetAPIKeyModelAccess(ctx, apiKeyID, req.patchModelAccessRequest.apply(key.ModelAccess))
+		if h.handleAccountsError(c, action, err, map[string]any{"api_key_id": apiKeyID}) {
+			return
+		}
+	}
+	metrics.ObserveAdminAction(action, true)
+	h.logInfo(c, "api key patched", map[string]any{
+		"user":                    currentAdminUser(c),
+		"api_key_id":              apiKeyID,
+		"enabled":                 key.Enabled,
+		"max_requests_per_minute": key.MaxRequestsPerMinute,
+		"max_concurrent_requests": key.MaxConcurrentRequests,
+	})
+	h.recordAudit(c, action, auditResourceAPIKey, apiKeyID, before, toAPIKeyResponse(key))
+	c.JSON(http.StatusOK, toAPIKeyResponse(key))
+}
@@ -0,0 +1,79 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// applyRequest 是 POST /apply 的请求体：期望状态文档，外加可选的预览指纹。
+type applyRequest struct {
+	DesiredState
+	// Fingerprint 为 dry_run 返回的指纹；提供时若当前计划已变化则拒绝执行。
+	Fingerprint string `json:"fingerprint"`
+}
+
+// apply 对比期望状态与当前状态；dry_run=true 时只返回计划，否则执行计划并失败回滚。
+func (h *Handler) apply(c *gin.Context) {
+	action := "apply"
+	var req applyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	ctx := c.Request.Context()
+	var (
+		plan ApplyPlan
+		err  error
+	)
+	if dryRun {
+		plan, err = h.service.PlanApply(ctx, req.DesiredState)
+	} else {
+		plan, err = h.service.Apply(ctx, req.DesiredState, ApplyOptions{Actor: currentAdminUser(c), Fingerprint: req.Fingerprint})
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidDesiredState):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrPlanChanged):
+			status = http.StatusConflict
+		case errors.Is(err, ErrAccountsUnavailable):
+			status = http.StatusNotImplemented
+		}
+		h.logError(c, "apply failed", err, map[string]any{
+			"user":    currentAdminUser(c),
+			"dry_run": dryRun,
+		})
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, status, errcode.ForStatus(status), err.Error())
+		return
+	}
+	if plan.Changes == nil {
+		plan.Changes = []ApplyChange{}
+	}
+	metrics.ObserveAdminAction(action, true)
+	msg := "apply planned"
+	if plan.Applied {
+		msg = "apply executed"
+	}
+	h.logInfo(c, msg, map[string]any{
+		"user":        currentAdminUser(c),
+		"dry_run":     dryRun,
+		"changes":     len(plan.Changes),
+		"fingerprint": plan.Fingerprint,
+	})
+	if plan.Applied {
+		for _, change := range plan.Changes {
+			h.recordAudit(c, action+"."+change.Action, change.ResourceType, change.ID, change.Before, change.After)
+		}
+	}
+	c.JSON(http.StatusOK, plan)
+}
@@ -0,0 +1,133 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/audit"
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// 审计记录中的资源类型。
+const (
+	auditResourceRule         = "rule"
+	auditResourceUser         = "user"
+	auditResourceAPIKey       = "api_key"
+	auditResourceUpstream     = "upstream_credential"
+	auditResourcePool         = "upstream_pool"
+	auditResourceAPIBinding   = "binding"
+	auditResourceAdminUser    = "admin_user"
+	auditResourceSvcToken     = "service_token"
+	auditResourceLogin        = "admin_login"
+	auditResourceBackup       = "backup"
+	auditResourceLogLevel     = "log_level"
+	auditResourceFeature      = "feature_flag"
+	auditResourceConfig       = "config"
+	auditResourceModelAlias   = "model_alias"
+	auditResourceConversation = "conversation"
+	auditResourceBudget       = "budget"
+	auditResourceRequest      = "request"
+)
+
+// WithAuditStore 设置审计日志存储，未设置时不记录审计日志。
+func WithAuditStore(store audit.Store) Option {
+	return func(h *Handler) {
+		h.audit = store
+	}
+}
+
+// auditSnapshot 在启用审计时读取资源的变更前快照；资源不存在或读取失败时返回 nil。
+func auditSnapshot[T any](h *Handler, load func() (T, error)) any {
+	if h.audit == nil {
+		return nil
+	}
+	value, err := load()
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+// recordAudit 记录一次成功的变更并通知事件订阅方。写入失败只记录日志，不影响已完成的管理操作。
+func (h *Handler) recordAudit(c *gin.Context, action, resourceType, resourceID string, before, after any) {
+	h.notifyChange(c, resourceType)
+	if h.audit == nil {
+		return
+	}
+	entry := audit.Entry{
+		Actor:        currentAdminUser(c),
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		RequestID:    middleware.RequestIDFromContext(c),
+		Before:       audit.Snapshot(before),
+		After:        audit.Snapshot(after),
+	}
+	if entry.Before != nil && entry.After != nil {
+		entry.Diff = audit.Diff(entry.Before, entry.After)
+	}
+	if err := h.audit.Record(c.Request.Context(), entry); err != nil {
+		h.logError(c, "record audit log failed", err, map[string]any{
+			"user":     entry.Actor,
+			"action":   action,
+			"resource": resourceID,
+		})
+	}
+}
+
+func (h *Handler) listAuditLogs(c *gin.Context) {
+	action := "audit_logs.list"
+	if h.audit == nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "audit log unavailable")
+		return
+	}
+	opts := parseAccountsListQuery(c)
+	filter := audit.Filter{
+		Actor:        strings.TrimSpace(c.Query("actor")),
+		Action:       strings.TrimSpace(c.Query("action")),
+		ResourceType: strings.TrimSpace(c.Query("resource_type")),
+		ResourceID:   strings.TrimSpace(c.Query("resource_id")),
+		Limit:        opts.Limit,
+		Offset:       opts.Offset,
+	}
+	var err error
+	if filter.Since, err = parseTimeQuery(c, "since"); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	if filter.Until, err = parseTimeQuery(c, "until"); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	entries, total, err := h.audit.List(c.Request.Context(), filter)
+	if err != nil {
+		h.logError(c, "list audit logs failed", err, map[string]any{"user": currentAdminUser(c)})
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, err.Error())
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, listAccountsResponse(entries, total, opts))
+}
+
+// parseTimeQuery 解析 RFC 3339 格式的时间参数，缺省时返回零值。
+func parseTimeQuery(c *gin.Context, key string) (time.Time, error) {
+	raw := strings.TrimSpace(c.Query(key))
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: must be an RFC 3339 timestamp", key)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,169 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/audit"
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+type tokenResponse struct {
+	AccessToken      string       `json:"access_token"`
+	TokenType        string       `json:"token_type"`
+	ExpiresIn        int          `json:"expires_in"`
+	RefreshToken     string       `json:"refresh_token"`
+	RefreshExpiresIn int          `json:"refresh_expires_in"`
+	Permissions      []Permission `json:"permissions"`
+}
+
+func toTokenResponse(pair TokenPair) tokenResponse {
+	return tokenResponse{
+		AccessToken:      pair.AccessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(pair.AccessExpiresIn.Seconds()),
+		RefreshToken:     pair.RefreshToken,
+		RefreshExpiresIn: int(pair.RefreshExpiresIn.Seconds()),
+		Permissions:      pair.Permissions,
+	}
+}
+
+// recordLoginFailure 将失败的登录尝试记入审计日志，操作人为尝试登录的用户名。
+// 登录失败不涉及资源变更，因此不经过 recordAudit，也不发布变更事件。
+func (h *Handler) recordLoginFailure(c *gin.Context, username, reason string) {
+	if h.audit == nil {
+		return
+	}
+	entry := audit.Entry{
+		Actor:        username,
+		Action:       "auth.login_failed",
+		ResourceType: auditResourceLogin,
+		ResourceID:   username,
+		RequestID:    middleware.RequestIDFromContext(c),
+		After:        audit.Snapshot(map[string]string{"client_ip": h.auth.clientIP(c), "reason": reason}),
+	}
+	if err := h.audit.Record(c.Request.Context(), entry); err != nil {
+		h.logError(c, "record audit log failed", err, map[string]any{"action": entry.Action, "username": username})
+	}
+}
+
+// auditBasicAuthFailure 包裹认证中间件：中间件拒绝 Basic 认证失败的请求后，按与 /login 失败相同的方式记入审计日志。
+func (h *Handler) auditBasicAuthFailure(c *gin.Context) {
+	c.Next()
+	if value, ok := c.Get(basicAuthFailureKey); ok && h.auth != nil {
+		failure := value.(basicAuthFailure)
+		h.recordLoginFailure(c, failure.username, failure.reason)
+	}
+}
+
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// refreshToken 以刷新令牌换取新的令牌对，旧刷新令牌同时失效。请求体未携带刷新令牌时
+// 使用刷新 Cookie，此时需通过 X-CSRF-Token 回传会话的 CSRF 令牌，新令牌同样写入 Cookie。
+func (h *Handler) refreshToken(c *gin.Context) {
+	if h.auth == nil || !h.auth.TokenEnabled() {
+		errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "token refresh disabled")
+		return
+	}
+	action := "auth.refresh"
+	var req refreshTokenRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			metrics.ObserveAdminAction(action, false)
+			errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+			return
+		}
+	}
+	if req.RefreshToken == "" {
+		if cookie, err := c.Cookie(RefreshCookie); err == nil && cookie != "" {
+			h.refreshSession(c, action, cookie)
+			return
+		}
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "refresh_token is required")
+		return
+	}
+	pair, err := h.auth.RefreshToken(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		h.logError(c, "token refresh failed", err, nil)
+		metrics.ObserveAdminAction(action, false)
+		message := "invalid refresh token"
+		if errors.Is(err, ErrTokenRevoked) || errors.Is(err, ErrExternalSessionExpired) {
+			message = err.Error()
+		}
+		errcode.Respond(c, http.StatusUnauthorized, errcode.Unauthorized, message)
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, toTokenResponse(pair))
+}
+
+func (h *Handler) refreshSession(c *gin.Context, action, refreshToken string) {
+	pair, err := h.auth.RefreshSessionToken(c.Request.Context(), refreshToken, c.GetHeader(CSRFHeader))
+	if err != nil {
+		h.logError(c, "session refresh failed", err, nil)
+		metrics.ObserveAdminAction(action, false)
+		if errors.Is(err, ErrInvalidCSRF) {
+			errcode.Respond(c, http.StatusForbidden, errcode.Forbidden, err.Error())
+			return
+		}
+		h.clearSession(c)
+		errcode.Respond(c, http.StatusUnauthorized, errcode.Unauthorized, "invalid refresh token")
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	h.writeSession(c, pair)
+}
+
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// logout 吊销请求头中的访问令牌、请求体中的刷新令牌以及会话 Cookie 中的令牌，并清除会话 Cookie；
+// 令牌已过期或无效时同样返回 204。
+func (h *Handler) logout(c *gin.Context) {
+	if h.auth == nil || !h.auth.TokenEnabled() {
+		errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "logout disabled")
+		return
+	}
+	action := "auth.logout"
+	var req logoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			metrics.ObserveAdminAction(action, false)
+			errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+			return
+		}
+	}
+	ctx := c.Request.Context()
+	tokens := []string{req.RefreshToken}
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		tokens = append(tokens, strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+	}
+	for _, name := range []string{SessionCookie, RefreshCookie} {
+		if cookie, err := c.Cookie(name); err == nil {
+			tokens = append(tokens, cookie)
+		}
+	}
+	for _, token := range tokens {
+		if token == "" {
+			continue
+		}
+		if err := h.auth.Revoke(ctx, token); err != nil {
+			h.logError(c, "token revoke failed", err, nil)
+			metrics.ObserveAdminAction(action, false)
+			errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, "revoke token failed")
+			return
+		}
+	}
+	h.clearSession(c)
+	metrics.ObserveAdminAction(action, true)
+	c.Status(http.StatusNoContent)
+}
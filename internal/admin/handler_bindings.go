@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+func toBindingResponses(items []accounts.BindingWithUpstream) []apiKeyBindingResponse {
+	resp := make([]apiKeyBindingResponse, 0, len(items))
+	for _, item := range items {
+		upstream := toUpstreamCredentialResponse(item.Upstream, decodeEndpoints(item.Upstream.Endpoints))
+		resp = append(resp, toBindingResponse(item.Binding, upstream))
+	}
+	return resp
+}
+
+func (h *Handler) listAPIKeyBindings(c *gin.Context) {
+	action := "accounts.api_keys.bindings.list"
+	apiKeyID := c.Param("id")
+	if apiKeyID == "" {
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "api key id is required")
+		return
+	}
+	items, err := h.service.ListBindingsByAPIKey(c.Request.Context(), apiKeyID)
+	if h.handleAccountsError(c, action, err, map[string]any{"api_key_id": apiKeyID}) {
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, gin.H{"items": toBindingResponses(items)})
+}
+
+func (h *Handler) listUserBindings(c *gin.Context) {
+	action := "accounts.bindings.list"
+	userID := c.Param("id")
+	if userID == "" {
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "user id is required")
+		return
+	}
+	items, err := h.service.ListBindingsByUser(c.Request.Context(), userID)
+	if h.handleAccountsError(c, action, err, map[string]any{"target_user": userID}) {
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, gin.H{"items": toBindingResponses(items)})
+}
+
+func (h *Handler) deleteBinding(c *gin.Context) {
+	action := "accounts.bindings.delete"
+	bindingID := c.Param("id")
+	if bindingID == "" {
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "binding id is required")
+		return
+	}
+	err := h.service.DeleteBinding(c.Request.Context(), bindingID)
+	if h.handleAccountsError(c, action, err, map[string]any{"binding": bindingID}) {
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	h.logInfo(c, "api key binding deleted", map[string]any{
+		"user":    currentAdminUser(c),
+		"binding": bindingID,
+	})
+	h.recordAudit(c, action, auditResourceAPIBinding, bindingID, nil, nil)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,210 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/budget"
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// WithBudgets 设置预算服务，未设置时预算接口返回 501。
+func WithBudgets(budgets *budget.Service) Option {
+	return func(h *Handler) {
+		h.budgets = budgets
+	}
+}
+
+// budgetResponse 是预算及本周期花费的对外表示。
+type budgetResponse struct {
+	Scope       string    `json:"scope"`
+	Subject     string    `json:"subject"`
+	LimitUSD    float64   `json:"limit_usd"`
+	Period      string    `json:"period"`
+	Thresholds  []float64 `json:"thresholds"`
+	WebhookURL  string    `json:"webhook_url,omitempty"`
+	Emails      []string  `json:"emails,omitempty"`
+	HardStop    bool      `json:"hard_stop"`
+	SpentUSD    float64   `json:"spent_usd"`
+	Exceeded    bool      `json:"exceeded"`
+	Notified    float64   `json:"notified_threshold,omitempty"`
+	PeriodStart time.Time `json:"period_start"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func toBudgetResponse(b budget.Budget) budgetResponse {
+	return budgetResponse{
+		Scope:       b.Scope,
+		Subject:     b.Subject,
+		LimitUSD:    b.LimitUSD,
+		Period:      b.Period,
+		Thresholds:  b.Thresholds,
+		WebhookURL:  b.WebhookURL,
+		Emails:      b.Emails,
+		HardStop:    b.HardStop,
+		SpentUSD:    b.SpentUSD,
+		Exceeded:    b.Exceeded(),
+		Notified:    b.Notified,
+		PeriodStart: b.PeriodStart,
+		UpdatedAt:   b.UpdatedAt,
+	}
+}
+
+type putBudgetRequest struct {
+	LimitUSD   float64   `json:"limit_usd"`
+	Period     string    `json:"period"`
+	Thresholds []float64 `json:"thresholds"`
+	WebhookURL string    `json:"webhook_url"`
+	Emails     []string  `json:"emails"`
+	HardStop   bool      `json:"hard_stop"`
+}
+
+func budgetID(c *gin.Context) string {
+	return c.Param("scope") + ":" + c.Param("subject")
+}
+
+func (h *Handler) listBudgets(c *gin.Context) {
+	action := "budgets.list"
+	if !h.budgetsAvailable(c, action) {
+		return
+	}
+	budgets, err := h.budgets.List(c.Request.Context())
+	if err != nil {
+		h.handleBudgetError(c, action, "list budgets failed", err)
+		return
+	}
+	items := make([]budgetResponse, 0, len(budgets))
+	for _, b := range budgets {
+		items = append(items, toBudgetResponse(b))
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *Handler) getBudget(c *gin.Context) {
+	action := "budgets.get"
+	if !h.budgetsAvailable(c, action) {
+		return
+	}
+	b, err := h.budgets.Get(c.Request.Context(), c.Param("scope"), c.Param("subject"))
+	if err != nil {
+		h.handleBudgetError(c, action, "get budget failed", err)
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, toBudgetResponse(b))
+}
+
+// putBudget 创建或整体替换预算配置，本周期已累计的花费保留。
+func (h *Handler) putBudget(c *gin.Context) {
+	action := "budgets.put"
+	if !h.budgetsAvailable(c, action) {
+		return
+	}
+	var req putBudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	ctx := c.Request.Context()
+	var before any
+	if existing, err := h.budgets.Get(ctx, c.Param("scope"), c.Param("subject")); err == nil {
+		before = toBudgetResponse(existing)
+	} else if !errors.Is(err, budget.ErrNotFound) {
+		h.handleBudgetError(c, action, "get budget failed", err)
+		return
+	}
+	b, err := h.budgets.Upsert(ctx, budget.Budget{
+		Scope:      c.Param("scope"),
+		Subject:    c.Param("subject"),
+		LimitUSD:   req.LimitUSD,
+		Period:     req.Period,
+		Thresholds: req.Thresholds,
+		WebhookURL: req.WebhookURL,
+		Emails:     req.Emails,
+		HardStop:   req.HardStop,
+	})
+	if err != nil {
+		h.handleBudgetError(c, action, "save budget failed", err)
+		return
+	}
+	resp := toBudgetResponse(b)
+	h.logInfo(c, "budget saved", map[string]any{"user": currentAdminUser(c), "budget": budgetID(c)})
+	h.recordAudit(c, action, auditResourceBudget, budgetID(c), before, resp)
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) deleteBudget(c *gin.Context) {
+	action := "budgets.delete"
+	if !h.budgetsAvailable(c, action) {
+		return
+	}
+	ctx := c.Request.Context()
+	before, err := h.budgets.Get(ctx, c.Param("scope"), c.Param("subject"))
+	if err != nil {
+		h.handleBudgetError(c, action, "get budget failed", err)
+		return
+	}
+	if err := h.budgets.Delete(ctx, before.Scope, before.Subject); err != nil {
+		h.handleBudgetError(c, action, "delete budget failed", err)
+		return
+	}
+	h.logInfo(c, "budget deleted", map[string]any{"user": currentAdminUser(c), "budget": budgetID(c)})
+	h.recordAudit(c, action, auditResourceBudget, budgetID(c), toBudgetResponse(before), nil)
+	metrics.ObserveAdminAction(action, true)
+	c.Status(http.StatusNoContent)
+}
+
+// resetBudget 清零本周期花费与告警状态，hard_stop 拦截随即解除。
+func (h *Handler) resetBudget(c *gin.Context) {
+	action := "budgets.reset"
+	if !h.budgetsAvailable(c, action) {
+		return
+	}
+	ctx := c.Request.Context()
+	before, err := h.budgets.Get(ctx, c.Param("scope"), c.Param("subject"))
+	if err != nil {
+		h.handleBudgetError(c, action, "get budget failed", err)
+		return
+	}
+	b, err := h.budgets.Reset(ctx, before.Scope, before.Subject)
+	if err != nil {
+		h.handleBudgetError(c, action, "reset budget failed", err)
+		return
+	}
+	resp := toBudgetResponse(b)
+	h.logInfo(c, "budget reset", map[string]any{"user": currentAdminUser(c), "budget": budgetID(c), "spent_usd": before.SpentUSD})
+	h.recordAudit(c, action, auditResourceBudget, budgetID(c), toBudgetResponse(before), resp)
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) budgetsAvailable(c *gin.Context, action string) bool {
+	if h.budgets != nil {
+		return true
+	}
+	metrics.ObserveAdminAction(action, false)
+	errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "budgets unavailable")
+	return false
+}
+
+// handleBudgetError 将预算服务错误映射为 HTTP 状态码。
+func (h *Handler) handleBudgetError(c *gin.Context, action, msg string, err error) {
+	metrics.ObserveAdminAction(action, false)
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, budget.ErrInvalidInput):
+		status = http.StatusBadRequest
+	case errors.Is(err, budget.ErrNotFound):
+		status = http.StatusNotFound
+	default:
+		h.logError(c, msg, err, map[string]any{"user": currentAdminUser(c), "budget": budgetID(c)})
+	}
+	errcode.Respond(c, status, errcode.ForStatus(status), err.Error())
+}
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/audit"
+	"github.com/prehisle/yapi/internal/budget"
+)
+
+func TestHandler_Budgets_CRUDAndReset(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	budgets := budget.NewService(budget.NewMemoryStore())
+	auditStore := audit.NewMemoryStore()
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth, WithBudgets(budgets), WithAuditStore(auditStore)), auth.Middleware())
+	owner := basicAuth("admin", "secret")
+
+	rec := doAdminRequest(router, http.MethodPut, "/admin/budgets/org/acme", `{"limit_usd":5,"period":"daily","hard_stop":true,"emails":["ops@example.com"]}`, owner)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp budgetResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, []float64{0.8, 1}, resp.Thresholds)
+	require.True(t, resp.HardStop)
+
+	rec = doAdminRequest(router, http.MethodPut, "/admin/budgets/team/acme", `{"limit_usd":5}`, owner)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	budgets.Record(t.Context(), "alice", "acme", 6)
+	require.ErrorIs(t, budgets.Check("alice", "acme"), budget.ErrExceeded)
+	rec = doAdminRequest(router, http.MethodGet, "/admin/budgets", "", owner)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list struct {
+		Items []budgetResponse `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Items, 1)
+	require.True(t, list.Items[0].Exceeded)
+	require.InDelta(t, 6, list.Items[0].SpentUSD, 1e-9)
+
+	rec = doAdminRequest(router, http.MethodPost, "/admin/budgets/org/acme/reset", "", owner)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Zero(t, resp.SpentUSD)
+	require.NoError(t, budgets.Check("alice", "acme"))
+
+	rec = doAdminRequest(router, http.MethodDelete, "/admin/budgets/org/acme", "", owner)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = doAdminRequest(router, http.MethodPost, "/admin/budgets/org/acme/reset", "", owner)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	entries, total, err := auditStore.List(t.Context(), audit.Filter{ResourceType: auditResourceBudget})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, total)
+	require.Equal(t, "budgets.delete", entries[0].Action)
+	require.Equal(t, "budgets.reset", entries[1].Action)
+	require.Equal(t, "org:acme", entries[1].ResourceID)
+}
+
+func TestHandler_Budgets_Unavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth), auth.Middleware())
+
+	rec := doAdminRequest(router, http.MethodGet, "/admin/budgets", "", basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+}
@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/reload"
+	"github.com/prehisle/yapi/pkg/config"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// RuntimeConfig 描述网关启动后实际生效的配置，用于排查“为什么没有使用 Redis”之类的问题。
+type RuntimeConfig struct {
+	// Settings 以环境变量名为键列出生效配置，敏感项已脱敏。
+	Settings map[string]string `json:"settings"`
+	// Backends 列出各组件实际使用的实现，如 rules_store: postgres、event_bus: memory。
+	Backends map[string]string `json:"backends"`
+	// Warnings 记录启动时发生的降级，如 Redis 不可达后回退到进程内实现。
+	Warnings []string `json:"warnings"`
+}
+
+// WithRuntimeConfig 设置 GET /config 返回的运行时配置，未设置时该接口返回 501。
+func WithRuntimeConfig(cfg RuntimeConfig) Option {
+	return func(h *Handler) {
+		h.runtimeConfig = &cfg
+	}
+}
+
+// ConfigReloader 重新加载配置文件并返回当前生效的配置，由 reload.Reloader 实现。
+type ConfigReloader interface {
+	Reload() (reload.Result, error)
+	Current() config.Config
+}
+
+// WithConfigReloader 设置配置热更新，未设置时 POST /config/reload 返回 501。
+// 设置后 GET /config 返回的 settings 反映最近一次重新加载的结果。
+func WithConfigReloader(reloader ConfigReloader) Option {
+	return func(h *Handler) {
+		h.reloader = reloader
+	}
+}
+
+// getConfig 返回生效配置与各组件使用的后端实现。
+func (h *Handler) getConfig(c *gin.Context) {
+	action := "config.get"
+	if h.runtimeConfig == nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "runtime config unavailable")
+		return
+	}
+	resp := *h.runtimeConfig
+	if h.reloader != nil {
+		resp.Settings = h.reloader.Current().Settings()
+	}
+	if resp.Warnings == nil {
+		resp.Warnings = []string{}
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, resp)
+}
+
+// reloadConfig 重新读取配置文件，把变更应用到日志级别、默认限流、CORS 来源、默认上游等子系统，
+// 其余变更在响应的 restart_required 中列出。与向进程发送 SIGHUP 等效。
+func (h *Handler) reloadConfig(c *gin.Context) {
+	action := "config.reload"
+	if h.reloader == nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "config reload unavailable")
+		return
+	}
+	before := h.reloader.Current().Settings()
+	result, err := h.reloader.Reload()
+	if err != nil {
+		metrics.ObserveAdminAction(action, false)
+		switch {
+		case errors.Is(err, reload.ErrNoConfigFile):
+			errcode.Respond(c, http.StatusConflict, errcode.Conflict, err.Error())
+		case errors.Is(err, config.ErrInvalidConfigFile):
+			errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		default:
+			errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, err.Error())
+		}
+		return
+	}
+	after := h.reloader.Current().Settings()
+	changedBefore := make(map[string]string, len(result.Changed))
+	changedAfter := make(map[string]string, len(result.Changed))
+	for _, key := range result.Changed {
+		changedBefore[key], changedAfter[key] = before[key], after[key]
+	}
+	h.logInfo(c, "config reloaded", map[string]any{"user": currentAdminUser(c), "changed": result.Changed, "applied": result.Applied})
+	h.recordAudit(c, action, auditResourceConfig, "", changedBefore, changedAfter)
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,41 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_GetConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth), auth.Middleware())
+	rec := doAdminRequest(router, http.MethodGet, "/admin/config", "", basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+
+	router = gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth, WithRuntimeConfig(RuntimeConfig{
+		Settings: map[string]string{"REDIS_ADDR": "redis:6379", "ADMIN_PASSWORD": "******"},
+		Backends: map[string]string{"rules_cache": "none", "event_bus": "memory"},
+	})), auth.Middleware())
+	rec = doAdminRequest(router, http.MethodGet, "/admin/config", "", basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp RuntimeConfig
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "redis:6379", resp.Settings["REDIS_ADDR"])
+	require.Equal(t, "memory", resp.Backends["event_bus"])
+	require.NotNil(t, resp.Warnings)
+
+	scoped, err := auth.IssueTokenPair(context.Background(), "admin", "secret", PermRulesRead)
+	require.NoError(t, err)
+	rec = doAdminRequest(router, http.MethodGet, "/admin/config", "", func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+scoped.AccessToken)
+	})
+	require.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -0,0 +1,185 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/archive"
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// WithConversationArchive 设置对话归档，未设置时对话接口返回 501。
+func WithConversationArchive(a *archive.Archive) Option {
+	return func(h *Handler) {
+		h.conversations = a
+	}
+}
+
+// conversationsAvailable 在未启用归档时返回 501。
+func (h *Handler) conversationsAvailable(c *gin.Context, action string) bool {
+	if h.conversations != nil {
+		return true
+	}
+	metrics.ObserveAdminAction(action, false)
+	errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "conversation archive unavailable")
+	return false
+}
+
+// parseConversationFilter 解析对话列表与导出共用的过滤参数。
+func parseConversationFilter(c *gin.Context) (archive.Filter, error) {
+	filter := archive.Filter{
+		UserID: strings.TrimSpace(c.Query("user_id")),
+		RuleID: strings.TrimSpace(c.Query("rule_id")),
+		Model:  strings.TrimSpace(c.Query("model")),
+	}
+	var err error
+	if filter.Since, err = parseTimeQuery(c, "since"); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = parseTimeQuery(c, "until"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// listConversations 返回归档对话的元数据，不含请求体与响应体。
+func (h *Handler) listConversations(c *gin.Context) {
+	action := "conversations.list"
+	if !h.conversationsAvailable(c, action) {
+		return
+	}
+	filter, err := parseConversationFilter(c)
+	if err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	opts := parseAccountsListQuery(c)
+	filter.Limit, filter.Offset = opts.Limit, opts.Offset
+	conversations, total, err := h.conversations.List(c.Request.Context(), filter)
+	if err != nil {
+		h.logError(c, "list conversations failed", err, map[string]any{"user": currentAdminUser(c)})
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, err.Error())
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, listAccountsResponse(conversations, total, opts))
+}
+
+// getConversation 返回解密后的单条对话，查看内容本身记入审计日志。
+func (h *Handler) getConversation(c *gin.Context) {
+	action := "conversations.get"
+	if !h.conversationsAvailable(c, action) {
+		return
+	}
+	id := c.Param("id")
+	conv, err := h.conversations.Get(c.Request.Context(), id)
+	if err != nil {
+		metrics.ObserveAdminAction(action, false)
+		if errors.Is(err, archive.ErrNotFound) {
+			errcode.Respond(c, http.StatusNotFound, errcode.NotFound, err.Error())
+			return
+		}
+		h.logError(c, "get conversation failed", err, map[string]any{"user": currentAdminUser(c), "conversation_id": id})
+		errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, err.Error())
+		return
+	}
+	h.recordAudit(c, action, auditResourceConversation, id, nil, nil)
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, conv)
+}
+
+// exportConversations 以 NDJSON 流式导出匹配的对话（含解密后的请求体与响应体），导出本身记入审计日志。
+func (h *Handler) exportConversations(c *gin.Context) {
+	action := "conversations.export"
+	if !h.conversationsAvailable(c, action) {
+		return
+	}
+	filter, err := parseConversationFilter(c)
+	if err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "yapi-conversations-"+time.Now().UTC().Format("20060102T150405Z")+".ndjson"))
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	exported := 0
+	err = h.conversations.Export(c.Request.Context(), filter, func(conv archive.Conversation) error {
+		exported++
+		return encoder.Encode(conv)
+	})
+	if err != nil {
+		h.logError(c, "export conversations failed", err, map[string]any{"user": currentAdminUser(c), "exported": exported})
+		metrics.ObserveAdminAction(action, false)
+		// 已开始输出时响应头已发出，只能中断输出。
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, err.Error())
+		}
+		return
+	}
+	h.recordAudit(c, action, auditResourceConversation, "", nil, map[string]any{
+		"user_id":  filter.UserID,
+		"rule_id":  filter.RuleID,
+		"model":    filter.Model,
+		"exported": exported,
+	})
+	metrics.ObserveAdminAction(action, true)
+}
+
+// deleteConversation 删除单条对话。
+func (h *Handler) deleteConversation(c *gin.Context) {
+	action := "conversations.delete"
+	if !h.conversationsAvailable(c, action) {
+		return
+	}
+	id := c.Param("id")
+	if err := h.conversations.Delete(c.Request.Context(), id); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		if errors.Is(err, archive.ErrNotFound) {
+			errcode.Respond(c, http.StatusNotFound, errcode.NotFound, err.Error())
+			return
+		}
+		h.logError(c, "delete conversation failed", err, map[string]any{"user": currentAdminUser(c), "conversation_id": id})
+		errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, err.Error())
+		return
+	}
+	h.recordAudit(c, action, auditResourceConversation, id, nil, nil)
+	metrics.ObserveAdminAction(action, true)
+	c.Status(http.StatusNoContent)
+}
+
+// deleteUserConversations 删除指定用户的全部对话，user_id 为必填参数，避免误删整个归档。
+func (h *Handler) deleteUserConversations(c *gin.Context) {
+	action := "conversations.delete_user"
+	if !h.conversationsAvailable(c, action) {
+		return
+	}
+	userID := strings.TrimSpace(c.Query("user_id"))
+	if userID == "" {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "user_id is required")
+		return
+	}
+	deleted, err := h.conversations.DeleteUser(c.Request.Context(), userID)
+	if err != nil {
+		h.logError(c, "delete user conversations failed", err, map[string]any{"user": currentAdminUser(c), "user_id": userID})
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, err.Error())
+		return
+	}
+	h.recordAudit(c, action, auditResourceConversation, userID, nil, map[string]any{"deleted": deleted})
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
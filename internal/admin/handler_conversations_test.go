@@ -0,0 +1,94 @@
+package admin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/archive"
+	"github.com/prehisle/yapi/internal/audit"
+)
+
+func TestHandler_Conversations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth), auth.Middleware())
+	rec := doAdminRequest(router, http.MethodGet, "/admin/conversations", "", basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+
+	ctx := context.Background()
+	conversations, err := archive.New(archive.NewMemoryStore(), bytes.Repeat([]byte{3}, 32), time.Hour, nil)
+	require.NoError(t, err)
+	base := time.Now().UTC().Add(-time.Minute)
+	for i, conv := range []archive.Conversation{
+		{ID: "c1", UserID: "u1", RuleID: "r1", Model: "gpt-4o", Request: "first", Response: "answer", CreatedAt: base},
+		{ID: "c2", UserID: "u1", RuleID: "r2", Model: "claude", Request: "second", CreatedAt: base.Add(time.Second)},
+		{ID: "c3", UserID: "u2", RuleID: "r1", Model: "gpt-4o", Request: "third", CreatedAt: base.Add(2 * time.Second)},
+	} {
+		require.NoErrorf(t, conversations.Record(ctx, conv, time.Hour), "record %d", i)
+	}
+	auditStore := audit.NewMemoryStore()
+	router = gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth, WithConversationArchive(conversations), WithAuditStore(auditStore)), auth.Middleware())
+	send := func(method, path string) *httptest.ResponseRecorder {
+		return doAdminRequest(router, method, path, "", basicAuth("admin", "secret"))
+	}
+
+	rec = send(http.MethodGet, "/admin/conversations?model=gpt-4o&limit=1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list struct {
+		Items []archive.Conversation `json:"items"`
+		Total int64                  `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.EqualValues(t, 2, list.Total)
+	require.Len(t, list.Items, 1)
+	require.Equal(t, "c3", list.Items[0].ID)
+	require.Empty(t, list.Items[0].Request)
+	require.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/admin/conversations?since=yesterday").Code)
+
+	rec = send(http.MethodGet, "/admin/conversations/c1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var conv archive.Conversation
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &conv))
+	require.Equal(t, "first", conv.Request)
+	require.Equal(t, "answer", conv.Response)
+	require.Equal(t, http.StatusNotFound, send(http.MethodGet, "/admin/conversations/missing").Code)
+
+	rec = send(http.MethodGet, "/admin/conversations/export?user_id=u1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	var exported []string
+	scanner := bufio.NewScanner(strings.NewReader(rec.Body.String()))
+	for scanner.Scan() {
+		var line archive.Conversation
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		exported = append(exported, line.Request)
+	}
+	require.Equal(t, []string{"second", "first"}, exported)
+
+	require.Equal(t, http.StatusBadRequest, send(http.MethodDelete, "/admin/conversations").Code)
+	require.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/admin/conversations/c3").Code)
+	require.Equal(t, http.StatusNotFound, send(http.MethodDelete, "/admin/conversations/c3").Code)
+	rec = send(http.MethodDelete, "/admin/conversations?user_id=u1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"deleted":2}`, rec.Body.String())
+
+	entries, _, err := auditStore.List(ctx, audit.Filter{ResourceType: auditResourceConversation})
+	require.NoError(t, err)
+	var actions []string
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+	require.ElementsMatch(t, []string{"conversations.get", "conversations.export", "conversations.delete", "conversations.delete_user"}, actions)
+}
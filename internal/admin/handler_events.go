@@ -0,0 +1,92 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/pkg/metrics"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// eventHeartbeatInterval 是事件流的心跳间隔，避免空闲连接被代理或负载均衡器断开。
+const eventHeartbeatInterval = 15 * time.Second
+
+// WithEventBus 设置事件总线：管理端在账户类资源变更后发布事件，并通过 GET /events 向订阅方推送。
+func WithEventBus(bus rules.EventBus) Option {
+	return func(h *Handler) {
+		h.events = bus
+	}
+}
+
+type changeEvent struct {
+	Type string    `json:"type"`
+	At   time.Time `json:"at"`
+}
+
+// notifyChange 为账户类资源发布变更事件；规则与模型别名的变更已由各自的服务广播，管理员账号、服务令牌与运行时设置的变更不对外推送。
+func (h *Handler) notifyChange(c *gin.Context, resourceType string) {
+	if h.events == nil {
+		return
+	}
+	switch resourceType {
+	case auditResourceRule, auditResourceModelAlias, auditResourceAdminUser, auditResourceSvcToken, auditResourceLogLevel, auditResourceFeature, auditResourceConversation, auditResourceRequest:
+		return
+	}
+	if err := h.events.Publish(c.Request.Context(), rules.EventAccountsChanged); err != nil {
+		h.logError(c, "publish accounts event failed", err, map[string]any{"resource_type": resourceType})
+	}
+}
+
+// streamEvents 以 Server-Sent Events 推送规则与账户变更，连接断开时结束订阅。
+func (h *Handler) streamEvents(c *gin.Context) {
+	action := "events.stream"
+	if h.events == nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "event stream unavailable")
+		return
+	}
+	ctx := c.Request.Context()
+	events, err := h.events.Subscribe(ctx)
+	if err != nil {
+		h.logError(c, "subscribe events failed", err, map[string]any{"user": currentAdminUser(c)})
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusServiceUnavailable, errcode.ServiceUnavailable, "event stream unavailable")
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	_, _ = c.Writer.WriteString(": connected\n\n")
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case evt, ok := <-events:
+			if !ok {
+				return
+			}
+			payload, _ := json.Marshal(changeEvent{Type: string(evt), At: time.Now().UTC()})
+			if _, err := fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", evt, payload); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/modelalias"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// WithModelAliases 设置模型别名服务，未设置时别名接口返回 501。
+func WithModelAliases(aliases modelalias.Service) Option {
+	return func(h *Handler) {
+		h.modelAliases = aliases
+	}
+}
+
+// modelAliasResponse 是模型别名的对外表示。
+type modelAliasResponse struct {
+	Name      string            `json:"name"`
+	Target    string            `json:"target,omitempty"`
+	Providers map[string]string `json:"providers,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+func toModelAliasResponse(alias modelalias.Alias) modelAliasResponse {
+	return modelAliasResponse{
+		Name:      alias.Name,
+		Target:    alias.Target,
+		Providers: alias.Providers,
+		UpdatedAt: alias.UpdatedAt,
+	}
+}
+
+type putModelAliasRequest struct {
+	Target    string            `json:"target"`
+	Providers map[string]string `json:"providers"`
+}
+
+func (h *Handler) listModelAliases(c *gin.Context) {
+	action := "model_aliases.list"
+	if !h.modelAliasesAvailable(c, action) {
+		return
+	}
+	aliases, err := h.modelAliases.List(c.Request.Context())
+	if err != nil {
+		h.handleModelAliasError(c, action, "list model aliases failed", err)
+		return
+	}
+	items := make([]modelAliasResponse, 0, len(aliases))
+	for _, alias := range aliases {
+		items = append(items, toModelAliasResponse(alias))
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *Handler) getModelAlias(c *gin.Context) {
+	action := "model_aliases.get"
+	if !h.modelAliasesAvailable(c, action) {
+		return
+	}
+	alias, err := h.modelAliases.Get(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		h.handleModelAliasError(c, action, "get model alias failed", err)
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, toModelAliasResponse(alias))
+}
+
+// putModelAlias 创建或整体替换别名，对后续请求立即生效。
+func (h *Handler) putModelAlias(c *gin.Context) {
+	action := "model_aliases.put"
+	if !h.modelAliasesAvailable(c, action) {
+		return
+	}
+	var req putModelAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	ctx := c.Request.Context()
+	name := c.Param("name")
+	var before any
+	if existing, err := h.modelAliases.Get(ctx, name); err == nil {
+		before = toModelAliasResponse(existing)
+	} else if !errors.Is(err, modelalias.ErrNotFound) {
+		h.handleModelAliasError(c, action, "get model alias failed", err)
+		return
+	}
+	alias, err := h.modelAliases.Upsert(ctx, modelalias.Alias{Name: name, Target: req.Target, Providers: req.Providers})
+	if err != nil {
+		h.handleModelAliasError(c, action, "save model alias failed", err)
+		return
+	}
+	resp := toModelAliasResponse(alias)
+	h.logInfo(c, "model alias saved", map[string]any{"user": currentAdminUser(c), "model_alias": alias.Name})
+	h.recordAudit(c, action, auditResourceModelAlias, alias.Name, before, resp)
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) deleteModelAlias(c *gin.Context) {
+	action := "model_aliases.delete"
+	if !h.modelAliasesAvailable(c, action) {
+		return
+	}
+	ctx := c.Request.Context()
+	name := c.Param("name")
+	before, err := h.modelAliases.Get(ctx, name)
+	if err != nil {
+		h.handleModelAliasError(c, action, "get model alias failed", err)
+		return
+	}
+	if err := h.modelAliases.Delete(ctx, name); err != nil {
+		h.handleModelAliasError(c, action, "delete model alias failed", err)
+		return
+	}
+	h.logInfo(c, "model alias deleted", map[string]any{"user": currentAdminUser(c), "model_alias": name})
+	h.recordAudit(c, action, auditResourceModelAlias, name, toModelAliasResponse(before), nil)
+	metrics.ObserveAdminAction(action, true)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) modelAliasesAvailable(c *gin.Context, action string) bool {
+	if h.modelAliases != nil {
+		return true
+	}
+	metrics.ObserveAdminAction(action, false)
+	errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "model aliases unavailable")
+	return false
+}
+
+// handleModelAliasError 将别名服务错误映射为 HTTP 状态码。
+func (h *Handler) handleModelAliasError(c *gin.Context, action, msg string, err error) {
+	metrics.ObserveAdminAction(action, false)
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, modelalias.ErrInvalidInput):
+		status = http.StatusBadRequest
+	case errors.Is(err, modelalias.ErrNotFound):
+		status = http.StatusNotFound
+	default:
+		h.logError(c, msg, err, map[string]any{"user": currentAdminUser(c), "model_alias": c.Param("name")})
+	}
+	errcode.Respond(c, status, errcode.ForStatus(status), err.Error())
+}
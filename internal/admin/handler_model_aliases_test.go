@@ -0,0 +1,68 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/audit"
+	"github.com/prehisle/yapi/internal/modelalias"
+)
+
+func TestHandler_ModelAliases_CRUD(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	aliases := modelalias.NewService(modelalias.NewMemoryStore())
+	auditStore := audit.NewMemoryStore()
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth, WithModelAliases(aliases), WithAuditStore(auditStore)), auth.Middleware())
+	owner := basicAuth("admin", "secret")
+
+	rec := doAdminRequest(router, http.MethodPut, "/admin/model-aliases/fast", `{"providers":{"Anthropic":"claude-3-5-haiku-latest"},"target":"gpt-4o-mini"}`, owner)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var alias modelAliasResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &alias))
+	require.Equal(t, map[string]string{"anthropic": "claude-3-5-haiku-latest"}, alias.Providers)
+	model, ok := aliases.Resolve("anthropic", "fast")
+	require.True(t, ok)
+	require.Equal(t, "claude-3-5-haiku-latest", model)
+
+	rec = doAdminRequest(router, http.MethodPut, "/admin/model-aliases/slow", `{}`, owner)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doAdminRequest(router, http.MethodGet, "/admin/model-aliases", "", owner)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list struct {
+		Items []modelAliasResponse `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Items, 1)
+	require.Equal(t, "gpt-4o-mini", list.Items[0].Target)
+
+	rec = doAdminRequest(router, http.MethodDelete, "/admin/model-aliases/fast", "", owner)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	_, ok = aliases.Resolve("", "fast")
+	require.False(t, ok)
+	rec = doAdminRequest(router, http.MethodGet, "/admin/model-aliases/fast", "", owner)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	entries, total, err := auditStore.List(t.Context(), audit.Filter{ResourceType: auditResourceModelAlias})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.Equal(t, "model_aliases.delete", entries[0].Action)
+	require.Equal(t, "model_aliases.put", entries[1].Action)
+}
+
+func TestHandler_ModelAliases_Unavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth), auth.Middleware())
+
+	rec := doAdminRequest(router, http.MethodGet, "/admin/model-aliases", "", basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+}
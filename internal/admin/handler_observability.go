@@ -0,0 +1,16 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/observability"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// getObservabilityDashboards 返回与本实例指标前缀一致的 Grafana 面板与 Prometheus 告警规则，可直接导入使用。
+func (h *Handler) getObservabilityDashboards(c *gin.Context) {
+	metrics.ObserveAdminAction("observability.dashboards", true)
+	c.JSON(http.StatusOK, observability.Generate(metrics.Name))
+}
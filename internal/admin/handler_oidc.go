@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/oidc"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// OIDCStateCookie 保存外部登录状态的 HttpOnly Cookie，回调时须与 state 参数匹配。
+const OIDCStateCookie = "yapi_admin_oidc_state"
+
+// WithOIDC 启用 OIDC 单点登录。postLoginURL 非空时回调成功后携带令牌（URL fragment）跳转到该地址，
+// 通常为管理后台前端；为空时回调直接返回 JSON 令牌。
+func WithOIDC(provider *oidc.Provider, postLoginURL string) Option {
+	return func(h *Handler) {
+		h.oidc = provider
+		h.oidcPostLoginURL = strings.TrimSpace(postLoginURL)
+	}
+}
+
+// oidcLogin 跳转到身份提供方的登录页。state、nonce 与 PKCE verifier 由认证器签发，
+// 签名后写入短期的 state Cookie，回调时校验。
+func (h *Handler) oidcLogin(c *gin.Context) {
+	action := "auth.oidc.login"
+	if h.oidc == nil || h.auth == nil || !h.auth.TokenEnabled() {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "oidc login disabled")
+		return
+	}
+	login, err := h.auth.IssueLoginState()
+	if err != nil {
+		h.logError(c, "issue oidc state failed", err, nil)
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, "oidc login failed")
+		return
+	}
+	target, err := h.oidc.AuthCodeURL(c.Request.Context(), login.State, login.Nonce, login.Verifier)
+	if err != nil {
+		h.logError(c, "oidc discovery failed", err, nil)
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadGateway, errcode.UpstreamUnavailable, "identity provider unavailable")
+		return
+	}
+	h.setLoginStateCookie(c, login.Cookie, int(loginStateTTL.Seconds()))
+	metrics.ObserveAdminAction(action, true)
+	c.Redirect(http.StatusFound, target)
+}
+
+// setLoginStateCookie 写入或删除 state Cookie。回调是从身份提供方跳回的跨站导航，
+// Cookie 使用 SameSite=Lax 才会随之发送，且只在回调中读取。
+func (h *Handler) setLoginStateCookie(c *gin.Context, value string, maxAge int) {
+	cookie := h.newCookie(OIDCStateCookie, value, maxAge, true)
+	cookie.SameSite = http.SameSiteLaxMode
+	cookie.Secure = !h.cookies.Insecure
+	http.SetCookie(c.Writer, cookie)
+}
+
+// oidcCallback 校验 state 与 state Cookie 匹配，用授权码与 PKCE verifier 换取 ID Token，并按用户组映射的角色签发管理端令牌。
+func (h *Handler) oidcCallback(c *gin.Context) {
+	action := "auth.oidc.callback"
+	if h.oidc == nil || h.auth == nil || !h.auth.TokenEnabled() {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "oidc login disabled")
+		return
+	}
+	if providerErr := c.Query("error"); providerErr != "" {
+		h.logError(c, "oidc provider returned error", errors.New(providerErr), map[string]any{"description": c.Query("error_description")})
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusUnauthorized, errcode.Unauthorized, "oidc login rejected: "+providerErr)
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "code and state are required")
+		return
+	}
+	ctx := c.Request.Context()
+	cookie, _ := c.Cookie(OIDCStateCookie)
+	h.setLoginStateCookie(c, "", -1)
+	login, err := h.auth.ConsumeLoginState(ctx, state, cookie)
+	if err != nil {
+		h.logError(c, "oidc state rejected", err, nil)
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusUnauthorized, errcode.Unauthorized, "invalid or expired state")
+		return
+	}
+	claims, err := h.oidc.Exchange(ctx, code, login.Nonce, login.Verifier)
+	if err != nil {
+		h.logError(c, "oidc exchange failed", err, nil)
+		metrics.ObserveAdminAction(action, false)
+		status := http.StatusBadGateway
+		if errors.Is(err, oidc.ErrInvalidToken) {
+			status = http.StatusUnauthorized
+		}
+		errcode.Respond(c, status, errcode.ForStatus(status), "oidc login failed")
+		return
+	}
+	role, err := h.oidc.ResolveRole(claims)
+	if err != nil {
+		h.logError(c, "oidc login denied", err, map[string]any{"subject": claims.Subject, "email": claims.Email, "groups": claims.Groups})
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusForbidden, errcode.Forbidden, err.Error())
+		return
+	}
+	pair, err := h.auth.IssueExternalTokenPair(claims.Username(), role)
+	if err != nil {
+		h.logError(c, "issue oidc token failed", err, map[string]any{"subject": claims.Subject})
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, "oidc login failed")
+		return
+	}
+	h.logInfo(c, "oidc login success", map[string]any{"username": claims.Username(), "role": role})
+	metrics.ObserveAdminAction(action, true)
+	if h.oidcPostLoginURL == "" {
+		c.JSON(http.StatusOK, toTokenResponse(pair))
+		return
+	}
+	resp := toTokenResponse(pair)
+	fragment := url.Values{
+		"access_token":       {resp.AccessToken},
+		"token_type":         {resp.TokenType},
+		"expires_in":         {strconv.Itoa(resp.ExpiresIn)},
+		"refresh_token":      {resp.RefreshToken},
+		"refresh_expires_in": {strconv.Itoa(resp.RefreshExpiresIn)},
+	}
+	c.Redirect(http.StatusFound, h.oidcPostLoginURL+"#"+fragment.Encode())
+}
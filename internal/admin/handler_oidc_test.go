@@ -0,0 +1,143 @@
+package admin
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/adminusers"
+	"github.com/prehisle/yapi/internal/oidc"
+)
+
+// newTestIdentityProvider 启动一个签发 ID Token 的最小 OIDC 提供方，授权码即为用户组名，
+// ID Token 的 nonce 取自最近一次授权请求，换取令牌时须携带与该请求的 code_challenge 匹配的 code_verifier。
+func newTestIdentityProvider(t *testing.T) *httptest.Server {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	var server *httptest.Server
+	var nonce, challenge string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		nonce = r.URL.Query().Get("nonce")
+		challenge = r.URL.Query().Get("code_challenge")
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if oidc.CodeChallenge(r.FormValue("code_verifier")) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		group := r.FormValue("code")
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss": server.URL, "aud": "yapi", "sub": "u-1", "email": "alice@example.com",
+			"nonce": nonce, "groups": []string{group}, "exp": time.Now().Add(time.Minute).Unix(),
+		})
+		token.Header["kid"] = "k1"
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHandler_OIDCLogin_MapsGroupToRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	idp := newTestIdentityProvider(t)
+	provider, err := oidc.NewProvider(oidc.Config{
+		IssuerURL:   idp.URL,
+		ClientID:    "yapi",
+		RedirectURL: "https://gateway.example.com/admin/oidc/callback",
+		RoleMapping: map[string]adminusers.Role{"ops": adminusers.RoleEditor, "readers": adminusers.RoleViewer},
+	})
+	require.NoError(t, err)
+	auth := NewAuthenticator("", "", "sign-key", time.Minute, WithExternalLogin(0))
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth, WithOIDC(provider, "")), auth.Middleware())
+
+	rec := doAdminRequest(router, http.MethodGet, "/admin/rules", "", nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// begin 发起登录并访问授权页，返回回调所需的 state 与 state Cookie。
+	begin := func() (string, *http.Cookie) {
+		rec := doAdminRequest(router, http.MethodGet, "/admin/oidc/login", "", nil)
+		require.Equal(t, http.StatusFound, rec.Code)
+		target, err := url.Parse(rec.Header().Get("Location"))
+		require.NoError(t, err)
+		resp, err := http.Get(target.String())
+		require.NoError(t, err)
+		resp.Body.Close()
+		cookies := rec.Result().Cookies()
+		require.Len(t, cookies, 1)
+		require.Equal(t, OIDCStateCookie, cookies[0].Name)
+		require.True(t, cookies[0].HttpOnly)
+		require.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+		return target.Query().Get("state"), cookies[0]
+	}
+	callback := func(group, state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		return doAdminRequest(router, http.MethodGet, "/admin/oidc/callback?code="+group+"&state="+url.QueryEscape(state), "", func(req *http.Request) {
+			if cookie != nil {
+				req.AddCookie(cookie)
+			}
+		})
+	}
+	login := func(group string) *httptest.ResponseRecorder {
+		state, cookie := begin()
+		return callback(group, state, cookie)
+	}
+	rec = login("ops")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var tokens tokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tokens))
+	require.Contains(t, tokens.Permissions, PermRulesWrite)
+	bearer := func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+tokens.AccessToken) }
+	rec = doAdminRequest(router, http.MethodDelete, "/admin/rules/r1", "", bearer)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = doAdminRequest(router, http.MethodGet, "/admin/admin-users", "", bearer)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = doAdminRequest(router, http.MethodPost, "/admin/token/refresh", `{"refresh_token":"`+tokens.RefreshToken+`"}`, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = login("strangers")
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = doAdminRequest(router, http.MethodGet, "/admin/oidc/callback?code=ops&state=forged", "", nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// 登录 CSRF：攻击者自己发起登录拿到的 state，不能配合受害者浏览器中的 state Cookie 使用，也不能脱离 Cookie 使用。
+	attackerState, _ := begin()
+	_, victimCookie := begin()
+	rec = callback("ops", attackerState, victimCookie)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = callback("ops", attackerState, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,166 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+type createUpstreamKeyPoolRequest struct {
+	Name          string         `json:"name" binding:"required"`
+	Provider      string         `json:"provider" binding:"required"`
+	Strategy      string         `json:"strategy"`
+	CredentialIDs []string       `json:"credential_ids"`
+	Metadata      map[string]any `json:"metadata"`
+}
+
+type updateUpstreamKeyPoolRequest struct {
+	Name          *string        `json:"name"`
+	Strategy      *string        `json:"strategy"`
+	CredentialIDs *[]string      `json:"credential_ids"`
+	Metadata      map[string]any `json:"metadata"`
+}
+
+type upstreamKeyPoolResponse struct {
+	ID            string         `json:"id"`
+	UserID        string         `json:"user_id"`
+	Name          string         `json:"name"`
+	Provider      string         `json:"provider"`
+	Strategy      string         `json:"strategy"`
+	CredentialIDs []string       `json:"credential_ids"`
+	Metadata      map[string]any `json:"metadata,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+func toUpstreamKeyPoolResponse(pool accounts.UpstreamKeyPool) upstreamKeyPoolResponse {
+	var metadata map[string]any
+	if pool.Metadata != nil {
+		metadata = map[string]any(pool.Metadata)
+	}
+	credentialIDs := pool.CredentialIDs
+	if credentialIDs == nil {
+		credentialIDs = []string{}
+	}
+	return upstreamKeyPoolResponse{
+		ID:            pool.ID,
+		UserID:        pool.UserID,
+		Name:          pool.Name,
+		Provider:      pool.Service,
+		Strategy:      pool.Strategy,
+		CredentialIDs: credentialIDs,
+		Metadata:      metadata,
+		CreatedAt:     pool.CreatedAt,
+		UpdatedAt:     pool.UpdatedAt,
+	}
+}
+
+func (h *Handler) listUpstreamKeyPools(c *gin.Context) {
+	action := "accounts.upstream_pools.list"
+	userID := c.Param("id")
+	if userID == "" {
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "user id is required")
+		return
+	}
+	pools, err := h.service.ListUpstreamKeyPools(c.Request.Context(), userID)
+	if h.handleAccountsError(c, action, err, map[string]any{"target_user": userID}) {
+		return
+	}
+	resp := make([]upstreamKeyPoolResponse, 0, len(pools))
+	for _, pool := range pools {
+		resp = append(resp, toUpstreamKeyPoolResponse(pool))
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, gin.H{"items": resp})
+}
+
+func (h *Handler) createUpstreamKeyPool(c *gin.Context) {
+	action := "accounts.upstream_pools.create"
+	userID := c.Param("id")
+	if userID == "" {
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "user id is required")
+		return
+	}
+	var req createUpstreamKeyPoolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	pool, err := h.service.CreateUpstreamKeyPool(c.Request.Context(), accounts.CreateUpstreamKeyPoolParams{
+		UserID:        userID,
+		Name:          req.Name,
+		Provider:      req.Provider,
+		Strategy:      req.Strategy,
+		CredentialIDs: req.CredentialIDs,
+		Metadata:      req.Metadata,
+	})
+	if h.handleAccountsError(c, action, err, map[string]any{"target_user": userID}) {
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	h.logInfo(c, "upstream key pool created", map[string]any{
+		"user":        currentAdminUser(c),
+		"target_user": userID,
+		"pool":        pool.ID,
+	})
+	h.recordAudit(c, action, auditResourcePool, pool.ID, nil, toUpstreamKeyPoolResponse(pool))
+	c.JSON(http.StatusCreated, toUpstreamKeyPoolResponse(pool))
+}
+
+func (h *Handler) updateUpstreamKeyPool(c *gin.Context) {
+	action := "accounts.upstream_pools.update"
+	poolID := c.Param("id")
+	if poolID == "" {
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "pool id is required")
+		return
+	}
+	var req updateUpstreamKeyPoolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	pool, err := h.service.UpdateUpstreamKeyPool(c.Request.Context(), accounts.UpdateUpstreamKeyPoolParams{
+		PoolID:        poolID,
+		Name:          req.Name,
+		Strategy:      req.Strategy,
+		CredentialIDs: req.CredentialIDs,
+		Metadata:      req.Metadata,
+	})
+	if h.handleAccountsError(c, action, err, map[string]any{"pool": poolID}) {
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	h.logInfo(c, "upstream key pool updated", map[string]any{
+		"user": currentAdminUser(c),
+		"pool": poolID,
+	})
+	h.recordAudit(c, action, auditResourcePool, poolID, nil, toUpstreamKeyPoolResponse(pool))
+	c.JSON(http.StatusOK, toUpstreamKeyPoolResponse(pool))
+}
+
+func (h *Handler) deleteUpstreamKeyPool(c *gin.Context) {
+	action := "accounts.upstream_pools.delete"
+	poolID := c.Param("id")
+	if poolID == "" {
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "pool id is required")
+		return
+	}
+	err := h.service.DeleteUpstreamKeyPool(c.Request.Context(), poolID)
+	if h.handleAccountsError(c, action, err, map[string]any{"pool": poolID}) {
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	h.logInfo(c, "upstream key pool deleted", map[string]any{
+		"user": currentAdminUser(c),
+		"pool": poolID,
+	})
+	h.recordAudit(c, action, auditResourcePool, poolID, nil, nil)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,258 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/archive"
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/proxy"
+	"github.com/prehisle/yapi/internal/reqtrace"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// 重放请求体的来源：管理员在请求中提供、对话归档或没有请求体。
+const (
+	replayBodyRequest = "request"
+	replayBodyArchive = "archive"
+	replayBodyNone    = "none"
+)
+
+// RequestReplayer 按当前规则重新执行代理请求，由 proxy.Handler 实现。
+type RequestReplayer interface {
+	Replay(ctx context.Context, req proxy.ReplayRequest) (proxy.ReplayResult, error)
+}
+
+// WithTraceStore 设置代理请求轨迹存储，未设置时请求轨迹接口返回 501。
+func WithTraceStore(store reqtrace.Store) Option {
+	return func(h *Handler) {
+		h.traces = store
+	}
+}
+
+// WithRequestReplayer 设置请求重放，未设置时 POST /requests/:request_id/replay 返回 501。
+func WithRequestReplayer(replayer RequestReplayer) Option {
+	return func(h *Handler) {
+		h.replayer = replayer
+	}
+}
+
+// getRequestTrace 按请求 ID（即响应头 X-Request-ID）返回代理请求的决策轨迹。
+func (h *Handler) getRequestTrace(c *gin.Context) {
+	action := "requests.get"
+	if h.traces == nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "request trace store unavailable")
+		return
+	}
+	requestID := c.Param("request_id")
+	trace, err := h.traces.Get(c.Request.Context(), requestID)
+	if err != nil {
+		metrics.ObserveAdminAction(action, false)
+		if errors.Is(err, reqtrace.ErrNotFound) {
+			errcode.Respond(c, http.StatusNotFound, errcode.NotFound, err.Error())
+			return
+		}
+		h.logError(c, "get request trace failed", err, map[string]any{"user": currentAdminUser(c), "request_id": requestID})
+		errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, err.Error())
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, trace)
+}
+
+// replayPayload 是重放接口的可选请求体：headers 覆盖轨迹中记录的同名原请求头，
+// body 覆盖归档中的请求体。原请求带有请求体但未归档或归档已截断时必须提供 body，否则返回 409。
+type replayPayload struct {
+	Headers map[string]string `json:"headers"`
+	Body    *string           `json:"body"`
+}
+
+// replayOutcome 是原请求或重放请求的执行结果。
+type replayOutcome struct {
+	RequestID  string              `json:"request_id"`
+	Status     int                 `json:"status"`
+	Rule       *reqtrace.RuleMatch `json:"rule,omitempty"`
+	Actions    []string            `json:"actions,omitempty"`
+	Attempts   []reqtrace.Attempt  `json:"attempts,omitempty"`
+	Error      string              `json:"error,omitempty"`
+	DurationMs int64               `json:"duration_ms"`
+	Response   string              `json:"response,omitempty"`
+	Truncated  bool                `json:"truncated,omitempty"`
+}
+
+type replayResponse struct {
+	RequestID     string        `json:"request_id"`
+	BodySource    string        `json:"body_source"`
+	Original      replayOutcome `json:"original"`
+	Replay        replayOutcome `json:"replay"`
+	StatusChanged bool          `json:"status_changed"`
+	RuleChanged   bool          `json:"rule_changed"`
+}
+
+// replayRequest 按请求轨迹（方法、路径、查询串与原请求头）与对话归档还原一次代理请求，以原请求的身份按当前规则重新执行，
+// 返回原结果与新结果供对比。重放会真实请求上游并计入用量，重放本身记入审计日志。
+func (h *Handler) replayRequest(c *gin.Context) {
+	action := "requests.replay"
+	if h.traces == nil || h.replayer == nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "request replay unavailable")
+		return
+	}
+	var payload replayPayload
+	if err := c.ShouldBindJSON(&payload); err != nil && !errors.Is(err, io.EOF) {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	ctx := c.Request.Context()
+	requestID := c.Param("request_id")
+	trace, err := h.traces.Get(ctx, requestID)
+	if err != nil {
+		metrics.ObserveAdminAction(action, false)
+		if errors.Is(err, reqtrace.ErrNotFound) {
+			errcode.Respond(c, http.StatusNotFound, errcode.NotFound, err.Error())
+			return
+		}
+		h.logError(c, "get request trace failed", err, map[string]any{"user": currentAdminUser(c), "request_id": requestID})
+		errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, err.Error())
+		return
+	}
+
+	resp := replayResponse{RequestID: requestID, BodySource: replayBodyNone, Original: traceOutcome(&trace)}
+	var body []byte
+	switch {
+	case payload.Body != nil:
+		body = []byte(*payload.Body)
+		resp.BodySource = replayBodyRequest
+	case h.conversations != nil:
+		conv, err := h.conversations.GetByRequestID(ctx, requestID)
+		if err != nil && !errors.Is(err, archive.ErrNotFound) {
+			metrics.ObserveAdminAction(action, false)
+			h.logError(c, "get archived request failed", err, map[string]any{"user": currentAdminUser(c), "request_id": requestID})
+			errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, err.Error())
+			return
+		}
+		if err == nil {
+			if conv.RequestTruncated {
+				metrics.ObserveAdminAction(action, false)
+				errcode.Respond(c, http.StatusConflict, errcode.Conflict, "archived request body is truncated, supply body to replay")
+				return
+			}
+			body = []byte(conv.Request)
+			resp.BodySource = replayBodyArchive
+			resp.Original.Response = conv.Response
+			resp.Original.Truncated = conv.Truncated
+		}
+	}
+	// 原请求带有请求体却没有可用的归档时不重放，避免向上游发送空请求体。
+	if trace.HasBody && len(body) == 0 && payload.Body == nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusConflict, errcode.Conflict, "archived request body unavailable, supply body to replay")
+		return
+	}
+
+	result, err := h.replayer.Replay(ctx, proxy.ReplayRequest{
+		Method:   trace.Method,
+		Path:     replayPath(trace.Path, trace.Query),
+		Header:   replayHeader(trace.RequestHeaders, payload.Headers),
+		Body:     body,
+		UserID:   trace.UserID,
+		APIKeyID: trace.APIKeyID,
+		ReplayOf: requestID,
+	})
+	if err != nil {
+		metrics.ObserveAdminAction(action, false)
+		h.logError(c, "replay request failed", err, map[string]any{"user": currentAdminUser(c), "request_id": requestID})
+		errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, err.Error())
+		return
+	}
+	resp.Replay = traceOutcome(result.Trace)
+	resp.Replay.RequestID = result.RequestID
+	resp.Replay.Status = result.Status
+	resp.Replay.DurationMs = result.DurationMs
+	resp.Replay.Response = string(result.Body)
+	resp.Replay.Truncated = result.Truncated
+	resp.StatusChanged = resp.Original.Status != resp.Replay.Status
+	resp.RuleChanged = !sameRule(resp.Original.Rule, resp.Replay.Rule)
+
+	h.recordAudit(c, action, auditResourceRequest, requestID, nil, map[string]any{
+		"replay_request_id": result.RequestID,
+		"body_source":       resp.BodySource,
+		"status":            result.Status,
+	})
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, resp)
+}
+
+// replayPath 拼接原请求的路径与查询串，轨迹中已脱敏的参数不再发送。
+func replayPath(path, query string) string {
+	if query == "" {
+		return path
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return path
+	}
+	redacted := false
+	for name, list := range values {
+		if slices.Contains(list, reqtrace.Redacted) {
+			delete(values, name)
+			redacted = true
+		}
+	}
+	if redacted {
+		query = values.Encode()
+	}
+	if query == "" {
+		return path
+	}
+	return path + "?" + query
+}
+
+// replayHeader 以轨迹中的原请求头为基础构造重放请求头：已脱敏的取值与 Content-Length 不再发送，
+// 管理员提供的请求头覆盖同名的原请求头。
+func replayHeader(recorded http.Header, overrides map[string]string) http.Header {
+	header := make(http.Header, len(recorded)+len(overrides))
+	for name, values := range recorded {
+		if strings.EqualFold(name, "Content-Length") || slices.Contains(values, reqtrace.Redacted) {
+			continue
+		}
+		header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+	for name, value := range overrides {
+		header.Set(name, value)
+	}
+	return header
+}
+
+// traceOutcome 从请求轨迹提取执行结果，trace 为 nil 时返回零值。
+func traceOutcome(trace *reqtrace.Trace) replayOutcome {
+	if trace == nil {
+		return replayOutcome{}
+	}
+	return replayOutcome{
+		RequestID:  trace.RequestID,
+		Status:     trace.Status,
+		Rule:       trace.Rule,
+		Actions:    trace.Actions,
+		Attempts:   trace.Attempts,
+		Error:      trace.Error,
+		DurationMs: trace.DurationMs,
+	}
+}
+
+// sameRule 判断两次执行是否命中同一规则的同一版本。
+func sameRule(a, b *reqtrace.RuleMatch) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.ID == b.ID && a.Version == b.Version
+}
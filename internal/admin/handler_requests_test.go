@@ -0,0 +1,155 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/archive"
+	"github.com/prehisle/yapi/internal/audit"
+	"github.com/prehisle/yapi/internal/proxy"
+	"github.com/prehisle/yapi/internal/reqtrace"
+)
+
+func TestHandler_GetRequestTrace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth), auth.Middleware())
+	rec := doAdminRequest(router, http.MethodGet, "/admin/requests/req-1", "", basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+
+	store := reqtrace.NewMemoryStore(10)
+	require.NoError(t, store.Save(context.Background(), reqtrace.Trace{
+		RequestID: "req-1",
+		Rule:      &reqtrace.RuleMatch{ID: "rule-a", Priority: 5},
+		Attempts:  []reqtrace.Attempt{{Target: "https://api.example.com", Status: http.StatusOK}},
+		Status:    http.StatusOK,
+	}))
+	router = gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth, WithTraceStore(store)), auth.Middleware())
+
+	rec = doAdminRequest(router, http.MethodGet, "/admin/requests/req-1", "", basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var trace reqtrace.Trace
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &trace))
+	require.Equal(t, "rule-a", trace.Rule.ID)
+	require.Len(t, trace.Attempts, 1)
+
+	rec = doAdminRequest(router, http.MethodGet, "/admin/requests/missing", "", basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+type replayerStub struct {
+	requests []proxy.ReplayRequest
+	result   proxy.ReplayResult
+}
+
+func (s *replayerStub) Replay(ctx context.Context, req proxy.ReplayRequest) (proxy.ReplayResult, error) {
+	s.requests = append(s.requests, req)
+	return s.result, nil
+}
+
+func TestHandler_ReplayRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	store := reqtrace.NewMemoryStore(10)
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth, WithTraceStore(store)), auth.Middleware())
+	rec := doAdminRequest(router, http.MethodPost, "/admin/requests/req-1/replay", "", basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+
+	ctx := context.Background()
+	require.NoError(t, store.Save(ctx, reqtrace.Trace{
+		RequestID: "req-1",
+		Method:    http.MethodPost,
+		Path:      "/v1/chat/completions",
+		Query:     "key=%5BREDACTED%5D&stream=true",
+		RequestHeaders: http.Header{
+			"Authorization":  {reqtrace.Redacted},
+			"Content-Length": {"18"},
+			"X-Client":       {"sdk"},
+			"X-Debug":        {"0"},
+		},
+		UserID:   "user-1",
+		APIKeyID: "key-1",
+		Rule:     &reqtrace.RuleMatch{ID: "rule-a", Priority: 5, Version: 1},
+		Status:   http.StatusOK,
+	}))
+	conversations, err := archive.New(archive.NewMemoryStore(), bytes.Repeat([]byte{3}, 32), time.Hour, nil)
+	require.NoError(t, err)
+	require.NoError(t, conversations.Record(ctx, archive.Conversation{
+		RequestID: "req-1", Request: `{"model":"gpt-4o"}`, Response: `{"id":"original"}`, CreatedAt: time.Now().UTC(),
+	}, time.Hour))
+	replayer := &replayerStub{result: proxy.ReplayResult{
+		RequestID:  "req-2",
+		Status:     http.StatusForbidden,
+		Body:       []byte(`{"code":"YAPI_MODEL_NOT_ALLOWED"}`),
+		DurationMs: 3,
+		Trace:      &reqtrace.Trace{RequestID: "req-2", Rule: &reqtrace.RuleMatch{ID: "rule-a", Priority: 5, Version: 2}, Error: "model not allowed"},
+	}}
+	auditStore := audit.NewMemoryStore()
+	router = gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth,
+		WithTraceStore(store), WithConversationArchive(conversations), WithRequestReplayer(replayer), WithAuditStore(auditStore)), auth.Middleware())
+
+	rec = doAdminRequest(router, http.MethodPost, "/admin/requests/req-1/replay", `{"headers":{"X-Debug":"1"}}`, basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp replayResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, replayBodyArchive, resp.BodySource)
+	require.Equal(t, `{"id":"original"}`, resp.Original.Response)
+	require.Equal(t, http.StatusOK, resp.Original.Status)
+	require.Equal(t, "req-2", resp.Replay.RequestID)
+	require.Equal(t, http.StatusForbidden, resp.Replay.Status)
+	require.Equal(t, "model not allowed", resp.Replay.Error)
+	require.True(t, resp.StatusChanged)
+	require.True(t, resp.RuleChanged)
+
+	require.Len(t, replayer.requests, 1)
+	sent := replayer.requests[0]
+	require.Equal(t, http.MethodPost, sent.Method)
+	require.Equal(t, "/v1/chat/completions?stream=true", sent.Path)
+	require.Equal(t, `{"model":"gpt-4o"}`, string(sent.Body))
+	require.Equal(t, []string{"1"}, sent.Header.Values("X-Debug"))
+	require.Equal(t, "sdk", sent.Header.Get("X-Client"))
+	require.NotContains(t, sent.Header, "Authorization")
+	require.NotContains(t, sent.Header, "Content-Length")
+	require.Equal(t, "user-1", sent.UserID)
+	require.Equal(t, "key-1", sent.APIKeyID)
+	require.Equal(t, "req-1", sent.ReplayOf)
+
+	entries, _, err := auditStore.List(ctx, audit.Filter{ResourceType: auditResourceRequest})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "requests.replay", entries[0].Action)
+
+	rec = doAdminRequest(router, http.MethodPost, "/admin/requests/req-1/replay", `{"body":"{}"}`, basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, replayBodyRequest, resp.BodySource)
+	require.Equal(t, "{}", string(replayer.requests[1].Body))
+
+	rec = doAdminRequest(router, http.MethodPost, "/admin/requests/missing/replay", "", basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	// 原请求带有请求体但未归档，或归档的请求体已截断时，只有管理员提供 body 才重放。
+	require.NoError(t, store.Save(ctx, reqtrace.Trace{RequestID: "req-unarchived", Method: http.MethodPost, Path: "/v1/chat/completions", HasBody: true}))
+	require.NoError(t, store.Save(ctx, reqtrace.Trace{RequestID: "req-truncated", Method: http.MethodPost, Path: "/v1/chat/completions", HasBody: true}))
+	require.NoError(t, conversations.Record(ctx, archive.Conversation{
+		RequestID: "req-truncated", Request: `{"model":"gpt-4o","mess`, Truncated: true, RequestTruncated: true, CreatedAt: time.Now().UTC(),
+	}, time.Hour))
+	for _, id := range []string{"req-unarchived", "req-truncated"} {
+		rec = doAdminRequest(router, http.MethodPost, "/admin/requests/"+id+"/replay", "", basicAuth("admin", "secret"))
+		require.Equal(t, http.StatusConflict, rec.Code, id)
+		rec = doAdminRequest(router, http.MethodPost, "/admin/requests/"+id+"/replay", `{"body":"{\"model\":\"gpt-4o\"}"}`, basicAuth("admin", "secret"))
+		require.Equal(t, http.StatusOK, rec.Code, id)
+	}
+	require.Len(t, replayer.requests, 4)
+}
@@ -0,0 +1,326 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/pkg/metrics"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// ruleDetailResponse 在规则本身之外附带命中统计、最近修改信息与修改时 If-Match 使用的 ETag。
+type ruleDetailResponse struct {
+	rules.Rule
+	ETag         string                   `json:"etag"`
+	Stats        ruleStatsResponse        `json:"stats"`
+	LastModified ruleLastModifiedResponse `json:"last_modified"`
+}
+
+// ruleStatsResponse 为当前实例自启动以来的命中统计，多实例部署时各实例独立计数。
+type ruleStatsResponse struct {
+	Matches       uint64     `json:"matches"`
+	LastMatchedAt *time.Time `json:"last_matched_at,omitempty"`
+}
+
+type ruleLastModifiedResponse struct {
+	At      *time.Time `json:"at,omitempty"`
+	By      string     `json:"by,omitempty"`
+	Version int        `json:"version"`
+}
+
+func toRuleDetailResponse(rule rules.Rule) ruleDetailResponse {
+	resp := ruleDetailResponse{
+		Rule: rule,
+		ETag: ruleETag(rule),
+		LastModified: ruleLastModifiedResponse{
+			By:      rule.UpdatedBy,
+			Version: rule.Version,
+		},
+	}
+	if !rule.UpdatedAt.IsZero() {
+		updatedAt := rule.UpdatedAt
+		resp.LastModified.At = &updatedAt
+	}
+	stat := metrics.RuleMatchStats(rule.ID)
+	resp.Stats.Matches = stat.Matches
+	if !stat.LastMatchedAt.IsZero() {
+		resp.Stats.LastMatchedAt = &stat.LastMatchedAt
+	}
+	return resp
+}
+
+func (h *Handler) getRule(c *gin.Context) {
+	action := "rules.get"
+	id := c.Param("id")
+	if id == "" {
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "id is required")
+		return
+	}
+	rule, err := h.service.GetRule(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, rules.ErrRuleNotFound) {
+			status = http.StatusNotFound
+		} else {
+			h.logError(c, "get rule failed", err, map[string]any{
+				"user": currentAdminUser(c),
+				"rule": id,
+			})
+		}
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, status, errcode.ForStatus(status), err.Error())
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.Header("ETag", ruleETag(rule))
+	c.JSON(http.StatusOK, toRuleDetailResponse(rule))
+}
+
+func (h *Handler) patchRule(c *gin.Context) {
+	action := "rules.patch"
+	id := c.Param("id")
+	if id == "" {
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "id is required")
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "read request body failed")
+		return
+	}
+	var patch map[string]any
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "patch must be a JSON object")
+		return
+	}
+	ctx := c.Request.Context()
+	current, err := h.service.GetRule(ctx, id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, rules.ErrRuleNotFound) {
+			status = http.StatusNotFound
+		}
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, status, errcode.ForStatus(status), err.Error())
+		return
+	}
+	if !h.matchRuleETag(c, action, current) {
+		return
+	}
+	merged, err := mergeRulePatch(current, patch)
+	if err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	merged.ID = id
+	merged.UpdatedBy = currentAdminUser(c)
+	// 合并结果基于上面读取的规则，始终以其版本号为条件写入，读取后规则被他人修改时返回 412。
+	if err := h.saveRule(ctx, merged, rulePrecondition{conditional: true, version: current.Version}); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, rules.ErrInvalidRule) {
+			status = http.StatusBadRequest
+		}
+		if errors.Is(err, rules.ErrVersionConflict) {
+			status = http.StatusPreconditionFailed
+		}
+		h.logError(c, "patch rule failed", err, map[string]any{
+			"user": currentAdminUser(c),
+			"rule": id,
+		})
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, status, errcode.ForStatus(status), err.Error())
+		return
+	}
+	saved, err := h.service.GetRule(ctx, id)
+	if err != nil {
+		saved = merged
+	}
+	h.logInfo(c, "rule patched", map[string]any{
+		"user": currentAdminUser(c),
+		"rule": id,
+	})
+	h.recordAudit(c, action, auditResourceRule, id, current, saved)
+	metrics.ObserveAdminAction(action, true)
+	c.Header("ETag", ruleETag(saved))
+	c.JSON(http.StatusOK, toRuleDetailResponse(saved))
+}
+
+// mergeRulePatch 按 JSON Merge Patch（RFC 7396）语义将 patch 合并到规则上。
+func mergeRulePatch(rule rules.Rule, patch map[string]any) (rules.Rule, error) {
+	raw, err := json.Marshal(rule)
+	if err != nil {
+		return rules.Rule{}, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return rules.Rule{}, err
+	}
+	merged, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return rules.Rule{}, err
+	}
+	var out rules.Rule
+	if err := json.Unmarshal(merged, &out); err != nil {
+		return rules.Rule{}, fmt.Errorf("invalid patch: %w", err)
+	}
+	return out, nil
+}
+
+// mergePatch 实现 RFC 7396：对象逐键递归合并，null 删除键，其余值整体替换。
+func mergePatch(target any, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = make(map[string]any, len(patchObj))
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
+
+func (h *Handler) enableRule(c *gin.Context) {
+	h.setRuleEnabled(c, true)
+}
+
+func (h *Handler) disableRule(c *gin.Context) {
+	h.setRuleEnabled(c, false)
+}
+
+// setRuleEnabled 幂等地切换规则启用状态：先按 If-Match 校验版本，状态未变化时直接返回，
+// 变化时记录操作人并以读取到的版本号为条件保存，经 UpsertRuleIfVersion 广播规则变更事件。
+func (h *Handler) setRuleEnabled(c *gin.Context, enabled bool) {
+	action := "rules.disable"
+	if enabled {
+		action = "rules.enable"
+	}
+	id := c.Param("id")
+	if id == "" {
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "id is required")
+		return
+	}
+	ctx := c.Request.Context()
+	rule, err := h.service.GetRule(ctx, id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, rules.ErrRuleNotFound) {
+			status = http.StatusNotFound
+		}
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, status, errcode.ForStatus(status), err.Error())
+		return
+	}
+	if !h.matchRuleETag(c, action, rule) {
+		return
+	}
+	if rule.Enabled == enabled {
+		metrics.ObserveAdminAction(action, true)
+		c.Header("ETag", ruleETag(rule))
+		c.JSON(http.StatusOK, toRuleDetailResponse(rule))
+		return
+	}
+	before := rule
+	rule.Enabled = enabled
+	rule.UpdatedBy = currentAdminUser(c)
+	if err := h.saveRule(ctx, rule, rulePrecondition{conditional: true, version: before.Version}); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, rules.ErrInvalidRule) {
+			status = http.StatusBadRequest
+		}
+		if errors.Is(err, rules.ErrVersionConflict) {
+			status = http.StatusPreconditionFailed
+		}
+		h.logError(c, "toggle rule failed", err, map[string]any{
+			"user":    currentAdminUser(c),
+			"rule":    id,
+			"enabled": enabled,
+		})
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, status, errcode.ForStatus(status), err.Error())
+		return
+	}
+	if saved, err := h.service.GetRule(ctx, id); err == nil {
+		rule = saved
+	}
+	h.logInfo(c, "rule toggled", map[string]any{
+		"user":    currentAdminUser(c),
+		"rule":    id,
+		"enabled": enabled,
+	})
+	h.recordAudit(c, action, auditResourceRule, id, before, rule)
+	metrics.ObserveAdminAction(action, true)
+	c.Header("ETag", ruleETag(rule))
+	c.JSON(http.StatusOK, toRuleDetailResponse(rule))
+}
+
+// diffRules 比较候选规则集与当前规则（或请求中给出的 base），返回新增、删除与字段级变更，不做任何修改。
+func (h *Handler) diffRules(c *gin.Context) {
+	action := "rules.diff"
+	var req RulesDiffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	diff, err := h.service.DiffRules(c.Request.Context(), req)
+	if err != nil {
+		metrics.ObserveAdminAction(action, false)
+		if errors.Is(err, ErrInvalidRuleSet) {
+			errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+			return
+		}
+		h.logError(c, "diff rules failed", err, map[string]any{"user": currentAdminUser(c)})
+		errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, err.Error())
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, diff)
+}
+
+// explainRule 用样例请求逐条评估规则的匹配条件，说明规则为何命中或未命中，不做任何修改。
+func (h *Handler) explainRule(c *gin.Context) {
+	action := "rules.explain"
+	id := c.Param("id")
+	var req RuleExplainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	explanation, err := h.service.ExplainRule(c.Request.Context(), id, req)
+	if err != nil {
+		metrics.ObserveAdminAction(action, false)
+		switch {
+		case errors.Is(err, ErrInvalidExplainRequest):
+			errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		case errors.Is(err, rules.ErrRuleNotFound):
+			errcode.Respond(c, http.StatusNotFound, errcode.ForStatus(http.StatusNotFound), err.Error())
+		default:
+			h.logError(c, "explain rule failed", err, map[string]any{
+				"user": currentAdminUser(c),
+				"rule": id,
+			})
+			errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, err.Error())
+		}
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, explanation)
+}
@@ -0,0 +1,129 @@
+package admin
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/features"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// WithLogLevel 设置可在运行时调整的日志级别，未设置时日志级别接口返回 501。
+func WithLogLevel(level *slog.LevelVar) Option {
+	return func(h *Handler) {
+		h.logLevel = level
+	}
+}
+
+// WithFeatureFlags 设置运行时功能开关注册表，未设置时功能开关接口返回 501。
+func WithFeatureFlags(registry *features.Registry) Option {
+	return func(h *Handler) {
+		h.features = registry
+	}
+}
+
+type logLevelRequest struct {
+	Level string `json:"level" binding:"required"`
+}
+
+type logLevelResponse struct {
+	Level string `json:"level"`
+}
+
+type featureFlagRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+func formatLogLevel(level slog.Level) logLevelResponse {
+	return logLevelResponse{Level: strings.ToLower(level.String())}
+}
+
+// getLogLevel 返回本实例当前的日志级别。
+func (h *Handler) getLogLevel(c *gin.Context) {
+	action := "loglevel.get"
+	if h.logLevel == nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "runtime log level unavailable")
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, formatLogLevel(h.logLevel.Level()))
+}
+
+// setLogLevel 调整本实例的日志级别，取值为 debug、info、warn 或 error，立即生效且重启后恢复。
+func (h *Handler) setLogLevel(c *gin.Context) {
+	action := "loglevel.update"
+	if h.logLevel == nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "runtime log level unavailable")
+		return
+	}
+	var req logLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(req.Level))); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "invalid log level: want debug, info, warn or error")
+		return
+	}
+	before := formatLogLevel(h.logLevel.Level())
+	h.logLevel.Set(level)
+	after := formatLogLevel(level)
+	h.logInfo(c, "log level updated", map[string]any{"user": currentAdminUser(c), "from": before.Level, "to": after.Level})
+	h.recordAudit(c, action, auditResourceLogLevel, "", before, after)
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, after)
+}
+
+// listFeatureFlags 列出本实例全部功能开关及其当前状态。
+func (h *Handler) listFeatureFlags(c *gin.Context) {
+	action := "features.list"
+	if h.features == nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "feature flags unavailable")
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, gin.H{"items": h.features.List()})
+}
+
+// setFeatureFlag 开启或关闭本实例的功能开关，立即生效且重启后恢复。
+func (h *Handler) setFeatureFlag(c *gin.Context) {
+	action := "features.update"
+	if h.features == nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "feature flags unavailable")
+		return
+	}
+	name := c.Param("name")
+	var req featureFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	before, err := h.features.Get(name)
+	if err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusNotFound, errcode.NotFound, err.Error())
+		return
+	}
+	after, err := h.features.Set(name, *req.Enabled)
+	if err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusNotFound, errcode.NotFound, err.Error())
+		return
+	}
+	h.logInfo(c, "feature flag updated", map[string]any{"user": currentAdminUser(c), "flag": name, "enabled": after.Enabled})
+	h.recordAudit(c, action, auditResourceFeature, name, before, after)
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, after)
+}
@@ -0,0 +1,142 @@
+package admin
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/audit"
+	"github.com/prehisle/yapi/internal/features"
+	"github.com/prehisle/yapi/internal/observability"
+	"github.com/prehisle/yapi/internal/reload"
+	"github.com/prehisle/yapi/pkg/config"
+)
+
+func TestHandler_RuntimeLogLevelAndFeatureFlags(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	level := new(slog.LevelVar)
+	registry := features.NewRegistry()
+	toggle := registry.Register(features.RequestTraces, "record request traces", true)
+	auditStore := audit.NewMemoryStore()
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth,
+		WithLogLevel(level), WithFeatureFlags(registry), WithAuditStore(auditStore)), auth.Middleware())
+	owner := basicAuth("admin", "secret")
+
+	rec := doAdminRequest(router, http.MethodGet, "/admin/loglevel", "", owner)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"level":"info"}`, rec.Body.String())
+	rec = doAdminRequest(router, http.MethodPut, "/admin/loglevel", `{"level":"DEBUG"}`, owner)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"level":"debug"}`, rec.Body.String())
+	require.Equal(t, slog.LevelDebug, level.Level())
+	rec = doAdminRequest(router, http.MethodPut, "/admin/loglevel", `{"level":"verbose"}`, owner)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, slog.LevelDebug, level.Level())
+
+	rec = doAdminRequest(router, http.MethodPut, "/admin/features/request_traces", `{"enabled":false}`, owner)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var flag features.Flag
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &flag))
+	require.False(t, flag.Enabled)
+	require.True(t, flag.Default)
+	require.False(t, toggle.Enabled())
+	rec = doAdminRequest(router, http.MethodPut, "/admin/features/missing", `{"enabled":true}`, owner)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	rec = doAdminRequest(router, http.MethodPut, "/admin/features/request_traces", `{}`, owner)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doAdminRequest(router, http.MethodGet, "/admin/features", "", owner)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list struct {
+		Items []features.Flag `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Equal(t, []features.Flag{{Name: features.RequestTraces, Description: "record request traces", Default: true}}, list.Items)
+
+	entries, total, err := auditStore.List(t.Context(), audit.Filter{})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.Equal(t, "features.update", entries[0].Action)
+	require.Equal(t, "loglevel.update", entries[1].Action)
+}
+
+func TestHandler_ObservabilityDashboards(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth), auth.Middleware())
+
+	rec := doAdminRequest(router, http.MethodGet, "/admin/observability/dashboards", "", nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = doAdminRequest(router, http.MethodGet, "/admin/observability/dashboards", "", basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var bundle observability.Bundle
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &bundle))
+	require.Len(t, bundle.Dashboards, 2)
+	require.Equal(t, "yapi-overview", bundle.Dashboards[0].UID)
+	require.Contains(t, bundle.Dashboards[0].Panels[0].Targets[0].Expr, "gateway_http_requests_total")
+	require.NotEmpty(t, bundle.AlertRules.Groups)
+}
+
+type reloaderStub struct {
+	current config.Config
+	next    config.Config
+	err     error
+}
+
+func (r *reloaderStub) Reload() (reload.Result, error) {
+	if r.err != nil {
+		return reload.Result{}, r.err
+	}
+	r.current = r.next
+	return reload.Result{Changed: []string{"LOG_LEVEL"}, Applied: []string{"log_level"}, RestartRequired: []string{}}, nil
+}
+
+func (r *reloaderStub) Current() config.Config {
+	return r.current
+}
+
+func TestHandler_ReloadConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	reloader := &reloaderStub{current: config.Config{LogLevel: "info"}, next: config.Config{LogLevel: "debug"}}
+	auditStore := audit.NewMemoryStore()
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth,
+		WithConfigReloader(reloader), WithRuntimeConfig(RuntimeConfig{}), WithAuditStore(auditStore)), auth.Middleware())
+	owner := basicAuth("admin", "secret")
+
+	rec := doAdminRequest(router, http.MethodPost, "/admin/config/reload", "", nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = doAdminRequest(router, http.MethodPost, "/admin/config/reload", "", owner)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"changed":["LOG_LEVEL"],"applied":["log_level"],"restart_required":[]}`, rec.Body.String())
+
+	rec = doAdminRequest(router, http.MethodGet, "/admin/config", "", owner)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var runtime RuntimeConfig
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &runtime))
+	require.Equal(t, "debug", runtime.Settings["LOG_LEVEL"])
+
+	reloader.err = config.ErrInvalidConfigFile
+	rec = doAdminRequest(router, http.MethodPost, "/admin/config/reload", "", owner)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	reloader.err = reload.ErrNoConfigFile
+	rec = doAdminRequest(router, http.MethodPost, "/admin/config/reload", "", owner)
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	entries, total, err := auditStore.List(t.Context(), audit.Filter{})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	require.Equal(t, "config.reload", entries[0].Action)
+	require.Equal(t, "config", entries[0].ResourceType)
+}
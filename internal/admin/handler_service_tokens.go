@@ -0,0 +1,174 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/servicetokens"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// WithServiceTokens 设置服务令牌存储，未设置时服务令牌接口返回 501。
+func WithServiceTokens(tokens servicetokens.Service) Option {
+	return func(h *Handler) {
+		h.serviceTokens = tokens
+	}
+}
+
+// serviceTokenResponse 是服务令牌的对外表示，不包含明文与摘要。
+type serviceTokenResponse struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Prefix      string       `json:"prefix"`
+	Permissions []Permission `json:"permissions"`
+	CreatedBy   string       `json:"created_by,omitempty"`
+	ExpiresAt   *time.Time   `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time   `json:"last_used_at,omitempty"`
+	RevokedAt   *time.Time   `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// createServiceTokenResponse 额外携带令牌明文，仅在创建时返回一次。
+type createServiceTokenResponse struct {
+	serviceTokenResponse
+	Token string `json:"token"`
+}
+
+func toServiceTokenResponse(token servicetokens.Token) serviceTokenResponse {
+	perms := make([]Permission, 0, len(token.Permissions))
+	for _, value := range token.Permissions {
+		perms = append(perms, Permission(value))
+	}
+	return serviceTokenResponse{
+		ID:          token.ID,
+		Name:        token.Name,
+		Prefix:      token.Prefix,
+		Permissions: perms,
+		CreatedBy:   token.CreatedBy,
+		ExpiresAt:   token.ExpiresAt,
+		LastUsedAt:  token.LastUsedAt,
+		RevokedAt:   token.RevokedAt,
+		CreatedAt:   token.CreatedAt,
+	}
+}
+
+type createServiceTokenRequest struct {
+	Name        string     `json:"name" binding:"required"`
+	Permissions []string   `json:"permissions" binding:"required"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+func (h *Handler) listServiceTokens(c *gin.Context) {
+	action := "service_tokens.list"
+	if !h.serviceTokensAvailable(c, action) {
+		return
+	}
+	tokens, err := h.serviceTokens.List(c.Request.Context())
+	if err != nil {
+		h.handleServiceTokensError(c, action, "list service tokens failed", err)
+		return
+	}
+	items := make([]serviceTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		items = append(items, toServiceTokenResponse(token))
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// createServiceToken 创建服务令牌，权限范围不得超出调用者自身的有效权限。
+func (h *Handler) createServiceToken(c *gin.Context) {
+	action := "service_tokens.create"
+	if !h.serviceTokensAvailable(c, action) {
+		return
+	}
+	var req createServiceTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	perms, err := ParsePermissions(req.Permissions)
+	if err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	if perm, missing := ungrantedPermission(c, perms); missing {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusForbidden, errcode.PermissionDenied, fmt.Sprintf("forbidden: cannot grant permission %s", perm))
+		return
+	}
+	values := make([]string, 0, len(perms))
+	for _, perm := range perms {
+		values = append(values, string(perm))
+	}
+	token, plaintext, err := h.serviceTokens.Create(c.Request.Context(), servicetokens.CreateParams{
+		Name:        req.Name,
+		Permissions: values,
+		ExpiresAt:   req.ExpiresAt,
+		CreatedBy:   currentAdminUser(c),
+	})
+	if err != nil {
+		h.handleServiceTokensError(c, action, "create service token failed", err)
+		return
+	}
+	resp := toServiceTokenResponse(token)
+	h.logInfo(c, "service token created", map[string]any{"user": currentAdminUser(c), "service_token": token.Name, "prefix": token.Prefix})
+	h.recordAudit(c, action, auditResourceSvcToken, token.ID, nil, resp)
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusCreated, createServiceTokenResponse{serviceTokenResponse: resp, Token: plaintext})
+}
+
+// revokeServiceToken 吊销服务令牌；记录保留以便审计，重复吊销是幂等的。
+func (h *Handler) revokeServiceToken(c *gin.Context) {
+	action := "service_tokens.revoke"
+	if !h.serviceTokensAvailable(c, action) {
+		return
+	}
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	before, err := h.serviceTokens.Get(ctx, id)
+	if err != nil {
+		h.handleServiceTokensError(c, action, "get service token failed", err)
+		return
+	}
+	token, err := h.serviceTokens.Revoke(ctx, id)
+	if err != nil {
+		h.handleServiceTokensError(c, action, "revoke service token failed", err)
+		return
+	}
+	h.logInfo(c, "service token revoked", map[string]any{"user": currentAdminUser(c), "service_token": token.Name, "prefix": token.Prefix})
+	h.recordAudit(c, action, auditResourceSvcToken, id, toServiceTokenResponse(before), toServiceTokenResponse(token))
+	metrics.ObserveAdminAction(action, true)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) serviceTokensAvailable(c *gin.Context, action string) bool {
+	if h.serviceTokens != nil {
+		return true
+	}
+	metrics.ObserveAdminAction(action, false)
+	errcode.Respond(c, http.StatusNotImplemented, errcode.NotImplemented, "service token store unavailable")
+	return false
+}
+
+// handleServiceTokensError 将服务令牌存储错误映射为 HTTP 状态码。
+func (h *Handler) handleServiceTokensError(c *gin.Context, action, msg string, err error) {
+	metrics.ObserveAdminAction(action, false)
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, servicetokens.ErrInvalidInput):
+		status = http.StatusBadRequest
+	case errors.Is(err, servicetokens.ErrNotFound):
+		status = http.StatusNotFound
+	default:
+		h.logError(c, msg, err, map[string]any{"user": currentAdminUser(c), "service_token_id": c.Param("id")})
+	}
+	errcode.Respond(c, status, errcode.ForStatus(status), err.Error())
+}
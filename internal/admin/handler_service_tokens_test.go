@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/prehisle/yapi/internal/adminusers"
+	"github.com/prehisle/yapi/internal/servicetokens"
+)
+
+func TestHandler_ServiceTokens_ScopedAndRevocable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file:admin_service_tokens?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	ctx := context.Background()
+	users := adminusers.NewService(db)
+	require.NoError(t, users.AutoMigrate(ctx))
+	tokens := servicetokens.NewService(db)
+	require.NoError(t, tokens.AutoMigrate(ctx))
+	_, err = users.Create(ctx, adminusers.CreateParams{Username: "editor", Password: "editor-pass", Role: adminusers.RoleEditor})
+	require.NoError(t, err)
+	auth := NewAuthenticator("root", "root-secret", "", time.Minute, WithUserStore(users), WithServiceTokenStore(tokens))
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth, WithServiceTokens(tokens)), auth.Middleware())
+
+	rec := doAdminRequest(router, http.MethodPost, "/admin/service-tokens", `{"name":"ci","permissions":["rules:read"]}`, basicAuth("editor", "editor-pass"))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doAdminRequest(router, http.MethodPost, "/admin/service-tokens", `{"name":"ci","permissions":["rules:delete"]}`, basicAuth("root", "root-secret"))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doAdminRequest(router, http.MethodPost, "/admin/service-tokens", `{"name":"ci","permissions":["rules:read","rules:write"]}`, basicAuth("root", "root-secret"))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var created createServiceTokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.NotEmpty(t, created.Token)
+	require.Equal(t, "root", created.CreatedBy)
+
+	// 服务令牌无需签发密钥，也不依赖管理员账号；权限仅限创建时的范围。
+	bearer := func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+created.Token) }
+	rec = doAdminRequest(router, http.MethodDelete, "/admin/rules/r1", "", bearer)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = doAdminRequest(router, http.MethodGet, "/admin/users", "", bearer)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = doAdminRequest(router, http.MethodGet, "/admin/service-tokens", "", basicAuth("root", "root-secret"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), created.Token)
+
+	rec = doAdminRequest(router, http.MethodDelete, "/admin/service-tokens/"+created.ID, "", basicAuth("root", "root-secret"))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = doAdminRequest(router, http.MethodGet, "/admin/rules", "", bearer)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = doAdminRequest(router, http.MethodDelete, "/admin/service-tokens/missing", "", basicAuth("root", "root-secret"))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
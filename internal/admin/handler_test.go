@@ -1,12 +1,14 @@
 package admin
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,26 +16,53 @@ import (
 	"github.com/stretchr/testify/require"
 	"gorm.io/datatypes"
 
+	"github.com/prehisle/yapi/internal/audit"
 	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/metrics"
 	"github.com/prehisle/yapi/pkg/rules"
 )
 
 type serviceStub struct {
+	planApplyFn      func(ctx context.Context, desired DesiredState) (ApplyPlan, error)
+	applyFn          func(ctx context.Context, desired DesiredState, opts ApplyOptions) (ApplyPlan, error)
+	backupFn         func(ctx context.Context, opts BackupOptions) (Backup, error)
+	restoreFn        func(ctx context.Context, backup Backup, opts RestoreOptions) (RestoreResult, error)
+	diffRulesFn      func(ctx context.Context, req RulesDiffRequest) (RulesDiff, error)
 	listFn           func(ctx context.Context) ([]rules.Rule, error)
+	getRuleFn        func(ctx context.Context, id string) (rules.Rule, error)
 	upsertFn         func(ctx context.Context, rule rules.Rule) error
 	deleteFn         func(ctx context.Context, id string) error
 	createUserFn     func(ctx context.Context, params accounts.CreateUserParams) (accounts.User, error)
-	listUsersFn      func(ctx context.Context) ([]accounts.User, error)
+	listUsersFn      func(ctx context.Context, opts accounts.ListOptions) ([]accounts.User, int64, error)
+	getUserDetailFn  func(ctx context.Context, id string) (UserDetail, error)
+	getUserFn        func(ctx context.Context, id string) (accounts.User, error)
+	userLimitsFn     func(ctx context.Context, id string, limits accounts.RateLimits) (accounts.User, error)
+	keyLimitsFn      func(ctx context.Context, apiKeyID string, limits accounts.RateLimits) (accounts.APIKey, error)
+	userModelsFn     func(ctx context.Context, id string, access accounts.ModelAccess) (accounts.User, error)
+	keyModelsFn      func(ctx context.Context, apiKeyID string, access accounts.ModelAccess) (accounts.APIKey, error)
 	deleteUserFn     func(ctx context.Context, id string) error
 	createAPIKeyFn   func(ctx context.Context, params accounts.CreateAPIKeyParams) (accounts.APIKey, string, error)
-	listAPIKeysFn    func(ctx context.Context, userID string) ([]accounts.APIKey, error)
+	listAPIKeysFn    func(ctx context.Context, userID string, opts accounts.ListOptions) ([]accounts.APIKey, int64, error)
 	revokeAPIKeyFn   func(ctx context.Context, apiKeyID string) error
+	getAPIKeyFn      func(ctx context.Context, apiKeyID string) (accounts.APIKey, error)
+	setKeyEnabledFn  func(ctx context.Context, apiKeyID string, enabled bool) error
 	createUpstreamFn func(ctx context.Context, params accounts.CreateUpstreamCredentialParams) (accounts.UpstreamCredential, error)
 	updateUpstreamFn func(ctx context.Context, params accounts.UpdateUpstreamCredentialParams) (accounts.UpstreamCredential, error)
-	listUpstreamFn   func(ctx context.Context, userID string) ([]accounts.UpstreamCredential, error)
+	listUpstreamFn   func(ctx context.Context, userID string, opts accounts.ListOptions) ([]accounts.UpstreamCredential, int64, error)
 	deleteUpstreamFn func(ctx context.Context, credentialID string) error
+	getUpstreamFn    func(ctx context.Context, credentialID string) (accounts.UpstreamCredential, error)
+	setEnabledFn     func(ctx context.Context, credentialID string, enabled bool) error
+	verifyUpstreamFn func(ctx context.Context, credentialID string) (accounts.UpstreamHealth, error)
+	setDefaultFn     func(ctx context.Context, credentialID string, isDefault bool) (accounts.UpstreamCredential, error)
 	bindAPIKeyFn     func(ctx context.Context, params accounts.BindAPIKeyParams) (accounts.UserAPIKeyBinding, error)
 	getBindingFn     func(ctx context.Context, apiKeyID string) (accounts.UserAPIKeyBinding, accounts.UpstreamCredential, error)
+	listBindingsFn   func(ctx context.Context, apiKeyID string) ([]accounts.BindingWithUpstream, error)
+	userBindingsFn   func(ctx context.Context, userID string) ([]accounts.BindingWithUpstream, error)
+	deleteBindingFn  func(ctx context.Context, bindingID string) error
+	createPoolFn     func(ctx context.Context, params accounts.CreateUpstreamKeyPoolParams) (accounts.UpstreamKeyPool, error)
+	listPoolsFn      func(ctx context.Context, userID string) ([]accounts.UpstreamKeyPool, error)
+	updatePoolFn     func(ctx context.Context, params accounts.UpdateUpstreamKeyPoolParams) (accounts.UpstreamKeyPool, error)
+	deletePoolFn     func(ctx context.Context, poolID string) error
 }
 
 func (s *serviceStub) ListRules(ctx context.Context) ([]rules.Rule, error) {
@@ -44,6 +73,9 @@ func (s *serviceStub) ListRules(ctx context.Context) ([]rules.Rule, error) {
 }
 
 func (s *serviceStub) GetRule(ctx context.Context, id string) (rules.Rule, error) {
+	if s.getRuleFn != nil {
+		return s.getRuleFn(ctx, id)
+	}
 	return rules.Rule{}, nil
 }
 
@@ -61,6 +93,14 @@ func (s *serviceStub) DeleteRule(ctx context.Context, id string) error {
 	return nil
 }
 
+func (s *serviceStub) UpdateRuleIfVersion(ctx context.Context, rule rules.Rule, version int) error {
+	return s.CreateOrUpdateRule(ctx, rule)
+}
+
+func (s *serviceStub) DeleteRuleIfVersion(ctx context.Context, id string, version int) error {
+	return s.DeleteRule(ctx, id)
+}
+
 func (s *serviceStub) CreateUser(ctx context.Context, params accounts.CreateUserParams) (accounts.User, error) {
 	if s.createUserFn != nil {
 		return s.createUserFn(ctx, params)
@@ -68,11 +108,11 @@ func (s *serviceStub) CreateUser(ctx context.Context, params accounts.CreateUser
 	return accounts.User{}, ErrAccountsUnavailable
 }
 
-func (s *serviceStub) ListUsers(ctx context.Context) ([]accounts.User, error) {
+func (s *serviceStub) ListUsers(ctx context.Context, opts accounts.ListOptions) ([]accounts.User, int64, error) {
 	if s.listUsersFn != nil {
-		return s.listUsersFn(ctx)
+		return s.listUsersFn(ctx, opts)
 	}
-	return nil, ErrAccountsUnavailable
+	return nil, 0, ErrAccountsUnavailable
 }
 
 func (s *serviceStub) DeleteUser(ctx context.Context, id string) error {
@@ -89,11 +129,11 @@ func (s *serviceStub) CreateUserAPIKey(ctx context.Context, params accounts.Crea
 	return accounts.APIKey{}, "", ErrAccountsUnavailable
 }
 
-func (s *serviceStub) ListUserAPIKeys(ctx context.Context, userID string) ([]accounts.APIKey, error) {
+func (s *serviceStub) ListUserAPIKeys(ctx context.Context, userID string, opts accounts.ListOptions) ([]accounts.APIKey, int64, error) {
 	if s.listAPIKeysFn != nil {
-		return s.listAPIKeysFn(ctx, userID)
+		return s.listAPIKeysFn(ctx, userID, opts)
 	}
-	return nil, ErrAccountsUnavailable
+	return nil, 0, ErrAccountsUnavailable
 }
 
 func (s *serviceStub) RevokeUserAPIKey(ctx context.Context, apiKeyID string) error {
@@ -103,6 +143,101 @@ func (s *serviceStub) RevokeUserAPIKey(ctx context.Context, apiKeyID string) err
 	return ErrAccountsUnavailable
 }
 
+func (s *serviceStub) GetUser(ctx context.Context, id string) (accounts.User, error) {
+	if s.getUserFn != nil {
+		return s.getUserFn(ctx, id)
+	}
+	return accounts.User{}, ErrAccountsUnavailable
+}
+
+func (s *serviceStub) PlanApply(ctx context.Context, desired DesiredState) (ApplyPlan, error) {
+	if s.planApplyFn != nil {
+		return s.planApplyFn(ctx, desired)
+	}
+	return ApplyPlan{}, nil
+}
+
+func (s *serviceStub) Apply(ctx context.Context, desired DesiredState, opts ApplyOptions) (ApplyPlan, error) {
+	if s.applyFn != nil {
+		return s.applyFn(ctx, desired, opts)
+	}
+	return ApplyPlan{Applied: true}, nil
+}
+
+func (s *serviceStub) Backup(ctx context.Context, opts BackupOptions) (Backup, error) {
+	if s.backupFn != nil {
+		return s.backupFn(ctx, opts)
+	}
+	return Backup{Version: BackupVersion}, nil
+}
+
+func (s *serviceStub) Restore(ctx context.Context, backup Backup, opts RestoreOptions) (RestoreResult, error) {
+	if s.restoreFn != nil {
+		return s.restoreFn(ctx, backup, opts)
+	}
+	return RestoreResult{}, nil
+}
+
+func (s *serviceStub) DiffRules(ctx context.Context, req RulesDiffRequest) (RulesDiff, error) {
+	if s.diffRulesFn != nil {
+		return s.diffRulesFn(ctx, req)
+	}
+	return RulesDiff{}, nil
+}
+
+func (s *serviceStub) ExplainRule(ctx context.Context, id string, req RuleExplainRequest) (RuleExplanation, error) {
+	return RuleExplanation{}, nil
+}
+
+func (s *serviceStub) SetUserRateLimits(ctx context.Context, id string, limits accounts.RateLimits) (accounts.User, error) {
+	if s.userLimitsFn != nil {
+		return s.userLimitsFn(ctx, id, limits)
+	}
+	return accounts.User{}, ErrAccountsUnavailable
+}
+
+func (s *serviceStub) SetAPIKeyRateLimits(ctx context.Context, apiKeyID string, limits accounts.RateLimits) (accounts.APIKey, error) {
+	if s.keyLimitsFn != nil {
+		return s.keyLimitsFn(ctx, apiKeyID, limits)
+	}
+	return accounts.APIKey{}, ErrAccountsUnavailable
+}
+
+func (s *serviceStub) SetUserModelAccess(ctx context.Context, id string, access accounts.ModelAccess) (accounts.User, error) {
+	if s.userModelsFn != nil {
+		return s.userModelsFn(ctx, id, access)
+	}
+	return accounts.User{}, ErrAccountsUnavailable
+}
+
+func (s *serviceStub) SetAPIKeyModelAccess(ctx context.Context, apiKeyID string, access accounts.ModelAccess) (accounts.APIKey, error) {
+	if s.keyModelsFn != nil {
+		return s.keyModelsFn(ctx, apiKeyID, access)
+	}
+	return accounts.APIKey{}, ErrAccountsUnavailable
+}
+
+func (s *serviceStub) GetUserDetail(ctx context.Context, id string) (UserDetail, error) {
+	if s.getUserDetailFn != nil {
+		return s.getUserDetailFn(ctx, id)
+	}
+	return UserDetail{}, ErrAccountsUnavailable
+}
+
+func (s *serviceStub) GetUserAPIKey(ctx context.Context, apiKeyID string) (accounts.APIKey, error) {
+	if s.getAPIKeyFn != nil {
+		return s.getAPIKeyFn(ctx, apiKeyID)
+	}
+	return accounts.APIKey{}, ErrAccountsUnavailable
+}
+
+func (s *serviceStub) SetUserAPIKeyEnabled(ctx context.Context, apiKeyID string, enabled bool) error {
+	if s.setKeyEnabledFn != nil {
+		return s.setKeyEnabledFn(ctx, apiKeyID, enabled)
+	}
+	return ErrAccountsUnavailable
+}
+
 func (s *serviceStub) CreateUpstreamCredential(ctx context.Context, params accounts.CreateUpstreamCredentialParams) (accounts.UpstreamCredential, error) {
 	if s.createUpstreamFn != nil {
 		return s.createUpstreamFn(ctx, params)
@@ -117,11 +252,11 @@ func (s *serviceStub) UpdateUpstreamCredential(ctx context.Context, params accou
 	return accounts.UpstreamCredential{}, ErrAccountsUnavailable
 }
 
-func (s *serviceStub) ListUpstreamCredentials(ctx context.Context, userID string) ([]accounts.UpstreamCredential, error) {
+func (s *serviceStub) ListUpstreamCredentials(ctx context.Context, userID string, opts accounts.ListOptions) ([]accounts.UpstreamCredential, int64, error) {
 	if s.listUpstreamFn != nil {
-		return s.listUpstreamFn(ctx, userID)
+		return s.listUpstreamFn(ctx, userID, opts)
 	}
-	return nil, ErrAccountsUnavailable
+	return nil, 0, ErrAccountsUnavailable
 }
 
 func (s *serviceStub) DeleteUpstreamCredential(ctx context.Context, credentialID string) error {
@@ -131,6 +266,34 @@ func (s *serviceStub) DeleteUpstreamCredential(ctx context.Context, credentialID
 	return ErrAccountsUnavailable
 }
 
+func (s *serviceStub) GetUpstreamCredential(ctx context.Context, credentialID string) (accounts.UpstreamCredential, error) {
+	if s.getUpstreamFn != nil {
+		return s.getUpstreamFn(ctx, credentialID)
+	}
+	return accounts.UpstreamCredential{}, ErrAccountsUnavailable
+}
+
+func (s *serviceStub) SetUpstreamCredentialEnabled(ctx context.Context, credentialID string, enabled bool) error {
+	if s.setEnabledFn != nil {
+		return s.setEnabledFn(ctx, credentialID, enabled)
+	}
+	return ErrAccountsUnavailable
+}
+
+func (s *serviceStub) SetUpstreamCredentialDefault(ctx context.Context, credentialID string, isDefault bool) (accounts.UpstreamCredential, error) {
+	if s.setDefaultFn != nil {
+		return s.setDefaultFn(ctx, credentialID, isDefault)
+	}
+	return accounts.UpstreamCredential{}, ErrAccountsUnavailable
+}
+
+func (s *serviceStub) VerifyUpstreamCredential(ctx context.Context, credentialID string) (accounts.UpstreamHealth, error) {
+	if s.verifyUpstreamFn != nil {
+		return s.verifyUpstreamFn(ctx, credentialID)
+	}
+	return accounts.UpstreamHealth{}, ErrVerifierUnavailable
+}
+
 func (s *serviceStub) BindAPIKey(ctx context.Context, params accounts.BindAPIKeyParams) (accounts.UserAPIKeyBinding, error) {
 	if s.bindAPIKeyFn != nil {
 		return s.bindAPIKeyFn(ctx, params)
@@ -145,6 +308,55 @@ func (s *serviceStub) GetBindingByAPIKeyID(ctx context.Context, apiKeyID string)
 	return accounts.UserAPIKeyBinding{}, accounts.UpstreamCredential{}, ErrAccountsUnavailable
 }
 
+func (s *serviceStub) CreateUpstreamKeyPool(ctx context.Context, params accounts.CreateUpstreamKeyPoolParams) (accounts.UpstreamKeyPool, error) {
+	if s.createPoolFn != nil {
+		return s.createPoolFn(ctx, params)
+	}
+	return accounts.UpstreamKeyPool{}, ErrAccountsUnavailable
+}
+
+func (s *serviceStub) ListUpstreamKeyPools(ctx context.Context, userID string) ([]accounts.UpstreamKeyPool, error) {
+	if s.listPoolsFn != nil {
+		return s.listPoolsFn(ctx, userID)
+	}
+	return nil, ErrAccountsUnavailable
+}
+
+func (s *serviceStub) UpdateUpstreamKeyPool(ctx context.Context, params accounts.UpdateUpstreamKeyPoolParams) (accounts.UpstreamKeyPool, error) {
+	if s.updatePoolFn != nil {
+		return s.updatePoolFn(ctx, params)
+	}
+	return accounts.UpstreamKeyPool{}, ErrAccountsUnavailable
+}
+
+func (s *serviceStub) DeleteUpstreamKeyPool(ctx context.Context, poolID string) error {
+	if s.deletePoolFn != nil {
+		return s.deletePoolFn(ctx, poolID)
+	}
+	return ErrAccountsUnavailable
+}
+
+func (s *serviceStub) ListBindingsByAPIKey(ctx context.Context, apiKeyID string) ([]accounts.BindingWithUpstream, error) {
+	if s.listBindingsFn != nil {
+		return s.listBindingsFn(ctx, apiKeyID)
+	}
+	return nil, ErrAccountsUnavailable
+}
+
+func (s *serviceStub) ListBindingsByUser(ctx context.Context, userID string) ([]accounts.BindingWithUpstream, error) {
+	if s.userBindingsFn != nil {
+		return s.userBindingsFn(ctx, userID)
+	}
+	return nil, ErrAccountsUnavailable
+}
+
+func (s *serviceStub) DeleteBinding(ctx context.Context, bindingID string) error {
+	if s.deleteBindingFn != nil {
+		return s.deleteBindingFn(ctx, bindingID)
+	}
+	return ErrAccountsUnavailable
+}
+
 func TestHandler_ListRules(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	expected := []rules.Rule{{ID: "rule-1"}}
@@ -321,7 +533,7 @@ func TestHandler_WithAuth_Bearer(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	svc := &serviceStub{}
 	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
-	token, err := auth.IssueToken("admin", "secret")
+	token, err := auth.IssueToken(context.Background(), "admin", "secret")
 	require.NoError(t, err)
 	router := gin.New()
 	group := router.Group("/admin")
@@ -353,6 +565,48 @@ func TestHandler_Login_Success(t *testing.T) {
 	require.Contains(t, rec.Body.String(), "access_token")
 }
 
+func TestHandler_RefreshAndLogout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	handler := NewHandler(&serviceStub{}, auth)
+	router := gin.New()
+	Mount(router.Group("/admin"), handler, auth.Middleware())
+
+	post := func(path, body, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	var login tokenResponse
+	rec := post("/admin/login", `{"username":"admin","password":"secret"}`, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	require.NotEmpty(t, login.RefreshToken)
+
+	var refreshed tokenResponse
+	rec = post("/admin/token/refresh", `{"refresh_token":"`+login.RefreshToken+`"}`, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &refreshed))
+	rec = post("/admin/token/refresh", `{"refresh_token":"`+login.RefreshToken+`"}`, "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = post("/admin/logout", `{"refresh_token":"`+refreshed.RefreshToken+`"}`, refreshed.AccessToken)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/rules", nil)
+	req.Header.Set("Authorization", "Bearer "+refreshed.AccessToken)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = post("/admin/token/refresh", `{"refresh_token":"`+refreshed.RefreshToken+`"}`, "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
 func TestHandler_Login_Disabled(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	handler := NewHandler(&serviceStub{}, nil)
@@ -423,7 +677,7 @@ func TestHandler_ListUsers_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	now := time.Date(2025, time.November, 2, 11, 0, 0, 0, time.UTC)
 	svc := &serviceStub{
-		listUsersFn: func(ctx context.Context) ([]accounts.User, error) {
+		listUsersFn: func(ctx context.Context, opts accounts.ListOptions) ([]accounts.User, int64, error) {
 			return []accounts.User{
 				{
 					ID:        "user-1",
@@ -438,7 +692,7 @@ func TestHandler_ListUsers_Success(t *testing.T) {
 					CreatedAt: now,
 					UpdatedAt: now,
 				},
-			}, nil
+			}, 2, nil
 		},
 	}
 	router := newTestRouter(svc)
@@ -458,6 +712,34 @@ func TestHandler_ListUsers_Success(t *testing.T) {
 	require.Equal(t, "bob", resp.Items[1].Name)
 }
 
+func TestHandler_ListUsers_Pagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &serviceStub{
+		listUsersFn: func(ctx context.Context, opts accounts.ListOptions) ([]accounts.User, int64, error) {
+			require.Equal(t, accounts.ListOptions{Limit: 1000, Offset: 40, Search: "ali"}, opts)
+			return []accounts.User{{ID: "user-41", Name: "alice"}}, 41, nil
+		},
+	}
+	router := newTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/users?limit=5000&offset=40&q=ali", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp struct {
+		Items  []userResponse `json:"items"`
+		Total  int64          `json:"total"`
+		Limit  int            `json:"limit"`
+		Offset int            `json:"offset"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Items, 1)
+	require.EqualValues(t, 41, resp.Total)
+	require.Equal(t, 1000, resp.Limit)
+	require.Equal(t, 40, resp.Offset)
+}
+
 func TestHandler_CreateUserAPIKey_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	now := time.Date(2025, time.November, 2, 12, 0, 0, 0, time.UTC)
@@ -498,7 +780,7 @@ func TestHandler_ListUserAPIKeys_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	now := time.Date(2025, time.November, 2, 13, 0, 0, 0, time.UTC)
 	svc := &serviceStub{
-		listAPIKeysFn: func(ctx context.Context, userID string) ([]accounts.APIKey, error) {
+		listAPIKeysFn: func(ctx context.Context, userID string, opts accounts.ListOptions) ([]accounts.APIKey, int64, error) {
 			require.Equal(t, "user-1", userID)
 			return []accounts.APIKey{
 				{
@@ -517,7 +799,7 @@ func TestHandler_ListUserAPIKeys_Success(t *testing.T) {
 					CreatedAt: now,
 					UpdatedAt: now,
 				},
-			}, nil
+			}, 2, nil
 		},
 	}
 	router := newTestRouter(svc)
@@ -665,7 +947,7 @@ func TestHandler_ListUpstreamCredentials_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	now := time.Date(2025, time.November, 2, 15, 0, 0, 0, time.UTC)
 	svc := &serviceStub{
-		listUpstreamFn: func(ctx context.Context, userID string) ([]accounts.UpstreamCredential, error) {
+		listUpstreamFn: func(ctx context.Context, userID string, opts accounts.ListOptions) ([]accounts.UpstreamCredential, int64, error) {
 			require.Equal(t, "user-1", userID)
 			return []accounts.UpstreamCredential{
 				{
@@ -676,10 +958,12 @@ func TestHandler_ListUpstreamCredentials_Success(t *testing.T) {
 					APIKey:    "secret",
 					Endpoints: datatypes.JSON([]byte(`["https://chat.example.com","https://backup.example.com"]`)),
 					Metadata:  datatypes.JSONMap{"env": "prod"},
+					HealthStatus:    accounts.UpstreamHealthValid,
+					HealthCheckedAt: &now,
 					CreatedAt: now,
 					UpdatedAt: now,
 				},
-			}, nil
+			}, 1, nil
 		},
 	}
 	router := newTestRouter(svc)
@@ -700,6 +984,8 @@ func TestHandler_ListUpstreamCredentials_Success(t *testing.T) {
 	require.Equal(t, "primary", resp.Items[0].Name)
 	require.ElementsMatch(t, []string{"https://chat.example.com", "https://backup.example.com"}, resp.Items[0].Endpoints)
 	require.Equal(t, "prod", resp.Items[0].Metadata["env"])
+	require.NotNil(t, resp.Items[0].Health)
+	require.Equal(t, accounts.UpstreamHealthValid, resp.Items[0].Health.Status)
 }
 
 func TestHandler_DeleteUpstreamCredential_Success(t *testing.T) {
@@ -722,6 +1008,270 @@ func TestHandler_DeleteUpstreamCredential_Success(t *testing.T) {
 	require.True(t, called, "expected deleteUpstreamCredential to be invoked")
 }
 
+func TestHandler_PatchUpstreamCredential_SetDefault(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &serviceStub{
+		setDefaultFn: func(ctx context.Context, credentialID string, isDefault bool) (accounts.UpstreamCredential, error) {
+			require.Equal(t, "cred-1", credentialID)
+			require.True(t, isDefault)
+			return accounts.UpstreamCredential{ID: credentialID, UserID: "user-1", Service: "openai", IsDefault: true}, nil
+		},
+		getUpstreamFn: func(ctx context.Context, credentialID string) (accounts.UpstreamCredential, error) {
+			return accounts.UpstreamCredential{ID: credentialID, UserID: "user-1", Service: "openai", Enabled: true, IsDefault: true}, nil
+		},
+	}
+	router := newTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodPatch, "/admin/upstreams/cred-1", bytes.NewBufferString(`{"is_default":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp upstreamCredentialResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.True(t, resp.IsDefault)
+
+	req = httptest.NewRequest(http.MethodPatch, "/admin/upstreams/cred-1", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHandler_PatchUpstreamCredential_Disable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	enabled := true
+	svc := &serviceStub{
+		setEnabledFn: func(ctx context.Context, credentialID string, value bool) error {
+			require.Equal(t, "cred-1", credentialID)
+			enabled = value
+			return nil
+		},
+		getUpstreamFn: func(ctx context.Context, credentialID string) (accounts.UpstreamCredential, error) {
+			return accounts.UpstreamCredential{ID: credentialID, UserID: "user-1", Service: "openai", Enabled: enabled}, nil
+		},
+	}
+	router := newTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodPatch, "/admin/upstreams/cred-1", bytes.NewBufferString(`{"enabled":false}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp upstreamCredentialResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.False(t, resp.Enabled)
+}
+
+func TestHandler_GetUser_EmbedsSummaries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &serviceStub{
+		getUserDetailFn: func(ctx context.Context, id string) (UserDetail, error) {
+			if id != "user-1" {
+				return UserDetail{}, accounts.ErrNotFound
+			}
+			return UserDetail{
+				User: accounts.User{ID: id, Name: "alice"},
+				APIKeys: []accounts.APIKey{
+					{ID: "key-1", UserID: id, Enabled: true},
+					{ID: "key-2", UserID: id},
+				},
+				Upstreams: []accounts.UpstreamCredential{{ID: "cred-1", UserID: id, Service: "openai", Enabled: true}},
+				Bindings: []accounts.BindingWithUpstream{{
+					Binding:  accounts.UserAPIKeyBinding{ID: "bind-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-1", Service: "openai"},
+					Upstream: accounts.UpstreamCredential{ID: "cred-1", Enabled: true},
+				}},
+			}, nil
+		},
+	}
+	router := newTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/users/user-1", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp userDetailResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "alice", resp.Name)
+	require.Equal(t, 2, resp.APIKeys.Total)
+	require.Equal(t, 1, resp.APIKeys.Enabled)
+	require.Equal(t, 1, resp.Upstreams.Total)
+	require.Len(t, resp.Bindings.Items, 1)
+	require.Equal(t, "cred-1", resp.Bindings.Items[0].UpstreamCredentialID)
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/users/missing", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_BindingLifecycle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	bindings := []accounts.BindingWithUpstream{
+		{
+			Binding:  accounts.UserAPIKeyBinding{ID: "bind-1", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-1", Service: "openai"},
+			Upstream: accounts.UpstreamCredential{ID: "cred-1", UserID: "user-1", Service: "openai", Enabled: true},
+		},
+		{
+			Binding:  accounts.UserAPIKeyBinding{ID: "bind-2", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-2", Service: "openai", Position: 1},
+			Upstream: accounts.UpstreamCredential{ID: "cred-2", UserID: "user-1", Service: "openai", Enabled: true},
+		},
+	}
+	var deleted string
+	svc := &serviceStub{
+		listBindingsFn: func(ctx context.Context, apiKeyID string) ([]accounts.BindingWithUpstream, error) {
+			require.Equal(t, "key-1", apiKeyID)
+			return bindings, nil
+		},
+		userBindingsFn: func(ctx context.Context, userID string) ([]accounts.BindingWithUpstream, error) {
+			require.Equal(t, "user-1", userID)
+			return bindings, nil
+		},
+		deleteBindingFn: func(ctx context.Context, bindingID string) error {
+			if bindingID != "bind-2" {
+				return accounts.ErrNotFound
+			}
+			deleted = bindingID
+			return nil
+		},
+	}
+	router := newTestRouter(svc)
+
+	for _, path := range []string{"/admin/api-keys/key-1/bindings", "/admin/users/user-1/bindings"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, rec.Code, path)
+		var resp struct {
+			Items []apiKeyBindingResponse `json:"items"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.Len(t, resp.Items, 2)
+		require.Equal(t, 1, resp.Items[1].Position)
+		require.Equal(t, "cred-2", resp.Items[1].Upstream.ID)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/bindings/bind-2", nil))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "bind-2", deleted)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/bindings/missing", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_PatchUserAPIKey_Enabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	enabled := true
+	svc := &serviceStub{
+		setKeyEnabledFn: func(ctx context.Context, apiKeyID string, value bool) error {
+			if apiKeyID != "key-1" {
+				return accounts.ErrNotFound
+			}
+			enabled = value
+			return nil
+		},
+		getAPIKeyFn: func(ctx context.Context, apiKeyID string) (accounts.APIKey, error) {
+			return accounts.APIKey{ID: apiKeyID, UserID: "user-1", Prefix: "pref", Enabled: enabled}, nil
+		},
+	}
+	router := newTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodPatch, "/admin/api-keys/key-1", bytes.NewBufferString(`{"enabled":false}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp apiKeyResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "key-1", resp.ID)
+	require.False(t, resp.Enabled)
+
+	req = httptest.NewRequest(http.MethodPatch, "/admin/api-keys/missing", bytes.NewBufferString(`{"enabled":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_VerifyUpstreamCredential_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	checkedAt := time.Date(2025, time.November, 3, 9, 0, 0, 0, time.UTC)
+	svc := &serviceStub{
+		verifyUpstreamFn: func(ctx context.Context, credentialID string) (accounts.UpstreamHealth, error) {
+			require.Equal(t, "cred-1", credentialID)
+			return accounts.UpstreamHealth{
+				Status:     accounts.UpstreamHealthRateLimited,
+				Detail:     "rate limited",
+				HTTPStatus: http.StatusTooManyRequests,
+				CheckedAt:  checkedAt,
+			}, nil
+		},
+	}
+	router := newTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/upstreams/cred-1/verify", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp upstreamHealthResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, accounts.UpstreamHealthRateLimited, resp.Status)
+	require.Equal(t, http.StatusTooManyRequests, resp.HTTPStatus)
+	require.NotNil(t, resp.CheckedAt)
+	require.True(t, checkedAt.Equal(*resp.CheckedAt))
+}
+
+func TestHandler_VerifyUpstreamCredential_Unavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newTestRouter(&serviceStub{})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/upstreams/cred-1/verify", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+}
+
+func TestHandler_CreateUpstreamKeyPool_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &serviceStub{
+		createPoolFn: func(ctx context.Context, params accounts.CreateUpstreamKeyPoolParams) (accounts.UpstreamKeyPool, error) {
+			require.Equal(t, "user-1", params.UserID)
+			require.Equal(t, "openai-pool", params.Name)
+			require.Equal(t, []string{"cred-1", "cred-2"}, params.CredentialIDs)
+			return accounts.UpstreamKeyPool{
+				ID:            "pool-1",
+				UserID:        params.UserID,
+				Name:          params.Name,
+				Service:       params.Provider,
+				Strategy:      accounts.PoolStrategyLeastRecentlyUsed,
+				CredentialIDs: params.CredentialIDs,
+			}, nil
+		},
+	}
+	router := newTestRouter(svc)
+
+	body := bytes.NewBufferString(`{"name":"openai-pool","provider":"openai","strategy":"lru","credential_ids":["cred-1","cred-2"]}`)
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/user-1/upstream-pools", body)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var resp upstreamKeyPoolResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "pool-1", resp.ID)
+	require.Equal(t, "openai", resp.Provider)
+	require.Equal(t, accounts.PoolStrategyLeastRecentlyUsed, resp.Strategy)
+	require.Equal(t, []string{"cred-1", "cred-2"}, resp.CredentialIDs)
+}
+
 func TestHandler_BindAPIKey_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	now := time.Date(2025, time.November, 2, 16, 0, 0, 0, time.UTC)
@@ -750,16 +1300,20 @@ func TestHandler_BindAPIKey_Success(t *testing.T) {
 			require.Equal(t, "user-1", params.UserID)
 			require.Equal(t, "key-1", params.UserAPIKeyID)
 			require.Equal(t, "cred-1", params.UpstreamCredentialID)
+			require.Equal(t, "mock-provider", params.Service)
+			require.Equal(t, 1, params.Position)
+			binding.Service = params.Service
+			binding.Position = params.Position
 			return binding, nil
 		},
-		getBindingFn: func(ctx context.Context, apiKeyID string) (accounts.UserAPIKeyBinding, accounts.UpstreamCredential, error) {
+		listBindingsFn: func(ctx context.Context, apiKeyID string) ([]accounts.BindingWithUpstream, error) {
 			require.Equal(t, "key-1", apiKeyID)
-			return binding, upstream, nil
+			return []accounts.BindingWithUpstream{{Binding: binding, Upstream: upstream}}, nil
 		},
 	}
 	router := newTestRouter(svc)
 
-	body := bytes.NewBufferString(`{"user_id":"user-1","upstream_credential_id":"cred-1"}`)
+	body := bytes.NewBufferString(`{"user_id":"user-1","upstream_credential_id":"cred-1","service":"mock-provider","position":1}`)
 	req := httptest.NewRequest(http.MethodPost, "/admin/api-keys/key-1/binding", body)
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
@@ -775,6 +1329,8 @@ func TestHandler_BindAPIKey_Success(t *testing.T) {
 	require.Equal(t, "primary", resp.Upstream.Label)
 	require.Equal(t, "primary", resp.Upstream.Name)
 	require.Equal(t, "primary", resp.Metadata["strategy"])
+	require.Equal(t, "mock-provider", resp.Service)
+	require.Equal(t, 1, resp.Position)
 }
 
 func TestHandler_GetAPIKeyBinding_Success(t *testing.T) {
@@ -829,3 +1385,430 @@ func newTestRouter(svc Service) *gin.Engine {
 	RegisterProtectedRoutes(router.Group("/admin"), NewHandler(svc, nil))
 	return router
 }
+
+func TestHandler_PatchUser_RateLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	current := accounts.User{ID: "user-1", Name: "alice", RateLimits: accounts.RateLimits{MaxRequestsPerMinute: 60, MaxConcurrentRequests: 4}}
+	svc := &serviceStub{
+		getUserFn: func(ctx context.Context, id string) (accounts.User, error) {
+			return current, nil
+		},
+		userLimitsFn: func(ctx context.Context, id string, limits accounts.RateLimits) (accounts.User, error) {
+			require.Equal(t, accounts.RateLimits{MaxRequestsPerMinute: 120, MaxConcurrentRequests: 4}, limits)
+			current.RateLimits = limits
+			return current, nil
+		},
+	}
+	router := newTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodPatch, "/admin/users/user-1", bytes.NewBufferString(`{"max_requests_per_minute":120}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp userResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, 120, resp.MaxRequestsPerMinute)
+	require.Equal(t, 4, resp.MaxConcurrentRequests)
+
+	req = httptest.NewRequest(http.MethodPatch, "/admin/users/user-1", bytes.NewBufferString(`{"max_concurrent_requests":-1}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHandler_PatchUser_ModelAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	current := accounts.User{ID: "user-1", Name: "alice", ModelAccess: accounts.ModelAccess{DeniedModels: []string{"o1*"}}}
+	svc := &serviceStub{
+		getUserFn: func(ctx context.Context, id string) (accounts.User, error) {
+			return current, nil
+		},
+		userLimitsFn: func(ctx context.Context, id string, limits accounts.RateLimits) (accounts.User, error) {
+			t.Fatal("rate limits must stay untouched")
+			return accounts.User{}, nil
+		},
+		userModelsFn: func(ctx context.Context, id string, access accounts.ModelAccess) (accounts.User, error) {
+			require.Equal(t, []string{"gpt-4o-mini"}, []string(access.AllowedModels))
+			require.Equal(t, []string{"o1*"}, []string(access.DeniedModels))
+			current.ModelAccess = access
+			return current, nil
+		},
+	}
+	router := newTestRouter(svc)
+
+	req := httptest.NewRequest(http.MethodPatch, "/admin/users/user-1", bytes.NewBufferString(`{"allowed_models":["gpt-4o-mini"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `["gpt-4o-mini"]`, mustJSONField(t, rec.Body.Bytes(), "allowed_models"))
+	require.JSONEq(t, `["o1*"]`, mustJSONField(t, rec.Body.Bytes(), "denied_models"))
+}
+
+func mustJSONField(t *testing.T, body []byte, field string) string {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(body, &fields))
+	return string(fields[field])
+}
+
+func TestHandler_GetRule_IncludesStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	updatedAt := time.Date(2025, time.November, 3, 9, 0, 0, 0, time.UTC)
+	svc := &serviceStub{
+		getRuleFn: func(ctx context.Context, id string) (rules.Rule, error) {
+			if id != "rule-stats" {
+				return rules.Rule{}, rules.ErrRuleNotFound
+			}
+			return rules.Rule{ID: id, Enabled: true, Version: 3, UpdatedBy: "ops", UpdatedAt: updatedAt}, nil
+		},
+	}
+	router := newTestRouter(svc)
+	metrics.ObserveRuleMatch("rule-stats")
+	metrics.ObserveRuleMatch("rule-stats")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/rules/rule-stats", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp ruleDetailResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "rule-stats", resp.ID)
+	require.Equal(t, uint64(2), resp.Stats.Matches)
+	require.NotNil(t, resp.Stats.LastMatchedAt)
+	require.Equal(t, "ops", resp.LastModified.By)
+	require.Equal(t, 3, resp.LastModified.Version)
+	require.True(t, updatedAt.Equal(*resp.LastModified.At))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/rules/missing", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_PatchRule_MergePatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stored := rules.Rule{
+		ID:       "rule-1",
+		Priority: 10,
+		Enabled:  true,
+		Matcher:  rules.Matcher{PathPrefix: "/v1", Methods: []string{"POST"}},
+		Actions: rules.Actions{
+			SetTargetURL: "https://example.com",
+			SetHeaders:   map[string]string{"X-A": "1", "X-B": "2"},
+		},
+	}
+	svc := &serviceStub{
+		getRuleFn: func(ctx context.Context, id string) (rules.Rule, error) {
+			if id != stored.ID {
+				return rules.Rule{}, rules.ErrRuleNotFound
+			}
+			return stored, nil
+		},
+		upsertFn: func(ctx context.Context, rule rules.Rule) error {
+			if err := rule.Validate(); err != nil {
+				return err
+			}
+			stored = rule
+			return nil
+		},
+	}
+	router := newTestRouter(svc)
+
+	patch := `{"enabled":false,"priority":20,"actions":{"set_headers":{"X-A":null,"X-C":"3"}}}`
+	req := httptest.NewRequest(http.MethodPatch, "/admin/rules/rule-1", bytes.NewBufferString(patch))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.False(t, stored.Enabled)
+	require.Equal(t, 20, stored.Priority)
+	require.Equal(t, []string{"POST"}, stored.Matcher.Methods)
+	require.Equal(t, "https://example.com", stored.Actions.SetTargetURL)
+	require.Equal(t, map[string]string{"X-B": "2", "X-C": "3"}, stored.Actions.SetHeaders)
+
+	req = httptest.NewRequest(http.MethodPatch, "/admin/rules/rule-1", bytes.NewBufferString(`{"matcher":{"path_prefix":"v1"}}`))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPatch, "/admin/rules/missing", bytes.NewBufferString(`{"enabled":true}`))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+type recordingEventBus struct {
+	published []rules.Event
+}
+
+func (b *recordingEventBus) Publish(ctx context.Context, evt rules.Event) error {
+	b.published = append(b.published, evt)
+	return nil
+}
+
+func (b *recordingEventBus) Subscribe(ctx context.Context) (<-chan rules.Event, error) {
+	return make(chan rules.Event), nil
+}
+
+func TestHandler_ToggleRule_IdempotentAndBroadcasts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	bus := &recordingEventBus{}
+	ruleService := rules.NewService(rules.NewMemoryStore(), rules.WithEventBus(bus))
+	require.NoError(t, ruleService.UpsertRule(ctx, rules.Rule{
+		ID:      "rule-toggle",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: "https://example.com"},
+	}))
+	bus.published = nil
+
+	router := gin.New()
+	group := router.Group("/admin")
+	group.Use(func(c *gin.Context) {
+		c.Set("admin_user", "ops")
+		c.Next()
+	})
+	RegisterProtectedRoutes(group, NewHandler(NewService(ruleService, nil), nil))
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/rules/rule-toggle/disable", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp ruleDetailResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.False(t, resp.Enabled)
+		require.Equal(t, "ops", resp.LastModified.By)
+		require.Equal(t, 2, resp.LastModified.Version)
+	}
+	require.Equal(t, []rules.Event{rules.EventRulesChanged}, bus.published)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/rules/rule-toggle/enable", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, bus.published, 2)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/rules/missing/enable", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_AuditLogs_RecordRuleChanges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	ruleService := rules.NewService(rules.NewMemoryStore())
+	require.NoError(t, ruleService.UpsertRule(ctx, rules.Rule{
+		ID:       "rule-audit",
+		Priority: 10,
+		Enabled:  true,
+		Matcher:  rules.Matcher{PathPrefix: "/v1"},
+		Actions:  rules.Actions{SetTargetURL: "https://example.com"},
+	}))
+	store := audit.NewMemoryStore()
+	router := gin.New()
+	group := router.Group("/admin")
+	group.Use(func(c *gin.Context) {
+		c.Set("admin_user", "ops")
+		c.Next()
+	})
+	RegisterProtectedRoutes(group, NewHandler(NewService(ruleService, nil), nil, WithAuditStore(store)))
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+		return rec
+	}
+	require.Equal(t, http.StatusOK, send(http.MethodPatch, "/admin/rules/rule-audit", `{"priority": 20}`).Code)
+	require.Equal(t, http.StatusOK, send(http.MethodPost, "/admin/rules/rule-audit/disable", "").Code)
+	require.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/admin/rules/rule-audit", "").Code)
+
+	rec := send(http.MethodGet, "/admin/audit-logs?actor=ops&action=rules.patch", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp struct {
+		Items []audit.Entry `json:"items"`
+		Total int64         `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.EqualValues(t, 1, resp.Total)
+	entry := resp.Items[0]
+	require.Equal(t, "ops", entry.Actor)
+	require.Equal(t, "rule", entry.ResourceType)
+	require.Equal(t, "rule-audit", entry.ResourceID)
+	require.Equal(t, audit.Change{Before: float64(10), After: float64(20)}, entry.Diff["priority"])
+
+	rec = send(http.MethodGet, "/admin/audit-logs?resource_id=rule-audit", "")
+	resp.Items = nil
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.EqualValues(t, 3, resp.Total)
+	require.Equal(t, "rules.delete", resp.Items[0].Action)
+	require.NotEmpty(t, resp.Items[0].Before)
+	require.Empty(t, resp.Items[0].After)
+
+	rec = send(http.MethodGet, "/admin/audit-logs?since="+time.Now().Add(time.Hour).UTC().Format(time.RFC3339), "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.EqualValues(t, 0, resp.Total)
+
+	require.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/admin/audit-logs?until=yesterday", "").Code)
+}
+
+func TestHandler_AuditLogs_Unavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newTestRouter(&serviceStub{})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/audit-logs", nil))
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+}
+
+func TestHandler_StreamEvents_PushesChanges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	bus := rules.NewMemoryEventBus()
+	svc := &serviceStub{
+		deleteUserFn: func(ctx context.Context, id string) error { return nil },
+	}
+	router := gin.New()
+	RegisterProtectedRoutes(router.Group("/admin"), NewHandler(svc, nil, WithEventBus(bus)))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/admin/events", nil)
+	require.NoError(t, err)
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, ": connected\n", line)
+
+	delReq, err := http.NewRequest(http.MethodDelete, server.URL+"/admin/users/user-1", nil)
+	require.NoError(t, err)
+	delResp, err := server.Client().Do(delReq)
+	require.NoError(t, err)
+	require.NoError(t, delResp.Body.Close())
+	require.Equal(t, http.StatusNoContent, delResp.StatusCode)
+	require.NoError(t, bus.Publish(ctx, rules.EventRulesChanged))
+
+	var got []string
+	for len(got) < 2 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		if strings.HasPrefix(line, "event: ") {
+			got = append(got, strings.TrimSpace(strings.TrimPrefix(line, "event: ")))
+			data, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			require.Contains(t, data, `"type":"`+got[len(got)-1]+`"`)
+		}
+	}
+	require.Equal(t, []string{"accounts_changed", "rules_changed"}, got)
+}
+
+func TestHandler_StreamEvents_Unavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newTestRouter(&serviceStub{})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/events", nil))
+	require.Equal(t, http.StatusNotImplemented, rec.Code)
+}
+
+func TestHandler_Apply_DryRunAndConfirm(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	change := ApplyChange{ResourceType: auditResourceRule, Action: ApplyActionCreate, ID: "r1", After: map[string]any{"id": "r1"}}
+	var gotOpts ApplyOptions
+	svc := &serviceStub{
+		planApplyFn: func(ctx context.Context, desired DesiredState) (ApplyPlan, error) {
+			require.Len(t, desired.Rules, 1)
+			return ApplyPlan{Fingerprint: "fp", Changes: []ApplyChange{change}}, nil
+		},
+		applyFn: func(ctx context.Context, desired DesiredState, opts ApplyOptions) (ApplyPlan, error) {
+			gotOpts = opts
+			if opts.Fingerprint != "fp" {
+				return ApplyPlan{}, ErrPlanChanged
+			}
+			return ApplyPlan{Fingerprint: "fp", Changes: []ApplyChange{change}, Applied: true}, nil
+		},
+	}
+	store := audit.NewMemoryStore()
+	router := gin.New()
+	group := router.Group("/admin")
+	group.Use(func(c *gin.Context) { c.Set("admin_user", "ops") })
+	RegisterProtectedRoutes(group, NewHandler(svc, nil, WithAuditStore(store)))
+
+	body := `{"rules":[{"id":"r1"}],"fingerprint":"fp"}`
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/apply?dry_run=true", bytes.NewBufferString(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var plan ApplyPlan
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &plan))
+	require.Equal(t, "fp", plan.Fingerprint)
+	require.False(t, plan.Applied)
+	require.Len(t, plan.Changes, 1)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/apply", bytes.NewBufferString(`{"rules":[{"id":"r1"}],"fingerprint":"stale"}`)))
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/apply", bytes.NewBufferString(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, ApplyOptions{Actor: "ops", Fingerprint: "fp"}, gotOpts)
+
+	entries, total, err := store.List(context.Background(), audit.Filter{})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	require.Equal(t, "apply.create", entries[0].Action)
+	require.Equal(t, "r1", entries[0].ResourceID)
+}
+
+func TestHandler_ListRules_Sort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	svc := &serviceStub{
+		listFn: func(ctx context.Context) ([]rules.Rule, error) {
+			return []rules.Rule{
+				{ID: "b", Priority: 20, UpdatedAt: base.Add(time.Hour)},
+				{ID: "c", Priority: 10, UpdatedAt: base.Add(3 * time.Hour)},
+				{ID: "a", Priority: 20, UpdatedAt: base.Add(2 * time.Hour)},
+			}, nil
+		},
+	}
+	router := gin.New()
+	RegisterProtectedRoutes(router.Group("/admin"), NewHandler(svc, nil))
+
+	ids := func(query string) []string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/rules"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp listRulesResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		var got []string
+		for _, rule := range resp.Items {
+			got = append(got, rule.ID)
+		}
+		return got
+	}
+	require.Equal(t, []string{"b", "c", "a"}, ids(""))
+	require.Equal(t, []string{"a", "b", "c"}, ids("?sort=priority"))
+	require.Equal(t, []string{"c", "a", "b"}, ids("?sort=priority&order=asc"))
+	require.Equal(t, []string{"c", "a", "b"}, ids("?sort=updated_at"))
+	require.Equal(t, []string{"b", "a", "c"}, ids("?sort=updated_at&order=asc"))
+	require.Equal(t, []string{"a", "b", "c"}, ids("?sort=id"))
+	require.Equal(t, []string{"c", "b"}, ids("?sort=id&order=desc&page_size=2"))
+
+	for _, query := range []string{"?sort=name", "?sort=id&order=up"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/rules"+query, nil))
+		require.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
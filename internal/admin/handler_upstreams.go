@@ -0,0 +1,122 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// patchUpstreamCredentialRequest 描述凭据的局部更新，缺省字段保持不变。
+type patchUpstreamCredentialRequest struct {
+	Enabled   *bool `json:"enabled"`
+	IsDefault *bool `json:"is_default"`
+}
+
+type upstreamHealthResponse struct {
+	Status     string     `json:"status"`
+	Detail     string     `json:"detail,omitempty"`
+	HTTPStatus int        `json:"http_status,omitempty"`
+	CheckedAt  *time.Time `json:"checked_at,omitempty"`
+}
+
+// toStoredHealthResponse 返回凭据最近一次记录的健康状态，尚未检查过时返回 nil。
+func toStoredHealthResponse(cred accounts.UpstreamCredential) *upstreamHealthResponse {
+	if cred.HealthStatus == "" {
+		return nil
+	}
+	return &upstreamHealthResponse{
+		Status:    cred.HealthStatus,
+		Detail:    cred.HealthDetail,
+		CheckedAt: cred.HealthCheckedAt,
+	}
+}
+
+func (h *Handler) verifyUpstreamCredential(c *gin.Context) {
+	action := "accounts.upstreams.verify"
+	credentialID := c.Param("id")
+	if credentialID == "" {
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "credential id is required")
+		return
+	}
+	health, err := h.service.VerifyUpstreamCredential(c.Request.Context(), credentialID)
+	if h.handleAccountsError(c, action, err, map[string]any{"credential": credentialID}) {
+		return
+	}
+	checkedAt := health.CheckedAt
+	metrics.ObserveAdminAction(action, true)
+	h.logInfo(c, "upstream credential verified", map[string]any{
+		"user":       currentAdminUser(c),
+		"credential": credentialID,
+		"status":     health.Status,
+	})
+	c.JSON(http.StatusOK, upstreamHealthResponse{
+		Status:     health.Status,
+		Detail:     health.Detail,
+		HTTPStatus: health.HTTPStatus,
+		CheckedAt:  &checkedAt,
+	})
+}
+
+func (h *Handler) patchUpstreamCredential(c *gin.Context) {
+	action := "accounts.upstreams.patch"
+	credentialID := c.Param("id")
+	if credentialID == "" {
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "credential id is required")
+		return
+	}
+	var req patchUpstreamCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	if req.Enabled == nil && req.IsDefault == nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "no patchable field provided")
+		return
+	}
+	ctx := c.Request.Context()
+	before := auditSnapshot(h, func() (upstreamCredentialResponse, error) {
+		return h.upstreamCredentialSnapshot(c, credentialID)
+	})
+	if req.Enabled != nil {
+		err := h.service.SetUpstreamCredentialEnabled(ctx, credentialID, *req.Enabled)
+		if h.handleAccountsError(c, action, err, map[string]any{"credential": credentialID}) {
+			return
+		}
+	}
+	if req.IsDefault != nil {
+		_, err := h.service.SetUpstreamCredentialDefault(ctx, credentialID, *req.IsDefault)
+		if h.handleAccountsError(c, action, err, map[string]any{"credential": credentialID}) {
+			return
+		}
+	}
+	cred, err := h.service.GetUpstreamCredential(ctx, credentialID)
+	if h.handleAccountsError(c, action, err, map[string]any{"credential": credentialID}) {
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	h.logInfo(c, "upstream credential patched", map[string]any{
+		"user":       currentAdminUser(c),
+		"credential": credentialID,
+		"enabled":    cred.Enabled,
+		"is_default": cred.IsDefault,
+	})
+	after := toUpstreamCredentialResponse(cred, decodeEndpoints(cred.Endpoints))
+	h.recordAudit(c, action, auditResourceUpstream, credentialID, before, after)
+	c.JSON(http.StatusOK, after)
+}
+
+// upstreamCredentialSnapshot 读取凭据当前状态，用作审计记录的变更前快照。
+func (h *Handler) upstreamCredentialSnapshot(c *gin.Context, credentialID string) (upstreamCredentialResponse, error) {
+	cred, err := h.service.GetUpstreamCredential(c.Request.Context(), credentialID)
+	if err != nil {
+		return upstreamCredentialResponse{}, err
+	}
+	return toUpstreamCredentialResponse(cred, decodeEndpoints(cred.Endpoints)), nil
+}
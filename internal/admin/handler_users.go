@@ -0,0 +1,221 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// userDetailResponse 在用户信息之外附带名下资源的计数与摘要。
+type userDetailResponse struct {
+	userResponse
+	APIKeys   resourceSummary[apiKeyResponse]             `json:"api_keys"`
+	Upstreams resourceSummary[upstreamCredentialResponse] `json:"upstreams"`
+	Bindings  resourceSummary[bindingSummaryResponse]     `json:"bindings"`
+}
+
+type resourceSummary[T any] struct {
+	Total   int `json:"total"`
+	Enabled int `json:"enabled"`
+	Items   []T `json:"items"`
+}
+
+type bindingSummaryResponse struct {
+	ID                   string `json:"id"`
+	UserAPIKeyID         string `json:"user_api_key_id"`
+	UpstreamCredentialID string `json:"upstream_credential_id"`
+	Service              string `json:"service"`
+	Position             int    `json:"position"`
+	UpstreamEnabled      bool   `json:"upstream_enabled"`
+}
+
+func toUserDetailResponse(detail UserDetail) userDetailResponse {
+	resp := userDetailResponse{
+		userResponse: toUserResponse(detail.User),
+		APIKeys:      resourceSummary[apiKeyResponse]{Items: make([]apiKeyResponse, 0, len(detail.APIKeys))},
+		Upstreams:    resourceSummary[upstreamCredentialResponse]{Items: make([]upstreamCredentialResponse, 0, len(detail.Upstreams))},
+		Bindings:     resourceSummary[bindingSummaryResponse]{Items: make([]bindingSummaryResponse, 0, len(detail.Bindings))},
+	}
+	for _, key := range detail.APIKeys {
+		resp.APIKeys.Items = append(resp.APIKeys.Items, toAPIKeyResponse(key))
+		if key.Enabled {
+			resp.APIKeys.Enabled++
+		}
+	}
+	for _, cred := range detail.Upstreams {
+		resp.Upstreams.Items = append(resp.Upstreams.Items, toUpstreamCredentialResponse(cred, decodeEndpoints(cred.Endpoints)))
+		if cred.Enabled {
+			resp.Upstreams.Enabled++
+		}
+	}
+	for _, item := range detail.Bindings {
+		resp.Bindings.Items = append(resp.Bindings.Items, toBindingSummaryResponse(item))
+		if item.Upstream.Enabled {
+			resp.Bindings.Enabled++
+		}
+	}
+	resp.APIKeys.Total = len(resp.APIKeys.Items)
+	resp.Upstreams.Total = len(resp.Upstreams.Items)
+	resp.Bindings.Total = len(resp.Bindings.Items)
+	return resp
+}
+
+func toBindingSummaryResponse(item accounts.BindingWithUpstream) bindingSummaryResponse {
+	return bindingSummaryResponse{
+		ID:                   item.Binding.ID,
+		UserAPIKeyID:         item.Binding.UserAPIKeyID,
+		UpstreamCredentialID: item.Binding.UpstreamKeyID,
+		Service:              item.Binding.Service,
+		Position:             item.Binding.Position,
+		UpstreamEnabled:      item.Upstream.Enabled,
+	}
+}
+
+func (h *Handler) getUser(c *gin.Context) {
+	action := "accounts.users.get"
+	id := c.Param("id")
+	if id == "" {
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "id is required")
+		return
+	}
+	detail, err := h.service.GetUserDetail(c.Request.Context(), id)
+	if h.handleAccountsError(c, action, err, map[string]any{"target_user": id}) {
+		return
+	}
+	metrics.ObserveAdminAction(action, true)
+	c.JSON(http.StatusOK, toUserDetailResponse(detail))
+}
+
+// rateLimitsPayload 是用户与 API Key 共用的限流字段，0 表示不限制（API Key 上表示沿用用户配置）。
+type rateLimitsPayload struct {
+	MaxRequestsPerMinute  int `json:"max_requests_per_minute" binding:"min=0"`
+	MaxConcurrentRequests int `json:"max_concurrent_requests" binding:"min=0"`
+}
+
+func toRateLimitsPayload(limits accounts.RateLimits) rateLimitsPayload {
+	return rateLimitsPayload{
+		MaxRequestsPerMinute:  limits.MaxRequestsPerMinute,
+		MaxConcurrentRequests: limits.MaxConcurrentRequests,
+	}
+}
+
+func (p rateLimitsPayload) toRateLimits() accounts.RateLimits {
+	return accounts.RateLimits{
+		MaxRequestsPerMinute:  p.MaxRequestsPerMinute,
+		MaxConcurrentRequests: p.MaxConcurrentRequests,
+	}
+}
+
+// patchRateLimitsRequest 描述限流字段的局部更新，缺省字段保持不变。
+type patchRateLimitsRequest struct {
+	MaxRequestsPerMinute  *int `json:"max_requests_per_minute" binding:"omitempty,min=0"`
+	MaxConcurrentRequests *int `json:"max_concurrent_requests" binding:"omitempty,min=0"`
+}
+
+func (p patchRateLimitsRequest) empty() bool {
+	return p.MaxRequestsPerMinute == nil && p.MaxConcurrentRequests == nil
+}
+
+// apply 将请求中出现的字段覆盖到现有配置上。
+func (p patchRateLimitsRequest) apply(current accounts.RateLimits) accounts.RateLimits {
+	if p.MaxRequestsPerMinute != nil {
+		current.MaxRequestsPerMinute = *p.MaxRequestsPerMinute
+	}
+	if p.MaxConcurrentRequests != nil {
+		current.MaxConcurrentRequests = *p.MaxConcurrentRequests
+	}
+	return current
+}
+
+// modelAccessPayload 是用户与 API Key 共用的模型访问控制字段，支持以 * 结尾的前缀匹配；
+// API Key 的列表在用户列表之上进一步收紧。
+type modelAccessPayload struct {
+	AllowedModels []string `json:"allowed_models,omitempty"`
+	DeniedModels  []string `json:"denied_models,omitempty"`
+}
+
+func toModelAccessPayload(access accounts.ModelAccess) modelAccessPayload {
+	return modelAccessPayload{AllowedModels: access.AllowedModels, DeniedModels: access.DeniedModels}
+}
+
+func (p modelAccessPayload) toModelAccess() accounts.ModelAccess {
+	return accounts.ModelAccess{AllowedModels: p.AllowedModels, DeniedModels: p.DeniedModels}
+}
+
+// patchModelAccessRequest 描述模型访问控制的局部更新，缺省字段保持不变，空数组表示清空。
+type patchModelAccessRequest struct {
+	AllowedModels *[]string `json:"allowed_models"`
+	DeniedModels  *[]string `json:"denied_models"`
+}
+
+func (p patchModelAccessRequest) empty() bool {
+	return p.AllowedModels == nil && p.DeniedModels == nil
+}
+
+// apply 将请求中出现的字段覆盖到现有配置上。
+func (p patchModelAccessRequest) apply(current accounts.ModelAccess) accounts.ModelAccess {
+	if p.AllowedModels != nil {
+		current.AllowedModels = *p.AllowedModels
+	}
+	if p.DeniedModels != nil {
+		current.DeniedModels = *p.DeniedModels
+	}
+	return current
+}
+
+// patchUserRequest 描述用户的局部更新。
+type patchUserRequest struct {
+	patchRateLimitsRequest
+	patchModelAccessRequest
+}
+
+func (h *Handler) patchUser(c *gin.Context) {
+	action := "accounts.users.patch"
+	id := c.Param("id")
+	if id == "" {
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "id is required")
+		return
+	}
+	var req patchUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, err.Error())
+		return
+	}
+	if req.patchRateLimitsRequest.empty() && req.patchModelAccessRequest.empty() {
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "no patchable field provided")
+		return
+	}
+	ctx := c.Request.Context()
+	user, err := h.service.GetUser(ctx, id)
+	if h.handleAccountsError(c, action, err, map[string]any{"target_user": id}) {
+		return
+	}
+	before := toUserResponse(user)
+	if !req.patchRateLimitsRequest.empty() {
+		user, err = h.service.SetUserRateLimits(ctx, id, req.patchRateLimitsRequest.apply(user.RateLimits))
+		if h.handleAccountsError(c, action, err, map[string]any{"target_user": id}) {
+			return
+		}
+	}
+	if !req.patchModelAccessRequest.empty() {
+		user, err = h.service.SetUserModelAccess(ctx, id, req.patchModelAccessRequest.apply(user.ModelAccess))
+		if h.handleAccountsError(c, action, err, map[string]any{"target_user": id}) {
+			return
+		}
+	}
+	metrics.ObserveAdminAction(action, true)
+	h.logInfo(c, "user patched", map[string]any{
+		"user":                    currentAdminUser(c),
+		"target_user":             id,
+		"max_requests_per_minute": user.MaxRequestsPerMinute,
+		"max_concurrent_requests": user.MaxConcurrentRequests,
+	})
+	h.recordAudit(c, action, auditResourceUser, id, before, toUserResponse(user))
+	c.JSON(http.StatusOK, toUserResponse(user))
+}
@@ -0,0 +1,289 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// LoginLockedError 表示因连续登录失败，来源 IP 或用户名被临时锁定。
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// LoginPolicy 描述登录失败的锁定策略。计数在最后一次失败后 Window（加上当前锁定时长）内有效；
+// 达到阈值后锁定 Lockout，此后每次失败锁定时长翻倍，最长 MaxLockout。阈值为 0 表示不按该维度限制。
+type LoginPolicy struct {
+	MaxAttempts   int
+	IPMaxAttempts int
+	Window        time.Duration
+	Lockout       time.Duration
+	MaxLockout    time.Duration
+}
+
+// DefaultLoginPolicy 返回默认策略：同一用户名 5 次、同一 IP 20 次失败后锁定 1 分钟起，最长 1 小时。
+func DefaultLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		MaxAttempts:   5,
+		IPMaxAttempts: 20,
+		Window:        15 * time.Minute,
+		Lockout:       time.Minute,
+		MaxLockout:    time.Hour,
+	}
+}
+
+// LoginAttemptStore 保存登录失败计数与锁定状态。
+type LoginAttemptStore interface {
+	// Fail 记录一次失败并返回有效期内的累计次数，ttl 自本次失败起算。
+	Fail(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	Lock(ctx context.Context, key string, d time.Duration) error
+	// LockedFor 返回剩余锁定时长，未锁定时为 0。
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	// Failures 返回有效期内的累计失败次数。
+	Failures(ctx context.Context, key string) (int64, error)
+	Reset(ctx context.Context, key string) error
+}
+
+// LoginGuard 按来源 IP 与用户名统计登录失败并实施渐进式锁定。
+type LoginGuard struct {
+	store  LoginAttemptStore
+	policy LoginPolicy
+}
+
+// NewLoginGuard 创建登录防护，store 为 nil 时使用进程内存储。
+func NewLoginGuard(store LoginAttemptStore, policy LoginPolicy) *LoginGuard {
+	if store == nil {
+		store = NewMemoryLoginAttemptStore()
+	}
+	defaults := DefaultLoginPolicy()
+	if policy.Window <= 0 {
+		policy.Window = defaults.Window
+	}
+	if policy.Lockout <= 0 {
+		policy.Lockout = defaults.Lockout
+	}
+	if policy.MaxLockout < policy.Lockout {
+		policy.MaxLockout = policy.Lockout
+	}
+	return &LoginGuard{store: store, policy: policy}
+}
+
+// Check 返回 IP 与用户名中较长的剩余锁定时长。
+func (g *LoginGuard) Check(ctx context.Context, ip, username string) (time.Duration, error) {
+	var locked time.Duration
+	for _, key := range g.keys(ip, username) {
+		remaining, err := g.store.LockedFor(ctx, key.name)
+		if err != nil {
+			return 0, err
+		}
+		locked = max(locked, remaining)
+	}
+	return locked, nil
+}
+
+// Fail 记录一次失败，达到阈值时锁定并返回锁定时长。
+func (g *LoginGuard) Fail(ctx context.Context, ip, username string) (time.Duration, error) {
+	var locked time.Duration
+	for _, key := range g.keys(ip, username) {
+		count, err := g.store.Fail(ctx, key.name, g.policy.Window+g.policy.MaxLockout)
+		if err != nil {
+			return 0, err
+		}
+		if count < int64(key.limit) {
+			continue
+		}
+		lockout := g.lockout(count - int64(key.limit))
+		if err := g.store.Lock(ctx, key.name, lockout); err != nil {
+			return 0, err
+		}
+		metrics.ObserveAdminLoginLockout(key.scope)
+		locked = max(locked, lockout)
+	}
+	return locked, nil
+}
+
+// Succeed 清除用户名的失败计数；IP 计数保留，避免攻击者穿插自己的有效账号重置计数。
+func (g *LoginGuard) Succeed(ctx context.Context, username string) error {
+	if g.policy.MaxAttempts <= 0 || username == "" {
+		return nil
+	}
+	return g.store.Reset(ctx, "user:"+username)
+}
+
+// HasFailures 报告用户名是否有未过期的失败记录；读取失败时按有记录处理，以免漏清计数。
+func (g *LoginGuard) HasFailures(ctx context.Context, username string) bool {
+	if g.policy.MaxAttempts <= 0 || username == "" {
+		return false
+	}
+	count, err := g.store.Failures(ctx, "user:"+username)
+	return err != nil || count > 0
+}
+
+// lockout 计算第 n 次超限（从 0 开始）的锁定时长。
+func (g *LoginGuard) lockout(n int64) time.Duration {
+	d := g.policy.Lockout
+	for i := int64(0); i < n && d < g.policy.MaxLockout; i++ {
+		d *= 2
+	}
+	return min(d, g.policy.MaxLockout)
+}
+
+type guardKey struct {
+	name  string
+	scope string
+	limit int
+}
+
+func (g *LoginGuard) keys(ip, username string) []guardKey {
+	keys := make([]guardKey, 0, 2)
+	if g.policy.IPMaxAttempts > 0 && ip != "" {
+		keys = append(keys, guardKey{name: "ip:" + ip, scope: "ip", limit: g.policy.IPMaxAttempts})
+	}
+	if g.policy.MaxAttempts > 0 && username != "" {
+		keys = append(keys, guardKey{name: "user:" + username, scope: "username", limit: g.policy.MaxAttempts})
+	}
+	return keys
+}
+
+// MemoryLoginAttemptStore 在进程内保存登录失败记录，仅适用于单实例部署。
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	failures map[string]memoryAttempt
+	locks    map[string]time.Time
+	now      func() time.Time
+}
+
+type memoryAttempt struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// NewMemoryLoginAttemptStore 创建进程内登录失败存储。
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		failures: make(map[string]memoryAttempt),
+		locks:    make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryLoginAttemptStore) Fail(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	attempt := s.failures[key]
+	attempt.count++
+	attempt.expiresAt = now.Add(ttl)
+	s.failures[key] = attempt
+	return attempt.count, nil
+}
+
+func (s *MemoryLoginAttemptStore) Lock(_ context.Context, key string, d time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locks[key] = s.now().Add(d)
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) LockedFor(_ context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.locks[key]
+	if !ok {
+		return 0, nil
+	}
+	return max(until.Sub(s.now()), 0), nil
+}
+
+func (s *MemoryLoginAttemptStore) Failures(_ context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt, ok := s.failures[key]
+	if !ok || !attempt.expiresAt.After(s.now()) {
+		return 0, nil
+	}
+	return attempt.count, nil
+}
+
+func (s *MemoryLoginAttemptStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, key)
+	delete(s.locks, key)
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) sweep(now time.Time) {
+	for key, attempt := range s.failures {
+		if !attempt.expiresAt.After(now) {
+			delete(s.failures, key)
+		}
+	}
+	for key, until := range s.locks {
+		if !until.After(now) {
+			delete(s.locks, key)
+		}
+	}
+}
+
+// RedisLoginAttemptStore 将失败计数与锁定状态写入 Redis，多实例共享。
+type RedisLoginAttemptStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisLoginAttemptStore 创建基于 Redis 的登录失败存储，键形如 <prefix>:fail:<key> 与 <prefix>:lock:<key>。
+func NewRedisLoginAttemptStore(client redis.UniversalClient, prefix string) *RedisLoginAttemptStore {
+	return &RedisLoginAttemptStore{client: client, prefix: prefix}
+}
+
+func (s *RedisLoginAttemptStore) Fail(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	pipe := s.client.TxPipeline()
+	incr := pipe.Incr(ctx, s.prefix+":fail:"+key)
+	pipe.PExpire(ctx, s.prefix+":fail:"+key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (s *RedisLoginAttemptStore) Lock(ctx context.Context, key string, d time.Duration) error {
+	return s.client.Set(ctx, s.prefix+":lock:"+key, 1, d).Err()
+}
+
+func (s *RedisLoginAttemptStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(ctx, s.prefix+":lock:"+key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// 键不存在时 PTTL 返回负值。
+	return max(ttl, 0), nil
+}
+
+func (s *RedisLoginAttemptStore) Failures(ctx context.Context, key string) (int64, error) {
+	count, err := s.client.Get(ctx, s.prefix+":fail:"+key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return count, err
+}
+
+func (s *RedisLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	// 逐个删除：集群模式下两个键可能位于不同槽位，不能在一条 DEL 中删除。
+	pipe := s.client.Pipeline()
+	pipe.Del(ctx, s.prefix+":fail:"+key)
+	pipe.Del(ctx, s.prefix+":lock:"+key)
+	_, err := pipe.Exec(ctx)
+	return err
+}
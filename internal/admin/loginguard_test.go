@@ -0,0 +1,140 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/audit"
+)
+
+func TestLoginGuard_ProgressiveLockout(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryLoginAttemptStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	guard := NewLoginGuard(store, LoginPolicy{MaxAttempts: 3, IPMaxAttempts: 10, Lockout: time.Minute, MaxLockout: 3 * time.Minute})
+
+	for i := 0; i < 2; i++ {
+		locked, err := guard.Fail(ctx, "10.0.0.1", "alice")
+		require.NoError(t, err)
+		require.Zero(t, locked)
+	}
+	locked, err := guard.Fail(ctx, "10.0.0.1", "alice")
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, locked)
+	remaining, err := guard.Check(ctx, "10.0.0.2", "alice")
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, remaining)
+	remaining, err = guard.Check(ctx, "10.0.0.1", "bob")
+	require.NoError(t, err)
+	require.Zero(t, remaining)
+
+	// 锁定到期后再次失败，锁定时长翻倍直至上限。
+	now = now.Add(time.Minute)
+	locked, err = guard.Fail(ctx, "10.0.0.1", "alice")
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Minute, locked)
+	now = now.Add(2 * time.Minute)
+	locked, err = guard.Fail(ctx, "10.0.0.1", "alice")
+	require.NoError(t, err)
+	require.Equal(t, 3*time.Minute, locked)
+
+	now = now.Add(3 * time.Minute)
+	require.NoError(t, guard.Succeed(ctx, "alice"))
+	locked, err = guard.Fail(ctx, "10.0.0.1", "alice")
+	require.NoError(t, err)
+	require.Zero(t, locked)
+}
+
+func TestHandler_Login_LocksOutAfterRepeatedFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	guard := NewLoginGuard(nil, LoginPolicy{MaxAttempts: 2, IPMaxAttempts: 10, Lockout: time.Minute})
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute, WithLoginGuard(guard))
+	auditStore := audit.NewMemoryStore()
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth, WithAuditStore(auditStore)), auth.Middleware())
+
+	for i := 0; i < 2; i++ {
+		rec := doAdminRequest(router, http.MethodPost, "/admin/login", `{"username":"admin","password":"guess"}`, nil)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	rec := doAdminRequest(router, http.MethodPost, "/admin/login", `{"username":"admin","password":"secret"}`, nil)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "60", rec.Header().Get("Retry-After"))
+	rec = doAdminRequest(router, http.MethodGet, "/admin/rules", "", basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	entries, total, err := auditStore.List(context.Background(), audit.Filter{Action: "auth.login_failed"})
+	require.NoError(t, err)
+	require.EqualValues(t, 4, total)
+	require.Equal(t, "admin", entries[0].Actor)
+	require.Contains(t, string(entries[0].After), `"reason":"locked"`)
+}
+
+// resetCountingStore 统计 Reset 调用次数，用于确认成功的 Basic 认证不会每次都清除失败计数。
+type resetCountingStore struct {
+	*MemoryLoginAttemptStore
+	resets int
+}
+
+func (s *resetCountingStore) Reset(ctx context.Context, key string) error {
+	s.resets++
+	return s.MemoryLoginAttemptStore.Reset(ctx, key)
+}
+
+func TestHandler_BasicAuth_AuditsFailuresAndResetsOnlyAfterFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &resetCountingStore{MemoryLoginAttemptStore: NewMemoryLoginAttemptStore()}
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute,
+		WithLoginGuard(NewLoginGuard(store, LoginPolicy{MaxAttempts: 5, IPMaxAttempts: 20, Lockout: time.Minute})))
+	auditStore := audit.NewMemoryStore()
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth, WithAuditStore(auditStore)), auth.Middleware())
+
+	for range 3 {
+		require.Equal(t, http.StatusOK, doAdminRequest(router, http.MethodGet, "/admin/rules", "", basicAuth("admin", "secret")).Code)
+	}
+	require.Zero(t, store.resets)
+
+	require.Equal(t, http.StatusUnauthorized, doAdminRequest(router, http.MethodGet, "/admin/rules", "", basicAuth("admin", "guess")).Code)
+	entries, total, err := auditStore.List(context.Background(), audit.Filter{Action: "auth.login_failed"})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	require.Equal(t, "admin", entries[0].Actor)
+	require.Contains(t, string(entries[0].After), `"reason":"invalid_credential"`)
+
+	// 存在失败记录时，成功的 Basic 认证清除计数一次，之后不再清除。
+	for range 2 {
+		require.Equal(t, http.StatusOK, doAdminRequest(router, http.MethodGet, "/admin/rules", "", basicAuth("admin", "secret")).Code)
+	}
+	require.Equal(t, 1, store.resets)
+
+	// 显式登录成功后总是清除。
+	require.Equal(t, http.StatusOK, doAdminRequest(router, http.MethodPost, "/admin/login", `{"username":"admin","password":"secret"}`, nil).Code)
+	require.Equal(t, 2, store.resets)
+}
+
+func TestHandler_Login_IPLockoutIgnoresSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	guard := NewLoginGuard(nil, LoginPolicy{MaxAttempts: 100, IPMaxAttempts: 2, Lockout: time.Minute})
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute, WithLoginGuard(guard))
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth), auth.Middleware())
+
+	// 同一对端每次伪造不同的 X-Forwarded-For，失败仍计入对端地址，达到上限后被锁定。
+	spoof := func(ip string) func(*http.Request) {
+		return func(req *http.Request) { req.Header.Set("X-Forwarded-For", ip) }
+	}
+	for i, ip := range []string{"198.51.100.1", "198.51.100.2"} {
+		body := fmt.Sprintf(`{"username":"user%d","password":"guess"}`, i)
+		require.Equal(t, http.StatusUnauthorized, doAdminRequest(router, http.MethodPost, "/admin/login", body, spoof(ip)).Code)
+	}
+	rec := doAdminRequest(router, http.MethodPost, "/admin/login", `{"username":"admin","password":"secret"}`, spoof("198.51.100.3"))
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
@@ -0,0 +1,23 @@
+package admin
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec 是管理端 API 的 OpenAPI 3 文档，新增或调整路由时需同步更新 openapi.json。
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec 返回管理端 API 的 OpenAPI 3 文档。
+func OpenAPISpec() []byte {
+	return openAPISpec
+}
+
+func (h *Handler) openAPI(c *gin.Context) {
+	c.Set(rawResponseKey, true)
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openAPISpec)
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+var ginPathParam = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+func TestOpenAPISpec_CoversAllRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := NewHandler(&serviceStub{}, nil)
+	RegisterPublicRoutes(router.Group("/admin"), handler)
+	RegisterProtectedRoutes(router.Group("/admin"), handler)
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(OpenAPISpec(), &spec))
+	require.True(t, strings.HasPrefix(spec.OpenAPI, "3."))
+
+	documented := 0
+	for _, route := range router.Routes() {
+		path := ginPathParam.ReplaceAllString(strings.TrimPrefix(route.Path, "/admin"), "{$1}")
+		ops, ok := spec.Paths[path]
+		require.Truef(t, ok, "path %s missing from openapi.json", path)
+		_, ok = ops[strings.ToLower(route.Method)]
+		require.Truef(t, ok, "%s %s missing from openapi.json", route.Method, path)
+		documented++
+	}
+	operations := 0
+	for _, ops := range spec.Paths {
+		for method := range ops {
+			if method != "parameters" {
+				operations++
+			}
+		}
+	}
+	require.Equal(t, documented, operations, "openapi.json documents routes that are not registered")
+}
+
+func TestHandler_ServesOpenAPISpec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterPublicRoutes(router.Group("/admin"), NewHandler(&serviceStub{}, nil))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+	require.JSONEq(t, string(OpenAPISpec()), rec.Body.String())
+}
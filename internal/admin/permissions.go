@@ -0,0 +1,131 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/adminusers"
+	"github.com/prehisle/yapi/internal/errcode"
+)
+
+// ErrInvalidPermissions 当令牌申请的权限未知或超出账号角色时返回。
+var ErrInvalidPermissions = errors.New("invalid permissions")
+
+// adminPermissionsKey 是 gin 上下文中保存当前请求有效权限的键。
+const adminPermissionsKey = "admin_permissions"
+
+// Permission 是管理端接口的细粒度权限。令牌可只携带角色权限的子集，
+// 供只读看板、自动化脚本等场景使用最小权限。
+type Permission string
+
+const (
+	PermRulesRead       Permission = "rules:read"
+	PermRulesWrite      Permission = "rules:write"
+	PermAccountsRead    Permission = "accounts:read"
+	PermAccountsWrite   Permission = "accounts:write"
+	PermAuditRead       Permission = "audit:read"
+	PermEventsRead      Permission = "events:read"
+	PermRequestsRead    Permission = "requests:read"
+	PermAdminUsersRead  Permission = "admin_users:read"
+	PermAdminUsersWrite Permission = "admin_users:write"
+	PermSvcTokensRead   Permission = "service_tokens:read"
+	PermSvcTokensWrite  Permission = "service_tokens:write"
+	PermBackupRead      Permission = "backup:read"
+	PermBackupWrite     Permission = "backup:write"
+	PermConfigRead      Permission = "config:read"
+	PermConfigWrite     Permission = "config:write"
+	// 对话归档包含用户的提示词与模型响应，只授予 owner。
+	PermConversationsRead  Permission = "conversations:read"
+	PermConversationsWrite Permission = "conversations:write"
+	// 重放以原用户的身份真实请求上游并读取归档的提示词，只授予 owner。
+	PermRequestsReplay Permission = "requests:replay"
+)
+
+var (
+	viewerPermissions = []Permission{PermRulesRead, PermAccountsRead, PermAuditRead, PermEventsRead, PermRequestsRead}
+	editorPermissions = append(slices.Clone(viewerPermissions), PermRulesWrite, PermAccountsWrite)
+	ownerPermissions  = append(slices.Clone(editorPermissions), PermAdminUsersRead, PermAdminUsersWrite, PermSvcTokensRead, PermSvcTokensWrite, PermBackupRead, PermBackupWrite, PermConfigRead, PermConfigWrite, PermConversationsRead, PermConversationsWrite, PermRequestsReplay)
+)
+
+// PermissionsForRole 返回角色拥有的全部权限，未知角色没有任何权限。
+func PermissionsForRole(role adminusers.Role) []Permission {
+	switch role {
+	case adminusers.RoleOwner:
+		return slices.Clone(ownerPermissions)
+	case adminusers.RoleEditor:
+		return slices.Clone(editorPermissions)
+	case adminusers.RoleViewer:
+		return slices.Clone(viewerPermissions)
+	default:
+		return nil
+	}
+}
+
+// ParsePermissions 校验并去重权限名称。
+func ParsePermissions(values []string) ([]Permission, error) {
+	perms := make([]Permission, 0, len(values))
+	for _, value := range values {
+		perm := Permission(value)
+		if !slices.Contains(ownerPermissions, perm) {
+			return nil, fmt.Errorf("%w: unknown permission %q", ErrInvalidPermissions, value)
+		}
+		if !slices.Contains(perms, perm) {
+			perms = append(perms, perm)
+		}
+	}
+	return perms, nil
+}
+
+// narrowPermissions 返回 granted 与 scope 的交集；scope 为空表示令牌不做额外限制。
+func narrowPermissions(granted, scope []Permission) []Permission {
+	if len(scope) == 0 {
+		return granted
+	}
+	narrowed := make([]Permission, 0, len(scope))
+	for _, perm := range granted {
+		if slices.Contains(scope, perm) {
+			narrowed = append(narrowed, perm)
+		}
+	}
+	return narrowed
+}
+
+// ungrantedPermission 返回 perms 中调用者自身不具备的第一个权限，调用者不能授予或赋予超出自身权限的令牌与角色。
+// 未启用认证时上下文中没有有效权限，不做限制。
+func ungrantedPermission(c *gin.Context, perms []Permission) (Permission, bool) {
+	value, exists := c.Get(adminPermissionsKey)
+	if !exists {
+		return "", false
+	}
+	granted, _ := value.([]Permission)
+	for _, perm := range perms {
+		if !slices.Contains(granted, perm) {
+			return perm, true
+		}
+	}
+	return "", false
+}
+
+// RequirePermission 返回要求当前请求同时具备 perms 的中间件，需挂在 Middleware 之后。
+// 未启用认证时不做限制。
+func (a *Authenticator) RequirePermission(perms ...Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.LoginEnabled() {
+			c.Next()
+			return
+		}
+		value, _ := c.Get(adminPermissionsKey)
+		granted, _ := value.([]Permission)
+		for _, perm := range perms {
+			if !slices.Contains(granted, perm) {
+				errcode.Abort(c, http.StatusForbidden, errcode.PermissionDenied, fmt.Sprintf("forbidden: missing permission %s", perm))
+				return
+			}
+		}
+		c.Next()
+	}
+}
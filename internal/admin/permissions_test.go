@@ -0,0 +1,87 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/adminusers"
+)
+
+func TestAuthenticator_ScopedToken(t *testing.T) {
+	ctx := context.Background()
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+
+	pair, err := auth.IssueTokenPair(ctx, "admin", "secret", PermRulesRead, PermEventsRead)
+	require.NoError(t, err)
+	require.Equal(t, []Permission{PermRulesRead, PermEventsRead}, pair.Permissions)
+	identity, err := auth.Identify(ctx, pair.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, adminusers.RoleOwner, identity.Role)
+	require.Equal(t, []Permission{PermRulesRead, PermEventsRead}, identity.Permissions)
+
+	refreshed, err := auth.RefreshToken(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+	identity, err = auth.Identify(ctx, refreshed.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, []Permission{PermRulesRead, PermEventsRead}, identity.Permissions)
+
+	full, err := auth.IssueTokenPair(ctx, "admin", "secret")
+	require.NoError(t, err)
+	require.Equal(t, PermissionsForRole(adminusers.RoleOwner), full.Permissions)
+
+	_, err = ParsePermissions([]string{"rules:read", "rules:delete"})
+	require.ErrorIs(t, err, ErrInvalidPermissions)
+}
+
+func TestHandler_Login_ScopeBeyondRole(t *testing.T) {
+	router, users := setupAdminUsersRouter(t, "file:permissions_scope?mode=memory&cache=shared", "root", "root-secret")
+	_, err := users.Create(context.Background(), adminusers.CreateParams{Username: "bot", Password: "bot-password", Role: adminusers.RoleEditor})
+	require.NoError(t, err)
+
+	rec := doAdminRequest(router, http.MethodPost, "/admin/login", `{"username":"bot","password":"bot-password","permissions":["admin_users:write"]}`, nil)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doAdminRequest(router, http.MethodPost, "/admin/login", `{"username":"bot","password":"bot-password","permissions":["everything"]}`, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doAdminRequest(router, http.MethodPost, "/admin/login", `{"username":"bot","password":"bot-password","permissions":["rules:write"]}`, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var login tokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &login))
+	require.Equal(t, []Permission{PermRulesWrite}, login.Permissions)
+	bearer := func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+login.AccessToken) }
+
+	rec = doAdminRequest(router, http.MethodDelete, "/admin/rules/r1", "", bearer)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = doAdminRequest(router, http.MethodGet, "/admin/rules", "", bearer)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), string(PermRulesRead))
+}
+
+// 每条受保护路由都必须声明权限：仅持有 events:read 的令牌访问其余路由均应返回 403。
+func TestRegisterProtectedRoutes_EveryRouteRequiresPermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	router := gin.New()
+	group := router.Group("/admin")
+	group.Use(auth.Middleware())
+	RegisterProtectedRoutes(group, NewHandler(&serviceStub{}, auth))
+
+	token, err := auth.IssueTokenPair(context.Background(), "admin", "secret", PermEventsRead)
+	require.NoError(t, err)
+	bearer := func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token.AccessToken) }
+	for _, route := range router.Routes() {
+		if route.Path == "/admin/events" {
+			continue
+		}
+		path := strings.ReplaceAll(route.Path, ":id", "x")
+		rec := doAdminRequest(router, route.Method, path, "", bearer)
+		require.Equal(t, http.StatusForbidden, rec.Code, "%s %s", route.Method, route.Path)
+	}
+}
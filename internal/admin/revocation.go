@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RevocationStore 记录已吊销的令牌 ID（jti），条目在令牌自然过期后即可清除。
+// Revoke 须原子地检查并写入：仅当本次调用新吊销了令牌时返回 true，令牌此前已被吊销或已过期时返回 false，
+// 刷新令牌与一次性 state 依此保证并发使用时只有一次成功。
+type RevocationStore interface {
+	Revoke(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error)
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+}
+
+// MemoryRevocationStore 在进程内保存吊销记录，仅适用于单实例部署。
+type MemoryRevocationStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+	now     func() time.Time
+}
+
+// NewMemoryRevocationStore 创建进程内吊销列表。
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{revoked: make(map[string]time.Time), now: time.Now}
+}
+
+func (s *MemoryRevocationStore) Revoke(_ context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for id, exp := range s.revoked {
+		if !exp.After(now) {
+			delete(s.revoked, id)
+		}
+	}
+	if _, ok := s.revoked[tokenID]; ok || !expiresAt.After(now) {
+		return false, nil
+	}
+	s.revoked[tokenID] = expiresAt
+	return true, nil
+}
+
+func (s *MemoryRevocationStore) IsRevoked(_ context.Context, tokenID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exp, ok := s.revoked[tokenID]
+	return ok && exp.After(s.now()), nil
+}
+
+// RedisRevocationStore 将吊销记录写入 Redis，键的过期时间与令牌一致，多实例共享。
+type RedisRevocationStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisRevocationStore 创建基于 Redis 的吊销列表，键形如 <prefix>:<jti>。
+func NewRedisRevocationStore(client redis.UniversalClient, prefix string) *RedisRevocationStore {
+	return &RedisRevocationStore{client: client, prefix: prefix}
+}
+
+// Revoke 以 SET NX 写入吊销记录，多个实例并发吊销同一令牌时只有一个返回 true。
+func (s *RedisRevocationStore) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return false, nil
+	}
+	return s.client.SetNX(ctx, s.key(tokenID), 1, ttl).Result()
+}
+
+func (s *RedisRevocationStore) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	err := s.client.Get(ctx, s.key(tokenID)).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *RedisRevocationStore) key(tokenID string) string {
+	return s.prefix + ":" + tokenID
+}
@@ -0,0 +1,160 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/prehisle/yapi/internal/audit"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// ErrInvalidRuleSet 表示待比较的规则集不合法，如规则 ID 为空、重复或规则校验失败。
+var ErrInvalidRuleSet = errors.New("invalid rule set")
+
+// RulesDiffRequest 描述一次规则集比较。Base 为 nil 时与当前生效的规则比较，
+// 传入另一环境导出的规则即可比较两个环境。
+type RulesDiffRequest struct {
+	Base      *[]rules.Rule `json:"base,omitempty"`
+	Candidate []rules.Rule  `json:"candidate"`
+}
+
+// RulesDiff 是 Candidate 相对 Base 的差异，各列表按规则 ID 排序。比较时忽略版本号、修改人与时间戳。
+type RulesDiff struct {
+	Added     []rules.Rule `json:"added"`
+	Removed   []rules.Rule `json:"removed"`
+	Changed   []RuleChange `json:"changed"`
+	Unchanged int          `json:"unchanged"`
+}
+
+// RuleChange 是单条规则的变更，Fields 以点分路径（如 actions.set_headers.X-Team）列出变化的字段。
+type RuleChange struct {
+	ID     string                  `json:"id"`
+	Before rules.Rule              `json:"before"`
+	After  rules.Rule              `json:"after"`
+	Fields map[string]audit.Change `json:"fields"`
+}
+
+// DiffRules 比较两个规则集，不做任何修改。
+func (s *service) DiffRules(ctx context.Context, req RulesDiffRequest) (RulesDiff, error) {
+	candidate, err := indexRuleSet("candidate", req.Candidate, true)
+	if err != nil {
+		return RulesDiff{}, err
+	}
+	var baseRules []rules.Rule
+	if req.Base != nil {
+		baseRules = *req.Base
+	} else if baseRules, err = s.rules.ListRules(ctx); err != nil {
+		return RulesDiff{}, err
+	}
+	base, err := indexRuleSet("base", baseRules, req.Base != nil)
+	if err != nil {
+		return RulesDiff{}, err
+	}
+	diff := RulesDiff{Added: []rules.Rule{}, Removed: []rules.Rule{}, Changed: []RuleChange{}}
+	for _, id := range sortedRuleIDs(candidate) {
+		after := candidate[id]
+		before, ok := base[id]
+		if !ok {
+			diff.Added = append(diff.Added, after)
+			continue
+		}
+		fields, err := ruleFieldChanges(before, after)
+		if err != nil {
+			return RulesDiff{}, err
+		}
+		if len(fields) == 0 {
+			diff.Unchanged++
+			continue
+		}
+		diff.Changed = append(diff.Changed, RuleChange{ID: id, Before: before, After: after, Fields: fields})
+	}
+	for _, id := range sortedRuleIDs(base) {
+		if _, ok := candidate[id]; !ok {
+			diff.Removed = append(diff.Removed, base[id])
+		}
+	}
+	return diff, nil
+}
+
+// indexRuleSet 按 ID 索引规则并去除版本与修改信息；validate 为 true 时逐条校验外部传入的规则。
+func indexRuleSet(name string, list []rules.Rule, validate bool) (map[string]rules.Rule, error) {
+	indexed := make(map[string]rules.Rule, len(list))
+	for _, rule := range list {
+		rule = ruleSpec(rule)
+		if validate {
+			if err := rule.Validate(); err != nil {
+				return nil, fmt.Errorf("%w: %s rule %q: %v", ErrInvalidRuleSet, name, rule.ID, err)
+			}
+		}
+		if _, ok := indexed[rule.ID]; ok {
+			return nil, fmt.Errorf("%w: duplicate %s rule %q", ErrInvalidRuleSet, name, rule.ID)
+		}
+		indexed[rule.ID] = rule
+	}
+	return indexed, nil
+}
+
+func sortedRuleIDs(indexed map[string]rules.Rule) []string {
+	ids := make([]string, 0, len(indexed))
+	for id := range indexed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ruleFieldChanges 将两条规则展开为点分路径后逐项比较；数组作为整体比较。
+func ruleFieldChanges(before, after rules.Rule) (map[string]audit.Change, error) {
+	prev, err := flattenRule(before)
+	if err != nil {
+		return nil, err
+	}
+	next, err := flattenRule(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := make(map[string]audit.Change)
+	for path, value := range prev {
+		if other, ok := next[path]; !ok || !reflect.DeepEqual(value, other) {
+			changes[path] = audit.Change{Before: value, After: next[path]}
+		}
+	}
+	for path, value := range next {
+		if _, ok := prev[path]; !ok {
+			changes[path] = audit.Change{After: value}
+		}
+	}
+	return changes, nil
+}
+
+func flattenRule(rule rules.Rule) (map[string]any, error) {
+	encoded, err := json.Marshal(rule)
+	if err != nil {
+		return nil, err
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, err
+	}
+	flat := make(map[string]any)
+	flattenInto(flat, "", decoded)
+	return flat, nil
+}
+
+func flattenInto(flat map[string]any, prefix string, value map[string]any) {
+	for key, child := range value {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := child.(map[string]any); ok && len(nested) > 0 {
+			flattenInto(flat, path, nested)
+			continue
+		}
+		flat[path] = child
+	}
+}
@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/audit"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+func TestHandler_DiffRules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	ruleService := rules.NewService(rules.NewMemoryStore())
+	current := []rules.Rule{
+		{ID: "chat", Priority: 10, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1/chat"},
+			Actions: rules.Actions{SetTargetURL: "https://api.openai.com", SetHeaders: map[string]string{"X-Team": "a"}}},
+		{ID: "legacy", Priority: 1, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v0"}, Actions: rules.Actions{SetTargetURL: "https://old.example.com"}},
+		{ID: "models", Priority: 5, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1/models"}, Actions: rules.Actions{SetTargetURL: "https://api.openai.com"}},
+	}
+	for _, rule := range current {
+		require.NoError(t, ruleService.UpsertRule(ctx, rule))
+	}
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(NewService(ruleService, nil), auth), auth.Middleware())
+
+	changed := current[0]
+	changed.Priority = 20
+	changed.Actions.SetHeaders = map[string]string{"X-Team": "b"}
+	candidate := []rules.Rule{
+		changed,
+		current[2],
+		{ID: "embeddings", Priority: 5, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1/embeddings"}, Actions: rules.Actions{SetTargetURL: "https://api.openai.com"}},
+	}
+	body, err := json.Marshal(RulesDiffRequest{Candidate: candidate})
+	require.NoError(t, err)
+	rec := doAdminRequest(router, http.MethodPost, "/admin/rules/diff", string(body), basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var diff RulesDiff
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &diff))
+	require.Len(t, diff.Added, 1)
+	require.Equal(t, "embeddings", diff.Added[0].ID)
+	require.Len(t, diff.Removed, 1)
+	require.Equal(t, "legacy", diff.Removed[0].ID)
+	require.Equal(t, 1, diff.Unchanged)
+	require.Len(t, diff.Changed, 1)
+	require.Equal(t, "chat", diff.Changed[0].ID)
+	require.Equal(t, map[string]audit.Change{
+		"priority":                   {Before: float64(10), After: float64(20)},
+		"actions.set_headers.X-Team": {Before: "a", After: "b"},
+	}, diff.Changed[0].Fields)
+
+	// 同时提供 base 时比较两个规则集，与当前规则无关。
+	body, err = json.Marshal(RulesDiffRequest{Base: &[]rules.Rule{}, Candidate: candidate[:1]})
+	require.NoError(t, err)
+	rec = doAdminRequest(router, http.MethodPost, "/admin/rules/diff", string(body), basicAuth("admin", "secret"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &diff))
+	require.Len(t, diff.Added, 1)
+	require.Empty(t, diff.Removed)
+
+	for _, invalid := range []RulesDiffRequest{
+		{Candidate: []rules.Rule{current[1], current[1]}},
+		{Candidate: []rules.Rule{{ID: "no-target", Matcher: rules.Matcher{PathPrefix: "/v1"}}}},
+	} {
+		body, err = json.Marshal(invalid)
+		require.NoError(t, err)
+		rec = doAdminRequest(router, http.MethodPost, "/admin/rules/diff", string(body), basicAuth("admin", "secret"))
+		require.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	}
+}
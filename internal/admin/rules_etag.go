@@ -0,0 +1,120 @@
+package admin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/pkg/metrics"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// WithRequireIfMatch 要求 PUT、PATCH、DELETE /rules/:id 与启用、停用规则时携带 If-Match，缺少时返回 428，
+// 避免两位管理员基于同一版本编辑时后保存者静默覆盖先保存者的修改。未启用时只校验请求中给出的 If-Match。
+func WithRequireIfMatch() Option {
+	return func(h *Handler) {
+		h.requireIfMatch = true
+	}
+}
+
+// ruleETag 返回规则当前版本的强实体标签，由规则 ID、版本号与更新时间计算，
+// 删除后重建的同名规则即使版本号相同也得到不同的标签。
+func ruleETag(rule rules.Rule) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s\x00%d\x00%d", rule.ID, rule.Version, rule.UpdatedAt.UnixMicro()))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// ifMatches 按强比较判断 If-Match 请求头（逗号分隔的实体标签列表或 *）是否匹配 etag。
+func ifMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// rulePrecondition 是 If-Match 校验的结果。conditional 为 true 时请求头匹配了 version 对应的 ETag，
+// 后续写入须交给存储按该版本号条件执行，校验与写入之间规则被他人修改时写入失败并返回 412。
+type rulePrecondition struct {
+	conditional bool
+	version     int
+}
+
+// checkRuleIfMatch 在修改规则前校验 If-Match：规则存在时请求头须匹配当前 ETag，启用 WithRequireIfMatch 时
+// 缺少请求头返回 428；规则不存在时 allowCreate 为 true 且未带 If-Match 才放行，否则返回 412。
+// 删除不存在的规则（allowCreate 为 false）不检查前提条件，交由后续处理返回 404。返回 false 时已写入错误响应。
+func (h *Handler) checkRuleIfMatch(c *gin.Context, action, id string, allowCreate bool) (rulePrecondition, bool) {
+	header := c.GetHeader("If-Match")
+	current, err := h.service.GetRule(c.Request.Context(), id)
+	switch {
+	case err == nil:
+		if !h.matchRuleETag(c, action, current) {
+			return rulePrecondition{}, false
+		}
+		header = strings.TrimSpace(header)
+		return rulePrecondition{conditional: header != "" && header != "*", version: current.Version}, true
+	case errors.Is(err, rules.ErrRuleNotFound):
+		if !allowCreate || header == "" {
+			return rulePrecondition{}, true
+		}
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusPreconditionFailed, errcode.PreconditionFailed, fmt.Sprintf("rule %s no longer exists", id))
+		return rulePrecondition{}, false
+	default:
+		h.logError(c, "get rule failed", err, map[string]any{
+			"user": currentAdminUser(c),
+			"rule": id,
+		})
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusInternalServerError, errcode.Internal, err.Error())
+		return rulePrecondition{}, false
+	}
+}
+
+// matchRuleETag 校验 If-Match 是否匹配已存在的规则 current：启用 WithRequireIfMatch 时缺少请求头返回 428，
+// 不匹配时返回 412 并附带最新的 ETag。返回 false 时已写入错误响应。
+func (h *Handler) matchRuleETag(c *gin.Context, action string, current rules.Rule) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		if !h.requireIfMatch {
+			return true
+		}
+		metrics.ObserveAdminAction(action, false)
+		errcode.Respond(c, http.StatusPreconditionRequired, errcode.PreconditionRequired,
+			"If-Match header is required; fetch the rule and send its ETag")
+		return false
+	}
+	if ifMatches(header, ruleETag(current)) {
+		return true
+	}
+	c.Header("ETag", ruleETag(current))
+	metrics.ObserveAdminAction(action, false)
+	errcode.Respond(c, http.StatusPreconditionFailed, errcode.PreconditionFailed,
+		fmt.Sprintf("rule %s has changed since it was fetched (current version %d)", current.ID, current.Version))
+	return false
+}
+
+// saveRule 按 If-Match 校验结果保存规则：匹配了具体 ETag 时以版本号为条件原子更新。
+func (h *Handler) saveRule(ctx context.Context, rule rules.Rule, pre rulePrecondition) error {
+	if pre.conditional {
+		return h.service.UpdateRuleIfVersion(ctx, rule, pre.version)
+	}
+	return h.service.CreateOrUpdateRule(ctx, rule)
+}
+
+// removeRule 与 saveRule 相同，匹配了具体 ETag 时以版本号为条件原子删除。
+func (h *Handler) removeRule(ctx context.Context, id string, pre rulePrecondition) error {
+	if pre.conditional {
+		return h.service.DeleteRuleIfVersion(ctx, id, pre.version)
+	}
+	return h.service.DeleteRule(ctx, id)
+}
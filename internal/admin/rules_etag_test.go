@@ -0,0 +1,125 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+func TestHandler_RuleIfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ruleService := rules.NewService(rules.NewMemoryStore())
+	ctx := context.Background()
+	require.NoError(t, ruleService.UpsertRule(ctx, rules.Rule{
+		ID:      "rule-etag",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: "https://example.com"},
+	}))
+	router := gin.New()
+	RegisterProtectedRoutes(router.Group("/admin"), NewHandler(NewService(ruleService, nil), nil, WithRequireIfMatch()))
+	send := func(method, path, body, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	update := `{"id":"rule-etag","enabled":true,"matcher":{"path_prefix":"/v2"},"actions":{"set_target_url":"https://example.com"}}`
+
+	rec := send(http.MethodGet, "/admin/rules/rule-etag", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	require.Regexp(t, `^"[0-9a-f]{16}"$`, etag)
+	var detail ruleDetailResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &detail))
+	require.Equal(t, etag, detail.ETag)
+
+	rec = send(http.MethodGet, "/admin/rules", "", "")
+	var list listRulesResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Equal(t, etag, list.Items[0].ETag)
+
+	rec = send(http.MethodPut, "/admin/rules/rule-etag", update, "")
+	require.Equal(t, http.StatusPreconditionRequired, rec.Code)
+	require.Contains(t, rec.Body.String(), "YAPI_PRECONDITION_REQUIRED")
+
+	rec = send(http.MethodPut, "/admin/rules/rule-etag", update, etag)
+	require.Equal(t, http.StatusOK, rec.Code)
+	next := rec.Header().Get("ETag")
+	require.NotEmpty(t, next)
+	require.NotEqual(t, etag, next)
+
+	// 另一位管理员仍持有旧的 ETag，保存与删除都被拒绝，响应附带最新的 ETag。
+	rec = send(http.MethodPut, "/admin/rules/rule-etag", update, etag)
+	require.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	require.Contains(t, rec.Body.String(), "YAPI_PRECONDITION_FAILED")
+	require.Equal(t, next, rec.Header().Get("ETag"))
+	rec = send(http.MethodDelete, "/admin/rules/rule-etag", "", etag)
+	require.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	rule, err := ruleService.GetRule(ctx, "rule-etag")
+	require.NoError(t, err)
+	require.Equal(t, "/v2", rule.Matcher.PathPrefix)
+
+	// PATCH 与 PUT 一样须携带匹配的 If-Match，成功后返回新的 ETag。
+	patch := `{"priority":3}`
+	require.Equal(t, http.StatusPreconditionRequired, send(http.MethodPatch, "/admin/rules/rule-etag", patch, "").Code)
+	require.Equal(t, http.StatusPreconditionFailed, send(http.MethodPatch, "/admin/rules/rule-etag", patch, etag).Code)
+	rec = send(http.MethodPatch, "/admin/rules/rule-etag", patch, next)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotEqual(t, next, rec.Header().Get("ETag"))
+	next = rec.Header().Get("ETag")
+
+	// 启用与停用同样校验 If-Match，即使状态未变化也不会放过过期的 ETag。
+	require.Equal(t, http.StatusPreconditionRequired, send(http.MethodPost, "/admin/rules/rule-etag/disable", "", "").Code)
+	require.Equal(t, http.StatusPreconditionFailed, send(http.MethodPost, "/admin/rules/rule-etag/enable", "", etag).Code)
+	rec = send(http.MethodPost, "/admin/rules/rule-etag/disable", "", next)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotEqual(t, next, rec.Header().Get("ETag"))
+	require.Equal(t, http.StatusPreconditionFailed, send(http.MethodPost, "/admin/rules/rule-etag/enable", "", next).Code)
+	next = rec.Header().Get("ETag")
+	rec = send(http.MethodPost, "/admin/rules/rule-etag/enable", "", next)
+	require.Equal(t, http.StatusOK, rec.Code)
+	next = rec.Header().Get("ETag")
+
+	require.Equal(t, http.StatusPreconditionRequired, send(http.MethodDelete, "/admin/rules/rule-etag", "", "").Code)
+	require.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/admin/rules/rule-etag", "", `"stale", `+next).Code)
+	require.Equal(t, http.StatusNotFound, send(http.MethodDelete, "/admin/rules/rule-etag", "", next).Code)
+
+	// 规则不存在时带 If-Match 的 PUT 不会重新创建规则；不带时照常创建。
+	require.Equal(t, http.StatusPreconditionFailed, send(http.MethodPut, "/admin/rules/rule-etag", update, next).Code)
+	require.Equal(t, http.StatusOK, send(http.MethodPut, "/admin/rules/rule-etag", update, "").Code)
+	require.Equal(t, http.StatusOK, send(http.MethodPut, "/admin/rules/rule-etag", update, "*").Code)
+}
+
+func TestHandler_RuleIfMatchOptional(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ruleService := rules.NewService(rules.NewMemoryStore())
+	require.NoError(t, ruleService.UpsertRule(context.Background(), rules.Rule{
+		ID:      "rule-etag",
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: "https://example.com"},
+	}))
+	router := newTestRouter(NewService(ruleService, nil))
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/rules/rule-etag", nil)
+	req.Header.Set("If-Match", `"stale"`)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusPreconditionFailed, rec.Code, "a supplied If-Match is checked even when not required")
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/rules/rule-etag", nil))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+}
@@ -0,0 +1,92 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// ErrInvalidExplainRequest 表示样例请求不合法，如缺少路径。
+var ErrInvalidExplainRequest = errors.New("invalid explain request")
+
+// RuleExplainRequest 描述用于解释规则匹配的样例请求。认证相关字段对应代理在认证后得到的上下文：
+// 填写 BindingUpstreamID 或 Provider 即视为请求携带上游绑定。
+type RuleExplainRequest struct {
+	Method            string            `json:"method"`
+	Path              string            `json:"path"`
+	Headers           map[string]string `json:"headers,omitempty"`
+	APIKeyID          string            `json:"api_key_id,omitempty"`
+	APIKeyPrefix      string            `json:"api_key_prefix,omitempty"`
+	UserID            string            `json:"user_id,omitempty"`
+	UserMetadata      map[string]any    `json:"user_metadata,omitempty"`
+	BindingUpstreamID string            `json:"binding_upstream_id,omitempty"`
+	Provider          string            `json:"provider,omitempty"`
+}
+
+// RuleExplanation 是规则对样例请求的逐条件评估结果。Matched 表示全部条件满足；
+// EffectiveRuleID 为代理按优先级实际会选中的启用规则（不含默认上游），为空表示没有规则匹配；
+// Fires 仅在本规则启用、匹配且没有被更高优先级的规则抢先命中时为 true。
+type RuleExplanation struct {
+	RuleID          string                  `json:"rule_id"`
+	Enabled         bool                    `json:"enabled"`
+	Matched         bool                    `json:"matched"`
+	Fires           bool                    `json:"fires"`
+	EffectiveRuleID string                  `json:"effective_rule_id,omitempty"`
+	Conditions      []rules.ConditionResult `json:"conditions"`
+}
+
+// ExplainRule 评估规则的每个匹配条件能否命中样例请求，不做任何修改。
+func (s *service) ExplainRule(ctx context.Context, id string, req RuleExplainRequest) (RuleExplanation, error) {
+	if !strings.HasPrefix(req.Path, "/") {
+		return RuleExplanation{}, fmt.Errorf("%w: path must start with '/'", ErrInvalidExplainRequest)
+	}
+	rule, err := s.rules.GetRule(ctx, id)
+	if err != nil {
+		return RuleExplanation{}, err
+	}
+	allRules, err := s.rules.ListRules(ctx)
+	if err != nil {
+		return RuleExplanation{}, err
+	}
+	sample := req.matchRequest()
+	explanation := RuleExplanation{
+		RuleID:     rule.ID,
+		Enabled:    rule.Enabled,
+		Conditions: rule.Matcher.Explain(sample),
+	}
+	explanation.Matched = true
+	for _, condition := range explanation.Conditions {
+		explanation.Matched = explanation.Matched && condition.Matched
+	}
+	for _, candidate := range allRules {
+		if candidate.Enabled && candidate.Matcher.Matches(sample) {
+			explanation.EffectiveRuleID = candidate.ID
+			break
+		}
+	}
+	explanation.Fires = explanation.EffectiveRuleID == rule.ID
+	return explanation, nil
+}
+
+func (r RuleExplainRequest) matchRequest() rules.MatchRequest {
+	header := make(http.Header, len(r.Headers))
+	for key, value := range r.Headers {
+		header.Set(key, value)
+	}
+	return rules.MatchRequest{
+		Path:              r.Path,
+		Method:            strings.ToUpper(r.Method),
+		Header:            header,
+		APIKeyID:          r.APIKeyID,
+		APIKeyPrefix:      r.APIKeyPrefix,
+		UserID:            r.UserID,
+		UserMetadata:      r.UserMetadata,
+		HasBinding:        r.BindingUpstreamID != "" || r.Provider != "",
+		BindingUpstreamID: r.BindingUpstreamID,
+		Provider:          r.Provider,
+	}
+}
@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+func TestHandler_ExplainRule(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	ruleService := rules.NewService(rules.NewMemoryStore())
+	for _, rule := range []rules.Rule{
+		{ID: "chat", Priority: 10, Enabled: true, Actions: rules.Actions{SetTargetURL: "https://api.openai.com"}, Matcher: rules.Matcher{
+			PathPrefix: "/v1/chat",
+			Methods:    []string{"POST"},
+			Headers:    map[string]string{"X-Env": "^prod$"},
+		}},
+		{ID: "catch-all", Priority: 1, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}, Actions: rules.Actions{SetTargetURL: "https://example.com"}},
+	} {
+		require.NoError(t, ruleService.UpsertRule(ctx, rule))
+	}
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(NewService(ruleService, nil), auth), auth.Middleware())
+
+	explain := func(id, body string) (int, RuleExplanation) {
+		rec := doAdminRequest(router, http.MethodPost, "/admin/rules/"+id+"/explain", body, basicAuth("admin", "secret"))
+		var explanation RuleExplanation
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &explanation))
+		}
+		return rec.Code, explanation
+	}
+
+	status, explanation := explain("chat", `{"method":"get","path":"/v1/chat/completions","headers":{"x-env":"staging"}}`)
+	require.Equal(t, http.StatusOK, status)
+	require.False(t, explanation.Matched)
+	require.False(t, explanation.Fires)
+	require.Equal(t, "catch-all", explanation.EffectiveRuleID)
+	require.Len(t, explanation.Conditions, 3)
+	require.Equal(t, "path_prefix", explanation.Conditions[0].Condition)
+	require.True(t, explanation.Conditions[0].Matched)
+	require.Equal(t, "methods", explanation.Conditions[1].Condition)
+	require.Equal(t, "GET", explanation.Conditions[1].Actual)
+	require.False(t, explanation.Conditions[1].Matched)
+	require.Equal(t, rules.ConditionResult{Condition: "headers.X-Env", Expected: "^prod$", Actual: "staging"}, explanation.Conditions[2])
+
+	status, explanation = explain("chat", `{"method":"POST","path":"/v1/chat/completions","headers":{"X-Env":"prod"}}`)
+	require.Equal(t, http.StatusOK, status)
+	require.True(t, explanation.Matched)
+	require.True(t, explanation.Fires)
+	require.Equal(t, "chat", explanation.EffectiveRuleID)
+
+	status, _ = explain("missing", `{"path":"/v1"}`)
+	require.Equal(t, http.StatusNotFound, status)
+	status, _ = explain("chat", `{"path":"v1"}`)
+	require.Equal(t, http.StatusBadRequest, status)
+}
@@ -3,6 +3,9 @@ package admin
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/prehisle/yapi/pkg/accounts"
 	"github.com/prehisle/yapi/pkg/rules"
@@ -10,38 +13,116 @@ import (
 
 var ErrAccountsUnavailable = errors.New("accounts service unavailable")
 
+// ErrVerifierUnavailable 表示未配置上游凭据检查器。
+var ErrVerifierUnavailable = errors.New("upstream verifier unavailable")
+
+// UpstreamVerifier 对上游凭据执行实时校验并记录结果。
+type UpstreamVerifier interface {
+	Verify(ctx context.Context, cred accounts.UpstreamCredential) (accounts.UpstreamHealth, error)
+}
+
+// SecretRefChecker 检查密钥引用是否在允许读取的范围内，见 secrets.CachingResolver.Check。
+type SecretRefChecker interface {
+	Check(ref string) error
+}
+
 // Service 定义管理端对规则的操作接口。
 type Service interface {
 	ListRules(ctx context.Context) ([]rules.Rule, error)
 	GetRule(ctx context.Context, id string) (rules.Rule, error)
 	CreateOrUpdateRule(ctx context.Context, rule rules.Rule) error
 	DeleteRule(ctx context.Context, id string) error
+	// UpdateRuleIfVersion 与 DeleteRuleIfVersion 仅当规则当前版本号等于 version 时写入，否则返回 rules.ErrVersionConflict。
+	UpdateRuleIfVersion(ctx context.Context, rule rules.Rule, version int) error
+	DeleteRuleIfVersion(ctx context.Context, id string, version int) error
 
 	CreateUser(ctx context.Context, params accounts.CreateUserParams) (accounts.User, error)
-	ListUsers(ctx context.Context) ([]accounts.User, error)
+	ListUsers(ctx context.Context, opts accounts.ListOptions) ([]accounts.User, int64, error)
+	GetUser(ctx context.Context, id string) (accounts.User, error)
+	GetUserDetail(ctx context.Context, id string) (UserDetail, error)
+	SetUserRateLimits(ctx context.Context, id string, limits accounts.RateLimits) (accounts.User, error)
+	SetUserModelAccess(ctx context.Context, id string, access accounts.ModelAccess) (accounts.User, error)
 	DeleteUser(ctx context.Context, id string) error
 
 	CreateUserAPIKey(ctx context.Context, params accounts.CreateAPIKeyParams) (accounts.APIKey, string, error)
-	ListUserAPIKeys(ctx context.Context, userID string) ([]accounts.APIKey, error)
+	ListUserAPIKeys(ctx context.Context, userID string, opts accounts.ListOptions) ([]accounts.APIKey, int64, error)
 	RevokeUserAPIKey(ctx context.Context, apiKeyID string) error
+	GetUserAPIKey(ctx context.Context, apiKeyID string) (accounts.APIKey, error)
+	SetUserAPIKeyEnabled(ctx context.Context, apiKeyID string, enabled bool) error
+	SetAPIKeyRateLimits(ctx context.Context, apiKeyID string, limits accounts.RateLimits) (accounts.APIKey, error)
+	SetAPIKeyModelAccess(ctx context.Context, apiKeyID string, access accounts.ModelAccess) (accounts.APIKey, error)
 
 	CreateUpstreamCredential(ctx context.Context, params accounts.CreateUpstreamCredentialParams) (accounts.UpstreamCredential, error)
 	UpdateUpstreamCredential(ctx context.Context, params accounts.UpdateUpstreamCredentialParams) (accounts.UpstreamCredential, error)
-	ListUpstreamCredentials(ctx context.Context, userID string) ([]accounts.UpstreamCredential, error)
+	ListUpstreamCredentials(ctx context.Context, userID string, opts accounts.ListOptions) ([]accounts.UpstreamCredential, int64, error)
 	DeleteUpstreamCredential(ctx context.Context, credentialID string) error
+	GetUpstreamCredential(ctx context.Context, credentialID string) (accounts.UpstreamCredential, error)
+	SetUpstreamCredentialEnabled(ctx context.Context, credentialID string, enabled bool) error
+	SetUpstreamCredentialDefault(ctx context.Context, credentialID string, isDefault bool) (accounts.UpstreamCredential, error)
+	VerifyUpstreamCredential(ctx context.Context, credentialID string) (accounts.UpstreamHealth, error)
+
+	CreateUpstreamKeyPool(ctx context.Context, params accounts.CreateUpstreamKeyPoolParams) (accounts.UpstreamKeyPool, error)
+	ListUpstreamKeyPools(ctx context.Context, userID string) ([]accounts.UpstreamKeyPool, error)
+	UpdateUpstreamKeyPool(ctx context.Context, params accounts.UpdateUpstreamKeyPoolParams) (accounts.UpstreamKeyPool, error)
+	DeleteUpstreamKeyPool(ctx context.Context, poolID string) error
 
 	BindAPIKey(ctx context.Context, params accounts.BindAPIKeyParams) (accounts.UserAPIKeyBinding, error)
 	GetBindingByAPIKeyID(ctx context.Context, apiKeyID string) (accounts.UserAPIKeyBinding, accounts.UpstreamCredential, error)
+	ListBindingsByAPIKey(ctx context.Context, apiKeyID string) ([]accounts.BindingWithUpstream, error)
+	ListBindingsByUser(ctx context.Context, userID string) ([]accounts.BindingWithUpstream, error)
+	DeleteBinding(ctx context.Context, bindingID string) error
+
+	DiffRules(ctx context.Context, req RulesDiffRequest) (RulesDiff, error)
+	ExplainRule(ctx context.Context, id string, req RuleExplainRequest) (RuleExplanation, error)
+
+	PlanApply(ctx context.Context, desired DesiredState) (ApplyPlan, error)
+	Apply(ctx context.Context, desired DesiredState, opts ApplyOptions) (ApplyPlan, error)
+
+	Backup(ctx context.Context, opts BackupOptions) (Backup, error)
+	Restore(ctx context.Context, backup Backup, opts RestoreOptions) (RestoreResult, error)
+}
+
+// UserDetail 汇总用户及其名下的 API Key、上游凭据与绑定，供详情页一次取回。
+type UserDetail struct {
+	User      accounts.User
+	APIKeys   []accounts.APIKey
+	Upstreams []accounts.UpstreamCredential
+	Bindings  []accounts.BindingWithUpstream
 }
 
 type service struct {
 	rules    rules.Service
 	accounts accounts.Service
+	verifier UpstreamVerifier
+	secrets  SecretRefChecker
+	// applyMu 串行化声明式 apply 的执行与回滚。
+	applyMu sync.Mutex
+}
+
+// ServiceOption 定义管理端服务可选项。
+type ServiceOption func(*service)
+
+// WithUpstreamVerifier 设置上游凭据检查器。
+func WithUpstreamVerifier(verifier UpstreamVerifier) ServiceOption {
+	return func(s *service) {
+		s.verifier = verifier
+	}
+}
+
+// WithSecretRefChecker 设置密钥引用检查器，创建、更新与恢复凭据时拒绝不允许读取的引用。
+func WithSecretRefChecker(checker SecretRefChecker) ServiceOption {
+	return func(s *service) {
+		s.secrets = checker
+	}
 }
 
 // NewService 创建管理端默认实现。
-func NewService(rules rules.Service, accounts accounts.Service) Service {
-	return &service{rules: rules, accounts: accounts}
+func NewService(rules rules.Service, accounts accounts.Service, opts ...ServiceOption) Service {
+	s := &service{rules: rules, accounts: accounts}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *service) ListRules(ctx context.Context) ([]rules.Rule, error) {
@@ -60,6 +141,14 @@ func (s *service) DeleteRule(ctx context.Context, id string) error {
 	return s.rules.DeleteRule(ctx, id)
 }
 
+func (s *service) UpdateRuleIfVersion(ctx context.Context, rule rules.Rule, version int) error {
+	return s.rules.UpsertRuleIfVersion(ctx, rule, version)
+}
+
+func (s *service) DeleteRuleIfVersion(ctx context.Context, id string, version int) error {
+	return s.rules.DeleteRuleIfVersion(ctx, id, version)
+}
+
 func (s *service) CreateUser(ctx context.Context, params accounts.CreateUserParams) (accounts.User, error) {
 	if s.accounts == nil {
 		return accounts.User{}, ErrAccountsUnavailable
@@ -67,11 +156,53 @@ func (s *service) CreateUser(ctx context.Context, params accounts.CreateUserPara
 	return s.accounts.CreateUser(ctx, params)
 }
 
-func (s *service) ListUsers(ctx context.Context) ([]accounts.User, error) {
+func (s *service) ListUsers(ctx context.Context, opts accounts.ListOptions) ([]accounts.User, int64, error) {
 	if s.accounts == nil {
-		return nil, ErrAccountsUnavailable
+		return nil, 0, ErrAccountsUnavailable
 	}
-	return s.accounts.ListUsers(ctx)
+	return s.accounts.ListUsers(ctx, opts)
+}
+
+func (s *service) GetUser(ctx context.Context, id string) (accounts.User, error) {
+	if s.accounts == nil {
+		return accounts.User{}, ErrAccountsUnavailable
+	}
+	return s.accounts.GetUser(ctx, id)
+}
+
+func (s *service) SetUserRateLimits(ctx context.Context, id string, limits accounts.RateLimits) (accounts.User, error) {
+	if s.accounts == nil {
+		return accounts.User{}, ErrAccountsUnavailable
+	}
+	return s.accounts.SetUserRateLimits(ctx, id, limits)
+}
+
+func (s *service) SetUserModelAccess(ctx context.Context, id string, access accounts.ModelAccess) (accounts.User, error) {
+	if s.accounts == nil {
+		return accounts.User{}, ErrAccountsUnavailable
+	}
+	return s.accounts.SetUserModelAccess(ctx, id, access)
+}
+
+func (s *service) GetUserDetail(ctx context.Context, id string) (UserDetail, error) {
+	if s.accounts == nil {
+		return UserDetail{}, ErrAccountsUnavailable
+	}
+	user, err := s.accounts.GetUser(ctx, id)
+	if err != nil {
+		return UserDetail{}, err
+	}
+	detail := UserDetail{User: user}
+	if detail.APIKeys, _, err = s.accounts.ListUserAPIKeys(ctx, id, accounts.ListOptions{}); err != nil {
+		return UserDetail{}, err
+	}
+	if detail.Upstreams, _, err = s.accounts.ListUpstreamCredentials(ctx, id, accounts.ListOptions{}); err != nil {
+		return UserDetail{}, err
+	}
+	if detail.Bindings, err = s.accounts.ListBindingsByUser(ctx, id); err != nil {
+		return UserDetail{}, err
+	}
+	return detail, nil
 }
 
 func (s *service) DeleteUser(ctx context.Context, id string) error {
@@ -88,11 +219,11 @@ func (s *service) CreateUserAPIKey(ctx context.Context, params accounts.CreateAP
 	return s.accounts.CreateUserAPIKey(ctx, params)
 }
 
-func (s *service) ListUserAPIKeys(ctx context.Context, userID string) ([]accounts.APIKey, error) {
+func (s *service) ListUserAPIKeys(ctx context.Context, userID string, opts accounts.ListOptions) ([]accounts.APIKey, int64, error) {
 	if s.accounts == nil {
-		return nil, ErrAccountsUnavailable
+		return nil, 0, ErrAccountsUnavailable
 	}
-	return s.accounts.ListUserAPIKeys(ctx, userID)
+	return s.accounts.ListUserAPIKeys(ctx, userID, opts)
 }
 
 func (s *service) RevokeUserAPIKey(ctx context.Context, apiKeyID string) error {
@@ -102,10 +233,41 @@ func (s *service) RevokeUserAPIKey(ctx context.Context, apiKeyID string) error {
 	return s.accounts.RevokeUserAPIKey(ctx, apiKeyID)
 }
 
+func (s *service) GetUserAPIKey(ctx context.Context, apiKeyID string) (accounts.APIKey, error) {
+	if s.accounts == nil {
+		return accounts.APIKey{}, ErrAccountsUnavailable
+	}
+	return s.accounts.GetUserAPIKey(ctx, apiKeyID)
+}
+
+func (s *service) SetUserAPIKeyEnabled(ctx context.Context, apiKeyID string, enabled bool) error {
+	if s.accounts == nil {
+		return ErrAccountsUnavailable
+	}
+	return s.accounts.SetUserAPIKeyEnabled(ctx, apiKeyID, enabled)
+}
+
+func (s *service) SetAPIKeyRateLimits(ctx context.Context, apiKeyID string, limits accounts.RateLimits) (accounts.APIKey, error) {
+	if s.accounts == nil {
+		return accounts.APIKey{}, ErrAccountsUnavailable
+	}
+	return s.accounts.SetAPIKeyRateLimits(ctx, apiKeyID, limits)
+}
+
+func (s *service) SetAPIKeyModelAccess(ctx context.Context, apiKeyID string, access accounts.ModelAccess) (accounts.APIKey, error) {
+	if s.accounts == nil {
+		return accounts.APIKey{}, ErrAccountsUnavailable
+	}
+	return s.accounts.SetAPIKeyModelAccess(ctx, apiKeyID, access)
+}
+
 func (s *service) CreateUpstreamCredential(ctx context.Context, params accounts.CreateUpstreamCredentialParams) (accounts.UpstreamCredential, error) {
 	if s.accounts == nil {
 		return accounts.UpstreamCredential{}, ErrAccountsUnavailable
 	}
+	if err := s.checkSecretRef(params.SecretRef); err != nil {
+		return accounts.UpstreamCredential{}, err
+	}
 	return s.accounts.CreateUpstreamCredential(ctx, params)
 }
 
@@ -113,14 +275,31 @@ func (s *service) UpdateUpstreamCredential(ctx context.Context, params accounts.
 	if s.accounts == nil {
 		return accounts.UpstreamCredential{}, ErrAccountsUnavailable
 	}
+	if params.SecretRef != nil {
+		if err := s.checkSecretRef(*params.SecretRef); err != nil {
+			return accounts.UpstreamCredential{}, err
+		}
+	}
 	return s.accounts.UpdateUpstreamCredential(ctx, params)
 }
 
-func (s *service) ListUpstreamCredentials(ctx context.Context, userID string) ([]accounts.UpstreamCredential, error) {
+// checkSecretRef 拒绝不允许读取的密钥引用；空引用与未设置检查器时不做限制，格式由 accounts 校验。
+func (s *service) checkSecretRef(ref string) error {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || s.secrets == nil {
+		return nil
+	}
+	if err := s.secrets.Check(ref); err != nil {
+		return fmt.Errorf("%w: upstream credential secret_ref: %w", accounts.ErrInvalidInput, err)
+	}
+	return nil
+}
+
+func (s *service) ListUpstreamCredentials(ctx context.Context, userID string, opts accounts.ListOptions) ([]accounts.UpstreamCredential, int64, error) {
 	if s.accounts == nil {
-		return nil, ErrAccountsUnavailable
+		return nil, 0, ErrAccountsUnavailable
 	}
-	return s.accounts.ListUpstreamCredentials(ctx, userID)
+	return s.accounts.ListUpstreamCredentials(ctx, userID, opts)
 }
 
 func (s *service) DeleteUpstreamCredential(ctx context.Context, credentialID string) error {
@@ -130,6 +309,41 @@ func (s *service) DeleteUpstreamCredential(ctx context.Context, credentialID str
 	return s.accounts.DeleteUpstreamCredential(ctx, credentialID)
 }
 
+func (s *service) GetUpstreamCredential(ctx context.Context, credentialID string) (accounts.UpstreamCredential, error) {
+	if s.accounts == nil {
+		return accounts.UpstreamCredential{}, ErrAccountsUnavailable
+	}
+	return s.accounts.GetUpstreamCredential(ctx, credentialID)
+}
+
+func (s *service) SetUpstreamCredentialEnabled(ctx context.Context, credentialID string, enabled bool) error {
+	if s.accounts == nil {
+		return ErrAccountsUnavailable
+	}
+	return s.accounts.SetUpstreamCredentialEnabled(ctx, credentialID, enabled)
+}
+
+func (s *service) SetUpstreamCredentialDefault(ctx context.Context, credentialID string, isDefault bool) (accounts.UpstreamCredential, error) {
+	if s.accounts == nil {
+		return accounts.UpstreamCredential{}, ErrAccountsUnavailable
+	}
+	return s.accounts.SetUpstreamCredentialDefault(ctx, credentialID, isDefault)
+}
+
+func (s *service) VerifyUpstreamCredential(ctx context.Context, credentialID string) (accounts.UpstreamHealth, error) {
+	if s.accounts == nil {
+		return accounts.UpstreamHealth{}, ErrAccountsUnavailable
+	}
+	if s.verifier == nil {
+		return accounts.UpstreamHealth{}, ErrVerifierUnavailable
+	}
+	cred, err := s.accounts.GetUpstreamCredential(ctx, credentialID)
+	if err != nil {
+		return accounts.UpstreamHealth{}, err
+	}
+	return s.verifier.Verify(ctx, cred)
+}
+
 func (s *service) BindAPIKey(ctx context.Context, params accounts.BindAPIKeyParams) (accounts.UserAPIKeyBinding, error) {
 	if s.accounts == nil {
 		return accounts.UserAPIKeyBinding{}, ErrAccountsUnavailable
@@ -143,3 +357,52 @@ func (s *service) GetBindingByAPIKeyID(ctx context.Context, apiKeyID string) (ac
 	}
 	return s.accounts.GetBindingByAPIKeyID(ctx, apiKeyID)
 }
+
+func (s *service) ListBindingsByAPIKey(ctx context.Context, apiKeyID string) ([]accounts.BindingWithUpstream, error) {
+	if s.accounts == nil {
+		return nil, ErrAccountsUnavailable
+	}
+	return s.accounts.ListBindingsByAPIKey(ctx, apiKeyID)
+}
+
+func (s *service) ListBindingsByUser(ctx context.Context, userID string) ([]accounts.BindingWithUpstream, error) {
+	if s.accounts == nil {
+		return nil, ErrAccountsUnavailable
+	}
+	return s.accounts.ListBindingsByUser(ctx, userID)
+}
+
+func (s *service) DeleteBinding(ctx context.Context, bindingID string) error {
+	if s.accounts == nil {
+		return ErrAccountsUnavailable
+	}
+	return s.accounts.DeleteBinding(ctx, bindingID)
+}
+
+func (s *service) CreateUpstreamKeyPool(ctx context.Context, params accounts.CreateUpstreamKeyPoolParams) (accounts.UpstreamKeyPool, error) {
+	if s.accounts == nil {
+		return accounts.UpstreamKeyPool{}, ErrAccountsUnavailable
+	}
+	return s.accounts.CreateUpstreamKeyPool(ctx, params)
+}
+
+func (s *service) ListUpstreamKeyPools(ctx context.Context, userID string) ([]accounts.UpstreamKeyPool, error) {
+	if s.accounts == nil {
+		return nil, ErrAccountsUnavailable
+	}
+	return s.accounts.ListUpstreamKeyPools(ctx, userID)
+}
+
+func (s *service) UpdateUpstreamKeyPool(ctx context.Context, params accounts.UpdateUpstreamKeyPoolParams) (accounts.UpstreamKeyPool, error) {
+	if s.accounts == nil {
+		return accounts.UpstreamKeyPool{}, ErrAccountsUnavailable
+	}
+	return s.accounts.UpdateUpstreamKeyPool(ctx, params)
+}
+
+func (s *service) DeleteUpstreamKeyPool(ctx context.Context, poolID string) error {
+	if s.accounts == nil {
+		return ErrAccountsUnavailable
+	}
+	return s.accounts.DeleteUpstreamKeyPool(ctx, poolID)
+}
@@ -0,0 +1,137 @@
+package admin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cookie 会话使用的 Cookie 与请求头名称。访问令牌与刷新令牌放在 HttpOnly Cookie 中，
+// CSRF 令牌放在可被前端脚本读取的 Cookie 中，写操作需通过 CSRFHeader 回传。
+const (
+	SessionCookie = "yapi_admin_session"
+	RefreshCookie = "yapi_admin_refresh"
+	CSRFCookie    = "yapi_admin_csrf"
+	CSRFHeader    = "X-CSRF-Token"
+)
+
+// ErrInvalidCSRF 当 Cookie 会话的写请求缺少或携带错误的 CSRF 令牌时返回。
+var ErrInvalidCSRF = errors.New("invalid csrf token")
+
+// SessionCookieOptions 配置会话 Cookie 的属性。
+type SessionCookieOptions struct {
+	// Path 限定 Cookie 的作用路径，默认 /admin，覆盖旧路径与 /admin/v1。
+	Path   string
+	Domain string
+	// Insecure 为 true 时不设置 Secure 属性，仅用于本地 HTTP 调试。
+	Insecure bool
+	// SameSite 默认 Strict。
+	SameSite http.SameSite
+}
+
+// WithSessionCookies 设置 Cookie 会话的属性；未设置时使用默认值（Path=/admin、Secure、SameSite=Strict）。
+func WithSessionCookies(opts SessionCookieOptions) Option {
+	return func(h *Handler) {
+		h.cookies = opts
+	}
+}
+
+// ParseSameSite 解析 strict、lax、none，其他取值返回 false。
+func ParseSameSite(value string) (http.SameSite, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "strict":
+		return http.SameSiteStrictMode, true
+	case "lax":
+		return http.SameSiteLaxMode, true
+	case "none":
+		return http.SameSiteNoneMode, true
+	default:
+		return 0, false
+	}
+}
+
+// sessionResponse 是 Cookie 会话登录或刷新的响应，令牌本身只写入 Cookie。
+type sessionResponse struct {
+	TokenType        string       `json:"token_type"`
+	ExpiresIn        int          `json:"expires_in"`
+	RefreshExpiresIn int          `json:"refresh_expires_in"`
+	CSRFToken        string       `json:"csrf_token"`
+	Permissions      []Permission `json:"permissions"`
+}
+
+// writeSession 将令牌对写入 Cookie 并返回会话响应。
+func (h *Handler) writeSession(c *gin.Context, pair TokenPair) {
+	h.setCookie(c, SessionCookie, pair.AccessToken, int(pair.AccessExpiresIn.Seconds()), true)
+	h.setCookie(c, RefreshCookie, pair.RefreshToken, int(pair.RefreshExpiresIn.Seconds()), true)
+	h.setCookie(c, CSRFCookie, pair.CSRFToken, int(pair.RefreshExpiresIn.Seconds()), false)
+	c.JSON(http.StatusOK, sessionResponse{
+		TokenType:        "Cookie",
+		ExpiresIn:        int(pair.AccessExpiresIn.Seconds()),
+		RefreshExpiresIn: int(pair.RefreshExpiresIn.Seconds()),
+		CSRFToken:        pair.CSRFToken,
+		Permissions:      pair.Permissions,
+	})
+}
+
+// clearSession 删除会话 Cookie。
+func (h *Handler) clearSession(c *gin.Context) {
+	for _, name := range []string{SessionCookie, RefreshCookie, CSRFCookie} {
+		h.setCookie(c, name, "", -1, name != CSRFCookie)
+	}
+}
+
+func (h *Handler) setCookie(c *gin.Context, name, value string, maxAge int, httpOnly bool) {
+	http.SetCookie(c.Writer, h.newCookie(name, value, maxAge, httpOnly))
+}
+
+// newCookie 按 SessionCookieOptions 构造 Cookie。
+func (h *Handler) newCookie(name, value string, maxAge int, httpOnly bool) *http.Cookie {
+	path := h.cookies.Path
+	if path == "" {
+		path = "/admin"
+	}
+	sameSite := h.cookies.SameSite
+	if sameSite == 0 || sameSite == http.SameSiteDefaultMode {
+		sameSite = http.SameSiteStrictMode
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   h.cookies.Domain,
+		MaxAge:   maxAge,
+		Secure:   !h.cookies.Insecure || sameSite == http.SameSiteNoneMode,
+		HttpOnly: httpOnly,
+		SameSite: sameSite,
+	}
+}
+
+// csrfToken 由会话 ID 派生 CSRF 令牌，无需服务端存储；同一会话刷新令牌后保持不变。
+func (a *Authenticator) csrfToken(sessionID string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte("csrf:" + sessionID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyCSRF 校验请求携带的 CSRF 令牌是否属于该会话。
+func (a *Authenticator) verifyCSRF(sessionID, token string) bool {
+	if sessionID == "" || token == "" {
+		return false
+	}
+	return hmac.Equal([]byte(a.csrfToken(sessionID)), []byte(token))
+}
+
+// safeMethod 判断请求方法是否无副作用，此类请求不校验 CSRF。
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_CookieSession_RequiresCSRFForWrites(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthenticator("admin", "secret", "sign-key", time.Minute)
+	router := gin.New()
+	Mount(router.Group("/admin"), NewHandler(&serviceStub{}, auth), auth.Middleware())
+
+	rec := doAdminRequest(router, http.MethodPost, "/admin/login", `{"username":"admin","password":"secret","cookie":true}`, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), "access_token")
+	var session sessionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &session))
+	require.Equal(t, "Cookie", session.TokenType)
+	require.NotEmpty(t, session.CSRFToken)
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range rec.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	require.True(t, cookies[SessionCookie].HttpOnly)
+	require.True(t, cookies[SessionCookie].Secure)
+	require.Equal(t, http.SameSiteStrictMode, cookies[SessionCookie].SameSite)
+	require.False(t, cookies[CSRFCookie].HttpOnly)
+	require.Equal(t, session.CSRFToken, cookies[CSRFCookie].Value)
+
+	withCookies := func(csrf string) func(*http.Request) {
+		return func(req *http.Request) {
+			req.AddCookie(cookies[SessionCookie])
+			req.AddCookie(cookies[RefreshCookie])
+			if csrf != "" {
+				req.Header.Set(CSRFHeader, csrf)
+			}
+		}
+	}
+	rec = doAdminRequest(router, http.MethodGet, "/admin/rules", "", withCookies(""))
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = doAdminRequest(router, http.MethodDelete, "/admin/rules/r1", "", withCookies(""))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doAdminRequest(router, http.MethodDelete, "/admin/rules/r1", "", withCookies("forged"))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doAdminRequest(router, http.MethodDelete, "/admin/rules/r1", "", withCookies(session.CSRFToken))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	// 刷新同样需要 CSRF 令牌，会话 ID 不变因此 CSRF 令牌保持不变。
+	rec = doAdminRequest(router, http.MethodPost, "/admin/token/refresh", "", withCookies(""))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = doAdminRequest(router, http.MethodPost, "/admin/token/refresh", "", withCookies(session.CSRFToken))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var refreshed sessionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &refreshed))
+	require.Equal(t, session.CSRFToken, refreshed.CSRFToken)
+
+	// 普通 Bearer 令牌不能作为会话 Cookie 使用。
+	rec = doAdminRequest(router, http.MethodPost, "/admin/login", `{"username":"admin","password":"secret"}`, nil)
+	var bearer tokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &bearer))
+	rec = doAdminRequest(router, http.MethodGet, "/admin/rules", "", func(req *http.Request) {
+		req.AddCookie(&http.Cookie{Name: SessionCookie, Value: bearer.AccessToken})
+	})
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = doAdminRequest(router, http.MethodPost, "/admin/logout", "", withCookies(""))
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	for _, cookie := range rec.Result().Cookies() {
+		require.Equal(t, -1, cookie.MaxAge)
+	}
+}
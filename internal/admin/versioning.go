@@ -0,0 +1,190 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+)
+
+// rawResponseKey 标记当前响应无需封装（如 OpenAPI 文档需保持原样供工具解析）。
+const rawResponseKey = "admin_raw_response"
+
+const (
+	// LegacyPrefix 是未版本化的管理端路径前缀，仅为兼容已有调用方保留。
+	LegacyPrefix = "/admin"
+	// V1Prefix 是当前版本管理端 API 的路径前缀。
+	V1Prefix = "/admin/v1"
+)
+
+// Mount 在 group 上注册公共路由，并在其子分组上以 authMiddleware 保护其余管理路由；
+// authMiddleware 拒绝的 Basic 认证失败记入审计日志。
+func Mount(group *gin.RouterGroup, handler *Handler, authMiddleware gin.HandlerFunc) {
+	RegisterPublicRoutes(group, handler)
+	protected := group.Group("")
+	if authMiddleware != nil {
+		protected.Use(handler.auditBasicAuthFailure, authMiddleware)
+	}
+	RegisterProtectedRoutes(protected, handler)
+}
+
+// DeprecationHeaders 为未版本化路径的响应添加 Deprecation 头，并通过 Link 指向新版本中的对应路径。
+func DeprecationHeaders(prefix, successorPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		successor := successorPrefix + strings.TrimPrefix(c.Request.URL.Path, prefix)
+		c.Header("Deprecation", "true")
+		c.Header("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		c.Next()
+	}
+}
+
+// dataEnvelope 是版本化接口成功响应的统一外层结构。
+type dataEnvelope struct {
+	Data json.RawMessage `json:"data"`
+}
+
+// errorEnvelope 是版本化接口错误响应的统一外层结构。
+type errorEnvelope struct {
+	Error envelopeError `json:"error"`
+}
+
+type envelopeError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Envelope 将处理器输出的 JSON 响应封装为 {"data": ...} 或 {"error": {"code", "message", "request_id"}}。
+//
+// 处理器本身无需感知版本：响应先写入缓冲区，处理结束后再统一改写；非 JSON 响应（如事件流）原样透传。
+func Envelope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := &envelopeWriter{ResponseWriter: c.Writer, ctx: c, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		w.finish()
+	}
+}
+
+type envelopeWriter struct {
+	gin.ResponseWriter
+	ctx         *gin.Context
+	status      int
+	body        bytes.Buffer
+	passthrough bool
+}
+
+func (w *envelopeWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *envelopeWriter) WriteHeaderNow() {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *envelopeWriter) Write(data []byte) (int, error) {
+	w.checkPassthrough()
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *envelopeWriter) WriteString(s string) (int, error) {
+	w.checkPassthrough()
+	if w.passthrough {
+		return w.ResponseWriter.WriteString(s)
+	}
+	return w.body.WriteString(s)
+}
+
+func (w *envelopeWriter) Status() int {
+	if w.passthrough {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *envelopeWriter) Size() int {
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	return w.body.Len()
+}
+
+func (w *envelopeWriter) Written() bool {
+	return w.ResponseWriter.Written() || w.body.Len() > 0
+}
+
+func (w *envelopeWriter) Flush() {
+	if w.passthrough {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// checkPassthrough 在首次写入非 JSON 或标记为原样输出的内容时切换为直写模式。
+func (w *envelopeWriter) checkPassthrough() {
+	if w.passthrough {
+		return
+	}
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") && !w.ctx.GetBool(rawResponseKey) {
+		return
+	}
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.body.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	}
+}
+
+func (w *envelopeWriter) finish() {
+	if w.passthrough {
+		return
+	}
+	if w.body.Len() == 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	var envelope any = dataEnvelope{Data: w.body.Bytes()}
+	if w.status >= http.StatusBadRequest {
+		envelope = errorEnvelope{Error: parseErrorBody(w.body.Bytes(), w.status)}
+	}
+	encoded, err := json.Marshal(envelope)
+	if err != nil {
+		encoded = w.body.Bytes()
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(encoded)
+}
+
+// parseErrorBody 从处理器输出的 {"error", "code", "request_id"} 中取出错误码、描述与请求 ID，错误码与描述缺失时按状态码补全。
+func parseErrorBody(body []byte, status int) envelopeError {
+	var payload struct {
+		Error     string `json:"error"`
+		Code      string `json:"code"`
+		RequestID string `json:"request_id"`
+	}
+	_ = json.Unmarshal(body, &payload)
+	parsed := envelopeError{Code: payload.Code, Message: payload.Error, RequestID: payload.RequestID}
+	if parsed.Code == "" {
+		parsed.Code = string(errcode.ForStatus(status))
+	}
+	if parsed.Message == "" {
+		parsed.Message = http.StatusText(status)
+	}
+	return parsed
+}
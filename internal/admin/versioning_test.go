@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+func newVersionedTestRouter(t *testing.T) (*gin.Engine, rules.Service) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ruleService := rules.NewService(rules.NewMemoryStore())
+	auth := NewAuthenticator("admin", "secret", "signing-key", time.Hour)
+	handler := NewHandler(NewService(ruleService, nil), auth)
+	router := gin.New()
+	v1 := router.Group(V1Prefix)
+	v1.Use(Envelope())
+	Mount(v1, handler, auth.Middleware())
+	legacy := router.Group(LegacyPrefix)
+	legacy.Use(DeprecationHeaders(LegacyPrefix, V1Prefix))
+	Mount(legacy, handler, auth.Middleware())
+	return router, ruleService
+}
+
+func TestEnvelope_WrapsV1Responses(t *testing.T) {
+	router, ruleService := newVersionedTestRouter(t)
+	require.NoError(t, ruleService.UpsertRule(context.Background(), rules.Rule{
+		ID:      "rule-v1",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: "https://example.com"},
+	}))
+
+	send := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.SetBasicAuth("admin", "secret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(http.MethodGet, "/admin/v1/rules/rule-v1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Header().Get("Deprecation"))
+	var ok struct {
+		Data ruleDetailResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ok))
+	require.Equal(t, "rule-v1", ok.Data.ID)
+
+	rec = send(http.MethodGet, "/admin/v1/rules/missing")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	var failed errorEnvelope
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &failed))
+	require.Equal(t, "YAPI_NOT_FOUND", failed.Error.Code)
+	require.NotEmpty(t, failed.Error.Message)
+
+	rec = send(http.MethodDelete, "/admin/v1/rules/rule-v1")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Empty(t, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/v1/rules", nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.JSONEq(t, `{"error":{"code":"YAPI_UNAUTHORIZED","message":"unauthorized"}}`, rec.Body.String())
+}
+
+func TestDeprecationHeaders_LegacyPaths(t *testing.T) {
+	router, _ := newVersionedTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/healthz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "true", rec.Header().Get("Deprecation"))
+	require.Equal(t, `</admin/v1/healthz>; rel="successor-version"`, rec.Header().Get("Link"))
+	require.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
@@ -0,0 +1,253 @@
+// Package adminusers 管理可登录管理端的账号及其角色，账号保存在 admin_users 表，密码以 bcrypt 存储。
+package adminusers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound 表示账号不存在。
+	ErrNotFound = errors.New("admin user not found")
+	// ErrInvalidInput 表示参数不合法。
+	ErrInvalidInput = errors.New("invalid admin user input")
+	// ErrConflict 表示用户名重复或操作会移除最后一个 owner。
+	ErrConflict = errors.New("admin user conflict")
+	// ErrInvalidCredential 表示用户名或密码错误，或账号已停用。
+	ErrInvalidCredential = errors.New("invalid credential")
+)
+
+// Role 是管理端角色，权限依次递增：viewer 只读，editor 可修改规则与账户，owner 可管理管理员账号。
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleOwner  Role = "owner"
+)
+
+var roleRank = map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// Valid 判断是否为已知角色。
+func (r Role) Valid() bool {
+	_, ok := roleRank[r]
+	return ok
+}
+
+// Allows 判断当前角色是否满足 required 的要求。
+func (r Role) Allows(required Role) bool {
+	return roleRank[r] >= roleRank[required] && r.Valid()
+}
+
+const minPasswordLength = 8
+
+// User 是一个管理端账号。
+type User struct {
+	ID           string `gorm:"type:char(36);primaryKey"`
+	Username     string `gorm:"type:varchar(128);uniqueIndex"`
+	PasswordHash string `gorm:"type:varchar(255)"`
+	Role         Role   `gorm:"type:varchar(16)"`
+	Disabled     bool   `gorm:"type:boolean;default:false"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// TableName 指定表名。
+func (User) TableName() string {
+	return "admin_users"
+}
+
+// CreateParams 描述新建账号。
+type CreateParams struct {
+	Username string
+	Password string
+	Role     Role
+}
+
+// UpdateParams 描述账号的局部更新，nil 字段保持不变。
+type UpdateParams struct {
+	ID       string
+	Password *string
+	Role     *Role
+	Disabled *bool
+}
+
+// Service 定义管理端账号的存取与认证。
+type Service interface {
+	AutoMigrate(ctx context.Context) error
+	Create(ctx context.Context, params CreateParams) (User, error)
+	List(ctx context.Context) ([]User, error)
+	Get(ctx context.Context, id string) (User, error)
+	GetByUsername(ctx context.Context, username string) (User, error)
+	Update(ctx context.Context, params UpdateParams) (User, error)
+	Delete(ctx context.Context, id string) error
+	Count(ctx context.Context) (int64, error)
+	Authenticate(ctx context.Context, username, password string) (User, error)
+}
+
+type service struct {
+	db         *gorm.DB
+	bcryptCost int
+	// dummyHash 用于在用户不存在时执行等价的 bcrypt 比对，避免通过响应时间枚举用户名。
+	dummyHash []byte
+}
+
+// NewService 基于 gorm.DB 创建账号服务。
+func NewService(db *gorm.DB) Service {
+	dummy, _ := bcrypt.GenerateFromPassword([]byte("yapi-admin-placeholder"), bcrypt.DefaultCost)
+	return &service{db: db, bcryptCost: bcrypt.DefaultCost, dummyHash: dummy}
+}
+
+func (s *service) AutoMigrate(ctx context.Context) error {
+	return s.db.WithContext(ctx).AutoMigrate(&User{})
+}
+
+func (s *service) Create(ctx context.Context, params CreateParams) (User, error) {
+	username := strings.TrimSpace(params.Username)
+	if username == "" || len(username) > 128 {
+		return User{}, fmt.Errorf("%w: username must be 1-128 characters", ErrInvalidInput)
+	}
+	if !params.Role.Valid() {
+		return User{}, fmt.Errorf("%w: unknown role %q", ErrInvalidInput, params.Role)
+	}
+	hash, err := s.hashPassword(params.Password)
+	if err != nil {
+		return User{}, err
+	}
+	user := User{ID: uuid.NewString(), Username: username, PasswordHash: hash, Role: params.Role}
+	if _, err := s.GetByUsername(ctx, username); err == nil {
+		return User{}, fmt.Errorf("%w: username already exists", ErrConflict)
+	} else if !errors.Is(err, ErrNotFound) {
+		return User{}, err
+	}
+	if err := s.db.WithContext(ctx).Create(&user).Error; err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (s *service) List(ctx context.Context) ([]User, error) {
+	var users []User
+	if err := s.db.WithContext(ctx).Order("username ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s *service) Get(ctx context.Context, id string) (User, error) {
+	return s.first(ctx, "id = ?", id)
+}
+
+func (s *service) GetByUsername(ctx context.Context, username string) (User, error) {
+	return s.first(ctx, "username = ?", strings.TrimSpace(username))
+}
+
+func (s *service) Update(ctx context.Context, params UpdateParams) (User, error) {
+	user, err := s.Get(ctx, params.ID)
+	if err != nil {
+		return User{}, err
+	}
+	updates := map[string]any{"updated_at": time.Now()}
+	if params.Password != nil {
+		hash, err := s.hashPassword(*params.Password)
+		if err != nil {
+			return User{}, err
+		}
+		updates["password_hash"] = hash
+	}
+	if params.Role != nil {
+		if !params.Role.Valid() {
+			return User{}, fmt.Errorf("%w: unknown role %q", ErrInvalidInput, *params.Role)
+		}
+		updates["role"] = *params.Role
+	}
+	if params.Disabled != nil {
+		updates["disabled"] = *params.Disabled
+	}
+	demoted := params.Role != nil && *params.Role != RoleOwner
+	disabled := params.Disabled != nil && *params.Disabled
+	if user.Role == RoleOwner && !user.Disabled && (demoted || disabled) {
+		if err := s.ensureAnotherOwner(ctx, user.ID); err != nil {
+			return User{}, err
+		}
+	}
+	if err := s.db.WithContext(ctx).Model(&User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+		return User{}, err
+	}
+	return s.Get(ctx, user.ID)
+}
+
+func (s *service) Delete(ctx context.Context, id string) error {
+	user, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user.Role == RoleOwner && !user.Disabled {
+		if err := s.ensureAnotherOwner(ctx, user.ID); err != nil {
+			return err
+		}
+	}
+	return s.db.WithContext(ctx).Where("id = ?", id).Delete(&User{}).Error
+}
+
+func (s *service) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&User{}).Count(&count).Error
+	return count, err
+}
+
+func (s *service) Authenticate(ctx context.Context, username, password string) (User, error) {
+	user, err := s.GetByUsername(ctx, username)
+	if errors.Is(err, ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+		return User{}, ErrInvalidCredential
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil || user.Disabled {
+		return User{}, ErrInvalidCredential
+	}
+	return user, nil
+}
+
+func (s *service) first(ctx context.Context, query string, arg string) (User, error) {
+	var user User
+	err := s.db.WithContext(ctx).Where(query, arg).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return User{}, ErrNotFound
+	}
+	return user, err
+}
+
+// ensureAnotherOwner 确保除 excludeID 外仍有启用的 owner，避免管理端被锁死。
+func (s *service) ensureAnotherOwner(ctx context.Context, excludeID string) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&User{}).
+		Where("role = ? AND disabled = ? AND id <> ?", RoleOwner, false, excludeID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: at least one enabled owner is required", ErrConflict)
+	}
+	return nil
+}
+
+func (s *service) hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("%w: password must be at least %d characters", ErrInvalidInput, minPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,81 @@
+package adminusers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupService(t *testing.T, dsn string) Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	svc := NewService(db)
+	require.NoError(t, svc.AutoMigrate(context.Background()))
+	return svc
+}
+
+func TestService_CreateAndAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	svc := setupService(t, "file:adminusers_auth?mode=memory&cache=shared")
+
+	user, err := svc.Create(ctx, CreateParams{Username: " alice ", Password: "correct-horse", Role: RoleEditor})
+	require.NoError(t, err)
+	require.Equal(t, "alice", user.Username)
+	require.NotContains(t, user.PasswordHash, "correct-horse")
+
+	_, err = svc.Create(ctx, CreateParams{Username: "alice", Password: "another-pass", Role: RoleViewer})
+	require.ErrorIs(t, err, ErrConflict)
+	_, err = svc.Create(ctx, CreateParams{Username: "bob", Password: "short", Role: RoleViewer})
+	require.ErrorIs(t, err, ErrInvalidInput)
+	_, err = svc.Create(ctx, CreateParams{Username: "bob", Password: "long-enough", Role: "admin"})
+	require.ErrorIs(t, err, ErrInvalidInput)
+
+	got, err := svc.Authenticate(ctx, "alice", "correct-horse")
+	require.NoError(t, err)
+	require.Equal(t, RoleEditor, got.Role)
+	_, err = svc.Authenticate(ctx, "alice", "wrong-password")
+	require.ErrorIs(t, err, ErrInvalidCredential)
+	_, err = svc.Authenticate(ctx, "nobody", "correct-horse")
+	require.ErrorIs(t, err, ErrInvalidCredential)
+
+	disabled := true
+	_, err = svc.Update(ctx, UpdateParams{ID: user.ID, Disabled: &disabled})
+	require.NoError(t, err)
+	_, err = svc.Authenticate(ctx, "alice", "correct-horse")
+	require.ErrorIs(t, err, ErrInvalidCredential)
+}
+
+func TestService_ProtectsLastOwner(t *testing.T) {
+	ctx := context.Background()
+	svc := setupService(t, "file:adminusers_owner?mode=memory&cache=shared")
+
+	first, err := svc.Create(ctx, CreateParams{Username: "root", Password: "root-password", Role: RoleOwner})
+	require.NoError(t, err)
+	viewer := RoleViewer
+	_, err = svc.Update(ctx, UpdateParams{ID: first.ID, Role: &viewer})
+	require.ErrorIs(t, err, ErrConflict)
+	require.ErrorIs(t, svc.Delete(ctx, first.ID), ErrConflict)
+
+	second, err := svc.Create(ctx, CreateParams{Username: "backup", Password: "backup-password", Role: RoleOwner})
+	require.NoError(t, err)
+	demoted, err := svc.Update(ctx, UpdateParams{ID: first.ID, Role: &viewer})
+	require.NoError(t, err)
+	require.Equal(t, RoleViewer, demoted.Role)
+	require.ErrorIs(t, svc.Delete(ctx, second.ID), ErrConflict)
+	require.NoError(t, svc.Delete(ctx, first.ID))
+
+	count, err := svc.Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+}
+
+func TestRole_Allows(t *testing.T) {
+	require.True(t, RoleOwner.Allows(RoleEditor))
+	require.True(t, RoleEditor.Allows(RoleViewer))
+	require.False(t, RoleViewer.Allows(RoleEditor))
+	require.False(t, Role("").Allows(RoleViewer))
+}
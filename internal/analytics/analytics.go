@@ -0,0 +1,252 @@
+// Package analytics 把代理请求与用量事件异步、批量写入 ClickHouse 或 Postgres 分析表，
+// 用于超出 Prometheus 保留期的长期分析。事件先进入有界队列，由后台协程按批量大小或刷新间隔写出；
+// 队列满时按丢弃策略处理，写入失败按退避重试后丢弃，均不阻塞或影响请求转发。
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// Event 是一次代理请求的分析记录。
+type Event struct {
+	Time             time.Time `json:"time"`
+	RequestID        string    `json:"request_id"`
+	RuleID           string    `json:"rule_id"`
+	Method           string    `json:"method"`
+	Path             string    `json:"path"`
+	Status           int       `json:"status"`
+	LatencyMs        int64     `json:"latency_ms"`
+	Upstream         string    `json:"upstream"`
+	Provider         string    `json:"provider"`
+	UserID           string    `json:"user_id"`
+	CredentialID     string    `json:"credential_id"`
+	Model            string    `json:"model"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	CostUSD          float64   `json:"cost_usd"`
+}
+
+// Writer 把一批事件写入存储。
+type Writer interface {
+	Write(ctx context.Context, events []Event) error
+}
+
+// DropPolicy 决定队列已满时如何处理新事件。
+type DropPolicy string
+
+const (
+	// DropNewest 丢弃新到的事件，请求路径上开销最小。
+	DropNewest DropPolicy = "drop_newest"
+	// DropOldest 丢弃队列中最早的事件，为新事件腾出位置。
+	DropOldest DropPolicy = "drop_oldest"
+	// Block 在 BlockTimeout 内等待队列空位以施加背压，超时后丢弃新事件。
+	Block DropPolicy = "block"
+)
+
+// ErrInvalidDropPolicy 表示丢弃策略取值错误。
+var ErrInvalidDropPolicy = errors.New("invalid analytics drop policy")
+
+// ParseDropPolicy 解析丢弃策略，空字符串返回 DropNewest。
+func ParseDropPolicy(raw string) (DropPolicy, error) {
+	switch policy := DropPolicy(strings.ToLower(strings.TrimSpace(raw))); policy {
+	case "":
+		return DropNewest, nil
+	case DropNewest, DropOldest, Block:
+		return policy, nil
+	}
+	return "", fmt.Errorf("%w %q: want drop_newest, drop_oldest or block", ErrInvalidDropPolicy, raw)
+}
+
+// Options 控制批量写出与背压行为，零值字段取默认值。
+type Options struct {
+	// QueueSize 为待写事件的队列容量，默认 10000。
+	QueueSize int
+	// BatchSize 为单次写出的最大事件数，默认 500。
+	BatchSize int
+	// FlushInterval 为未攒满一批时的最长等待时间，默认 5 秒。
+	FlushInterval time.Duration
+	// DropPolicy 为队列满时的处理方式，默认 DropNewest。
+	DropPolicy DropPolicy
+	// BlockTimeout 为 Block 策略下的最长等待时间，默认 50 毫秒。
+	BlockTimeout time.Duration
+	// MaxRetries 为写入失败后的重试次数，默认 2，负数表示不重试；WriteTimeout 为单次写入超时，默认 10 秒。
+	MaxRetries   int
+	WriteTimeout time.Duration
+	Logger       *slog.Logger
+}
+
+func (o Options) withDefaults() Options {
+	if o.QueueSize <= 0 {
+		o.QueueSize = 10000
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 500
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = 5 * time.Second
+	}
+	if o.DropPolicy == "" {
+		o.DropPolicy = DropNewest
+	}
+	if o.BlockTimeout <= 0 {
+		o.BlockTimeout = 50 * time.Millisecond
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	} else if o.MaxRetries == 0 {
+		o.MaxRetries = 2
+	}
+	if o.WriteTimeout <= 0 {
+		o.WriteTimeout = 10 * time.Second
+	}
+	if o.Logger == nil {
+		o.Logger = slog.Default()
+	}
+	return o
+}
+
+// Sink 是异步批量写出器，可被多个请求协程并发调用 Emit。
+type Sink struct {
+	writer  Writer
+	opts    Options
+	queue   chan Event
+	stop    chan struct{}
+	done    chan struct{}
+	closed  atomic.Bool
+	once    sync.Once
+	backoff func(attempt int) time.Duration
+}
+
+// NewSink 创建写出器并启动后台协程，调用方需在退出前调用 Close 刷新剩余事件。
+func NewSink(writer Writer, opts Options) *Sink {
+	opts = opts.withDefaults()
+	s := &Sink{
+		writer: writer,
+		opts:   opts,
+		queue:  make(chan Event, opts.QueueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		backoff: func(attempt int) time.Duration {
+			return time.Duration(attempt) * 200 * time.Millisecond
+		},
+	}
+	go s.run()
+	return s
+}
+
+// Emit 把事件放入队列，队列满时按丢弃策略处理。Sink 关闭后的事件直接丢弃。
+func (s *Sink) Emit(event Event) {
+	if s.closed.Load() {
+		metrics.ObserveAnalyticsEvents("dropped", 1)
+		return
+	}
+	select {
+	case s.queue <- event:
+		return
+	default:
+	}
+	switch s.opts.DropPolicy {
+	case DropOldest:
+		for {
+			select {
+			case s.queue <- event:
+				return
+			default:
+			}
+			select {
+			case <-s.queue:
+				metrics.ObserveAnalyticsEvents("dropped", 1)
+			default:
+			}
+		}
+	case Block:
+		timer := time.NewTimer(s.opts.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case s.queue <- event:
+			return
+		case <-timer.C:
+		case <-s.stop:
+		}
+	}
+	metrics.ObserveAnalyticsEvents("dropped", 1)
+}
+
+// Close 停止接收事件，写出队列中剩余的事件后返回；ctx 到期时放弃剩余事件。
+func (s *Sink) Close(ctx context.Context) error {
+	s.once.Do(func() {
+		s.closed.Store(true)
+		close(s.stop)
+	})
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Sink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]Event, 0, s.opts.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.write(batch)
+		batch = make([]Event, 0, s.opts.BatchSize)
+	}
+	for {
+		select {
+		case event := <-s.queue:
+			batch = append(batch, event)
+			if len(batch) >= s.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.stop:
+			for {
+				select {
+				case event := <-s.queue:
+					batch = append(batch, event)
+					if len(batch) >= s.opts.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Sink) write(batch []Event) {
+	var err error
+	for attempt := 0; attempt <= s.opts.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(s.backoff(attempt))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), s.opts.WriteTimeout)
+		err = s.writer.Write(ctx, batch)
+		cancel()
+		if err == nil {
+			metrics.ObserveAnalyticsEvents("written", len(batch))
+			return
+		}
+	}
+	metrics.ObserveAnalyticsEvents("failed", len(batch))
+	s.opts.Logger.Warn("analytics write failed", "error", err, "events", len(batch))
+}
@@ -0,0 +1,202 @@
+package analytics
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type recordingWriter struct {
+	mu      sync.Mutex
+	batches [][]Event
+	calls   int
+	fail    int
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *recordingWriter) Write(ctx context.Context, events []Event) error {
+	w.mu.Lock()
+	w.calls++
+	calls := w.calls
+	w.mu.Unlock()
+	if w.started != nil && calls == 1 {
+		close(w.started)
+		<-w.release
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if calls <= w.fail {
+		return errors.New("temporary failure")
+	}
+	w.batches = append(w.batches, append([]Event(nil), events...))
+	return nil
+}
+
+func (w *recordingWriter) requestIDs() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var ids []string
+	for _, batch := range w.batches {
+		for _, event := range batch {
+			ids = append(ids, event.RequestID)
+		}
+	}
+	return ids
+}
+
+func TestSink_BatchesAndFlushesOnClose(t *testing.T) {
+	writer := &recordingWriter{}
+	sink := NewSink(writer, Options{BatchSize: 2, FlushInterval: time.Hour})
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		sink.Emit(Event{RequestID: id})
+	}
+	require.NoError(t, sink.Close(context.Background()))
+
+	require.Equal(t, []string{"a", "b", "c", "d", "e"}, writer.requestIDs())
+	for _, batch := range writer.batches {
+		require.LessOrEqual(t, len(batch), 2)
+	}
+	sink.Emit(Event{RequestID: "late"})
+	require.NotContains(t, writer.requestIDs(), "late")
+}
+
+func TestSink_FlushesOnInterval(t *testing.T) {
+	writer := &recordingWriter{}
+	sink := NewSink(writer, Options{BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+	defer sink.Close(context.Background())
+
+	sink.Emit(Event{RequestID: "a"})
+	require.Eventually(t, func() bool { return len(writer.requestIDs()) == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestSink_DropPolicies(t *testing.T) {
+	cases := []struct {
+		policy DropPolicy
+		want   []string
+	}{
+		{policy: DropNewest, want: []string{"first", "second"}},
+		{policy: DropOldest, want: []string{"first", "third"}},
+		{policy: Block, want: []string{"first", "second"}},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			writer := &recordingWriter{started: make(chan struct{}), release: make(chan struct{})}
+			sink := NewSink(writer, Options{QueueSize: 1, BatchSize: 1, DropPolicy: tc.policy, BlockTimeout: 10 * time.Millisecond})
+
+			// 第一条事件被后台协程取走并阻塞在写入中，此后队列只能容纳一条。
+			sink.Emit(Event{RequestID: "first"})
+			<-writer.started
+			sink.Emit(Event{RequestID: "second"})
+			sink.Emit(Event{RequestID: "third"})
+			close(writer.release)
+
+			require.NoError(t, sink.Close(context.Background()))
+			require.Equal(t, tc.want, writer.requestIDs())
+		})
+	}
+}
+
+func TestSink_RetriesFailedWrites(t *testing.T) {
+	writer := &recordingWriter{fail: 1}
+	sink := NewSink(writer, Options{BatchSize: 1})
+	sink.backoff = func(int) time.Duration { return 0 }
+	sink.Emit(Event{RequestID: "a"})
+	require.NoError(t, sink.Close(context.Background()))
+	require.Equal(t, []string{"a"}, writer.requestIDs())
+	require.Equal(t, 2, writer.calls)
+}
+
+func TestParseDropPolicy(t *testing.T) {
+	policy, err := ParseDropPolicy("")
+	require.NoError(t, err)
+	require.Equal(t, DropNewest, policy)
+	policy, err = ParseDropPolicy("Block")
+	require.NoError(t, err)
+	require.Equal(t, Block, policy)
+	_, err = ParseDropPolicy("drop_all")
+	require.ErrorIs(t, err, ErrInvalidDropPolicy)
+}
+
+func TestClickHouseWriter_InsertsJSONEachRow(t *testing.T) {
+	var (
+		query, user, key string
+		rows             []map[string]any
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		user, key = r.Header.Get("X-ClickHouse-User"), r.Header.Get("X-ClickHouse-Key")
+		require.Equal(t, "analytics", r.URL.Query().Get("database"))
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var row map[string]any
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+			rows = append(rows, row)
+		}
+	}))
+	defer server.Close()
+
+	writer, err := NewClickHouseWriter("http://ingest:s3cret@"+server.Listener.Addr().String()+"/?database=analytics", "request_events", nil)
+	require.NoError(t, err)
+	at := time.Date(2025, time.November, 4, 10, 0, 0, 123e6, time.UTC)
+	err = writer.Write(context.Background(), []Event{
+		{Time: at, RequestID: "r-1", Model: "gpt-4o", PromptTokens: 10},
+		{Time: at, RequestID: "r-2", Status: 502},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "INSERT INTO request_events FORMAT JSONEachRow", query)
+	require.Equal(t, "ingest", user)
+	require.Equal(t, "s3cret", key)
+	require.Len(t, rows, 2)
+	require.Equal(t, "2025-11-04 10:00:00.123", rows[0]["time"])
+	require.Equal(t, "gpt-4o", rows[0]["model"])
+	require.Equal(t, float64(502), rows[1]["status"])
+
+	_, err = NewClickHouseWriter("http://localhost:8123", "events; DROP TABLE x", nil)
+	require.Error(t, err)
+}
+
+func TestClickHouseWriter_ReportsServerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Table analytics.request_events doesn't exist", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	writer, err := NewClickHouseWriter(server.URL, "request_events", nil)
+	require.NoError(t, err)
+	err = writer.Write(context.Background(), []Event{{RequestID: "r-1"}})
+	require.ErrorContains(t, err, "doesn't exist")
+}
+
+func TestPostgresWriter_WritesRows(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "analytics.db")), &gorm.Config{})
+	require.NoError(t, err)
+	writer, err := NewPostgresWriter(db, "request_events")
+	require.NoError(t, err)
+	require.NoError(t, writer.AutoMigrate(context.Background()))
+
+	err = writer.Write(context.Background(), []Event{
+		{Time: time.Now(), RequestID: "r-1", RuleID: "rule-1", Model: "claude-3-5-sonnet", PromptTokens: 12, CompletionTokens: 34, CostUSD: 0.01},
+		{Time: time.Now(), RequestID: "r-2", Status: 200},
+	})
+	require.NoError(t, err)
+
+	var records []eventRecord
+	require.NoError(t, db.Table("request_events").Order("id").Find(&records).Error)
+	require.Len(t, records, 2)
+	require.Equal(t, "claude-3-5-sonnet", records[0].Model)
+	require.Equal(t, int64(34), records[0].CompletionTokens)
+	require.Equal(t, 200, records[1].Status)
+}
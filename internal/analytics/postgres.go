@@ -0,0 +1,79 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostgresWriter 把事件批量插入关系型数据库的分析表，表结构由 AutoMigrate 创建。
+type PostgresWriter struct {
+	db    *gorm.DB
+	table string
+}
+
+// NewPostgresWriter 使用给定 gorm.DB 与表名创建写入器。
+func NewPostgresWriter(db *gorm.DB, table string) (*PostgresWriter, error) {
+	if !tableNamePattern.MatchString(table) {
+		return nil, fmt.Errorf("invalid analytics table name %q", table)
+	}
+	return &PostgresWriter{db: db, table: table}, nil
+}
+
+// AutoMigrate 创建或更新分析表。
+func (w *PostgresWriter) AutoMigrate(ctx context.Context) error {
+	return w.db.WithContext(ctx).Table(w.table).AutoMigrate(&eventRecord{})
+}
+
+// Write 实现 Writer。
+func (w *PostgresWriter) Write(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	records := make([]eventRecord, 0, len(events))
+	for _, event := range events {
+		records = append(records, newEventRecord(event))
+	}
+	return w.db.WithContext(ctx).Table(w.table).Create(&records).Error
+}
+
+type eventRecord struct {
+	ID               uint64    `gorm:"primaryKey;autoIncrement"`
+	Time             time.Time `gorm:"index;not null"`
+	RequestID        string    `gorm:"size:128"`
+	RuleID           string    `gorm:"size:128;index"`
+	Method           string    `gorm:"size:16"`
+	Path             string    `gorm:"size:2048"`
+	Status           int
+	LatencyMs        int64
+	Upstream         string `gorm:"size:255"`
+	Provider         string `gorm:"size:64"`
+	UserID           string `gorm:"size:64;index"`
+	CredentialID     string `gorm:"size:64"`
+	Model            string `gorm:"size:128;index"`
+	PromptTokens     int64
+	CompletionTokens int64
+	CostUSD          float64
+}
+
+func newEventRecord(event Event) eventRecord {
+	return eventRecord{
+		Time:             event.Time.UTC(),
+		RequestID:        event.RequestID,
+		RuleID:           event.RuleID,
+		Method:           event.Method,
+		Path:             event.Path,
+		Status:           event.Status,
+		LatencyMs:        event.LatencyMs,
+		Upstream:         event.Upstream,
+		Provider:         event.Provider,
+		UserID:           event.UserID,
+		CredentialID:     event.CredentialID,
+		Model:            event.Model,
+		PromptTokens:     event.PromptTokens,
+		CompletionTokens: event.CompletionTokens,
+		CostUSD:          event.CostUSD,
+	}
+}
@@ -0,0 +1,327 @@
+// Package archive 加密保存代理转发的提示词与模型响应，供合规团队审计 LLM 的使用情况。
+//
+// 请求体与响应体以 AES-256-GCM 加密后写入存储，用户、规则、模型与时间等元数据以明文保存用于筛选；
+// 每条记录按所属用户的保留期限计算过期时间，过期记录不再返回，并由 Archive.Start 定期清理。
+package archive
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+
+	// RetentionMetadataKey 是用户元数据中覆盖默认保留期限的键，取值为天数，0 表示不归档该用户的请求。
+	RetentionMetadataKey = "archive_retention_days"
+	// MaxRetentionDays 是 archive_retention_days 的上限，更大的取值按上限处理，避免换算为 time.Duration 时溢出。
+	MaxRetentionDays = 3650
+)
+
+var (
+	// ErrNotFound 表示归档记录不存在或已过期。
+	ErrNotFound = errors.New("conversation not found")
+	// ErrInvalidKey 表示加密密钥不是 base64 编码的 32 字节。
+	ErrInvalidKey = errors.New("invalid archive encryption key")
+)
+
+// Conversation 是一次归档的代理请求及其响应。Request、Response 与 RequestTruncated 只在读取单条记录或导出时填充；
+// Truncated 表示请求体或响应体被截断，RequestTruncated 单独表示请求体被截断，此时请求体不能用于重放。
+type Conversation struct {
+	ID        string `json:"id"`
+	RequestID string `json:"request_id,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	APIKeyID  string `json:"api_key_id,omitempty"`
+	RuleID    string `json:"rule_id,omitempty"`
+	Model     string `json:"model,omitempty"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Status    int    `json:"status"`
+	Truncated bool   `json:"truncated,omitempty"`
+	// RequestTruncated 随请求体加密保存，不作为筛选条件。
+	RequestTruncated bool      `json:"request_truncated,omitempty"`
+	Request          string    `json:"request,omitempty"`
+	Response         string    `json:"response,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// Record 是存储中的一条记录：明文元数据与加密的请求体、响应体。
+type Record struct {
+	Conversation
+	Payload []byte
+}
+
+// Filter 描述归档记录的查询条件，零值字段表示不过滤。ActiveAt 非零时只返回在该时刻尚未过期的记录。
+type Filter struct {
+	RequestID string
+	UserID    string
+	RuleID    string
+	Model     string
+	Since     time.Time
+	Until     time.Time
+	ActiveAt  time.Time
+	Limit     int
+	Offset    int
+}
+
+// Store 定义归档记录的存储接口。
+type Store interface {
+	Save(ctx context.Context, record Record) error
+	List(ctx context.Context, filter Filter) ([]Record, int64, error)
+	Get(ctx context.Context, id string) (Record, error)
+	Delete(ctx context.Context, id string) error
+	DeleteUser(ctx context.Context, userID string) (int64, error)
+	// Purge 删除在 now 之前过期的记录，返回删除的条数。
+	Purge(ctx context.Context, now time.Time) (int64, error)
+}
+
+// payload 是加密前的请求体与响应体。
+type payload struct {
+	Request          string `json:"request,omitempty"`
+	Response         string `json:"response,omitempty"`
+	RequestTruncated bool   `json:"request_truncated,omitempty"`
+}
+
+// Archive 负责加密、保留期限与过期清理，记录的持久化交给 Store。
+type Archive struct {
+	store     Store
+	aead      cipher.AEAD
+	retention time.Duration
+	logger    *slog.Logger
+	now       func() time.Time
+}
+
+// ParseKey 解析 base64 编码（标准或 URL 字母表，可省略填充）的 32 字节密钥。
+func ParseKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if key, err := encoding.DecodeString(value); err == nil {
+			if len(key) != 32 {
+				return nil, fmt.Errorf("%w: decoded key is %d bytes, want 32", ErrInvalidKey, len(key))
+			}
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: must be base64 encoded", ErrInvalidKey)
+}
+
+// New 创建归档。key 为 32 字节的 AES-256 密钥，retention 为未单独配置的用户的保留期限。
+func New(store Store, key []byte, retention time.Duration, logger *slog.Logger) (*Archive, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%w: key is %d bytes, want 32", ErrInvalidKey, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Archive{store: store, aead: aead, retention: retention, logger: logger, now: time.Now}, nil
+}
+
+// Retention 返回用户的保留期限：元数据 archive_retention_days 覆盖默认值，超过 MaxRetentionDays 时按上限处理，
+// 结果不大于 0 时不归档。
+func (a *Archive) Retention(metadata map[string]any) time.Duration {
+	value, ok := metadata[RetentionMetadataKey]
+	if !ok {
+		return a.retention
+	}
+	var days float64
+	switch typed := value.(type) {
+	case float64:
+		days = typed
+	case int:
+		days = float64(typed)
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(typed), 64)
+		if err != nil {
+			return a.retention
+		}
+		days = parsed
+	default:
+		return a.retention
+	}
+	if math.IsNaN(days) {
+		return a.retention
+	}
+	return time.Duration(min(days, MaxRetentionDays) * float64(24*time.Hour))
+}
+
+// Record 加密并保存一次对话，retention 不大于 0 时忽略。
+func (a *Archive) Record(ctx context.Context, conv Conversation, retention time.Duration) error {
+	if retention <= 0 {
+		return nil
+	}
+	if conv.ID == "" {
+		conv.ID = uuid.NewString()
+	}
+	if conv.CreatedAt.IsZero() {
+		conv.CreatedAt = a.now().UTC()
+	}
+	conv.ExpiresAt = conv.CreatedAt.Add(retention)
+	sealed, err := a.seal(conv.ID, payload{Request: conv.Request, Response: conv.Response, RequestTruncated: conv.RequestTruncated})
+	if err != nil {
+		return err
+	}
+	conv.Request, conv.Response, conv.RequestTruncated = "", "", false
+	return a.store.Save(ctx, Record{Conversation: conv, Payload: sealed})
+}
+
+// List 按时间倒序返回未过期记录的元数据及总数，不解密请求体与响应体。
+func (a *Archive) List(ctx context.Context, filter Filter) ([]Conversation, int64, error) {
+	filter.ActiveAt = a.now()
+	records, total, err := a.store.List(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	conversations := make([]Conversation, 0, len(records))
+	for _, record := range records {
+		conversations = append(conversations, record.Conversation)
+	}
+	return conversations, total, nil
+}
+
+// Get 返回解密后的单条记录，记录不存在或已过期时返回 ErrNotFound。
+func (a *Archive) Get(ctx context.Context, id string) (Conversation, error) {
+	record, err := a.store.Get(ctx, id)
+	if err != nil {
+		return Conversation{}, err
+	}
+	if !a.now().Before(record.ExpiresAt) {
+		return Conversation{}, ErrNotFound
+	}
+	return a.open(record)
+}
+
+// GetByRequestID 返回指定代理请求 ID 最近一条解密后的记录，不存在或已过期时返回 ErrNotFound。
+func (a *Archive) GetByRequestID(ctx context.Context, requestID string) (Conversation, error) {
+	records, _, err := a.store.List(ctx, Filter{RequestID: requestID, ActiveAt: a.now(), Limit: 1})
+	if err != nil {
+		return Conversation{}, err
+	}
+	if len(records) == 0 {
+		return Conversation{}, ErrNotFound
+	}
+	return a.open(records[0])
+}
+
+// Export 按时间倒序逐条解密匹配的记录并交给 fn，fn 返回错误时停止。
+// 导出开始后新增的记录不包含在内，避免分页时重复输出。
+func (a *Archive) Export(ctx context.Context, filter Filter, fn func(Conversation) error) error {
+	now := a.now()
+	if filter.Until.IsZero() || filter.Until.After(now) {
+		filter.Until = now
+	}
+	filter.ActiveAt = now
+	filter.Limit, filter.Offset = maxListLimit, 0
+	for {
+		records, _, err := a.store.List(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			conv, err := a.open(record)
+			if err != nil {
+				return err
+			}
+			if err := fn(conv); err != nil {
+				return err
+			}
+		}
+		if len(records) < filter.Limit {
+			return nil
+		}
+		filter.Offset += len(records)
+	}
+}
+
+// Delete 删除单条记录。
+func (a *Archive) Delete(ctx context.Context, id string) error {
+	return a.store.Delete(ctx, id)
+}
+
+// DeleteUser 删除用户的全部记录，返回删除的条数。
+func (a *Archive) DeleteUser(ctx context.Context, userID string) (int64, error) {
+	return a.store.DeleteUser(ctx, userID)
+}
+
+// Purge 删除已过期的记录。
+func (a *Archive) Purge(ctx context.Context) (int64, error) {
+	return a.store.Purge(ctx, a.now())
+}
+
+// PurgeExpired 删除已过期的记录并记录清理的条数，供周期任务调用。
+func (a *Archive) PurgeExpired(ctx context.Context) error {
+	purged, err := a.Purge(ctx)
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		a.logger.Info("purged expired conversations", "count", purged)
+	}
+	return nil
+}
+
+// seal 加密载荷，输出为随机 nonce 与密文的拼接。记录 ID 作为附加数据，密文无法挪用到其他记录。
+func (a *Archive) seal(id string, p payload) ([]byte, error) {
+	plaintext, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, a.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return a.aead.Seal(nonce, nonce, plaintext, []byte(id)), nil
+}
+
+func (a *Archive) open(record Record) (Conversation, error) {
+	conv := record.Conversation
+	size := a.aead.NonceSize()
+	if len(record.Payload) < size {
+		return Conversation{}, fmt.Errorf("conversation %s: payload too short", conv.ID)
+	}
+	plaintext, err := a.aead.Open(nil, record.Payload[:size], record.Payload[size:], []byte(conv.ID))
+	if err != nil {
+		return Conversation{}, fmt.Errorf("conversation %s: decrypt payload: %w", conv.ID, err)
+	}
+	var p payload
+	if err := json.Unmarshal(plaintext, &p); err != nil {
+		return Conversation{}, fmt.Errorf("conversation %s: decode payload: %w", conv.ID, err)
+	}
+	conv.Request, conv.Response, conv.RequestTruncated = p.Request, p.Response, p.RequestTruncated
+	return conv, nil
+}
+
+func normalizeFilter(filter Filter) Filter {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	return filter
+}
@@ -0,0 +1,146 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+var testKey = bytes.Repeat([]byte{7}, 32)
+
+func TestParseKey(t *testing.T) {
+	key, err := ParseKey(base64.StdEncoding.EncodeToString(testKey))
+	require.NoError(t, err)
+	require.Equal(t, testKey, key)
+
+	key, err = ParseKey(base64.RawURLEncoding.EncodeToString(testKey))
+	require.NoError(t, err)
+	require.Equal(t, testKey, key)
+
+	_, err = ParseKey(base64.StdEncoding.EncodeToString([]byte("short")))
+	require.ErrorIs(t, err, ErrInvalidKey)
+	_, err = ParseKey("not base64!")
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestArchive_Retention(t *testing.T) {
+	a, err := New(NewMemoryStore(), testKey, 720*time.Hour, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, 720*time.Hour, a.Retention(nil))
+	require.Equal(t, 48*time.Hour, a.Retention(map[string]any{RetentionMetadataKey: float64(2)}))
+	require.Equal(t, 24*time.Hour, a.Retention(map[string]any{RetentionMetadataKey: "1"}))
+	require.Zero(t, a.Retention(map[string]any{RetentionMetadataKey: 0}))
+	require.Equal(t, 720*time.Hour, a.Retention(map[string]any{RetentionMetadataKey: "forever"}))
+	require.Equal(t, 720*time.Hour, a.Retention(map[string]any{RetentionMetadataKey: "NaN"}))
+	// 超出 time.Duration 表示范围的天数按上限处理，不会溢出为负数而停止归档。
+	maxRetention := MaxRetentionDays * 24 * time.Hour
+	require.Equal(t, maxRetention, a.Retention(map[string]any{RetentionMetadataKey: float64(1e9)}))
+	require.Equal(t, maxRetention, a.Retention(map[string]any{RetentionMetadataKey: "1e300"}))
+	require.Equal(t, maxRetention, a.Retention(map[string]any{RetentionMetadataKey: "+Inf"}))
+}
+
+func TestArchive_Stores(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:archive_store?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	dbStore := NewDBStore(db)
+	require.NoError(t, dbStore.AutoMigrate(context.Background()))
+
+	stores := map[string]Store{"memory": NewMemoryStore(), "db": dbStore}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			a, err := New(store, testKey, 24*time.Hour, nil)
+			require.NoError(t, err)
+			base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+			a.now = func() time.Time { return base.Add(3 * time.Hour) }
+
+			conversations := []Conversation{
+				{UserID: "u1", RuleID: "r1", Model: "gpt-4o", Method: "POST", Path: "/v1/chat/completions", Status: 200,
+					Request: `{"messages":[{"role":"user","content":"hi"}]}`, Response: `{"choices":[]}`, CreatedAt: base},
+				{RequestID: "req-2", UserID: "u2", RuleID: "r1", Model: "claude", Method: "POST", Path: "/v1/messages", Status: 200,
+					Request: "second", CreatedAt: base.Add(time.Hour)},
+				{UserID: "u1", RuleID: "r2", Model: "gpt-4o", Method: "POST", Path: "/v1/chat/completions", Status: 500,
+					Request: "third", CreatedAt: base.Add(2 * time.Hour)},
+			}
+			for _, conv := range conversations {
+				require.NoError(t, a.Record(ctx, conv, 24*time.Hour))
+			}
+			require.NoError(t, a.Record(ctx, Conversation{UserID: "u3", Request: "skipped", CreatedAt: base}, 0))
+			require.NoError(t, a.Record(ctx, Conversation{UserID: "u3", Request: "expired", CreatedAt: base}, time.Hour))
+
+			all, total, err := a.List(ctx, Filter{})
+			require.NoError(t, err)
+			require.EqualValues(t, 3, total)
+			require.Empty(t, all[0].Request)
+			latest, err := a.Get(ctx, all[0].ID)
+			require.NoError(t, err)
+			require.Equal(t, "third", latest.Request)
+
+			raw, err := store.Get(ctx, all[2].ID)
+			require.NoError(t, err)
+			require.NotContains(t, string(raw.Payload), "hi")
+			full, err := a.Get(ctx, all[2].ID)
+			require.NoError(t, err)
+			require.Equal(t, `{"choices":[]}`, full.Response)
+			require.Equal(t, base.Add(24*time.Hour), full.ExpiresAt.UTC())
+
+			byUser, total, err := a.List(ctx, Filter{UserID: "u1", Model: "gpt-4o", Limit: 1})
+			require.NoError(t, err)
+			require.EqualValues(t, 2, total)
+			require.Len(t, byUser, 1)
+
+			ranged, _, err := a.List(ctx, Filter{Since: base.Add(30 * time.Minute), Until: base.Add(2 * time.Hour)})
+			require.NoError(t, err)
+			require.Len(t, ranged, 1)
+			require.Equal(t, "u2", ranged[0].UserID)
+
+			byRequest, err := a.GetByRequestID(ctx, "req-2")
+			require.NoError(t, err)
+			require.Equal(t, "second", byRequest.Request)
+			_, err = a.GetByRequestID(ctx, "missing")
+			require.ErrorIs(t, err, ErrNotFound)
+
+			var exported []string
+			require.NoError(t, a.Export(ctx, Filter{RuleID: "r1"}, func(conv Conversation) error {
+				exported = append(exported, conv.Request)
+				return nil
+			}))
+			require.Equal(t, []string{"second", `{"messages":[{"role":"user","content":"hi"}]}`}, exported)
+
+			purged, err := a.Purge(ctx)
+			require.NoError(t, err)
+			require.EqualValues(t, 1, purged)
+
+			require.NoError(t, a.Delete(ctx, ranged[0].ID))
+			require.ErrorIs(t, a.Delete(ctx, ranged[0].ID), ErrNotFound)
+			_, err = a.Get(ctx, ranged[0].ID)
+			require.ErrorIs(t, err, ErrNotFound)
+
+			deleted, err := a.DeleteUser(ctx, "u1")
+			require.NoError(t, err)
+			require.EqualValues(t, 2, deleted)
+			_, total, err = a.List(ctx, Filter{})
+			require.NoError(t, err)
+			require.Zero(t, total)
+		})
+	}
+}
+
+func TestArchive_RejectsTamperedPayload(t *testing.T) {
+	store := NewMemoryStore()
+	a, err := New(store, testKey, time.Hour, nil)
+	require.NoError(t, err)
+	require.NoError(t, a.Record(context.Background(), Conversation{ID: "c1", Request: "secret"}, time.Hour))
+
+	other, err := New(store, bytes.Repeat([]byte{8}, 32), time.Hour, nil)
+	require.NoError(t, err)
+	_, err = other.Get(context.Background(), "c1")
+	require.Error(t, err)
+}
@@ -0,0 +1,165 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DBStore 基于关系型数据库的归档存储，记录写入 conversation_archive 表。
+type DBStore struct {
+	db *gorm.DB
+}
+
+// NewDBStore 使用给定 gorm.DB 初始化存储。
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// AutoMigrate 执行归档表结构迁移。
+func (s *DBStore) AutoMigrate(ctx context.Context) error {
+	return s.db.WithContext(ctx).AutoMigrate(&conversationRecord{})
+}
+
+// Save 写入一条记录。
+func (s *DBStore) Save(ctx context.Context, record Record) error {
+	rec := newConversationRecord(record)
+	return s.db.WithContext(ctx).Create(&rec).Error
+}
+
+// List 按时间倒序返回匹配的记录及总数。
+func (s *DBStore) List(ctx context.Context, filter Filter) ([]Record, int64, error) {
+	filter = normalizeFilter(filter)
+	query := s.db.WithContext(ctx).Model(&conversationRecord{})
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.RuleID != "" {
+		query = query.Where("rule_id = ?", filter.RuleID)
+	}
+	if filter.Model != "" {
+		query = query.Where("model = ?", filter.Model)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+	if !filter.ActiveAt.IsZero() {
+		query = query.Where("expires_at > ?", filter.ActiveAt)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var records []conversationRecord
+	if err := query.Order("created_at DESC").Order("id DESC").
+		Limit(filter.Limit).Offset(filter.Offset).Find(&records).Error; err != nil {
+		return nil, 0, err
+	}
+	result := make([]Record, 0, len(records))
+	for _, rec := range records {
+		result = append(result, rec.toDomain())
+	}
+	return result, total, nil
+}
+
+// Get 按 ID 返回记录。
+func (s *DBStore) Get(ctx context.Context, id string) (Record, error) {
+	var rec conversationRecord
+	if err := s.db.WithContext(ctx).Where("id = ?", id).Take(&rec).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Record{}, ErrNotFound
+		}
+		return Record{}, err
+	}
+	return rec.toDomain(), nil
+}
+
+// Delete 删除单条记录，记录不存在时返回 ErrNotFound。
+func (s *DBStore) Delete(ctx context.Context, id string) error {
+	result := s.db.WithContext(ctx).Where("id = ?", id).Delete(&conversationRecord{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteUser 删除用户的全部记录。
+func (s *DBStore) DeleteUser(ctx context.Context, userID string) (int64, error) {
+	result := s.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&conversationRecord{})
+	return result.RowsAffected, result.Error
+}
+
+// Purge 删除在 now 之前过期的记录。
+func (s *DBStore) Purge(ctx context.Context, now time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&conversationRecord{})
+	return result.RowsAffected, result.Error
+}
+
+type conversationRecord struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	RequestID string `gorm:"size:128;index"`
+	UserID    string `gorm:"size:64;index"`
+	APIKeyID  string `gorm:"size:64"`
+	RuleID    string `gorm:"size:128;index"`
+	Model     string `gorm:"size:128;index"`
+	Method    string `gorm:"size:16"`
+	Path      string `gorm:"size:2048"`
+	Status    int
+	Truncated bool
+	Payload   []byte
+	CreatedAt time.Time `gorm:"index"`
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (conversationRecord) TableName() string {
+	return "conversation_archive"
+}
+
+func newConversationRecord(record Record) conversationRecord {
+	return conversationRecord{
+		ID:        record.ID,
+		RequestID: record.RequestID,
+		UserID:    record.UserID,
+		APIKeyID:  record.APIKeyID,
+		RuleID:    record.RuleID,
+		Model:     record.Model,
+		Method:    record.Method,
+		Path:      record.Path,
+		Status:    record.Status,
+		Truncated: record.Truncated,
+		Payload:   record.Payload,
+		CreatedAt: record.CreatedAt,
+		ExpiresAt: record.ExpiresAt,
+	}
+}
+
+func (r conversationRecord) toDomain() Record {
+	return Record{
+		Conversation: Conversation{
+			ID:        r.ID,
+			RequestID: r.RequestID,
+			UserID:    r.UserID,
+			APIKeyID:  r.APIKeyID,
+			RuleID:    r.RuleID,
+			Model:     r.Model,
+			Method:    r.Method,
+			Path:      r.Path,
+			Status:    r.Status,
+			Truncated: r.Truncated,
+			CreatedAt: r.CreatedAt,
+			ExpiresAt: r.ExpiresAt,
+		},
+		Payload: r.Payload,
+	}
+}
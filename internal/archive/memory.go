@@ -0,0 +1,112 @@
+package archive
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryStore 是基于内存的归档存储，适用于未配置数据库的部署与测试，重启后记录丢失。
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records []Record
+}
+
+// NewMemoryStore 创建内存存储。
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Save 追加一条记录。
+func (s *MemoryStore) Save(ctx context.Context, record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	return nil
+}
+
+// List 按时间倒序返回匹配的记录及总数。
+func (s *MemoryStore) List(ctx context.Context, filter Filter) ([]Record, int64, error) {
+	filter = normalizeFilter(filter)
+	s.mu.RLock()
+	var matched []Record
+	for _, record := range s.records {
+		if filter.matches(record) {
+			matched = append(matched, record)
+		}
+	}
+	s.mu.RUnlock()
+	slices.SortStableFunc(matched, func(a, b Record) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	total := int64(len(matched))
+	if filter.Offset >= len(matched) {
+		return []Record{}, total, nil
+	}
+	end := min(filter.Offset+filter.Limit, len(matched))
+	return matched[filter.Offset:end], total, nil
+}
+
+// Get 按 ID 返回记录。
+func (s *MemoryStore) Get(ctx context.Context, id string) (Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, record := range s.records {
+		if record.ID == id {
+			return record, nil
+		}
+	}
+	return Record{}, ErrNotFound
+}
+
+// Delete 删除单条记录，记录不存在时返回 ErrNotFound。
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	if s.remove(func(record Record) bool { return record.ID == id }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteUser 删除用户的全部记录。
+func (s *MemoryStore) DeleteUser(ctx context.Context, userID string) (int64, error) {
+	return s.remove(func(record Record) bool { return record.UserID == userID }), nil
+}
+
+// Purge 删除在 now 之前过期的记录。
+func (s *MemoryStore) Purge(ctx context.Context, now time.Time) (int64, error) {
+	return s.remove(func(record Record) bool { return !now.Before(record.ExpiresAt) }), nil
+}
+
+func (s *MemoryStore) remove(match func(Record) bool) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.records)
+	s.records = slices.DeleteFunc(s.records, match)
+	return int64(before - len(s.records))
+}
+
+func (f Filter) matches(record Record) bool {
+	if f.RequestID != "" && record.RequestID != f.RequestID {
+		return false
+	}
+	if f.UserID != "" && record.UserID != f.UserID {
+		return false
+	}
+	if f.RuleID != "" && record.RuleID != f.RuleID {
+		return false
+	}
+	if f.Model != "" && record.Model != f.Model {
+		return false
+	}
+	if !f.Since.IsZero() && record.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !record.CreatedAt.Before(f.Until) {
+		return false
+	}
+	if !f.ActiveAt.IsZero() && !f.ActiveAt.Before(record.ExpiresAt) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+// Package audit 持久化管理端的变更记录，供事后按操作人、动作与时间范围查询。
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// Entry 是一条管理端变更记录。
+type Entry struct {
+	ID           string            `json:"id"`
+	Actor        string            `json:"actor"`
+	Action       string            `json:"action"`
+	ResourceType string            `json:"resource_type"`
+	ResourceID   string            `json:"resource_id"`
+	RequestID    string            `json:"request_id,omitempty"`
+	Before       json.RawMessage   `json:"before,omitempty"`
+	After        json.RawMessage   `json:"after,omitempty"`
+	Diff         map[string]Change `json:"diff,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+// Change 描述单个顶层字段在变更前后的取值。
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Filter 描述审计日志的查询条件，零值字段表示不过滤。
+type Filter struct {
+	Actor        string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Since        time.Time
+	Until        time.Time
+	Limit        int
+	Offset       int
+}
+
+// Store 定义审计日志的存储接口。
+type Store interface {
+	Record(ctx context.Context, entry Entry) error
+	List(ctx context.Context, filter Filter) ([]Entry, int64, error)
+}
+
+// Snapshot 将资源序列化为 JSON 快照，nil 表示资源不存在（创建前或删除后）。
+func Snapshot(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil || string(raw) == "null" {
+		return nil
+	}
+	return raw
+}
+
+// Diff 比较前后快照的顶层字段，返回发生变化的字段。
+func Diff(before, after json.RawMessage) map[string]Change {
+	prev := decodeObject(before)
+	next := decodeObject(after)
+	keys := make(map[string]struct{}, len(prev)+len(next))
+	for key := range prev {
+		keys[key] = struct{}{}
+	}
+	for key := range next {
+		keys[key] = struct{}{}
+	}
+	changes := make(map[string]Change)
+	for key := range keys {
+		if key == "updated_at" {
+			continue
+		}
+		b, a := prev[key], next[key]
+		if reflect.DeepEqual(b, a) {
+			continue
+		}
+		changes[key] = Change{Before: b, After: a}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func decodeObject(raw json.RawMessage) map[string]any {
+	if len(raw) == 0 {
+		return nil
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil
+	}
+	return obj
+}
+
+func normalizeFilter(filter Filter) Filter {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	return filter
+}
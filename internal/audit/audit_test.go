@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDiff_TopLevelChanges(t *testing.T) {
+	before := Snapshot(map[string]any{"enabled": true, "priority": 10, "updated_at": "t1"})
+	after := Snapshot(map[string]any{"enabled": false, "priority": 10, "updated_at": "t2", "label": "x"})
+
+	diff := Diff(before, after)
+	require.Len(t, diff, 2)
+	require.Equal(t, Change{Before: true, After: false}, diff["enabled"])
+	require.Equal(t, Change{Before: nil, After: "x"}, diff["label"])
+	require.Nil(t, Diff(before, before))
+}
+
+func TestStores_RecordAndFilter(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:audit_store?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	dbStore := NewDBStore(db)
+	require.NoError(t, dbStore.AutoMigrate(context.Background()))
+
+	stores := map[string]Store{"memory": NewMemoryStore(), "db": dbStore}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+			entries := []Entry{
+				{Actor: "alice", Action: "rules.update", ResourceType: "rule", ResourceID: "r1", CreatedAt: base},
+				{Actor: "bob", Action: "rules.delete", ResourceType: "rule", ResourceID: "r2", CreatedAt: base.Add(time.Hour)},
+				{
+					Actor: "alice", Action: "accounts.users.patch", ResourceType: "user", ResourceID: "u1",
+					Before:    json.RawMessage(`{"max_requests_per_minute":0}`),
+					After:     json.RawMessage(`{"max_requests_per_minute":60}`),
+					Diff:      map[string]Change{"max_requests_per_minute": {Before: float64(0), After: float64(60)}},
+					CreatedAt: base.Add(2 * time.Hour),
+				},
+			}
+			for _, entry := range entries {
+				require.NoError(t, store.Record(ctx, entry))
+			}
+
+			all, total, err := store.List(ctx, Filter{})
+			require.NoError(t, err)
+			require.EqualValues(t, 3, total)
+			require.Equal(t, "accounts.users.patch", all[0].Action)
+			require.NotEmpty(t, all[0].ID)
+			require.JSONEq(t, `{"max_requests_per_minute":60}`, string(all[0].After))
+			require.Equal(t, float64(60), all[0].Diff["max_requests_per_minute"].After)
+
+			byActor, total, err := store.List(ctx, Filter{Actor: "alice", Limit: 1})
+			require.NoError(t, err)
+			require.EqualValues(t, 2, total)
+			require.Len(t, byActor, 1)
+
+			ranged, _, err := store.List(ctx, Filter{Since: base.Add(30 * time.Minute), Until: base.Add(2 * time.Hour)})
+			require.NoError(t, err)
+			require.Len(t, ranged, 1)
+			require.Equal(t, "r2", ranged[0].ResourceID)
+
+			byAction, _, err := store.List(ctx, Filter{Action: "rules.update"})
+			require.NoError(t, err)
+			require.Len(t, byAction, 1)
+			require.Equal(t, "r1", byAction[0].ResourceID)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// DBStore 基于关系型数据库的审计日志存储，记录写入 audit_logs 表。
+type DBStore struct {
+	db *gorm.DB
+}
+
+// NewDBStore 使用给定 gorm.DB 初始化存储。
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// AutoMigrate 执行审计表结构迁移。
+func (s *DBStore) AutoMigrate(ctx context.Context) error {
+	return s.db.WithContext(ctx).AutoMigrate(&auditRecord{})
+}
+
+// Record 写入一条审计记录。
+func (s *DBStore) Record(ctx context.Context, entry Entry) error {
+	rec, err := newAuditRecord(entry)
+	if err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Create(&rec).Error
+}
+
+// List 按时间倒序返回匹配的记录及总数。
+func (s *DBStore) List(ctx context.Context, filter Filter) ([]Entry, int64, error) {
+	filter = normalizeFilter(filter)
+	query := s.db.WithContext(ctx).Model(&auditRecord{})
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ResourceType != "" {
+		query = query.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		query = query.Where("resource_id = ?", filter.ResourceID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var records []auditRecord
+	if err := query.Order("created_at DESC").Order("id DESC").
+		Limit(filter.Limit).Offset(filter.Offset).Find(&records).Error; err != nil {
+		return nil, 0, err
+	}
+	entries := make([]Entry, 0, len(records))
+	for _, rec := range records {
+		entry, err := rec.toDomain()
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, nil
+}
+
+type auditRecord struct {
+	ID           string `gorm:"primaryKey;size:64"`
+	Actor        string `gorm:"size:255;index"`
+	Action       string `gorm:"size:128;index"`
+	ResourceType string `gorm:"size:64;index:idx_audit_logs_resource"`
+	ResourceID   string `gorm:"size:128;index:idx_audit_logs_resource"`
+	RequestID    string `gorm:"size:128"`
+	Before       datatypes.JSON
+	After        datatypes.JSON
+	Diff         datatypes.JSON
+	CreatedAt    time.Time `gorm:"index"`
+}
+
+func (auditRecord) TableName() string {
+	return "audit_logs"
+}
+
+func newAuditRecord(entry Entry) (auditRecord, error) {
+	if entry.ID == "" {
+		entry.ID = uuid.NewString()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+	var diff datatypes.JSON
+	if len(entry.Diff) > 0 {
+		raw, err := json.Marshal(entry.Diff)
+		if err != nil {
+			return auditRecord{}, err
+		}
+		diff = raw
+	}
+	return auditRecord{
+		ID:           entry.ID,
+		Actor:        entry.Actor,
+		Action:       entry.Action,
+		ResourceType: entry.ResourceType,
+		ResourceID:   entry.ResourceID,
+		RequestID:    entry.RequestID,
+		Before:       datatypes.JSON(entry.Before),
+		After:        datatypes.JSON(entry.After),
+		Diff:         diff,
+		CreatedAt:    entry.CreatedAt,
+	}, nil
+}
+
+func (r auditRecord) toDomain() (Entry, error) {
+	entry := Entry{
+		ID:           r.ID,
+		Actor:        r.Actor,
+		Action:       r.Action,
+		ResourceType: r.ResourceType,
+		ResourceID:   r.ResourceID,
+		RequestID:    r.RequestID,
+		CreatedAt:    r.CreatedAt,
+	}
+	if len(r.Before) > 0 {
+		entry.Before = json.RawMessage(r.Before)
+	}
+	if len(r.After) > 0 {
+		entry.After = json.RawMessage(r.After)
+	}
+	if len(r.Diff) > 0 {
+		if err := json.Unmarshal(r.Diff, &entry.Diff); err != nil {
+			return Entry{}, err
+		}
+	}
+	return entry, nil
+}
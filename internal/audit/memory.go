@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore 是基于内存的审计日志存储，适用于未配置数据库的部署与测试，重启后记录丢失。
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries []Entry
+	now     func() time.Time
+}
+
+// NewMemoryStore 创建内存存储。
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{now: time.Now}
+}
+
+// Record 追加一条审计记录。
+func (s *MemoryStore) Record(ctx context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry.ID == "" {
+		entry.ID = uuid.NewString()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = s.now().UTC()
+	}
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+// List 按时间倒序返回匹配的记录及总数。
+func (s *MemoryStore) List(ctx context.Context, filter Filter) ([]Entry, int64, error) {
+	filter = normalizeFilter(filter)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var matched []Entry
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if entry := s.entries[i]; filter.matches(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	total := int64(len(matched))
+	if filter.Offset >= len(matched) {
+		return []Entry{}, total, nil
+	}
+	end := filter.Offset + filter.Limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+	return matched[filter.Offset:end], total, nil
+}
+
+func (f Filter) matches(entry Entry) bool {
+	if f.Actor != "" && entry.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if f.ResourceType != "" && entry.ResourceType != f.ResourceType {
+		return false
+	}
+	if f.ResourceID != "" && entry.ResourceID != f.ResourceID {
+		return false
+	}
+	if !f.Since.IsZero() && entry.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.CreatedAt.Before(f.Until) {
+		return false
+	}
+	return true
+}
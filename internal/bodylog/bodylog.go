@@ -0,0 +1,215 @@
+// Package bodylog 为请求/响应体日志提供脱敏：按 JSON 路径遮蔽字段（如 `messages[*].content`），
+// 并始终去除 Authorization 等凭据请求头、遮蔽名称敏感的 JSON 字段，使调试日志不泄露密钥与个人信息。
+package bodylog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prehisle/yapi/internal/reqtrace"
+)
+
+// ErrInvalidPath 表示脱敏路径格式错误。
+var ErrInvalidPath = errors.New("invalid redaction path")
+
+// strippedHeaders 始终从日志中去除，不论策略如何配置。
+var strippedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// step 是路径中的一段：对象键、数组下标或数组通配。
+type step struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// Policy 是脱敏策略，零值只执行内置的敏感字段与请求头处理。
+type Policy struct {
+	paths [][]step
+}
+
+// ParsePolicy 解析脱敏路径。路径以 `.` 分隔对象键，`[n]` 选择数组元素，`[*]` 匹配全部元素，
+// 例如 `messages[*].content`、`input[0]`、`metadata.email`。
+func ParsePolicy(paths []string) (Policy, error) {
+	var policy Policy
+	for _, raw := range paths {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		steps, err := parsePath(raw)
+		if err != nil {
+			return Policy{}, err
+		}
+		policy.paths = append(policy.paths, steps)
+	}
+	return policy, nil
+}
+
+func parsePath(path string) ([]step, error) {
+	var steps []step
+	for _, segment := range strings.Split(path, ".") {
+		key, rest, _ := strings.Cut(segment, "[")
+		if key == "" && rest == "" {
+			return nil, fmt.Errorf("%w: empty segment in %q", ErrInvalidPath, path)
+		}
+		if key != "" {
+			steps = append(steps, step{key: key})
+		}
+		for rest != "" {
+			inner, after, ok := strings.Cut(rest, "]")
+			if !ok {
+				return nil, fmt.Errorf("%w: missing closing bracket in %q", ErrInvalidPath, path)
+			}
+			switch {
+			case inner == "*":
+				steps = append(steps, step{wildcard: true})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("%w: invalid array index %q in %q", ErrInvalidPath, inner, path)
+				}
+				steps = append(steps, step{index: index, isIndex: true})
+			}
+			if after == "" {
+				break
+			}
+			if !strings.HasPrefix(after, "[") {
+				return nil, fmt.Errorf("%w: unexpected %q in %q", ErrInvalidPath, after, path)
+			}
+			rest = after[1:]
+		}
+	}
+	return steps, nil
+}
+
+// Headers 返回可记录的请求头：凭据类请求头被去除，名称敏感的请求头取值记为 [REDACTED]。
+func Headers(header http.Header) map[string]string {
+	out := make(map[string]string, len(header))
+	for name, values := range header {
+		canonical := http.CanonicalHeaderKey(name)
+		if strippedHeaders[canonical] {
+			continue
+		}
+		out[canonical] = reqtrace.RedactHeader(canonical, strings.Join(values, ", "))
+	}
+	return out
+}
+
+// Body 返回可记录的请求体。JSON 按策略与敏感字段名脱敏；SSE 流逐条脱敏 data 事件；
+// 被截断或无法解析的内容不记录原文，只返回占位说明，避免绕过脱敏。
+func (p Policy) Body(contentType string, body []byte, truncated bool) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(contentType)), "text/event-stream") {
+		return p.stream(body, truncated)
+	}
+	if truncated {
+		return fmt.Sprintf("[TRUNCATED: %d bytes captured, not logged]", len(body))
+	}
+	redacted, ok := p.JSON(body)
+	if !ok {
+		return fmt.Sprintf("[NON-JSON: %d bytes, not logged]", len(body))
+	}
+	return string(redacted)
+}
+
+// JSON 对 JSON 文档脱敏，body 不是合法 JSON 时 ok 为 false。
+func (p Policy) JSON(body []byte) (redacted []byte, ok bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, false
+	}
+	doc = redactSensitiveKeys(doc)
+	for _, path := range p.paths {
+		doc = redactPath(doc, path)
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// stream 逐行处理 SSE，截断时丢弃最后一个不完整的行。
+func (p Policy) stream(body []byte, truncated bool) string {
+	lines := strings.Split(string(body), "\n")
+	if truncated && len(lines) > 0 {
+		lines = lines[:len(lines)-1]
+	}
+	for i, line := range lines {
+		data, ok := strings.CutPrefix(line, "data:")
+		data = strings.TrimSpace(data)
+		if !ok || data == "" || data == "[DONE]" {
+			continue
+		}
+		if redacted, ok := p.JSON([]byte(data)); ok {
+			lines[i] = "data: " + string(redacted)
+		} else {
+			lines[i] = "data: " + reqtrace.Redacted
+		}
+	}
+	out := strings.Join(lines, "\n")
+	if truncated {
+		out += "\n[TRUNCATED]"
+	}
+	return out
+}
+
+func redactSensitiveKeys(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, child := range typed {
+			// 只遮蔽字符串取值，max_tokens、prompt_tokens 等数值字段照常记录。
+			if _, isString := child.(string); isString && reqtrace.Sensitive(key) {
+				typed[key] = reqtrace.Redacted
+				continue
+			}
+			typed[key] = redactSensitiveKeys(child)
+		}
+	case []any:
+		for i, child := range typed {
+			typed[i] = redactSensitiveKeys(child)
+		}
+	}
+	return value
+}
+
+func redactPath(value any, path []step) any {
+	if len(path) == 0 {
+		return reqtrace.Redacted
+	}
+	current, rest := path[0], path[1:]
+	switch typed := value.(type) {
+	case map[string]any:
+		if current.isIndex || current.wildcard {
+			return value
+		}
+		if child, ok := typed[current.key]; ok {
+			typed[current.key] = redactPath(child, rest)
+		}
+	case []any:
+		switch {
+		case current.wildcard:
+			for i, child := range typed {
+				typed[i] = redactPath(child, rest)
+			}
+		case current.isIndex && current.index < len(typed):
+			typed[current.index] = redactPath(typed[current.index], rest)
+		}
+	}
+	return value
+}
@@ -0,0 +1,53 @@
+package bodylog
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_RedactsPathsAndSensitiveFields(t *testing.T) {
+	policy, err := ParsePolicy([]string{"messages[*].content", "metadata.email", "input[1]", "missing.path"})
+	require.NoError(t, err)
+
+	body := `{"model":"gpt-4o","max_tokens":256,"api_key":"sk-secret",` +
+		`"messages":[{"role":"system","content":"be nice"},{"role":"user","content":"my ssn is 123"}],` +
+		`"metadata":{"email":"a@example.com","team":"search"},"input":["keep","drop"]}`
+	require.JSONEq(t, `{"model":"gpt-4o","max_tokens":256,"api_key":"[REDACTED]",`+
+		`"messages":[{"role":"system","content":"[REDACTED]"},{"role":"user","content":"[REDACTED]"}],`+
+		`"metadata":{"email":"[REDACTED]","team":"search"},"input":["keep","[REDACTED]"]}`,
+		policy.Body("application/json", []byte(body), false))
+
+	require.Equal(t, "[TRUNCATED: 5 bytes captured, not logged]", policy.Body("application/json", []byte(`{"mod`), true))
+	require.Equal(t, "[NON-JSON: 5 bytes, not logged]", policy.Body("text/plain", []byte("hello"), false))
+}
+
+func TestPolicy_RedactsStreamEvents(t *testing.T) {
+	policy, err := ParsePolicy([]string{"choices[*].delta.content"})
+	require.NoError(t, err)
+	stream := "data: {\"choices\":[{\"delta\":{\"content\":\"secret\"}}],\"usage\":{\"prompt_tokens\":3}}\n\n" +
+		"data: not-json\n\ndata: [DONE]\n\ndata: {\"choi"
+	require.Equal(t, "data: {\"choices\":[{\"delta\":{\"content\":\"[REDACTED]\"}}],\"usage\":{\"prompt_tokens\":3}}\n\n"+
+		"data: [REDACTED]\n\ndata: [DONE]\n\n[TRUNCATED]",
+		policy.Body("text/event-stream", []byte(stream), true))
+}
+
+func TestParsePolicy_RejectsInvalidPaths(t *testing.T) {
+	for _, path := range []string{"a..b", "messages[x]", "messages[*", "messages[0]content", "messages[-1]"} {
+		_, err := ParsePolicy([]string{path})
+		require.ErrorIs(t, err, ErrInvalidPath, path)
+	}
+}
+
+func TestHeaders_StripsCredentials(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer sk-upstream")
+	header.Set("Cookie", "session=1")
+	header.Set("X-Api-Key", "sk-client")
+	header.Set("Content-Type", "application/json")
+	require.Equal(t, map[string]string{
+		"X-Api-Key":    "[REDACTED]",
+		"Content-Type": "application/json",
+	}, Headers(header))
+}
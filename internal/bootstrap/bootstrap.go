@@ -0,0 +1,206 @@
+// Package bootstrap 在启动时应用引导清单：按清单幂等地创建基线规则、用户与 API Key，
+// 使新数据库启动后即具备组织的基础配置。
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// ErrInvalidManifest 表示引导清单无法解析或内容不合法，如 ID 重复或 API Key 引用了未知用户。
+var ErrInvalidManifest = errors.New("invalid bootstrap manifest")
+
+// ErrAccountsUnavailable 表示清单声明了用户或 API Key，但未配置账户服务（DATABASE_DSN）。
+var ErrAccountsUnavailable = errors.New("bootstrap manifest declares users or api keys but accounts are not configured")
+
+// Manifest 是引导清单。应用时只创建缺失的资源：规则按 ID、用户按用户名、API Key 按前缀判断是否已存在，
+// 已存在的资源（包括之后经管理 API 修改过的）保持不变，因此每次启动都可以安全地重复应用。
+type Manifest struct {
+	Rules   []rules.Rule `json:"rules"`
+	Users   []User       `json:"users"`
+	APIKeys []APIKey     `json:"api_keys"`
+}
+
+// User 描述需要存在的用户，以用户名标识。
+type User struct {
+	Name                  string         `json:"name"`
+	Description           string         `json:"description,omitempty"`
+	Metadata              map[string]any `json:"metadata,omitempty"`
+	MaxRequestsPerMinute  int            `json:"max_requests_per_minute,omitempty"`
+	MaxConcurrentRequests int            `json:"max_concurrent_requests,omitempty"`
+	AllowedModels         []string       `json:"allowed_models,omitempty"`
+	DeniedModels          []string       `json:"denied_models,omitempty"`
+}
+
+// APIKey 描述需要存在的 API Key。User 为所属用户名，可以是清单中的用户或已存在的用户；
+// Key 为完整明文（yapi_<8 位十六进制>_<40 位十六进制>），以便客户端预先配置同一个 Key。
+type APIKey struct {
+	User                  string   `json:"user"`
+	Label                 string   `json:"label,omitempty"`
+	Key                   string   `json:"key"`
+	MaxRequestsPerMinute  int      `json:"max_requests_per_minute,omitempty"`
+	MaxConcurrentRequests int      `json:"max_concurrent_requests,omitempty"`
+	AllowedModels         []string `json:"allowed_models,omitempty"`
+	DeniedModels          []string `json:"denied_models,omitempty"`
+}
+
+// Result 汇总一次应用中新建的资源数量，已存在而跳过的资源计入 Existing。
+type Result struct {
+	RulesCreated   int
+	UsersCreated   int
+	APIKeysCreated int
+	Existing       int
+}
+
+// DefaultManifest 是未配置 BOOTSTRAP_MANIFEST 时应用的清单，只包含一条默认停用、指向 OpenAI 的示例规则。
+func DefaultManifest() Manifest {
+	return Manifest{Rules: []rules.Rule{{
+		ID:       "bootstrap-openai",
+		Priority: 100,
+		Matcher: rules.Matcher{
+			PathPrefix: "/v1",
+			Methods:    []string{"POST"},
+		},
+		Actions: rules.Actions{
+			SetTargetURL: "https://api.openai.com",
+		},
+		Enabled: false,
+	}}}
+}
+
+// Parse 解析 JSON 格式的引导清单并检查其内容，未知字段视为错误以便发现拼写错误。
+func Parse(data []byte) (Manifest, error) {
+	var manifest Manifest
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&manifest); err != nil {
+		return Manifest{}, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+	if err := manifest.Validate(); err != nil {
+		return Manifest{}, err
+	}
+	return manifest, nil
+}
+
+// Validate 检查规则 ID、用户名与 API Key 非空且不重复；规则与账户字段的具体校验在创建时由各服务完成。
+func (m Manifest) Validate() error {
+	seen := make(map[string]struct{})
+	check := func(kind, value string, index int) error {
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("%w: %s[%d] is empty", ErrInvalidManifest, kind, index)
+		}
+		if _, ok := seen[kind+"\x00"+value]; ok {
+			return fmt.Errorf("%w: duplicate %s %q", ErrInvalidManifest, kind, value)
+		}
+		seen[kind+"\x00"+value] = struct{}{}
+		return nil
+	}
+	for i, rule := range m.Rules {
+		if err := check("rule id", rule.ID, i); err != nil {
+			return err
+		}
+	}
+	for i, user := range m.Users {
+		if err := check("user name", user.Name, i); err != nil {
+			return err
+		}
+	}
+	for i, key := range m.APIKeys {
+		if strings.TrimSpace(key.User) == "" {
+			return fmt.Errorf("%w: api_keys[%d] has no user", ErrInvalidManifest, i)
+		}
+		if err := check("api key", key.Key, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply 依次创建清单中缺失的规则、用户与 API Key，遇到第一个错误即返回，已创建的资源保留，
+// 下次启动时会继续创建剩余部分。accountService 为 nil 时清单不得声明用户或 API Key。
+func Apply(ctx context.Context, manifest Manifest, ruleService rules.Service, accountService accounts.Service) (Result, error) {
+	var result Result
+	if accountService == nil && (len(manifest.Users) > 0 || len(manifest.APIKeys) > 0) {
+		return result, ErrAccountsUnavailable
+	}
+	for _, rule := range manifest.Rules {
+		_, err := ruleService.GetRule(ctx, rule.ID)
+		if err == nil {
+			result.Existing++
+			continue
+		}
+		if !errors.Is(err, rules.ErrRuleNotFound) {
+			return result, fmt.Errorf("get rule %q: %w", rule.ID, err)
+		}
+		if err := ruleService.UpsertRule(ctx, rule); err != nil {
+			return result, fmt.Errorf("create rule %q: %w", rule.ID, err)
+		}
+		result.RulesCreated++
+	}
+	if len(manifest.Users) == 0 && len(manifest.APIKeys) == 0 {
+		return result, nil
+	}
+
+	existing, _, err := accountService.ListUsers(ctx, accounts.ListOptions{})
+	if err != nil {
+		return result, fmt.Errorf("list users: %w", err)
+	}
+	userIDs := make(map[string]string, len(existing))
+	for _, user := range existing {
+		userIDs[user.Name] = user.ID
+	}
+	for _, user := range manifest.Users {
+		if _, ok := userIDs[user.Name]; ok {
+			result.Existing++
+			continue
+		}
+		created, err := accountService.CreateUser(ctx, accounts.CreateUserParams{
+			Name:        user.Name,
+			Description: user.Description,
+			Metadata:    user.Metadata,
+			RateLimits:  accounts.RateLimits{MaxRequestsPerMinute: user.MaxRequestsPerMinute, MaxConcurrentRequests: user.MaxConcurrentRequests},
+			ModelAccess: accounts.ModelAccess{AllowedModels: user.AllowedModels, DeniedModels: user.DeniedModels},
+		})
+		if err != nil {
+			return result, fmt.Errorf("create user %q: %w", user.Name, err)
+		}
+		userIDs[user.Name] = created.ID
+		result.UsersCreated++
+	}
+
+	for _, key := range manifest.APIKeys {
+		userID, ok := userIDs[key.User]
+		if !ok {
+			return result, fmt.Errorf("%w: api key %q references unknown user %q", ErrInvalidManifest, key.Label, key.User)
+		}
+		resolved, err := accountService.ResolveAPIKey(ctx, key.Key)
+		if err == nil {
+			if resolved.UserID != userID {
+				return result, fmt.Errorf("%w: api key %q already belongs to another user", ErrInvalidManifest, key.Label)
+			}
+			result.Existing++
+			continue
+		}
+		if !errors.Is(err, accounts.ErrNotFound) {
+			return result, fmt.Errorf("resolve api key %q: %w", key.Label, err)
+		}
+		if _, _, err := accountService.CreateUserAPIKey(ctx, accounts.CreateAPIKeyParams{
+			UserID:      userID,
+			Label:       key.Label,
+			RateLimits:  accounts.RateLimits{MaxRequestsPerMinute: key.MaxRequestsPerMinute, MaxConcurrentRequests: key.MaxConcurrentRequests},
+			ModelAccess: accounts.ModelAccess{AllowedModels: key.AllowedModels, DeniedModels: key.DeniedModels},
+			Plaintext:   key.Key,
+		}); err != nil {
+			return result, fmt.Errorf("create api key %q: %w", key.Label, err)
+		}
+		result.APIKeysCreated++
+	}
+	return result, nil
+}
@@ -0,0 +1,90 @@
+package bootstrap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+const testKey = "yapi_0123abcd_00112233445566778899aabbccddeeff00112233"
+
+func setupAccounts(t *testing.T, dsn string) accounts.Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	svc := accounts.NewService(db)
+	require.NoError(t, svc.AutoMigrate(context.Background()))
+	return svc
+}
+
+func TestApply_CreatesMissingResourcesIdempotently(t *testing.T) {
+	ctx := context.Background()
+	ruleSvc := rules.NewService(rules.NewMemoryStore())
+	accountSvc := setupAccounts(t, "file:bootstrap_apply?mode=memory&cache=shared")
+
+	manifest, err := Parse([]byte(`{
+		"rules": [{"id": "openai", "priority": 10, "matcher": {"path_prefix": "/v1"}, "actions": {"set_target_url": "https://api.openai.com"}, "enabled": true}],
+		"users": [{"name": "ci", "max_requests_per_minute": 60}],
+		"api_keys": [{"user": "ci", "label": "pipeline", "key": "` + testKey + `"}]
+	}`))
+	require.NoError(t, err)
+
+	result, err := Apply(ctx, manifest, ruleSvc, accountSvc)
+	require.NoError(t, err)
+	require.Equal(t, Result{RulesCreated: 1, UsersCreated: 1, APIKeysCreated: 1}, result)
+
+	key, err := accountSvc.ResolveAPIKey(ctx, testKey)
+	require.NoError(t, err)
+	require.Equal(t, "pipeline", key.Label)
+
+	// 管理员之后修改的规则不会被清单覆盖。
+	rule, err := ruleSvc.GetRule(ctx, "openai")
+	require.NoError(t, err)
+	rule.Enabled = false
+	require.NoError(t, ruleSvc.UpsertRule(ctx, rule))
+
+	result, err = Apply(ctx, manifest, ruleSvc, accountSvc)
+	require.NoError(t, err)
+	require.Equal(t, Result{Existing: 3}, result)
+	rule, err = ruleSvc.GetRule(ctx, "openai")
+	require.NoError(t, err)
+	require.False(t, rule.Enabled)
+}
+
+func TestApply_RequiresAccountsForUsers(t *testing.T) {
+	ruleSvc := rules.NewService(rules.NewMemoryStore())
+	_, err := Apply(context.Background(), Manifest{Users: []User{{Name: "ci"}}}, ruleSvc, nil)
+	require.ErrorIs(t, err, ErrAccountsUnavailable)
+
+	result, err := Apply(context.Background(), DefaultManifest(), ruleSvc, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.RulesCreated)
+}
+
+func TestParse_RejectsInvalidManifests(t *testing.T) {
+	for name, raw := range map[string]string{
+		"unknown field":    `{"rule": []}`,
+		"duplicate rule":   `{"rules": [{"id": "a"}, {"id": "a"}]}`,
+		"empty user":       `{"users": [{"name": " "}]}`,
+		"key without user": `{"api_keys": [{"key": "` + testKey + `"}]}`,
+	} {
+		_, err := Parse([]byte(raw))
+		require.ErrorIs(t, err, ErrInvalidManifest, name)
+	}
+}
+
+func TestApply_RejectsMalformedKeys(t *testing.T) {
+	accountSvc := setupAccounts(t, "file:bootstrap_keys?mode=memory&cache=shared")
+	manifest := Manifest{
+		Users:   []User{{Name: "ci"}},
+		APIKeys: []APIKey{{User: "ci", Key: "yapi_0123abcd_short"}},
+	}
+	_, err := Apply(context.Background(), manifest, rules.NewService(rules.NewMemoryStore()), accountSvc)
+	require.ErrorIs(t, err, accounts.ErrInvalidInput)
+}
@@ -0,0 +1,482 @@
+// Package budget 按用户或组织（用户元数据 org）累计代理调用的估算费用，与预算上限比较：
+// 花费越过告警阈值时向预算配置的 Webhook 与邮箱发送通知，开启 hard_stop 的预算用尽后代理拒绝该主体的请求。
+// 花费按周期（daily / monthly / total）累计，周期以 UTC 划分，进入新周期时自动清零。
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+var (
+	// ErrNotFound 表示预算不存在。
+	ErrNotFound = errors.New("budget not found")
+	// ErrInvalidInput 表示预算参数不合法。
+	ErrInvalidInput = errors.New("invalid budget input")
+	// ErrExceeded 表示开启 hard_stop 的预算已用尽。
+	ErrExceeded = errors.New("budget exceeded")
+)
+
+// 预算作用的主体类型。
+const (
+	ScopeUser = "user"
+	ScopeOrg  = "org"
+)
+
+// 预算周期。
+const (
+	PeriodDaily   = "daily"
+	PeriodMonthly = "monthly"
+	PeriodTotal   = "total"
+)
+
+// OrgMetadataKey 是用户元数据中表示所属组织的字段。
+const OrgMetadataKey = "org"
+
+// defaultThresholds 是未配置阈值时的告警点：用到 80% 与用尽时各通知一次。
+var defaultThresholds = []float64{0.8, 1}
+
+// Budget 是一个主体的预算及当前周期的花费。Thresholds 为占上限的比例，
+// Notified 记录本周期已通知的最高阈值，避免重复通知。
+type Budget struct {
+	Scope       string    `gorm:"type:varchar(16);primaryKey"`
+	Subject     string    `gorm:"type:varchar(255);primaryKey"`
+	LimitUSD    float64   `gorm:"not null"`
+	Period      string    `gorm:"type:varchar(16);not null"`
+	Thresholds  []float64 `gorm:"serializer:json;type:text"`
+	WebhookURL  string    `gorm:"type:text"`
+	Emails      []string  `gorm:"serializer:json;type:text"`
+	HardStop    bool
+	SpentUSD    float64
+	Notified    float64
+	PeriodStart time.Time
+	UpdatedAt   time.Time
+}
+
+// TableName 指定表名。
+func (Budget) TableName() string {
+	return "budgets"
+}
+
+// Exceeded 判断本周期花费是否已达到上限。
+func (b Budget) Exceeded() bool {
+	return b.LimitUSD > 0 && b.SpentUSD >= b.LimitUSD
+}
+
+// PeriodStart 返回 now 所在周期的起点（UTC），total 周期固定为零值。
+func PeriodStart(period string, now time.Time) time.Time {
+	now = now.UTC()
+	switch period {
+	case PeriodDaily:
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	case PeriodMonthly:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Time{}
+	}
+}
+
+// Store 定义预算的持久化接口。AddSpend、MarkNotified 与 Reset 须原子执行，使多实例共享同一份花费。
+type Store interface {
+	List(ctx context.Context) ([]Budget, error)
+	Get(ctx context.Context, scope, subject string) (Budget, error)
+	// Upsert 保存预算配置，保留已有的花费与通知状态。
+	Upsert(ctx context.Context, budget Budget) (Budget, error)
+	Delete(ctx context.Context, scope, subject string) error
+	// AddSpend 累加花费；记录的周期早于 periodStart 时先清零花费与通知状态。
+	AddSpend(ctx context.Context, scope, subject string, amount float64, periodStart time.Time) (Budget, error)
+	// MarkNotified 在本周期尚未通知 threshold 时记录并返回 true。
+	MarkNotified(ctx context.Context, scope, subject string, threshold float64) (bool, error)
+	// Reset 清零花费与通知状态，并把周期起点设为 periodStart。
+	Reset(ctx context.Context, scope, subject string, periodStart time.Time) (Budget, error)
+}
+
+// Alert 是一次预算告警，以 JSON 发送给 Webhook。
+type Alert struct {
+	Scope       string    `json:"scope"`
+	Subject     string    `json:"subject"`
+	Period      string    `json:"period"`
+	PeriodStart time.Time `json:"period_start"`
+	Threshold   float64   `json:"threshold"`
+	SpentUSD    float64   `json:"spent_usd"`
+	LimitUSD    float64   `json:"limit_usd"`
+	Exceeded    bool      `json:"exceeded"`
+	HardStop    bool      `json:"hard_stop"`
+	Time        time.Time `json:"time"`
+}
+
+// Option 定义 Service 可选项。
+type Option func(*Service)
+
+// WithHTTPClient 设置发送 Webhook 使用的 HTTP 客户端。
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Service) {
+		if client != nil {
+			s.client = client
+		}
+	}
+}
+
+// WithMailer 设置发送邮件告警的客户端，未设置时忽略预算中的邮箱。
+func WithMailer(mailer Mailer) Option {
+	return func(s *Service) {
+		s.mailer = mailer
+	}
+}
+
+// WithLogger 设置日志记录器。
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Service) {
+		if logger != nil {
+			s.logger = logger
+		}
+	}
+}
+
+// Service 在内存中保存预算表供代理热路径检查，花费写入存储后以存储返回的结果更新内存；
+// 其他实例累计的花费在定期重新加载时同步。
+type Service struct {
+	store  Store
+	client *http.Client
+	mailer Mailer
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu      sync.RWMutex
+	budgets map[string]Budget
+	// notify 跟踪尚未完成的告警发送，供测试等待。
+	notify sync.WaitGroup
+}
+
+// NewService 基于存储创建预算服务，调用 Load 后预算才会生效。
+func NewService(store Store, opts ...Option) *Service {
+	s := &Service{
+		store:   store,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		logger:  slog.Default(),
+		now:     time.Now,
+		budgets: map[string]Budget{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func key(scope, subject string) string {
+	return scope + ":" + subject
+}
+
+// Load 从存储重新加载预算表。
+func (s *Service) Load(ctx context.Context) error {
+	budgets, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	table := make(map[string]Budget, len(budgets))
+	for _, b := range budgets {
+		table[key(b.Scope, b.Subject)] = b
+	}
+	s.mu.Lock()
+	s.budgets = table
+	s.mu.Unlock()
+	return nil
+}
+
+// Start 每隔 interval 重新加载预算表，同步其他实例累计的花费，直到 ctx 结束。
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Load(ctx); err != nil {
+					s.logger.Warn("budgets reload failed", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// subjects 返回请求主体对应的预算键：用户本身与其所属组织（组织为空时省略）。
+func subjects(userID, org string) [][2]string {
+	var list [][2]string
+	if userID != "" {
+		list = append(list, [2]string{ScopeUser, userID})
+	}
+	if org != "" {
+		list = append(list, [2]string{ScopeOrg, org})
+	}
+	return list
+}
+
+// ExceededError 描述被用尽的预算，errors.Is(err, ErrExceeded) 成立。
+type ExceededError struct {
+	Budget Budget
+}
+
+func (e *ExceededError) Error() string {
+	b := e.Budget
+	return fmt.Sprintf("%s: %s %s spent $%.4f of $%.4f %s budget", ErrExceeded, b.Scope, b.Subject, b.SpentUSD, b.LimitUSD, b.Period)
+}
+
+func (e *ExceededError) Unwrap() error {
+	return ErrExceeded
+}
+
+// Check 在转发前检查用户及其组织的预算，任一开启 hard_stop 的预算在本周期已用尽时返回 *ExceededError。
+// 只读取内存中的预算表。
+func (s *Service) Check(userID, org string) error {
+	now := s.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, subject := range subjects(userID, org) {
+		b, ok := s.budgets[key(subject[0], subject[1])]
+		if !ok || !b.HardStop || b.PeriodStart.Before(PeriodStart(b.Period, now)) {
+			continue
+		}
+		if b.Exceeded() {
+			return &ExceededError{Budget: b}
+		}
+	}
+	return nil
+}
+
+// Record 把一次调用的费用计入用户及其组织的预算，越过告警阈值时异步发送通知。
+func (s *Service) Record(ctx context.Context, userID, org string, cost float64) {
+	if cost <= 0 {
+		return
+	}
+	now := s.now()
+	for _, subject := range subjects(userID, org) {
+		s.mu.RLock()
+		current, ok := s.budgets[key(subject[0], subject[1])]
+		s.mu.RUnlock()
+		if !ok {
+			continue
+		}
+		updated, err := s.store.AddSpend(ctx, subject[0], subject[1], cost, PeriodStart(current.Period, now))
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) {
+				s.logger.Warn("record budget spend failed", "scope", subject[0], "subject", subject[1], "error", err)
+			}
+			continue
+		}
+		s.mu.Lock()
+		s.budgets[key(updated.Scope, updated.Subject)] = updated
+		s.mu.Unlock()
+		s.checkThresholds(ctx, updated)
+	}
+}
+
+// checkThresholds 找出花费已越过且本周期尚未通知的最高阈值，抢占成功的实例负责发送通知。
+func (s *Service) checkThresholds(ctx context.Context, b Budget) {
+	if b.LimitUSD <= 0 {
+		return
+	}
+	crossed := 0.0
+	for _, threshold := range b.Thresholds {
+		if b.SpentUSD >= threshold*b.LimitUSD && threshold > crossed {
+			crossed = threshold
+		}
+	}
+	if crossed <= b.Notified {
+		return
+	}
+	claimed, err := s.store.MarkNotified(ctx, b.Scope, b.Subject, crossed)
+	if err != nil {
+		s.logger.Warn("mark budget alert failed", "scope", b.Scope, "subject", b.Subject, "error", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	alert := Alert{
+		Scope:       b.Scope,
+		Subject:     b.Subject,
+		Period:      b.Period,
+		PeriodStart: b.PeriodStart,
+		Threshold:   crossed,
+		SpentUSD:    b.SpentUSD,
+		LimitUSD:    b.LimitUSD,
+		Exceeded:    b.Exceeded(),
+		HardStop:    b.HardStop,
+		Time:        s.now().UTC(),
+	}
+	s.logger.Warn("budget threshold reached",
+		"scope", b.Scope,
+		"subject", b.Subject,
+		"threshold", crossed,
+		"spent_usd", b.SpentUSD,
+		"limit_usd", b.LimitUSD,
+	)
+	s.notify.Add(1)
+	go func() {
+		defer s.notify.Done()
+		s.send(b, alert)
+	}()
+}
+
+// send 向预算配置的 Webhook 与邮箱发送告警，失败只记录日志。
+func (s *Service) send(b Budget, alert Alert) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if b.WebhookURL != "" {
+		err := postWebhook(ctx, s.client, b.WebhookURL, alert)
+		if err != nil {
+			s.logger.Warn("budget webhook failed", "scope", b.Scope, "subject", b.Subject, "error", err)
+		}
+		metrics.ObserveBudgetAlert(b.Scope, "webhook", err == nil)
+	}
+	if len(b.Emails) > 0 && s.mailer != nil {
+		subject, body := alertEmail(alert)
+		err := s.mailer.Send(ctx, b.Emails, subject, body)
+		if err != nil {
+			s.logger.Warn("budget email failed", "scope", b.Scope, "subject", b.Subject, "error", err)
+		}
+		metrics.ObserveBudgetAlert(b.Scope, "email", err == nil)
+	}
+}
+
+// List 返回全部预算，按主体类型与名称排序；花费属于已结束的周期时按本周期清零展示。
+func (s *Service) List(ctx context.Context) ([]Budget, error) {
+	budgets, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range budgets {
+		budgets[i] = s.current(budgets[i])
+	}
+	slices.SortFunc(budgets, func(a, b Budget) int {
+		return strings.Compare(key(a.Scope, a.Subject), key(b.Scope, b.Subject))
+	})
+	return budgets, nil
+}
+
+// Get 返回单个预算。
+func (s *Service) Get(ctx context.Context, scope, subject string) (Budget, error) {
+	b, err := s.store.Get(ctx, scope, subject)
+	if err != nil {
+		return Budget{}, err
+	}
+	return s.current(b), nil
+}
+
+// current 把已结束周期的花费视为本周期的零值。
+func (s *Service) current(b Budget) Budget {
+	if start := PeriodStart(b.Period, s.now()); b.PeriodStart.Before(start) {
+		b.SpentUSD, b.Notified, b.PeriodStart = 0, 0, start
+	}
+	return b
+}
+
+// Upsert 校验并保存预算配置，保留已有花费，立即对本实例生效。
+func (s *Service) Upsert(ctx context.Context, b Budget) (Budget, error) {
+	b, err := normalize(b)
+	if err != nil {
+		return Budget{}, err
+	}
+	b.PeriodStart = PeriodStart(b.Period, s.now())
+	saved, err := s.store.Upsert(ctx, b)
+	if err != nil {
+		return Budget{}, err
+	}
+	s.mu.Lock()
+	s.budgets[key(saved.Scope, saved.Subject)] = saved
+	s.mu.Unlock()
+	return s.current(saved), nil
+}
+
+// Delete 删除预算。
+func (s *Service) Delete(ctx context.Context, scope, subject string) error {
+	if err := s.store.Delete(ctx, scope, subject); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.budgets, key(scope, subject))
+	s.mu.Unlock()
+	return nil
+}
+
+// Reset 清零预算本周期的花费与通知状态，hard_stop 拦截随即解除。
+func (s *Service) Reset(ctx context.Context, scope, subject string) (Budget, error) {
+	current, err := s.store.Get(ctx, scope, subject)
+	if err != nil {
+		return Budget{}, err
+	}
+	b, err := s.store.Reset(ctx, scope, subject, PeriodStart(current.Period, s.now()))
+	if err != nil {
+		return Budget{}, err
+	}
+	s.mu.Lock()
+	s.budgets[key(b.Scope, b.Subject)] = b
+	s.mu.Unlock()
+	return b, nil
+}
+
+// normalize 校验预算配置并填充默认值：上限为正数，周期默认 monthly，阈值为 (0, 1] 内的比例并升序去重。
+func normalize(b Budget) (Budget, error) {
+	b.Scope = strings.ToLower(strings.TrimSpace(b.Scope))
+	b.Subject = strings.TrimSpace(b.Subject)
+	if b.Scope != ScopeUser && b.Scope != ScopeOrg {
+		return Budget{}, fmt.Errorf("%w: scope must be %s or %s", ErrInvalidInput, ScopeUser, ScopeOrg)
+	}
+	if b.Subject == "" || len(b.Subject) > 255 {
+		return Budget{}, fmt.Errorf("%w: subject must be 1-255 characters", ErrInvalidInput)
+	}
+	if b.LimitUSD <= 0 {
+		return Budget{}, fmt.Errorf("%w: limit_usd must be positive", ErrInvalidInput)
+	}
+	b.Period = strings.ToLower(strings.TrimSpace(b.Period))
+	switch b.Period {
+	case "":
+		b.Period = PeriodMonthly
+	case PeriodDaily, PeriodMonthly, PeriodTotal:
+	default:
+		return Budget{}, fmt.Errorf("%w: period must be daily, monthly or total", ErrInvalidInput)
+	}
+	if len(b.Thresholds) == 0 {
+		b.Thresholds = slices.Clone(defaultThresholds)
+	}
+	for _, threshold := range b.Thresholds {
+		if threshold <= 0 || threshold > 1 {
+			return Budget{}, fmt.Errorf("%w: thresholds must be fractions in (0, 1]", ErrInvalidInput)
+		}
+	}
+	slices.Sort(b.Thresholds)
+	b.Thresholds = slices.Compact(b.Thresholds)
+	b.WebhookURL = strings.TrimSpace(b.WebhookURL)
+	if b.WebhookURL != "" {
+		u, err := url.Parse(b.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Budget{}, fmt.Errorf("%w: webhook_url must be an absolute http(s) URL", ErrInvalidInput)
+		}
+	}
+	emails := make([]string, 0, len(b.Emails))
+	for _, email := range b.Emails {
+		email = strings.TrimSpace(email)
+		if _, err := mail.ParseAddress(email); err != nil {
+			return Budget{}, fmt.Errorf("%w: invalid email %q", ErrInvalidInput, email)
+		}
+		emails = append(emails, email)
+	}
+	b.Emails = emails
+	return b, nil
+}
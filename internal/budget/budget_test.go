@@ -0,0 +1,137 @@
+package budget
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newDBStore(t *testing.T, dsn string) *DBStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	store := NewDBStore(db)
+	require.NoError(t, store.AutoMigrate(context.Background()))
+	return store
+}
+
+type mailerStub struct {
+	mu   sync.Mutex
+	sent [][]string
+}
+
+func (m *mailerStub) Send(_ context.Context, to []string, subject, _ string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, append([]string{subject}, to...))
+	return nil
+}
+
+func TestService_AlertsAndHardStop(t *testing.T) {
+	for name, store := range map[string]Store{
+		"memory": NewMemoryStore(),
+		"db":     newDBStore(t, "file:budget_alerts?mode=memory&cache=shared"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			var (
+				mu     sync.Mutex
+				alerts []Alert
+			)
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var alert Alert
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&alert))
+				mu.Lock()
+				alerts = append(alerts, alert)
+				mu.Unlock()
+			}))
+			defer webhook.Close()
+			mailer := &mailerStub{}
+			svc := NewService(store, WithMailer(mailer))
+			now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+			svc.now = func() time.Time { return now }
+			require.NoError(t, svc.Load(ctx))
+
+			b, err := svc.Upsert(ctx, Budget{Scope: "Org", Subject: " acme ", LimitUSD: 10, Thresholds: []float64{1, 0.5}, WebhookURL: webhook.URL, Emails: []string{"ops@example.com"}, HardStop: true})
+			require.NoError(t, err)
+			require.Equal(t, PeriodMonthly, b.Period)
+			require.Equal(t, []float64{0.5, 1}, b.Thresholds)
+			require.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), b.PeriodStart.UTC())
+
+			// 用户本身没有预算时只计入组织。
+			svc.Record(ctx, "alice", "acme", 4)
+			svc.notify.Wait()
+			require.Empty(t, alerts)
+			svc.Record(ctx, "alice", "acme", 2)
+			svc.Record(ctx, "bob", "acme", 1)
+			svc.notify.Wait()
+			require.Len(t, alerts, 1)
+			require.Equal(t, 0.5, alerts[0].Threshold)
+			require.False(t, alerts[0].Exceeded)
+			require.NoError(t, svc.Check("alice", "acme"))
+
+			svc.Record(ctx, "bob", "acme", 5)
+			svc.notify.Wait()
+			require.Len(t, alerts, 2)
+			require.True(t, alerts[1].Exceeded)
+			require.Len(t, mailer.sent, 2)
+			require.Equal(t, "ops@example.com", mailer.sent[1][1])
+			require.ErrorIs(t, svc.Check("carol", "acme"), ErrExceeded)
+			require.NoError(t, svc.Check("carol", ""))
+
+			// 修改配置保留花费；重置后解除拦截。
+			b, err = svc.Upsert(ctx, Budget{Scope: "org", Subject: "acme", LimitUSD: 20, HardStop: true})
+			require.NoError(t, err)
+			require.InDelta(t, 12, b.SpentUSD, 1e-9)
+			require.NoError(t, svc.Check("carol", "acme"))
+			b, err = svc.Upsert(ctx, Budget{Scope: "org", Subject: "acme", LimitUSD: 10, HardStop: true})
+			require.NoError(t, err)
+			require.ErrorIs(t, svc.Check("carol", "acme"), ErrExceeded)
+			b, err = svc.Reset(ctx, "org", "acme")
+			require.NoError(t, err)
+			require.Zero(t, b.SpentUSD)
+			require.NoError(t, svc.Check("carol", "acme"))
+
+			// 进入下一个月后花费自动清零。
+			svc.Record(ctx, "bob", "acme", 11)
+			svc.notify.Wait()
+			require.ErrorIs(t, svc.Check("carol", "acme"), ErrExceeded)
+			now = now.Add(24 * time.Hour)
+			require.NoError(t, svc.Check("carol", "acme"))
+			svc.Record(ctx, "bob", "acme", 1)
+			b, err = svc.Get(ctx, "org", "acme")
+			require.NoError(t, err)
+			require.InDelta(t, 1, b.SpentUSD, 1e-9)
+			require.Equal(t, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), b.PeriodStart.UTC())
+
+			require.NoError(t, svc.Delete(ctx, "org", "acme"))
+			require.ErrorIs(t, svc.Delete(ctx, "org", "acme"), ErrNotFound)
+			_, err = svc.Reset(ctx, "org", "acme")
+			require.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestService_UpsertValidation(t *testing.T) {
+	svc := NewService(NewMemoryStore())
+	for _, b := range []Budget{
+		{Scope: "team", Subject: "a", LimitUSD: 1},
+		{Scope: "user", Subject: "", LimitUSD: 1},
+		{Scope: "user", Subject: "a", LimitUSD: 0},
+		{Scope: "user", Subject: "a", LimitUSD: 1, Period: "weekly"},
+		{Scope: "user", Subject: "a", LimitUSD: 1, Thresholds: []float64{1.5}},
+		{Scope: "user", Subject: "a", LimitUSD: 1, WebhookURL: "ftp://example.com"},
+		{Scope: "user", Subject: "a", LimitUSD: 1, Emails: []string{"not-an-email"}},
+	} {
+		_, err := svc.Upsert(context.Background(), b)
+		require.ErrorIs(t, err, ErrInvalidInput, "%+v", b)
+	}
+}
@@ -0,0 +1,90 @@
+package budget
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Mailer 发送邮件告警。
+type Mailer interface {
+	Send(ctx context.Context, to []string, subject, body string) error
+}
+
+// SMTPMailer 通过 SMTP 服务器发送纯文本邮件，配置了用户名时使用 PLAIN 认证（服务器支持时升级为 STARTTLS）。
+type SMTPMailer struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// Send 发送邮件。net/smtp 不支持上下文，ctx 只用于在发送前检查是否已取消。
+func (m SMTPMailer) Send(ctx context.Context, to []string, subject, body string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return fmt.Errorf("smtp addr: %w", err)
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return smtp.SendMail(m.Addr, auth, m.From, to, msg.Bytes())
+}
+
+// postWebhook 以 JSON POST 告警，非 2xx 响应视为失败。
+func postWebhook(ctx context.Context, client *http.Client, url string, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// alertEmail 生成告警邮件的主题与正文。
+func alertEmail(alert Alert) (subject, body string) {
+	state := fmt.Sprintf("reached %.0f%%", alert.Threshold*100)
+	if alert.Exceeded {
+		state = "exceeded"
+	}
+	subject = fmt.Sprintf("[yapi] %s budget for %s %s", alert.Period, alert.Scope, state)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Budget: %s %s\n", alert.Scope, alert.Subject)
+	fmt.Fprintf(&b, "Period: %s (since %s)\n", alert.Period, alert.PeriodStart.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Spent: $%.4f of $%.4f (%.0f%%)\n", alert.SpentUSD, alert.LimitUSD, alert.SpentUSD/alert.LimitUSD*100)
+	if alert.Exceeded && alert.HardStop {
+		b.WriteString("Requests are blocked until the budget is reset or the next period starts.\n")
+	}
+	return subject, b.String()
+}
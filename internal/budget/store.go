@@ -0,0 +1,215 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DBStore 基于 gorm 持久化预算，多实例共享同一份花费。
+type DBStore struct {
+	db *gorm.DB
+}
+
+// NewDBStore 创建数据库存储。
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// AutoMigrate 创建或更新预算表。
+func (s *DBStore) AutoMigrate(ctx context.Context) error {
+	return s.db.WithContext(ctx).AutoMigrate(&Budget{})
+}
+
+func (s *DBStore) List(ctx context.Context) ([]Budget, error) {
+	var budgets []Budget
+	if err := s.db.WithContext(ctx).Order("scope, subject").Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+	return budgets, nil
+}
+
+func (s *DBStore) Get(ctx context.Context, scope, subject string) (Budget, error) {
+	var b Budget
+	err := s.db.WithContext(ctx).Where("scope = ? AND subject = ?", scope, subject).Take(&b).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Budget{}, fmt.Errorf("%w: %s %s", ErrNotFound, scope, subject)
+	}
+	if err != nil {
+		return Budget{}, err
+	}
+	return b, nil
+}
+
+func (s *DBStore) Upsert(ctx context.Context, b Budget) (Budget, error) {
+	b.SpentUSD, b.Notified = 0, 0
+	b.UpdatedAt = time.Now().UTC()
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "scope"}, {Name: "subject"}},
+		DoUpdates: clause.AssignmentColumns([]string{"limit_usd", "period", "thresholds", "webhook_url", "emails", "hard_stop", "updated_at"}),
+	}).Create(&b).Error
+	if err != nil {
+		return Budget{}, err
+	}
+	return s.Get(ctx, b.Scope, b.Subject)
+}
+
+func (s *DBStore) Delete(ctx context.Context, scope, subject string) error {
+	result := s.db.WithContext(ctx).Delete(&Budget{}, "scope = ? AND subject = ?", scope, subject)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s %s", ErrNotFound, scope, subject)
+	}
+	return nil
+}
+
+// AddSpend 以单条 UPDATE 累加花费，周期翻转在同一语句内完成，多实例并发写入不会丢失。
+func (s *DBStore) AddSpend(ctx context.Context, scope, subject string, amount float64, periodStart time.Time) (Budget, error) {
+	result := s.db.WithContext(ctx).Model(&Budget{}).
+		Where("scope = ? AND subject = ?", scope, subject).
+		Updates(map[string]any{
+			"spent_usd":    gorm.Expr("CASE WHEN period_start < ? THEN ? ELSE spent_usd + ? END", periodStart, amount, amount),
+			"notified":     gorm.Expr("CASE WHEN period_start < ? THEN 0 ELSE notified END", periodStart),
+			"period_start": gorm.Expr("CASE WHEN period_start < ? THEN ? ELSE period_start END", periodStart, periodStart),
+		})
+	if result.Error != nil {
+		return Budget{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return Budget{}, fmt.Errorf("%w: %s %s", ErrNotFound, scope, subject)
+	}
+	return s.Get(ctx, scope, subject)
+}
+
+// MarkNotified 以条件 UPDATE 抢占告警，只有一个实例会更新成功。
+func (s *DBStore) MarkNotified(ctx context.Context, scope, subject string, threshold float64) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&Budget{}).
+		Where("scope = ? AND subject = ? AND notified < ?", scope, subject, threshold).
+		Update("notified", threshold)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (s *DBStore) Reset(ctx context.Context, scope, subject string, periodStart time.Time) (Budget, error) {
+	result := s.db.WithContext(ctx).Model(&Budget{}).
+		Where("scope = ? AND subject = ?", scope, subject).
+		Updates(map[string]any{"spent_usd": 0, "notified": 0, "period_start": periodStart, "updated_at": time.Now().UTC()})
+	if result.Error != nil {
+		return Budget{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return Budget{}, fmt.Errorf("%w: %s %s", ErrNotFound, scope, subject)
+	}
+	return s.Get(ctx, scope, subject)
+}
+
+// MemoryStore 在进程内保存预算，适用于未配置数据库的单实例部署，重启后花费清零。
+type MemoryStore struct {
+	mu      sync.Mutex
+	budgets map[string]Budget
+}
+
+// NewMemoryStore 创建内存存储。
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{budgets: map[string]Budget{}}
+}
+
+func clone(b Budget) Budget {
+	b.Thresholds = slices.Clone(b.Thresholds)
+	b.Emails = slices.Clone(b.Emails)
+	return b
+}
+
+func (s *MemoryStore) List(context.Context) ([]Budget, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	budgets := make([]Budget, 0, len(s.budgets))
+	for _, b := range s.budgets {
+		budgets = append(budgets, clone(b))
+	}
+	return budgets, nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, scope, subject string) (Budget, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.budgets[key(scope, subject)]
+	if !ok {
+		return Budget{}, fmt.Errorf("%w: %s %s", ErrNotFound, scope, subject)
+	}
+	return clone(b), nil
+}
+
+func (s *MemoryStore) Upsert(_ context.Context, b Budget) (Budget, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b = clone(b)
+	b.UpdatedAt = time.Now().UTC()
+	if existing, ok := s.budgets[key(b.Scope, b.Subject)]; ok {
+		b.SpentUSD, b.Notified, b.PeriodStart = existing.SpentUSD, existing.Notified, existing.PeriodStart
+	} else {
+		b.SpentUSD, b.Notified = 0, 0
+	}
+	s.budgets[key(b.Scope, b.Subject)] = b
+	return clone(b), nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, scope, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.budgets[key(scope, subject)]; !ok {
+		return fmt.Errorf("%w: %s %s", ErrNotFound, scope, subject)
+	}
+	delete(s.budgets, key(scope, subject))
+	return nil
+}
+
+func (s *MemoryStore) AddSpend(_ context.Context, scope, subject string, amount float64, periodStart time.Time) (Budget, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.budgets[key(scope, subject)]
+	if !ok {
+		return Budget{}, fmt.Errorf("%w: %s %s", ErrNotFound, scope, subject)
+	}
+	if b.PeriodStart.Before(periodStart) {
+		b.SpentUSD, b.Notified, b.PeriodStart = 0, 0, periodStart
+	}
+	b.SpentUSD += amount
+	s.budgets[key(scope, subject)] = b
+	return clone(b), nil
+}
+
+func (s *MemoryStore) MarkNotified(_ context.Context, scope, subject string, threshold float64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.budgets[key(scope, subject)]
+	if !ok || b.Notified >= threshold {
+		return false, nil
+	}
+	b.Notified = threshold
+	s.budgets[key(scope, subject)] = b
+	return true, nil
+}
+
+func (s *MemoryStore) Reset(_ context.Context, scope, subject string, periodStart time.Time) (Budget, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.budgets[key(scope, subject)]
+	if !ok {
+		return Budget{}, fmt.Errorf("%w: %s %s", ErrNotFound, scope, subject)
+	}
+	b.SpentUSD, b.Notified, b.PeriodStart = 0, 0, periodStart
+	b.UpdatedAt = time.Now().UTC()
+	s.budgets[key(scope, subject)] = b
+	return clone(b), nil
+}
@@ -0,0 +1,263 @@
+// Package dialer 构建代理建立上游连接使用的拨号函数：可限定或优先使用 IPv4 / IPv6，
+// 在首选地址族迟迟连不上时按 Happy Eyeballs（RFC 8305）延迟并行尝试另一地址族，
+// 避免部分服务商 IPv6 线路不通导致的间歇性超时。
+package dialer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+)
+
+// IP 地址族偏好的取值。
+const (
+	PreferenceAuto = "auto"
+	PreferIPv4     = "prefer_ipv4"
+	PreferIPv6     = "prefer_ipv6"
+	IPv4Only       = "ipv4_only"
+	IPv6Only       = "ipv6_only"
+)
+
+// Preferences 列出 Options.Preference 支持的全部取值。
+var Preferences = []string{PreferenceAuto, PreferIPv4, PreferIPv6, IPv4Only, IPv6Only}
+
+// ErrInvalidOptions 表示拨号设置不合法。
+var ErrInvalidOptions = errors.New("invalid dialer options")
+
+// minAttemptTimeout 是按剩余地址平分建连时限时单个地址至少分到的时长，与标准库一致。
+const minAttemptTimeout = 2 * time.Second
+
+// Resolver 把主机名解析为 IP 地址；同时实现 Invalidate(host string) 时，地址全部连接失败后调用它丢弃缓存。
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+type invalidator interface {
+	Invalidate(host string)
+}
+
+// DialFunc 与 net.Dialer.DialContext 签名一致，可直接设置为 http.Transport.DialContext。
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Options 描述上游拨号行为。
+type Options struct {
+	// Preference 为地址族偏好，取值见 Preferences，为空等同 auto（按解析结果的顺序，首个地址的地址族优先）。
+	Preference string
+	// FallbackDelay 为首选地址族开始建连后、并行尝试另一地址族前等待的时长，不大于 0 时不并行，按顺序逐个尝试。
+	FallbackDelay time.Duration
+	// Timeout 为一次拨号（含全部地址）的总时限，为 0 时不限制；多个地址按顺序尝试时平分剩余时限。
+	Timeout time.Duration
+	// KeepAlive 为 TCP keep-alive 探测间隔，为 0 时使用系统默认值。
+	KeepAlive time.Duration
+	// Resolver 为主机名解析器，如 dnscache.Resolver，为 nil 时使用 net.DefaultResolver。
+	Resolver Resolver
+}
+
+// Validate 检查拨号设置。
+func (o Options) Validate() error {
+	if o.Preference != "" && !slices.Contains(Preferences, o.Preference) {
+		return fmt.Errorf("%w: preference %q must be one of %v", ErrInvalidOptions, o.Preference, Preferences)
+	}
+	if o.Timeout < 0 {
+		return fmt.Errorf("%w: timeout must not be negative", ErrInvalidOptions)
+	}
+	return nil
+}
+
+type dialer struct {
+	net      *net.Dialer
+	opts     Options
+	resolver Resolver
+}
+
+// New 按 Options 创建拨号函数。未指定解析器且偏好为 auto 时直接使用标准库拨号（自带 Happy Eyeballs）。
+func New(opts Options) (DialFunc, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Preference == "" {
+		opts.Preference = PreferenceAuto
+	}
+	base := &net.Dialer{KeepAlive: opts.KeepAlive, FallbackDelay: opts.FallbackDelay}
+	if opts.FallbackDelay <= 0 {
+		base.FallbackDelay = -1
+	}
+	if opts.Resolver == nil && opts.Preference == PreferenceAuto {
+		base.Timeout = opts.Timeout
+		return base.DialContext, nil
+	}
+	d := &dialer{net: base, opts: opts, resolver: opts.Resolver}
+	if d.resolver == nil {
+		d.resolver = net.DefaultResolver
+	}
+	return d.dial, nil
+}
+
+func (d *dialer) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.opts.Timeout)
+		defer cancel()
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return d.net.DialContext(ctx, network, address)
+	}
+	var addrs []string
+	if net.ParseIP(host) != nil {
+		addrs = []string{host}
+	} else if addrs, err = d.resolver.LookupHost(ctx, host); err != nil {
+		return nil, err
+	}
+	primaries, fallbacks := d.partition(network, addrs)
+	if len(primaries) == 0 {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: &net.AddrError{Err: "no suitable address found", Addr: host}}
+	}
+	conn, err := d.dialParallel(ctx, network, port, primaries, fallbacks)
+	if err != nil {
+		if cache, ok := d.resolver.(invalidator); ok {
+			cache.Invalidate(host)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// partition 按偏好把地址分为首选与后备两组，组内保持解析顺序；限定地址族时后备组为空。
+func (d *dialer) partition(network string, addrs []string) (primaries, fallbacks []string) {
+	var ipv4, ipv6 []string
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		switch {
+		case ip == nil:
+		case ip.To4() != nil:
+			ipv4 = append(ipv4, addr)
+		default:
+			ipv6 = append(ipv6, addr)
+		}
+	}
+	switch network {
+	case "tcp4", "udp4":
+		ipv6 = nil
+	case "tcp6", "udp6":
+		ipv4 = nil
+	}
+	switch d.opts.Preference {
+	case IPv4Only:
+		return ipv4, nil
+	case IPv6Only:
+		return ipv6, nil
+	case PreferIPv4:
+		primaries, fallbacks = ipv4, ipv6
+	case PreferIPv6:
+		primaries, fallbacks = ipv6, ipv4
+	default:
+		primaries, fallbacks = ipv4, ipv6
+		if len(ipv6) > 0 && ipv6[0] == addrs[0] {
+			primaries, fallbacks = ipv6, ipv4
+		}
+	}
+	if len(primaries) == 0 {
+		return fallbacks, nil
+	}
+	return primaries, fallbacks
+}
+
+type dialResult struct {
+	conn    net.Conn
+	err     error
+	primary bool
+}
+
+// dialParallel 先按顺序尝试首选地址，FallbackDelay 后（或首选地址全部失败时）并行尝试后备地址，返回最先建立的连接。
+func (d *dialer) dialParallel(ctx context.Context, network, port string, primaries, fallbacks []string) (net.Conn, error) {
+	if len(fallbacks) == 0 || d.opts.FallbackDelay <= 0 {
+		return d.dialSerial(ctx, network, port, append(primaries, fallbacks...))
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult, 2)
+	start := func(addrs []string, primary bool) {
+		go func() {
+			conn, err := d.dialSerial(ctx, network, port, addrs)
+			results <- dialResult{conn: conn, err: err, primary: primary}
+		}()
+	}
+	start(primaries, true)
+	timer := time.NewTimer(d.opts.FallbackDelay)
+	defer timer.Stop()
+	pending, fallbackStarted := 1, false
+	var primaryErr, fallbackErr error
+	for {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				start(fallbacks, false)
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				// 另一组稍后建立的连接不再需要。
+				go func(remaining int) {
+					for range remaining {
+						if late := <-results; late.conn != nil {
+							_ = late.conn.Close()
+						}
+					}
+				}(pending)
+				return res.conn, nil
+			}
+			if res.primary {
+				primaryErr = res.err
+			} else {
+				fallbackErr = res.err
+			}
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				start(fallbacks, false)
+			}
+			if pending == 0 {
+				if primaryErr != nil {
+					return nil, primaryErr
+				}
+				return nil, fallbackErr
+			}
+		}
+	}
+}
+
+// dialSerial 按顺序逐个尝试地址，ctx 有截止时间时为每个地址平分剩余时限（至少 minAttemptTimeout）。
+func (d *dialer) dialSerial(ctx context.Context, network, port string, addrs []string) (net.Conn, error) {
+	var firstErr error
+	for i, addr := range addrs {
+		if err := ctx.Err(); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			break
+		}
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout := time.Until(deadline) / time.Duration(len(addrs)-i)
+			if timeout < minAttemptTimeout {
+				timeout = minAttemptTimeout
+			}
+			attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		conn, err := d.net.DialContext(attemptCtx, network, net.JoinHostPort(addr, port))
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
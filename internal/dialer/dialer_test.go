@@ -0,0 +1,98 @@
+package dialer
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// staticResolver 依次返回 answers 中的解析结果，最后一个结果重复使用，并记录被丢弃缓存的主机。
+type staticResolver struct {
+	answers     [][]string
+	lookups     atomic.Int32
+	invalidated atomic.Int32
+}
+
+func (r *staticResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	i := int(r.lookups.Add(1)) - 1
+	return r.answers[min(i, len(r.answers)-1)], nil
+}
+
+func (r *staticResolver) Invalidate(host string) {
+	r.invalidated.Add(1)
+}
+
+func upstreamPort(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	target, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	_, port, err := net.SplitHostPort(target.Host)
+	require.NoError(t, err)
+	return port
+}
+
+func TestPartition(t *testing.T) {
+	addrs := []string{"2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2"}
+	for preference, want := range map[string][2][]string{
+		PreferenceAuto: {{"2001:db8::1", "2001:db8::2"}, {"192.0.2.1", "192.0.2.2"}},
+		PreferIPv4:     {{"192.0.2.1", "192.0.2.2"}, {"2001:db8::1", "2001:db8::2"}},
+		PreferIPv6:     {{"2001:db8::1", "2001:db8::2"}, {"192.0.2.1", "192.0.2.2"}},
+		IPv4Only:       {{"192.0.2.1", "192.0.2.2"}, nil},
+		IPv6Only:       {{"2001:db8::1", "2001:db8::2"}, nil},
+	} {
+		d := &dialer{opts: Options{Preference: preference}}
+		primaries, fallbacks := d.partition("tcp", addrs)
+		require.Equal(t, want[0], primaries, preference)
+		require.Equal(t, want[1], fallbacks, preference)
+	}
+	// 首选地址族没有地址时直接使用另一地址族。
+	primaries, fallbacks := (&dialer{opts: Options{Preference: PreferIPv6}}).partition("tcp", []string{"192.0.2.1"})
+	require.Equal(t, []string{"192.0.2.1"}, primaries)
+	require.Empty(t, fallbacks)
+}
+
+func TestDial_FallsBackAfterDelay(t *testing.T) {
+	port := upstreamPort(t)
+	// 首选地址不可路由（模拟 IPv6 线路不通），延迟后并行尝试的后备地址应建连成功。
+	resolver := &staticResolver{answers: [][]string{{"192.0.2.1", "127.0.0.1"}}}
+	d := &dialer{net: &net.Dialer{}, opts: Options{FallbackDelay: 20 * time.Millisecond}, resolver: resolver}
+	start := time.Now()
+	conn, err := d.dialParallel(context.Background(), "tcp", port, []string{"192.0.2.1"}, []string{"127.0.0.1"})
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestDial_InvalidatesAfterFailure(t *testing.T) {
+	port := upstreamPort(t)
+	// 第一次解析到没有监听的地址（模拟服务商轮换 IP），建连失败后丢弃缓存并重新解析。
+	resolver := &staticResolver{answers: [][]string{{"127.0.0.2"}, {"127.0.0.1"}}}
+	dial, err := New(Options{Preference: IPv4Only, Timeout: time.Second, Resolver: resolver})
+	require.NoError(t, err)
+
+	_, err = dial(context.Background(), "tcp", net.JoinHostPort("upstream.test", port))
+	require.Error(t, err)
+	require.Equal(t, int32(1), resolver.invalidated.Load())
+	conn, err := dial(context.Background(), "tcp", net.JoinHostPort("upstream.test", port))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	require.Equal(t, int32(2), resolver.lookups.Load())
+
+	_, err = dial(context.Background(), "tcp", net.JoinHostPort("::1", port))
+	require.Error(t, err)
+}
+
+func TestOptions_Validate(t *testing.T) {
+	require.NoError(t, Options{}.Validate())
+	require.ErrorIs(t, Options{Preference: "ipv5"}.Validate(), ErrInvalidOptions)
+	require.ErrorIs(t, Options{Timeout: -time.Second}.Validate(), ErrInvalidOptions)
+}
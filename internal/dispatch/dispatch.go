@@ -0,0 +1,210 @@
+// Package dispatch 限制同时转发给上游的请求数。名额用尽时请求进入优先级队列排队，
+// 名额释放后先放行优先级高的请求，同一优先级按到达顺序放行；队列已满或等待超时的请求被拒绝。
+// 名额与队列只在本实例内存中计数。
+package dispatch
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull 表示排队请求数已达上限。
+	ErrQueueFull = errors.New("dispatch queue full")
+	// ErrQueueTimeout 表示排队等待超过了最长等待时长。
+	ErrQueueTimeout = errors.New("dispatch queue wait timed out")
+	// ErrInvalidTiers 表示用户等级优先级配置无法解析。
+	ErrInvalidTiers = errors.New("invalid tier priorities")
+)
+
+// Options 控制并发上限、队列深度与最长等待时长。
+type Options struct {
+	// MaxConcurrency 是同时转发的请求数上限，不大于 0 时不限制。
+	MaxConcurrency int
+	// MaxQueue 是排队请求数上限，为 0 时名额用尽的请求直接被拒绝。
+	MaxQueue int
+	// Timeout 是单个请求的最长排队时长，不大于 0 时只受请求上下文约束。
+	Timeout time.Duration
+}
+
+// Stats 是队列的瞬时状态。
+type Stats struct {
+	InFlight int
+	Waiting  int
+}
+
+// Queue 是并发安全的优先级调度队列。
+type Queue struct {
+	opts     Options
+	mu       sync.Mutex
+	inflight int
+	waiters  waiterHeap
+	seq      uint64
+}
+
+type waiter struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+	index    int
+}
+
+// New 创建调度队列，MaxConcurrency 不大于 0 时返回 nil，调用方据此跳过排队。
+func New(opts Options) *Queue {
+	if opts.MaxConcurrency <= 0 {
+		return nil
+	}
+	opts.MaxQueue = max(opts.MaxQueue, 0)
+	return &Queue{opts: opts}
+}
+
+// Acquire 占用一个转发名额，名额用尽时按 priority 排队等待（数值越大越先放行）。
+// 成功时须调用 release 归还名额；队列已满返回 ErrQueueFull，等待超时返回 ErrQueueTimeout，
+// 上下文取消时返回上下文的错误。
+func (q *Queue) Acquire(ctx context.Context, priority int) (release func(), err error) {
+	q.mu.Lock()
+	if q.inflight < q.opts.MaxConcurrency && len(q.waiters) == 0 {
+		q.inflight++
+		q.mu.Unlock()
+		return q.releaseFunc(), nil
+	}
+	if len(q.waiters) >= q.opts.MaxQueue {
+		q.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	q.seq++
+	w := &waiter{priority: priority, seq: q.seq, ready: make(chan struct{})}
+	heap.Push(&q.waiters, w)
+	q.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if q.opts.Timeout > 0 {
+		timer := time.NewTimer(q.opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-w.ready:
+		return q.releaseFunc(), nil
+	case <-timeout:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if w.index < 0 {
+		// 超时与放行同时发生时名额已转交给本请求，直接归还。
+		q.handOff()
+		return nil, err
+	}
+	heap.Remove(&q.waiters, w.index)
+	return nil, err
+}
+
+// Stats 返回当前转发中与排队中的请求数。
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return Stats{InFlight: q.inflight, Waiting: len(q.waiters)}
+}
+
+func (q *Queue) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			q.handOff()
+		})
+	}
+}
+
+// handOff 把归还的名额转交给优先级最高的排队请求，没有排队请求时减少占用数。调用方须持有锁。
+func (q *Queue) handOff() {
+	if len(q.waiters) == 0 {
+		q.inflight--
+		return
+	}
+	w := heap.Pop(&q.waiters).(*waiter)
+	close(w.ready)
+}
+
+// waiterHeap 按优先级从高到低、同优先级按到达顺序排列排队请求。
+type waiterHeap []*waiter
+
+func (h waiterHeap) Len() int { return len(h) }
+
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() any {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*h = old[:len(old)-1]
+	return w
+}
+
+// Tiers 把用户元数据中的 tier 取值映射为排队优先级。
+type Tiers map[string]int
+
+// ParseTiers 解析逗号分隔的 tier=priority 列表，如 enterprise=100,pro=10,free=0。
+func ParseTiers(raw string) (Tiers, error) {
+	tiers := Tiers{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%w: %q: want tier=priority", ErrInvalidTiers, part)
+		}
+		priority, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: priority must be an integer", ErrInvalidTiers, part)
+		}
+		tiers[strings.ToLower(name)] = priority
+	}
+	return tiers, nil
+}
+
+// Priority 返回 tier 的优先级：先按名称（不区分大小写）查表，未配置的整数取值直接作为优先级，其余为 0。
+func (t Tiers) Priority(tier string) int {
+	tier = strings.TrimSpace(tier)
+	if tier == "" {
+		return 0
+	}
+	if priority, ok := t[strings.ToLower(tier)]; ok {
+		return priority
+	}
+	if priority, err := strconv.Atoi(tier); err == nil {
+		return priority
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+package dispatch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue_ReleasesHigherPriorityFirst(t *testing.T) {
+	q := New(Options{MaxConcurrency: 1, MaxQueue: 10, Timeout: time.Second})
+	release, err := q.Acquire(context.Background(), 0)
+	require.NoError(t, err)
+
+	order := make(chan int, 3)
+	for i, priority := range []int{1, 10, 1} {
+		go func() {
+			next, err := q.Acquire(context.Background(), priority)
+			if err != nil {
+				return
+			}
+			order <- priority
+			next()
+		}()
+		// 等待请求进入队列，使同优先级的到达顺序确定。
+		require.Eventually(t, func() bool { return q.Stats().Waiting == i+1 }, time.Second, time.Millisecond)
+	}
+
+	release()
+	require.Equal(t, 10, <-order)
+	require.Equal(t, 1, <-order)
+	require.Equal(t, 1, <-order)
+	require.Eventually(t, func() bool { return q.Stats() == Stats{} }, time.Second, time.Millisecond)
+}
+
+func TestQueue_RejectsWhenFullOrTimedOut(t *testing.T) {
+	q := New(Options{MaxConcurrency: 1, MaxQueue: 1, Timeout: 20 * time.Millisecond})
+	release, err := q.Acquire(context.Background(), 0)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := q.Acquire(context.Background(), 0)
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return q.Stats().Waiting == 1 }, time.Second, time.Millisecond)
+	_, err = q.Acquire(context.Background(), 5)
+	require.ErrorIs(t, err, ErrQueueFull)
+	require.ErrorIs(t, <-done, ErrQueueTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = q.Acquire(ctx, 0)
+	require.ErrorIs(t, err, context.Canceled)
+
+	release()
+	require.Equal(t, Stats{}, q.Stats())
+	require.Nil(t, New(Options{}))
+}
+
+func TestParseTiers(t *testing.T) {
+	tiers, err := ParseTiers("enterprise=100, Pro=10,free=0")
+	require.NoError(t, err)
+	require.Equal(t, 100, tiers.Priority("enterprise"))
+	require.Equal(t, 10, tiers.Priority("pro"))
+	require.Equal(t, 0, tiers.Priority("free"))
+	require.Equal(t, 3, tiers.Priority("3"))
+	require.Equal(t, 0, tiers.Priority("unknown"))
+
+	for _, raw := range []string{"enterprise", "pro=high", "=1"} {
+		_, err := ParseTiers(raw)
+		require.ErrorIs(t, err, ErrInvalidTiers, raw)
+	}
+}
@@ -0,0 +1,90 @@
+// Package dnscache 为上游主机提供带缓存的 DNS 解析：解析结果在 TTL 内复用，避免每次新建连接都查询 DNS；
+// 重新解析失败时继续使用过期的结果。作为 dialer.Options.Resolver 使用时，解析出的地址全部连接失败后
+// 拨号函数调用 Invalidate 丢弃缓存，使下一次建连重新解析，以应对服务商轮换 IP。
+package dnscache
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// LookupFunc 把主机名解析为 IP 地址列表。
+type LookupFunc func(ctx context.Context, host string) ([]string, error)
+
+// Resolver 缓存主机名的解析结果，并发安全。
+type Resolver struct {
+	ttl    time.Duration
+	lookup LookupFunc
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+type entry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// Option 定义 Resolver 可选项。
+type Option func(*Resolver)
+
+// WithLookup 替换底层解析函数，默认使用 net.DefaultResolver。
+func WithLookup(lookup LookupFunc) Option {
+	return func(r *Resolver) {
+		r.lookup = lookup
+	}
+}
+
+// New 创建缓存解析器，解析结果在 ttl 内直接复用。
+func New(ttl time.Duration, opts ...Option) *Resolver {
+	r := &Resolver{
+		ttl:     ttl,
+		lookup:  net.DefaultResolver.LookupHost,
+		now:     time.Now,
+		entries: make(map[string]entry),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// LookupHost 返回 host 的 IP 地址。缓存未过期时直接返回；过期后重新解析，解析失败且存在旧结果时继续使用旧结果。
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	cached, ok := r.entries[host]
+	r.mu.Unlock()
+	if ok && r.now().Before(cached.expires) {
+		metrics.ObserveDNSLookup("hit")
+		return cached.addrs, nil
+	}
+	addrs, err := r.lookup(ctx, host)
+	if err == nil && len(addrs) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	if err != nil {
+		if ok {
+			metrics.ObserveDNSLookup("stale")
+			return cached.addrs, nil
+		}
+		metrics.ObserveDNSLookup("error")
+		return nil, err
+	}
+	metrics.ObserveDNSLookup("miss")
+	r.mu.Lock()
+	r.entries[host] = entry{addrs: addrs, expires: r.now().Add(r.ttl)}
+	r.mu.Unlock()
+	return addrs, nil
+}
+
+// Invalidate 丢弃 host 的缓存，下一次查询重新解析。
+func (r *Resolver) Invalidate(host string) {
+	r.mu.Lock()
+	delete(r.entries, host)
+	r.mu.Unlock()
+}
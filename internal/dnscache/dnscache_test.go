@@ -0,0 +1,44 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver_CachesAndServesStale(t *testing.T) {
+	var lookups atomic.Int32
+	var failing atomic.Bool
+	resolver := New(time.Minute, WithLookup(func(ctx context.Context, host string) ([]string, error) {
+		lookups.Add(1)
+		if failing.Load() {
+			return nil, errors.New("dns down")
+		}
+		return []string{"192.0.2.1"}, nil
+	}))
+	now := time.Now()
+	resolver.now = func() time.Time { return now }
+
+	for range 3 {
+		addrs, err := resolver.LookupHost(context.Background(), "api.example.com")
+		require.NoError(t, err)
+		require.Equal(t, []string{"192.0.2.1"}, addrs)
+	}
+	require.Equal(t, int32(1), lookups.Load())
+
+	// 过期后重新解析，解析失败时沿用旧结果。
+	now = now.Add(2 * time.Minute)
+	failing.Store(true)
+	addrs, err := resolver.LookupHost(context.Background(), "api.example.com")
+	require.NoError(t, err)
+	require.Equal(t, []string{"192.0.2.1"}, addrs)
+	require.Equal(t, int32(2), lookups.Load())
+
+	resolver.Invalidate("api.example.com")
+	_, err = resolver.LookupHost(context.Background(), "api.example.com")
+	require.EqualError(t, err, "dns down")
+}
@@ -0,0 +1,154 @@
+// Package errcode 定义网关统一的错误码（如 YAPI_NO_RULE、YAPI_UPSTREAM_TIMEOUT），
+// 代理与管理端的 JSON 错误响应、访问日志与 gateway_errors_total 指标均使用同一套取值，
+// 便于客户端与告警按错误码而非错误文案做程序化处理。错误码一经发布不再改名。
+package errcode
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// Code 是网关错误码。
+type Code string
+
+// 通用错误码，与 HTTP 状态一一对应，用于没有更具体分类的错误。
+const (
+	InvalidRequest       Code = "YAPI_INVALID_REQUEST"
+	Unauthorized         Code = "YAPI_UNAUTHORIZED"
+	Forbidden            Code = "YAPI_FORBIDDEN"
+	NotFound             Code = "YAPI_NOT_FOUND"
+	Conflict             Code = "YAPI_CONFLICT"
+	PreconditionFailed   Code = "YAPI_PRECONDITION_FAILED"
+	PreconditionRequired Code = "YAPI_PRECONDITION_REQUIRED"
+	RateLimited          Code = "YAPI_RATE_LIMITED"
+	Internal             Code = "YAPI_INTERNAL"
+	NotImplemented       Code = "YAPI_NOT_IMPLEMENTED"
+	ServiceUnavailable   Code = "YAPI_SERVICE_UNAVAILABLE"
+)
+
+// 客户端认证与限额。
+const (
+	InvalidAPIKey       Code = "YAPI_INVALID_API_KEY"
+	APIKeyRequired      Code = "YAPI_API_KEY_REQUIRED"
+	APIKeyDisabled      Code = "YAPI_API_KEY_DISABLED"
+	PermissionDenied    Code = "YAPI_PERMISSION_DENIED"
+	LoginLocked         Code = "YAPI_LOGIN_LOCKED"
+	ConcurrencyLimited  Code = "YAPI_CONCURRENCY_LIMITED"
+	QuotaExceeded       Code = "YAPI_QUOTA_EXCEEDED"
+	BindingUnauthorized Code = "YAPI_BINDING_UNAUTHORIZED"
+	ModelNotAllowed     Code = "YAPI_MODEL_NOT_ALLOWED"
+	IPForbidden         Code = "YAPI_IP_FORBIDDEN"
+	Overloaded          Code = "YAPI_OVERLOADED"
+	HeadersTooLarge     Code = "YAPI_HEADERS_TOO_LARGE"
+	BodyTooLarge        Code = "YAPI_BODY_TOO_LARGE"
+)
+
+// 代理路由与上游。
+const (
+	NoRule                        Code = "YAPI_NO_RULE"
+	RuleMatchFailed               Code = "YAPI_RULE_MATCH_FAILED"
+	NoServiceBinding              Code = "YAPI_NO_SERVICE_BINDING"
+	NoMatchingUpstream            Code = "YAPI_NO_MATCHING_UPSTREAM"
+	TargetNotConfigured           Code = "YAPI_TARGET_NOT_CONFIGURED"
+	UpstreamCredentialDisabled    Code = "YAPI_UPSTREAM_CREDENTIAL_DISABLED"
+	UpstreamCredentialUnavailable Code = "YAPI_UPSTREAM_CREDENTIAL_UNAVAILABLE"
+	UpstreamUnavailable           Code = "YAPI_UPSTREAM_UNAVAILABLE"
+	UpstreamTimeout               Code = "YAPI_UPSTREAM_TIMEOUT"
+	UpstreamContractViolation     Code = "YAPI_UPSTREAM_CONTRACT_VIOLATION"
+	ClientClosed                  Code = "YAPI_CLIENT_CLOSED"
+	ContentRejected               Code = "YAPI_CONTENT_REJECTED"
+	PromptTooLarge                Code = "YAPI_PROMPT_TOO_LARGE"
+	QueueFull                     Code = "YAPI_QUEUE_FULL"
+	QueueTimeout                  Code = "YAPI_QUEUE_TIMEOUT"
+	BudgetExceeded                Code = "YAPI_BUDGET_EXCEEDED"
+)
+
+// contextKey 保存当前请求的错误码，供访问日志读取。
+const contextKey = "yapi_error_code"
+
+// requestIDKey 与 middleware.RequestIDKey 一致（middleware 依赖本包，不能反向引用）。
+const requestIDKey = "request_id"
+
+// ForStatus 返回状态码对应的通用错误码，2xx/3xx 返回空字符串。
+func ForStatus(status int) Code {
+	switch {
+	case status < http.StatusBadRequest:
+		return ""
+	case status == http.StatusUnauthorized:
+		return Unauthorized
+	case status == http.StatusForbidden:
+		return Forbidden
+	case status == http.StatusNotFound:
+		return NotFound
+	case status == http.StatusConflict:
+		return Conflict
+	case status == http.StatusPreconditionFailed:
+		return PreconditionFailed
+	case status == http.StatusPreconditionRequired:
+		return PreconditionRequired
+	case status == http.StatusTooManyRequests:
+		return RateLimited
+	case status == 499:
+		return ClientClosed
+	case status == http.StatusNotImplemented:
+		return NotImplemented
+	case status == http.StatusBadGateway:
+		return UpstreamUnavailable
+	case status == http.StatusServiceUnavailable:
+		return ServiceUnavailable
+	case status == http.StatusGatewayTimeout:
+		return UpstreamTimeout
+	case status < http.StatusInternalServerError:
+		return InvalidRequest
+	default:
+		return Internal
+	}
+}
+
+// Body 返回错误响应体：error 为可读描述（与既有响应兼容），code 为错误码。
+func Body(code Code, message string) gin.H {
+	return gin.H{"error": message, "code": code}
+}
+
+// ContextBody 在 Body 的基础上附加当前请求的 request_id，便于客户端反馈问题时与日志、请求轨迹对应。
+func ContextBody(c *gin.Context, code Code, message string) gin.H {
+	body := Body(code, message)
+	if id := c.GetString(requestIDKey); id != "" {
+		body["request_id"] = id
+	}
+	return body
+}
+
+// Set 记录当前请求的错误码并累加指标，适用于不经 Respond 写出的错误响应。
+func Set(c *gin.Context, code Code) {
+	if code == "" {
+		return
+	}
+	c.Set(contextKey, code)
+	metrics.ObserveError(string(code))
+}
+
+// FromContext 返回当前请求记录的错误码。
+func FromContext(c *gin.Context) (Code, bool) {
+	value, ok := c.Get(contextKey)
+	if !ok {
+		return "", false
+	}
+	code, ok := value.(Code)
+	return code, ok
+}
+
+// Respond 写出带错误码的 JSON 错误响应。
+func Respond(c *gin.Context, status int, code Code, message string) {
+	Set(c, code)
+	c.JSON(status, ContextBody(c, code, message))
+}
+
+// Abort 写出带错误码的 JSON 错误响应并中止后续处理器。
+func Abort(c *gin.Context, status int, code Code, message string) {
+	Set(c, code)
+	c.AbortWithStatusJSON(status, ContextBody(c, code, message))
+}
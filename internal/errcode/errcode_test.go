@@ -0,0 +1,79 @@
+package errcode
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+func TestForStatus_MapsStatusToGenericCode(t *testing.T) {
+	cases := map[int]Code{
+		http.StatusOK:                   "",
+		http.StatusBadRequest:           InvalidRequest,
+		http.StatusUnauthorized:         Unauthorized,
+		http.StatusForbidden:            Forbidden,
+		http.StatusNotFound:             NotFound,
+		http.StatusConflict:             Conflict,
+		http.StatusPreconditionFailed:   PreconditionFailed,
+		http.StatusPreconditionRequired: PreconditionRequired,
+		http.StatusUnprocessableEntity:  InvalidRequest,
+		http.StatusTooManyRequests:      RateLimited,
+		499:                             ClientClosed,
+		http.StatusInternalServerError:  Internal,
+		http.StatusNotImplemented:       NotImplemented,
+		http.StatusBadGateway:           UpstreamUnavailable,
+		http.StatusServiceUnavailable:   ServiceUnavailable,
+		http.StatusGatewayTimeout:       UpstreamTimeout,
+	}
+	for status, want := range cases {
+		require.Equal(t, want, ForStatus(status), "status %d", status)
+	}
+}
+
+func TestAbort_WritesBodyAndRecordsCode(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	var recorded Code
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Next()
+		recorded, _ = FromContext(c)
+	})
+	router.GET("/limited", func(c *gin.Context) {
+		Abort(c, http.StatusTooManyRequests, RateLimited, "rate limit exceeded")
+	})
+	counter := metrics.ErrorsTotal.WithLabelValues(string(RateLimited))
+	before := testutil.ToFloat64(counter)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/limited", nil))
+
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, map[string]string{"error": "rate limit exceeded", "code": "YAPI_RATE_LIMITED"}, body)
+	require.Equal(t, RateLimited, recorded)
+	require.Equal(t, 1.0, testutil.ToFloat64(counter)-before)
+}
+
+func TestRespond_IncludesRequestID(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set(requestIDKey, "req-1") })
+	router.GET("/missing", func(c *gin.Context) {
+		Respond(c, http.StatusNotFound, NoRule, "no matching rule")
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, map[string]string{"error": "no matching rule", "code": "YAPI_NO_RULE", "request_id": "req-1"}, body)
+}
@@ -0,0 +1,130 @@
+// Package features 提供运行时可切换的功能开关，供运维在不重启网关的情况下启停实验性子系统。
+// 开关状态只保存在本实例内存中，重启后恢复为注册时的默认值或 FEATURE_FLAGS 指定的值。
+package features
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// RequestTraces 控制代理是否记录请求决策轨迹（GET /admin/requests/:request_id）。
+const RequestTraces = "request_traces"
+
+// BodyLogging 控制代理是否记录脱敏后的请求/响应体日志。
+const BodyLogging = "body_logging"
+
+// ErrUnknownFlag 表示开关未注册。
+var ErrUnknownFlag = errors.New("unknown feature flag")
+
+// Flag 是开关的当前状态。
+type Flag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Default     bool   `json:"default"`
+}
+
+// Toggle 是单个开关的句柄，热路径上直接调用 Enabled 读取原子状态。
+type Toggle struct {
+	name        string
+	description string
+	fallback    bool
+	enabled     atomic.Bool
+}
+
+// Enabled 返回开关是否开启，nil 句柄视为开启，未接入开关的组件保持原有行为。
+func (t *Toggle) Enabled() bool {
+	return t == nil || t.enabled.Load()
+}
+
+func (t *Toggle) snapshot() Flag {
+	return Flag{Name: t.name, Description: t.description, Enabled: t.enabled.Load(), Default: t.fallback}
+}
+
+// Registry 持有全部已注册的开关，可并发使用。
+type Registry struct {
+	mu    sync.RWMutex
+	flags map[string]*Toggle
+}
+
+// NewRegistry 创建空的开关注册表。
+func NewRegistry() *Registry {
+	return &Registry{flags: make(map[string]*Toggle)}
+}
+
+// Register 注册开关并返回其句柄；重复注册同名开关返回已有句柄。
+func (r *Registry) Register(name, description string, enabled bool) *Toggle {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if toggle, ok := r.flags[name]; ok {
+		return toggle
+	}
+	toggle := &Toggle{name: name, description: description, fallback: enabled}
+	toggle.enabled.Store(enabled)
+	r.flags[name] = toggle
+	return toggle
+}
+
+// Set 修改开关状态并返回修改后的状态。
+func (r *Registry) Set(name string, enabled bool) (Flag, error) {
+	r.mu.RLock()
+	toggle, ok := r.flags[name]
+	r.mu.RUnlock()
+	if !ok {
+		return Flag{}, fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+	}
+	toggle.enabled.Store(enabled)
+	return toggle.snapshot(), nil
+}
+
+// Get 返回开关的当前状态。
+func (r *Registry) Get(name string) (Flag, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	toggle, ok := r.flags[name]
+	if !ok {
+		return Flag{}, fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+	}
+	return toggle.snapshot(), nil
+}
+
+// List 按名称返回全部开关的当前状态。
+func (r *Registry) List() []Flag {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	flags := make([]Flag, 0, len(r.flags))
+	for _, toggle := range r.flags {
+		flags = append(flags, toggle.snapshot())
+	}
+	slices.SortFunc(flags, func(a, b Flag) int { return strings.Compare(a.Name, b.Name) })
+	return flags
+}
+
+// Apply 按 "name=true,other=false" 形式的配置设置开关，仅写名称等价于 name=true。
+// 遇到未注册的开关或无法解析的取值时返回错误，此前的项已生效。
+func (r *Registry) Apply(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, raw, hasValue := strings.Cut(item, "=")
+		enabled := true
+		if hasValue {
+			parsed, err := strconv.ParseBool(strings.TrimSpace(raw))
+			if err != nil {
+				return fmt.Errorf("feature flag %s: invalid value %q", name, raw)
+			}
+			enabled = parsed
+		}
+		if _, err := r.Set(strings.TrimSpace(name), enabled); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package features
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_ToggleAndApply(t *testing.T) {
+	registry := NewRegistry()
+	traces := registry.Register(RequestTraces, "record request traces", true)
+	cache := registry.Register("response_cache", "cache responses", false)
+	require.Same(t, traces, registry.Register(RequestTraces, "ignored", false))
+	require.True(t, traces.Enabled())
+	require.False(t, cache.Enabled())
+
+	flag, err := registry.Set("response_cache", true)
+	require.NoError(t, err)
+	require.Equal(t, Flag{Name: "response_cache", Description: "cache responses", Enabled: true}, flag)
+	require.True(t, cache.Enabled())
+
+	_, err = registry.Set("missing", true)
+	require.ErrorIs(t, err, ErrUnknownFlag)
+	_, err = registry.Get("missing")
+	require.ErrorIs(t, err, ErrUnknownFlag)
+
+	require.NoError(t, registry.Apply(" request_traces=false , response_cache=0"))
+	require.False(t, traces.Enabled())
+	require.False(t, cache.Enabled())
+	require.NoError(t, registry.Apply("response_cache"))
+	require.True(t, cache.Enabled())
+	require.Error(t, registry.Apply("response_cache=maybe"))
+	require.ErrorIs(t, registry.Apply("missing=true"), ErrUnknownFlag)
+
+	flags := registry.List()
+	require.Len(t, flags, 2)
+	require.Equal(t, "request_traces", flags[0].Name)
+	require.True(t, flags[0].Default)
+	require.False(t, flags[0].Enabled)
+
+	var nilToggle *Toggle
+	require.True(t, nilToggle.Enabled())
+}
+
+func TestRegistry_ConcurrentAccess(t *testing.T) {
+	registry := NewRegistry()
+	toggle := registry.Register("flag", "", false)
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = registry.Set("flag", i%2 == 0)
+			_ = toggle.Enabled()
+			_ = registry.List()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,141 @@
+// Package health 提供存活（/livez）与就绪（/readyz）探针，就绪检查逐项探测网关依赖。
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 探针与单项检查的状态。
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+)
+
+// ErrDegraded 由检查包装返回，表示依赖不可用但网关仍以降级模式提供服务（如数据库中断时使用 Redis 中缓存的规则）：
+// 该项与整体状态为 degraded，/readyz 仍返回 200，避免实例被摘除流量。
+var ErrDegraded = errors.New("degraded")
+
+// defaultTimeout 是单次就绪检查的默认超时，需小于 Kubernetes 探针的 timeoutSeconds。
+const defaultTimeout = 2 * time.Second
+
+// Check 探测一项依赖，返回 nil 表示可用。
+type Check func(ctx context.Context) error
+
+// Result 是单项检查的结果。
+type Result struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+// Report 汇总全部检查，任一检查失败时 Status 为 unavailable；否则任一检查降级时为 degraded。
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker 持有就绪检查列表。
+type Checker struct {
+	checks  []namedCheck
+	timeout time.Duration
+}
+
+// Option 配置 Checker。
+type Option func(*Checker)
+
+// WithCheck 注册一项名为 name 的就绪检查。
+func WithCheck(name string, check Check) Option {
+	return func(c *Checker) {
+		if check != nil {
+			c.checks = append(c.checks, namedCheck{name: name, check: check})
+		}
+	}
+}
+
+// WithTimeout 设置所有检查共享的超时时间。
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Checker) {
+		if timeout > 0 {
+			c.timeout = timeout
+		}
+	}
+}
+
+// NewChecker 创建 Checker。
+func NewChecker(opts ...Option) *Checker {
+	c := &Checker{timeout: defaultTimeout}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Run 并发执行全部检查并汇总结果。
+func (c *Checker) Run(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, item := range c.checks {
+		wg.Add(1)
+		go func(item namedCheck) {
+			defer wg.Done()
+			start := time.Now()
+			err := item.check(ctx)
+			result := Result{Status: StatusOK, LatencyMS: time.Since(start).Milliseconds()}
+			switch {
+			case errors.Is(err, ErrDegraded):
+				result.Status = StatusDegraded
+				result.Error = err.Error()
+			case err != nil:
+				result.Status = StatusUnavailable
+				result.Error = err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[item.name] = result
+			if result.Status == StatusUnavailable || (result.Status == StatusDegraded && report.Status == StatusOK) {
+				report.Status = result.Status
+			}
+		}(item)
+	}
+	wg.Wait()
+	return report
+}
+
+// RegisterRoutes 注册 GET /livez 与 GET /readyz。
+func RegisterRoutes(routes gin.IRoutes, checker *Checker) {
+	routes.GET("/livez", checker.livez)
+	routes.GET("/readyz", checker.readyz)
+}
+
+// livez 只要进程能处理请求即返回 200，不探测依赖，避免依赖故障导致实例被反复重启。
+func (c *Checker) livez(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": StatusOK})
+}
+
+// readyz 在任一依赖不可用时返回 503，并附带各项检查的详情；降级时仍返回 200。
+func (c *Checker) readyz(ctx *gin.Context) {
+	report := c.Run(ctx.Request.Context())
+	status := http.StatusOK
+	if report.Status == StatusUnavailable {
+		status = http.StatusServiceUnavailable
+	}
+	ctx.JSON(status, report)
+}
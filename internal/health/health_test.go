@@ -0,0 +1,78 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouter(checker *Checker) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterRoutes(router, checker)
+	return router
+}
+
+func TestChecker_ReadyzReportsFailingDependency(t *testing.T) {
+	router := newTestRouter(NewChecker(
+		WithCheck("database", func(ctx context.Context) error { return nil }),
+		WithCheck("redis", func(ctx context.Context) error { return errors.New("connection refused") }),
+	))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var report Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	require.Equal(t, StatusUnavailable, report.Status)
+	require.Equal(t, StatusOK, report.Checks["database"].Status)
+	require.Equal(t, StatusUnavailable, report.Checks["redis"].Status)
+	require.Equal(t, "connection refused", report.Checks["redis"].Error)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestChecker_ReadyzHealthyAndTimeout(t *testing.T) {
+	router := newTestRouter(NewChecker(WithCheck("rules", func(ctx context.Context) error { return nil })))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	slow := NewChecker(WithTimeout(10*time.Millisecond), WithCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	report := slow.Run(context.Background())
+	require.Equal(t, StatusUnavailable, report.Status)
+	require.Contains(t, report.Checks["slow"].Error, "deadline exceeded")
+}
+
+func TestChecker_ReadyzDegradedStaysReady(t *testing.T) {
+	degraded := WithCheck("database", func(ctx context.Context) error {
+		return fmt.Errorf("%w: serving cached rules: connection refused", ErrDegraded)
+	})
+	router := newTestRouter(NewChecker(degraded, WithCheck("rules", func(ctx context.Context) error { return nil })))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var report Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	require.Equal(t, StatusDegraded, report.Status)
+	require.Equal(t, StatusDegraded, report.Checks["database"].Status)
+	require.Contains(t, report.Checks["database"].Error, "connection refused")
+
+	failing := NewChecker(degraded, WithCheck("rules", func(ctx context.Context) error { return errors.New("rules not loaded") }))
+	require.Equal(t, StatusUnavailable, failing.Run(context.Background()).Status)
+}
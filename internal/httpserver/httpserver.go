@@ -0,0 +1,117 @@
+// Package httpserver 封装网关与管理端共用的 http.Server：可配置的读写与空闲超时、请求头大小上限，
+// 以及分阶段的优雅关闭——先停止接受新连接并等待进行中的请求（含流式响应）在宽限期内完成，
+// 宽限期结束后取消仍在进行的请求并关闭连接，使进程在可预期的时间内退出。
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Options 是服务端超时设置，零值表示不限制（ReadHeaderTimeout 与 MaxHeaderBytes 除外，见字段说明）。
+type Options struct {
+	// ReadTimeout 为读取整个请求（含请求体）的时限。
+	ReadTimeout time.Duration
+	// ReadHeaderTimeout 为读取请求头的时限，默认 5 秒。
+	ReadHeaderTimeout time.Duration
+	// WriteTimeout 为从读完请求头到写完响应的时限。流式响应由处理器自行解除该时限，见 proxy 包。
+	WriteTimeout time.Duration
+	// IdleTimeout 为 keep-alive 连接的空闲时限，为零时沿用 ReadTimeout。
+	IdleTimeout time.Duration
+	// MaxHeaderBytes 为请求头大小上限，默认 1 MiB。
+	MaxHeaderBytes int
+	// ShutdownGracePeriod 为关闭时等待进行中请求完成的时长，默认 10 秒。
+	ShutdownGracePeriod time.Duration
+	Logger              *slog.Logger
+}
+
+// Server 是带优雅关闭的 HTTP 服务端。
+type Server struct {
+	name   string
+	server *http.Server
+	grace  time.Duration
+	logger *slog.Logger
+	// cancel 取消全部请求的 context，宽限期结束后调用。
+	cancel context.CancelFunc
+}
+
+// New 创建监听 addr 的服务端，name 用于日志（如 gateway、admin api）。tlsConfig 非空时以 HTTPS 提供服务。
+func New(name, addr string, handler http.Handler, tlsConfig *tls.Config, opts Options) *Server {
+	if opts.ReadHeaderTimeout <= 0 {
+		opts.ReadHeaderTimeout = 5 * time.Second
+	}
+	if opts.MaxHeaderBytes <= 0 {
+		opts.MaxHeaderBytes = http.DefaultMaxHeaderBytes
+	}
+	if opts.ShutdownGracePeriod <= 0 {
+		opts.ShutdownGracePeriod = 10 * time.Second
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	base, cancel := context.WithCancel(context.Background())
+	return &Server{
+		name: name,
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			TLSConfig:         tlsConfig,
+			ReadTimeout:       opts.ReadTimeout,
+			ReadHeaderTimeout: opts.ReadHeaderTimeout,
+			WriteTimeout:      opts.WriteTimeout,
+			IdleTimeout:       opts.IdleTimeout,
+			MaxHeaderBytes:    opts.MaxHeaderBytes,
+			BaseContext:       func(net.Listener) context.Context { return base },
+		},
+		grace:  opts.ShutdownGracePeriod,
+		logger: opts.Logger,
+		cancel: cancel,
+	}
+}
+
+// Run 开始监听并阻塞到服务端完全关闭：ctx 结束后停止接受新连接，等待进行中的请求最多 ShutdownGracePeriod，
+// 仍未完成的请求（通常是长时间的流式响应）随后被取消。监听失败时立即返回错误，正常关闭时返回 nil。
+func (s *Server) Run(ctx context.Context) error {
+	listen := make(chan error, 1)
+	go func() {
+		if s.server.TLSConfig != nil {
+			s.logger.Info(s.name+" listening", "addr", s.server.Addr, "tls", true)
+			listen <- s.server.ListenAndServeTLS("", "")
+			return
+		}
+		s.logger.Info(s.name+" listening", "addr", s.server.Addr, "tls", false)
+		listen <- s.server.ListenAndServe()
+	}()
+	select {
+	case err := <-listen:
+		s.cancel()
+		return err
+	case <-ctx.Done():
+	}
+	err := s.Shutdown()
+	if listenErr := <-listen; !errors.Is(listenErr, http.ErrServerClosed) {
+		return listenErr
+	}
+	return err
+}
+
+// Shutdown 停止接受新连接并等待进行中的请求完成，超过宽限期后取消剩余请求并强制关闭连接。
+func (s *Server) Shutdown() error {
+	defer s.cancel()
+	s.logger.Info(s.name+" shutting down", "grace_period", s.grace)
+	ctx, cancel := context.WithTimeout(context.Background(), s.grace)
+	defer cancel()
+	err := s.server.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	s.logger.Warn(s.name+" shutdown grace period elapsed, aborting in-flight requests", "grace_period", s.grace)
+	// 先取消请求 context，使代理中止上游调用并让处理器返回，再关闭仍打开的连接。
+	s.cancel()
+	return s.server.Close()
+}
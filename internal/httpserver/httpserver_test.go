@@ -0,0 +1,111 @@
+package httpserver
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// freeAddr 返回一个当前空闲的本地监听地址。
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	return addr
+}
+
+func startServer(t *testing.T, handler http.Handler, grace time.Duration) (addr string, cancel context.CancelFunc, done <-chan error) {
+	t.Helper()
+	addr = freeAddr(t)
+	server := New("test", addr, handler, nil, Options{ShutdownGracePeriod: grace, Logger: slog.New(slog.DiscardHandler)})
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- server.Run(ctx) }()
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			_ = conn.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	return addr, cancel, result
+}
+
+func TestServer_ShutdownWaitsForInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	addr, cancel, done := startServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		_, _ = io.WriteString(w, "finished")
+	}), 5*time.Second)
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr)
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		body <- string(data)
+	}()
+	<-started
+	cancel()
+
+	require.NoError(t, <-done)
+	require.Equal(t, "finished", <-body)
+}
+
+func TestServer_ShutdownAbortsRequestsAfterGracePeriod(t *testing.T) {
+	started := make(chan struct{})
+	aborted := make(chan struct{})
+	addr, cancel, done := startServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		close(started)
+		<-r.Context().Done()
+		close(aborted)
+	}), 100*time.Millisecond)
+
+	go func() {
+		resp, err := http.Get("http://" + addr)
+		if err == nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+	}()
+	<-started
+	begin := time.Now()
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after the grace period")
+	}
+	require.Less(t, time.Since(begin), time.Second)
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatal("in-flight request context was not cancelled")
+	}
+}
+
+func TestServer_RunReturnsListenError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	server := New("test", listener.Addr().String(), http.NotFoundHandler(), nil, Options{Logger: slog.New(slog.DiscardHandler)})
+	require.Error(t, server.Run(context.Background()))
+}
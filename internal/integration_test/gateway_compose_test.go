@@ -394,6 +394,7 @@ func deleteRule(t *testing.T, client *http.Client, baseURL, token, ruleID string
 	req, err := http.NewRequest(http.MethodDelete, baseURL+"/admin/rules/"+ruleID, nil)
 	require.NoError(t, err)
 	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", "*")
 	resp, err := client.Do(req)
 	require.NoError(t, err)
 	defer resp.Body.Close()
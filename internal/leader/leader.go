@@ -0,0 +1,146 @@
+// Package leader 在多个网关实例之间选举领导者，使作用于共享数据的周期任务（如上游健康检查、
+// 清理过期归档）只在一个实例上执行。领导权以租约形式保存在 Redis 或 Postgres advisory lock 中，
+// 持有者异常退出后由其他实例接管。
+package leader
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// DefaultLeaseTTL 是未指定时领导权租约的有效期，租约每隔三分之一有效期续期一次。
+const DefaultLeaseTTL = 15 * time.Second
+
+// Backend 保存领导权。
+type Backend interface {
+	// Campaign 尝试取得或续期领导权，返回本实例此刻是否为领导者。
+	Campaign(ctx context.Context) (bool, error)
+	// Resign 释放本实例持有的领导权，未持有时不做任何事。
+	Resign(ctx context.Context) error
+}
+
+// Elector 周期性地竞选领导权，并只在本实例为领导者时执行注册的周期任务。
+// 未配置 Backend 时视为单实例部署，本实例始终是领导者。
+type Elector struct {
+	backend  Backend
+	leaseTTL time.Duration
+	logger   *slog.Logger
+	leader   atomic.Bool
+}
+
+// Option 配置 Elector。
+type Option func(*Elector)
+
+// WithLeaseTTL 设置租约有效期，需与创建 Backend 时使用的有效期一致。
+func WithLeaseTTL(ttl time.Duration) Option {
+	return func(e *Elector) {
+		if ttl > 0 {
+			e.leaseTTL = ttl
+		}
+	}
+}
+
+// WithLogger 设置日志记录器。
+func WithLogger(logger *slog.Logger) Option {
+	return func(e *Elector) {
+		if logger != nil {
+			e.logger = logger
+		}
+	}
+}
+
+// New 创建 Elector，backend 为 nil 时本实例始终是领导者。
+func New(backend Backend, opts ...Option) *Elector {
+	e := &Elector{backend: backend, leaseTTL: DefaultLeaseTTL, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(e)
+	}
+	e.leader.Store(backend == nil)
+	return e
+}
+
+// IsLeader 报告本实例当前是否为领导者。
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Start 立即竞选一次，之后在后台每隔三分之一租约有效期续期，ctx 结束时释放领导权。
+func (e *Elector) Start(ctx context.Context) {
+	if e.backend == nil {
+		metrics.ObserveLeader(true)
+		return
+	}
+	e.campaign(ctx)
+	go func() {
+		ticker := time.NewTicker(e.leaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				resignCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				if err := e.backend.Resign(resignCtx); err != nil {
+					e.logger.Warn("leader resign failed", "error", err)
+				}
+				e.leader.Store(false)
+				metrics.ObserveLeader(false)
+				return
+			case <-ticker.C:
+				e.campaign(ctx)
+			}
+		}
+	}()
+}
+
+// campaign 竞选一次；出错时放弃领导权，宁可本轮没有实例执行任务，也不让多个实例同时执行。
+func (e *Elector) campaign(ctx context.Context) {
+	ok, err := e.backend.Campaign(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		e.logger.Warn("leader campaign failed", "error", err)
+	}
+	if was := e.leader.Swap(ok); was != ok {
+		e.logger.Info("leadership changed", "leader", ok)
+	}
+	metrics.ObserveLeader(ok)
+}
+
+// Every 在后台每隔 interval 执行一次名为 name 的任务，仅当本实例为领导者时执行，直到 ctx 结束；
+// interval 不大于 0 时不启动。
+func (e *Elector) Every(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.run(ctx, name, job)
+			}
+		}
+	}()
+}
+
+// run 执行一次任务并记录结果。
+func (e *Elector) run(ctx context.Context, name string, job func(ctx context.Context) error) {
+	if !e.IsLeader() {
+		metrics.ObserveBackgroundJob(name, "skipped")
+		return
+	}
+	if err := job(ctx); err != nil {
+		if !errors.Is(err, context.Canceled) {
+			e.logger.Error("background job failed", "job", name, "error", err)
+		}
+		metrics.ObserveBackgroundJob(name, "error")
+		return
+	}
+	metrics.ObserveBackgroundJob(name, "success")
+}
@@ -0,0 +1,92 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// sharedLease 模拟多个实例共享的租约存储。
+type sharedLease struct {
+	mu     sync.Mutex
+	holder string
+	err    error
+}
+
+type fakeBackend struct {
+	lease *sharedLease
+	id    string
+}
+
+func (b fakeBackend) Campaign(ctx context.Context) (bool, error) {
+	b.lease.mu.Lock()
+	defer b.lease.mu.Unlock()
+	if b.lease.err != nil {
+		return false, b.lease.err
+	}
+	if b.lease.holder == "" {
+		b.lease.holder = b.id
+	}
+	return b.lease.holder == b.id, nil
+}
+
+func (b fakeBackend) Resign(ctx context.Context) error {
+	b.lease.mu.Lock()
+	defer b.lease.mu.Unlock()
+	if b.lease.holder == b.id {
+		b.lease.holder = ""
+	}
+	return nil
+}
+
+func TestElector_OnlyLeaderRunsJobs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lease := &sharedLease{}
+	first := New(fakeBackend{lease: lease, id: "a"}, WithLeaseTTL(30*time.Millisecond))
+	second := New(fakeBackend{lease: lease, id: "b"}, WithLeaseTTL(30*time.Millisecond))
+	firstCtx, stopFirst := context.WithCancel(ctx)
+	first.Start(firstCtx)
+	second.Start(ctx)
+	require.True(t, first.IsLeader())
+	require.False(t, second.IsLeader())
+
+	var firstRuns, secondRuns atomic.Int32
+	job := func(counter *atomic.Int32) func(context.Context) error {
+		return func(context.Context) error {
+			counter.Add(1)
+			return nil
+		}
+	}
+	first.run(ctx, "job", job(&firstRuns))
+	second.run(ctx, "job", job(&secondRuns))
+	require.Equal(t, int32(1), firstRuns.Load())
+	require.Equal(t, int32(0), secondRuns.Load())
+
+	// 领导者退出时释放租约，由另一实例接管。
+	stopFirst()
+	require.Eventually(t, second.IsLeader, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool { return !first.IsLeader() }, time.Second, 5*time.Millisecond)
+}
+
+func TestElector_CampaignErrorDropsLeadership(t *testing.T) {
+	lease := &sharedLease{}
+	elector := New(fakeBackend{lease: lease, id: "a"})
+	elector.campaign(context.Background())
+	require.True(t, elector.IsLeader())
+
+	lease.err = errors.New("redis unavailable")
+	elector.campaign(context.Background())
+	require.False(t, elector.IsLeader())
+}
+
+func TestElector_WithoutBackendAlwaysLeads(t *testing.T) {
+	elector := New(nil)
+	elector.Start(context.Background())
+	require.True(t, elector.IsLeader())
+}
@@ -0,0 +1,67 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"sync"
+)
+
+// PostgresBackend 以会话级 advisory lock 作为领导权：持有者在一条专用连接上保持锁，
+// 连接断开（包括实例异常退出）时锁由数据库自动释放，其他实例在下一次竞选时取得。
+type PostgresBackend struct {
+	db  *sql.DB
+	key int64
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+// NewPostgresBackend 创建基于 advisory lock 的领导权，name 经哈希后作为锁的键，为空时使用 "yapi:leader"。
+func NewPostgresBackend(db *sql.DB, name string) *PostgresBackend {
+	if name == "" {
+		name = "yapi:leader"
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return &PostgresBackend{db: db, key: int64(h.Sum64())}
+}
+
+func (b *PostgresBackend) Campaign(ctx context.Context) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn != nil {
+		if err := b.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		// 连接已断开，锁随之释放，重新竞选。
+		_ = b.conn.Close()
+		b.conn = nil
+	}
+	conn, err := b.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", b.key).Scan(&locked); err != nil {
+		_ = conn.Close()
+		return false, err
+	}
+	if !locked {
+		return false, conn.Close()
+	}
+	b.conn = conn
+	return true, nil
+}
+
+func (b *PostgresBackend) Resign(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn == nil {
+		return nil
+	}
+	_, err := b.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", b.key)
+	_ = b.conn.Close()
+	b.conn = nil
+	return err
+}
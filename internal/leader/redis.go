@@ -0,0 +1,63 @@
+package leader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// campaignScript 在 key 由本实例持有时续期，空闲时以 NX 取得，返回 1 表示本实例持有领导权。
+var campaignScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+  redis.call("PEXPIRE", KEYS[1], ARGV[2])
+  return 1
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+  return 1
+end
+return 0
+`)
+
+// resignScript 只删除本实例持有的 key，避免误删其他实例在租约过期后取得的领导权。
+var resignScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+  return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisBackend 以带过期时间的 Redis key 作为领导权租约，值为实例的随机标识。
+type RedisBackend struct {
+	client redis.UniversalClient
+	key    string
+	token  string
+	ttl    time.Duration
+}
+
+// NewRedisBackend 创建基于 Redis 的租约，key 为空时使用 "yapi:leader"，ttl 不大于 0 时使用 DefaultLeaseTTL。
+func NewRedisBackend(client redis.UniversalClient, key string, ttl time.Duration) *RedisBackend {
+	if key == "" {
+		key = "yapi:leader"
+	}
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return &RedisBackend{client: client, key: key, token: hex.EncodeToString(buf), ttl: ttl}
+}
+
+func (b *RedisBackend) Campaign(ctx context.Context) (bool, error) {
+	held, err := campaignScript.Run(ctx, b.client, []string{b.key}, b.token, b.ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return held == 1, nil
+}
+
+func (b *RedisBackend) Resign(ctx context.Context) error {
+	return resignScript.Run(ctx, b.client, []string{b.key}, b.token).Err()
+}
@@ -0,0 +1,85 @@
+// Package metricsserver 注册 Prometheus 指标（/metrics）与运行时剖析（/debug/pprof）端点。
+// 端点可挂在独立的内部监听地址上，也可要求 Bearer Token，避免在公开的代理端口上暴露内部数据。
+package metricsserver
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/prehisle/yapi/internal/errcode"
+)
+
+type settings struct {
+	token string
+	pprof bool
+}
+
+// Option 配置端点。
+type Option func(*settings)
+
+// WithBearerToken 要求请求携带 `Authorization: Bearer <token>`，token 为空时不校验。
+func WithBearerToken(token string) Option {
+	return func(s *settings) {
+		s.token = token
+	}
+}
+
+// WithPprof 启用 /debug/pprof 剖析端点。
+func WithPprof(enabled bool) Option {
+	return func(s *settings) {
+		s.pprof = enabled
+	}
+}
+
+// RegisterRoutes 注册 GET /metrics，启用剖析时另注册 /debug/pprof/*。
+func RegisterRoutes(routes gin.IRouter, opts ...Option) {
+	var s settings
+	for _, opt := range opts {
+		opt(&s)
+	}
+	var handlers []gin.HandlerFunc
+	if s.token != "" {
+		handlers = append(handlers, bearerAuth(s.token))
+	}
+	routes.GET("/metrics", append(handlers, gin.WrapH(promhttp.Handler()))...)
+	if !s.pprof {
+		return
+	}
+	debug := routes.Group("/debug/pprof", handlers...)
+	debug.GET("/*name", profile)
+	debug.POST("/*name", profile)
+}
+
+// profile 按路径分派到 net/http/pprof；Index 会根据路径输出具名剖析（heap、goroutine 等）。
+func profile(c *gin.Context) {
+	switch strings.TrimPrefix(c.Param("name"), "/") {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Index(c.Writer, c.Request)
+	}
+}
+
+func bearerAuth(token string) gin.HandlerFunc {
+	expected := []byte(token)
+	return func(c *gin.Context) {
+		provided, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(provided)), expected) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+			errcode.Abort(c, http.StatusUnauthorized, errcode.Unauthorized, "unauthorized")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,58 @@
+package metricsserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(router *gin.Engine, path, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRegisterRoutes_RequiresBearerToken(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, WithBearerToken("scrape-secret"), WithPprof(true))
+
+	for _, path := range []string{"/metrics", "/debug/pprof/"} {
+		rec := serve(router, path, "")
+		require.Equal(t, http.StatusUnauthorized, rec.Code, path)
+		require.Equal(t, `Bearer realm="metrics"`, rec.Header().Get("WWW-Authenticate"))
+		require.Contains(t, rec.Body.String(), "YAPI_UNAUTHORIZED")
+
+		require.Equal(t, http.StatusUnauthorized, serve(router, path, "Bearer wrong").Code, path)
+		require.Equal(t, http.StatusOK, serve(router, path, "Bearer scrape-secret").Code, path)
+	}
+	rec := serve(router, "/metrics", "Bearer scrape-secret")
+	require.Contains(t, rec.Body.String(), "go_goroutines")
+}
+
+func TestRegisterRoutes_PprofDisabledByDefault(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router)
+
+	require.Equal(t, http.StatusOK, serve(router, "/metrics", "").Code)
+	require.Equal(t, http.StatusNotFound, serve(router, "/debug/pprof/", "").Code)
+}
+
+func TestRegisterRoutes_ServesNamedProfiles(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, WithPprof(true))
+
+	rec := serve(router, "/debug/pprof/goroutine?debug=1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "goroutine profile")
+	require.Equal(t, http.StatusOK, serve(router, "/debug/pprof/cmdline", "").Code)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/datatypes"
 
+	"github.com/prehisle/yapi/internal/errcode"
 	"github.com/prehisle/yapi/pkg/accounts"
 )
 
@@ -48,7 +49,11 @@ func APIKeyAuth(auth Authenticator) gin.HandlerFunc {
 		if err != nil {
 			apiKey, apiErr := auth.ResolveAPIKey(c.Request.Context(), rawKey)
 			if apiErr != nil {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+				errcode.Abort(c, http.StatusUnauthorized, errcode.InvalidAPIKey, "invalid api key")
+				return
+			}
+			if !apiKey.Enabled {
+				errcode.Abort(c, http.StatusForbidden, errcode.APIKeyDisabled, "api key disabled")
 				return
 			}
 			c.Set(apiKeyContextKey, apiKey)
@@ -60,13 +65,17 @@ func APIKeyAuth(auth Authenticator) gin.HandlerFunc {
 			return
 		}
 		if apiKey, apiErr := auth.ResolveAPIKey(c.Request.Context(), rawKey); apiErr == nil {
+			if !apiKey.Enabled {
+				errcode.Abort(c, http.StatusForbidden, errcode.APIKeyDisabled, "api key disabled")
+				return
+			}
 			c.Set(apiKeyContextKey, apiKey)
 		}
-		c.Set(bindingContextKey, binding)
-		c.Set(upstreamContextKey, UpstreamInfo{
-			Credential: upstream,
-			Endpoints:  decodeEndpoints(upstream.Endpoints),
-		})
+		if !upstream.Enabled {
+			errcode.Abort(c, http.StatusForbidden, errcode.UpstreamCredentialDisabled, "upstream credential disabled")
+			return
+		}
+		SetBinding(c, binding, upstream)
 		c.Set(rawAPIKeyContextKey, rawKey)
 		if user, err := auth.GetUser(c.Request.Context(), binding.UserID); err == nil {
 			c.Set(userContextKey, user)
@@ -112,6 +121,17 @@ func CurrentAPIKey(c *gin.Context) (accounts.APIKey, bool) {
 	return accounts.APIKey{}, false
 }
 
+// SetUser stores the authenticated user on the request, e.g. when an admin
+// replays a captured request on the user's behalf.
+func SetUser(c *gin.Context, user accounts.User) {
+	c.Set(userContextKey, user)
+}
+
+// SetAPIKey stores the resolved API key on the request without its raw value.
+func SetAPIKey(c *gin.Context, apiKey accounts.APIKey) {
+	c.Set(apiKeyContextKey, apiKey)
+}
+
 // CurrentBinding returns API key binding if available.
 func CurrentBinding(c *gin.Context) (accounts.UserAPIKeyBinding, bool) {
 	if value, ok := c.Get(bindingContextKey); ok {
@@ -122,6 +142,16 @@ func CurrentBinding(c *gin.Context) (accounts.UserAPIKeyBinding, bool) {
 	return accounts.UserAPIKeyBinding{}, false
 }
 
+// SetBinding stores the binding and its upstream credential on the request,
+// replacing any previous one (e.g. when the proxy fails over to a fallback).
+func SetBinding(c *gin.Context, binding accounts.UserAPIKeyBinding, upstream accounts.UpstreamCredential) {
+	c.Set(bindingContextKey, binding)
+	c.Set(upstreamContextKey, UpstreamInfo{
+		Credential: upstream,
+		Endpoints:  decodeEndpoints(upstream.Endpoints),
+	})
+}
+
 // CurrentUpstreamCredential returns upstream credential associated with request.
 func CurrentUpstreamInfo(c *gin.Context) (UpstreamInfo, bool) {
 	if value, ok := c.Get(upstreamContextKey); ok {
@@ -3,20 +3,49 @@ package middleware
 import (
 	"net/http"
 	"slices"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 )
 
+// CORSOrigins is an admin origin allowlist that can be replaced at runtime,
+// e.g. on configuration reload. An empty allowlist echoes any Origin.
+type CORSOrigins struct {
+	origins atomic.Pointer[[]string]
+}
+
+// NewCORSOrigins creates an allowlist with the given origins.
+func NewCORSOrigins(origins []string) *CORSOrigins {
+	o := &CORSOrigins{}
+	o.Set(origins)
+	return o
+}
+
+// Set replaces the allowlist; subsequent requests see the new origins.
+func (o *CORSOrigins) Set(origins []string) {
+	cloned := slices.Clone(origins)
+	o.origins.Store(&cloned)
+}
+
+// Allowed reports whether origin may access the admin APIs.
+func (o *CORSOrigins) Allowed(origin string) bool {
+	origins := *o.origins.Load()
+	return len(origins) == 0 || slices.Contains(origins, origin)
+}
+
 // CORS enables cross-origin requests for admin APIs with optional allowlist control.
 func CORS(allowedOrigins []string) gin.HandlerFunc {
-	allowAll := len(allowedOrigins) == 0
+	return DynamicCORS(NewCORSOrigins(allowedOrigins))
+}
 
+// DynamicCORS is CORS backed by an allowlist that can change at runtime.
+func DynamicCORS(origins *CORSOrigins) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
-		if origin != "" && (allowAll || slices.Contains(allowedOrigins, origin)) {
+		if origin != "" && origins.Allowed(origin) {
 			c.Header("Access-Control-Allow-Origin", origin)
 			c.Header("Access-Control-Allow-Credentials", "true")
-			c.Header("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			c.Header("Access-Control-Allow-Headers", "Authorization, Content-Type, X-CSRF-Token")
 			c.Header("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
 		}
 		if c.Request.Method == http.MethodOptions {
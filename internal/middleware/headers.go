@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InternalHeaderPrefix is the prefix reserved for headers the gateway injects
+// or emits itself; clients must never be able to set them.
+const InternalHeaderPrefix = "X-Yapi-"
+
+// DefaultInternalHeaders are the non-prefixed headers the proxy injects into
+// upstream requests to identify the selected service and credential.
+var DefaultInternalHeaders = []string{
+	"X-Upstream-Service",
+	"X-Upstream-Provider",
+	"X-Upstream-Name",
+	"X-Upstream-Credential-ID",
+}
+
+// InternalHeaders is the set of header names stripped from inbound requests.
+// It can be replaced at runtime, e.g. on configuration reload. Names are
+// matched case-insensitively; a trailing "*" matches any header with that
+// prefix.
+type InternalHeaders struct {
+	matcher atomic.Pointer[headerMatcher]
+}
+
+type headerMatcher struct {
+	names    map[string]struct{}
+	prefixes []string
+}
+
+// NewInternalHeaders creates a header set with DefaultInternalHeaders, every
+// X-YAPI-* header and the given extra names.
+func NewInternalHeaders(extra []string) *InternalHeaders {
+	h := &InternalHeaders{}
+	h.Set(extra)
+	return h
+}
+
+// Set replaces the configured extra names; the defaults always apply.
+func (h *InternalHeaders) Set(extra []string) {
+	m := &headerMatcher{
+		names:    make(map[string]struct{}, len(DefaultInternalHeaders)+len(extra)),
+		prefixes: []string{InternalHeaderPrefix},
+	}
+	for _, name := range append(append([]string(nil), DefaultInternalHeaders...), extra...) {
+		name = strings.TrimSpace(name)
+		if prefix, ok := strings.CutSuffix(name, "*"); ok {
+			if prefix != "" {
+				m.prefixes = append(m.prefixes, http.CanonicalHeaderKey(prefix))
+			}
+			continue
+		}
+		if name != "" {
+			m.names[http.CanonicalHeaderKey(name)] = struct{}{}
+		}
+	}
+	h.matcher.Store(m)
+}
+
+// Internal reports whether the header must not be accepted from clients.
+func (h *InternalHeaders) Internal(name string) bool {
+	m := h.matcher.Load()
+	name = http.CanonicalHeaderKey(name)
+	if _, ok := m.names[name]; ok {
+		return true
+	}
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// StripInternalHeaders removes internal headers from inbound requests before
+// any other handler sees them, so clients cannot spoof identity or routing
+// headers the gateway injects for upstreams.
+func StripInternalHeaders(headers *InternalHeaders) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for name := range c.Request.Header {
+			if headers.Internal(name) {
+				c.Request.Header.Del(name)
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripInternalHeaders(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	headers := NewInternalHeaders([]string{"X-Tenant", "x-corp-*"})
+	router := gin.New()
+	router.Use(StripInternalHeaders(headers))
+	var got http.Header
+	router.GET("/", func(c *gin.Context) {
+		got = c.Request.Header.Clone()
+		c.Status(http.StatusNoContent)
+	})
+
+	send := func() {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("x-yapi-user-id", "admin")
+		req.Header.Set("X-Upstream-Credential-ID", "cred-1")
+		req.Header.Set("X-Tenant", "acme")
+		req.Header.Set("X-Corp-Role", "root")
+		req.Header.Set("X-Conversation-ID", "conv-1")
+		req.Header.Set("Authorization", "Bearer sk")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send()
+	for _, name := range []string{"X-Yapi-User-Id", "X-Upstream-Credential-ID", "X-Tenant", "X-Corp-Role"} {
+		require.Empty(t, got.Get(name), name)
+	}
+	require.Equal(t, "conv-1", got.Get("X-Conversation-ID"))
+	require.Equal(t, "Bearer sk", got.Get("Authorization"))
+
+	headers.Set(nil)
+	send()
+	require.Equal(t, "acme", got.Get("X-Tenant"), "configured names are replaced on reload")
+	require.Empty(t, got.Get("X-Yapi-User-Id"), "X-YAPI-* is always stripped")
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/errcode"
+)
+
+func TestLimitInflight_ShedsBeyondLimit(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	limit := NewInflightLimit("proxy", 1)
+	entered, release := make(chan struct{}), make(chan struct{})
+	router := gin.New()
+	router.Use(LimitInflight(limit))
+	router.GET("/slow", func(c *gin.Context) {
+		close(entered)
+		<-release
+		c.Status(http.StatusOK)
+	})
+	router.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		done <- rec.Code
+	}()
+	<-entered
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("Retry-After"))
+	require.Contains(t, rec.Body.String(), string(errcode.Overloaded))
+
+	limit.Set(0)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	require.Equal(t, http.StatusOK, rec.Code, "zero disables the limit")
+
+	limit.Set(1)
+	close(release)
+	require.Equal(t, http.StatusOK, <-done)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	require.Equal(t, http.StatusOK, rec.Code, "slots are released when requests finish")
+}
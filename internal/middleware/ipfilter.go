@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// IPFilter is a client IP allowlist/denylist that can be replaced at runtime,
+// e.g. on configuration reload. The denylist wins over the allowlist; an empty
+// allowlist admits every address that is not denied.
+type IPFilter struct {
+	scope string
+	lists atomic.Pointer[ipLists]
+}
+
+type ipLists struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// NewIPFilter creates an empty filter, which admits every address, for scope
+// (used as the metrics label). Call Set to configure the lists.
+func NewIPFilter(scope string) *IPFilter {
+	f := &IPFilter{scope: scope}
+	f.lists.Store(&ipLists{})
+	return f
+}
+
+// Set replaces both lists with IP addresses or CIDR ranges; on error the
+// current lists are kept.
+func (f *IPFilter) Set(allow, deny []string) error {
+	allowed, err := parseIPPrefixes(allow)
+	if err != nil {
+		return err
+	}
+	denied, err := parseIPPrefixes(deny)
+	if err != nil {
+		return err
+	}
+	f.lists.Store(&ipLists{allow: allowed, deny: denied})
+	return nil
+}
+
+// Check reports whether ip may pass and, if not, why: "denylist" or
+// "not_allowlisted". Unparseable addresses only pass an empty allowlist.
+func (f *IPFilter) Check(ip string) (bool, string) {
+	lists := f.lists.Load()
+	addr, err := netip.ParseAddr(ip)
+	if err == nil {
+		addr = addr.Unmap()
+		for _, prefix := range lists.deny {
+			if prefix.Contains(addr) {
+				return false, "denylist"
+			}
+		}
+	}
+	if len(lists.allow) == 0 {
+		return true, ""
+	}
+	if err == nil {
+		for _, prefix := range lists.allow {
+			if prefix.Contains(addr) {
+				return true, ""
+			}
+		}
+	}
+	return false, "not_allowlisted"
+}
+
+// parseIPPrefixes parses IP addresses and CIDR ranges; a bare address is
+// treated as a single-host range.
+func parseIPPrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP %q: %w", entry, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// RestrictIPsOption customises RestrictIPs.
+type RestrictIPsOption func(*restrictIPsConfig)
+
+type restrictIPsConfig struct {
+	forwarded bool
+}
+
+// WithForwardedClientIP makes RestrictIPs filter on gin's ClientIP, which reads
+// X-Forwarded-For / X-Real-IP set by the engine's trusted proxies. Only use it
+// when the engine was configured with SetTrustedProxies: gin otherwise trusts
+// every source and clients could spoof their address past the lists.
+func WithForwardedClientIP() RestrictIPsOption {
+	return func(cfg *restrictIPsConfig) {
+		cfg.forwarded = true
+	}
+}
+
+// RestrictIPs rejects requests whose client IP is denied by filter with 403
+// YAPI_IP_FORBIDDEN. The client IP is the TCP peer address unless
+// WithForwardedClientIP is given. It should run before authentication so
+// blocked clients cannot probe keys.
+func RestrictIPs(filter *IPFilter, opts ...RestrictIPsOption) gin.HandlerFunc {
+	var cfg restrictIPsConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return func(c *gin.Context) {
+		ip := c.RemoteIP()
+		if cfg.forwarded {
+			ip = c.ClientIP()
+		}
+		if ok, reason := filter.Check(ip); !ok {
+			metrics.ObserveIPFilterRejection(filter.scope, reason)
+			errcode.Abort(c, http.StatusForbidden, errcode.IPForbidden, "client ip not allowed")
+			return
+		}
+		c.Next()
+	}
+}
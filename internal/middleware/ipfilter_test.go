@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/errcode"
+)
+
+func TestIPFilter_Check(t *testing.T) {
+	filter := NewIPFilter("global")
+	ok, _ := filter.Check("198.51.100.1")
+	require.True(t, ok, "an empty filter admits everyone")
+
+	require.NoError(t, filter.Set([]string{"10.0.0.0/8", "2001:db8::1"}, []string{"10.0.0.13"}))
+	for ip, want := range map[string]string{
+		"10.1.2.3":        "",
+		"::ffff:10.1.2.3": "",
+		"2001:db8::1":     "",
+		"10.0.0.13":       "denylist",
+		"198.51.100.1":    "not_allowlisted",
+		"not-an-ip":       "not_allowlisted",
+	} {
+		ok, reason := filter.Check(ip)
+		require.Equal(t, want == "", ok, ip)
+		require.Equal(t, want, reason, ip)
+	}
+
+	require.Error(t, filter.Set([]string{"10.0.0.0/33"}, nil))
+	ok, _ = filter.Check("10.1.2.3")
+	require.True(t, ok, "invalid lists keep the previous configuration")
+}
+
+func TestRestrictIPs(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	global := NewIPFilter("global")
+	proxy := NewIPFilter("proxy")
+	require.NoError(t, proxy.Set(nil, []string{"192.0.2.0/24"}))
+	router := gin.New()
+	router.Use(RestrictIPs(global), Unrouted(RestrictIPs(proxy)))
+	router.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.NoRoute(func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send("/v1/chat/completions", "192.0.2.10:4000")
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), string(errcode.IPForbidden))
+	require.Equal(t, http.StatusOK, send("/healthz", "192.0.2.10:4000").Code, "proxy lists do not apply to registered routes")
+	require.Equal(t, http.StatusOK, send("/v1/chat/completions", "203.0.113.5:4000").Code)
+
+	require.NoError(t, global.Set([]string{"203.0.113.0/24"}, nil))
+	require.Equal(t, http.StatusForbidden, send("/healthz", "198.51.100.1:4000").Code)
+	require.Equal(t, http.StatusOK, send("/healthz", "203.0.113.5:4000").Code)
+}
+
+func TestRestrictIPs_IgnoresForwardedForFromUntrustedPeer(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	filter := NewIPFilter("admin")
+	require.NoError(t, filter.Set([]string{"10.0.0.0/8"}, nil))
+
+	send := func(router *gin.Engine, remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	// 默认按 TCP 对端地址过滤，即使引擎仍沿用 gin 信任任意来源的默认设置。
+	router := gin.New()
+	router.GET("/admin", RestrictIPs(filter), ok)
+	require.Equal(t, http.StatusForbidden, send(router, "203.0.113.5:4000", "10.1.2.3"))
+	require.Equal(t, http.StatusOK, send(router, "10.9.9.9:4000", "203.0.113.5"))
+
+	// 配置可信代理后只采信这些代理转发的地址，不可信对端的伪造仍被拒绝。
+	trusted := gin.New()
+	require.NoError(t, trusted.SetTrustedProxies([]string{"192.0.2.1"}))
+	trusted.GET("/admin", RestrictIPs(filter, WithForwardedClientIP()), ok)
+	require.Equal(t, http.StatusOK, send(trusted, "192.0.2.1:4000", "10.1.2.3"))
+	require.Equal(t, http.StatusForbidden, send(trusted, "203.0.113.5:4000", "10.1.2.3"))
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+)
+
+// SizeLimits are per-request header and body limits; zero disables a limit.
+type SizeLimits struct {
+	// MaxHeaders caps the number of header values.
+	MaxHeaders int
+	// MaxHeaderBytes caps the combined length of header names and values.
+	MaxHeaderBytes int
+	// MaxBodyBytes caps the request body size.
+	MaxBodyBytes int
+}
+
+// RequestLimits holds SizeLimits that can be replaced at runtime, e.g. on
+// configuration reload.
+type RequestLimits struct {
+	limits atomic.Pointer[SizeLimits]
+}
+
+// NewRequestLimits creates a holder with the given limits.
+func NewRequestLimits(limits SizeLimits) *RequestLimits {
+	l := &RequestLimits{}
+	l.Set(limits)
+	return l
+}
+
+// Set replaces the limits; subsequent requests see the new values.
+func (l *RequestLimits) Set(limits SizeLimits) {
+	l.limits.Store(&limits)
+}
+
+// Get returns the current limits.
+func (l *RequestLimits) Get() SizeLimits {
+	return *l.limits.Load()
+}
+
+// LimitRequestSize rejects requests with too many or too large headers (431
+// YAPI_HEADERS_TOO_LARGE) or an oversized body (413 YAPI_BODY_TOO_LARGE)
+// before any handler buffers them. Bodies with a declared Content-Length are
+// rejected up front; chunked bodies are read up to the limit and replayed.
+func LimitRequestSize(limits *RequestLimits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		current := limits.Get()
+		if current.MaxHeaders > 0 || current.MaxHeaderBytes > 0 {
+			count, size := 0, 0
+			for name, values := range c.Request.Header {
+				count += len(values)
+				for _, value := range values {
+					size += len(name) + len(value)
+				}
+			}
+			if (current.MaxHeaders > 0 && count > current.MaxHeaders) || (current.MaxHeaderBytes > 0 && size > current.MaxHeaderBytes) {
+				errcode.Abort(c, http.StatusRequestHeaderFieldsTooLarge, errcode.HeadersTooLarge, "request headers too large")
+				return
+			}
+		}
+		if current.MaxBodyBytes > 0 && c.Request.Body != nil && c.Request.Body != http.NoBody {
+			maxBytes := int64(current.MaxBodyBytes)
+			if c.Request.ContentLength > maxBytes {
+				errcode.Abort(c, http.StatusRequestEntityTooLarge, errcode.BodyTooLarge, "request body too large")
+				return
+			}
+			if c.Request.ContentLength < 0 {
+				body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBytes+1))
+				if err != nil {
+					errcode.Abort(c, http.StatusBadRequest, errcode.InvalidRequest, "read request body failed")
+					return
+				}
+				if int64(len(body)) > maxBytes {
+					errcode.Abort(c, http.StatusRequestEntityTooLarge, errcode.BodyTooLarge, "request body too large")
+					return
+				}
+				_ = c.Request.Body.Close()
+				c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			} else {
+				c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/errcode"
+)
+
+func TestLimitRequestSize(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	limits := NewRequestLimits(SizeLimits{MaxHeaders: 3, MaxHeaderBytes: 64, MaxBodyBytes: 8})
+	router := gin.New()
+	router.Use(LimitRequestSize(limits))
+	var got string
+	router.POST("/", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		require.NoError(t, err)
+		got = string(body)
+		c.Status(http.StatusOK)
+	})
+
+	send := func(body string, chunked bool, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		if chunked {
+			req.ContentLength = -1
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, send("12345678", false, nil).Code)
+	require.Equal(t, http.StatusOK, send("1234", true, nil).Code)
+	require.Equal(t, "1234", got, "chunked bodies within the limit are replayed")
+
+	for _, chunked := range []bool{false, true} {
+		rec := send("123456789", chunked, nil)
+		require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		require.Contains(t, rec.Body.String(), string(errcode.BodyTooLarge))
+	}
+
+	rec := send("", false, map[string]string{"A": "1", "B": "2", "C": "3", "D": "4"})
+	require.Equal(t, http.StatusRequestHeaderFieldsTooLarge, rec.Code)
+	require.Contains(t, rec.Body.String(), string(errcode.HeadersTooLarge))
+	require.Equal(t, http.StatusRequestHeaderFieldsTooLarge, send("", false, map[string]string{"X-Large": strings.Repeat("x", 64)}).Code)
+
+	limits.Set(SizeLimits{})
+	require.Equal(t, http.StatusOK, send(strings.Repeat("x", 1024), false, map[string]string{"A": "1", "B": "2", "C": "3", "D": "4"}).Code)
+}
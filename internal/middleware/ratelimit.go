@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/ratelimit"
+	"github.com/prehisle/yapi/pkg/accounts"
+)
+
+// RateLimitDefaults holds the per-user limits applied when neither the user
+// nor the API key configures its own. Zero means unlimited. The values can be
+// replaced at runtime, e.g. on configuration reload.
+type RateLimitDefaults struct {
+	rpm         atomic.Int64
+	concurrency atomic.Int64
+}
+
+// NewRateLimitDefaults creates defaults with the given limits.
+func NewRateLimitDefaults(rpm, concurrency int) *RateLimitDefaults {
+	d := &RateLimitDefaults{}
+	d.Set(rpm, concurrency)
+	return d
+}
+
+// Set replaces the default limits; negative values are treated as zero.
+func (d *RateLimitDefaults) Set(rpm, concurrency int) {
+	d.rpm.Store(int64(max(rpm, 0)))
+	d.concurrency.Store(int64(max(concurrency, 0)))
+}
+
+// Get returns the current default limits.
+func (d *RateLimitDefaults) Get() (rpm, concurrency int) {
+	return int(d.rpm.Load()), int(d.concurrency.Load())
+}
+
+// RateLimitOption customises RateLimit.
+type RateLimitOption func(*rateLimitConfig)
+
+type rateLimitConfig struct {
+	defaults *RateLimitDefaults
+}
+
+// WithDefaultLimits applies defaults to users whose own limits are zero.
+func WithDefaultLimits(defaults *RateLimitDefaults) RateLimitOption {
+	return func(cfg *rateLimitConfig) {
+		cfg.defaults = defaults
+	}
+}
+
+// RateLimit enforces per-user (or per API key override) request-per-minute and
+// concurrency caps. It must run after APIKeyAuth; anonymous requests pass
+// through. Limiter failures fail open so a Redis outage never blocks traffic.
+func RateLimit(limiter ratelimit.Limiter, opts ...RateLimitOption) gin.HandlerFunc {
+	if limiter == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	var cfg rateLimitConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return func(c *gin.Context) {
+		user, ok := CurrentUser(c)
+		if !ok {
+			c.Next()
+			return
+		}
+		apiKey, _ := CurrentAPIKey(c)
+		rpm, concurrency := accounts.EffectiveRateLimits(user, apiKey)
+		if cfg.defaults != nil {
+			defaultRPM, defaultConcurrency := cfg.defaults.Get()
+			if rpm.Limit == 0 {
+				rpm.Limit = defaultRPM
+			}
+			if concurrency.Limit == 0 {
+				concurrency.Limit = defaultConcurrency
+			}
+		}
+		ctx := c.Request.Context()
+
+		if rpm.Limit > 0 {
+			result, err := limiter.Allow(ctx, scopeKey(rpm.Scope), rpm.Limit)
+			if err == nil {
+				header := c.Writer.Header()
+				header.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+				header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+				header.Set("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+				if !result.Allowed {
+					retryAfter := int(time.Until(result.ResetAt).Seconds()) + 1
+					header.Set("Retry-After", strconv.Itoa(retryAfter))
+					errcode.Abort(c, http.StatusTooManyRequests, errcode.RateLimited, "rate limit exceeded")
+					return
+				}
+			}
+		}
+
+		if concurrency.Limit > 0 {
+			release, acquired, err := limiter.Acquire(ctx, scopeKey(concurrency.Scope), concurrency.Limit)
+			if err == nil {
+				if !acquired {
+					c.Header("Retry-After", "1")
+					errcode.Abort(c, http.StatusTooManyRequests, errcode.ConcurrencyLimited, "too many concurrent requests")
+					return
+				}
+				defer release()
+			}
+		}
+		c.Next()
+	}
+}
+
+func scopeKey(scope accounts.RateLimitScope) string {
+	return scope.Kind + ":" + scope.ID
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/ratelimit"
+	"github.com/prehisle/yapi/pkg/accounts"
+)
+
+func TestRateLimit_AppliesRuntimeDefaults(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	defaults := NewRateLimitDefaults(1, 0)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(userContextKey, accounts.User{ID: c.GetHeader("X-User")})
+		c.Next()
+	}, RateLimit(ratelimit.NewMemoryLimiter(), WithDefaultLimits(defaults)))
+	router.GET("/v1/models", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	call := func(user string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	require.Equal(t, http.StatusOK, call("u1"))
+	require.Equal(t, http.StatusTooManyRequests, call("u1"))
+
+	defaults.Set(0, 0)
+	require.Equal(t, http.StatusOK, call("u1"))
+}
+
+func TestDynamicCORS_FollowsAllowlistUpdates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	origins := NewCORSOrigins([]string{"https://a.example.com"})
+	router := gin.New()
+	router.Use(DynamicCORS(origins))
+	router.GET("/admin/rules", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	allowOrigin := func(origin string) string {
+		req := httptest.NewRequest(http.MethodGet, "/admin/rules", nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Header().Get("Access-Control-Allow-Origin")
+	}
+	require.Equal(t, "https://a.example.com", allowOrigin("https://a.example.com"))
+	require.Empty(t, allowOrigin("https://b.example.com"))
+
+	origins.Set([]string{"https://b.example.com"})
+	require.Empty(t, allowOrigin("https://a.example.com"))
+	require.Equal(t, "https://b.example.com", allowOrigin("https://b.example.com"))
+}
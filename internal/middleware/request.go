@@ -3,21 +3,30 @@ package middleware
 import (
 	"log/slog"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/prehisle/yapi/internal/errcode"
 	"github.com/prehisle/yapi/pkg/metrics"
 )
 
 // RequestIDKey is the gin context key storing current request id.
 const RequestIDKey = "request_id"
 
-// RequestID ensures each incoming request has a traceable identifier.
+// RequestID ensures each incoming request has a traceable identifier. It
+// prefers the client's X-Request-ID, then the trace ID of a valid W3C
+// traceparent header, so gateway logs correlate with the caller's trace, and
+// otherwise generates a UUID.
 func RequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		requestID := c.GetHeader("X-Request-ID")
+		if requestID == "" {
+			requestID = traceIDFromTraceparent(c.GetHeader("traceparent"))
+		}
 		if requestID == "" {
 			requestID = uuid.NewString()
 		}
@@ -27,33 +36,136 @@ func RequestID() gin.HandlerFunc {
 	}
 }
 
-// AccessLogger prints structured logs for each request.
-func AccessLogger(logger *slog.Logger) gin.HandlerFunc {
+// traceIDFromTraceparent returns the trace ID of a W3C traceparent header
+// ("version-traceid-parentid-flags"), or "" when the header is malformed or
+// the trace ID is all zeros.
+func traceIDFromTraceparent(header string) string {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return ""
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return ""
+	}
+	for _, part := range parts[:4] {
+		if !isLowerHex(part) {
+			return ""
+		}
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return ""
+	}
+	return parts[1]
+}
+
+func isLowerHex(s string) bool {
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLogOption customises AccessLogger.
+type AccessLogOption func(*accessLogConfig)
+
+type accessLogConfig struct {
+	sampleN       uint64
+	slowThreshold time.Duration
+	slowOnly      bool
+}
+
+// WithSuccessSampling logs only one in every n successful (non-error, non-slow)
+// requests. Sampled records carry a sample_rate attribute so log consumers can
+// extrapolate volumes. Values below 2 disable sampling.
+func WithSuccessSampling(n int) AccessLogOption {
+	return func(cfg *accessLogConfig) {
+		if n > 1 {
+			cfg.sampleN = uint64(n)
+		}
+	}
+}
+
+// WithSlowThreshold always logs requests that take at least threshold,
+// bypassing sampling, and marks them with slow=true. Zero disables it.
+func WithSlowThreshold(threshold time.Duration) AccessLogOption {
+	return func(cfg *accessLogConfig) {
+		cfg.slowThreshold = threshold
+	}
+}
+
+// WithSlowOnly drops every successful request that is not slow, leaving only
+// errors and requests above the slow threshold in the access log.
+func WithSlowOnly(enabled bool) AccessLogOption {
+	return func(cfg *accessLogConfig) {
+		cfg.slowOnly = enabled
+	}
+}
+
+// AccessLogger prints structured logs for each request. Records are logged at
+// error level for 5xx responses, warn for 4xx and info otherwise, so sinks can
+// filter by level. Error responses carry their gateway error code as
+// error_code. Errors (4xx and 5xx) are always logged; successful requests
+// may be sampled or dropped via options. Metrics cover every request.
+func AccessLogger(logger *slog.Logger, opts ...AccessLogOption) gin.HandlerFunc {
 	if logger == nil {
 		logger = slog.Default()
 	}
+	var cfg accessLogConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var successes atomic.Uint64
 	return func(c *gin.Context) {
 		start := time.Now()
 		c.Next()
 		duration := time.Since(start)
 		status := c.Writer.Status()
-		requestID := RequestIDFromContext(c)
 		route := c.FullPath()
 		if route == "" {
 			route = "<unmatched>"
 		}
+		metrics.ObserveHTTPRequest(c.Request.Method, route, status, duration)
 
-		logger.Info("http request",
-			"request_id", requestID,
+		attrs := []any{
+			"request_id", RequestIDFromContext(c),
 			"method", c.Request.Method,
 			"path", c.Request.URL.Path,
 			"status", status,
 			"latency_ms", duration.Milliseconds(),
 			"client_ip", c.ClientIP(),
 			"user_agent", c.Request.UserAgent(),
-		)
+		}
+		if code, ok := errcode.FromContext(c); ok {
+			attrs = append(attrs, "error_code", string(code))
+		}
+		slow := cfg.slowThreshold > 0 && duration >= cfg.slowThreshold
+		if slow {
+			attrs = append(attrs, "slow", true)
+		}
+		switch {
+		case status >= http.StatusBadRequest, slow:
+		case cfg.slowOnly:
+			return
+		case cfg.sampleN > 1:
+			if successes.Add(1)%cfg.sampleN != 1 {
+				return
+			}
+			attrs = append(attrs, "sample_rate", cfg.sampleN)
+		}
+		logger.Log(c.Request.Context(), accessLogLevel(status), "http request", attrs...)
+	}
+}
 
-		metrics.ObserveHTTPRequest(c.Request.Method, route, status, duration)
+func accessLogLevel(status int) slog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return slog.LevelError
+	case status >= http.StatusBadRequest:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
 	}
 }
 
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/errcode"
+)
+
+func TestAccessLogger_SamplesSuccessesAndKeepsErrorsAndSlowRequests(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(AccessLogger(logger, WithSuccessSampling(3), WithSlowThreshold(20*time.Millisecond)))
+	router.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/fail", func(c *gin.Context) { c.Status(http.StatusBadGateway) })
+	router.GET("/slow", func(c *gin.Context) {
+		time.Sleep(25 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/ok", "/ok", "/ok", "/ok", "/fail", "/slow", "/ok"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 4)
+	require.Equal(t, "/ok", records[0]["path"])
+	require.Equal(t, float64(3), records[0]["sample_rate"])
+	require.Equal(t, "/ok", records[1]["path"])
+	require.Equal(t, "/fail", records[2]["path"])
+	require.Equal(t, "ERROR", records[2]["level"])
+	require.Equal(t, "/slow", records[3]["path"])
+	require.Equal(t, true, records[3]["slow"])
+}
+
+func TestAccessLogger_SlowOnly(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(AccessLogger(logger, WithSlowOnly(true), WithSlowThreshold(time.Hour)))
+	router.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/missing", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], `"status":404`)
+	require.Contains(t, lines[0], `"level":"WARN"`)
+}
+
+func TestAccessLogger_RecordsErrorCode(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(AccessLogger(logger))
+	router.GET("/denied", func(c *gin.Context) {
+		errcode.Abort(c, http.StatusUnauthorized, errcode.InvalidAPIKey, "invalid api key")
+	})
+	router.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/denied", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `"error_code":"YAPI_INVALID_API_KEY"`)
+	require.NotContains(t, lines[1], "error_code")
+}
+
+func TestRequestID_PrefersHeaderThenTraceparent(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, RequestIDFromContext(c)) })
+
+	send := func(headers map[string]string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, rec.Body.String(), rec.Header().Get("X-Request-ID"))
+		return rec.Body.String()
+	}
+
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	require.Equal(t, "client-id", send(map[string]string{"X-Request-ID": "client-id", "traceparent": traceparent}))
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", send(map[string]string{"traceparent": traceparent}))
+	for _, invalid := range []string{
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		id := send(map[string]string{"traceparent": invalid})
+		require.Len(t, id, 36, "malformed traceparent %q falls back to a UUID", invalid)
+	}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// Unrouted runs handler only for requests that match no registered route,
+// i.e. the ones served by the proxy's NoRoute/NoMethod handler. Other requests
+// skip it. Use it to scope engine-level middleware to proxied traffic.
+func Unrouted(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.FullPath() != "" {
+			c.Next()
+			return
+		}
+		handler(c)
+	}
+}
@@ -0,0 +1,262 @@
+// Package modelalias 维护网关级的模型别名表：转发前把请求体中的 model 别名替换为实际模型，
+// 可按上游凭据的 Provider 映射到不同的模型，使模型迁移无需修改客户端。
+package modelalias
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+var (
+	// ErrNotFound 表示别名不存在。
+	ErrNotFound = errors.New("model alias not found")
+	// ErrInvalidInput 表示参数不合法。
+	ErrInvalidInput = errors.New("invalid model alias input")
+)
+
+const maxNameLength = 255
+
+// Alias 是一条模型别名：Providers 中与上游凭据 Provider 匹配的映射优先，否则使用 Target；
+// Target 为空且没有匹配的 Provider 时保留客户端请求的模型。
+type Alias struct {
+	Name      string            `gorm:"type:varchar(255);primaryKey"`
+	Target    string            `gorm:"type:varchar(255)"`
+	Providers map[string]string `gorm:"serializer:json;type:text"`
+	UpdatedAt time.Time
+}
+
+// TableName 指定表名。
+func (Alias) TableName() string {
+	return "model_aliases"
+}
+
+// Resolve 返回别名在指定 Provider 下对应的模型。
+func (a Alias) Resolve(provider string) (string, bool) {
+	if target, ok := a.Providers[normalizeProvider(provider)]; ok {
+		return target, true
+	}
+	return a.Target, a.Target != ""
+}
+
+// Store 定义别名的持久化接口。
+type Store interface {
+	List(ctx context.Context) ([]Alias, error)
+	Upsert(ctx context.Context, alias Alias) (Alias, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// Service 定义别名的管理与解析；Resolve 只读取内存中的别名表，可在代理热路径上调用。
+type Service interface {
+	Load(ctx context.Context) error
+	StartBackgroundSync(ctx context.Context)
+	List(ctx context.Context) ([]Alias, error)
+	Get(ctx context.Context, name string) (Alias, error)
+	Upsert(ctx context.Context, alias Alias) (Alias, error)
+	Delete(ctx context.Context, name string) error
+	Resolve(provider, model string) (string, bool)
+	Empty() bool
+}
+
+// Option 定义 Service 可选项。
+type Option func(*service)
+
+// WithEventBus 设置事件总线：别名变更后广播 model_aliases_changed，其他实例收到后重新加载别名表。
+func WithEventBus(bus rules.EventBus) Option {
+	return func(s *service) {
+		s.eventBus = bus
+	}
+}
+
+// WithLogger 设置日志记录器。
+func WithLogger(logger *log.Logger) Option {
+	return func(s *service) {
+		if logger != nil {
+			s.logger = logger
+		}
+	}
+}
+
+type service struct {
+	store    Store
+	eventBus rules.EventBus
+	logger   *log.Logger
+
+	mu      sync.RWMutex
+	aliases map[string]Alias
+}
+
+// NewService 基于存储创建别名服务，调用 Load 后别名表才会生效。
+func NewService(store Store, opts ...Option) Service {
+	s := &service{store: store, logger: log.Default(), aliases: map[string]Alias{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Load 从存储重新加载别名表。
+func (s *service) Load(ctx context.Context) error {
+	aliases, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	table := make(map[string]Alias, len(aliases))
+	for _, alias := range aliases {
+		table[alias.Name] = alias
+	}
+	s.mu.Lock()
+	s.aliases = table
+	s.mu.Unlock()
+	return nil
+}
+
+// syncRetryInterval 是重新加载失败后的重试间隔。
+const syncRetryInterval = 5 * time.Second
+
+// StartBackgroundSync 订阅别名变更事件并重新加载别名表，未设置事件总线时不做任何事。
+func (s *service) StartBackgroundSync(ctx context.Context) {
+	if s.eventBus == nil {
+		return
+	}
+	events, err := s.eventBus.Subscribe(ctx)
+	if err != nil {
+		s.logger.Printf("model aliases event subscribe failed: %v", err)
+		return
+	}
+	go func() {
+		var retry <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-retry:
+				retry = s.syncOnce(ctx)
+			case evt, ok := <-events:
+				if !ok {
+					return
+				}
+				if evt == rules.EventModelAliasesChanged {
+					retry = s.syncOnce(ctx)
+				}
+			}
+		}
+	}()
+}
+
+func (s *service) syncOnce(ctx context.Context) <-chan time.Time {
+	if err := s.Load(ctx); err != nil {
+		s.logger.Printf("model aliases reload failed: %v", err)
+		return time.After(syncRetryInterval)
+	}
+	return nil
+}
+
+// List 按名称返回全部别名。
+func (s *service) List(ctx context.Context) ([]Alias, error) {
+	aliases, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(aliases, func(a, b Alias) int { return strings.Compare(a.Name, b.Name) })
+	return aliases, nil
+}
+
+func (s *service) Get(ctx context.Context, name string) (Alias, error) {
+	aliases, err := s.store.List(ctx)
+	if err != nil {
+		return Alias{}, err
+	}
+	for _, alias := range aliases {
+		if alias.Name == name {
+			return alias, nil
+		}
+	}
+	return Alias{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// Upsert 校验并保存别名，成功后刷新本实例的别名表并通知其他实例。
+func (s *service) Upsert(ctx context.Context, alias Alias) (Alias, error) {
+	alias, err := normalize(alias)
+	if err != nil {
+		return Alias{}, err
+	}
+	saved, err := s.store.Upsert(ctx, alias)
+	if err != nil {
+		return Alias{}, err
+	}
+	s.changed(ctx)
+	return saved, nil
+}
+
+func (s *service) Delete(ctx context.Context, name string) error {
+	if err := s.store.Delete(ctx, name); err != nil {
+		return err
+	}
+	s.changed(ctx)
+	return nil
+}
+
+// Resolve 返回模型在指定 Provider 下的实际模型；模型不是别名或别名未覆盖该 Provider 时返回 false。
+// 别名只解析一层，目标模型不会再次按别名替换。
+func (s *service) Resolve(provider, model string) (string, bool) {
+	s.mu.RLock()
+	alias, ok := s.aliases[model]
+	s.mu.RUnlock()
+	if !ok {
+		return "", false
+	}
+	return alias.Resolve(provider)
+}
+
+// Empty 判断别名表是否为空，代理据此跳过请求体解析。
+func (s *service) Empty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.aliases) == 0
+}
+
+func (s *service) changed(ctx context.Context) {
+	if err := s.Load(ctx); err != nil {
+		s.logger.Printf("model aliases reload failed: %v", err)
+	}
+	if s.eventBus == nil {
+		return
+	}
+	if err := s.eventBus.Publish(ctx, rules.EventModelAliasesChanged); err != nil {
+		s.logger.Printf("model aliases event publish failed: %v", err)
+	}
+}
+
+// normalize 去除首尾空白并校验别名：名称必填，Target 与 Providers 至少设置一项，Provider 名称统一为小写。
+func normalize(alias Alias) (Alias, error) {
+	alias.Name = strings.TrimSpace(alias.Name)
+	if alias.Name == "" || len(alias.Name) > maxNameLength {
+		return Alias{}, fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidInput, maxNameLength)
+	}
+	alias.Target = strings.TrimSpace(alias.Target)
+	providers := make(map[string]string, len(alias.Providers))
+	for provider, target := range alias.Providers {
+		provider, target = normalizeProvider(provider), strings.TrimSpace(target)
+		if provider == "" || target == "" {
+			return Alias{}, fmt.Errorf("%w: provider mappings require a provider and a target model", ErrInvalidInput)
+		}
+		providers[provider] = target
+	}
+	if alias.Target == "" && len(providers) == 0 {
+		return Alias{}, fmt.Errorf("%w: target or providers is required", ErrInvalidInput)
+	}
+	alias.Providers = providers
+	return alias, nil
+}
+
+func normalizeProvider(provider string) string {
+	return strings.ToLower(strings.TrimSpace(provider))
+}
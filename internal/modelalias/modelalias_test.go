@@ -0,0 +1,97 @@
+package modelalias
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+func newDBStore(t *testing.T, dsn string) *DBStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	store := NewDBStore(db)
+	require.NoError(t, store.AutoMigrate(context.Background()))
+	return store
+}
+
+func TestService_ResolveByProvider(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(newDBStore(t, "file:modelalias_resolve?mode=memory&cache=shared"))
+	require.True(t, svc.Empty())
+
+	_, err := svc.Upsert(ctx, Alias{Name: " fast ", Target: "gpt-4o-mini", Providers: map[string]string{" Anthropic ": "claude-3-5-haiku-latest"}})
+	require.NoError(t, err)
+	_, err = svc.Upsert(ctx, Alias{Name: "gpt-4", Target: "gpt-4o-2024-08-06"})
+	require.NoError(t, err)
+	require.False(t, svc.Empty())
+
+	model, ok := svc.Resolve("anthropic", "fast")
+	require.True(t, ok)
+	require.Equal(t, "claude-3-5-haiku-latest", model)
+	model, ok = svc.Resolve("", "fast")
+	require.True(t, ok)
+	require.Equal(t, "gpt-4o-mini", model)
+	_, ok = svc.Resolve("", "gpt-4o")
+	require.False(t, ok)
+
+	// 再次写入同名别名时覆盖原有映射。
+	_, err = svc.Upsert(ctx, Alias{Name: "gpt-4", Providers: map[string]string{"azure": "prod-gpt4o"}})
+	require.NoError(t, err)
+	_, ok = svc.Resolve("openai", "gpt-4")
+	require.False(t, ok)
+	model, ok = svc.Resolve("Azure", "gpt-4")
+	require.True(t, ok)
+	require.Equal(t, "prod-gpt4o", model)
+
+	aliases, err := svc.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, aliases, 2)
+	require.Equal(t, "fast", aliases[0].Name)
+
+	require.NoError(t, svc.Delete(ctx, "fast"))
+	_, ok = svc.Resolve("", "fast")
+	require.False(t, ok)
+	require.ErrorIs(t, svc.Delete(ctx, "fast"), ErrNotFound)
+	_, err = svc.Get(ctx, "fast")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestService_UpsertValidates(t *testing.T) {
+	svc := NewService(NewMemoryStore())
+	cases := map[string]Alias{
+		"missing name":     {Target: "gpt-4o"},
+		"missing target":   {Name: "fast"},
+		"empty mapping":    {Name: "fast", Providers: map[string]string{"anthropic": " "}},
+		"missing provider": {Name: "fast", Providers: map[string]string{"": "gpt-4o"}},
+	}
+	for name, alias := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := svc.Upsert(context.Background(), alias)
+			require.ErrorIs(t, err, ErrInvalidInput)
+		})
+	}
+}
+
+func TestService_BackgroundSyncReloadsOnEvent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewMemoryStore()
+	bus := rules.NewMemoryEventBus()
+	writer := NewService(store, WithEventBus(bus))
+	reader := NewService(store, WithEventBus(bus))
+	reader.StartBackgroundSync(ctx)
+
+	_, err := writer.Upsert(ctx, Alias{Name: "gpt-4", Target: "gpt-4o-2024-08-06"})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		model, ok := reader.Resolve("", "gpt-4")
+		return ok && model == "gpt-4o-2024-08-06"
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,99 @@
+package modelalias
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DBStore 基于 gorm 持久化别名，多实例共享同一张表。
+type DBStore struct {
+	db *gorm.DB
+}
+
+// NewDBStore 创建数据库存储。
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// AutoMigrate 创建或更新别名表。
+func (s *DBStore) AutoMigrate(ctx context.Context) error {
+	return s.db.WithContext(ctx).AutoMigrate(&Alias{})
+}
+
+func (s *DBStore) List(ctx context.Context) ([]Alias, error) {
+	var aliases []Alias
+	if err := s.db.WithContext(ctx).Order("name").Find(&aliases).Error; err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+func (s *DBStore) Upsert(ctx context.Context, alias Alias) (Alias, error) {
+	alias.UpdatedAt = time.Now().UTC()
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"target", "providers", "updated_at"}),
+	}).Create(&alias).Error
+	if err != nil {
+		return Alias{}, err
+	}
+	return alias, nil
+}
+
+func (s *DBStore) Delete(ctx context.Context, name string) error {
+	result := s.db.WithContext(ctx).Delete(&Alias{}, "name = ?", name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return nil
+}
+
+// MemoryStore 在进程内保存别名，适用于未配置数据库的单实例部署，重启后清空。
+type MemoryStore struct {
+	mu      sync.RWMutex
+	aliases map[string]Alias
+}
+
+// NewMemoryStore 创建内存存储。
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{aliases: map[string]Alias{}}
+}
+
+func (s *MemoryStore) List(context.Context) ([]Alias, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	aliases := make([]Alias, 0, len(s.aliases))
+	for _, alias := range s.aliases {
+		alias.Providers = maps.Clone(alias.Providers)
+		aliases = append(aliases, alias)
+	}
+	return aliases, nil
+}
+
+func (s *MemoryStore) Upsert(_ context.Context, alias Alias) (Alias, error) {
+	alias.UpdatedAt = time.Now().UTC()
+	alias.Providers = maps.Clone(alias.Providers)
+	s.mu.Lock()
+	s.aliases[alias.Name] = alias
+	s.mu.Unlock()
+	return alias, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.aliases[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	delete(s.aliases, name)
+	return nil
+}
@@ -0,0 +1,200 @@
+// Package moderation 调用外部内容审核服务检查提示词与模型输出，供代理的 moderation 规则动作使用。
+//
+// provider 为 openai 时按 OpenAI Moderation API 调用：请求 {"input", "model"}，响应 {"results": [{"flagged", "categories": {...}}]}。
+// provider 为 webhook 时向自定义端点 POST {"stage", "input", "rule_id", "user_id"}，端点返回 {"flagged": bool, "categories": [...]}，
+// 非 2xx 响应视为审核失败。
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// DefaultOpenAIURL 是未配置 url 时使用的 OpenAI Moderation 端点。
+const DefaultOpenAIURL = "https://api.openai.com/v1/moderations"
+
+const defaultTimeout = 5 * time.Second
+
+// maxResponseBytes 限制读取的审核响应大小。
+const maxResponseBytes = 1 << 20
+
+// ErrUnavailable 表示审核服务调用失败或返回了无法解析的结果。
+var ErrUnavailable = errors.New("moderation unavailable")
+
+// Request 是一次审核请求。
+type Request struct {
+	Stage  string `json:"stage"`
+	Input  string `json:"input"`
+	RuleID string `json:"rule_id,omitempty"`
+	UserID string `json:"user_id,omitempty"`
+}
+
+// Verdict 是审核结果，Categories 按名称排序。
+type Verdict struct {
+	Flagged    bool
+	Categories []string
+}
+
+// Moderator 执行内容审核。
+type Moderator interface {
+	Moderate(ctx context.Context, req Request) (Verdict, error)
+}
+
+// HTTPModerator 通过 HTTP 调用 OpenAI 或自定义审核端点。
+type HTTPModerator struct {
+	client  *http.Client
+	webhook bool
+	url     string
+	apiKey  string
+	model   string
+	timeout time.Duration
+}
+
+// New 按 moderation 配置创建审核客户端，apiKey 为已解析的密钥；client 为 nil 时使用 http.DefaultClient。
+func New(action rules.ModerationAction, apiKey string, client *http.Client) *HTTPModerator {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	m := &HTTPModerator{
+		client:  client,
+		webhook: action.Provider == rules.ModerationWebhook,
+		url:     action.URL,
+		apiKey:  apiKey,
+		model:   action.Model,
+		timeout: time.Duration(action.TimeoutMS) * time.Millisecond,
+	}
+	if m.url == "" {
+		m.url = DefaultOpenAIURL
+	}
+	if m.timeout <= 0 {
+		m.timeout = defaultTimeout
+	}
+	return m
+}
+
+// Moderate 调用审核服务，失败时返回包装 ErrUnavailable 的错误。
+func (m *HTTPModerator) Moderate(ctx context.Context, req Request) (Verdict, error) {
+	var payload any = req
+	if !m.webhook {
+		openai := map[string]string{"input": req.Input}
+		if m.model != "" {
+			openai["model"] = m.model
+		}
+		payload = openai
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(body))
+	if err != nil {
+		return Verdict{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if m.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+	resp, err := m.client.Do(httpReq)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return Verdict{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Verdict{}, fmt.Errorf("%w: status %d", ErrUnavailable, resp.StatusCode)
+	}
+	if m.webhook {
+		return parseWebhook(data)
+	}
+	return parseOpenAI(data)
+}
+
+func parseWebhook(data []byte) (Verdict, error) {
+	var result struct {
+		Flagged    *bool    `json:"flagged"`
+		Categories []string `json:"categories"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil || result.Flagged == nil {
+		return Verdict{}, fmt.Errorf("%w: malformed webhook response", ErrUnavailable)
+	}
+	verdict := Verdict{Flagged: *result.Flagged, Categories: result.Categories}
+	slices.Sort(verdict.Categories)
+	return verdict, nil
+}
+
+func parseOpenAI(data []byte) (Verdict, error) {
+	var result struct {
+		Results []struct {
+			Flagged    bool            `json:"flagged"`
+			Categories map[string]bool `json:"categories"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil || len(result.Results) == 0 {
+		return Verdict{}, fmt.Errorf("%w: malformed moderation response", ErrUnavailable)
+	}
+	var verdict Verdict
+	for _, item := range result.Results {
+		verdict.Flagged = verdict.Flagged || item.Flagged
+		for category, hit := range item.Categories {
+			if hit && !slices.Contains(verdict.Categories, category) {
+				verdict.Categories = append(verdict.Categories, category)
+			}
+		}
+	}
+	slices.Sort(verdict.Categories)
+	return verdict, nil
+}
+
+// textKeys 是承载提示词或模型输出文本的 JSON 字段，覆盖 OpenAI、Anthropic 与 Gemini 的请求与响应格式。
+var textKeys = map[string]bool{"content": true, "text": true, "prompt": true, "input": true, "system": true}
+
+// ExtractText 从 JSON 请求体或响应体中提取需要审核的文本，以换行拼接（数组保持原有顺序）；
+// body 不是合法 JSON 时返回空字符串。
+func ExtractText(body []byte) string {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return ""
+	}
+	var parts []string
+	collectText(doc, false, &parts)
+	return strings.Join(parts, "\n")
+}
+
+// collectText 收集 textKeys 字段下的字符串：数组继承父字段的判断，对象内的每个字段重新判断，
+// 因此内容块中的 type、image_url 等字段不会被收集。
+func collectText(value any, take bool, parts *[]string) {
+	switch typed := value.(type) {
+	case string:
+		if take && typed != "" {
+			*parts = append(*parts, typed)
+		}
+	case []any:
+		for _, child := range typed {
+			collectText(child, take, parts)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(typed))
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			collectText(typed[key], textKeys[key], parts)
+		}
+	}
+}
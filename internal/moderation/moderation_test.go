@@ -0,0 +1,60 @@
+package moderation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+func TestHTTPModerator_OpenAI(t *testing.T) {
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer sk-mod", r.Header.Get("Authorization"))
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		_, _ = w.Write([]byte(`{"results":[{"flagged":true,"categories":{"violence":true,"hate":true,"sexual":false}}]}`))
+	}))
+	defer server.Close()
+
+	moderator := New(rules.ModerationAction{URL: server.URL, Model: "omni-moderation-latest"}, "sk-mod", nil)
+	verdict, err := moderator.Moderate(t.Context(), Request{Stage: rules.ModerationStagePrompt, Input: "text", RuleID: "r1"})
+	require.NoError(t, err)
+	require.Equal(t, Verdict{Flagged: true, Categories: []string{"hate", "violence"}}, verdict)
+	require.Equal(t, map[string]string{"input": "text", "model": "omni-moderation-latest"}, received)
+}
+
+func TestHTTPModerator_WebhookErrors(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		require.Equal(t, Request{Stage: rules.ModerationStageCompletion, Input: "reply", RuleID: "r1", UserID: "u1"}, req)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"flagged":false}`))
+	}))
+	defer server.Close()
+
+	moderator := New(rules.ModerationAction{Provider: rules.ModerationWebhook, URL: server.URL}, "", nil)
+	req := Request{Stage: rules.ModerationStageCompletion, Input: "reply", RuleID: "r1", UserID: "u1"}
+	verdict, err := moderator.Moderate(t.Context(), req)
+	require.NoError(t, err)
+	require.False(t, verdict.Flagged)
+
+	status = http.StatusInternalServerError
+	_, err = moderator.Moderate(t.Context(), req)
+	require.ErrorIs(t, err, ErrUnavailable)
+}
+
+func TestExtractText(t *testing.T) {
+	request := `{"model":"gpt-4o","system":"be nice","messages":[{"role":"user","content":[{"type":"text","text":"hello"},` +
+		`{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]},{"role":"assistant","content":"hi there"}]}`
+	require.Equal(t, "hello\nhi there\nbe nice", ExtractText([]byte(request)))
+
+	response := `{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"answer"}}],"usage":{"prompt_tokens":3}}`
+	require.Equal(t, "answer", ExtractText([]byte(response)))
+	require.Empty(t, ExtractText([]byte("not json")))
+}
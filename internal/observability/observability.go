@@ -0,0 +1,317 @@
+// Package observability 生成与网关指标名称、标签一致的 Grafana 面板与 Prometheus 告警规则。
+// 指标全名由调用方提供的函数拼接（通常为 metrics.Name），因此修改 METRICS_NAMESPACE / METRICS_SUBSYSTEM
+// 后生成的查询会随之变化，无需手工替换面板与规则中的指标名。
+package observability
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Bundle 是一次生成的全部产物：可直接导入 Grafana 的面板与可写入 rule_files 的告警规则。
+type Bundle struct {
+	Dashboards []Dashboard `json:"dashboards"`
+	AlertRules RuleFile    `json:"alert_rules"`
+}
+
+// Dashboard 是 Grafana 面板 JSON 模型的子集，导入时通过 datasource 变量选择 Prometheus 数据源。
+type Dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Version       int        `json:"version"`
+	Refresh       string     `json:"refresh"`
+	Time          TimeRange  `json:"time"`
+	Templating    Templating `json:"templating"`
+	Panels        []Panel    `json:"panels"`
+}
+
+// TimeRange 是面板的默认时间范围。
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Templating 是面板变量列表。
+type Templating struct {
+	List []Variable `json:"list"`
+}
+
+// Variable 是面板变量，这里只用于选择数据源。
+type Variable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+// Panel 是一个时间序列面板。
+type Panel struct {
+	ID          int           `json:"id"`
+	Type        string        `json:"type"`
+	Title       string        `json:"title"`
+	Description string        `json:"description,omitempty"`
+	Datasource  DataSourceRef `json:"datasource"`
+	GridPos     GridPos       `json:"gridPos"`
+	FieldConfig FieldConfig   `json:"fieldConfig"`
+	Targets     []Target      `json:"targets"`
+}
+
+// DataSourceRef 引用数据源。
+type DataSourceRef struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+// GridPos 是面板在 24 列网格中的位置与大小。
+type GridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// FieldConfig 设置面板数值的单位。
+type FieldConfig struct {
+	Defaults FieldDefaults `json:"defaults"`
+}
+
+// FieldDefaults 是字段的默认显示设置。
+type FieldDefaults struct {
+	Unit string `json:"unit,omitempty"`
+}
+
+// Target 是面板中的一条 PromQL 查询。
+type Target struct {
+	RefID        string        `json:"refId"`
+	Datasource   DataSourceRef `json:"datasource"`
+	Expr         string        `json:"expr"`
+	LegendFormat string        `json:"legendFormat"`
+}
+
+// RuleFile 是 Prometheus 规则文件；JSON 是 YAML 的子集，可直接保存为 rule_files 引用的文件。
+type RuleFile struct {
+	Groups []RuleGroup `json:"groups"`
+}
+
+// RuleGroup 是一组告警规则。
+type RuleGroup struct {
+	Name  string      `json:"name"`
+	Rules []AlertRule `json:"rules"`
+}
+
+// AlertRule 是一条告警规则。
+type AlertRule struct {
+	Alert       string            `json:"alert"`
+	Expr        string            `json:"expr"`
+	For         string            `json:"for,omitempty"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// 默认 SLO 目标，与 deploy/prometheus-slo-rules.yml 保持一致。
+const (
+	availabilityTarget = 0.999
+	latencyTarget      = 0.99
+)
+
+// datasource 引用面板变量 ${datasource}，导入时由用户选择实际的 Prometheus 数据源。
+var datasource = DataSourceRef{Type: "prometheus", UID: "${datasource}"}
+
+// Generate 生成面板与告警规则。name 把指标短名（如 http_requests_total）转换为带前缀的全名。
+func Generate(name func(string) string) Bundle {
+	return Bundle{
+		Dashboards: []Dashboard{overviewDashboard(name), usageDashboard(name)},
+		AlertRules: RuleFile{Groups: []RuleGroup{gatewayAlerts(name), sloAlerts(name)}},
+	}
+}
+
+type panelSpec struct {
+	title       string
+	description string
+	unit        string
+	queries     []Target
+}
+
+func query(expr, legend string) Target {
+	return Target{Expr: expr, LegendFormat: legend}
+}
+
+// dashboard 按每行两个面板排列。
+func dashboard(uid, title string, specs []panelSpec) Dashboard {
+	panels := make([]Panel, len(specs))
+	for i, spec := range specs {
+		targets := make([]Target, len(spec.queries))
+		for j, target := range spec.queries {
+			target.RefID = string(rune('A' + j))
+			target.Datasource = datasource
+			targets[j] = target
+		}
+		panels[i] = Panel{
+			ID:          i + 1,
+			Type:        "timeseries",
+			Title:       spec.title,
+			Description: spec.description,
+			Datasource:  datasource,
+			GridPos:     GridPos{H: 8, W: 12, X: (i % 2) * 12, Y: (i / 2) * 8},
+			FieldConfig: FieldConfig{Defaults: FieldDefaults{Unit: spec.unit}},
+			Targets:     targets,
+		}
+	}
+	return Dashboard{
+		UID:           uid,
+		Title:         title,
+		Tags:          []string{"yapi"},
+		SchemaVersion: 39,
+		Version:       1,
+		Refresh:       "30s",
+		Time:          TimeRange{From: "now-6h", To: "now"},
+		Templating: Templating{List: []Variable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+		}},
+		Panels: panels,
+	}
+}
+
+func overviewDashboard(name func(string) string) Dashboard {
+	requests, duration := name("http_requests_total"), name("http_request_duration_seconds_bucket")
+	upstream, upstreamBucket := name("upstream_latency_seconds_count"), name("upstream_latency_seconds_bucket")
+	slo := name("slo_requests_total")
+	return dashboard("yapi-overview", "YAPI Gateway Overview", []panelSpec{
+		{title: "Request Rate by Status", unit: "reqps", queries: []Target{
+			query(fmt.Sprintf("sum(rate(%s[5m])) by (status)", requests), "{{status}}"),
+		}},
+		{title: "P95 Latency by Route", unit: "s", queries: []Target{
+			query(fmt.Sprintf("histogram_quantile(0.95, sum(rate(%s[5m])) by (le, route))", duration), "{{route}}"),
+		}},
+		{title: "Upstream Error Ratio", unit: "percentunit", queries: []Target{
+			query(fmt.Sprintf(`sum(rate(%s{outcome="error"}[5m])) by (upstream) / sum(rate(%s[5m])) by (upstream)`, upstream, upstream), "{{upstream}}"),
+		}},
+		{title: "Upstream P95 Latency", unit: "s", queries: []Target{
+			query(fmt.Sprintf("histogram_quantile(0.95, sum(rate(%s[5m])) by (le, upstream))", upstreamBucket), "{{upstream}}"),
+		}},
+		{title: "Gateway Errors by Code", description: "Error responses generated by the gateway itself, see docs/error-codes.md.", unit: "reqps", queries: []Target{
+			query(fmt.Sprintf("sum(rate(%s[5m])) by (code)", name("errors_total")), "{{code}}"),
+		}},
+		{title: "Rule Matches", unit: "reqps", queries: []Target{
+			query(fmt.Sprintf("sum(rate(%s[5m])) by (rule)", name("rule_matches_total")), "{{rule}}"),
+		}},
+		{title: "SLO Error Ratio (1h)", unit: "percentunit", queries: []Target{
+			query(sloErrorRatio(slo, "availability", "1h"), "availability {{rule}}"),
+			query(sloErrorRatio(slo, "latency", "1h"), "latency {{rule}}"),
+		}},
+		{title: "Admin Actions", unit: "ops", queries: []Target{
+			query(fmt.Sprintf("sum(rate(%s[5m])) by (action, outcome)", name("admin_actions_total")), "{{action}} {{outcome}}"),
+		}},
+	})
+}
+
+func usageDashboard(name func(string) string) Dashboard {
+	return dashboard("yapi-usage", "YAPI Usage & Upstream Health", []panelSpec{
+		{title: "Tokens by Model", unit: "short", queries: []Target{
+			query(fmt.Sprintf("sum(rate(%s[5m])) by (model, type)", name("tokens_total")), "{{model}} {{type}}"),
+		}},
+		{title: "Estimated Cost by User (1h)", unit: "currencyUSD", queries: []Target{
+			query(fmt.Sprintf("sum(increase(%s[1h])) by (user)", name("cost_usd_total")), "{{user}}"),
+		}},
+		{title: "Endpoint Health Score", unit: "percentunit", queries: []Target{
+			query(name("upstream_endpoint_score"), "{{endpoint}}"),
+		}},
+		{title: "Endpoint EWMA Latency", unit: "s", queries: []Target{
+			query(name("upstream_endpoint_latency_ewma_seconds"), "{{endpoint}}"),
+		}},
+		{title: "Analytics Events", unit: "ops", queries: []Target{
+			query(fmt.Sprintf("sum(rate(%s[5m])) by (outcome)", name("analytics_events_total")), "{{outcome}}"),
+		}},
+		{title: "Admin Login Failures", unit: "short", queries: []Target{
+			query(fmt.Sprintf("sum(increase(%s[15m])) by (reason)", name("admin_login_failures_total")), "{{reason}}"),
+		}},
+	})
+}
+
+func gatewayAlerts(name func(string) string) RuleGroup {
+	requests, upstream := name("http_requests_total"), name("upstream_latency_seconds_count")
+	errors := name("errors_total")
+	return RuleGroup{Name: "yapi-gateway", Rules: []AlertRule{
+		alert("YAPIHighServerErrorRate", "ticket", "10m",
+			fmt.Sprintf(`sum(rate(%s{status=~"5.."}[5m])) / sum(rate(%s[5m])) > 0.05`, requests, requests),
+			"More than 5% of gateway responses are 5xx."),
+		alert("YAPIHighLatency", "ticket", "10m",
+			fmt.Sprintf("histogram_quantile(0.95, sum(rate(%s[5m])) by (le)) > 1", name("http_request_duration_seconds_bucket")),
+			"Gateway P95 latency is above 1s."),
+		alert("YAPIUpstreamErrorRate", "page", "5m",
+			fmt.Sprintf(`sum(rate(%s{outcome="error"}[5m])) by (upstream) / sum(rate(%s[5m])) by (upstream) > 0.1`, upstream, upstream),
+			"Upstream {{ $labels.upstream }} error ratio is above 10%."),
+		alert("YAPIUpstreamTimeouts", "ticket", "5m",
+			fmt.Sprintf(`sum(rate(%s{code="YAPI_UPSTREAM_TIMEOUT"}[5m])) > 1`, errors),
+			"Upstream requests are timing out (YAPI_UPSTREAM_TIMEOUT)."),
+		alert("YAPINoMatchingUpstream", "ticket", "5m",
+			fmt.Sprintf(`sum(rate(%s{code="YAPI_NO_MATCHING_UPSTREAM"}[5m])) > 0`, errors),
+			"Requests are rejected because no upstream credential satisfies the metadata constraints."),
+		alert("YAPIAnalyticsEventsDropped", "ticket", "15m",
+			fmt.Sprintf(`sum(rate(%s{outcome=~"dropped|failed"}[10m])) > 0`, name("analytics_events_total")),
+			"Analytics events are being dropped or failing to write."),
+		alert("YAPIAdminLoginLockouts", "ticket", "",
+			fmt.Sprintf("sum(increase(%s[15m])) > 5", name("admin_login_lockouts_total")),
+			"Repeated admin login lockouts, possible brute-force attempt."),
+	}}
+}
+
+// burnWindow 是一组多窗口燃烧率告警：长短两个窗口的错误比例都超过 factor 倍错误预算时触发。
+type burnWindow struct {
+	long, short string
+	factor      float64
+	severity    string
+	forDuration string
+}
+
+var burnWindows = []burnWindow{
+	{long: "1h", short: "5m", factor: 14.4, severity: "page", forDuration: "2m"},
+	{long: "6h", short: "30m", factor: 6, severity: "page", forDuration: "15m"},
+	{long: "1d", short: "2h", factor: 3, severity: "ticket", forDuration: "1h"},
+}
+
+func sloAlerts(name func(string) string) RuleGroup {
+	slo := name("slo_requests_total")
+	group := RuleGroup{Name: "yapi-slo"}
+	for _, objective := range []struct {
+		slo, title string
+		target     float64
+	}{
+		{slo: "availability", title: "Availability", target: availabilityTarget},
+		{slo: "latency", title: "Latency", target: latencyTarget},
+	} {
+		for _, window := range burnWindows {
+			threshold := formatFloat(window.factor * (1 - objective.target))
+			group.Rules = append(group.Rules, alert(
+				fmt.Sprintf("YAPI%sBurnRate%s", objective.title, window.long), window.severity, window.forDuration,
+				fmt.Sprintf("%s > %s and %s > %s",
+					sloErrorRatio(slo, objective.slo, window.long), threshold,
+					sloErrorRatio(slo, objective.slo, window.short), threshold),
+				fmt.Sprintf("Rule {{ $labels.rule }} is burning its %s error budget at %sx over %s.", objective.slo, formatFloat(window.factor), window.long),
+			))
+		}
+	}
+	return group
+}
+
+func sloErrorRatio(metric, slo, window string) string {
+	return fmt.Sprintf(`(sum(rate(%s{slo="%s",outcome="bad"}[%s])) by (rule) / sum(rate(%s{slo="%s"}[%s])) by (rule))`,
+		metric, slo, window, metric, slo, window)
+}
+
+func alert(name, severity, forDuration, expr, summary string) AlertRule {
+	return AlertRule{
+		Alert:       name,
+		Expr:        expr,
+		For:         forDuration,
+		Labels:      map[string]string{"severity": severity, "service": "yapi"},
+		Annotations: map[string]string{"summary": summary},
+	}
+}
+
+// formatFloat 输出最短的十进制表示，避免 14.4*0.01 之类的浮点误差出现在表达式中。
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', 6, 64)
+}
@@ -0,0 +1,73 @@
+package observability
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func prefixed(prefix string) func(string) string {
+	return func(name string) string { return prefix + name }
+}
+
+func TestGenerate_UsesConfiguredMetricNames(t *testing.T) {
+	bundle := Generate(prefixed("yapi_edge_"))
+
+	var exprs []string
+	for _, dashboard := range bundle.Dashboards {
+		ids := map[int]bool{}
+		for _, panel := range dashboard.Panels {
+			require.False(t, ids[panel.ID], "duplicate panel id %d in %s", panel.ID, dashboard.UID)
+			ids[panel.ID] = true
+			require.NotEmpty(t, panel.Targets, panel.Title)
+			for _, target := range panel.Targets {
+				require.Equal(t, "${datasource}", target.Datasource.UID)
+				exprs = append(exprs, target.Expr)
+			}
+		}
+	}
+	for _, group := range bundle.AlertRules.Groups {
+		for _, rule := range group.Rules {
+			require.NotEmpty(t, rule.Labels["severity"], rule.Alert)
+			exprs = append(exprs, rule.Expr)
+		}
+	}
+	require.NotEmpty(t, exprs)
+	for _, expr := range exprs {
+		require.Contains(t, expr, "yapi_edge_")
+		require.NotContains(t, expr, "gateway_")
+	}
+}
+
+func TestGenerate_SLOBurnRateThresholds(t *testing.T) {
+	bundle := Generate(prefixed("gateway_"))
+
+	rules := map[string]AlertRule{}
+	for _, group := range bundle.AlertRules.Groups {
+		for _, rule := range group.Rules {
+			rules[rule.Alert] = rule
+		}
+	}
+	fast := rules["YAPIAvailabilityBurnRate1h"]
+	require.Equal(t, "page", fast.Labels["severity"])
+	require.Contains(t, fast.Expr, `gateway_slo_requests_total{slo="availability",outcome="bad"}[1h]`)
+	require.Contains(t, fast.Expr, `[5m]`)
+	require.Equal(t, 2, strings.Count(fast.Expr, "> 0.0144"))
+	require.Contains(t, rules["YAPILatencyBurnRate1h"].Expr, "> 0.144 and")
+	require.Equal(t, "ticket", rules["YAPILatencyBurnRate1d"].Labels["severity"])
+}
+
+func TestGenerate_MarshalsGrafanaModel(t *testing.T) {
+	raw, err := json.Marshal(Generate(prefixed("gateway_")).Dashboards[0])
+	require.NoError(t, err)
+	var dashboard map[string]any
+	require.NoError(t, json.Unmarshal(raw, &dashboard))
+	require.Equal(t, "yapi-overview", dashboard["uid"])
+	require.EqualValues(t, 39, dashboard["schemaVersion"])
+	panel := dashboard["panels"].([]any)[1].(map[string]any)
+	require.Equal(t, map[string]any{"h": float64(8), "w": float64(12), "x": float64(12), "y": float64(0)}, panel["gridPos"])
+	target := panel["targets"].([]any)[0].(map[string]any)
+	require.Equal(t, "A", target["refId"])
+}
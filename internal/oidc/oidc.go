@@ -0,0 +1,410 @@
+// Package oidc 实现管理端的 OpenID Connect 授权码登录：发现配置、换取并校验 ID Token，
+// 再按用户组映射为管理端角色。仅依赖标准库与 golang-jwt，适配 Okta、Keycloak、Google 等提供方。
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/prehisle/yapi/internal/adminusers"
+)
+
+var (
+	// ErrInvalidToken 表示 ID Token 校验失败。
+	ErrInvalidToken = errors.New("invalid id token")
+	// ErrNoRole 表示用户所在的组均未映射到管理端角色。
+	ErrNoRole = errors.New("no admin role mapped for user")
+	// ErrInvalidConfig 表示 OIDC 配置不完整或角色映射无法解析。
+	ErrInvalidConfig = errors.New("invalid oidc config")
+)
+
+// WildcardGroup 是角色映射中匹配任意已登录用户的键，适用于不下发组信息的提供方（如 Google）。
+const WildcardGroup = "*"
+
+// Config 描述 OIDC 客户端配置。
+type Config struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// GroupsClaim 是 ID Token 中携带用户组的声明名，默认 groups。
+	GroupsClaim string
+	// RoleMapping 将用户组映射为管理端角色，用户属于多个组时取最高角色。
+	RoleMapping map[string]adminusers.Role
+}
+
+// Claims 是从 ID Token 中提取的用户信息。
+type Claims struct {
+	Subject string
+	Email   string
+	Name    string
+	Groups  []string
+}
+
+// Username 返回用于令牌与审计的用户名：优先使用邮箱，缺失时使用 sub。
+func (c Claims) Username() string {
+	if c.Email != "" {
+		return "oidc:" + c.Email
+	}
+	return "oidc:" + c.Subject
+}
+
+// Provider 是一个 OIDC 提供方，发现文档与签名公钥在首次使用时拉取并缓存。
+type Provider struct {
+	cfg    Config
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      map[string]any
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Option 配置 Provider。
+type Option func(*Provider)
+
+// WithHTTPClient 替换访问提供方使用的 HTTP 客户端。
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *Provider) {
+		if client != nil {
+			p.client = client
+		}
+	}
+}
+
+// NewProvider 创建 OIDC 提供方，校验必填配置但不访问网络。
+func NewProvider(cfg Config, opts ...Option) (*Provider, error) {
+	cfg.IssuerURL = strings.TrimRight(strings.TrimSpace(cfg.IssuerURL), "/")
+	if cfg.IssuerURL == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("%w: issuer url, client id and redirect url are required", ErrInvalidConfig)
+	}
+	if len(cfg.RoleMapping) == 0 {
+		return nil, fmt.Errorf("%w: role mapping is empty", ErrInvalidConfig)
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	if !slices.Contains(cfg.Scopes, "openid") {
+		cfg.Scopes = append([]string{"openid"}, cfg.Scopes...)
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	p := &Provider{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}, now: time.Now}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// AuthCodeURL 返回跳转到提供方登录页的地址，verifier 为 PKCE 的 code_verifier，以 S256 方式派生 code_challenge。
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return doc.AuthorizationEndpoint + sep + query.Encode(), nil
+}
+
+// Exchange 用授权码与 PKCE verifier 换取 ID Token 并完成校验，nonce 与 verifier 必须与发起登录时一致。
+func (p *Provider) Exchange(ctx context.Context, code, nonce, verifier string) (Claims, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	var payload struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := p.doJSON(req, &payload); err != nil {
+		return Claims{}, fmt.Errorf("oidc token exchange failed: %w", err)
+	}
+	if payload.IDToken == "" {
+		return Claims{}, fmt.Errorf("%w: token response has no id_token", ErrInvalidToken)
+	}
+	return p.verify(ctx, doc, payload.IDToken, nonce)
+}
+
+// CodeChallenge 按 RFC 7636 的 S256 方式由 code_verifier 派生 code_challenge。
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// ResolveRole 按角色映射返回用户的最高角色，无匹配时返回 ErrNoRole。
+func (p *Provider) ResolveRole(claims Claims) (adminusers.Role, error) {
+	var best adminusers.Role
+	consider := func(group string) {
+		if role, ok := p.cfg.RoleMapping[group]; ok && (best == "" || role.Allows(best)) {
+			best = role
+		}
+	}
+	consider(WildcardGroup)
+	for _, group := range claims.Groups {
+		consider(group)
+	}
+	if best == "" {
+		return "", ErrNoRole
+	}
+	return best, nil
+}
+
+func (p *Provider) verify(ctx context.Context, doc discoveryDocument, raw, nonce string) (Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, doc, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(p.now),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if got, _ := claims["nonce"].(string); nonce == "" || got != nonce {
+		return Claims{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+	out := Claims{}
+	out.Subject, _ = claims["sub"].(string)
+	out.Email, _ = claims["email"].(string)
+	out.Name, _ = claims["name"].(string)
+	if out.Subject == "" {
+		return Claims{}, fmt.Errorf("%w: missing sub", ErrInvalidToken)
+	}
+	switch groups := claims[p.cfg.GroupsClaim].(type) {
+	case []any:
+		for _, group := range groups {
+			if value, ok := group.(string); ok {
+				out.Groups = append(out.Groups, value)
+			}
+		}
+	case string:
+		out.Groups = []string{groups}
+	}
+	return out, nil
+}
+
+func (p *Provider) discover(ctx context.Context) (discoveryDocument, error) {
+	p.mu.Lock()
+	cached := p.discovery
+	p.mu.Unlock()
+	if cached != nil {
+		return *cached, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.IssuerURL+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return discoveryDocument{}, err
+	}
+	var doc discoveryDocument
+	if err := p.doJSON(req, &doc); err != nil {
+		return discoveryDocument{}, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return discoveryDocument{}, fmt.Errorf("oidc discovery failed: incomplete document")
+	}
+	if strings.TrimRight(doc.Issuer, "/") != p.cfg.IssuerURL {
+		return discoveryDocument{}, fmt.Errorf("oidc discovery failed: issuer %q does not match %q", doc.Issuer, p.cfg.IssuerURL)
+	}
+	p.mu.Lock()
+	p.discovery = &doc
+	p.mu.Unlock()
+	return doc, nil
+}
+
+// key 返回 kid 对应的公钥；缓存中不存在时重新拉取 JWKS，以便提供方轮换密钥后无需重启。
+func (p *Provider) key(ctx context.Context, doc discoveryDocument, kid string) (any, error) {
+	p.mu.Lock()
+	key, ok := p.lookupKey(kid)
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	keys, err := p.fetchKeys(ctx, doc.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = keys
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("signing key %q not found", kid)
+}
+
+// lookupKey 需持有 p.mu。未指定 kid 且只有一把公钥时直接使用该公钥。
+func (p *Provider) lookupKey(kid string) (any, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (p *Provider) fetchKeys(ctx context.Context, jwksURI string) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.doJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("oidc jwks fetch failed: %w", err)
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
+
+func (p *Provider) doJSON(req *http.Request, out any) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with status %d", req.URL.Host, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ParseRoleMapping 解析形如 "admins=owner,ops=editor,*=viewer" 的组到角色映射。
+func ParseRoleMapping(raw string) (map[string]adminusers.Role, error) {
+	mapping := make(map[string]adminusers.Role)
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(part, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" || !adminusers.Role(role).Valid() {
+			return nil, fmt.Errorf("%w: invalid role mapping entry %q", ErrInvalidConfig, part)
+		}
+		mapping[group] = adminusers.Role(role)
+	}
+	return mapping, nil
+}
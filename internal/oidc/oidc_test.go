@@ -0,0 +1,150 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/internal/adminusers"
+)
+
+// fakeIssuer 模拟一个最小的 OIDC 提供方：发现文档、JWKS 与授权码换取 ID Token。
+// challenge 非空时换取令牌须携带与之匹配的 PKCE code_verifier。
+type fakeIssuer struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	claims    jwt.MapClaims
+	challenge string
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	f := &fakeIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.server.URL,
+			"authorization_endpoint": f.server.URL + "/authorize",
+			"token_endpoint":         f.server.URL + "/token",
+			"jwks_uri":               f.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "yapi" || pass != "client-secret" || r.FormValue("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if f.challenge != "" && CodeChallenge(r.FormValue("code_verifier")) != f.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, f.claims)
+		token.Header["kid"] = "k1"
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "access_token": "opaque"})
+	})
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeIssuer) provider(t *testing.T, mapping map[string]adminusers.Role) *Provider {
+	t.Helper()
+	provider, err := NewProvider(Config{
+		IssuerURL:    f.server.URL,
+		ClientID:     "yapi",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://gateway.example.com/admin/v1/oidc/callback",
+		RoleMapping:  mapping,
+	})
+	require.NoError(t, err)
+	return provider
+}
+
+func (f *fakeIssuer) setClaims(nonce string, groups ...string) {
+	f.claims = jwt.MapClaims{
+		"iss":    f.server.URL,
+		"aud":    "yapi",
+		"sub":    "user-1",
+		"email":  "alice@example.com",
+		"nonce":  nonce,
+		"groups": groups,
+		"exp":    time.Now().Add(time.Minute).Unix(),
+	}
+}
+
+func TestProvider_ExchangeAndResolveRole(t *testing.T) {
+	ctx := context.Background()
+	issuer := newFakeIssuer(t)
+	provider := issuer.provider(t, map[string]adminusers.Role{"ops": adminusers.RoleEditor, "admins": adminusers.RoleOwner})
+
+	target, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-1")
+	require.NoError(t, err)
+	parsed, err := url.Parse(target)
+	require.NoError(t, err)
+	require.Equal(t, "/authorize", parsed.Path)
+	require.Equal(t, "openid email profile", parsed.Query().Get("scope"))
+	require.Equal(t, "nonce-1", parsed.Query().Get("nonce"))
+	require.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+	require.Equal(t, CodeChallenge("verifier-1"), parsed.Query().Get("code_challenge"))
+	require.NotContains(t, target, "verifier-1")
+	issuer.challenge = parsed.Query().Get("code_challenge")
+
+	issuer.setClaims("nonce-1", "ops", "admins", "unrelated")
+	_, err = provider.Exchange(ctx, "good-code", "nonce-1", "stolen-code-without-verifier")
+	require.Error(t, err)
+	claims, err := provider.Exchange(ctx, "good-code", "nonce-1", "verifier-1")
+	require.NoError(t, err)
+	require.Equal(t, "oidc:alice@example.com", claims.Username())
+	role, err := provider.ResolveRole(claims)
+	require.NoError(t, err)
+	require.Equal(t, adminusers.RoleOwner, role)
+
+	_, err = provider.Exchange(ctx, "good-code", "other-nonce", "verifier-1")
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	issuer.claims["aud"] = "someone-else"
+	_, err = provider.Exchange(ctx, "good-code", "nonce-1", "verifier-1")
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = provider.ResolveRole(Claims{Groups: []string{"unrelated"}})
+	require.ErrorIs(t, err, ErrNoRole)
+}
+
+func TestParseRoleMapping(t *testing.T) {
+	mapping, err := ParseRoleMapping("admins=owner, ops = editor,*=viewer")
+	require.NoError(t, err)
+	require.Equal(t, map[string]adminusers.Role{
+		"admins":      adminusers.RoleOwner,
+		"ops":         adminusers.RoleEditor,
+		WildcardGroup: adminusers.RoleViewer,
+	}, mapping)
+
+	_, err = ParseRoleMapping("admins=root")
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	_, err = NewProvider(Config{IssuerURL: "https://idp.example.com", ClientID: "yapi", RedirectURL: "https://gw/cb"})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+}
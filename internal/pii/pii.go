@@ -0,0 +1,171 @@
+// Package pii 检测请求体中的个人敏感信息（邮箱、电话、银行卡号与自定义正则），
+// 供代理的 redact_pii 规则动作在转发前打码或拒绝请求。
+package pii
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// DefaultMask 是未配置 mask 时替换命中内容的文本。
+const DefaultMask = "[REDACTED]"
+
+type detector struct {
+	name    string
+	pattern *regexp.Regexp
+	// valid 进一步校验正则命中的文本，返回 false 时保留原文。
+	valid func(string) bool
+}
+
+// builtins 按执行顺序排列：银行卡号先于电话处理，避免卡号片段被当作电话号码。
+var builtins = []detector{
+	{name: rules.DetectorEmail, pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)},
+	{name: rules.DetectorCreditCard, pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), valid: luhnValid},
+	{name: rules.DetectorPhone, pattern: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{2,4}\)[ .-]?|\b\d{2,4}[ .-])\d{3,4}[ .-]?\d{4}\b|\b1[3-9]\d{9}\b`)},
+}
+
+// compiled 缓存自定义正则的编译结果，规则在每次请求时都会重新构建 Filter。
+var compiled sync.Map
+
+// Hits 按检测器名称统计命中次数。
+type Hits map[string]int
+
+// Total 返回全部检测器的命中次数之和。
+func (h Hits) Total() int {
+	total := 0
+	for _, n := range h {
+		total += n
+	}
+	return total
+}
+
+// Filter 按规则配置的检测器扫描并打码文本。
+type Filter struct {
+	detectors []detector
+	mask      string
+}
+
+// New 按 redact_pii 配置创建 Filter，自定义正则按名称排序执行。
+func New(action rules.RedactionAction) (*Filter, error) {
+	f := &Filter{mask: action.Mask}
+	if f.mask == "" {
+		f.mask = DefaultMask
+	}
+	for _, builtin := range builtins {
+		if slices.Contains(action.Detectors, builtin.name) {
+			f.detectors = append(f.detectors, builtin)
+		}
+	}
+	names := make([]string, 0, len(action.Patterns))
+	for name := range action.Patterns {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		pattern, err := compile(action.Patterns[name])
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", name, err)
+		}
+		f.detectors = append(f.detectors, detector{name: name, pattern: pattern})
+	}
+	return f, nil
+}
+
+func compile(expr string) (*regexp.Regexp, error) {
+	if cached, ok := compiled.Load(expr); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	compiled.Store(expr, pattern)
+	return pattern, nil
+}
+
+// Text 打码文本中的命中内容，并把命中次数累加到 hits。
+func (f *Filter) Text(text string, hits Hits) string {
+	for _, d := range f.detectors {
+		text = d.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if d.valid != nil && !d.valid(match) {
+				return match
+			}
+			hits[d.name]++
+			return f.mask
+		})
+	}
+	return text
+}
+
+// JSON 扫描 JSON 文档中的全部字符串取值（不含对象键），返回打码后的文档与命中统计；
+// 没有命中时原样返回 body。body 不是合法 JSON 时按纯文本处理。
+func (f *Filter) JSON(body []byte) ([]byte, Hits, error) {
+	hits := Hits{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		redacted := f.Text(string(body), hits)
+		if hits.Total() == 0 {
+			return body, hits, nil
+		}
+		return []byte(redacted), hits, nil
+	}
+	doc = f.walk(doc, hits)
+	if hits.Total() == 0 {
+		return body, hits, nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), hits, nil
+}
+
+func (f *Filter) walk(value any, hits Hits) any {
+	switch typed := value.(type) {
+	case string:
+		return f.Text(typed, hits)
+	case map[string]any:
+		for key, child := range typed {
+			typed[key] = f.walk(child, hits)
+		}
+	case []any:
+		for i, child := range typed {
+			typed[i] = f.walk(child, hits)
+		}
+	}
+	return value
+}
+
+// luhnValid 用 Luhn 校验和排除订单号、时间戳等恰好位数相符的数字串。
+func luhnValid(match string) bool {
+	var digits []int
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := digits[i]
+		if (len(digits)-1-i)%2 == 1 {
+			if digit *= 2; digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
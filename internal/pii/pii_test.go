@@ -0,0 +1,43 @@
+package pii
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+func TestFilter_JSONMasksBuiltinsAndPatterns(t *testing.T) {
+	filter, err := New(rules.RedactionAction{
+		Detectors: []string{rules.DetectorEmail, rules.DetectorPhone, rules.DetectorCreditCard},
+		Patterns:  map[string]string{"employee_id": `EMP-\d{6}`},
+	})
+	require.NoError(t, err)
+
+	body := `{"model":"gpt-4o","max_tokens":256,"messages":[{"role":"user","content":` +
+		`"mail bob@example.co.uk or call +1 (555) 123-4567 / 13812345678, card 4111 1111 1111 1111, order 1234567890123, EMP-004211 <b>"}]}`
+	redacted, hits, err := filter.JSON([]byte(body))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"model":"gpt-4o","max_tokens":256,"messages":[{"role":"user","content":`+
+		`"mail [REDACTED] or call [REDACTED] / [REDACTED], card [REDACTED], order 1234567890123, [REDACTED] <b>"}]}`, string(redacted))
+	require.Contains(t, string(redacted), "<b>")
+	require.Equal(t, Hits{"email": 1, "phone": 2, "credit_card": 1, "employee_id": 1}, hits)
+	require.Equal(t, 5, hits.Total())
+}
+
+func TestFilter_JSONLeavesCleanBodyUntouched(t *testing.T) {
+	filter, err := New(rules.RedactionAction{Detectors: []string{rules.DetectorEmail}, Mask: "***"})
+	require.NoError(t, err)
+
+	body := []byte(`{"b":1, "a":"no contact info"}`)
+	redacted, hits, err := filter.JSON(body)
+	require.NoError(t, err)
+	require.Equal(t, body, redacted)
+	require.Zero(t, hits.Total())
+
+	redacted, hits, err = filter.JSON([]byte(`not json: alice@example.com`))
+	require.NoError(t, err)
+	require.Equal(t, "not json: ***", string(redacted))
+	require.Equal(t, 1, hits["email"])
+}
@@ -0,0 +1,253 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/upstreams"
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// affinityKeyContextKey 保存本次请求的会话亲和键，转发成功后据此记录所用的凭据与端点。
+const affinityKeyContextKey = "yapi_affinity_key"
+
+// affinityEndpointContextKey 保存会话上次使用的端点，resolveTarget 优先选择该端点。
+const affinityEndpointContextKey = "yapi_affinity_endpoint"
+
+// affinityContextKey 标记本次请求按会话亲和改用了凭据或端点，供决策轨迹记录。
+const affinityContextKey = "yapi_affinity_pinned"
+
+// defaultAffinityTTL 是未指定 ttl_seconds 时会话固定的有效期。
+const defaultAffinityTTL = time.Hour
+
+// stickySweepInterval 为清理过期粘性记录的写入间隔。
+const stickySweepInterval = 256
+
+// maxStickyRoutes 是每类粘性记录的条目上限，防止客户端不断更换会话 ID 使内存无限增长。
+const maxStickyRoutes = 100000
+
+// stickyRoutes 在内存中保存 smart_route 的粘性选择与会话亲和记录；每个实例独立保存，多实例部署时
+// 只在同一实例内生效。过期条目在写入时定期清理；条目数达到 max 时先清理过期条目，仍然已满则淘汰
+// 任意一批条目（约 max 的 1/16），使清理的开销分摊到之后的写入上。
+type stickyRoutes struct {
+	mu      sync.Mutex
+	entries map[string]stickyRoute
+	max     int
+	writes  int
+	now     func() time.Time
+}
+
+// stickyRoute 是一条粘性记录，endpoint 为端点统计键，只有会话亲和记录端点。
+type stickyRoute struct {
+	credentialID string
+	endpoint     string
+	expires      time.Time
+}
+
+func newStickyRoutes() *stickyRoutes {
+	return &stickyRoutes{entries: make(map[string]stickyRoute), max: maxStickyRoutes, now: time.Now}
+}
+
+// get 返回未过期的粘性记录。
+func (s *stickyRoutes) get(key string) (stickyRoute, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || !s.now().Before(entry.expires) {
+		return stickyRoute{}, false
+	}
+	return entry, true
+}
+
+// set 记录或续期粘性记录。
+func (s *stickyRoutes) set(key string, route stickyRoute, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	route.expires = now.Add(ttl)
+	if _, exists := s.entries[key]; !exists && s.max > 0 && len(s.entries) >= s.max {
+		s.sweep(now)
+		if len(s.entries) >= s.max {
+			s.evict(len(s.entries) - s.max + 1 + s.max/16)
+		}
+	}
+	s.entries[key] = route
+	s.writes++
+	if s.writes%stickySweepInterval == 0 {
+		s.sweep(now)
+	}
+}
+
+// sweep 删除已过期的条目。调用方需持有锁。
+func (s *stickyRoutes) sweep(now time.Time) {
+	for k, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			delete(s.entries, k)
+		}
+	}
+}
+
+// evict 淘汰任意 n 个条目。调用方需持有锁。
+func (s *stickyRoutes) evict(n int) {
+	for k := range s.entries {
+		if n <= 0 {
+			return
+		}
+		delete(s.entries, k)
+		n--
+	}
+}
+
+// conversationID 返回请求的会话 ID：先取请求头（affinity.header，默认 X-Conversation-ID），
+// 再取 JSON 请求体中 affinity.json_field 路径的字符串或数值，都缺失时返回空字符串。
+func conversationID(c *gin.Context, rule rules.Rule, body []byte) string {
+	header, field := rules.DefaultConversationHeader, ""
+	if affinity := rule.Actions.Affinity; affinity != nil {
+		if affinity.Header != "" {
+			header = affinity.Header
+		}
+		field = affinity.JSONField
+	}
+	if id := strings.TrimSpace(c.GetHeader(header)); id != "" {
+		return id
+	}
+	if field == "" || len(body) == 0 {
+		return ""
+	}
+	tokens, err := rules.ParseJSONPath(field)
+	if err != nil {
+		return ""
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return ""
+	}
+	value, _ := lookupJSONPath(doc, tokens)
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+// resolveAffinity 执行 Affinity 动作：按 API Key 与会话 ID 查找上次成功转发所用的凭据与端点。凭据仍可用、仍在该 API Key
+// 的绑定中（直接绑定或所绑定 Key 池的成员）且与当前凭据属于同一服务时改用该凭据，端点记入请求上下文供 resolveTarget 优先选择。
+// 亲和记录按 API Key 区分，同一用户的不同 Key 即使携带相同的会话 ID 也不会借用彼此的凭据。会话亲和是尽力而为的，查找失败时保持当前选择。
+func (h *Handler) resolveAffinity(c *gin.Context, rule rules.Rule) {
+	action := rule.Actions.Affinity
+	if action == nil {
+		return
+	}
+	info, ok := middleware.CurrentUpstreamInfo(c)
+	if !ok {
+		return
+	}
+	binding, ok := middleware.CurrentBinding(c)
+	if !ok || binding.UserAPIKeyID == "" {
+		return
+	}
+	var body []byte
+	if action.JSONField != "" && c.Request.Body != nil && c.Request.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(c.Request.Body); err != nil {
+			return
+		}
+		_ = c.Request.Body.Close()
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	id := conversationID(c, rule, body)
+	if id == "" {
+		return
+	}
+	key := binding.UserAPIKeyID + "\x00" + id
+	c.Set(affinityKeyContextKey, key)
+	pinned, ok := h.affinity.get(key)
+	if !ok {
+		return
+	}
+	c.Set(affinityEndpointContextKey, pinned.endpoint)
+	c.Set(affinityContextKey, true)
+	if pinned.credentialID == info.Credential.ID || h.accountService == nil {
+		return
+	}
+	cred, err := h.accountService.GetUpstreamCredential(c.Request.Context(), pinned.credentialID)
+	if err == nil && cred.UserID == info.Credential.UserID && cred.Service == info.Credential.Service && h.credentialAvailable(cred) {
+		var bound bool
+		if bound, err = h.boundToAPIKey(c, binding.UserAPIKeyID, cred); err == nil && bound {
+			binding.UpstreamKeyID = cred.ID
+			// 直接设置凭据，不再经过 Key 池重新挑选成员。
+			middleware.SetBinding(c, binding, cred)
+			return
+		}
+	}
+	if err != nil && h.logger != nil {
+		h.logger.Warn("load affinity credential failed",
+			"request_id", middleware.RequestIDFromContext(c),
+			"error", err,
+			"rule_id", rule.ID,
+			"credential", pinned.credentialID,
+		)
+	}
+}
+
+// boundToAPIKey 报告凭据是否在 API Key 的绑定中：直接绑定，或是所绑定 Key 池的成员。
+func (h *Handler) boundToAPIKey(c *gin.Context, apiKeyID string, cred accounts.UpstreamCredential) (bool, error) {
+	bindings, err := h.accountService.ListBindingsByAPIKey(c.Request.Context(), apiKeyID)
+	if err != nil {
+		return false, err
+	}
+	for _, item := range bindings {
+		if item.Upstream.ID == cred.ID || (cred.PoolID != "" && item.Upstream.PoolID == cred.PoolID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rememberAffinity 在上游成功响应后记录会话所用的凭据与端点。
+func (h *Handler) rememberAffinity(c *gin.Context, rule rules.Rule, target *url.URL) {
+	key := c.GetString(affinityKeyContextKey)
+	if key == "" {
+		return
+	}
+	info, ok := middleware.CurrentUpstreamInfo(c)
+	if !ok {
+		return
+	}
+	ttl := defaultAffinityTTL
+	if rule.Actions.Affinity != nil && rule.Actions.Affinity.TTLSeconds > 0 {
+		ttl = time.Duration(rule.Actions.Affinity.TTLSeconds) * time.Second
+	}
+	h.affinity.set(key, stickyRoute{credentialID: info.Credential.ID, endpoint: upstreams.EndpointKey(target)}, ttl)
+}
+
+// credentialAvailable 报告凭据是否已启用、未被健康检查判定为不可用且没有已知的额度耗尽。
+func (h *Handler) credentialAvailable(cred accounts.UpstreamCredential) bool {
+	if !cred.Enabled {
+		return false
+	}
+	switch cred.HealthStatus {
+	case accounts.UpstreamHealthInvalid, accounts.UpstreamHealthUnreachable, accounts.UpstreamHealthRateLimited:
+		return false
+	}
+	if h.pools != nil {
+		if _, exhausted := h.pools.ExhaustedUntil(cred.ID); exhausted {
+			return false
+		}
+	}
+	return true
+}
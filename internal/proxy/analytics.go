@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/analytics"
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/usage"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// analyticsRecordKey 保存当前请求正在构建的分析事件。
+const analyticsRecordKey = "proxy_analytics_record"
+
+// WithAnalyticsSink 设置分析事件写出器，每个命中规则的代理请求结束时写出一条事件。
+func WithAnalyticsSink(sink *analytics.Sink) Option {
+	return func(h *Handler) {
+		h.analytics = sink
+	}
+}
+
+// analyticsRecord 在转发过程中逐步填充；用量在读完上游响应体时写入，因此需要加锁。
+type analyticsRecord struct {
+	mu    sync.Mutex
+	event analytics.Event
+}
+
+// trackAnalytics 为请求创建分析事件，返回的函数在请求结束时补全状态与耗时并写出。
+func (h *Handler) trackAnalytics(c *gin.Context, rule rules.Rule) func() {
+	if h.analytics == nil {
+		return func() {}
+	}
+	start := time.Now()
+	record := &analyticsRecord{event: analytics.Event{
+		Time:      start,
+		RequestID: middleware.RequestIDFromContext(c),
+		RuleID:    rule.ID,
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+	}}
+	c.Set(analyticsRecordKey, record)
+	return func() {
+		record.mu.Lock()
+		event := record.event
+		record.mu.Unlock()
+		event.Status = c.Writer.Status()
+		event.LatencyMs = time.Since(start).Milliseconds()
+		labels := currentUsageLabels(c)
+		event.Provider, event.UserID = labels.provider, labels.user
+		if info, ok := middleware.CurrentUpstreamInfo(c); ok {
+			event.CredentialID = info.Credential.ID
+		}
+		h.analytics.Emit(event)
+	}
+}
+
+func currentAnalyticsRecord(c *gin.Context) *analyticsRecord {
+	value, ok := c.Get(analyticsRecordKey)
+	if !ok {
+		return nil
+	}
+	record, _ := value.(*analyticsRecord)
+	return record
+}
+
+// setUpstream 记录最后一次尝试的上游主机。
+func (r *analyticsRecord) setUpstream(host string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.event.Upstream = host
+	r.mu.Unlock()
+}
+
+// setUsage 记录提取到的用量与估算费用。
+func (r *analyticsRecord) setUsage(u usage.Usage, cost float64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.event.Model = u.Model
+	r.event.PromptTokens = u.PromptTokens
+	r.event.CompletionTokens = u.CompletionTokens
+	r.event.CostUSD = cost
+	r.mu.Unlock()
+}
@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/archive"
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// defaultArchiveMaxBytes 是未指定上限时归档的请求体或响应体最多保存的字节数。
+const defaultArchiveMaxBytes = 256 << 10
+
+// WithArchive 启用对话归档：带请求体的请求在响应结束后加密写入 a，maxBytes 为请求体与响应体各自的保存上限。
+func WithArchive(a *archive.Archive, maxBytes int) Option {
+	return func(h *Handler) {
+		if maxBytes <= 0 {
+			maxBytes = defaultArchiveMaxBytes
+		}
+		h.archive = a
+		h.archiveMaxBytes = maxBytes
+	}
+}
+
+// archiveEntry 收集一次上游尝试的请求体与客户端收到的响应体，响应体读完或关闭后写入归档。
+type archiveEntry struct {
+	h         *Handler
+	retention time.Duration
+	conv      archive.Conversation
+	request   *bodyCapture
+}
+
+// startArchive 在规则动作之前包装请求体，归档的是客户端发来的原始请求（已按规则打码），重放可直接使用。返回 nil 表示本次不归档。
+func (h *Handler) startArchive(c *gin.Context, req *http.Request, rule rules.Rule) *archiveEntry {
+	if h.archive == nil || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	var metadata map[string]any
+	conv := archive.Conversation{
+		RequestID: middleware.RequestIDFromContext(c),
+		RuleID:    rule.ID,
+		Method:    req.Method,
+		Path:      c.Request.URL.Path,
+	}
+	if user, ok := middleware.CurrentUser(c); ok {
+		conv.UserID = user.ID
+		metadata = map[string]any(user.Metadata)
+	}
+	if apiKey, ok := middleware.CurrentAPIKey(c); ok {
+		conv.APIKeyID = apiKey.ID
+	}
+	retention := h.archive.Retention(metadata)
+	if retention <= 0 {
+		return nil
+	}
+	entry := &archiveEntry{
+		h:         h,
+		retention: retention,
+		conv:      conv,
+		request:   &bodyCapture{ReadCloser: req.Body, limit: h.archiveMaxBytes},
+	}
+	req.Body = entry.request
+	return entry
+}
+
+// finish 包装客户端收到的响应体；压缩过的响应体不保存内容，只记录元数据与请求。
+func (e *archiveEntry) finish(resp *http.Response) {
+	if e == nil {
+		return
+	}
+	e.conv.Status = resp.StatusCode
+	encoding := resp.Header.Get("Content-Encoding")
+	if resp.Body == nil || resp.Body == http.NoBody || (encoding != "" && !strings.EqualFold(encoding, "identity")) {
+		e.save(nil)
+		return
+	}
+	captured := &bodyCapture{ReadCloser: resp.Body, limit: e.h.archiveMaxBytes}
+	captured.onDone = func() { e.save(captured) }
+	resp.Body = captured
+}
+
+func (e *archiveEntry) save(response *bodyCapture) {
+	conv := e.conv
+	request, truncated := e.request.snapshot()
+	conv.Request = string(request)
+	conv.RequestTruncated = truncated
+	conv.Model = requestModel(request)
+	if response != nil {
+		body, responseTruncated := response.snapshot()
+		conv.Response = string(body)
+		truncated = truncated || responseTruncated
+	}
+	conv.Truncated = truncated
+	// 响应体在客户端断开时也会被关闭，归档不应随请求上下文一起取消。
+	if err := e.h.archive.Record(context.Background(), conv, e.retention); err != nil && e.h.logger != nil {
+		e.h.logger.Warn("archive conversation failed", "error", err, "request_id", conv.RequestID, "rule_id", conv.RuleID)
+	}
+}
+
+// requestModel 从 JSON 请求体中读取 model 字段，请求体被截断或不是 JSON 时返回空串。
+func requestModel(body []byte) string {
+	var payload struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+	return payload.Model
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// applyBetaHeaders 按 beta_headers 策略改写逗号分隔的特性请求头（如 anthropic-beta），
+// 返回发生变化的请求头名称与改写后的取值，取值为空表示该请求头已被删除。请求头按名称排序处理，结果稳定。
+func applyBetaHeaders(header http.Header, policies map[string]rules.BetaHeaderPolicy) [][2]string {
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var changes [][2]string
+	for _, name := range names {
+		before := betaFeatures(header.Values(name))
+		after := filterBetaFeatures(before, policies[name])
+		if slices.Equal(before, after) && len(header.Values(name)) <= 1 {
+			continue
+		}
+		value := strings.Join(after, ",")
+		if value == "" {
+			header.Del(name)
+		} else {
+			header.Set(name, value)
+		}
+		changes = append(changes, [2]string{name, value})
+	}
+	return changes
+}
+
+// betaFeatures 拆分请求头中逗号分隔的特性，去掉空白与重复项并保持顺序；多个同名请求头合并处理。
+func betaFeatures(values []string) []string {
+	var features []string
+	for _, value := range values {
+		for _, feature := range strings.Split(value, ",") {
+			feature = strings.TrimSpace(feature)
+			if feature != "" && !slices.Contains(features, feature) {
+				features = append(features, feature)
+			}
+		}
+	}
+	return features
+}
+
+// filterBetaFeatures 按策略过滤客户端的特性并附加强制特性。
+func filterBetaFeatures(features []string, policy rules.BetaHeaderPolicy) []string {
+	var kept []string
+	if !policy.Strip {
+		for _, feature := range features {
+			if len(policy.Allow) == 0 || slices.ContainsFunc(policy.Allow, func(pattern string) bool {
+				return betaFeatureMatches(strings.TrimSpace(pattern), feature)
+			}) {
+				kept = append(kept, feature)
+			}
+		}
+	}
+	for _, feature := range policy.Set {
+		feature = strings.TrimSpace(feature)
+		if !slices.Contains(kept, feature) {
+			kept = append(kept, feature)
+		}
+	}
+	return kept
+}
+
+// betaFeatureMatches 不区分大小写地比较特性名，pattern 以 * 结尾时按前缀匹配。
+func betaFeatureMatches(pattern, feature string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return len(feature) >= len(prefix) && strings.EqualFold(feature[:len(prefix)], prefix)
+	}
+	return strings.EqualFold(pattern, feature)
+}
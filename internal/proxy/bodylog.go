@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/prehisle/yapi/internal/bodylog"
+	"github.com/prehisle/yapi/internal/features"
+)
+
+// defaultBodyLogMaxBytes 是未指定上限时每个请求体或响应体最多捕获的字节数。
+const defaultBodyLogMaxBytes = 64 << 10
+
+// BodyLogOptions 配置请求/响应体日志。
+type BodyLogOptions struct {
+	// Policy 是脱敏策略；Authorization 等凭据请求头与名称敏感的字段始终被处理。
+	Policy bodylog.Policy
+	// MaxBytes 为每个请求体或响应体最多捕获的字节数，超出部分不记录。
+	MaxBytes int
+	// Toggle 控制是否记录，为 nil 时始终记录。
+	Toggle *features.Toggle
+}
+
+// WithBodyLogging 启用请求/响应体日志：每次上游尝试结束时输出一条 "proxy body" 日志，
+// 包含脱敏后的请求头、请求体（规则改写之后）、响应状态、响应头与响应体。
+func WithBodyLogging(opts BodyLogOptions) Option {
+	return func(h *Handler) {
+		if opts.MaxBytes <= 0 {
+			opts.MaxBytes = defaultBodyLogMaxBytes
+		}
+		h.bodyLog = &opts
+	}
+}
+
+// bodyLogEntry 收集一次上游尝试的请求与响应，两者都结束后才写日志。
+type bodyLogEntry struct {
+	h        *Handler
+	attrs    []any
+	request  *http.Request
+	captured *bodyCapture
+	once     sync.Once
+}
+
+// startBodyLog 在请求发往上游前调用，返回 nil 表示本次不记录。
+func (h *Handler) startBodyLog(req *http.Request, attrs ...any) *bodyLogEntry {
+	if h.bodyLog == nil || !h.bodyLog.Toggle.Enabled() {
+		return nil
+	}
+	entry := &bodyLogEntry{h: h, attrs: attrs, request: req}
+	if req.Body != nil && req.Body != http.NoBody {
+		entry.captured = &bodyCapture{ReadCloser: req.Body, limit: h.bodyLog.MaxBytes}
+		req.Body = entry.captured
+	}
+	return entry
+}
+
+// finishWithResponse 包装响应体，在响应体读完或关闭时写日志。
+func (e *bodyLogEntry) finishWithResponse(resp *http.Response) {
+	if e == nil {
+		return
+	}
+	if resp.Body == nil || resp.Body == http.NoBody {
+		e.write(resp, nil)
+		return
+	}
+	captured := &bodyCapture{ReadCloser: resp.Body, limit: e.h.bodyLog.MaxBytes}
+	captured.onDone = func() { e.write(resp, captured) }
+	resp.Body = captured
+}
+
+// finishWithError 在上游调用失败、没有响应时写日志。
+func (e *bodyLogEntry) finishWithError(err error) {
+	if e == nil {
+		return
+	}
+	e.once.Do(func() {
+		e.h.logger.Info("proxy body", append(e.requestAttrs(), "error", err.Error())...)
+	})
+}
+
+func (e *bodyLogEntry) write(resp *http.Response, body *bodyCapture) {
+	e.once.Do(func() {
+		attrs := append(e.requestAttrs(),
+			"response_status", resp.StatusCode,
+			"response_headers", bodylog.Headers(resp.Header),
+		)
+		if body != nil {
+			captured, truncated := body.snapshot()
+			attrs = append(attrs, "response_body", e.h.bodyLog.Policy.Body(resp.Header.Get("Content-Type"), captured, truncated))
+		}
+		e.h.logger.Info("proxy body", attrs...)
+	})
+}
+
+func (e *bodyLogEntry) requestAttrs() []any {
+	attrs := append([]any{}, e.attrs...)
+	attrs = append(attrs,
+		"method", e.request.Method,
+		"path", e.request.URL.Path,
+		"request_headers", bodylog.Headers(e.request.Header),
+	)
+	if e.captured != nil {
+		captured, truncated := e.captured.snapshot()
+		attrs = append(attrs, "request_body", e.h.bodyLog.Policy.Body(e.request.Header.Get("Content-Type"), captured, truncated))
+	}
+	return attrs
+}
+
+// bodyCapture 在转发数据的同时保留前 limit 字节。请求体由传输层在独立的 goroutine 中读取，
+// 因此缓冲需加锁。
+type bodyCapture struct {
+	io.ReadCloser
+	limit     int
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	truncated bool
+	onDone    func()
+	once      sync.Once
+}
+
+func (b *bodyCapture) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.mu.Lock()
+		if room := b.limit - b.buf.Len(); room >= n {
+			b.buf.Write(p[:n])
+		} else {
+			b.buf.Write(p[:max(room, 0)])
+			b.truncated = true
+		}
+		b.mu.Unlock()
+	}
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *bodyCapture) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
+
+// snapshot 返回已捕获内容的副本与是否被截断。
+func (b *bodyCapture) snapshot() ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf.Bytes()), b.truncated
+}
+
+func (b *bodyCapture) done() {
+	if b.onDone != nil {
+		b.once.Do(b.onDone)
+	}
+}
@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/budget"
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// WithBudgets 设置用户与组织预算：转发前拒绝 hard_stop 预算已用尽的请求，响应计费后把估算费用计入预算。
+// 费用依赖 WithModelPricing 的价格表。
+func WithBudgets(budgets *budget.Service) Option {
+	return func(h *Handler) {
+		h.budgets = budgets
+	}
+}
+
+// budgetSubjects 返回请求计入预算的用户 ID 与所属组织（用户元数据 org），匿名请求均为空。
+func budgetSubjects(c *gin.Context) (userID, org string) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		return "", ""
+	}
+	if value, found := user.Metadata[budget.OrgMetadataKey]; found && value != nil {
+		org = fmt.Sprint(value)
+	}
+	return user.ID, org
+}
+
+// checkBudget 在转发前检查预算，已用尽时写出 403 并返回 false。
+func (h *Handler) checkBudget(c *gin.Context) bool {
+	if h.budgets == nil {
+		return true
+	}
+	userID, org := budgetSubjects(c)
+	err := h.budgets.Check(userID, org)
+	if err == nil {
+		return true
+	}
+	traceError(c, err)
+	scope := budget.ScopeUser
+	var exceeded *budget.ExceededError
+	if errors.As(err, &exceeded) {
+		scope = exceeded.Budget.Scope
+	}
+	metrics.ObserveBudgetRejected(scope)
+	if h.logger != nil {
+		h.logger.Warn("budget exceeded",
+			"request_id", middleware.RequestIDFromContext(c),
+			"user_id", userID,
+			"org", org,
+			"error", err,
+		)
+	}
+	errcode.Respond(c, http.StatusForbidden, errcode.BudgetExceeded, err.Error())
+	return false
+}
+
+// recordBudget 把一次调用的估算费用计入预算。在响应体读完时调用，此时请求上下文可能已结束，因此不沿用。
+func (h *Handler) recordBudget(labels usageLabels, cost float64) {
+	if h.budgets == nil {
+		return
+	}
+	h.budgets.Record(context.Background(), labels.budgetUser, labels.budgetOrg, cost)
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/tidwall/sjson"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// paramChange 记录 clamp_params 对一个参数的改写。
+type paramChange struct {
+	Path  string
+	Value float64
+}
+
+// clampJSONParams 按规则的 clamp_params 把 JSON 请求体中的数值参数限制在范围内，缺失的参数按 default 注入，
+// 返回实际发生的改写。非 JSON 请求、空请求体与非数值参数保持不变。
+func clampJSONParams(req *http.Request, bounds map[string]rules.ParamBound) ([]paramChange, error) {
+	if len(bounds) == 0 || req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if !strings.Contains(strings.ToLower(req.Header.Get("Content-Type")), "application/json") {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	_ = req.Body.Close()
+	setRequestBody(req, body)
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var doc map[string]any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, nil
+	}
+	paths := make([]string, 0, len(bounds))
+	for path := range bounds {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	var changes []paramChange
+	for _, path := range paths {
+		bound := bounds[path]
+		tokens, err := rules.ParseJSONPath(path)
+		if err != nil {
+			return nil, err
+		}
+		var target float64
+		switch value, ok := lookupJSONPath(doc, tokens); {
+		case !ok || value == nil:
+			if bound.Default == nil {
+				continue
+			}
+			target = *bound.Default
+		default:
+			number, isNumber := value.(json.Number)
+			if !isNumber {
+				continue
+			}
+			current, err := number.Float64()
+			if err != nil {
+				continue
+			}
+			if target = bound.Clamp(current); target == current {
+				continue
+			}
+		}
+		body, err = sjson.SetBytesOptions(body, tokensToSJSONPath(tokens), target, &sjson.Options{Optimistic: true})
+		if err != nil {
+			return nil, fmt.Errorf("clamp path %s: %w", path, err)
+		}
+		changes = append(changes, paramChange{Path: path, Value: target})
+	}
+	if len(changes) > 0 {
+		setRequestBody(req, body)
+	}
+	return changes, nil
+}
+
+// lookupJSONPath 在解码后的 JSON 文档中按路径取值。
+func lookupJSONPath(doc any, tokens []rules.JSONPathToken) (any, bool) {
+	current := doc
+	for _, token := range tokens {
+		if token.IsKey() {
+			object, ok := current.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if current, ok = object[token.Key]; !ok {
+				return nil, false
+			}
+			continue
+		}
+		array, ok := current.([]any)
+		if !ok || token.IndexValue() < 0 || token.IndexValue() >= len(array) {
+			return nil, false
+		}
+		current = array[token.IndexValue()]
+	}
+	return current, true
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/pkg/metrics"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// checkResponseContract 按 expect_response 检查上游响应的状态码类别、必需响应头与 JSON Schema，
+// 违规时记录指标与日志；规则开启 enforce 时把响应替换为 502 并返回 true。
+// JSON Schema 只校验非流式、未压缩的 2xx application/json 响应，无法解析的响应体计为 schema 违规。
+func (h *Handler) checkResponseContract(c *gin.Context, rule rules.Rule, resp *http.Response) (bool, error) {
+	contract := rule.Actions.ExpectResponse
+	if contract == nil {
+		return false, nil
+	}
+	var violations []string
+	observe := func(check, detail string) {
+		metrics.ObserveContractViolation(rule.ID, check)
+		violations = append(violations, check+": "+detail)
+	}
+	if !contract.AllowsStatus(resp.StatusCode) {
+		observe("status", fmt.Sprintf("status %d not in %s", resp.StatusCode, strings.Join(contract.StatusClasses, ", ")))
+	}
+	for _, name := range contract.RequiredHeaders {
+		if resp.Header.Get(name) == "" {
+			observe("header", name+" missing")
+		}
+	}
+	if len(contract.JSONSchema) > 0 && schemaCheckable(resp) {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return false, err
+		}
+		_ = resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		var value any
+		if err := json.Unmarshal(body, &value); err != nil {
+			observe("schema", "invalid json: "+err.Error())
+		} else if mismatches := rules.MatchJSONSchema(contract.JSONSchema, value); len(mismatches) > 0 {
+			observe("schema", strings.Join(mismatches, "; "))
+		}
+	}
+	if len(violations) == 0 {
+		return false, nil
+	}
+	if h.logger != nil {
+		h.logger.Warn("upstream response contract violated",
+			"request_id", middleware.RequestIDFromContext(c),
+			"rule_id", rule.ID,
+			"status", resp.StatusCode,
+			"violations", violations,
+			"enforce", contract.Enforce,
+		)
+	}
+	if !contract.Enforce {
+		return false, nil
+	}
+	replaceResponse(c, resp, http.StatusBadGateway, errcode.UpstreamContractViolation,
+		"upstream response violated expected contract: "+strings.Join(violations, "; "))
+	return true, nil
+}
+
+// schemaCheckable 判断响应体能否在不影响流式转发的前提下完整读取并按 JSON 解析。
+func schemaCheckable(resp *http.Response) bool {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || resp.Body == nil || resp.Body == http.NoBody || isStreamingResponse(resp) {
+		return false
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
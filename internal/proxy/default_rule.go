@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errDefaultRuleAuthRequired 表示默认规则只转发已认证的请求，而请求未携带 API Key。
+var errDefaultRuleAuthRequired = errors.New("api key required for default upstream")
+
+// DefaultRulePolicy 控制未命中任何规则时转发到默认上游的隐式 default 规则，零值表示不限制。
+type DefaultRulePolicy struct {
+	// Disabled 为 true 时不再兜底转发，未命中规则的请求返回 404。
+	Disabled bool
+	// RequireAuth 为 true 时只兜底转发携带有效 API Key 的请求，其余返回 401。
+	RequireAuth bool
+	// Methods 非空时只兜底转发列出的方法（不区分大小写）。
+	Methods []string
+	// PathPrefixes 非空时只兜底转发路径以其中之一开头的请求。
+	PathPrefixes []string
+}
+
+// WithDefaultRulePolicy 设置默认规则的兜底策略。
+func WithDefaultRulePolicy(policy DefaultRulePolicy) Option {
+	return func(h *Handler) {
+		h.defaultRule.Store(&policy)
+	}
+}
+
+// SetDefaultRulePolicy 在运行时替换默认规则的兜底策略，用于配置热更新，对后续请求生效。
+func (h *Handler) SetDefaultRulePolicy(policy DefaultRulePolicy) {
+	h.defaultRule.Store(&policy)
+}
+
+// defaultRuleAllows 判断未命中规则的请求能否交给默认规则：被策略排除时返回 ErrNoMatchingRule，
+// 要求认证而请求未认证时返回 errDefaultRuleAuthRequired。
+func (h *Handler) defaultRuleAllows(c *gin.Context) error {
+	policy := h.defaultRule.Load()
+	if policy == nil {
+		return nil
+	}
+	if policy.Disabled {
+		return ErrNoMatchingRule
+	}
+	if len(policy.Methods) > 0 && !slices.ContainsFunc(policy.Methods, func(method string) bool {
+		return strings.EqualFold(method, c.Request.Method)
+	}) {
+		return ErrNoMatchingRule
+	}
+	if len(policy.PathPrefixes) > 0 && !slices.ContainsFunc(policy.PathPrefixes, func(prefix string) bool {
+		return strings.HasPrefix(c.Request.URL.Path, prefix)
+	}) {
+		return ErrNoMatchingRule
+	}
+	if policy.RequireAuth && !clientAuthenticated(c) {
+		return errDefaultRuleAuthRequired
+	}
+	return nil
+}
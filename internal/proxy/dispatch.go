@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/dispatch"
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// tierMetadataKey 是用户元数据中决定排队优先级的字段。
+const tierMetadataKey = "tier"
+
+// WithDispatchQueue 设置上游转发的并发上限与排队队列，名额用尽时按用户元数据 tier 对应的优先级放行。
+// queue 为 nil 时不限制。
+func WithDispatchQueue(queue *dispatch.Queue, tiers dispatch.Tiers) Option {
+	return func(h *Handler) {
+		h.dispatch = queue
+		h.tiers = tiers
+	}
+}
+
+// acquireDispatch 在转发前占用一个上游转发名额，返回的函数在请求结束（含流式响应）后归还名额。
+// 排队失败时已写出错误响应并返回 ok=false。
+func (h *Handler) acquireDispatch(c *gin.Context) (release func(), ok bool) {
+	if h.dispatch == nil {
+		return func() {}, true
+	}
+	tier := ""
+	if user, found := middleware.CurrentUser(c); found && user.Metadata != nil {
+		if value, found := user.Metadata[tierMetadataKey]; found && value != nil {
+			tier = fmt.Sprint(value)
+		}
+	}
+	releaseSlot, err := h.dispatch.Acquire(c.Request.Context(), h.tiers.Priority(tier))
+	h.observeDispatch()
+	if err == nil {
+		metrics.ObserveDispatchOutcome("dispatched")
+		return func() {
+			releaseSlot()
+			h.observeDispatch()
+		}, true
+	}
+	traceError(c, err)
+	switch {
+	case errors.Is(err, dispatch.ErrQueueFull):
+		metrics.ObserveDispatchOutcome("rejected")
+		c.Header("Retry-After", "1")
+		errcode.Respond(c, http.StatusServiceUnavailable, errcode.QueueFull, err.Error())
+	case errors.Is(err, dispatch.ErrQueueTimeout):
+		metrics.ObserveDispatchOutcome("timeout")
+		c.Header("Retry-After", "1")
+		errcode.Respond(c, http.StatusServiceUnavailable, errcode.QueueTimeout, err.Error())
+	default:
+		metrics.ObserveDispatchOutcome("canceled")
+		if errors.Is(err, context.DeadlineExceeded) {
+			errcode.Respond(c, http.StatusGatewayTimeout, errcode.UpstreamTimeout, err.Error())
+		} else {
+			errcode.Respond(c, statusClientClosed, errcode.ClientClosed, err.Error())
+		}
+	}
+	if h.logger != nil {
+		h.logger.Warn("dispatch queue rejected request",
+			"request_id", middleware.RequestIDFromContext(c),
+			"tier", tier,
+			"error", err,
+		)
+	}
+	return nil, false
+}
+
+func (h *Handler) observeDispatch() {
+	stats := h.dispatch.Stats()
+	metrics.ObserveDispatchQueue(stats.InFlight, stats.Waiting)
+}
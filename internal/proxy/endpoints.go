@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prehisle/yapi/internal/upstreams"
+)
+
+// WithEndpointScorer 自定义端点健康评分器，默认每个 Handler 独立创建。
+func WithEndpointScorer(scorer *upstreams.EndpointScorer) Option {
+	return func(h *Handler) {
+		h.endpoints = scorer
+	}
+}
+
+// selectEndpoint 解析凭据的端点列表并按健康得分选择一个，忽略无法解析的条目。preferred 为会话亲和记录的端点统计键，
+// 仍在列表中时直接选用。
+func (h *Handler) selectEndpoint(endpoints []string, preferred string) (*url.URL, bool) {
+	candidates := make([]*url.URL, 0, len(endpoints))
+	keys := make([]string, 0, len(endpoints))
+	for _, raw := range endpoints {
+		target, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil || target.Host == "" {
+			continue
+		}
+		candidates = append(candidates, target)
+		keys = append(keys, upstreams.EndpointKey(target))
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+	if preferred != "" {
+		if index := slices.Index(keys, preferred); index >= 0 {
+			return candidates[index], true
+		}
+	}
+	index := 0
+	if h.endpoints != nil {
+		index = h.endpoints.Select(keys)
+	}
+	return candidates[index], true
+}
+
+// observeEndpoint 记录一次上游调用结果，供后续端点选择参考。
+func (h *Handler) observeEndpoint(target *url.URL, latency time.Duration, failed bool) {
+	if h.endpoints == nil {
+		return
+	}
+	h.endpoints.Observe(upstreams.EndpointKey(target), latency, failed)
+}
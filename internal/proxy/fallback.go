@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/prehisle/yapi/pkg/accounts"
+)
+
+// errUpstreamFailover 表示上游响应可重试，应切换至下一个备用绑定。
+var errUpstreamFailover = errors.New("upstream failed, switching to fallback binding")
+
+// bindingFallback 按 Position 顺序维护同一 Service 下的备用绑定，首次需要时才加载。
+type bindingFallback struct {
+	accounts accounts.Service
+	current  accounts.UserAPIKeyBinding
+	loaded   bool
+	queue    []accounts.BindingWithUpstream
+	hops     int
+}
+
+// newBindingFallback 在存在绑定且启用账户服务时创建故障转移队列，否则返回 nil。
+func newBindingFallback(svc accounts.Service, binding accounts.UserAPIKeyBinding, hasBinding bool) *bindingFallback {
+	if svc == nil || !hasBinding || binding.UserAPIKeyID == "" {
+		return nil
+	}
+	return &bindingFallback{accounts: svc, current: binding}
+}
+
+// available 判断是否还有可切换的备用绑定。
+func (f *bindingFallback) available(ctx context.Context) bool {
+	if f == nil {
+		return false
+	}
+	f.load(ctx)
+	return len(f.queue) > 0
+}
+
+// next 取出下一个备用绑定。
+func (f *bindingFallback) next(ctx context.Context) (accounts.BindingWithUpstream, bool) {
+	if !f.available(ctx) {
+		return accounts.BindingWithUpstream{}, false
+	}
+	candidate := f.queue[0]
+	f.queue = f.queue[1:]
+	f.current = candidate.Binding
+	f.hops++
+	return candidate, true
+}
+
+// switched 判断当前绑定是否由故障转移切换而来。
+func (f *bindingFallback) switched() bool {
+	return f != nil && f.hops > 0
+}
+
+func (f *bindingFallback) load(ctx context.Context) {
+	if f.loaded {
+		return
+	}
+	f.loaded = true
+	bindings, err := f.accounts.ListBindingsByAPIKey(ctx, f.current.UserAPIKeyID)
+	if err != nil {
+		return
+	}
+	for _, item := range bindings {
+		if item.Binding.ID == f.current.ID || item.Binding.Position <= f.current.Position {
+			continue
+		}
+		if !strings.EqualFold(strings.TrimSpace(item.Binding.Service), strings.TrimSpace(f.current.Service)) {
+			continue
+		}
+		if !item.Upstream.Enabled || item.Upstream.UserID != f.current.UserID {
+			continue
+		}
+		f.queue = append(f.queue, item)
+	}
+}
+
+// shouldFailover 判断上游状态码是否意味着当前凭据不可用。
+func shouldFailover(status int) bool {
+	return status == http.StatusUnauthorized || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/usage"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// genAISystems 把上游凭据的 Provider 映射为 GenAI 语义约定的 gen_ai.system 取值，未列出的 Provider 原样使用。
+var genAISystems = map[string]attribute.KeyValue{
+	"openai":    semconv.GenAISystemOpenAI,
+	"azure":     semconv.GenAISystemAzAIOpenAI,
+	"anthropic": semconv.GenAISystemAnthropic,
+	"gemini":    semconv.GenAISystemGCPGemini,
+	"vertex":    semconv.GenAISystemGCPVertexAI,
+	"bedrock":   semconv.GenAISystemAWSBedrock,
+	"mistral":   semconv.GenAISystemMistralAI,
+	"cohere":    semconv.GenAISystemCohere,
+	"deepseek":  semconv.GenAISystemDeepseek,
+	"groq":      semconv.GenAISystemGroq,
+	"xai":       semconv.GenAISystemXai,
+}
+
+// genAICall 是一次上游模型调用的 GenAI 语义约定维度，由 proxy.upstream span 与 gen_ai_client_* 指标共用。
+// 无法识别为模型调用的请求（如列出模型）为 nil，所有方法对 nil 安全。
+type genAICall struct {
+	span      oteltrace.Span
+	operation string
+	system    string
+	model     string
+}
+
+// startGenAI 按上游请求（规则动作与协议转换之后）识别操作类型、提供方与请求模型，并写入 span。
+// 只读取 JSON 请求体中的模型与采样参数，不记录提示词内容。
+func startGenAI(c *gin.Context, req *http.Request, span oteltrace.Span) *genAICall {
+	operation := genAIOperation(req.URL.Path)
+	if operation == "" {
+		return nil
+	}
+	var service string
+	if info, ok := middleware.CurrentUpstreamInfo(c); ok {
+		service = strings.ToLower(strings.TrimSpace(info.Credential.Service))
+	}
+	call := &genAICall{span: span, operation: operation, system: genAISystem(service, req.URL.Path)}
+	attrs := []attribute.KeyValue{
+		semconv.GenAIOperationNameKey.String(call.operation),
+		semconv.GenAISystemKey.String(call.system),
+	}
+	params := readGenAIParams(req)
+	call.model = params.Model
+	if call.model == "" {
+		call.model = pathModel(req.URL.Path)
+	}
+	if call.model != "" {
+		attrs = append(attrs, semconv.GenAIRequestModel(call.model))
+	}
+	config := params.GenerationConfig
+	if maxTokens := firstInt(params.MaxTokens, params.MaxCompletionTokens, config.MaxOutputTokens); maxTokens != nil {
+		attrs = append(attrs, semconv.GenAIRequestMaxTokens(*maxTokens))
+	}
+	if temperature := firstFloat(params.Temperature, config.Temperature); temperature != nil {
+		attrs = append(attrs, semconv.GenAIRequestTemperature(*temperature))
+	}
+	if topP := firstFloat(params.TopP, config.TopP); topP != nil {
+		attrs = append(attrs, semconv.GenAIRequestTopP(*topP))
+	}
+	span.SetAttributes(attrs...)
+	return call
+}
+
+// genAIParams 覆盖 OpenAI、Anthropic 与 Gemini 请求体中的模型与采样参数。
+type genAIParams struct {
+	Model               string   `json:"model"`
+	MaxTokens           *int     `json:"max_tokens"`
+	MaxCompletionTokens *int     `json:"max_completion_tokens"`
+	Temperature         *float64 `json:"temperature"`
+	TopP                *float64 `json:"top_p"`
+	GenerationConfig    struct {
+		MaxOutputTokens *int     `json:"maxOutputTokens"`
+		Temperature     *float64 `json:"temperature"`
+		TopP            *float64 `json:"topP"`
+	} `json:"generationConfig"`
+}
+
+func readGenAIParams(req *http.Request) genAIParams {
+	var params genAIParams
+	if req.Body == nil || req.Body == http.NoBody || !strings.Contains(strings.ToLower(req.Header.Get("Content-Type")), "json") {
+		return params
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	setRequestBody(req, body)
+	if err == nil {
+		_ = json.Unmarshal(body, &params)
+	}
+	return params
+}
+
+// finish 在提取到用量时写入响应模型、响应 ID、结束原因与 token 数。
+func (g *genAICall) finish(u usage.Usage) {
+	if g == nil {
+		return
+	}
+	attrs := []attribute.KeyValue{
+		semconv.GenAIUsageInputTokens(int(u.PromptTokens)),
+		semconv.GenAIUsageOutputTokens(int(u.CompletionTokens)),
+	}
+	if u.Model != "" {
+		attrs = append(attrs, semconv.GenAIResponseModel(u.Model))
+	}
+	if u.ResponseID != "" {
+		attrs = append(attrs, semconv.GenAIResponseID(u.ResponseID))
+	}
+	if len(u.FinishReasons) > 0 {
+		attrs = append(attrs, semconv.GenAIResponseFinishReasons(u.FinishReasons...))
+	}
+	g.span.SetAttributes(attrs...)
+	metrics.ObserveGenAITokens(g.operation, g.system, g.model, u.Model, u.PromptTokens, u.CompletionTokens)
+}
+
+// observe 在上游调用结束（含流式响应读完）时记录耗时；失败时 error_type 为上游状态码或 _OTHER。
+func (g *genAICall) observe(elapsed time.Duration, status int, failed bool) {
+	if g == nil {
+		return
+	}
+	errorType := ""
+	switch {
+	case status >= http.StatusBadRequest:
+		errorType = strconv.Itoa(status)
+	case failed:
+		errorType = "_OTHER"
+	}
+	if errorType != "" {
+		g.span.SetAttributes(semconv.ErrorTypeKey.String(errorType))
+	}
+	metrics.ObserveGenAIDuration(g.operation, g.system, g.model, errorType, elapsed.Seconds())
+}
+
+// genAIOperation 按上游路径识别 gen_ai.operation.name，非模型调用返回空串。
+func genAIOperation(path string) string {
+	switch {
+	case strings.HasSuffix(path, "/chat/completions"), strings.HasSuffix(path, "/messages"), strings.HasSuffix(path, "/responses"):
+		return "chat"
+	case strings.HasSuffix(path, "/completions"):
+		return "text_completion"
+	case strings.HasSuffix(path, "/embeddings"), strings.HasSuffix(path, ":embedContent"), strings.HasSuffix(path, ":batchEmbedContents"):
+		return "embeddings"
+	case strings.HasSuffix(path, ":generateContent"), strings.HasSuffix(path, ":streamGenerateContent"):
+		return "generate_content"
+	}
+	return ""
+}
+
+// genAISystem 优先按凭据 Provider 取值；未绑定凭据时按上游协议推断，OpenAI 兼容接口记为 openai。
+func genAISystem(service, path string) string {
+	if service != "" {
+		if system, ok := genAISystems[service]; ok {
+			return system.Value.AsString()
+		}
+		return service
+	}
+	switch {
+	case strings.HasSuffix(path, "/messages"):
+		return semconv.GenAISystemAnthropic.Value.AsString()
+	case strings.Contains(path, "/models/") && strings.Contains(path, ":"):
+		return semconv.GenAISystemGCPGemini.Value.AsString()
+	}
+	return semconv.GenAISystemOpenAI.Value.AsString()
+}
+
+// pathModel 从 Gemini（/models/{model}:method）或 Azure OpenAI（/deployments/{deployment}/）的路径中读取模型。
+func pathModel(path string) string {
+	if _, rest, ok := strings.Cut(path, "/models/"); ok {
+		model, _, _ := strings.Cut(rest, ":")
+		return model
+	}
+	if _, rest, ok := strings.Cut(path, "/deployments/"); ok {
+		deployment, _, _ := strings.Cut(rest, "/")
+		return deployment
+	}
+	return ""
+}
+
+func firstInt(values ...*int) *int {
+	for _, v := range values {
+		if v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func firstFloat(values ...*float64) *float64 {
+	for _, v := range values {
+		if v != nil {
+			return v
+		}
+	}
+	return nil
+}
@@ -3,27 +3,53 @@ package proxy
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tidwall/sjson"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
 
+	"github.com/prehisle/yapi/internal/analytics"
+	"github.com/prehisle/yapi/internal/archive"
+	"github.com/prehisle/yapi/internal/budget"
+	"github.com/prehisle/yapi/internal/dispatch"
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/features"
 	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/modelalias"
+	"github.com/prehisle/yapi/internal/reqtrace"
+	"github.com/prehisle/yapi/internal/respcache"
+	"github.com/prehisle/yapi/internal/telemetry"
+	"github.com/prehisle/yapi/internal/tokenizer"
+	"github.com/prehisle/yapi/internal/traceexport"
+	"github.com/prehisle/yapi/internal/translate"
+	"github.com/prehisle/yapi/internal/upstreams"
+	"github.com/prehisle/yapi/internal/usage"
 	"github.com/prehisle/yapi/pkg/accounts"
 	"github.com/prehisle/yapi/pkg/metrics"
 	"github.com/prehisle/yapi/pkg/rules"
+	"github.com/prehisle/yapi/pkg/secrets"
 )
 
+// resolvedSecretKey 保存当前请求已解析的上游外部密钥。
+const resolvedSecretKey = "proxy_resolved_upstream_secret"
+
 // ErrNoMatchingRule 表示没有规则匹配当前请求。
 var ErrNoMatchingRule = errors.New("no matching rule")
 
@@ -31,9 +57,44 @@ var ErrNoMatchingRule = errors.New("no matching rule")
 type Handler struct {
 	service        rules.Service
 	accountService accounts.Service
-	defaultTarget  *url.URL
+	secrets        secrets.Resolver
+	pools          *upstreams.PoolSelector
+	endpoints      *upstreams.EndpointScorer
+	sloThreshold   time.Duration
+	analytics      *analytics.Sink
+	defaultTarget  atomic.Pointer[url.URL]
 	transport      http.RoundTripper
-	logger         *slog.Logger
+	dialContext    func(ctx context.Context, network, address string) (net.Conn, error)
+	// warm 为上游连接预热设置，warmTransport 为未包装指标的传输层，预热请求不计入上游指标。
+	warm          WarmOptions
+	warmTransport http.RoundTripper
+	logger        *slog.Logger
+	traces        reqtrace.Store
+	traceToggle   *features.Toggle
+	pricing       usage.Pricing
+	bodyLog       *BodyLogOptions
+	modelAliases  modelalias.Service
+	responseCache *respcache.Cache
+	tokenizer     *tokenizer.Tokenizer
+	retryAfterMax time.Duration
+	// streamHeartbeat 与 streamIdleTimeout 控制 SSE 响应的心跳与空闲超时。
+	streamHeartbeat   time.Duration
+	streamIdleTimeout time.Duration
+	archive           *archive.Archive
+	archiveMaxBytes   int
+	dispatch          *dispatch.Queue
+	tiers             dispatch.Tiers
+	budgets           *budget.Service
+	traceExport       *traceexport.Service
+	// traceExportMaxBytes 为导出的请求体与响应体各自的保存上限。
+	traceExportMaxBytes int
+	// smartRoutes 保存 smart_route 的粘性选择，affinity 保存会话亲和记录。
+	smartRoutes *stickyRoutes
+	affinity    *stickyRoutes
+	// requireClientAuth 为 true 时拒绝没有有效 API Key 的请求，关闭默认上游兜底带来的开放代理风险。
+	requireClientAuth atomic.Bool
+	// defaultRule 为默认规则的兜底策略，nil 表示不限制。
+	defaultRule atomic.Pointer[DefaultRulePolicy]
 }
 
 // Option 定义 Handler 可配参数。
@@ -42,10 +103,27 @@ type Option func(*Handler)
 // WithDefaultTarget 设置默认的上游地址。
 func WithDefaultTarget(u *url.URL) Option {
 	return func(h *Handler) {
-		h.defaultTarget = u
+		h.defaultTarget.Store(u)
 	}
 }
 
+// SetDefaultTarget 在运行时替换默认上游地址，nil 表示不再兜底转发；用于配置热更新，对后续请求生效。
+func (h *Handler) SetDefaultTarget(u *url.URL) {
+	h.defaultTarget.Store(u)
+}
+
+// WithRequireClientAuth 设置是否要求每个请求携带可解析的 API Key，缺少时在规则匹配之前返回 401。
+func WithRequireClientAuth(required bool) Option {
+	return func(h *Handler) {
+		h.requireClientAuth.Store(required)
+	}
+}
+
+// SetRequireClientAuth 在运行时切换匿名访问开关，用于配置热更新，对后续请求生效。
+func (h *Handler) SetRequireClientAuth(required bool) {
+	h.requireClientAuth.Store(required)
+}
+
 // WithTransport 自定义 HTTP 传输层，实现如链路追踪等能力。
 func WithTransport(rt http.RoundTripper) Option {
 	return func(h *Handler) {
@@ -53,6 +131,13 @@ func WithTransport(rt http.RoundTripper) Option {
 	}
 }
 
+// WithDialContext 设置默认传输层建立上游连接的拨号函数，如带 DNS 缓存的拨号；与 WithTransport 同时使用时不生效。
+func WithDialContext(dial func(ctx context.Context, network, address string) (net.Conn, error)) Option {
+	return func(h *Handler) {
+		h.dialContext = dial
+	}
+}
+
 // WithLogger 设置结构化日志记录器。
 func WithLogger(logger *slog.Logger) Option {
 	return func(h *Handler) {
@@ -60,16 +145,28 @@ func WithLogger(logger *slog.Logger) Option {
 	}
 }
 
+// WithSecretResolver 设置外部密钥解析器，用于解析上游凭据中的 secret_ref。
+func WithSecretResolver(resolver secrets.Resolver) Option {
+	return func(h *Handler) {
+		h.secrets = resolver
+	}
+}
+
 // NewHandler 创建 Proxy Handler。
 func NewHandler(service rules.Service, opts ...Option) *Handler {
 	h := &Handler{
 		service: service,
 		transport: &http.Transport{
-			Proxy:               http.ProxyFromEnvironment,
+			Proxy: http.ProxyFromEnvironment,
+			// 设置了 DialContext 时标准库不再自动启用 HTTP/2，需显式开启。
+			ForceAttemptHTTP2:   true,
 			MaxIdleConns:        100,
 			IdleConnTimeout:     90 * time.Second,
 			TLSHandshakeTimeout: 10 * time.Second,
 		},
+		sloThreshold: defaultSLOLatencyThreshold,
+		smartRoutes:  newStickyRoutes(),
+		affinity:     newStickyRoutes(),
 	}
 	for _, opt := range opts {
 		opt(h)
@@ -77,10 +174,40 @@ func NewHandler(service rules.Service, opts ...Option) *Handler {
 	if h.logger == nil {
 		h.logger = slog.Default()
 	}
+	if h.pools == nil {
+		h.pools = upstreams.NewPoolSelector()
+	}
+	if h.endpoints == nil {
+		h.endpoints = upstreams.NewEndpointScorer()
+	}
+	if h.tokenizer == nil {
+		h.tokenizer = tokenizer.New()
+	}
+	if transport, ok := h.transport.(*http.Transport); ok {
+		if h.dialContext != nil {
+			transport.DialContext = h.dialContext
+		}
+		if h.warm.Conns > transport.MaxIdleConnsPerHost {
+			transport.MaxIdleConnsPerHost = max(h.warm.Conns, http.DefaultMaxIdleConnsPerHost)
+		}
+	}
+	h.warmTransport = h.transport
+	if h.warmTransport == nil {
+		h.warmTransport = http.DefaultTransport
+	}
 	h.transport = wrapWithMetricsTransport(h.transport)
 	return h
 }
 
+// clientAuthenticated 报告请求是否通过 APIKeyAuth 解析出了 API Key 或绑定。
+func clientAuthenticated(c *gin.Context) bool {
+	if _, ok := middleware.CurrentAPIKey(c); ok {
+		return true
+	}
+	_, ok := middleware.CurrentBinding(c)
+	return ok
+}
+
 // RegisterRoutes 将代理注册为全局 fallback。
 func RegisterRoutes(engine *gin.Engine, handler *Handler) {
 	engine.NoRoute(handler.Handle)
@@ -89,21 +216,44 @@ func RegisterRoutes(engine *gin.Engine, handler *Handler) {
 
 // Handle 转发任意未命中的请求。
 func (h *Handler) Handle(c *gin.Context) {
+	defer h.finishTrace(c, h.startTrace(c))
+	if h.requireClientAuth.Load() && !clientAuthenticated(c) {
+		errcode.Respond(c, http.StatusUnauthorized, errcode.APIKeyRequired, "api key required")
+		return
+	}
 	binding, hasBinding := middleware.CurrentBinding(c)
 	upstreamInfo, hasUpstream := middleware.CurrentUpstreamInfo(c)
 	if hasBinding && hasUpstream {
 		if err := h.authorizeBinding(c, binding, upstreamInfo); err != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			traceError(c, err)
+			errcode.Respond(c, http.StatusForbidden, errcode.BindingUnauthorized, err.Error())
 			return
 		}
+		h.useBinding(c, binding, upstreamInfo.Credential)
+	}
+	if err := h.authorizeModel(c); err != nil {
+		traceError(c, err)
+		if errors.Is(err, errModelNotAllowed) {
+			errcode.Respond(c, http.StatusForbidden, errcode.ModelNotAllowed, err.Error())
+		} else {
+			errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "read request body failed")
+		}
+		return
+	}
+	rule, err := h.tracedMatchRule(c)
+	if errors.Is(err, errDefaultRuleAuthRequired) {
+		traceError(c, err)
+		errcode.Respond(c, http.StatusUnauthorized, errcode.APIKeyRequired, err.Error())
+		return
 	}
-	rule, err := h.matchRule(c)
 	if err != nil {
-		status := http.StatusBadGateway
+		traceError(c, err)
+		status, code := http.StatusBadGateway, errcode.RuleMatchFailed
 		if errors.Is(err, ErrNoMatchingRule) {
-			status = http.StatusNotFound
+			status, code = http.StatusNotFound, errcode.NoRule
 			if h.logger != nil {
 				h.logger.Info("no matching rule",
+					"request_id", middleware.RequestIDFromContext(c),
 					"method", c.Request.Method,
 					"path", c.Request.URL.Path,
 				)
@@ -111,38 +261,303 @@ func (h *Handler) Handle(c *gin.Context) {
 		}
 		if h.logger != nil && !errors.Is(err, ErrNoMatchingRule) {
 			h.logger.Error("match rule failed",
+				"request_id", middleware.RequestIDFromContext(c),
 				"error", err,
 				"method", c.Request.Method,
 				"path", c.Request.URL.Path,
 			)
 		}
-		c.JSON(status, gin.H{"error": err.Error()})
+		errcode.Respond(c, status, code, err.Error())
+		return
+	}
+	traceRule(c, rule)
+	defer h.trackSLO(c, rule)()
+	defer h.trackAnalytics(c, rule)()
+
+	if err := h.scopeBindingToService(c, rule); err != nil {
+		traceError(c, err)
+		status, code := http.StatusBadGateway, errcode.Internal
+		if errors.Is(err, errNoServiceBinding) {
+			status, code = http.StatusForbidden, errcode.NoServiceBinding
+		}
+		if h.logger != nil {
+			h.logger.Warn("resolve service binding failed",
+				"request_id", middleware.RequestIDFromContext(c),
+				"error", err,
+				"rule_id", rule.ID,
+				"path", c.Request.URL.Path,
+			)
+		}
+		errcode.Respond(c, status, code, err.Error())
+		return
+	}
+	binding, hasBinding = middleware.CurrentBinding(c)
+	if err := h.selectUpstreamByMetadata(c, rule); err != nil {
+		traceError(c, err)
+		if h.logger != nil {
+			h.logger.Warn("select upstream by metadata failed",
+				"request_id", middleware.RequestIDFromContext(c),
+				"error", err,
+				"rule_id", rule.ID,
+				"path", c.Request.URL.Path,
+			)
+		}
+		status, code := metadataSelectionError(err)
+		errcode.Respond(c, status, code, err.Error())
+		return
+	}
+	if err := h.smartRoute(c, rule); err != nil {
+		traceError(c, err)
+		if h.logger != nil {
+			h.logger.Warn("smart route failed",
+				"request_id", middleware.RequestIDFromContext(c),
+				"error", err,
+				"rule_id", rule.ID,
+				"path", c.Request.URL.Path,
+			)
+		}
+		status, code := smartRouteError(err)
+		errcode.Respond(c, status, code, err.Error())
+		return
+	}
+	h.resolveAffinity(c, rule)
+	if err := h.redactRequest(c, rule); err != nil {
+		traceError(c, err)
+		if errors.Is(err, errContentRejected) {
+			errcode.Respond(c, http.StatusBadRequest, errcode.ContentRejected, err.Error())
+		} else {
+			errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "read request body failed")
+		}
+		return
+	}
+	// 估算在审核之前进行，超出上限的请求不再调用审核服务。
+	if err := h.estimateTokens(c, rule); err != nil {
+		traceError(c, err)
+		if errors.Is(err, errPromptTooLarge) {
+			errcode.Respond(c, http.StatusRequestEntityTooLarge, errcode.PromptTooLarge, err.Error())
+		} else {
+			errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "read request body failed")
+		}
+		return
+	}
+	if err := h.moderatePrompt(c, rule); err != nil {
+		traceError(c, err)
+		status, code := moderationErrorStatus(err)
+		if code == errcode.InvalidRequest {
+			errcode.Respond(c, status, code, "read request body failed")
+		} else {
+			errcode.Respond(c, status, code, err.Error())
+		}
+		return
+	}
+	// 缓存键按打码后的请求体计算，命中时不再访问上游。
+	if served, err := h.serveCachedResponse(c, rule); err != nil {
+		traceError(c, err)
+		errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "read request body failed")
+		return
+	} else if served {
+		return
+	}
+	// 缓存命中不产生费用，预算只拦截需要访问上游的请求。
+	if !h.checkBudget(c) {
+		return
+	}
+	// 缓存命中的请求不占用转发名额；名额在整个转发过程（含故障转移与流式响应）中保持占用。
+	release, ok := h.acquireDispatch(c)
+	if !ok {
 		return
 	}
+	defer release()
+	// 按元数据或 smart_route 选出的凭据不参与按 Position 的故障转移，避免切换到不满足过滤条件或其他服务的凭据。
+	useFallback := hasBinding && len(rule.Actions.SelectUpstreamByMetadata) == 0 && !c.GetBool(smartRouteContextKey)
+	fallback := newBindingFallback(h.accountService, binding, useFallback)
+	delay := newDelayedRetry(h.retryAfterMax)
+	var body []byte
+	if (fallback != nil || len(rule.Actions.FallbackModels) > 0 || delay != nil) && c.Request.Body != nil {
+		// 缓存请求体，以便主凭据或主模型失败时向备用凭据、备用模型重放，或按 Retry-After 等待后重试。
+		body, err = io.ReadAll(c.Request.Body)
+		if err != nil {
+			errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "read request body failed")
+			return
+		}
+		_ = c.Request.Body.Close()
+	}
+	models := newModelFallback(rule, c.Request.Header, body, modelPermitter(c))
+	primary, hasPrimary := middleware.CurrentUpstreamInfo(c)
+	for {
+		if body != nil {
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Request.ContentLength = int64(len(body))
+		}
+		// 当前凭据已知额度耗尽时直接换用备用绑定，不再发送注定被限流的请求。
+		for h.credentialExhausted(c) {
+			next, ok := fallback.next(c.Request.Context())
+			if !ok {
+				break
+			}
+			if h.logger != nil {
+				h.logger.Info("skip exhausted upstream credential",
+					"request_id", middleware.RequestIDFromContext(c),
+					"rule_id", rule.ID,
+					"binding_id", next.Binding.ID,
+					"credential", next.Upstream.ID,
+				)
+			}
+			h.useBinding(c, next.Binding, next.Upstream)
+		}
+		if !h.forward(c, rule, fallback, models, delay) {
+			return
+		}
+		if wait, ok := delay.take(); ok {
+			if h.logger != nil {
+				h.logger.Warn("upstream retry after",
+					"request_id", middleware.RequestIDFromContext(c),
+					"rule_id", rule.ID,
+					"wait_ms", wait.Milliseconds(),
+				)
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+				timer.Stop()
+				errcode.Respond(c, statusClientClosed, errcode.ClientClosed, c.Request.Context().Err().Error())
+				return
+			}
+			continue
+		}
+		if next, ok := fallback.next(c.Request.Context()); ok {
+			if h.logger != nil {
+				h.logger.Warn("upstream failover",
+					"request_id", middleware.RequestIDFromContext(c),
+					"rule_id", rule.ID,
+					"binding_id", next.Binding.ID,
+					"credential", next.Upstream.ID,
+					"position", next.Binding.Position,
+				)
+			}
+			h.useBinding(c, next.Binding, next.Upstream)
+			continue
+		}
+		failed := models.model()
+		model, ok := models.next()
+		if !ok {
+			return
+		}
+		if body, err = setBodyModel(body, model); err != nil {
+			errcode.Respond(c, http.StatusBadRequest, errcode.InvalidRequest, "rewrite request model failed")
+			return
+		}
+		if h.logger != nil {
+			h.logger.Warn("model fallback",
+				"request_id", middleware.RequestIDFromContext(c),
+				"rule_id", rule.ID,
+				"from", failed,
+				"to", model,
+			)
+		}
+		// 新模型从主绑定开始重新走一遍同一 Service 的备用绑定。
+		if hasPrimary && hasBinding {
+			fallback = newBindingFallback(h.accountService, binding, useFallback)
+			h.useBinding(c, binding, primary.Credential)
+		}
+	}
+}
 
+// forward 将请求转发至当前绑定的上游。返回 true 表示上游失败且存在可用的备用绑定、备用模型或可按 Retry-After 重试，
+// 此时尚未向客户端写入任何响应。
+func (h *Handler) forward(c *gin.Context, rule rules.Rule, fallback *bindingFallback, models *modelFallback, delay *delayedRetry) bool {
 	targetURL, err := h.resolveTarget(c, rule)
 	if err != nil {
+		traceError(c, err)
 		if h.logger != nil {
 			h.logger.Error("resolve target failed",
+				"request_id", middleware.RequestIDFromContext(c),
 				"error", err,
 				"rule_id", rule.ID,
 				"path", c.Request.URL.Path,
 			)
 		}
-		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
-		return
+		errcode.Respond(c, http.StatusBadGateway, errcode.TargetNotConfigured, err.Error())
+		return false
+	}
+
+	attempt := reqtrace.Attempt{Target: targetURL.String(), Model: models.model(), Failover: fallback.switched() || models.switched() || delay.retried()}
+	if binding, ok := middleware.CurrentBinding(c); ok {
+		attempt.BindingID = binding.ID
+		attempt.Position = binding.Position
+	}
+	if info, ok := middleware.CurrentUpstreamInfo(c); ok {
+		attempt.CredentialID = info.Credential.ID
+	}
+	trace := currentTrace(c)
+	start := time.Now()
+	defer func() {
+		if trace != nil {
+			attempt.DurationMs = time.Since(start).Milliseconds()
+			trace.Attempts = append(trace.Attempts, attempt)
+		}
+	}()
+
+	if err := h.resolveUpstreamSecret(c); err != nil {
+		attempt.Error = "upstream credential unavailable"
+		if h.logger != nil {
+			h.logger.Error("resolve upstream secret failed",
+				"request_id", middleware.RequestIDFromContext(c),
+				"error", err,
+				"rule_id", rule.ID,
+				"path", c.Request.URL.Path,
+			)
+		}
+		if fallback.available(c.Request.Context()) {
+			return true
+		}
+		traceError(c, errors.New(attempt.Error))
+		errcode.Respond(c, http.StatusBadGateway, errcode.UpstreamCredentialUnavailable, "upstream credential unavailable")
+		return false
 	}
 
+	ctx, span := telemetry.Tracer().Start(c.Request.Context(), "proxy.upstream",
+		oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+		oteltrace.WithAttributes(
+			attribute.String("yapi.rule_id", rule.ID),
+			attribute.String("server.address", targetURL.Host),
+			attribute.Bool("yapi.failover", attempt.Failover),
+		))
+	defer span.End()
+	if attempt.CredentialID != "" {
+		span.SetAttributes(attribute.String("yapi.credential_id", attempt.CredentialID))
+	}
+	var genAI *genAICall
+	defer func() {
+		genAI.observe(time.Since(start), attempt.Status, attempt.Error != "")
+	}()
+
+	labels := currentUsageLabels(c)
+	record := currentAnalyticsRecord(c)
+	record.setUpstream(targetURL.Host)
+	var bodyLog *bodyLogEntry
+	var conversation *archiveEntry
+	var export *exportEntry
+	failover := false
+	translator := newTranslator(c, rule)
+	var translateErr error
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
 	proxy.Transport = h.transport
+	if translator != nil {
+		proxy.Transport = &translateTransport{base: h.transport, err: &translateErr}
+	}
 	originalDirector := proxy.Director
 	proxy.Director = func(req *http.Request) {
 		originalDirector(req)
 		middleware.WithRequestID(req, middleware.RequestIDFromContext(c))
+		// 归档在规则动作之前包装请求体，保存的是客户端发来的原始请求，重放时不会把改写再执行一遍。
+		conversation = h.startArchive(c, req, rule)
 		if err := h.applyRuleActions(c, req, rule); err != nil {
 			req.Header.Add("X-YAPI-Body-Rewrite-Error", err.Error())
 			if h.logger != nil {
 				h.logger.Warn("apply rule actions failed",
+					"request_id", middleware.RequestIDFromContext(c),
 					"error", err,
 					"rule_id", rule.ID,
 					"path", req.URL.Path,
@@ -150,17 +565,115 @@ func (h *Handler) Handle(c *gin.Context) {
 				)
 			}
 		}
+		if models.switched() {
+			trace.RecordAction("fallback_models")
+		}
+		// 别名在规则动作之后解析，override_json 写入的别名同样生效；协议转换看到的是实际模型。
+		if aliased, err := h.applyModelAlias(c, req); err != nil {
+			if h.logger != nil {
+				h.logger.Warn("apply model alias failed", "request_id", middleware.RequestIDFromContext(c), "error", err, "rule_id", rule.ID, "path", req.URL.Path)
+			}
+		} else if aliased {
+			trace.RecordAction("model_alias")
+		}
+		export = h.startTraceExport(c, req, rule)
+		if translator != nil {
+			// 协议转换在其他规则动作之后进行，使 override_json 等动作仍作用于客户端协议的请求体。
+			if translateErr = translator.Request(req); translateErr != nil {
+				return
+			}
+			trace.RecordAction("translate_protocol")
+		}
+		attempt.Path = req.URL.Path
+		genAI = startGenAI(c, req, span)
+		// 在规则动作之后注入，确保上游收到的 traceparent 指向本次上游调用 span，而不是客户端透传的值。
+		telemetry.InjectHeaders(req.Context(), req.Header)
+		bodyLog = h.startBodyLog(req,
+			"request_id", middleware.RequestIDFromContext(c),
+			"rule_id", rule.ID,
+			"target", targetURL.Host,
+		)
+	}
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		attempt.Status = resp.StatusCode
+		attempt.LatencyMs = time.Since(start).Milliseconds()
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		if resp.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+		}
+		h.observeRateLimits(c, resp)
+		h.observeEndpoint(targetURL, time.Since(start), resp.StatusCode >= http.StatusInternalServerError)
+		if retryable(resp.Request.Context(), resp.StatusCode, fallback, models) || delay.accept(resp) {
+			return fmt.Errorf("%w: status %d", errUpstreamFailover, resp.StatusCode)
+		}
+		if resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests {
+			h.rememberAffinity(c, rule, targetURL)
+		}
+		if models != nil {
+			resp.Header.Set(servedModelHeader, models.model())
+		}
+		if isStreamingResponse(resp) {
+			clearConnDeadlines(c.Writer)
+		}
+		h.meterUsage(resp, labels, record, genAI)
+		bodyLog.finishWithResponse(resp)
+		if replaced, err := h.checkResponseContract(c, rule, resp); err != nil || replaced {
+			return err
+		}
+		if replaced, err := h.moderateResponse(c, rule, resp); err != nil || replaced {
+			return err
+		}
+		if translator != nil {
+			// 用量统计与请求体日志记录上游的原始响应，转换在其后包装响应体。
+			if err := translator.Response(resp); err != nil {
+				return err
+			}
+		}
+		// 工具调用按客户端协议过滤，因此在协议转换之后进行。
+		if err := h.filterToolCalls(rule, resp); err != nil {
+			return err
+		}
+		h.watchStream(c, rule, resp)
+		// 缓存与归档客户端实际收到的（转换后的）响应。
+		if err := h.storeCachedResponse(c, rule, resp); err != nil {
+			return err
+		}
+		conversation.finish(resp)
+		export.finish(resp)
+		return nil
 	}
 	proxy.ErrorHandler = func(rw http.ResponseWriter, req *http.Request, proxyErr error) {
-		status := http.StatusBadGateway
-		if errors.Is(proxyErr, context.Canceled) {
-			status = 499 // 客户端主动取消
+		attempt.Error = proxyErr.Error()
+		telemetry.RecordError(span, proxyErr)
+		bodyLog.finishWithError(proxyErr)
+		if attempt.LatencyMs == 0 {
+			attempt.LatencyMs = time.Since(start).Milliseconds()
+		}
+		// 客户端取消与无法转换的请求都与上游无关，既不计入端点健康也不切换绑定。
+		clientErr := errors.Is(proxyErr, context.Canceled) || errors.Is(proxyErr, translate.ErrInvalidRequest)
+		if !clientErr && !errors.Is(proxyErr, errUpstreamFailover) {
+			// 因状态码触发的切换已在 ModifyResponse 中记录。
+			h.observeEndpoint(targetURL, time.Since(start), true)
+		}
+		if !clientErr && (errors.Is(proxyErr, errUpstreamFailover) || fallback.available(req.Context()) || models.available()) {
+			failover = true
+			return
 		}
-		http.Error(rw, proxyErr.Error(), status)
+		traceError(c, proxyErr)
+		status, code := upstreamErrorStatus(proxyErr)
+		errcode.Set(c, code)
+		rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+		rw.WriteHeader(status)
+		_ = json.NewEncoder(rw).Encode(errcode.ContextBody(c, code, proxyErr.Error()))
 	}
-	start := time.Now()
 	rec := &responseRecorder{ResponseWriter: c.Writer, status: http.StatusOK}
-	proxy.ServeHTTP(rec, c.Request)
+	proxy.ServeHTTP(rec, c.Request.WithContext(ctx))
+	if failover {
+		return true
+	}
+	if attempt.Status == 0 {
+		attempt.Status = rec.status
+	}
 	if h.logger != nil {
 		h.logger.Info("proxy upstream",
 			"request_id", middleware.RequestIDFromContext(c),
@@ -173,6 +686,43 @@ func (h *Handler) Handle(c *gin.Context) {
 			"latency_ms", time.Since(start).Milliseconds(),
 		)
 	}
+	return false
+}
+
+// upstreamErrorStatus 将上游调用失败映射为响应状态码与错误码：请求无法进行协议转换为 400，客户端取消为 499，
+// 超时为 504，其余为 502。
+func upstreamErrorStatus(err error) (int, errcode.Code) {
+	if errors.Is(err, translate.ErrInvalidRequest) {
+		return http.StatusBadRequest, errcode.InvalidRequest
+	}
+	if errors.Is(err, context.Canceled) {
+		return statusClientClosed, errcode.ClientClosed
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return http.StatusGatewayTimeout, errcode.UpstreamTimeout
+	}
+	return http.StatusBadGateway, errcode.UpstreamUnavailable
+}
+
+// tracedMatchRule 在 proxy.match_rule span 中执行规则匹配。
+func (h *Handler) tracedMatchRule(c *gin.Context) (rules.Rule, error) {
+	_, span := telemetry.Tracer().Start(c.Request.Context(), "proxy.match_rule")
+	defer span.End()
+	rule, err := h.matchRule(c)
+	if err != nil {
+		if !errors.Is(err, ErrNoMatchingRule) && !errors.Is(err, errDefaultRuleAuthRequired) {
+			telemetry.RecordError(span, err)
+		}
+		span.SetAttributes(attribute.Bool("yapi.rule_matched", false))
+		return rule, err
+	}
+	span.SetAttributes(
+		attribute.Bool("yapi.rule_matched", true),
+		attribute.String("yapi.rule_id", rule.ID),
+		attribute.Int("yapi.rule_priority", rule.Priority),
+	)
+	return rule, nil
 }
 
 func (h *Handler) matchRule(c *gin.Context) (rules.Rule, error) {
@@ -185,16 +735,21 @@ func (h *Handler) matchRule(c *gin.Context) (rules.Rule, error) {
 			continue
 		}
 		if matchesRequest(c, rule.Matcher) {
+			metrics.ObserveRuleMatch(rule.ID)
 			return rule, nil
 		}
 	}
-	if h.defaultTarget != nil {
+	if defaultTarget := h.defaultTarget.Load(); defaultTarget != nil {
+		if err := h.defaultRuleAllows(c); err != nil {
+			return rules.Rule{}, err
+		}
+		metrics.ObserveRuleMatch("default")
 		return rules.Rule{
 			ID:       "default",
 			Priority: -1,
 			Matcher:  rules.Matcher{PathPrefix: "/"},
 			Actions: rules.Actions{
-				SetTargetURL: h.defaultTarget.String(),
+				SetTargetURL: defaultTarget.String(),
 			},
 			Enabled: true,
 		}, nil
@@ -203,109 +758,49 @@ func (h *Handler) matchRule(c *gin.Context) (rules.Rule, error) {
 }
 
 func matchesRequest(c *gin.Context, matcher rules.Matcher) bool {
-	if matcher.PathPrefix != "" && !strings.HasPrefix(c.FullPath(), matcher.PathPrefix) && !strings.HasPrefix(c.Request.URL.Path, matcher.PathPrefix) {
-		return false
-	}
-	if len(matcher.Methods) > 0 {
-		methodMatched := false
-		for _, method := range matcher.Methods {
-			if strings.EqualFold(c.Request.Method, method) {
-				methodMatched = true
-				break
-			}
-		}
-		if !methodMatched {
-			return false
-		}
-	}
-	for key, pattern := range matcher.Headers {
-		headerValue := c.GetHeader(key)
-		if pattern == "" && headerValue == "" {
-			continue
-		}
-		matched, err := regexp.MatchString(pattern, headerValue)
-		if err != nil || !matched {
-			return false
-		}
-	}
+	return matcher.Matches(matchRequest(c))
+}
 
-	apiKey, hasAPIKey := middleware.CurrentAPIKey(c)
-	rawKey, _ := middleware.RawAPIKey(c)
-	binding, hasBinding := middleware.CurrentBinding(c)
-	upstreamInfo, hasUpstream := middleware.CurrentUpstreamInfo(c)
-	user, hasUser := middleware.CurrentUser(c)
-	if matcher.RequireBinding {
-		if !hasBinding {
-			return false
-		}
-	}
-	if len(matcher.APIKeyIDs) > 0 {
-		if !hasAPIKey {
-			return false
-		}
-		if !stringInSliceTrimmed(matcher.APIKeyIDs, apiKey.ID, false) {
-			return false
-		}
+// matchRequest 从当前请求与认证上下文中提取规则匹配所需的属性。
+func matchRequest(c *gin.Context) rules.MatchRequest {
+	req := rules.MatchRequest{
+		Path:      c.Request.URL.Path,
+		RoutePath: c.FullPath(),
+		Method:    c.Request.Method,
+		Header:    c.Request.Header,
 	}
-	if len(matcher.APIKeyPrefixes) > 0 {
-		prefix := strings.TrimSpace(apiKey.Prefix)
-		if prefix == "" && rawKey != "" {
-			parts := strings.Split(rawKey, "_")
-			if len(parts) >= 3 {
-				prefix = parts[1]
-			}
-		}
-		if prefix == "" || !stringInSliceTrimmed(matcher.APIKeyPrefixes, prefix, true) {
-			return false
-		}
+	if apiKey, ok := middleware.CurrentAPIKey(c); ok {
+		req.APIKeyID = apiKey.ID
+		req.APIKeyPrefix = strings.TrimSpace(apiKey.Prefix)
 	}
-	if len(matcher.UserIDs) > 0 {
-		if !hasUser {
-			return false
-		}
-		if !stringInSliceTrimmed(matcher.UserIDs, user.ID, false) {
-			return false
+	if rawKey, _ := middleware.RawAPIKey(c); req.APIKeyPrefix == "" && rawKey != "" {
+		if parts := strings.Split(rawKey, "_"); len(parts) >= 3 {
+			req.APIKeyPrefix = parts[1]
 		}
 	}
-	if len(matcher.UserMetadata) > 0 {
-		if !hasUser || user.Metadata == nil {
-			return false
-		}
-		if !metadataContains(map[string]any(user.Metadata), matcher.UserMetadata) {
-			return false
-		}
+	if user, ok := middleware.CurrentUser(c); ok {
+		req.UserID = user.ID
+		req.UserMetadata = user.Metadata
 	}
-	if len(matcher.BindingUpstreamIDs) > 0 {
-		if !hasBinding {
-			return false
-		}
-		if !stringInSliceTrimmed(matcher.BindingUpstreamIDs, binding.UpstreamKeyID, false) {
-			return false
-		}
+	if binding, ok := middleware.CurrentBinding(c); ok {
+		req.HasBinding = true
+		req.BindingUpstreamID = binding.UpstreamKeyID
 	}
-	if len(matcher.BindingProviders) > 0 {
-		if !hasUpstream {
-			return false
-		}
-		if !stringInSliceTrimmed(matcher.BindingProviders, upstreamInfo.Credential.Service, true) {
-			return false
-		}
+	if upstreamInfo, ok := middleware.CurrentUpstreamInfo(c); ok {
+		req.Provider = upstreamInfo.Credential.Service
 	}
-	return true
+	return req
 }
 
 func (h *Handler) resolveTarget(c *gin.Context, rule rules.Rule) (*url.URL, error) {
 	if info, ok := middleware.CurrentUpstreamInfo(c); ok {
-		if len(info.Endpoints) > 0 {
-			target, err := url.Parse(strings.TrimSpace(info.Endpoints[0]))
-			if err == nil {
-				return target, nil
-			}
+		if target, ok := h.selectEndpoint(info.Endpoints, c.GetString(affinityEndpointContextKey)); ok {
+			return target, nil
 		}
 	}
 	target := rule.Actions.SetTargetURL
-	if target == "" && h.defaultTarget != nil {
-		return h.defaultTarget, nil
+	if defaultTarget := h.defaultTarget.Load(); target == "" && defaultTarget != nil {
+		return defaultTarget, nil
 	}
 	if target == "" {
 		return nil, errors.New("rule target not configured")
@@ -319,51 +814,157 @@ func (h *Handler) resolveTarget(c *gin.Context, rule rules.Rule) (*url.URL, erro
 
 func (h *Handler) applyRuleActions(c *gin.Context, req *http.Request, rule rules.Rule) error {
 	actions := rule.Actions
-	for key, value := range actions.SetHeaders {
+	trace := currentTrace(c)
+	trace.ResetMutations()
+	if c.GetBool(redactionContextKey) {
+		trace.RecordAction("redact_pii")
+	}
+	if c.GetBool(moderationContextKey) {
+		trace.RecordAction("moderation")
+	}
+	if c.GetBool(smartRouteContextKey) {
+		trace.RecordAction("smart_route")
+	}
+	if c.GetBool(affinityContextKey) {
+		trace.RecordAction("affinity")
+	}
+	setHeader := func(key, value string) {
 		req.Header.Set(key, value)
+		trace.RecordHeader("set", key, value)
+	}
+	for key, value := range actions.SetHeaders {
+		setHeader(key, value)
+		trace.RecordAction("set_headers")
 	}
 	for key, value := range actions.AddHeaders {
 		req.Header.Add(key, value)
+		trace.RecordHeader("add", key, value)
+		trace.RecordAction("add_headers")
 	}
 	for _, key := range actions.RemoveHeaders {
 		req.Header.Del(key)
+		trace.RecordHeader("remove", key, "")
+		trace.RecordAction("remove_headers")
 	}
 	if auth := strings.TrimSpace(actions.SetAuthorization); auth != "" {
-		req.Header.Set("Authorization", auth)
+		setHeader("Authorization", auth)
+		trace.RecordAction("set_authorization")
+	}
+	// beta 特性头在其他请求头动作之后处理，set_headers 写入的取值同样受策略约束。
+	for _, change := range applyBetaHeaders(req.Header, actions.BetaHeaders) {
+		if change[1] == "" {
+			trace.RecordHeader("remove", change[0], "")
+		} else {
+			trace.RecordHeader("set", change[0], change[1])
+		}
+		trace.RecordAction("beta_headers")
 	}
 
 	if expr := actions.RewritePathRegex; expr != nil {
 		re, err := regexp.Compile(expr.Pattern)
 		if err == nil {
 			req.URL.Path = re.ReplaceAllString(req.URL.Path, expr.Replace)
+			trace.RecordAction("rewrite_path_regex")
 		}
 	}
 	if len(actions.OverrideJSON) > 0 || len(actions.RemoveJSON) > 0 {
-		if err := rewriteJSONBody(req, actions.OverrideJSON, actions.RemoveJSON); err != nil {
+		_, span := telemetry.Tracer().Start(req.Context(), "proxy.rewrite_body")
+		err := rewriteJSONBody(req, actions.OverrideJSON, actions.RemoveJSON)
+		telemetry.RecordError(span, err)
+		span.End()
+		if err != nil {
+			return err
+		}
+		for key, value := range actions.OverrideJSON {
+			trace.RecordBody("override", key, value)
+			trace.RecordAction("override_json")
+		}
+		for _, key := range actions.RemoveJSON {
+			trace.RecordBody("remove", key, nil)
+			trace.RecordAction("remove_json")
+		}
+	}
+	changes, err := clampJSONParams(req, actions.ClampParams)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		trace.RecordBody("clamp", change.Path, change.Value)
+		trace.RecordAction("clamp_params")
+	}
+	if prompt := actions.SystemPrompt; prompt != nil {
+		user, _ := middleware.CurrentUser(c)
+		path, err := applySystemPrompt(req, prompt, renderSystemPrompt(prompt.Template, user))
+		if err != nil {
+			return err
+		}
+		if path != "" {
+			trace.RecordBody("system_prompt", path, prompt.Mode)
+			trace.RecordAction("system_prompt")
+		}
+	}
+	if policy := actions.ToolPolicy; policy != nil {
+		changed, err := applyToolPolicy(req, policy)
+		if err != nil {
 			return err
 		}
+		if changed {
+			trace.RecordAction("tool_policy")
+		}
 	}
 	if info, ok := middleware.CurrentUpstreamInfo(c); ok {
-		if apiKey := strings.TrimSpace(info.Credential.APIKey); apiKey != "" {
-			req.Header.Set("Authorization", "Bearer "+apiKey)
+		if apiKey := upstreamAPIKey(c, info.Credential); apiKey != "" {
+			setHeader("Authorization", "Bearer "+apiKey)
+			trace.RecordAction("upstream_credential")
 		}
 		if service := strings.TrimSpace(info.Credential.Service); service != "" {
-			req.Header.Set("X-Upstream-Service", service)
-			req.Header.Set("X-Upstream-Provider", service)
+			setHeader("X-Upstream-Service", service)
+			setHeader("X-Upstream-Provider", service)
 		}
 		if name := strings.TrimSpace(info.Credential.Name); name != "" {
-			req.Header.Set("X-Upstream-Name", name)
+			setHeader("X-Upstream-Name", name)
 		}
 		if info.Credential.ID != "" {
-			req.Header.Set("X-Upstream-Credential-ID", info.Credential.ID)
+			setHeader("X-Upstream-Credential-ID", info.Credential.ID)
 		}
 	}
 	if user, ok := middleware.CurrentUser(c); ok && strings.TrimSpace(user.ID) != "" {
-		req.Header.Set("X-YAPI-User-ID", user.ID)
+		setHeader("X-YAPI-User-ID", user.ID)
+	}
+	return nil
+}
+
+// resolveUpstreamSecret 在转发前解析外部密钥引用，结果仅保存在当前请求上下文。
+func (h *Handler) resolveUpstreamSecret(c *gin.Context) error {
+	// 清除上一次尝试（故障转移前）解析出的密钥。
+	c.Set(resolvedSecretKey, "")
+	info, ok := middleware.CurrentUpstreamInfo(c)
+	if !ok || !info.Credential.UsesSecretRef() {
+		return nil
+	}
+	if h.secrets == nil {
+		return errors.New("secret resolver not configured")
+	}
+	value, err := h.secrets.Resolve(c.Request.Context(), info.Credential.SecretRef)
+	if err != nil {
+		return err
 	}
+	c.Set(resolvedSecretKey, value)
 	return nil
 }
 
+func upstreamAPIKey(c *gin.Context, cred accounts.UpstreamCredential) string {
+	if apiKey := strings.TrimSpace(cred.APIKey); apiKey != "" {
+		return apiKey
+	}
+	if value, ok := c.Get(resolvedSecretKey); ok {
+		if resolved, ok := value.(string); ok {
+			return strings.TrimSpace(resolved)
+		}
+	}
+	return ""
+}
+
 func rewriteJSONBody(req *http.Request, override map[string]any, remove []string) error {
 	if req.Body == nil {
 		return errors.New("missing request body")
@@ -418,46 +1019,6 @@ func rewriteJSONBody(req *http.Request, override map[string]any, remove []string
 	return nil
 }
 
-func stringInSliceTrimmed(list []string, target string, caseInsensitive bool) bool {
-	target = strings.TrimSpace(target)
-	if target == "" {
-		return false
-	}
-	for _, item := range list {
-		trimmed := strings.TrimSpace(item)
-		if trimmed == "" {
-			continue
-		}
-		if caseInsensitive {
-			if strings.EqualFold(trimmed, target) {
-				return true
-			}
-		} else if trimmed == target {
-			return true
-		}
-	}
-	return false
-}
-
-func metadataContains(metadata map[string]any, expected map[string]string) bool {
-	if len(expected) == 0 {
-		return true
-	}
-	if len(metadata) == 0 {
-		return false
-	}
-	for key, value := range expected {
-		actual, ok := metadata[key]
-		if !ok {
-			return false
-		}
-		if strings.TrimSpace(fmt.Sprint(actual)) != strings.TrimSpace(value) {
-			return false
-		}
-	}
-	return true
-}
-
 func (h *Handler) authorizeBinding(c *gin.Context, binding accounts.UserAPIKeyBinding, upstream middleware.UpstreamInfo) error {
 	if upstream.Credential.ID == "" {
 		return errors.New("upstream credential missing")
@@ -486,6 +1047,24 @@ func tokensToSJSONPath(tokens []rules.JSONPathToken) string {
 	return strings.Join(parts, ".")
 }
 
+// isStreamingResponse 判断上游响应是否为流式响应，条件与 httputil.ReverseProxy 立即刷新的条件一致：
+// SSE（text/event-stream）或长度未知的响应体。
+func isStreamingResponse(resp *http.Response) bool {
+	if resp.ContentLength == -1 {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// clearConnDeadlines 解除服务端 SERVER_READ_TIMEOUT / SERVER_WRITE_TIMEOUT 对当前连接设置的时限，
+// 使流式响应可以持续到上游结束，而不是在固定时长后被截断。连接不支持时忽略。
+func clearConnDeadlines(w http.ResponseWriter) {
+	controller := http.NewResponseController(w)
+	_ = controller.SetReadDeadline(time.Time{})
+	_ = controller.SetWriteDeadline(time.Time{})
+}
+
 type responseRecorder struct {
 	gin.ResponseWriter
 	status int
@@ -503,6 +1082,11 @@ func (r *responseRecorder) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap 供 http.ResponseController 访问底层连接。
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 type metricsRoundTripper struct {
 	base http.RoundTripper
 }
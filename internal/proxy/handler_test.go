@@ -4,20 +4,37 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"gorm.io/datatypes"
 
+	"github.com/prehisle/yapi/internal/archive"
+	"github.com/prehisle/yapi/internal/budget"
+	"github.com/prehisle/yapi/internal/dispatch"
+	"github.com/prehisle/yapi/internal/errcode"
 	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/modelalias"
+	"github.com/prehisle/yapi/internal/respcache"
+	"github.com/prehisle/yapi/internal/traceexport"
+	"github.com/prehisle/yapi/internal/upstreams"
+	"github.com/prehisle/yapi/internal/usage"
 	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/metrics"
 	"github.com/prehisle/yapi/pkg/rules"
+	"github.com/prehisle/yapi/pkg/secrets"
 )
 
 func TestApplyRuleActions_ModifyJSONAndHeaders(t *testing.T) {
@@ -149,6 +166,794 @@ func TestHandler_StreamPassthrough(t *testing.T) {
 	require.Equal(t, strings.Join(chunks, ""), string(body))
 }
 
+func TestHandler_StreamOutlivesServerTimeouts(t *testing.T) {
+	chunks := []string{"data: first\n\n", "data: second\n\n", "data: [DONE]\n\n"}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			_, _ = io.WriteString(w, chunk)
+			w.(http.Flusher).Flush()
+			time.Sleep(150 * time.Millisecond)
+		}
+	}))
+	defer upstream.Close()
+
+	svc := rules.NewService(rules.NewMemoryStore())
+	require.NoError(t, svc.UpsertRule(context.Background(), rules.Rule{
+		ID:      "stream-rule",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: upstream.URL},
+	}))
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(svc))
+	server := httptest.NewUnstartedServer(router)
+	// 整个流约 450ms，超过服务端的读写时限；流式响应应解除时限而不是被截断。
+	server.Config.ReadTimeout = 200 * time.Millisecond
+	server.Config.WriteTimeout = 200 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v1/chat")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, strings.Join(chunks, ""), string(body))
+}
+
+func TestHandler_TranslateProtocol(t *testing.T) {
+	var upstreamHits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits.Add(1)
+		require.Equal(t, "/v1/messages", r.URL.Path)
+		require.Equal(t, "sk-test", r.Header.Get("x-api-key"))
+		require.Empty(t, r.Header.Get("Authorization"))
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, "be brief", body["system"])
+		require.EqualValues(t, 64, body["max_tokens"])
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"msg_1","model":"claude-sonnet-4","content":[{"type":"text","text":"hi there"}],"stop_reason":"end_turn","usage":{"input_tokens":4,"output_tokens":2}}`)
+	}))
+	defer upstream.Close()
+
+	svc := rules.NewService(rules.NewMemoryStore())
+	require.NoError(t, svc.UpsertRule(context.Background(), rules.Rule{
+		ID:      "translate-rule",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: upstream.URL, TranslateProtocol: rules.TranslateOpenAIToAnthropic},
+	}))
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(svc))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions", strings.NewReader(
+		`{"model":"claude-sonnet-4","max_tokens":64,"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hello"}]}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer sk-test")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var completion map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&completion))
+	require.Equal(t, "chat.completion", completion["object"])
+	choice := completion["choices"].([]any)[0].(map[string]any)
+	require.Equal(t, "stop", choice["finish_reason"])
+	require.Equal(t, "hi there", choice["message"].(map[string]any)["content"])
+
+	// 无法转换的请求直接返回 400，不访问上游。
+	resp, err = http.Post(server.URL+"/v1/embeddings", "application/json", strings.NewReader(`{"input":"x"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Contains(t, string(body), string(errcode.InvalidRequest))
+	require.EqualValues(t, 1, upstreamHits.Load())
+}
+
+func TestHandler_TranslatesForGeminiCredential(t *testing.T) {
+	var gotPath, gotKey, gotAuth string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotKey, gotAuth = r.URL.Path, r.URL.Query().Get("key"), r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"hi"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":1}}`)
+	}))
+	defer upstream.Close()
+
+	binding := accounts.UserAPIKeyBinding{ID: "b-1", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-gemini", Service: "gemini"}
+	cred := accounts.UpstreamCredential{ID: "cred-gemini", UserID: "user-1", Service: "gemini", APIKey: "gm-key", Enabled: true}
+	svc := &ruleServiceStub{rules: []rules.Rule{{
+		ID:      "gemini",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1beta"},
+		Actions: rules.Actions{SetTargetURL: upstream.URL},
+	}}}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetBinding(c, binding, cred)
+		c.Next()
+	})
+	RegisterRoutes(router, NewHandler(svc))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/v1beta/chat/completions", "application/json",
+		strings.NewReader(`{"model":"gemini-2.5-flash","messages":[{"role":"user","content":"hello"}]}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var completion map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&completion))
+	require.Equal(t, "chat.completion", completion["object"])
+	require.Equal(t, "/v1beta/models/gemini-2.5-flash:generateContent", gotPath)
+	require.Equal(t, "gm-key", gotKey)
+	require.Empty(t, gotAuth)
+
+	// 原生 Gemini 请求不做转换。
+	resp, err = http.Post(server.URL+"/v1beta/models/gemini-2.5-flash:generateContent", "application/json",
+		strings.NewReader(`{"contents":[]}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "/v1beta/models/gemini-2.5-flash:generateContent", gotPath)
+	require.Equal(t, "Bearer gm-key", gotAuth)
+}
+
+func TestHandler_RoutesAzureCredential(t *testing.T) {
+	var gotPath, gotVersion, gotKey string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotVersion, gotKey = r.URL.Path, r.URL.Query().Get("api-version"), r.Header.Get("api-key")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	binding := accounts.UserAPIKeyBinding{ID: "b-1", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-azure", Service: "azure"}
+	cred := accounts.UpstreamCredential{
+		ID: "cred-azure", UserID: "user-1", Service: "azure", APIKey: "az-key", Enabled: true,
+		Metadata: datatypes.JSONMap{"deployments": map[string]any{"gpt-4o": "prod-gpt4o"}},
+	}
+	svc := &ruleServiceStub{rules: []rules.Rule{{
+		ID:      "azure",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: upstream.URL},
+	}}}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetBinding(c, binding, cred)
+		c.Next()
+	})
+	RegisterRoutes(router, NewHandler(svc))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+		strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hello"}]}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "/openai/deployments/prod-gpt4o/chat/completions", gotPath)
+	require.NotEmpty(t, gotVersion)
+	require.Equal(t, "az-key", gotKey)
+}
+
+func TestHandler_ResolvesModelAlias(t *testing.T) {
+	var gotPath, gotBody string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotPath, gotBody = r.URL.Path, string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	aliases := modelalias.NewService(modelalias.NewMemoryStore())
+	_, err := aliases.Upsert(context.Background(), modelalias.Alias{Name: "fast", Target: "gpt-4o-mini", Providers: map[string]string{"azure": "gpt-4o"}})
+	require.NoError(t, err)
+
+	binding := accounts.UserAPIKeyBinding{ID: "b-1", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-azure", Service: "azure"}
+	cred := accounts.UpstreamCredential{
+		ID: "cred-azure", UserID: "user-1", Service: "azure", APIKey: "az-key", Enabled: true,
+		Metadata: datatypes.JSONMap{"deployments": map[string]any{"gpt-4o": "prod-gpt4o"}},
+	}
+	svc := &ruleServiceStub{rules: []rules.Rule{{
+		ID:      "aliases",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: upstream.URL},
+	}}}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if c.GetHeader("X-Test-Azure") != "" {
+			middleware.SetBinding(c, binding, cred)
+		}
+		c.Next()
+	})
+	RegisterRoutes(router, NewHandler(svc, WithModelAliases(aliases)))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	send := func(azure bool) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions",
+			strings.NewReader(`{"model":"fast","messages":[{"role":"user","content":"hello"}]}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if azure {
+			req.Header.Set("X-Test-Azure", "1")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	send(false)
+	require.Equal(t, "/v1/chat/completions", gotPath)
+	require.JSONEq(t, `{"model":"gpt-4o-mini","messages":[{"role":"user","content":"hello"}]}`, gotBody)
+
+	// Provider 专属映射优先，且在选择 Azure 部署之前生效。
+	send(true)
+	require.Equal(t, "/openai/deployments/prod-gpt4o/chat/completions", gotPath)
+	require.JSONEq(t, `{"model":"gpt-4o","messages":[{"role":"user","content":"hello"}]}`, gotBody)
+}
+
+func TestHandler_FallbackModels(t *testing.T) {
+	var models []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		models = append(models, payload.Model)
+		switch payload.Model {
+		case "gpt-4o":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "gpt-4o-mini":
+			w.WriteHeader(529)
+		case "locked":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer upstream.Close()
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{
+		ID:      "fallback",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: upstream.URL, FallbackModels: []string{"gpt-4o-mini", "gpt-3.5-turbo"}},
+	}}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(svc))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"gpt-4o","messages":[]}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "gpt-3.5-turbo", resp.Header.Get("X-YAPI-Model"))
+	require.Equal(t, []string{"gpt-4o", "gpt-4o-mini", "gpt-3.5-turbo"}, models)
+
+	// 401 是凭据问题，不切换模型。
+	models = nil
+	resp, err = http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"locked"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, "locked", resp.Header.Get("X-YAPI-Model"))
+	require.Equal(t, []string{"locked"}, models)
+}
+
+func TestHandler_ModelAccess(t *testing.T) {
+	var models []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		models = append(models, payload.Model)
+		if payload.Model == "gpt-4o-mini" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{
+		ID:      "models",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: upstream.URL, FallbackModels: []string{"gpt-4o", "gpt-3.5-turbo"}},
+	}}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("auth_user", accounts.User{ID: "user-1", ModelAccess: accounts.ModelAccess{DeniedModels: datatypes.JSONSlice[string]{"o1*"}}})
+		c.Set("auth_api_key", accounts.APIKey{ID: "key-1", UserID: "user-1", ModelAccess: accounts.ModelAccess{AllowedModels: datatypes.JSONSlice[string]{"gpt-4o-mini", "gpt-3.5-*"}}})
+		c.Next()
+	})
+	RegisterRoutes(router, NewHandler(svc))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	for _, model := range []string{"gpt-4o", "o1-preview"} {
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"`+model+`"}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusForbidden, resp.StatusCode, model)
+	}
+	require.Empty(t, models)
+
+	// 备用模型中不在白名单内的 gpt-4o 被跳过。
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"gpt-4o-mini"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []string{"gpt-4o-mini", "gpt-3.5-turbo"}, models)
+}
+
+func TestHandler_ClampParams(t *testing.T) {
+	var received map[string]any
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = nil
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	maxTokens, defaultTokens, one := 1024.0, 256.0, 1.0
+	svc := &ruleServiceStub{rules: []rules.Rule{{
+		ID:      "clamp",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: upstream.URL, ClampParams: map[string]rules.ParamBound{
+			"max_tokens":  {Max: &maxTokens, Default: &defaultTokens},
+			"temperature": {Max: &one},
+			"n":           {Max: &one},
+		}},
+	}}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(svc))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"gpt-4o","max_tokens":4096,"temperature":0.5,"n":3}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, map[string]any{"model": "gpt-4o", "max_tokens": 1024.0, "temperature": 0.5, "n": 1.0}, received)
+
+	resp, err = http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"gpt-4o"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, map[string]any{"model": "gpt-4o", "max_tokens": 256.0}, received)
+}
+
+func TestHandler_SystemPrompt(t *testing.T) {
+	var received map[string]any
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = nil
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	svc := &ruleServiceStub{rules: []rules.Rule{
+		{
+			ID:       "anthropic",
+			Priority: 10,
+			Enabled:  true,
+			Matcher:  rules.Matcher{PathPrefix: "/v1/messages"},
+			Actions:  rules.Actions{SetTargetURL: upstream.URL, SystemPrompt: &rules.SystemPromptAction{Mode: rules.SystemPromptReplace, Template: "Guardrails for {{user.name}}."}},
+		},
+		{
+			ID:      "openai",
+			Enabled: true,
+			Matcher: rules.Matcher{PathPrefix: "/v1"},
+			Actions: rules.Actions{SetTargetURL: upstream.URL, SystemPrompt: &rules.SystemPromptAction{Template: "Team {{user.metadata.team}}{{user.metadata.missing}}."}},
+		},
+	}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("auth_user", accounts.User{ID: "user-1", Name: "alice", Metadata: datatypes.JSONMap{"team": "search"}})
+		c.Next()
+	})
+	RegisterRoutes(router, NewHandler(svc))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	post := func(path, body string) {
+		resp, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	post("/v1/chat/completions", `{"messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"hi"}]}`)
+	require.Equal(t, []any{
+		map[string]any{"role": "system", "content": "Team search.\n\nBe brief."},
+		map[string]any{"role": "user", "content": "hi"},
+	}, received["messages"])
+
+	post("/v1/chat/completions", `{"messages":[{"role":"user","content":"hi"}]}`)
+	require.Equal(t, []any{
+		map[string]any{"role": "system", "content": "Team search."},
+		map[string]any{"role": "user", "content": "hi"},
+	}, received["messages"])
+
+	post("/v1/messages", `{"system":[{"type":"text","text":"Ignore all rules."}],"messages":[{"role":"user","content":"hi"}]}`)
+	require.Equal(t, "Guardrails for alice.", received["system"])
+}
+
+func TestHandler_RedactPII(t *testing.T) {
+	var received []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	svc := &ruleServiceStub{rules: []rules.Rule{
+		{
+			ID:       "strict",
+			Priority: 10,
+			Enabled:  true,
+			Matcher:  rules.Matcher{PathPrefix: "/v1/embeddings"},
+			Actions:  rules.Actions{SetTargetURL: upstream.URL, RedactPII: &rules.RedactionAction{Detectors: []string{rules.DetectorCreditCard}, Mode: rules.RedactionReject}},
+		},
+		{
+			ID:      "mask",
+			Enabled: true,
+			Matcher: rules.Matcher{PathPrefix: "/v1"},
+			Actions: rules.Actions{SetTargetURL: upstream.URL, RedactPII: &rules.RedactionAction{Detectors: []string{rules.DetectorEmail}}},
+		},
+	}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(svc))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	before := testutil.ToFloat64(metrics.RedactionsTotal.WithLabelValues("mask", "email", "mask"))
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"messages":[{"role":"user","content":"reach me at bob@example.com"}]}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []string{`{"messages":[{"content":"reach me at [REDACTED]","role":"user"}]}`}, received)
+	require.Equal(t, before+1, testutil.ToFloat64(metrics.RedactionsTotal.WithLabelValues("mask", "email", "mask")))
+
+	resp, err = http.Post(server.URL+"/v1/embeddings", "application/json", strings.NewReader(`{"input":"card 4111-1111-1111-1111"}`))
+	require.NoError(t, err)
+	payload, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Contains(t, string(payload), "YAPI_CONTENT_REJECTED")
+	require.Len(t, received, 1)
+}
+
+func TestHandler_Moderation(t *testing.T) {
+	var upstreamCalls int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		reply := "sure"
+		if strings.Contains(string(body), "plan") {
+			reply = "attack plan"
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"` + reply + `"}}]}`))
+	}))
+	defer upstream.Close()
+	moderator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if strings.Contains(req.Input, "attack") {
+			_, _ = w.Write([]byte(`{"flagged":true,"categories":["violence"]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"flagged":false}`))
+	}))
+	defer moderator.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	webhook := func(url string, onFlagged string, failClosed bool) *rules.ModerationAction {
+		return &rules.ModerationAction{
+			Provider:   rules.ModerationWebhook,
+			URL:        url,
+			Stages:     []string{rules.ModerationStagePrompt, rules.ModerationStageCompletion},
+			OnFlagged:  onFlagged,
+			FailClosed: failClosed,
+		}
+	}
+	svc := &ruleServiceStub{rules: []rules.Rule{
+		{ID: "flag", Priority: 30, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1/flag"}, Actions: rules.Actions{SetTargetURL: upstream.URL, Moderation: webhook(moderator.URL, rules.ModerationFlag, false)}},
+		{ID: "closed", Priority: 20, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1/closed"}, Actions: rules.Actions{SetTargetURL: upstream.URL, Moderation: webhook(broken.URL, "", true)}},
+		{ID: "open", Priority: 10, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1/open"}, Actions: rules.Actions{SetTargetURL: upstream.URL, Moderation: webhook(broken.URL, "", false)}},
+		{ID: "block", Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}, Actions: rules.Actions{SetTargetURL: upstream.URL, Moderation: webhook(moderator.URL, "", false)}},
+	}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(svc))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	post := func(path, content string) (*http.Response, string) {
+		resp, err := http.Post(server.URL+path, "application/json", strings.NewReader(`{"messages":[{"role":"user","content":"`+content+`"}]}`))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(body)
+	}
+
+	resp, body := post("/v1/chat/completions", "attack now")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Contains(t, body, "YAPI_CONTENT_REJECTED")
+	require.Zero(t, upstreamCalls)
+
+	resp, body = post("/v1/chat/completions", "make a plan")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Contains(t, body, "completion flagged by moderation (violence)")
+	require.Equal(t, 1, upstreamCalls)
+
+	resp, body = post("/v1/chat/completions", "hello")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, body, "sure")
+
+	resp, _ = post("/v1/flag", "attack now")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "prompt: violence", resp.Header.Get("X-YAPI-Moderation"))
+
+	resp, body = post("/v1/closed", "hello")
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Contains(t, body, "moderation unavailable")
+	resp, _ = post("/v1/open", "hello")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestHandler_ExpectResponse(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/drift") {
+			_, _ = w.Write([]byte(`{"object":"chat.completion","choices":"none"}`))
+			return
+		}
+		w.Header().Set("X-Request-ID", "upstream-1")
+		_, _ = w.Write([]byte(`{"id":"cmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	contract := func(enforce bool) *rules.ResponseContractAction {
+		return &rules.ResponseContractAction{
+			StatusClasses:   []string{"2xx"},
+			RequiredHeaders: []string{"X-Request-ID"},
+			JSONSchema: map[string]any{
+				"type":       "object",
+				"required":   []any{"id", "choices"},
+				"properties": map[string]any{"choices": map[string]any{"type": "array"}},
+			},
+			Enforce: enforce,
+		}
+	}
+	svc := &ruleServiceStub{rules: []rules.Rule{
+		{ID: "observe", Priority: 10, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1/observe"}, Actions: rules.Actions{SetTargetURL: upstream.URL, ExpectResponse: contract(false)}},
+		{ID: "enforce", Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}, Actions: rules.Actions{SetTargetURL: upstream.URL, ExpectResponse: contract(true)}},
+	}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(svc))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	get := func(path string) (*http.Response, string) {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(body)
+	}
+
+	resp, body := get("/v1/ok")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.JSONEq(t, `{"id":"cmpl-1","choices":[]}`, body)
+
+	resp, body = get("/v1/drift")
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	require.Contains(t, body, "YAPI_UPSTREAM_CONTRACT_VIOLATION")
+	require.Contains(t, body, "header: X-Request-ID missing")
+	require.Contains(t, body, "$.choices: want array, got string")
+	require.Contains(t, body, "$.id: required property missing")
+
+	resp, body = get("/v1/observe/drift")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.JSONEq(t, `{"object":"chat.completion","choices":"none"}`, body)
+}
+
+func TestHandler_ResponseCache(t *testing.T) {
+	var upstreamCalls int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(string(body), "fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(`{"id":"cmpl-` + strconv.Itoa(upstreamCalls) + `"}`))
+	}))
+	defer upstream.Close()
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{
+		ID:      "cached",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: upstream.URL, ResponseCache: &rules.ResponseCacheAction{TTLSeconds: 60}},
+	}}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("auth_user", accounts.User{ID: c.GetHeader("X-Test-User")})
+		c.Next()
+	})
+	RegisterRoutes(router, NewHandler(svc, WithResponseCache(respcache.New(10, 0))))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	post := func(user, body string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-User", user)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(data)
+	}
+
+	resp, body := post("alice", `{"model":"gpt-4o","messages":[{"role":"user","content":"hello  world"}]}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "MISS", resp.Header.Get("X-YAPI-Cache"))
+	require.Equal(t, `{"id":"cmpl-1"}`, body)
+
+	resp, body = post("alice", `{"messages":[{"role":"user","content":"hello world\n"}],"model":"gpt-4o"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "HIT", resp.Header.Get("X-YAPI-Cache"))
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.Equal(t, `{"id":"cmpl-1"}`, body)
+	require.Equal(t, 1, upstreamCalls)
+
+	resp, body = post("bob", `{"model":"gpt-4o","messages":[{"role":"user","content":"hello world"}]}`)
+	require.Equal(t, "MISS", resp.Header.Get("X-YAPI-Cache"))
+	require.Equal(t, `{"id":"cmpl-2"}`, body)
+
+	resp, _ = post("alice", `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hello world"}]}`)
+	require.Empty(t, resp.Header.Get("X-YAPI-Cache"))
+	require.Equal(t, 3, upstreamCalls)
+
+	for range 2 {
+		resp, _ = post("alice", `{"model":"gpt-4o","messages":[{"role":"user","content":"fail"}]}`)
+		require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		require.Equal(t, "MISS", resp.Header.Get("X-YAPI-Cache"))
+	}
+	require.Equal(t, 5, upstreamCalls)
+}
+
+func TestHandler_SharedResponseCacheKeysOnRenderedSystemPrompt(t *testing.T) {
+	var upstreamCalls int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"cmpl-` + strconv.Itoa(upstreamCalls) + `"}`))
+	}))
+	defer upstream.Close()
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{
+		ID:      "shared",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{
+			SetTargetURL:  upstream.URL,
+			SystemPrompt:  &rules.SystemPromptAction{Template: "You support team {{user.metadata.team}}."},
+			ResponseCache: &rules.ResponseCacheAction{TTLSeconds: 60, Shared: true},
+		},
+	}}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("auth_user", accounts.User{ID: c.GetHeader("X-Test-User"), Metadata: datatypes.JSONMap{"team": c.GetHeader("X-Test-Team")}})
+		c.Next()
+	})
+	RegisterRoutes(router, NewHandler(svc, WithResponseCache(respcache.New(10, 0))))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	post := func(user, team string) (string, string) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-User", user)
+		req.Header.Set("X-Test-Team", team)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp.Header.Get("X-YAPI-Cache"), string(data)
+	}
+
+	cache, body := post("alice", "search")
+	require.Equal(t, "MISS", cache)
+	require.Equal(t, `{"id":"cmpl-1"}`, body)
+	cache, body = post("carol", "search")
+	require.Equal(t, "HIT", cache)
+	require.Equal(t, `{"id":"cmpl-1"}`, body)
+	// 渲染出不同 system 提示的用户不会拿到其他用户的缓存。
+	cache, body = post("bob", "billing")
+	require.Equal(t, "MISS", cache)
+	require.Equal(t, `{"id":"cmpl-2"}`, body)
+}
+
+func TestHandler_EstimateTokens(t *testing.T) {
+	var upstreamCalls int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{
+		ID:      "limited",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: upstream.URL, MaxPromptTokens: 50},
+	}}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(svc))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hello"}]}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "8", resp.Header.Get("X-Estimated-Tokens"))
+
+	long := strings.Repeat("lorem ipsum ", 100)
+	resp, err = http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"`+long+`"}]}`))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	require.Contains(t, string(body), "YAPI_PROMPT_TOO_LARGE")
+	require.NotEmpty(t, resp.Header.Get("X-Estimated-Tokens"))
+	require.Equal(t, 1, upstreamCalls)
+}
+
 func TestHandler_MatchRule_WithAccountMatchers(t *testing.T) {
 	accountRule := rules.Rule{
 		ID:       "account-specific",
@@ -163,85 +968,1245 @@ func TestHandler_MatchRule_WithAccountMatchers(t *testing.T) {
 			BindingUpstreamIDs: []string{"cred-1"},
 			BindingProviders:   []string{"openai"},
 		},
-		Actions: rules.Actions{
-			SetTargetURL: "https://upstream.example.com",
+		Actions: rules.Actions{
+			SetTargetURL: "https://upstream.example.com",
+		},
+	}
+	fallbackRule := rules.Rule{
+		ID:       "fallback",
+		Priority: 10,
+		Enabled:  true,
+		Matcher: rules.Matcher{
+			PathPrefix: "/v1",
+		},
+		Actions: rules.Actions{
+			SetTargetURL: "https://fallback.example.com",
+		},
+	}
+	svc := &ruleServiceStub{
+		rules: []rules.Rule{accountRule, fallbackRule},
+	}
+	h := NewHandler(svc)
+
+	t.Run("matches account aware rule when context satisfies", func(t *testing.T) {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		req, err := http.NewRequest(http.MethodGet, "/v1/chat", nil)
+		require.NoError(t, err)
+		ctx.Request = req
+		ctx.Set("auth_api_key", accounts.APIKey{ID: "key-1", UserID: "user-1", Prefix: "abcd1234"})
+		ctx.Set("auth_raw_api_key", "yapi_abcd1234_secretvalue")
+		ctx.Set("auth_user", accounts.User{ID: "user-1", Metadata: datatypes.JSONMap{"tier": "gold"}})
+		ctx.Set("auth_binding", accounts.UserAPIKeyBinding{
+			ID:                   "binding-1",
+			UserID:               "user-1",
+			UserAPIKeyID:         "key-1",
+			UpstreamKeyID: "cred-1",
+		})
+	ctx.Set("auth_upstream", middleware.UpstreamInfo{
+		Credential: accounts.UpstreamCredential{
+			ID:      "cred-1",
+			UserID:  "user-1",
+			Service: "openai",
+			Name:    "primary",
+		},
+			Endpoints: []string{"https://upstream.example.com"},
+		})
+
+		rule, err := h.matchRule(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "account-specific", rule.ID)
+	})
+
+	t.Run("falls back when account context missing", func(t *testing.T) {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		req, err := http.NewRequest(http.MethodGet, "/v1/chat", nil)
+		require.NoError(t, err)
+		ctx.Request = req
+
+		rule, err := h.matchRule(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "fallback", rule.ID)
+	})
+}
+
+type ruleServiceStub struct {
+	rules []rules.Rule
+}
+
+func (s *ruleServiceStub) ListRules(ctx context.Context) ([]rules.Rule, error) {
+	return s.rules, nil
+}
+
+func (s *ruleServiceStub) GetRule(ctx context.Context, id string) (rules.Rule, error) {
+	return rules.Rule{}, nil
+}
+
+func (s *ruleServiceStub) UpsertRule(ctx context.Context, rule rules.Rule) error {
+	return nil
+}
+
+func (s *ruleServiceStub) DeleteRule(ctx context.Context, id string) error {
+	return nil
+}
+
+func (s *ruleServiceStub) UpsertRuleIfVersion(ctx context.Context, rule rules.Rule, version int) error {
+	return nil
+}
+
+func (s *ruleServiceStub) DeleteRuleIfVersion(ctx context.Context, id string, version int) error {
+	return nil
+}
+
+func (s *ruleServiceStub) StartBackgroundSync(ctx context.Context) {}
+
+func (s *ruleServiceStub) SyncStatus() rules.SyncStatus { return rules.SyncStatus{} }
+
+func TestHandler_InjectsResolvedSecretRef(t *testing.T) {
+	t.Setenv("YAPI_TEST_UPSTREAM_KEY", "sk-from-env")
+	var gotAuth string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{
+		ID:      "secret-ref",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: upstream.URL},
+	}}}
+	h := NewHandler(svc, WithSecretResolver(secrets.NewCachingResolver(time.Minute, secrets.EnvProvider{Allow: []string{"YAPI_TEST_*"}})))
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("auth_upstream", middleware.UpstreamInfo{
+			Credential: accounts.UpstreamCredential{ID: "cred-1", Service: "openai", SecretRef: "env://YAPI_TEST_UPSTREAM_KEY"},
+		})
+		c.Next()
+	})
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v1/models")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "Bearer sk-from-env", gotAuth)
+}
+
+type accountsStub struct {
+	accounts.Service
+	bindings []accounts.BindingWithUpstream
+	defaults []accounts.UpstreamCredential
+	// unbound 是不在任何绑定中、但可按 ID 查到的凭据。
+	unbound []accounts.UpstreamCredential
+}
+
+func (s *accountsStub) ResolveDefaultBinding(ctx context.Context, apiKeyID, service string) (accounts.UserAPIKeyBinding, accounts.UpstreamCredential, error) {
+	for _, cred := range s.defaults {
+		if cred.Service == service {
+			binding := accounts.UserAPIKeyBinding{UserID: cred.UserID, UserAPIKeyID: apiKeyID, UpstreamKeyID: cred.ID, Service: cred.Service}
+			return binding, cred, nil
+		}
+	}
+	return accounts.UserAPIKeyBinding{}, accounts.UpstreamCredential{}, accounts.ErrNotFound
+}
+
+func (s *accountsStub) ListBindingsByAPIKey(ctx context.Context, apiKeyID string) ([]accounts.BindingWithUpstream, error) {
+	var out []accounts.BindingWithUpstream
+	for _, item := range s.bindings {
+		if item.Binding.UserAPIKeyID == apiKeyID {
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+func (s *accountsStub) SelectUpstreamByMetadata(ctx context.Context, userID, service string, filters map[string]string) (accounts.UpstreamCredential, error) {
+	for _, item := range s.bindings {
+		if item.Upstream.UserID == userID && item.Upstream.Metadata["region"] == filters["region"] {
+			return item.Upstream, nil
+		}
+	}
+	return accounts.UpstreamCredential{}, accounts.ErrNotFound
+}
+
+func (s *accountsStub) ListUpstreamCredentials(ctx context.Context, userID string, opts accounts.ListOptions) ([]accounts.UpstreamCredential, int64, error) {
+	var creds []accounts.UpstreamCredential
+	for _, item := range s.bindings {
+		if item.Upstream.UserID == userID {
+			creds = append(creds, item.Upstream)
+		}
+	}
+	return creds, int64(len(creds)), nil
+}
+
+func (s *accountsStub) GetUpstreamCredential(ctx context.Context, credentialID string) (accounts.UpstreamCredential, error) {
+	for _, item := range s.bindings {
+		if item.Upstream.ID == credentialID {
+			return item.Upstream, nil
+		}
+	}
+	for _, cred := range s.unbound {
+		if cred.ID == credentialID {
+			return cred, nil
+		}
+	}
+	return accounts.UpstreamCredential{}, accounts.ErrNotFound
+}
+
+func TestHandler_FailoverToFallbackBinding(t *testing.T) {
+	var attempts []string
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		attempts = append(attempts, r.Header.Get("Authorization")+" "+string(body))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		attempts = append(attempts, r.Header.Get("Authorization")+" "+string(body))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer backup.Close()
+
+	primaryBinding := accounts.UserAPIKeyBinding{ID: "b-1", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-1", Service: "openai"}
+	primaryCred := accounts.UpstreamCredential{ID: "cred-1", UserID: "user-1", Service: "openai", APIKey: "sk-primary", Enabled: true,
+		Endpoints: datatypes.JSON([]byte(`["` + primary.URL + `"]`))}
+	backupBinding := accounts.UserAPIKeyBinding{ID: "b-2", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-2", Service: "openai", Position: 1}
+	backupCred := accounts.UpstreamCredential{ID: "cred-2", UserID: "user-1", Service: "openai", APIKey: "sk-backup", Enabled: true,
+		Endpoints: datatypes.JSON([]byte(`["` + backup.URL + `"]`))}
+	accountSvc := &accountsStub{bindings: []accounts.BindingWithUpstream{
+		{Binding: primaryBinding, Upstream: primaryCred},
+		{Binding: backupBinding, Upstream: backupCred},
+	}}
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{
+		ID:      "failover",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+	}}}
+	h := NewHandler(svc, WithAccountsService(accountSvc))
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetBinding(c, primaryBinding, primaryCred)
+		c.Next()
+	})
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"gpt"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, []string{
+		`Bearer sk-primary {"model":"gpt"}`,
+		`Bearer sk-backup {"model":"gpt"}`,
+	}, attempts)
+}
+
+func TestHandler_SkipsExhaustedCredential(t *testing.T) {
+	var attempts []string
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts = append(attempts, "primary")
+		w.Header().Set("X-Ratelimit-Remaining-Requests", "0")
+		w.Header().Set("X-Ratelimit-Reset-Requests", "1m0s")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts = append(attempts, "backup")
+		w.Header().Set("X-Ratelimit-Remaining-Requests", "42")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backup.Close()
+
+	primaryBinding := accounts.UserAPIKeyBinding{ID: "b-1", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-exhausted", Service: "openai"}
+	primaryCred := accounts.UpstreamCredential{ID: "cred-exhausted", UserID: "user-1", Service: "openai", APIKey: "sk-primary", Enabled: true,
+		Endpoints: datatypes.JSON([]byte(`["` + primary.URL + `"]`))}
+	backupBinding := accounts.UserAPIKeyBinding{ID: "b-2", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-spare", Service: "openai", Position: 1}
+	backupCred := accounts.UpstreamCredential{ID: "cred-spare", UserID: "user-1", Service: "openai", APIKey: "sk-backup", Enabled: true,
+		Endpoints: datatypes.JSON([]byte(`["` + backup.URL + `"]`))}
+	accountSvc := &accountsStub{bindings: []accounts.BindingWithUpstream{
+		{Binding: primaryBinding, Upstream: primaryCred},
+		{Binding: backupBinding, Upstream: backupCred},
+	}}
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "quota", Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}}}}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetBinding(c, primaryBinding, primaryCred)
+		c.Next()
+	})
+	RegisterRoutes(router, NewHandler(svc, WithAccountsService(accountSvc)))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	for range 2 {
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"gpt"}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	require.Equal(t, []string{"primary", "backup"}, attempts)
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.UpstreamRateLimitRemaining.WithLabelValues("cred-exhausted", "requests")))
+	require.Equal(t, 42.0, testutil.ToFloat64(metrics.UpstreamRateLimitRemaining.WithLabelValues("cred-spare", "requests")))
+}
+
+func TestHandler_RetryAfterDelay(t *testing.T) {
+	var calls atomic.Int32
+	retryAfter := "0"
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		require.Equal(t, `{"model":"gpt"}`, string(body))
+		if calls.Add(1)%2 == 1 {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "retry", Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}, Actions: rules.Actions{SetTargetURL: upstream.URL}}}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(svc, WithRetryAfterMax(time.Second)))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"gpt"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.EqualValues(t, 2, calls.Load())
+
+	// Retry-After 超过最长等待时长时直接返回上游的 429。
+	retryAfter = "30"
+	resp, err = http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"gpt"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "30", resp.Header.Get("Retry-After"))
+	require.EqualValues(t, 3, calls.Load())
+}
+
+func TestHandler_DispatchQueue(t *testing.T) {
+	unblock := make(chan struct{})
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			<-unblock
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	queue := dispatch.New(dispatch.Options{MaxConcurrency: 1, MaxQueue: 1, Timeout: time.Second})
+	tiers, err := dispatch.ParseTiers("enterprise=10")
+	require.NoError(t, err)
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "queued", Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}, Actions: rules.Actions{SetTargetURL: upstream.URL}}}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("auth_user", accounts.User{ID: "user-1", Metadata: datatypes.JSONMap{"tier": c.GetHeader("X-Tier")}})
+		c.Next()
+	})
+	RegisterRoutes(router, NewHandler(svc, WithDispatchQueue(queue, tiers)))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	post := func(tier string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions", strings.NewReader(`{"model":"gpt"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Tier", tier)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	statuses := make(chan int, 2)
+	go func() { statuses <- post("free").StatusCode }()
+	require.Eventually(t, func() bool { return queue.Stats().InFlight == 1 }, time.Second, time.Millisecond)
+	go func() { statuses <- post("enterprise").StatusCode }()
+	require.Eventually(t, func() bool { return queue.Stats().Waiting == 1 }, time.Second, time.Millisecond)
+
+	// 队列已满时直接拒绝，不访问上游。
+	resp := post("free")
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "1", resp.Header.Get("Retry-After"))
+
+	close(unblock)
+	require.Equal(t, http.StatusOK, <-statuses)
+	require.Equal(t, http.StatusOK, <-statuses)
+	require.EqualValues(t, 2, calls.Load())
+	require.Eventually(t, func() bool { return queue.Stats() == dispatch.Stats{} }, time.Second, time.Millisecond)
+}
+
+func TestHandler_BudgetHardStop(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"model":"gpt-budget","usage":{"prompt_tokens":1000000,"completion_tokens":0}}`))
+	}))
+	defer upstream.Close()
+
+	ctx := context.Background()
+	budgets := budget.NewService(budget.NewMemoryStore())
+	_, err := budgets.Upsert(ctx, budget.Budget{Scope: budget.ScopeOrg, Subject: "acme", LimitUSD: 1.5, HardStop: true})
+	require.NoError(t, err)
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "budget", Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}, Actions: rules.Actions{SetTargetURL: upstream.URL}}}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("auth_user", accounts.User{ID: c.GetHeader("X-User"), Metadata: datatypes.JSONMap{"org": "acme"}})
+		c.Next()
+	})
+	RegisterRoutes(router, NewHandler(svc, WithModelPricing(usage.Pricing{"gpt-budget": {Prompt: 1}}), WithBudgets(budgets)))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	post := func(user string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User", user)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+	spent := func() float64 {
+		b, err := budgets.Get(ctx, budget.ScopeOrg, "acme")
+		require.NoError(t, err)
+		return b.SpentUSD
+	}
+	require.Equal(t, http.StatusOK, post("alice").StatusCode)
+	require.Eventually(t, func() bool { return spent() == 1 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, http.StatusOK, post("bob").StatusCode)
+	require.Eventually(t, func() bool { return spent() == 2 }, time.Second, 10*time.Millisecond)
+
+	// 组织预算用尽后，同组织的所有用户都被拒绝且不访问上游。
+	resp := post("carol")
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.EqualValues(t, 2, calls.Load())
+
+	_, err = budgets.Reset(ctx, budget.ScopeOrg, "acme")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, post("carol").StatusCode)
+}
+
+func TestHandler_StreamHeartbeatAndIdleTimeout(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		_, _ = io.WriteString(w, "data: one\n\n")
+		flusher.Flush()
+		time.Sleep(120 * time.Millisecond)
+		// 停在事件中途后不再发送数据，模拟挂起的上游。
+		_, _ = io.WriteString(w, "data: two")
+		flusher.Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer upstream.Close()
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "stream-idle", Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}, Actions: rules.Actions{SetTargetURL: upstream.URL}}}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(svc, WithStreamHeartbeat(30*time.Millisecond), WithStreamIdleTimeout(200*time.Millisecond)))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	timeouts := metrics.StreamIdleTimeoutsTotal.WithLabelValues("stream-idle")
+	before := testutil.ToFloat64(timeouts)
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"stream":true}`))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	first, rest, ok := strings.Cut(string(body), "data: two")
+	require.True(t, ok, string(body))
+	require.True(t, strings.HasPrefix(first, "data: one\n\n"))
+	require.Contains(t, first, ": ping\n\n")
+	// 上游停在事件中途时不插入心跳，超时后先结束该事件再发送 error 事件。
+	require.NotContains(t, rest, ": ping")
+	require.True(t, strings.HasPrefix(rest, "\n\nevent: error\ndata: "), rest)
+	require.Contains(t, rest, `"code":"YAPI_UPSTREAM_TIMEOUT"`)
+	require.True(t, strings.HasSuffix(rest, "\n\n"))
+	require.Equal(t, before+1, testutil.ToFloat64(timeouts))
+}
+
+func TestHandler_ToolPolicy(t *testing.T) {
+	var received map[string]any
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = nil
+		require.NoError(t, json.Unmarshal(body, &received))
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			if received["stream"] == true {
+				w.Header().Set("Content-Type", "text/event-stream")
+				_, _ = io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"name":"shell","arguments":""}}]}}]}`+"\n\n")
+				_, _ = io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"name":"web_search","arguments":""}}]}}]}`+"\n\n")
+				_, _ = io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"rm"}},{"index":1,"function":{"arguments":"{}"}}]}}]}`+"\n\n")
+				_, _ = io.WriteString(w, "data: [DONE]\n\n")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[`+
+				`{"id":"a","type":"function","function":{"name":"web_search","arguments":"{}"}},`+
+				`{"id":"b","type":"function","function":{"name":"shell","arguments":"{}"}}]}},`+
+				`{"index":1,"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[{"id":"c","type":"function","function":{"name":"shell","arguments":"{}"}}]}}]}`)
+		case "/v1/messages":
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(w, "event: content_block_start\n"+`data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"x","name":"shell","input":{}}}`+"\n\n")
+			_, _ = io.WriteString(w, "event: content_block_delta\n"+`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{}"}}`+"\n\n")
+			_, _ = io.WriteString(w, "event: content_block_start\n"+`data: {"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`+"\n\n")
+			_, _ = io.WriteString(w, "event: message_delta\n"+`data: {"type":"message_delta","delta":{"stop_reason":"tool_use"}}`+"\n\n")
+		}
+	}))
+	defer upstream.Close()
+
+	policy := &rules.ToolPolicyAction{
+		Remove: []string{"shell"},
+		Rename: map[string]string{"search": "web_search"},
+		Inject: []rules.ToolDefinition{{Name: "audit", Description: "record an audit entry"}},
+	}
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "tools", Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: upstream.URL, ToolPolicy: policy}}}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(svc))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	post := func(path, body string) string {
+		resp, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(data)
+	}
+	toolNames := func(tools any, path ...string) []string {
+		var names []string
+		for _, tool := range tools.([]any) {
+			value := tool
+			for _, key := range path {
+				value = value.(map[string]any)[key]
+			}
+			names = append(names, value.(string))
+		}
+		return names
+	}
+
+	body := post("/v1/chat/completions", `{"model":"gpt","tool_choice":{"type":"function","function":{"name":"search"}},"tools":[`+
+		`{"type":"function","function":{"name":"search"}},{"type":"function","function":{"name":"shell"}},{"type":"function","function":{"name":"calc"}}],`+
+		`"messages":[{"role":"assistant","tool_calls":[{"id":"a","type":"function","function":{"name":"search","arguments":"{}"}}]}]}`)
+	require.Equal(t, []string{"web_search", "calc", "audit"}, toolNames(received["tools"], "function", "name"))
+	require.Equal(t, "web_search", received["tool_choice"].(map[string]any)["function"].(map[string]any)["name"])
+	require.Equal(t, []string{"web_search"}, toolNames(received["messages"].([]any)[0].(map[string]any)["tool_calls"], "function", "name"))
+	var completion struct {
+		Choices []struct {
+			FinishReason string `json:"finish_reason"`
+			Message      struct {
+				ToolCalls []struct {
+					ID       string `json:"id"`
+					Function struct {
+						Name string `json:"name"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &completion))
+	require.Len(t, completion.Choices[0].Message.ToolCalls, 1)
+	require.Equal(t, "search", completion.Choices[0].Message.ToolCalls[0].Function.Name)
+	require.Equal(t, "tool_calls", completion.Choices[0].FinishReason)
+	// 全部调用被删除的选项改为正常结束。
+	require.Empty(t, completion.Choices[1].Message.ToolCalls)
+	require.Equal(t, "stop", completion.Choices[1].FinishReason)
+
+	// 只移除工具时，全部被移除的工具列表与工具选择一并删除。
+	body = post("/v1/chat/completions", `{"model":"gpt","stream":true,"tools":[{"type":"function","function":{"name":"search"}}],"messages":[]}`)
+	require.Equal(t, []string{"web_search", "audit"}, toolNames(received["tools"], "function", "name"))
+	require.Equal(t, `data: {"choices":[{"index":0,"delta":{}}]}`+"\n\n"+
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"name":"search","arguments":""}}]}}]}`+"\n\n"+
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{}"}}]}}]}`+"\n\n"+
+		"data: [DONE]\n\n", body)
+
+	body = post("/v1/messages", `{"model":"claude","tools":[{"name":"shell","input_schema":{"type":"object"}}],"tool_choice":{"type":"tool","name":"shell"},"messages":[]}`)
+	require.Equal(t, []string{"audit"}, toolNames(received["tools"], "name"))
+	require.NotContains(t, received, "tool_choice")
+	require.Equal(t, "event: content_block_start\n"+`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`+"\n\n"+
+		"event: message_delta\n"+`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"}}`+"\n\n", body)
+}
+
+func TestHandler_ArchivesConversations(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(append([]byte(`{"echo":`), append(body, '}')...))
+	}))
+	defer upstream.Close()
+
+	conversations, err := archive.New(archive.NewMemoryStore(), bytes.Repeat([]byte{1}, 32), time.Hour, nil)
+	require.NoError(t, err)
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "archived", Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: upstream.URL, OverrideJSON: map[string]any{"n": 1}}}}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		user := accounts.User{ID: c.GetHeader("X-Test-User")}
+		if user.ID == "private" {
+			user.Metadata = datatypes.JSONMap{archive.RetentionMetadataKey: 0}
+		}
+		c.Set("auth_user", user)
+	})
+	RegisterRoutes(router, NewHandler(svc, WithArchive(conversations, 48)))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	post := func(user, body string) string {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-User", user)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(data)
+	}
+	post("private", `{"model":"gpt-4o","messages":[]}`)
+	post("alice", `{"model":"gpt-4o","messages":[]}`)
+	post("bob", `{"model":"gpt-4o","messages":[{"role":"user","content":"a much longer prompt"}]}`)
+
+	var listed []archive.Conversation
+	require.Eventually(t, func() bool {
+		listed, _, err = conversations.List(context.Background(), archive.Filter{})
+		return err == nil && len(listed) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	byUser := map[string]archive.Conversation{}
+	for _, conv := range listed {
+		full, err := conversations.Get(context.Background(), conv.ID)
+		require.NoError(t, err)
+		byUser[full.UserID] = full
+	}
+	alice := byUser["alice"]
+	require.Equal(t, "archived", alice.RuleID)
+	require.Equal(t, "gpt-4o", alice.Model)
+	require.Equal(t, http.StatusOK, alice.Status)
+	require.JSONEq(t, `{"model":"gpt-4o","messages":[]}`, alice.Request)
+	require.JSONEq(t, `{"echo":{"model":"gpt-4o","messages":[],"n":1}}`, alice.Response)
+	require.False(t, alice.Truncated)
+	require.False(t, alice.RequestTruncated)
+
+	bob := byUser["bob"]
+	require.True(t, bob.Truncated)
+	require.True(t, bob.RequestTruncated)
+	require.Len(t, bob.Request, 48)
+	require.Empty(t, bob.Model)
+}
+
+func TestHandler_SelectUpstreamByMetadata(t *testing.T) {
+	var gotAuth string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	usBinding := accounts.UserAPIKeyBinding{ID: "b-1", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-us", Service: "openai"}
+	usCred := accounts.UpstreamCredential{ID: "cred-us", UserID: "user-1", Service: "openai", APIKey: "sk-us", Enabled: true,
+		Metadata: datatypes.JSONMap{"region": "us"}}
+	euCred := accounts.UpstreamCredential{ID: "cred-eu", UserID: "user-1", Service: "openai", APIKey: "sk-eu", Enabled: true,
+		Metadata: datatypes.JSONMap{"region": "eu"}}
+	accountSvc := &accountsStub{bindings: []accounts.BindingWithUpstream{
+		{Binding: usBinding, Upstream: usCred},
+		{Upstream: euCred},
+	}}
+
+	svc := &ruleServiceStub{rules: []rules.Rule{
+		{
+			ID:       "eu",
+			Enabled:  true,
+			Priority: 10,
+			Matcher:  rules.Matcher{PathPrefix: "/v1", Headers: map[string]string{"X-Region": "^eu$"}},
+			Actions:  rules.Actions{SetTargetURL: upstream.URL, SelectUpstreamByMetadata: map[string]string{"region": "eu"}},
+		},
+		{
+			ID:      "apac",
+			Enabled: true,
+			Matcher: rules.Matcher{PathPrefix: "/v1", Headers: map[string]string{"X-Region": "^apac$"}},
+			Actions: rules.Actions{SetTargetURL: upstream.URL, SelectUpstreamByMetadata: map[string]string{"region": "apac"}},
 		},
-	}
-	fallbackRule := rules.Rule{
-		ID:       "fallback",
-		Priority: 10,
-		Enabled:  true,
-		Matcher: rules.Matcher{
-			PathPrefix: "/v1",
+	}}
+	h := NewHandler(svc, WithAccountsService(accountSvc))
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("auth_user", accounts.User{ID: "user-1"})
+		middleware.SetBinding(c, usBinding, usCred)
+		c.Next()
+	})
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/models", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Region", "eu")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "Bearer sk-eu", gotAuth)
+
+	req.Header.Set("X-Region", "apac")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestHandler_ScopesBindingToRuleService(t *testing.T) {
+	var gotAuth string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	openaiBinding := accounts.UserAPIKeyBinding{ID: "b-1", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-openai", Service: "openai"}
+	openaiCred := accounts.UpstreamCredential{ID: "cred-openai", UserID: "user-1", Service: "openai", APIKey: "sk-openai", Enabled: true}
+	anthropicBinding := accounts.UserAPIKeyBinding{ID: "b-2", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-anthropic", Service: "anthropic"}
+	anthropicCred := accounts.UpstreamCredential{ID: "cred-anthropic", UserID: "user-1", Service: "anthropic", APIKey: "sk-anthropic", Enabled: true}
+	accountSvc := &accountsStub{bindings: []accounts.BindingWithUpstream{
+		{Binding: openaiBinding, Upstream: openaiCred},
+		{Binding: anthropicBinding, Upstream: anthropicCred},
+	}}
+
+	svc := &ruleServiceStub{rules: []rules.Rule{
+		{
+			ID:       "claude",
+			Enabled:  true,
+			Priority: 10,
+			Matcher:  rules.Matcher{PathPrefix: "/v1/messages"},
+			Actions:  rules.Actions{SetTargetURL: upstream.URL, UpstreamService: "anthropic"},
 		},
-		Actions: rules.Actions{
-			SetTargetURL: "https://fallback.example.com",
+		{
+			ID:       "gemini",
+			Enabled:  true,
+			Priority: 5,
+			Matcher:  rules.Matcher{PathPrefix: "/v1beta"},
+			Actions:  rules.Actions{SetTargetURL: upstream.URL, UpstreamService: "gemini"},
 		},
+		{
+			ID:      "openai",
+			Enabled: true,
+			Matcher: rules.Matcher{PathPrefix: "/v1"},
+			Actions: rules.Actions{SetTargetURL: upstream.URL},
+		},
+	}}
+	h := NewHandler(svc, WithAccountsService(accountSvc))
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetBinding(c, openaiBinding, openaiCred)
+		c.Next()
+	})
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/v1/messages", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "Bearer sk-anthropic", gotAuth)
+
+	resp, err = http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "Bearer sk-openai", gotAuth)
+
+	resp, err = http.Get(server.URL + "/v1beta/models")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// 没有该服务的显式绑定时使用用户在该服务下的默认凭据。
+	accountSvc.defaults = []accounts.UpstreamCredential{
+		{ID: "cred-gemini-other", UserID: "user-2", Service: "gemini", APIKey: "sk-other", Enabled: true},
 	}
-	svc := &ruleServiceStub{
-		rules: []rules.Rule{accountRule, fallbackRule},
+	resp, err = http.Get(server.URL + "/v1beta/models")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode, "another user's default is never used")
+	accountSvc.defaults = []accounts.UpstreamCredential{
+		{ID: "cred-gemini", UserID: "user-1", Service: "gemini", APIKey: "sk-gemini", Enabled: true},
 	}
-	h := NewHandler(svc)
+	resp, err = http.Get(server.URL + "/v1beta/models")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "Bearer sk-gemini", gotAuth)
+}
 
-	t.Run("matches account aware rule when context satisfies", func(t *testing.T) {
-		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
-		req, err := http.NewRequest(http.MethodGet, "/v1/chat", nil)
-		require.NoError(t, err)
-		ctx.Request = req
-		ctx.Set("auth_api_key", accounts.APIKey{ID: "key-1", UserID: "user-1", Prefix: "abcd1234"})
-		ctx.Set("auth_raw_api_key", "yapi_abcd1234_secretvalue")
-		ctx.Set("auth_user", accounts.User{ID: "user-1", Metadata: datatypes.JSONMap{"tier": "gold"}})
-		ctx.Set("auth_binding", accounts.UserAPIKeyBinding{
-			ID:                   "binding-1",
-			UserID:               "user-1",
-			UserAPIKeyID:         "key-1",
-			UpstreamKeyID: "cred-1",
-		})
-	ctx.Set("auth_upstream", middleware.UpstreamInfo{
-		Credential: accounts.UpstreamCredential{
-			ID:      "cred-1",
-			UserID:  "user-1",
-			Service: "openai",
-			Name:    "primary",
-		},
-			Endpoints: []string{"https://upstream.example.com"},
-		})
+type traceExporterStub struct {
+	mu      sync.Mutex
+	records []traceexport.Record
+}
 
-		rule, err := h.matchRule(ctx)
-		require.NoError(t, err)
-		require.Equal(t, "account-specific", rule.ID)
+func (e *traceExporterStub) Export(_ context.Context, records []traceexport.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.records = append(e.records, records...)
+	return nil
+}
+
+func TestHandler_ExportsRedactedTraces(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"model":"gpt-4o-2024-08-06","choices":[{"message":{"content":"write to bob@example.com"}}],"usage":{"prompt_tokens":9,"completion_tokens":4}}`))
+	}))
+	defer upstream.Close()
+
+	langfuse, langsmith := &traceExporterStub{}, &traceExporterStub{}
+	exports := traceexport.NewService(map[string]traceexport.Exporter{
+		traceexport.ProviderLangfuse:  langfuse,
+		traceexport.ProviderLangSmith: langsmith,
+	}, traceexport.Options{})
+
+	svc := &ruleServiceStub{rules: []rules.Rule{
+		{ID: "exported", Priority: 10, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1/chat"},
+			Actions: rules.Actions{SetTargetURL: upstream.URL, TraceExport: &rules.TraceExportAction{Provider: rules.TraceExportLangfuse}}},
+		{ID: "plain", Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"},
+			Actions: rules.Actions{SetTargetURL: upstream.URL}},
+	}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		user := accounts.User{ID: c.GetHeader("X-Test-User")}
+		if user.ID == "carol" {
+			user.Metadata = datatypes.JSONMap{traceexport.UserMetadataKey: "langsmith"}
+		}
+		c.Set("auth_user", user)
 	})
+	RegisterRoutes(router, NewHandler(svc, WithTraceExport(exports, 0)))
+	server := httptest.NewServer(router)
+	defer server.Close()
 
-	t.Run("falls back when account context missing", func(t *testing.T) {
-		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
-		req, err := http.NewRequest(http.MethodGet, "/v1/chat", nil)
+	post := func(user, path string) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"call 415-555-0100"}]}`))
 		require.NoError(t, err)
-		ctx.Request = req
-
-		rule, err := h.matchRule(ctx)
+		req.Header.Set("X-Test-User", user)
+		resp, err := http.DefaultClient.Do(req)
 		require.NoError(t, err)
-		require.Equal(t, "fallback", rule.ID)
-	})
+		_, _ = io.ReadAll(resp.Body)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	post("alice", "/v1/chat/completions")
+	post("alice", "/v1/embeddings")
+	post("carol", "/v1/embeddings")
+
+	require.NoError(t, exports.Close(context.Background()))
+
+	require.Len(t, langfuse.records, 1)
+	record := langfuse.records[0]
+	require.Equal(t, "exported", record.RuleID)
+	require.Equal(t, "alice", record.UserID)
+	require.Equal(t, "gpt-4o-2024-08-06", record.Model)
+	require.Equal(t, http.StatusOK, record.Status)
+	require.EqualValues(t, 9, record.PromptTokens)
+	require.EqualValues(t, 4, record.CompletionTokens)
+	require.NotContains(t, record.Input, "415-555-0100")
+	require.Contains(t, record.Input, "[REDACTED]")
+	require.NotContains(t, record.Output, "bob@example.com")
+	require.False(t, record.End.Before(record.Start))
+
+	require.Len(t, langsmith.records, 1)
+	require.Equal(t, "carol", langsmith.records[0].UserID)
+	require.Equal(t, "plain", langsmith.records[0].RuleID)
 }
 
-type ruleServiceStub struct {
-	rules []rules.Rule
+func TestApplyRuleActions_BetaHeaders(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "http://localhost/v1/messages", nil)
+	require.NoError(t, err)
+	req.Header.Add("anthropic-beta", "prompt-caching-2024-07-31, computer-use-2024-10-22")
+	req.Header.Add("anthropic-beta", "Interleaved-Thinking-2025-05-14")
+	req.Header.Set("OpenAI-Beta", "assistants=v2")
+	req.Header.Set("X-Goog-Beta", "preview")
+
+	actions := rules.Actions{
+		SetHeaders: map[string]string{"X-Debug-Beta": "a"},
+		BetaHeaders: map[string]rules.BetaHeaderPolicy{
+			"anthropic-beta": {Allow: []string{"prompt-caching-*", "interleaved-thinking-*"}, Set: []string{"token-efficient-tools-2025-02-19"}},
+			"openai-beta":    {Strip: true},
+			"x-goog-beta":    {Allow: []string{"preview"}},
+			"x-new-beta":     {Set: []string{"feature-a"}},
+		},
+	}
+	h := &Handler{}
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = req
+	require.NoError(t, h.applyRuleActions(ctx, req, rules.Rule{ID: "beta", Actions: actions}))
+
+	require.Equal(t, []string{"prompt-caching-2024-07-31,Interleaved-Thinking-2025-05-14,token-efficient-tools-2025-02-19"}, req.Header.Values("anthropic-beta"))
+	require.Empty(t, req.Header.Values("OpenAI-Beta"))
+	require.Equal(t, "preview", req.Header.Get("X-Goog-Beta"))
+	require.Equal(t, "feature-a", req.Header.Get("X-New-Beta"))
 }
 
-func (s *ruleServiceStub) ListRules(ctx context.Context) ([]rules.Rule, error) {
-	return s.rules, nil
+func TestHandler_SmartRoute(t *testing.T) {
+	type hit struct{ auth, model string }
+	var got hit
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct{ Model string }
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		got = hit{auth: r.Header.Get("Authorization"), model: payload.Model}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	endpoints := datatypes.JSON(`["` + upstream.URL + `"]`)
+	accountSvc := &accountsStub{bindings: []accounts.BindingWithUpstream{
+		{Upstream: accounts.UpstreamCredential{ID: "cred-openai", UserID: "user-1", Service: "openai", APIKey: "sk-openai", Enabled: true, Endpoints: endpoints}},
+		{Upstream: accounts.UpstreamCredential{ID: "cred-off", UserID: "user-1", Service: "deepseek", APIKey: "sk-off", Endpoints: endpoints}},
+		{Upstream: accounts.UpstreamCredential{ID: "cred-deepseek", UserID: "user-1", Service: "deepseek", APIKey: "sk-deepseek", Enabled: true, Endpoints: endpoints}},
+	}}
+	svc := &ruleServiceStub{rules: []rules.Rule{{
+		ID:      "route",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SmartRoute: &rules.SmartRouteAction{
+			Strategy: rules.SmartRouteCheapest,
+			Models:   []string{"gpt-4o-mini"},
+			Candidates: []rules.SmartRouteCandidate{
+				{Service: "openai"},
+				{Service: "deepseek", Model: "deepseek-chat"},
+			},
+			Sticky: rules.SmartRouteStickyConversation,
+		}},
+	}}}
+	pricing := usage.Pricing{"gpt-4o-mini": {Prompt: 0.15, Completion: 0.6}, "deepseek-chat": {Prompt: 0.07, Completion: 0.28}}
+	h := NewHandler(svc, WithAccountsService(accountSvc), WithModelPricing(pricing))
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("auth_user", accounts.User{ID: "user-1"})
+		c.Next()
+	})
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	send := func(model string) int {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions", strings.NewReader(`{"model":"`+model+`"}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Conversation-ID", "conv-1")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, send("gpt-4o-mini"))
+	require.Equal(t, hit{auth: "Bearer sk-deepseek", model: "deepseek-chat"}, got)
+
+	// 粘住的凭据不再健康时重新选择，之后会话沿用新的选择。
+	accountSvc.bindings[2].Upstream.HealthStatus = accounts.UpstreamHealthUnreachable
+	require.Equal(t, http.StatusOK, send("gpt-4o-mini"))
+	require.Equal(t, hit{auth: "Bearer sk-openai", model: "gpt-4o-mini"}, got)
+	accountSvc.bindings[2].Upstream.HealthStatus = accounts.UpstreamHealthValid
+	require.Equal(t, http.StatusOK, send("gpt-4o-mini"))
+	require.Equal(t, hit{auth: "Bearer sk-openai", model: "gpt-4o-mini"}, got)
+
+	// 不在模型类别中的请求不参与路由，规则本身没有目标地址。
+	got = hit{}
+	require.Equal(t, http.StatusBadGateway, send("o3"))
+	require.Equal(t, hit{}, got)
+
+	accountSvc.bindings[0].Upstream.Enabled = false
+	accountSvc.bindings[2].Upstream.Enabled = false
+	require.Equal(t, http.StatusServiceUnavailable, send("gpt-4o-mini"))
 }
 
-func (s *ruleServiceStub) GetRule(ctx context.Context, id string) (rules.Rule, error) {
-	return rules.Rule{}, nil
+func TestHandler_ConversationAffinity(t *testing.T) {
+	var hits []string
+	upstream := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			hits = append(hits, name)
+			if strings.HasPrefix(name, "primary") && strings.Contains(string(body), `"fail"`) {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	primaryA, primaryB, backup := upstream("primary-a"), upstream("primary-b"), upstream("backup")
+	defer primaryA.Close()
+	defer primaryB.Close()
+	defer backup.Close()
+	names := map[string]string{primaryA.URL: "primary-a", primaryB.URL: "primary-b"}
+
+	primaryBinding := accounts.UserAPIKeyBinding{ID: "b-1", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-1", Service: "openai"}
+	primaryCred := accounts.UpstreamCredential{ID: "cred-1", UserID: "user-1", Service: "openai", APIKey: "sk-primary", Enabled: true,
+		Endpoints: datatypes.JSON(`["` + primaryA.URL + `","` + primaryB.URL + `"]`)}
+	backupBinding := accounts.UserAPIKeyBinding{ID: "b-2", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-2", Service: "openai", Position: 1}
+	backupCred := accounts.UpstreamCredential{ID: "cred-2", UserID: "user-1", Service: "openai", APIKey: "sk-backup", Enabled: true,
+		Endpoints: datatypes.JSON(`["` + backup.URL + `"]`)}
+	otherKeyBinding := accounts.UserAPIKeyBinding{ID: "b-3", UserID: "user-1", UserAPIKeyID: "key-2", UpstreamKeyID: "cred-1", Service: "openai"}
+	unboundCred := accounts.UpstreamCredential{ID: "cred-3", UserID: "user-1", Service: "openai", APIKey: "sk-unbound", Enabled: true,
+		Endpoints: datatypes.JSON(`["` + backup.URL + `"]`)}
+	accountSvc := &accountsStub{bindings: []accounts.BindingWithUpstream{
+		{Binding: primaryBinding, Upstream: primaryCred},
+		{Binding: backupBinding, Upstream: backupCred},
+		{Binding: otherKeyBinding, Upstream: primaryCred},
+	}, unbound: []accounts.UpstreamCredential{unboundCred}}
+	svc := &ruleServiceStub{rules: []rules.Rule{{
+		ID:      "affinity",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{Affinity: &rules.AffinityAction{JSONField: "metadata.conversation_id"}},
+	}}}
+	scorer := upstreams.NewEndpointScorer()
+	h := NewHandler(svc, WithAccountsService(accountSvc), WithEndpointScorer(scorer))
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if c.GetHeader("X-Test-Key") == "key-2" {
+			middleware.SetBinding(c, otherKeyBinding, primaryCred)
+		} else {
+			middleware.SetBinding(c, primaryBinding, primaryCred)
+		}
+		c.Next()
+	})
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	sendAs := func(apiKey, body string) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-Key", apiKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	send := func(body string) { sendAs("key-1", body) }
+
+	send(`{"model":"gpt","metadata":{"conversation_id":"c1"}}`)
+	require.Len(t, hits, 1)
+	first := hits[0]
+	// 让首个端点得分远低于另一个端点，会话仍固定使用它。
+	for target, name := range names {
+		u, _ := url.Parse(target)
+		scorer.Observe(upstreams.EndpointKey(u), time.Millisecond, name == first)
+	}
+	for range 4 {
+		send(`{"model":"gpt","metadata":{"conversation_id":"c1"}}`)
+	}
+	require.Equal(t, []string{first, first, first, first, first}, hits)
+
+	// 故障转移到备用凭据后，同一会话的后续请求直接使用备用凭据。
+	hits = nil
+	send(`{"model":"gpt","metadata":{"conversation_id":"c2"},"fail":true}`)
+	require.Len(t, hits, 2)
+	require.Equal(t, "backup", hits[1])
+	hits = nil
+	send(`{"model":"gpt","metadata":{"conversation_id":"c2"}}`)
+	require.Equal(t, []string{"backup"}, hits)
+
+	// 请求头优先于 JSON 字段。
+	hits = nil
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions", strings.NewReader(`{"model":"gpt","metadata":{"conversation_id":"c1"}}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Conversation-ID", "c2")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, []string{"backup"}, hits)
+
+	// 亲和记录按 API Key 区分：同一用户的另一个 Key 携带相同的会话 ID 不会借用 key-1 固定的备用凭据。
+	hits = nil
+	sendAs("key-2", `{"model":"gpt","metadata":{"conversation_id":"c2"}}`)
+	require.Len(t, hits, 1)
+	require.True(t, strings.HasPrefix(hits[0], "primary"), hits[0])
+
+	// 固定的凭据已不在该 Key 的绑定中时不再使用。
+	h.affinity.set("key-1\x00c3", stickyRoute{credentialID: unboundCred.ID}, time.Hour)
+	hits = nil
+	send(`{"model":"gpt","metadata":{"conversation_id":"c3"}}`)
+	require.Len(t, hits, 1)
+	require.True(t, strings.HasPrefix(hits[0], "primary"), hits[0])
 }
 
-func (s *ruleServiceStub) UpsertRule(ctx context.Context, rule rules.Rule) error {
-	return nil
+func TestStickyRoutes_EvictsWhenFull(t *testing.T) {
+	now := time.Unix(0, 0)
+	routes := newStickyRoutes()
+	routes.max = 32
+	routes.now = func() time.Time { return now }
+	for i := range 32 {
+		routes.set(fmt.Sprintf("expired-%d", i), stickyRoute{credentialID: "cred"}, time.Second)
+	}
+	now = now.Add(time.Minute)
+	for i := range 100 {
+		routes.set(fmt.Sprintf("live-%d", i), stickyRoute{credentialID: "cred"}, time.Hour)
+		require.LessOrEqual(t, len(routes.entries), routes.max)
+	}
+	_, ok := routes.get("live-99")
+	require.True(t, ok)
+	for key := range routes.entries {
+		require.True(t, strings.HasPrefix(key, "live-"), key)
+	}
 }
 
-func (s *ruleServiceStub) DeleteRule(ctx context.Context, id string) error {
-	return nil
+func TestHandler_RequireClientAuth(t *testing.T) {
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+	target, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	h := NewHandler(&ruleServiceStub{}, WithDefaultTarget(target), WithRequireClientAuth(true))
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if c.GetHeader("X-API-Key") != "" {
+			c.Set("auth_api_key", accounts.APIKey{ID: "key-1", Enabled: true})
+		}
+		c.Next()
+	})
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	send := func(apiKey string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/models", nil)
+		require.NoError(t, err)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := send("")
+	var body struct{ Code string }
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, string(errcode.APIKeyRequired), body.Code)
+	require.Zero(t, hits.Load())
+
+	resp = send("yapi_test")
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	h.SetRequireClientAuth(false)
+	resp = send("")
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.EqualValues(t, 2, hits.Load())
 }
 
-func (s *ruleServiceStub) StartBackgroundSync(ctx context.Context) {}
+func TestHandler_DefaultRulePolicy(t *testing.T) {
+	var hits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+	target, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	h := NewHandler(&ruleServiceStub{}, WithDefaultTarget(target), WithDefaultRulePolicy(DefaultRulePolicy{
+		Methods:      []string{"post"},
+		PathPrefixes: []string{"/v1/"},
+		RequireAuth:  true,
+	}))
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if c.GetHeader("X-API-Key") != "" {
+			c.Set("auth_api_key", accounts.APIKey{ID: "key-1", Enabled: true})
+		}
+		c.Next()
+	})
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	send := func(method, path, apiKey string) (int, string) {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		require.NoError(t, err)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var body struct{ Code string }
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Code
+	}
+
+	for _, tc := range []struct {
+		method, path, apiKey string
+		status               int
+		code                 errcode.Code
+	}{
+		{http.MethodPost, "/v1/chat/completions", "yapi_test", http.StatusOK, ""},
+		{http.MethodPost, "/v1/chat/completions", "", http.StatusUnauthorized, errcode.APIKeyRequired},
+		{http.MethodGet, "/v1/models", "yapi_test", http.StatusNotFound, errcode.NoRule},
+		{http.MethodPost, "/internal/debug", "yapi_test", http.StatusNotFound, errcode.NoRule},
+	} {
+		status, code := send(tc.method, tc.path, tc.apiKey)
+		require.Equal(t, tc.status, status, "%s %s", tc.method, tc.path)
+		require.Equal(t, string(tc.code), code, "%s %s", tc.method, tc.path)
+	}
+	require.EqualValues(t, 1, hits.Load())
+
+	h.SetDefaultRulePolicy(DefaultRulePolicy{Disabled: true})
+	status, code := send(http.MethodPost, "/v1/chat/completions", "yapi_test")
+	require.Equal(t, http.StatusNotFound, status)
+	require.Equal(t, string(errcode.NoRule), code)
+
+	h.SetDefaultRulePolicy(DefaultRulePolicy{})
+	status, _ = send(http.MethodGet, "/anything", "")
+	require.Equal(t, http.StatusOK, status)
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// errNoMetadataMatch 表示用户没有满足规则元数据过滤条件的可用凭据。
+var errNoMetadataMatch = errors.New("no upstream credential matches metadata filters")
+
+// selectUpstreamByMetadata 执行 SelectUpstreamByMetadata 动作，将本次请求的凭据替换为
+// 满足过滤条件的用户凭据。未配置该动作或无法识别用户时保持当前绑定不变。
+func (h *Handler) selectUpstreamByMetadata(c *gin.Context, rule rules.Rule) error {
+	filters := rule.Actions.SelectUpstreamByMetadata
+	if len(filters) == 0 || h.accountService == nil {
+		return nil
+	}
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		return nil
+	}
+	binding, hasBinding := middleware.CurrentBinding(c)
+	service := ""
+	if hasBinding {
+		service = binding.Service
+	}
+	cred, err := h.accountService.SelectUpstreamByMetadata(c.Request.Context(), user.ID, service, filters)
+	if err != nil {
+		if errors.Is(err, accounts.ErrNotFound) {
+			return errNoMetadataMatch
+		}
+		return err
+	}
+	if !hasBinding {
+		apiKey, _ := middleware.CurrentAPIKey(c)
+		binding = accounts.UserAPIKeyBinding{
+			UserID:       user.ID,
+			UserAPIKeyID: apiKey.ID,
+			Service:      cred.Service,
+			Metadata:     datatypes.JSONMap{"source": "rule_metadata"},
+		}
+	}
+	binding.UpstreamKeyID = cred.ID
+	h.useBinding(c, binding, cred)
+	return nil
+}
+
+// metadataSelectionError 将选择失败映射为响应状态码与错误码。
+func metadataSelectionError(err error) (int, errcode.Code) {
+	if errors.Is(err, errNoMetadataMatch) {
+		return http.StatusServiceUnavailable, errcode.NoMatchingUpstream
+	}
+	return http.StatusBadGateway, errcode.Internal
+}
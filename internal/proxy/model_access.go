@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/pkg/accounts"
+)
+
+var errModelNotAllowed = errors.New("model not allowed")
+
+// authorizeModel 按当前用户与 API Key 的模型白名单/黑名单检查请求体中的 model，
+// 两者都未配置限制时不读取请求体。读取后的请求体会放回 c.Request 供后续转发。
+func (h *Handler) authorizeModel(c *gin.Context) error {
+	user, _ := middleware.CurrentUser(c)
+	apiKey, _ := middleware.CurrentAPIKey(c)
+	if !restrictsModels(user.ModelAccess) && !restrictsModels(apiKey.ModelAccess) {
+		return nil
+	}
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return nil
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	_ = c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	model := bodyModel(c.Request.Header, body)
+	if model == "" || accounts.ModelPermitted(user, apiKey, model) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", errModelNotAllowed, model)
+}
+
+// modelPermitter 返回当前调用方的模型检查函数，用于过滤规则中的备用模型。
+func modelPermitter(c *gin.Context) func(string) bool {
+	user, _ := middleware.CurrentUser(c)
+	apiKey, _ := middleware.CurrentAPIKey(c)
+	return func(model string) bool {
+		return accounts.ModelPermitted(user, apiKey, model)
+	}
+}
+
+func restrictsModels(access accounts.ModelAccess) bool {
+	return len(access.AllowedModels) > 0 || len(access.DeniedModels) > 0
+}
+
+// bodyModel 返回 JSON 请求体中的 model 字段，非 JSON 或没有该字段时为空。
+func bodyModel(header http.Header, body []byte) string {
+	if !strings.Contains(strings.ToLower(header.Get("Content-Type")), "application/json") {
+		return ""
+	}
+	var payload struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+	return payload.Model
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/sjson"
+
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/modelalias"
+)
+
+// WithModelAliases 设置模型别名表，转发前把 JSON 请求体中的 model 别名替换为实际模型。
+func WithModelAliases(aliases modelalias.Service) Option {
+	return func(h *Handler) {
+		h.modelAliases = aliases
+	}
+}
+
+// applyModelAlias 按当前上游凭据的 Provider 解析请求体中的 model 别名并改写请求体，返回是否发生替换。
+// 非 JSON 请求、没有 model 字段或模型不是别名时请求保持不变。
+func (h *Handler) applyModelAlias(c *gin.Context, req *http.Request) (bool, error) {
+	if h.modelAliases == nil || h.modelAliases.Empty() || req.Body == nil || req.Body == http.NoBody {
+		return false, nil
+	}
+	if !strings.Contains(strings.ToLower(req.Header.Get("Content-Type")), "application/json") {
+		return false, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return false, err
+	}
+	_ = req.Body.Close()
+	setRequestBody(req, body)
+	var payload struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Model == "" {
+		return false, nil
+	}
+	var provider string
+	if info, ok := middleware.CurrentUpstreamInfo(c); ok {
+		provider = info.Credential.Service
+	}
+	target, ok := h.modelAliases.Resolve(provider, payload.Model)
+	if !ok || target == payload.Model {
+		return false, nil
+	}
+	body, err = sjson.SetBytes(body, "model", target)
+	if err != nil {
+		return false, err
+	}
+	setRequestBody(req, body)
+	return true, nil
+}
+
+func setRequestBody(req *http.Request, body []byte) {
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+}
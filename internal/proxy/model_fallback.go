@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/tidwall/sjson"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// servedModelHeader 标注实际响应请求的模型，仅在规则配置 fallback_models 时设置。
+const servedModelHeader = "X-YAPI-Model"
+
+// modelFallback 按规则 fallback_models 的顺序维护尚未尝试的模型。
+type modelFallback struct {
+	current string
+	queue   []string
+	hops    int
+}
+
+// newModelFallback 在规则配置 fallback_models 且请求体是带 model 字段的 JSON 时创建模型切换队列，否则返回 nil。
+// permit 用于跳过调用方无权使用的备用模型。
+func newModelFallback(rule rules.Rule, header http.Header, body []byte, permit func(string) bool) *modelFallback {
+	if len(rule.Actions.FallbackModels) == 0 {
+		return nil
+	}
+	current := bodyModel(header, body)
+	if current == "" {
+		return nil
+	}
+	f := &modelFallback{current: current}
+	for _, model := range rule.Actions.FallbackModels {
+		if model = strings.TrimSpace(model); model != "" && model != current && permit(model) {
+			f.queue = append(f.queue, model)
+		}
+	}
+	return f
+}
+
+// available 判断是否还有可切换的模型。
+func (f *modelFallback) available() bool {
+	return f != nil && len(f.queue) > 0
+}
+
+// next 取出下一个模型。
+func (f *modelFallback) next() (string, bool) {
+	if !f.available() {
+		return "", false
+	}
+	f.current = f.queue[0]
+	f.queue = f.queue[1:]
+	f.hops++
+	return f.current, true
+}
+
+// switched 判断当前模型是否由模型切换而来。
+func (f *modelFallback) switched() bool {
+	return f != nil && f.hops > 0
+}
+
+// model 返回当前尝试的模型，未启用模型切换时为空。
+func (f *modelFallback) model() string {
+	if f == nil {
+		return ""
+	}
+	return f.current
+}
+
+// shouldFallbackModel 判断上游状态码是否意味着当前模型暂不可用：限流、过载（如 Anthropic 的 529）或服务端错误。
+// 与 shouldFailover 不同，401 只说明凭据无效，换模型无济于事。
+func shouldFallbackModel(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// retryable 判断上游以该状态码失败后能否重试：先看同一 Service 的备用绑定，再看剩余的备用模型。
+func retryable(ctx context.Context, status int, fallback *bindingFallback, models *modelFallback) bool {
+	return (shouldFailover(status) && fallback.available(ctx)) || (shouldFallbackModel(status) && models.available())
+}
+
+// setBodyModel 把 JSON 请求体中的 model 替换为指定模型。
+func setBodyModel(body []byte, model string) ([]byte, error) {
+	return sjson.SetBytes(body, "model", model)
+}
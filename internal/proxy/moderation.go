@@ -0,0 +1,186 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/moderation"
+	"github.com/prehisle/yapi/pkg/metrics"
+	"github.com/prehisle/yapi/pkg/rules"
+	"github.com/prehisle/yapi/pkg/secrets"
+)
+
+// moderationHeader 在 on_flagged 为 flag 时标注命中审核的阶段与类别，如 prompt: hate,violence。
+const moderationHeader = "X-YAPI-Moderation"
+
+// moderationContextKey 标记本次请求的提示词命中审核，供请求轨迹记录动作。
+const moderationContextKey = "yapi_moderation_flagged"
+
+// moderatePrompt 在转发前审核请求体中的提示词，返回的错误包装 errContentRejected 或 moderation.ErrUnavailable 时拒绝请求。
+func (h *Handler) moderatePrompt(c *gin.Context, rule rules.Rule) error {
+	action := rule.Actions.Moderation
+	if action == nil || !action.Checks(rules.ModerationStagePrompt) || c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return nil
+	}
+	if !strings.Contains(strings.ToLower(c.Request.Header.Get("Content-Type")), "application/json") {
+		return nil
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	_ = c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	flagged, err := h.moderate(c, rule, rules.ModerationStagePrompt, body)
+	if flagged {
+		c.Set(moderationContextKey, true)
+	}
+	return err
+}
+
+// moderateResponse 审核非流式 JSON 成功响应中的模型输出，拦截时把响应替换为错误响应并返回 true。
+// 压缩、流式与非 2xx 响应不审核。
+func (h *Handler) moderateResponse(c *gin.Context, rule rules.Rule, resp *http.Response) (bool, error) {
+	action := rule.Actions.Moderation
+	if action == nil || !action.Checks(rules.ModerationStageCompletion) {
+		return false, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || resp.Body == nil || resp.Body == http.NoBody || isStreamingResponse(resp) {
+		return false, nil
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return false, nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "application/json" {
+		return false, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if _, err := h.moderate(c, rule, rules.ModerationStageCompletion, body); err != nil {
+		status, code := moderationErrorStatus(err)
+		replaceResponse(c, resp, status, code, err.Error())
+		return true, nil
+	}
+	return false, nil
+}
+
+// moderate 调用审核服务并按 on_flagged 与 fail_closed 处理结果，返回内容是否命中。
+// 需要拒绝时返回包装 errContentRejected 或（fail_closed 时）moderation.ErrUnavailable 的错误。
+func (h *Handler) moderate(c *gin.Context, rule rules.Rule, stage string, body []byte) (bool, error) {
+	action := rule.Actions.Moderation
+	input := moderation.ExtractText(body)
+	if input == "" {
+		return false, nil
+	}
+	user, _ := middleware.CurrentUser(c)
+	verdict, err := h.moderator(c, *action).Moderate(c.Request.Context(), moderation.Request{
+		Stage:  stage,
+		Input:  input,
+		RuleID: rule.ID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		metrics.ObserveModeration(rule.ID, stage, "error")
+		if h.logger != nil {
+			h.logger.Warn("moderation failed",
+				"error", err,
+				"request_id", middleware.RequestIDFromContext(c),
+				"rule_id", rule.ID,
+				"stage", stage,
+				"fail_closed", action.FailClosed,
+			)
+		}
+		if action.FailClosed {
+			return false, err
+		}
+		return false, nil
+	}
+	if !verdict.Flagged {
+		metrics.ObserveModeration(rule.ID, stage, "passed")
+		return false, nil
+	}
+	metrics.ObserveModeration(rule.ID, stage, "flagged")
+	categories := strings.Join(verdict.Categories, ",")
+	if h.logger != nil {
+		h.logger.Warn("moderation flagged",
+			"request_id", middleware.RequestIDFromContext(c),
+			"rule_id", rule.ID,
+			"stage", stage,
+			"categories", categories,
+			"user_id", user.ID,
+		)
+	}
+	switch action.OnFlagged {
+	case rules.ModerationFlag:
+		c.Writer.Header().Add(moderationHeader, strings.TrimSpace(stage+": "+categories))
+	case rules.ModerationLog:
+	default:
+		return true, fmt.Errorf("%w: %s flagged by moderation (%s)", errContentRejected, stage, categories)
+	}
+	return true, nil
+}
+
+// moderator 为本次请求创建审核客户端，api_key 为密钥引用时先解析。
+func (h *Handler) moderator(c *gin.Context, action rules.ModerationAction) moderation.Moderator {
+	apiKey := action.APIKey
+	if secrets.IsReference(apiKey) {
+		if h.secrets == nil {
+			return unavailableModerator{err: errors.New("secret resolver not configured")}
+		}
+		resolved, err := h.secrets.Resolve(c.Request.Context(), apiKey)
+		if err != nil {
+			return unavailableModerator{err: err}
+		}
+		apiKey = resolved
+	}
+	return moderation.New(action, apiKey, nil)
+}
+
+// unavailableModerator 在审核客户端无法创建时返回固定错误，交由 fail_closed 决定是否放行。
+type unavailableModerator struct {
+	err error
+}
+
+func (m unavailableModerator) Moderate(_ context.Context, _ moderation.Request) (moderation.Verdict, error) {
+	return moderation.Verdict{}, fmt.Errorf("%w: %v", moderation.ErrUnavailable, m.err)
+}
+
+// moderationErrorStatus 把审核拒绝映射为响应状态码与错误码。
+func moderationErrorStatus(err error) (int, errcode.Code) {
+	if errors.Is(err, moderation.ErrUnavailable) {
+		return http.StatusServiceUnavailable, errcode.ServiceUnavailable
+	}
+	if errors.Is(err, errContentRejected) {
+		return http.StatusBadRequest, errcode.ContentRejected
+	}
+	return http.StatusBadRequest, errcode.InvalidRequest
+}
+
+// replaceResponse 用网关错误响应替换上游响应。
+func replaceResponse(c *gin.Context, resp *http.Response, status int, code errcode.Code, message string) {
+	errcode.Set(c, code)
+	payload, _ := json.Marshal(errcode.ContextBody(c, code, message))
+	resp.StatusCode = status
+	resp.Status = strconv.Itoa(status) + " " + http.StatusText(status)
+	resp.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Set("Content-Length", strconv.Itoa(len(payload)))
+	resp.ContentLength = int64(len(payload))
+	resp.Body = io.NopCloser(bytes.NewReader(payload))
+}
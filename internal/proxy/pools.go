@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/upstreams"
+	"github.com/prehisle/yapi/pkg/accounts"
+)
+
+// WithPoolSelector 自定义 Key 池选择器，默认每个 Handler 独立创建。
+func WithPoolSelector(selector *upstreams.PoolSelector) Option {
+	return func(h *Handler) {
+		h.pools = selector
+	}
+}
+
+// useBinding 将绑定写入请求上下文；若凭据属于 Key 池，则按池策略替换为轮换选中的成员。
+func (h *Handler) useBinding(c *gin.Context, binding accounts.UserAPIKeyBinding, cred accounts.UpstreamCredential) {
+	if cred.PoolID != "" && h.accountService != nil && h.pools != nil {
+		if member, ok := h.selectPoolMember(c, binding, cred); ok {
+			cred = member
+		}
+	}
+	middleware.SetBinding(c, binding, cred)
+}
+
+func (h *Handler) selectPoolMember(c *gin.Context, binding accounts.UserAPIKeyBinding, cred accounts.UpstreamCredential) (accounts.UpstreamCredential, bool) {
+	ctx := c.Request.Context()
+	pool, err := h.accountService.GetUpstreamKeyPool(ctx, cred.PoolID)
+	if err != nil {
+		h.logger.Warn("load key pool failed", "request_id", middleware.RequestIDFromContext(c), "error", err, "pool", cred.PoolID)
+		return accounts.UpstreamCredential{}, false
+	}
+	members, err := h.accountService.ListUpstreamKeyPoolMembers(ctx, pool.ID)
+	if err != nil {
+		h.logger.Warn("load key pool members failed", "request_id", middleware.RequestIDFromContext(c), "error", err, "pool", pool.ID)
+		return accounts.UpstreamCredential{}, false
+	}
+	owned := members[:0]
+	for _, member := range members {
+		if member.UserID == binding.UserID {
+			owned = append(owned, member)
+		}
+	}
+	member, ok := h.pools.Select(pool, owned)
+	if !ok {
+		h.logger.Warn("key pool exhausted", "request_id", middleware.RequestIDFromContext(c), "pool", pool.ID, "credential", cred.ID)
+	}
+	return member, ok
+}
+
+// observeRateLimits 记录当前上游凭据的限流状态与剩余额度，Key 池据此轮换，故障转移据此跳过额度耗尽的绑定。
+func (h *Handler) observeRateLimits(c *gin.Context, resp *http.Response) {
+	if h.pools == nil {
+		return
+	}
+	if info, ok := middleware.CurrentUpstreamInfo(c); ok {
+		h.pools.Observe(info.Credential.ID, resp)
+	}
+}
+
+// credentialExhausted 判断当前上游凭据是否因限流或额度耗尽被暂时移除。
+func (h *Handler) credentialExhausted(c *gin.Context) bool {
+	if h.pools == nil {
+		return false
+	}
+	info, ok := middleware.CurrentUpstreamInfo(c)
+	if !ok {
+		return false
+	}
+	_, exhausted := h.pools.ExhaustedUntil(info.Credential.ID)
+	return exhausted
+}
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/pii"
+	"github.com/prehisle/yapi/pkg/metrics"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+var errContentRejected = errors.New("request contains sensitive data")
+
+// redactionContextKey 标记本次请求已被 redact_pii 打码，供请求轨迹记录动作。
+const redactionContextKey = "yapi_redacted"
+
+// redactRequest 在转发前按规则的 redact_pii 扫描 JSON 请求体：mask 模式打码后写回 c.Request，
+// reject 模式在命中时返回 errContentRejected。非 JSON 请求不扫描。
+func (h *Handler) redactRequest(c *gin.Context, rule rules.Rule) error {
+	action := rule.Actions.RedactPII
+	if action == nil || c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return nil
+	}
+	if !strings.Contains(strings.ToLower(c.Request.Header.Get("Content-Type")), "application/json") {
+		return nil
+	}
+	filter, err := pii.New(*action)
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	_ = c.Request.Body.Close()
+	redacted, hits, err := filter.JSON(body)
+	if err != nil {
+		return err
+	}
+	mode := action.Mode
+	if mode == "" {
+		mode = rules.RedactionMask
+	}
+	detectors := make([]string, 0, len(hits))
+	for detector, count := range hits {
+		metrics.ObserveRedactions(rule.ID, detector, mode, count)
+		detectors = append(detectors, detector)
+	}
+	if len(detectors) == 0 {
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		return nil
+	}
+	slices.Sort(detectors)
+	if mode == rules.RedactionReject {
+		return fmt.Errorf("%w: %s", errContentRejected, strings.Join(detectors, ", "))
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(redacted))
+	c.Request.ContentLength = int64(len(redacted))
+	c.Set(redactionContextKey, true)
+	return nil
+}
@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/reqtrace"
+)
+
+// replayMaxResponseBytes 是重放结果中保留的响应体上限，超出部分丢弃并标记截断。
+const replayMaxResponseBytes = 256 << 10
+
+// ReplayRequest 描述需要按当前规则重新执行的请求。
+type ReplayRequest struct {
+	Method string
+	// Path 为请求路径，可以带查询串。
+	Path   string
+	Header http.Header
+	Body   []byte
+	// UserID 与 APIKeyID 为原请求的身份，重放时按当前账户数据重新加载，API Key 的绑定一并加载。
+	UserID   string
+	APIKeyID string
+	// ReplayOf 为原请求 ID，只用于日志。
+	ReplayOf string
+}
+
+// ReplayResult 是一次重放的结果。Trace 为重放请求的轨迹，未启用轨迹或请求在规则匹配前被拒绝时为 nil。
+type ReplayResult struct {
+	RequestID  string
+	Status     int
+	Header     http.Header
+	Body       []byte
+	Truncated  bool
+	DurationMs int64
+	Trace      *reqtrace.Trace
+}
+
+// Replay 以新的请求 ID 走一遍完整的代理流程并返回结果。请求会真实发往上游并计入用量，
+// 但不经过网关路由上的限流与配额中间件。
+func (h *Handler) Replay(ctx context.Context, req ReplayRequest) (ReplayResult, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, "http://localhost"+req.Path, bytes.NewReader(req.Body))
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	for name, values := range req.Header {
+		httpReq.Header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+	if host := httpReq.Header.Get("Host"); host != "" {
+		httpReq.Host = host
+	}
+	if len(req.Body) > 0 && httpReq.Header.Get("Content-Type") == "" {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	result := ReplayResult{RequestID: uuid.NewString()}
+	recorder := &replayRecorder{header: make(http.Header), limit: replayMaxResponseBytes}
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		c.Set(middleware.RequestIDKey, result.RequestID)
+		c.Writer.Header().Set("X-Request-ID", result.RequestID)
+		if !h.replayIdentity(c, req) {
+			return
+		}
+		c.Next()
+		result.Trace = currentTrace(c)
+	})
+	engine.NoRoute(h.Handle)
+	engine.NoMethod(h.Handle)
+
+	started := time.Now()
+	engine.ServeHTTP(recorder, httpReq)
+	result.DurationMs = time.Since(started).Milliseconds()
+	result.Status = recorder.status
+	if result.Status == 0 {
+		result.Status = http.StatusOK
+	}
+	result.Header = recorder.header
+	result.Body = recorder.body.Bytes()
+	result.Truncated = recorder.truncated
+	if h.logger != nil {
+		h.logger.Info("request replayed",
+			"request_id", result.RequestID,
+			"replay_of", req.ReplayOf,
+			"status", result.Status,
+		)
+	}
+	return result, nil
+}
+
+// replayIdentity 按 ID 加载原请求的 API Key、用户与绑定，绑定的选择与校验均与 API Key 认证中间件一致：
+// API Key 已删除、停用或绑定的上游凭据停用时直接写入对应错误响应并返回 false。
+func (h *Handler) replayIdentity(c *gin.Context, req ReplayRequest) bool {
+	if h.accountService == nil {
+		return true
+	}
+	ctx := c.Request.Context()
+	userID := req.UserID
+	if req.APIKeyID != "" {
+		apiKey, err := h.accountService.GetUserAPIKey(ctx, req.APIKeyID)
+		if err != nil {
+			errcode.Abort(c, http.StatusUnauthorized, errcode.InvalidAPIKey, "invalid api key")
+			return false
+		}
+		if !apiKey.Enabled {
+			errcode.Abort(c, http.StatusForbidden, errcode.APIKeyDisabled, "api key disabled")
+			return false
+		}
+		middleware.SetAPIKey(c, apiKey)
+		if binding, upstream, err := h.accountService.ResolveBindingByAPIKeyID(ctx, apiKey.ID); err == nil {
+			if !upstream.Enabled {
+				errcode.Abort(c, http.StatusForbidden, errcode.UpstreamCredentialDisabled, "upstream credential disabled")
+				return false
+			}
+			middleware.SetBinding(c, binding, upstream)
+		}
+		userID = apiKey.UserID
+	}
+	if userID != "" {
+		if user, err := h.accountService.GetUser(ctx, userID); err == nil {
+			middleware.SetUser(c, user)
+		}
+	}
+	return true
+}
+
+// replayRecorder 缓存重放的响应，响应体超过 limit 的部分丢弃。
+type replayRecorder struct {
+	header    http.Header
+	status    int
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (r *replayRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *replayRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+}
+
+func (r *replayRecorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	if room := r.limit - r.body.Len(); room < len(b) {
+		r.body.Write(b[:max(room, 0)])
+		r.truncated = true
+	} else {
+		r.body.Write(b)
+	}
+	return len(b), nil
+}
+
+// Flush 供流式响应调用，响应已全部缓存在内存中，无需处理。
+func (r *replayRecorder) Flush() {}
+
+// CloseNotify 满足 gin 对 http.CloseNotifier 的断言；重放没有客户端连接，取消由请求上下文传递。
+func (r *replayRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/respcache"
+	"github.com/prehisle/yapi/pkg/metrics"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// responseCacheHeader 标注响应是否来自 response_cache：HIT 或 MISS。
+const responseCacheHeader = "X-YAPI-Cache"
+
+// responseCacheContextKey 保存未命中缓存的请求的缓存键，上游成功响应后据此写入缓存。
+const responseCacheContextKey = "yapi_response_cache_key"
+
+// WithResponseCache 设置 response_cache 规则动作使用的缓存，未设置时该动作不生效。
+func WithResponseCache(cache *respcache.Cache) Option {
+	return func(h *Handler) {
+		h.responseCache = cache
+	}
+}
+
+// serveCachedResponse 按规则的 response_cache 查找缓存，命中时直接写回缓存的响应并返回 true；
+// 未命中时记下缓存键，由 storeCachedResponse 在上游成功响应后写入。只缓存 POST 的 JSON 请求。
+func (h *Handler) serveCachedResponse(c *gin.Context, rule rules.Rule) (bool, error) {
+	action := rule.Actions.ResponseCache
+	if action == nil || h.responseCache == nil || c.Request.Method != http.MethodPost || c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return false, nil
+	}
+	if !strings.Contains(strings.ToLower(c.Request.Header.Get("Content-Type")), "application/json") {
+		return false, nil
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return false, err
+	}
+	_ = c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	key, ok := respcache.Key(responseCacheScope(c, rule, *action), body)
+	if !ok {
+		return false, nil
+	}
+	entry, hit := h.responseCache.Get(key)
+	if !hit {
+		metrics.ObserveResponseCache(rule.ID, "miss")
+		c.Set(responseCacheContextKey, key)
+		c.Writer.Header().Set(responseCacheHeader, "MISS")
+		return false, nil
+	}
+	metrics.ObserveResponseCache(rule.ID, "hit")
+	currentTrace(c).RecordAction("response_cache")
+	header := c.Writer.Header()
+	for name, values := range entry.Header {
+		header[name] = values
+	}
+	header.Set(responseCacheHeader, "HIT")
+	header.Set("Content-Length", strconv.Itoa(len(entry.Body)))
+	c.Writer.WriteHeader(entry.Status)
+	_, _ = c.Writer.Write(entry.Body)
+	return true, nil
+}
+
+// responseCacheScope 把规则（含版本）、请求路径与用户纳入缓存键，规则修改后旧缓存自然失效。
+// shared 时不区分用户，但按用户渲染的 system_prompt 会改变上游收到的请求，渲染结果纳入缓存键，
+// 只有渲染结果相同的用户才共享缓存。
+func responseCacheScope(c *gin.Context, rule rules.Rule, action rules.ResponseCacheAction) string {
+	scope := rule.ID + "\x00" + strconv.Itoa(rule.Version) + "\x00" + c.Request.URL.Path + "?" + c.Request.URL.RawQuery
+	user, _ := middleware.CurrentUser(c)
+	if !action.Shared {
+		scope += "\x00" + user.ID
+	} else if prompt := rule.Actions.SystemPrompt; prompt != nil {
+		scope += "\x00" + renderSystemPrompt(prompt.Template, user)
+	}
+	return scope
+}
+
+// storeCachedResponse 缓存未命中请求的上游响应，只缓存未压缩的非流式 200 响应。
+func (h *Handler) storeCachedResponse(c *gin.Context, rule rules.Rule, resp *http.Response) error {
+	key := c.GetString(responseCacheContextKey)
+	if key == "" || rule.Actions.ResponseCache == nil || resp.StatusCode != http.StatusOK || resp.Body == nil || resp.Body == http.NoBody || isStreamingResponse(resp) {
+		return nil
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	header := resp.Header.Clone()
+	for _, name := range []string{"Content-Length", "Date", "Set-Cookie"} {
+		header.Del(name)
+	}
+	ttl := time.Duration(rule.Actions.ResponseCache.TTLSeconds) * time.Second
+	h.responseCache.Set(key, respcache.Entry{Status: resp.StatusCode, Header: header, Body: body}, ttl)
+	return nil
+}
@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prehisle/yapi/internal/upstreams"
+)
+
+// WithRetryAfterMax 设置上游以 429 或 503 拒绝且没有备用绑定或备用模型时，按 Retry-After 等待后重试的最长等待时长；
+// 0 表示不等待重试，直接把上游响应返回给客户端。
+func WithRetryAfterMax(d time.Duration) Option {
+	return func(h *Handler) {
+		h.retryAfterMax = d
+	}
+}
+
+// delayedRetry 记录一次请求内按 Retry-After 的延迟重试，每个请求最多重试一次。
+type delayedRetry struct {
+	max     time.Duration
+	used    bool
+	pending bool
+	wait    time.Duration
+}
+
+// newDelayedRetry 在配置了最长等待时长时创建延迟重试状态，否则返回 nil。
+func newDelayedRetry(max time.Duration) *delayedRetry {
+	if max <= 0 {
+		return nil
+	}
+	return &delayedRetry{max: max}
+}
+
+// accept 判断上游响应能否在等待后用同一凭据重试：状态码为 429 或 503，且 Retry-After 不超过最长等待时长。
+func (d *delayedRetry) accept(resp *http.Response) bool {
+	if d == nil || d.used || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return false
+	}
+	wait, ok := upstreams.RetryAfter(resp.Header, time.Now())
+	if !ok || wait > d.max {
+		return false
+	}
+	d.used, d.pending, d.wait = true, true, wait
+	return true
+}
+
+// take 取出待执行的等待时长。
+func (d *delayedRetry) take() (time.Duration, bool) {
+	if d == nil || !d.pending {
+		return 0, false
+	}
+	d.pending = false
+	return d.wait, true
+}
+
+// retried 判断当前尝试是否为延迟重试。
+func (d *delayedRetry) retried() bool {
+	return d != nil && d.used && !d.pending
+}
@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// errNoServiceBinding 表示 API Key 没有与规则目标服务匹配的可用绑定。
+var errNoServiceBinding = errors.New("api key has no binding for service")
+
+// knownServiceHosts 用于从规则目标地址推断上游服务。
+var knownServiceHosts = map[string]string{
+	"api.openai.com":    "openai",
+	"api.anthropic.com": "anthropic",
+
+	"generativelanguage.googleapis.com": "gemini",
+}
+
+// ruleService 返回规则面向的上游服务：优先使用 upstream_service，否则按 set_target_url 主机名推断。
+func ruleService(rule rules.Rule) string {
+	if service := strings.TrimSpace(rule.Actions.UpstreamService); service != "" {
+		return service
+	}
+	target := strings.TrimSpace(rule.Actions.SetTargetURL)
+	if target == "" {
+		return ""
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	return knownServiceHosts[strings.ToLower(u.Hostname())]
+}
+
+// scopeBindingToService 在当前绑定的 Service 与规则目标服务不一致时，切换到该 API Key 下
+// 同一服务 position 最小的可用绑定，使一个客户端 Key 可透明访问多个供应商；
+// 没有该服务的显式绑定时回退到用户在该服务下的默认凭据。
+func (h *Handler) scopeBindingToService(c *gin.Context, rule rules.Rule) error {
+	service := ruleService(rule)
+	if service == "" || h.accountService == nil {
+		return nil
+	}
+	binding, ok := middleware.CurrentBinding(c)
+	if !ok || strings.EqualFold(strings.TrimSpace(binding.Service), service) {
+		return nil
+	}
+	if binding.UserAPIKeyID != "" {
+		bindings, err := h.accountService.ListBindingsByAPIKey(c.Request.Context(), binding.UserAPIKeyID)
+		if err != nil {
+			return err
+		}
+		for _, item := range bindings {
+			if !strings.EqualFold(strings.TrimSpace(item.Binding.Service), service) {
+				continue
+			}
+			if !item.Upstream.Enabled || item.Upstream.UserID != binding.UserID {
+				continue
+			}
+			h.useBinding(c, item.Binding, item.Upstream)
+			return nil
+		}
+		fallback, upstream, err := h.accountService.ResolveDefaultBinding(c.Request.Context(), binding.UserAPIKeyID, service)
+		if err != nil && !errors.Is(err, accounts.ErrNotFound) {
+			return err
+		}
+		if err == nil && upstream.UserID == binding.UserID {
+			h.useBinding(c, fallback, upstream)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w %q", errNoServiceBinding, service)
+}
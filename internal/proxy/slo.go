@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/pkg/metrics"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// defaultSLOLatencyThreshold 是延迟 SLO 的默认阈值，按收到响应头计时，流式响应即首字节耗时。
+const defaultSLOLatencyThreshold = 5 * time.Second
+
+// statusClientClosed 是客户端主动取消时记录的状态码。
+const statusClientClosed = 499
+
+// WithSLOLatencyThreshold 设置延迟 SLO 的阈值，响应头在该耗时内写出的请求计为达标。
+func WithSLOLatencyThreshold(threshold time.Duration) Option {
+	return func(h *Handler) {
+		if threshold > 0 {
+			h.sloThreshold = threshold
+		}
+	}
+}
+
+// trackSLO 记录响应头写出的时间，返回的函数在请求结束时按规则上报 SLO 结果。
+func (h *Handler) trackSLO(c *gin.Context, rule rules.Rule) func() {
+	writer := &sloWriter{ResponseWriter: c.Writer, start: time.Now()}
+	c.Writer = writer
+	return func() {
+		c.Writer = writer.ResponseWriter
+		status := c.Writer.Status()
+		if status == statusClientClosed {
+			// 客户端取消既不反映网关可用性也不反映延迟，不计入 SLO。
+			return
+		}
+		latency := writer.headerLatency
+		if latency == 0 {
+			latency = time.Since(writer.start)
+		}
+		metrics.ObserveSLO(rule.ID, status < http.StatusInternalServerError, latency <= h.sloThreshold)
+	}
+}
+
+// sloWriter 记录首次写出响应头的耗时。
+type sloWriter struct {
+	gin.ResponseWriter
+	start         time.Time
+	headerLatency time.Duration
+}
+
+func (w *sloWriter) WriteHeader(code int) {
+	w.markHeader()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sloWriter) Write(b []byte) (int, error) {
+	w.markHeader()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *sloWriter) WriteString(s string) (int, error) {
+	w.markHeader()
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Unwrap 供 http.ResponseController 访问底层连接。
+func (w *sloWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *sloWriter) markHeader() {
+	if w.headerLatency == 0 {
+		w.headerLatency = time.Since(w.start)
+	}
+}
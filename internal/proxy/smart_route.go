@@ -0,0 +1,203 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/upstreams"
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/metrics"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// smartRouteContextKey 标记本次请求的凭据由 smart_route 选出，供决策轨迹记录。
+const smartRouteContextKey = "yapi_smart_routed"
+
+// defaultSmartRouteStickyTTL 是未指定 sticky_ttl_seconds 时粘性选择的有效期。
+const defaultSmartRouteStickyTTL = 30 * time.Minute
+
+// errNoSmartRouteCandidate 表示候选服务下没有健康的可用凭据。
+var errNoSmartRouteCandidate = errors.New("no healthy upstream credential for smart route")
+
+// routeOption 是一个可选的凭据及转发到该凭据时使用的模型。
+type routeOption struct {
+	credential accounts.UpstreamCredential
+	model      string
+}
+
+// smartRoute 执行 SmartRoute 动作：在用户属于候选服务的启用凭据中排除已知不可用的凭据，按策略选出一个，
+// 改写请求模型并替换本次请求的绑定。未配置该动作、无法识别用户或请求模型不在 models 中时保持当前绑定不变。
+func (h *Handler) smartRoute(c *gin.Context, rule rules.Rule) error {
+	route := rule.Actions.SmartRoute
+	if route == nil || h.accountService == nil {
+		return nil
+	}
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		return nil
+	}
+	var body []byte
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(c.Request.Body); err != nil {
+			return err
+		}
+		_ = c.Request.Body.Close()
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	model := requestModel(body)
+	if len(route.Models) > 0 && !slices.Contains(route.Models, model) {
+		return nil
+	}
+	creds, _, err := h.accountService.ListUpstreamCredentials(c.Request.Context(), user.ID, accounts.ListOptions{})
+	if err != nil {
+		return err
+	}
+	options := h.smartRouteOptions(*route, creds, model)
+	if len(options) == 0 {
+		metrics.ObserveSmartRoute(rule.ID, "", "unavailable")
+		return errNoSmartRouteCandidate
+	}
+	chosen, reason := -1, "sticky"
+	key := smartRouteStickyKey(c, rule, *route, user.ID, body)
+	if key != "" {
+		if sticky, ok := h.smartRoutes.get(key); ok {
+			chosen = slices.IndexFunc(options, func(o routeOption) bool { return o.credential.ID == sticky.credentialID })
+		}
+	}
+	if chosen < 0 {
+		chosen, reason = h.rankSmartRoute(route.Strategy, options), route.Strategy
+	}
+	option := options[chosen]
+	if key != "" {
+		ttl := defaultSmartRouteStickyTTL
+		if route.StickyTTLSeconds > 0 {
+			ttl = time.Duration(route.StickyTTLSeconds) * time.Second
+		}
+		h.smartRoutes.set(key, stickyRoute{credentialID: option.credential.ID}, ttl)
+	}
+	if option.model != "" && option.model != model && json.Valid(body) {
+		if body, err = setBodyModel(body, option.model); err != nil {
+			return err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+	}
+	binding, hasBinding := middleware.CurrentBinding(c)
+	if !hasBinding {
+		apiKey, _ := middleware.CurrentAPIKey(c)
+		binding = accounts.UserAPIKeyBinding{
+			UserID:       user.ID,
+			UserAPIKeyID: apiKey.ID,
+			Metadata:     datatypes.JSONMap{"source": "smart_route"},
+		}
+	}
+	binding.Service = option.credential.Service
+	binding.UpstreamKeyID = option.credential.ID
+	h.useBinding(c, binding, option.credential)
+	c.Set(smartRouteContextKey, true)
+	metrics.ObserveSmartRoute(rule.ID, option.credential.Service, reason)
+	return nil
+}
+
+// smartRouteOptions 按候选顺序列出可用的凭据，跳过已停用、健康检查判定为不可用或已知额度耗尽的凭据。
+func (h *Handler) smartRouteOptions(route rules.SmartRouteAction, creds []accounts.UpstreamCredential, model string) []routeOption {
+	var options []routeOption
+	for _, candidate := range route.Candidates {
+		target := candidate.Model
+		if target == "" {
+			target = model
+		}
+		for _, cred := range creds {
+			if !strings.EqualFold(cred.Service, strings.TrimSpace(candidate.Service)) || !h.credentialAvailable(cred) {
+				continue
+			}
+			options = append(options, routeOption{credential: cred, model: target})
+		}
+	}
+	return options
+}
+
+// rankSmartRoute 返回按策略最优的候选下标。cheapest 比较每百万 token 的提示词与补全单价之和，
+// fastest 比较端点健康得分；主指标相同时比较另一指标，仍相同时取靠前的候选。
+func (h *Handler) rankSmartRoute(strategy string, options []routeOption) int {
+	costs := make([]float64, len(options))
+	scores := make([]float64, len(options))
+	for i, option := range options {
+		costs[i] = math.Inf(1)
+		if price, ok := h.pricing.Lookup(option.model); ok {
+			costs[i] = price.Prompt + price.Completion
+		}
+		scores[i] = h.credentialScore(option.credential)
+	}
+	better := func(i, j int) bool {
+		if strategy == rules.SmartRouteFastest {
+			return scores[i] > scores[j] || (scores[i] == scores[j] && costs[i] < costs[j])
+		}
+		return costs[i] < costs[j] || (costs[i] == costs[j] && scores[i] > scores[j])
+	}
+	best := 0
+	for i := 1; i < len(options); i++ {
+		if better(i, best) {
+			best = i
+		}
+	}
+	return best
+}
+
+// credentialScore 返回凭据各端点中最高的健康得分；尚无样本或未配置端点的凭据按 1 处理，
+// 因此新凭据会先被选中以获得延迟样本。
+func (h *Handler) credentialScore(cred accounts.UpstreamCredential) float64 {
+	var endpoints []string
+	if len(cred.Endpoints) > 0 {
+		_ = json.Unmarshal(cred.Endpoints, &endpoints)
+	}
+	best := -1.0
+	for _, raw := range endpoints {
+		target, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil || target.Host == "" {
+			continue
+		}
+		best = max(best, h.endpoints.Score(upstreams.EndpointKey(target)))
+	}
+	if best < 0 {
+		return 1
+	}
+	return best
+}
+
+// smartRouteStickyKey 返回粘性选择的键，未开启粘性或请求未携带会话标识时返回空字符串。
+func smartRouteStickyKey(c *gin.Context, rule rules.Rule, route rules.SmartRouteAction, userID string, body []byte) string {
+	switch route.Sticky {
+	case rules.SmartRouteStickyConversation:
+		conversation := conversationID(c, rule, body)
+		if conversation == "" {
+			return ""
+		}
+		return rule.ID + "\x00conversation\x00" + userID + "\x00" + conversation
+	case rules.SmartRouteStickyUser:
+		return rule.ID + "\x00user\x00" + userID
+	}
+	return ""
+}
+
+// smartRouteError 将选择失败映射为响应状态码与错误码。
+func smartRouteError(err error) (int, errcode.Code) {
+	if errors.Is(err, errNoSmartRouteCandidate) {
+		return http.StatusServiceUnavailable, errcode.NoMatchingUpstream
+	}
+	return http.StatusBadGateway, errcode.Internal
+}
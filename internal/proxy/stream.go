@@ -0,0 +1,205 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/errcode"
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/pkg/metrics"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// sseHeartbeat 是上游静默时写给客户端的 SSE 注释行，客户端按规范忽略注释，只用于保持连接活跃。
+const sseHeartbeat = ": ping\n\n"
+
+// streamReadSize 是后台读取上游 SSE 响应体的缓冲大小。
+const streamReadSize = 32 << 10
+
+// WithStreamHeartbeat 设置 SSE 响应在上游静默多久后向客户端发送一次 `: ping` 注释行，0 表示不发送。
+func WithStreamHeartbeat(interval time.Duration) Option {
+	return func(h *Handler) {
+		h.streamHeartbeat = interval
+	}
+}
+
+// WithStreamIdleTimeout 设置 SSE 响应允许上游连续静默的最长时长，超过后向客户端发送 error 事件并结束响应，0 表示不限制。
+func WithStreamIdleTimeout(timeout time.Duration) Option {
+	return func(h *Handler) {
+		h.streamIdleTimeout = timeout
+	}
+}
+
+// watchStream 为 SSE 成功响应包装响应体，按配置注入心跳并在上游长时间静默时以 error 事件结束响应。
+// 在协议转换之后包装，心跳与错误事件均以客户端看到的事件边界为准。
+func (h *Handler) watchStream(c *gin.Context, rule rules.Rule, resp *http.Response) {
+	if h.streamHeartbeat <= 0 && h.streamIdleTimeout <= 0 {
+		return
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		return
+	}
+	resp.Body = newStreamWatchdog(resp.Body, h.streamHeartbeat, h.streamIdleTimeout, func(idle time.Duration) {
+		errcode.Set(c, errcode.UpstreamTimeout)
+		metrics.ObserveStreamIdleTimeout(rule.ID)
+		if h.logger != nil {
+			h.logger.Warn("upstream stream idle timeout",
+				"request_id", middleware.RequestIDFromContext(c),
+				"rule_id", rule.ID,
+				"path", c.Request.URL.Path,
+				"idle", idle.String(),
+			)
+		}
+	})
+}
+
+// streamChunk 是后台读取到的一段上游数据及读取结束时的错误。
+type streamChunk struct {
+	data []byte
+	err  error
+}
+
+// streamWatchdog 在后台读取上游响应体，使 Read 可以在等待上游数据的同时按时写出心跳或超时错误事件。
+// 心跳只在完整事件之后插入，避免拆开上游的事件。
+type streamWatchdog struct {
+	body      io.ReadCloser
+	chunks    chan streamChunk
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	heartbeat time.Duration
+	idle      time.Duration
+	onTimeout func(time.Duration)
+
+	pending   []byte
+	err       error
+	tail      []byte
+	lastData  time.Time
+	lastWrite time.Time
+}
+
+func newStreamWatchdog(body io.ReadCloser, heartbeat, idle time.Duration, onTimeout func(time.Duration)) *streamWatchdog {
+	now := time.Now()
+	w := &streamWatchdog{
+		body:      body,
+		chunks:    make(chan streamChunk),
+		stop:      make(chan struct{}),
+		heartbeat: heartbeat,
+		idle:      idle,
+		onTimeout: onTimeout,
+		lastData:  now,
+		lastWrite: now,
+	}
+	go w.pump()
+	return w
+}
+
+// pump 持续读取上游响应体，直到读完、出错或响应体被关闭。
+func (w *streamWatchdog) pump() {
+	for {
+		buf := make([]byte, streamReadSize)
+		n, err := w.body.Read(buf)
+		if n == 0 && err == nil {
+			continue
+		}
+		select {
+		case w.chunks <- streamChunk{data: buf[:n], err: err}:
+		case <-w.stop:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (w *streamWatchdog) Read(p []byte) (int, error) {
+	for len(w.pending) == 0 {
+		if w.err != nil {
+			return 0, w.err
+		}
+		timer := time.NewTimer(w.nextDeadline())
+		select {
+		case chunk := <-w.chunks:
+			timer.Stop()
+			w.lastData, w.lastWrite = time.Now(), time.Now()
+			w.pending, w.err = chunk.data, chunk.err
+		case <-timer.C:
+			w.tick(time.Now())
+		}
+	}
+	n := copy(p, w.pending)
+	w.pending = w.pending[n:]
+	w.tail = append(w.tail, p[:n]...)
+	if len(w.tail) > 4 {
+		w.tail = w.tail[len(w.tail)-4:]
+	}
+	return n, nil
+}
+
+// nextDeadline 返回距下一次需要检查心跳或空闲超时的时长。
+func (w *streamWatchdog) nextDeadline() time.Duration {
+	now := time.Now()
+	next := time.Duration(-1)
+	if w.heartbeat > 0 {
+		next = w.lastWrite.Add(w.heartbeat).Sub(now)
+	}
+	if w.idle > 0 {
+		if until := w.lastData.Add(w.idle).Sub(now); next < 0 || until < next {
+			next = until
+		}
+	}
+	return max(next, 0)
+}
+
+// tick 在等待上游数据超时时决定写出心跳还是错误事件。上游正处于事件中途时本轮不发送心跳。
+func (w *streamWatchdog) tick(now time.Time) {
+	if w.idle > 0 && now.Sub(w.lastData) >= w.idle {
+		w.onTimeout(w.idle)
+		w.pending, w.err = w.timeoutEvent(), io.EOF
+		return
+	}
+	if w.heartbeat > 0 && now.Sub(w.lastWrite) >= w.heartbeat {
+		if w.atBoundary() {
+			w.pending = []byte(sseHeartbeat)
+		}
+		w.lastWrite = now
+	}
+}
+
+// timeoutEvent 生成空闲超时的 SSE error 事件，data 与网关其他错误响应的结构一致。
+// 上游停在事件中途时先补一个空行结束该事件。
+func (w *streamWatchdog) timeoutEvent() []byte {
+	payload, _ := json.Marshal(errcode.Body(errcode.UpstreamTimeout, fmt.Sprintf("upstream stream idle for %s", w.idle)))
+	event := fmt.Sprintf("event: error\ndata: %s\n\n", payload)
+	if !w.atBoundary() {
+		event = "\n\n" + event
+	}
+	return []byte(event)
+}
+
+// atBoundary 判断已写出的数据是否停在事件边界（尚未写出数据或以空行结尾）。
+func (w *streamWatchdog) atBoundary() bool {
+	tail := string(w.tail)
+	return tail == "" || strings.HasSuffix(tail, "\n\n") || strings.HasSuffix(tail, "\r\r") || strings.HasSuffix(tail, "\r\n\r\n")
+}
+
+func (w *streamWatchdog) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.stop)
+		err = w.body.Close()
+	})
+	return err
+}
@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/tidwall/sjson"
+
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+var promptVariablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// renderSystemPrompt 用当前用户信息替换模板中的 {{user.id}}、{{user.name}} 与 {{user.metadata.<key>}}，
+// 未知变量与缺失的元数据替换为空字符串。
+func renderSystemPrompt(template string, user accounts.User) string {
+	return promptVariablePattern.ReplaceAllStringFunc(template, func(match string) string {
+		name := promptVariablePattern.FindStringSubmatch(match)[1]
+		switch {
+		case name == "user.id":
+			return user.ID
+		case name == "user.name":
+			return user.Name
+		case strings.HasPrefix(name, "user.metadata."):
+			if value, ok := user.Metadata[strings.TrimPrefix(name, "user.metadata.")]; ok && value != nil {
+				return fmt.Sprint(value)
+			}
+		}
+		return ""
+	})
+}
+
+// applySystemPrompt 把渲染后的提示写入聊天请求的 system 消息，返回改写的 JSON 路径。
+// 路径以 /messages 结尾的请求按 Anthropic Messages 格式改写顶层 system，其余带 messages 数组的请求按 OpenAI 格式改写 system 角色消息；
+// 非 JSON 请求或不含 messages 的请求保持不变。
+func applySystemPrompt(req *http.Request, action *rules.SystemPromptAction, prompt string) (string, error) {
+	if action == nil || req.Body == nil || req.Body == http.NoBody {
+		return "", nil
+	}
+	if !strings.Contains(strings.ToLower(req.Header.Get("Content-Type")), "application/json") {
+		return "", nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", err
+	}
+	_ = req.Body.Close()
+	setRequestBody(req, body)
+	var payload struct {
+		Messages []json.RawMessage `json:"messages"`
+		System   json.RawMessage   `json:"system"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Messages == nil {
+		return "", nil
+	}
+	replace := action.Mode == rules.SystemPromptReplace
+	var path string
+	if strings.HasSuffix(strings.TrimSuffix(req.URL.Path, "/"), "/messages") {
+		path = "system"
+		body, err = injectAnthropicSystem(body, payload.System, prompt, replace)
+	} else {
+		path = "messages"
+		body, err = injectOpenAISystem(body, payload.Messages, prompt, replace)
+	}
+	if err != nil {
+		return "", err
+	}
+	setRequestBody(req, body)
+	return path, nil
+}
+
+// injectAnthropicSystem 改写 Anthropic 请求的顶层 system，它可以是字符串或文本块数组。
+func injectAnthropicSystem(body []byte, system json.RawMessage, prompt string, replace bool) ([]byte, error) {
+	if replace || len(system) == 0 || string(system) == "null" {
+		return sjson.SetBytes(body, "system", prompt)
+	}
+	content, err := prependPromptContent(system, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return sjson.SetRawBytes(body, "system", content)
+}
+
+// injectOpenAISystem 改写 OpenAI 请求的 messages：replace 移除所有 system/developer 消息后插入模板，
+// prepend 把模板并入首条 system 消息，没有时在开头插入一条。
+func injectOpenAISystem(body []byte, messages []json.RawMessage, prompt string, replace bool) ([]byte, error) {
+	promptMessage, err := json.Marshal(map[string]string{"role": "system", "content": prompt})
+	if err != nil {
+		return nil, err
+	}
+	var first struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	}
+	if len(messages) > 0 {
+		_ = json.Unmarshal(messages[0], &first)
+	}
+	if !replace && isSystemRole(first.Role) && len(first.Content) > 0 {
+		content, err := prependPromptContent(first.Content, prompt)
+		if err != nil {
+			return nil, err
+		}
+		return sjson.SetRawBytes(body, "messages.0.content", content)
+	}
+	rewritten := []json.RawMessage{promptMessage}
+	for _, message := range messages {
+		var role struct {
+			Role string `json:"role"`
+		}
+		_ = json.Unmarshal(message, &role)
+		if replace && isSystemRole(role.Role) {
+			continue
+		}
+		rewritten = append(rewritten, message)
+	}
+	raw, err := json.Marshal(rewritten)
+	if err != nil {
+		return nil, err
+	}
+	return sjson.SetRawBytes(body, "messages", raw)
+}
+
+// prependPromptContent 把提示放在消息内容之前：字符串内容以空行拼接，内容块数组在开头插入文本块。
+func prependPromptContent(content json.RawMessage, prompt string) ([]byte, error) {
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		return json.Marshal(prompt + "\n\n" + text)
+	}
+	var blocks []json.RawMessage
+	if err := json.Unmarshal(content, &blocks); err != nil {
+		return nil, fmt.Errorf("unsupported system content: %w", err)
+	}
+	block, err := json.Marshal(map[string]string{"type": "text", "text": prompt})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(append([]json.RawMessage{block}, blocks...))
+}
+
+func isSystemRole(role string) bool {
+	return role == "system" || role == "developer"
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/tokenizer"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// estimatedTokensHeader 在响应中返回转发前估算的提示词 token 数。
+const estimatedTokensHeader = "X-Estimated-Tokens"
+
+// estimatedTokensContextKey 保存估算的提示词 token 数，供后续处理读取。
+const estimatedTokensContextKey = "yapi_estimated_tokens"
+
+var errPromptTooLarge = errors.New("prompt exceeds token limit")
+
+// WithTokenizer 设置估算提示词 token 数使用的分词器，默认按近似规则估算。
+func WithTokenizer(t *tokenizer.Tokenizer) Option {
+	return func(h *Handler) {
+		h.tokenizer = t
+	}
+}
+
+// estimateTokens 在转发前估算 JSON 请求体的提示词 token 数，写入 X-Estimated-Tokens 响应头；
+// 超过规则的 max_prompt_tokens 时返回 errPromptTooLarge。不含提示词字段的请求不估算。
+func (h *Handler) estimateTokens(c *gin.Context, rule rules.Rule) error {
+	if h.tokenizer == nil || c.Request.Method != http.MethodPost || c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return nil
+	}
+	if !strings.Contains(strings.ToLower(c.Request.Header.Get("Content-Type")), "application/json") {
+		return nil
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	_ = c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	count, ok := h.tokenizer.CountRequest(body)
+	if !ok {
+		return nil
+	}
+	c.Set(estimatedTokensContextKey, count)
+	c.Writer.Header().Set(estimatedTokensHeader, strconv.Itoa(count))
+	if limit := rule.Actions.MaxPromptTokens; limit > 0 && count > limit {
+		return fmt.Errorf("%w: estimated %d tokens, limit %d", errPromptTooLarge, count, limit)
+	}
+	return nil
+}
@@ -0,0 +1,710 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/sjson"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// toolPolicy 是 tool_policy 动作的查询形式，名称集合在每次使用时由动作构建。
+type toolPolicy struct {
+	action   *rules.ToolPolicyAction
+	allow    map[string]bool
+	remove   map[string]bool
+	reverse  map[string]string
+	injected map[string]bool
+}
+
+func newToolPolicy(action *rules.ToolPolicyAction) *toolPolicy {
+	p := &toolPolicy{
+		action:   action,
+		allow:    make(map[string]bool, len(action.Allow)),
+		remove:   make(map[string]bool, len(action.Remove)),
+		reverse:  make(map[string]string, len(action.Rename)),
+		injected: make(map[string]bool, len(action.Inject)),
+	}
+	for _, name := range action.Allow {
+		p.allow[name] = true
+	}
+	for _, name := range action.Remove {
+		p.remove[name] = true
+	}
+	for from, to := range action.Rename {
+		p.reverse[to] = from
+	}
+	for _, tool := range action.Inject {
+		p.injected[tool.Name] = true
+	}
+	return p
+}
+
+// permits 判断客户端工具是否可以转发给上游。
+func (p *toolPolicy) permits(name string) bool {
+	return !p.remove[name] && (len(p.allow) == 0 || p.allow[name])
+}
+
+// upstreamName 返回客户端工具在上游请求中的名称。
+func (p *toolPolicy) upstreamName(name string) string {
+	if to, ok := p.action.Rename[name]; ok {
+		return to
+	}
+	return name
+}
+
+// resolveCall 把模型调用的（上游）工具名还原为客户端名称，调用未授权的工具时返回 false。注入的工具总是放行。
+func (p *toolPolicy) resolveCall(name string) (string, bool) {
+	if p.injected[name] {
+		return name, true
+	}
+	client := name
+	if from, ok := p.reverse[name]; ok {
+		client = from
+	}
+	return client, p.permits(client)
+}
+
+// toolFormat 描述一种协议中工具列表、工具选择与工具名称所在的位置。
+type toolFormat struct {
+	toolsKey   string
+	choiceKey  string
+	namePath   string
+	choicePath string
+	// companions 是工具列表清空时一并删除的字段。
+	companions []string
+	render     func(rules.ToolDefinition) any
+}
+
+var (
+	openAIToolFormat = toolFormat{
+		toolsKey:   "tools",
+		choiceKey:  "tool_choice",
+		namePath:   "function.name",
+		choicePath: "function.name",
+		companions: []string{"parallel_tool_calls"},
+		render: func(tool rules.ToolDefinition) any {
+			return map[string]any{"type": "function", "function": openAIFunction(tool)}
+		},
+	}
+	openAIFunctionFormat = toolFormat{
+		toolsKey:   "functions",
+		choiceKey:  "function_call",
+		namePath:   "name",
+		choicePath: "name",
+		render: func(tool rules.ToolDefinition) any {
+			return openAIFunction(tool)
+		},
+	}
+	anthropicToolFormat = toolFormat{
+		toolsKey:   "tools",
+		choiceKey:  "tool_choice",
+		namePath:   "name",
+		choicePath: "name",
+		render: func(tool rules.ToolDefinition) any {
+			def := map[string]any{"name": tool.Name, "input_schema": toolParameters(tool)}
+			if tool.Description != "" {
+				def["description"] = tool.Description
+			}
+			return def
+		},
+	}
+)
+
+func openAIFunction(tool rules.ToolDefinition) map[string]any {
+	def := map[string]any{"name": tool.Name, "parameters": toolParameters(tool)}
+	if tool.Description != "" {
+		def["description"] = tool.Description
+	}
+	return def
+}
+
+func toolParameters(tool rules.ToolDefinition) map[string]any {
+	if tool.Parameters == nil {
+		return map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	return tool.Parameters
+}
+
+// applyToolPolicy 按 tool_policy 改写聊天请求的工具列表、工具选择与历史消息中的工具调用，返回请求体是否改变。
+// 路径以 /messages 结尾的请求按 Anthropic Messages 格式处理，其余按 OpenAI 格式（含旧版 functions）处理；
+// 非 JSON 请求或不含 messages 与工具列表的请求保持不变。
+func applyToolPolicy(req *http.Request, action *rules.ToolPolicyAction) (bool, error) {
+	if action == nil || req.Body == nil || req.Body == http.NoBody {
+		return false, nil
+	}
+	if !strings.Contains(strings.ToLower(req.Header.Get("Content-Type")), "application/json") {
+		return false, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return false, err
+	}
+	_ = req.Body.Close()
+	setRequestBody(req, body)
+	var payload struct {
+		Messages     []json.RawMessage `json:"messages"`
+		Tools        []json.RawMessage `json:"tools"`
+		ToolChoice   json.RawMessage   `json:"tool_choice"`
+		Functions    []json.RawMessage `json:"functions"`
+		FunctionCall json.RawMessage   `json:"function_call"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return false, nil
+	}
+	if payload.Messages == nil && payload.Tools == nil && payload.Functions == nil {
+		return false, nil
+	}
+	policy := newToolPolicy(action)
+	rewritten := body
+	if strings.HasSuffix(strings.TrimSuffix(req.URL.Path, "/"), "/messages") {
+		rewritten, err = policy.rewriteTools(rewritten, anthropicToolFormat, payload.Tools, payload.ToolChoice, true)
+	} else {
+		// 只使用旧版 functions 的请求把注入的工具写入 functions，避免与 tools 混用。
+		legacy := payload.Functions != nil && payload.Tools == nil
+		rewritten, err = policy.rewriteTools(rewritten, openAIToolFormat, payload.Tools, payload.ToolChoice, !legacy)
+		if err == nil && payload.Functions != nil {
+			rewritten, err = policy.rewriteTools(rewritten, openAIFunctionFormat, payload.Functions, payload.FunctionCall, legacy)
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	if rewritten, err = policy.renameHistory(rewritten, payload.Messages); err != nil {
+		return false, err
+	}
+	if bytes.Equal(rewritten, body) {
+		return false, nil
+	}
+	setRequestBody(req, rewritten)
+	return true, nil
+}
+
+// rewriteTools 过滤、重命名并注入一种格式的工具列表，工具选择指向被移除的工具时一并删除；
+// 工具列表清空时删除列表与工具选择，避免上游拒绝空数组。
+func (p *toolPolicy) rewriteTools(body []byte, format toolFormat, tools []json.RawMessage, choice json.RawMessage, inject bool) ([]byte, error) {
+	if tools == nil && (!inject || len(p.action.Inject) == 0) {
+		return body, nil
+	}
+	kept := make([]json.RawMessage, 0, len(tools)+len(p.action.Inject))
+	positions := make(map[string]int, len(tools))
+	changed := false
+	for _, tool := range tools {
+		name, ok := jsonString(tool, format.namePath)
+		if !ok {
+			kept = append(kept, tool)
+			continue
+		}
+		if !p.permits(name) {
+			changed = true
+			continue
+		}
+		if to := p.upstreamName(name); to != name {
+			renamed, err := sjson.SetBytes(tool, format.namePath, to)
+			if err != nil {
+				return nil, err
+			}
+			tool, name, changed = renamed, to, true
+		}
+		positions[name] = len(kept)
+		kept = append(kept, tool)
+	}
+	if inject {
+		for _, tool := range p.action.Inject {
+			raw, err := json.Marshal(format.render(tool))
+			if err != nil {
+				return nil, err
+			}
+			if i, ok := positions[tool.Name]; ok {
+				kept[i] = raw
+			} else {
+				kept = append(kept, raw)
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return body, nil
+	}
+	var err error
+	if len(kept) == 0 {
+		for _, key := range append([]string{format.toolsKey, format.choiceKey}, format.companions...) {
+			if body, err = sjson.DeleteBytes(body, key); err != nil {
+				return nil, err
+			}
+		}
+		return body, nil
+	}
+	raw, err := json.Marshal(kept)
+	if err != nil {
+		return nil, err
+	}
+	if body, err = sjson.SetRawBytes(body, format.toolsKey, raw); err != nil {
+		return nil, err
+	}
+	name, ok := jsonString(choice, format.choicePath)
+	switch {
+	case !ok || p.injected[name]:
+		return body, nil
+	case !p.permits(name):
+		return sjson.DeleteBytes(body, format.choiceKey)
+	case p.upstreamName(name) != name:
+		return sjson.SetBytes(body, format.choiceKey+"."+format.choicePath, p.upstreamName(name))
+	}
+	return body, nil
+}
+
+// renameHistory 把历史消息中对重命名工具的调用改为上游名称，使上游看到的对话与工具列表一致：
+// OpenAI 的 tool_calls 与 function_call，以及 Anthropic 的 tool_use 内容块。
+func (p *toolPolicy) renameHistory(body []byte, messages []json.RawMessage) ([]byte, error) {
+	if len(p.action.Rename) == 0 {
+		return body, nil
+	}
+	var err error
+	rename := func(path, name string) {
+		if to := p.upstreamName(name); err == nil && to != name {
+			body, err = sjson.SetBytes(body, path, to)
+		}
+	}
+	for i, raw := range messages {
+		var message struct {
+			ToolCalls []struct {
+				Function struct {
+					Name string `json:"name"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+			FunctionCall *struct {
+				Name string `json:"name"`
+			} `json:"function_call"`
+			Content json.RawMessage `json:"content"`
+		}
+		if json.Unmarshal(raw, &message) != nil {
+			continue
+		}
+		for j, call := range message.ToolCalls {
+			rename(fmt.Sprintf("messages.%d.tool_calls.%d.function.name", i, j), call.Function.Name)
+		}
+		if message.FunctionCall != nil {
+			rename(fmt.Sprintf("messages.%d.function_call.name", i), message.FunctionCall.Name)
+		}
+		var blocks []struct {
+			Type string `json:"type"`
+			Name string `json:"name"`
+		}
+		if json.Unmarshal(message.Content, &blocks) != nil {
+			continue
+		}
+		for j, block := range blocks {
+			if block.Type == "tool_use" {
+				rename(fmt.Sprintf("messages.%d.content.%d.name", i, j), block.Name)
+			}
+		}
+	}
+	return body, err
+}
+
+// filterToolCalls 过滤成功响应中的工具调用：调用未授权工具的 tool_calls（Anthropic 为 tool_use 内容块）被删除，
+// 重命名工具的调用改回客户端名称。JSON 响应整体改写，SSE 响应逐个事件改写；压缩的响应体跳过。
+func (h *Handler) filterToolCalls(rule rules.Rule, resp *http.Response) error {
+	action := rule.Actions.ToolPolicy
+	if action == nil || resp.StatusCode < 200 || resp.StatusCode >= 300 || resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return nil
+	}
+	policy := newToolPolicy(action)
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/event-stream":
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Body = &toolCallStream{src: resp.Body, reader: bufio.NewReader(resp.Body), policy: policy}
+	case "application/json":
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if body, err = policy.filterResponse(body); err != nil {
+			return err
+		}
+		resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		resp.ContentLength = int64(len(body))
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return nil
+}
+
+// filterResponse 过滤 OpenAI chat.completion 与 Anthropic message 响应中的工具调用。
+// 全部工具调用被删除时把结束原因从 tool_calls / function_call / tool_use 改为 stop / end_turn。
+func (p *toolPolicy) filterResponse(body []byte) ([]byte, error) {
+	var payload struct {
+		Choices []struct {
+			FinishReason string `json:"finish_reason"`
+			Message      struct {
+				ToolCalls    []json.RawMessage `json:"tool_calls"`
+				FunctionCall json.RawMessage   `json:"function_call"`
+			} `json:"message"`
+		} `json:"choices"`
+		Content    []json.RawMessage `json:"content"`
+		StopReason string            `json:"stop_reason"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return body, nil
+	}
+	var err error
+	set := func(path string, value any) {
+		if err == nil {
+			body, err = sjson.SetBytes(body, path, value)
+		}
+	}
+	setRaw := func(path string, raw []json.RawMessage) {
+		if err != nil {
+			return
+		}
+		var encoded []byte
+		if encoded, err = json.Marshal(raw); err == nil {
+			body, err = sjson.SetRawBytes(body, path, encoded)
+		}
+	}
+	remove := func(path string) {
+		if err == nil {
+			body, err = sjson.DeleteBytes(body, path)
+		}
+	}
+	for i, choice := range payload.Choices {
+		prefix := fmt.Sprintf("choices.%d.", i)
+		if kept, calls, changed := p.filterCalls(choice.Message.ToolCalls, "function.name", ""); changed {
+			if calls == 0 {
+				remove(prefix + "message.tool_calls")
+				if choice.FinishReason == "tool_calls" {
+					set(prefix+"finish_reason", "stop")
+				}
+			} else {
+				setRaw(prefix+"message.tool_calls", kept)
+			}
+		}
+		if name, ok := jsonString(choice.Message.FunctionCall, "name"); ok {
+			if client, allowed := p.resolveCall(name); !allowed {
+				remove(prefix + "message.function_call")
+				if choice.FinishReason == "function_call" {
+					set(prefix+"finish_reason", "stop")
+				}
+			} else if client != name {
+				set(prefix+"message.function_call.name", client)
+			}
+		}
+	}
+	if kept, calls, changed := p.filterCalls(payload.Content, "name", "tool_use"); changed {
+		setRaw("content", kept)
+		if calls == 0 && payload.StopReason == "tool_use" {
+			set("stop_reason", "end_turn")
+		}
+	}
+	return body, err
+}
+
+// filterCalls 删除调用未授权工具的条目并还原重命名工具的名称，返回保留的条目、其中的工具调用数与是否有改动。
+// kind 非空时只处理 type 等于 kind 的条目，其余条目原样保留。
+func (p *toolPolicy) filterCalls(items []json.RawMessage, namePath, kind string) ([]json.RawMessage, int, bool) {
+	kept := make([]json.RawMessage, 0, len(items))
+	calls, changed := 0, false
+	for _, item := range items {
+		if kind != "" {
+			if itemType, _ := jsonString(item, "type"); itemType != kind {
+				kept = append(kept, item)
+				continue
+			}
+		}
+		name, ok := jsonString(item, namePath)
+		if !ok {
+			kept = append(kept, item)
+			calls++
+			continue
+		}
+		client, allowed := p.resolveCall(name)
+		if !allowed {
+			changed = true
+			continue
+		}
+		if client != name {
+			if renamed, err := sjson.SetBytes(item, namePath, client); err == nil {
+				item, changed = renamed, true
+			}
+		}
+		kept = append(kept, item)
+		calls++
+	}
+	return kept, calls, changed
+}
+
+// streamedCalls 记录流式响应中上游序号到客户端序号的映射：删除的工具调用映射为 -1，
+// 其后的条目序号前移，使客户端收到的序号保持连续。
+type streamedCalls struct {
+	index   map[int]int
+	next    int
+	calls   int
+	dropped bool
+}
+
+func (s *streamedCalls) assign(upstream int, keep bool) int {
+	if s.index == nil {
+		s.index = make(map[int]int)
+	}
+	if !keep {
+		s.index[upstream] = -1
+		s.dropped = true
+		return -1
+	}
+	s.index[upstream] = s.next
+	s.next++
+	return s.index[upstream]
+}
+
+// toolCallStream 逐个事件过滤 SSE 响应中的工具调用：OpenAI chat.completion.chunk 的 delta.tool_calls，
+// 以及 Anthropic 的 content_block_start / content_block_delta / content_block_stop 事件。
+type toolCallStream struct {
+	src    io.ReadCloser
+	reader *bufio.Reader
+	policy *toolPolicy
+	lines  [][]byte
+	out    bytes.Buffer
+	eof    bool
+
+	choices map[int]*streamedCalls
+	blocks  streamedCalls
+}
+
+func (s *toolCallStream) Read(p []byte) (int, error) {
+	for s.out.Len() == 0 {
+		if s.eof {
+			return 0, io.EOF
+		}
+		line, err := s.reader.ReadBytes('\n')
+		if len(line) > 0 {
+			s.lines = append(s.lines, line)
+			if len(bytes.TrimRight(line, "\r\n")) == 0 {
+				s.dispatch()
+			}
+		}
+		if err == io.EOF {
+			// 上游在事件中途结束时原样写出剩余数据。
+			for _, rest := range s.lines {
+				s.out.Write(rest)
+			}
+			s.lines = nil
+			s.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	return s.out.Read(p)
+}
+
+func (s *toolCallStream) Close() error {
+	return s.src.Close()
+}
+
+// dispatch 处理一个以空行结尾的事件：data 为 JSON 时交给 filterEvent，其余行（event、id、注释）原样保留。
+func (s *toolCallStream) dispatch() {
+	lines := s.lines
+	s.lines = nil
+	var data [][]byte
+	for _, line := range lines {
+		if value, ok := bytes.CutPrefix(bytes.TrimRight(line, "\r\n"), []byte("data:")); ok {
+			data = append(data, bytes.TrimPrefix(value, []byte(" ")))
+		}
+	}
+	payload := bytes.Join(data, []byte("\n"))
+	if len(data) == 0 || !json.Valid(payload) {
+		for _, line := range lines {
+			s.out.Write(line)
+		}
+		return
+	}
+	filtered, keep := s.filterEvent(payload)
+	if !keep {
+		return
+	}
+	if bytes.Equal(filtered, payload) {
+		for _, line := range lines {
+			s.out.Write(line)
+		}
+		return
+	}
+	for _, line := range lines[:len(lines)-1] {
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			s.out.Write(line)
+		}
+	}
+	s.out.WriteString("data: ")
+	s.out.Write(filtered)
+	s.out.WriteString("\n")
+	s.out.Write(lines[len(lines)-1])
+}
+
+// filterEvent 改写一个事件的 data，返回 false 表示丢弃整个事件。
+func (s *toolCallStream) filterEvent(data []byte) ([]byte, bool) {
+	var event struct {
+		Type         string        `json:"type"`
+		Index        *int          `json:"index"`
+		Choices      []chunkChoice `json:"choices"`
+		ContentBlock struct {
+			Type string `json:"type"`
+			Name string `json:"name"`
+		} `json:"content_block"`
+		Delta struct {
+			StopReason string `json:"stop_reason"`
+		} `json:"delta"`
+	}
+	if json.Unmarshal(data, &event) != nil {
+		return data, true
+	}
+	if event.Choices != nil {
+		return s.filterChunk(data, event.Choices), true
+	}
+	switch event.Type {
+	case "content_block_start":
+		if event.Index == nil {
+			return data, true
+		}
+		keep := true
+		if event.ContentBlock.Type == "tool_use" {
+			var client string
+			client, keep = s.policy.resolveCall(event.ContentBlock.Name)
+			if keep && client != event.ContentBlock.Name {
+				data, _ = sjson.SetBytes(data, "content_block.name", client)
+			}
+			if keep {
+				s.blocks.calls++
+			}
+		}
+		return s.reindex(data, s.blocks.assign(*event.Index, keep), *event.Index)
+	case "content_block_delta", "content_block_stop":
+		if event.Index == nil {
+			return data, true
+		}
+		if mapped, ok := s.blocks.index[*event.Index]; ok {
+			return s.reindex(data, mapped, *event.Index)
+		}
+	case "message_delta":
+		if event.Delta.StopReason == "tool_use" && s.blocks.dropped && s.blocks.calls == 0 {
+			data, _ = sjson.SetBytes(data, "delta.stop_reason", "end_turn")
+		}
+	}
+	return data, true
+}
+
+// reindex 把事件的 index 改为客户端序号，序号为 -1 时丢弃事件。
+func (s *toolCallStream) reindex(data []byte, mapped, upstream int) ([]byte, bool) {
+	if mapped < 0 {
+		return nil, false
+	}
+	if mapped != upstream {
+		data, _ = sjson.SetBytes(data, "index", mapped)
+	}
+	return data, true
+}
+
+// chunkChoice 是 OpenAI 流式分片中与工具调用相关的字段。
+type chunkChoice struct {
+	Index        int     `json:"index"`
+	FinishReason *string `json:"finish_reason"`
+	Delta        struct {
+		ToolCalls []json.RawMessage `json:"tool_calls"`
+	} `json:"delta"`
+}
+
+// filterChunk 过滤 OpenAI 流式分片中的工具调用。工具名只出现在每个调用的首个分片中，
+// 后续分片按序号沿用首个分片的判断。
+func (s *toolCallStream) filterChunk(data []byte, choices []chunkChoice) []byte {
+	if s.choices == nil {
+		s.choices = make(map[int]*streamedCalls)
+	}
+	for i, choice := range choices {
+		state, ok := s.choices[choice.Index]
+		if !ok {
+			state = &streamedCalls{}
+			s.choices[choice.Index] = state
+		}
+		prefix := fmt.Sprintf("choices.%d.", i)
+		if choice.Delta.ToolCalls != nil {
+			kept := make([]json.RawMessage, 0, len(choice.Delta.ToolCalls))
+			changed := false
+			for _, call := range choice.Delta.ToolCalls {
+				var head struct {
+					Index    int `json:"index"`
+					Function struct {
+						Name *string `json:"name"`
+					} `json:"function"`
+				}
+				_ = json.Unmarshal(call, &head)
+				mapped, seen := state.index[head.Index]
+				if !seen {
+					keep := true
+					if head.Function.Name != nil {
+						var client string
+						client, keep = s.policy.resolveCall(*head.Function.Name)
+						if keep && client != *head.Function.Name {
+							call, _ = sjson.SetBytes(call, "function.name", client)
+							changed = true
+						}
+					}
+					if keep {
+						state.calls++
+					}
+					mapped = state.assign(head.Index, keep)
+				}
+				if mapped < 0 {
+					changed = true
+					continue
+				}
+				if mapped != head.Index {
+					call, _ = sjson.SetBytes(call, "index", mapped)
+					changed = true
+				}
+				kept = append(kept, call)
+			}
+			if changed {
+				if len(kept) == 0 {
+					data, _ = sjson.DeleteBytes(data, prefix+"delta.tool_calls")
+				} else if raw, err := json.Marshal(kept); err == nil {
+					data, _ = sjson.SetRawBytes(data, prefix+"delta.tool_calls", raw)
+				}
+			}
+		}
+		if choice.FinishReason != nil && *choice.FinishReason == "tool_calls" && state.dropped && state.calls == 0 {
+			data, _ = sjson.SetBytes(data, prefix+"finish_reason", "stop")
+		}
+	}
+	return data
+}
+
+// jsonString 读取 JSON 对象中以点分隔的路径上的字符串值。
+func jsonString(raw json.RawMessage, path string) (string, bool) {
+	if len(raw) == 0 {
+		return "", false
+	}
+	var value any
+	if json.Unmarshal(raw, &value) != nil {
+		return "", false
+	}
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		value = object[key]
+	}
+	text, ok := value.(string)
+	return text, ok
+}
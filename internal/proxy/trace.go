@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/features"
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/reqtrace"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// traceKey 保存当前请求的决策轨迹。
+const traceKey = "proxy_request_trace"
+
+// traceSaveTimeout 限制轨迹写入耗时，避免存储故障拖慢请求收尾。
+const traceSaveTimeout = time.Second
+
+// WithTraceStore 设置请求轨迹存储，未设置时不记录轨迹。
+func WithTraceStore(store reqtrace.Store) Option {
+	return func(h *Handler) {
+		h.traces = store
+	}
+}
+
+// WithTraceToggle 设置控制轨迹记录的运行时开关，关闭时即使配置了存储也不记录轨迹。
+func WithTraceToggle(toggle *features.Toggle) Option {
+	return func(h *Handler) {
+		h.traceToggle = toggle
+	}
+}
+
+// startTrace 为当前请求创建决策轨迹，未启用轨迹或缺少请求 ID 时返回 nil。
+func (h *Handler) startTrace(c *gin.Context) *reqtrace.Trace {
+	if h.traces == nil || !h.traceToggle.Enabled() {
+		return nil
+	}
+	requestID := middleware.RequestIDFromContext(c)
+	if requestID == "" {
+		return nil
+	}
+	trace := &reqtrace.Trace{
+		RequestID:      requestID,
+		Method:         c.Request.Method,
+		Path:           c.Request.URL.Path,
+		Query:          reqtrace.RedactQuery(c.Request.URL.RawQuery),
+		RequestHeaders: reqtrace.RedactHeaders(c.Request.Header),
+		HasBody:        c.Request.Body != nil && c.Request.Body != http.NoBody,
+		StartedAt:      time.Now().UTC(),
+	}
+	if user, ok := middleware.CurrentUser(c); ok {
+		trace.UserID = user.ID
+	}
+	if apiKey, ok := middleware.CurrentAPIKey(c); ok {
+		trace.APIKeyID = apiKey.ID
+	}
+	c.Set(traceKey, trace)
+	return trace
+}
+
+// currentTrace 返回当前请求的决策轨迹，未启用时返回 nil；reqtrace.Trace 的记录方法可安全处理 nil。
+func currentTrace(c *gin.Context) *reqtrace.Trace {
+	value, ok := c.Get(traceKey)
+	if !ok {
+		return nil
+	}
+	trace, _ := value.(*reqtrace.Trace)
+	return trace
+}
+
+// traceRule 记录命中的规则。
+func traceRule(c *gin.Context, rule rules.Rule) {
+	trace := currentTrace(c)
+	if trace == nil {
+		return
+	}
+	trace.Rule = &reqtrace.RuleMatch{
+		ID:       rule.ID,
+		Priority: rule.Priority,
+		Version:  rule.Version,
+		Default:  rule.ID == "default" && rule.Priority < 0,
+	}
+}
+
+// traceError 记录导致请求失败的错误。
+func traceError(c *gin.Context, err error) {
+	if trace := currentTrace(c); trace != nil && err != nil {
+		trace.Error = err.Error()
+	}
+}
+
+// finishTrace 补全响应状态与总耗时并保存轨迹，保存失败只记录日志。
+func (h *Handler) finishTrace(c *gin.Context, trace *reqtrace.Trace) {
+	if trace == nil {
+		return
+	}
+	trace.Status = c.Writer.Status()
+	trace.DurationMs = time.Since(trace.StartedAt).Milliseconds()
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), traceSaveTimeout)
+	defer cancel()
+	if err := h.traces.Save(ctx, *trace); err != nil && h.logger != nil {
+		h.logger.Warn("save request trace failed",
+			"error", err,
+			"request_id", trace.RequestID,
+		)
+	}
+}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/pii"
+	"github.com/prehisle/yapi/internal/traceexport"
+	"github.com/prehisle/yapi/internal/usage"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// defaultTraceExportMaxBytes 是未指定上限时导出的请求体或响应体最多保存的字节数。
+const defaultTraceExportMaxBytes = 64 << 10
+
+// WithTraceExport 启用提示词与补全导出：规则配置了 trace_export 或用户元数据 trace_export 指定平台时，
+// 上游调用结束后把脱敏的请求体与响应体交给 s 异步导出，maxBytes 为请求体与响应体各自的保存上限。
+func WithTraceExport(s *traceexport.Service, maxBytes int) Option {
+	return func(h *Handler) {
+		if maxBytes <= 0 {
+			maxBytes = defaultTraceExportMaxBytes
+		}
+		h.traceExport = s
+		h.traceExportMaxBytes = maxBytes
+	}
+}
+
+// exportEntry 收集一次上游尝试的请求体与客户端收到的响应体，响应体读完或关闭后脱敏并放入导出队列。
+type exportEntry struct {
+	h        *Handler
+	provider string
+	filter   *pii.Filter
+	record   traceexport.Record
+	request  *bodyCapture
+}
+
+// traceExportSettings 返回本次请求导出的平台与脱敏配置：规则的 trace_export 优先，其次是用户元数据。
+func traceExportSettings(rule rules.Rule, metadata map[string]any) (string, rules.TraceExportAction) {
+	if export := rule.Actions.TraceExport; export != nil {
+		return export.Provider, *export
+	}
+	provider, _ := metadata[traceexport.UserMetadataKey].(string)
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	return provider, rules.TraceExportAction{Provider: provider}
+}
+
+// startTraceExport 与归档一样在协议转换之前包装请求体，导出的是客户端协议的请求。返回 nil 表示本次不导出。
+func (h *Handler) startTraceExport(c *gin.Context, req *http.Request, rule rules.Rule) *exportEntry {
+	if h.traceExport == nil || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	var metadata map[string]any
+	record := traceexport.Record{
+		ID:        uuid.NewString(),
+		RequestID: middleware.RequestIDFromContext(c),
+		RuleID:    rule.ID,
+		Method:    req.Method,
+		Path:      c.Request.URL.Path,
+		Start:     time.Now(),
+	}
+	if user, ok := middleware.CurrentUser(c); ok {
+		record.UserID = user.ID
+		metadata = map[string]any(user.Metadata)
+	}
+	provider, action := traceExportSettings(rule, metadata)
+	if !h.traceExport.Enabled(provider) {
+		return nil
+	}
+	filter, err := pii.New(action.Redaction())
+	if err != nil {
+		if h.logger != nil {
+			h.logger.Warn("trace export redaction invalid", "request_id", middleware.RequestIDFromContext(c), "error", err, "rule_id", rule.ID)
+		}
+		return nil
+	}
+	if apiKey, ok := middleware.CurrentAPIKey(c); ok {
+		record.APIKeyID = apiKey.ID
+	}
+	entry := &exportEntry{
+		h:        h,
+		provider: provider,
+		filter:   filter,
+		record:   record,
+		request:  &bodyCapture{ReadCloser: req.Body, limit: h.traceExportMaxBytes},
+	}
+	req.Body = entry.request
+	return entry
+}
+
+// finish 包装客户端收到的响应体，并从完整的响应体中提取用量；压缩过的响应体只导出请求。
+func (e *exportEntry) finish(resp *http.Response) {
+	if e == nil {
+		return
+	}
+	e.record.Status = resp.StatusCode
+	encoding := resp.Header.Get("Content-Encoding")
+	if resp.Body == nil || resp.Body == http.NoBody || (encoding != "" && !strings.EqualFold(encoding, "identity")) {
+		e.send(nil, nil)
+		return
+	}
+	extractor := usage.NewExtractor(resp.Header.Get("Content-Type"))
+	captured := &bodyCapture{
+		ReadCloser: struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(resp.Body, extractor), resp.Body},
+		limit: e.h.traceExportMaxBytes,
+	}
+	captured.onDone = func() { e.send(captured, extractor) }
+	resp.Body = captured
+}
+
+func (e *exportEntry) send(response *bodyCapture, extractor *usage.Extractor) {
+	record := e.record
+	record.End = time.Now()
+	request, truncated := e.request.snapshot()
+	record.Model = requestModel(request)
+	record.Input = e.redact(request)
+	if response != nil {
+		body, responseTruncated := response.snapshot()
+		record.Output = e.redact(body)
+		truncated = truncated || responseTruncated
+	}
+	record.Truncated = truncated
+	if extractor != nil {
+		if u, ok := extractor.Result(); ok {
+			if u.Model != "" {
+				record.Model = u.Model
+			}
+			record.PromptTokens, record.CompletionTokens = u.PromptTokens, u.CompletionTokens
+			record.CostUSD = e.h.pricing.Cost(u)
+		}
+	}
+	// 队列已满时丢弃记录，由 gateway_trace_exports_total{outcome="dropped"} 反映。
+	e.h.traceExport.Enqueue(e.provider, record)
+}
+
+// redact 按 JSON 打码请求体或响应体，截断或非 JSON 的内容（如 SSE 流）按纯文本打码。
+func (e *exportEntry) redact(body []byte) string {
+	redacted, _, err := e.filter.JSON(body)
+	if err != nil {
+		return e.filter.Text(string(body), pii.Hits{})
+	}
+	return string(redacted)
+}
@@ -0,0 +1,771 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/datatypes"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/prehisle/yapi/internal/analytics"
+	"github.com/prehisle/yapi/internal/bodylog"
+	"github.com/prehisle/yapi/internal/features"
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/reqtrace"
+	"github.com/prehisle/yapi/internal/telemetry"
+	"github.com/prehisle/yapi/internal/upstreams"
+	"github.com/prehisle/yapi/internal/usage"
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/metrics"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+func TestHandler_RecordsRequestTrace(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backup.Close()
+
+	primaryBinding := accounts.UserAPIKeyBinding{ID: "b-1", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-1", Service: "openai"}
+	primaryCred := accounts.UpstreamCredential{ID: "cred-1", UserID: "user-1", Service: "openai", APIKey: "sk-primary", Enabled: true,
+		Endpoints: datatypes.JSON([]byte(`["` + primary.URL + `"]`))}
+	backupBinding := accounts.UserAPIKeyBinding{ID: "b-2", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-2", Service: "openai", Position: 1}
+	backupCred := accounts.UpstreamCredential{ID: "cred-2", UserID: "user-1", Service: "openai", APIKey: "sk-backup", Enabled: true,
+		Endpoints: datatypes.JSON([]byte(`["` + backup.URL + `"]`))}
+	accountSvc := &accountsStub{bindings: []accounts.BindingWithUpstream{
+		{Binding: primaryBinding, Upstream: primaryCred},
+		{Binding: backupBinding, Upstream: backupCred},
+	}}
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{
+		ID:       "traced",
+		Priority: 10,
+		Version:  3,
+		Enabled:  true,
+		Matcher:  rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{
+			SetHeaders:       map[string]string{"X-Team": "search"},
+			RewritePathRegex: &rules.RewritePathExpression{Pattern: "^/v1/chat", Replace: "/v2/chat"},
+			OverrideJSON:     map[string]any{"model": "gpt-4.1", "metadata.api_key": "sk-leak"},
+			RemoveJSON:       []string{"user"},
+		},
+	}}}
+	store := reqtrace.NewMemoryStore(10)
+	h := NewHandler(svc, WithAccountsService(accountSvc), WithTraceStore(store))
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(middleware.RequestID(), func(c *gin.Context) {
+		middleware.SetBinding(c, primaryBinding, primaryCred)
+		c.Next()
+	})
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions?api-version=1&api_key=sk-1", strings.NewReader(`{"model":"gpt","user":"u"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", "req-trace-1")
+	req.Header.Set("X-Api-Key", "yapi_secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	trace, err := store.Get(context.Background(), "req-trace-1")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, trace.Status)
+	require.Equal(t, &reqtrace.RuleMatch{ID: "traced", Priority: 10, Version: 3}, trace.Rule)
+	require.Equal(t, "api-version=1&api_key=%5BREDACTED%5D", trace.Query)
+	require.Equal(t, "application/json", trace.RequestHeaders.Get("Content-Type"))
+	require.Equal(t, reqtrace.Redacted, trace.RequestHeaders.Get("X-Api-Key"))
+	require.True(t, trace.HasBody)
+	require.ElementsMatch(t, []string{"set_headers", "rewrite_path_regex", "override_json", "remove_json", "upstream_credential"}, trace.Actions)
+	require.Contains(t, trace.Headers, reqtrace.HeaderMutation{Op: "set", Name: "X-Team", Value: "search"})
+	require.Contains(t, trace.Headers, reqtrace.HeaderMutation{Op: "set", Name: "Authorization", Value: reqtrace.Redacted})
+	require.Contains(t, trace.Headers, reqtrace.HeaderMutation{Op: "set", Name: "X-Upstream-Credential-ID", Value: "cred-2"})
+	require.ElementsMatch(t, []reqtrace.BodyMutation{
+		{Op: "override", Path: "model", Value: "gpt-4.1"},
+		{Op: "override", Path: "metadata.api_key", Value: reqtrace.Redacted},
+		{Op: "remove", Path: "user"},
+	}, trace.Body)
+
+	require.Len(t, trace.Attempts, 2)
+	require.Equal(t, primary.URL, trace.Attempts[0].Target)
+	require.Equal(t, "/v2/chat/completions", trace.Attempts[0].Path)
+	require.Equal(t, "cred-1", trace.Attempts[0].CredentialID)
+	require.Equal(t, http.StatusServiceUnavailable, trace.Attempts[0].Status)
+	require.False(t, trace.Attempts[0].Failover)
+	require.Equal(t, backup.URL, trace.Attempts[1].Target)
+	require.Equal(t, "b-2", trace.Attempts[1].BindingID)
+	require.Equal(t, 1, trace.Attempts[1].Position)
+	require.Equal(t, http.StatusOK, trace.Attempts[1].Status)
+	require.True(t, trace.Attempts[1].Failover)
+}
+
+func TestHandler_TraceToggleDisablesRecording(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "r", Priority: 1, Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"}, Actions: rules.Actions{SetTargetURL: upstream.URL}}}}
+	store := reqtrace.NewMemoryStore(10)
+	registry := features.NewRegistry()
+	toggle := registry.Register(features.RequestTraces, "", true)
+	h := NewHandler(svc, WithTraceStore(store), WithTraceToggle(toggle))
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	send := func(requestID string) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/models", nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Request-ID", requestID)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	send("traced")
+	_, err := store.Get(context.Background(), "traced")
+	require.NoError(t, err)
+
+	_, err = registry.Set(features.RequestTraces, false)
+	require.NoError(t, err)
+	send("untraced")
+	_, err = store.Get(context.Background(), "untraced")
+	require.ErrorIs(t, err, reqtrace.ErrNotFound)
+}
+
+func TestHandler_EmitsOpenTelemetrySpans(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	_, err := telemetry.Setup(context.Background(), telemetry.Config{})
+	require.NoError(t, err)
+
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "otel", Priority: 5, Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: upstream.URL, OverrideJSON: map[string]any{"model": "gpt-4.1"}}}}}
+	h := NewHandler(svc)
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(middleware.RequestID(), telemetry.Middleware())
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions", strings.NewReader(`{"model":"gpt"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	require.Contains(t, spans, "POST")
+	require.Contains(t, spans, "proxy.match_rule")
+	require.Contains(t, spans, "proxy.rewrite_body")
+	require.Contains(t, spans, "proxy.upstream")
+	serverSpan, upstreamSpan := spans["POST"], spans["proxy.upstream"]
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", serverSpan.SpanContext().TraceID().String())
+	require.Equal(t, serverSpan.SpanContext().SpanID(), spans["proxy.match_rule"].Parent().SpanID())
+	require.Equal(t, serverSpan.SpanContext().SpanID(), upstreamSpan.Parent().SpanID())
+	require.Equal(t, upstreamSpan.SpanContext().SpanID(), spans["proxy.rewrite_body"].Parent().SpanID())
+	require.Contains(t, spans["proxy.match_rule"].Attributes(), attribute.String("yapi.rule_id", "otel"))
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+upstreamSpan.SpanContext().SpanID().String()+"-01", traceparent)
+}
+
+func TestHandler_EmitsGenAISemanticConventions(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-genai","model":"gpt-genai-2025","choices":[{"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":30}}`))
+	}))
+	defer upstream.Close()
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "genai", Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}, Actions: rules.Actions{SetTargetURL: upstream.URL}}}}
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(svc))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"gpt-genai","max_tokens":64,"temperature":0.2,"messages":[]}`))
+	require.NoError(t, err)
+	_, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var upstreamSpan sdktrace.ReadOnlySpan
+	require.Eventually(t, func() bool {
+		for _, span := range recorder.Ended() {
+			if span.Name() == "proxy.upstream" {
+				upstreamSpan = span
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+	attrs := upstreamSpan.Attributes()
+	require.Contains(t, attrs, attribute.String("gen_ai.operation.name", "chat"))
+	require.Contains(t, attrs, attribute.String("gen_ai.system", "openai"))
+	require.Contains(t, attrs, attribute.String("gen_ai.request.model", "gpt-genai"))
+	require.Contains(t, attrs, attribute.Int("gen_ai.request.max_tokens", 64))
+	require.Contains(t, attrs, attribute.Float64("gen_ai.request.temperature", 0.2))
+	require.Contains(t, attrs, attribute.String("gen_ai.response.model", "gpt-genai-2025"))
+	require.Contains(t, attrs, attribute.String("gen_ai.response.id", "chatcmpl-genai"))
+	require.Contains(t, attrs, attribute.StringSlice("gen_ai.response.finish_reasons", []string{"stop"}))
+	require.Contains(t, attrs, attribute.Int("gen_ai.usage.input_tokens", 12))
+	require.Contains(t, attrs, attribute.Int("gen_ai.usage.output_tokens", 30))
+}
+
+func TestGenAIOperationAndModel(t *testing.T) {
+	for path, want := range map[string][3]string{
+		"/v1/chat/completions": {"chat", "openai", ""},
+		"/v1/messages":         {"chat", "anthropic", ""},
+		"/v1/embeddings":       {"embeddings", "openai", ""},
+		"/v1beta/models/gemini-2.5-flash:streamGenerateContent": {"generate_content", "gcp.gemini", "gemini-2.5-flash"},
+		"/openai/deployments/prod-gpt4o/chat/completions":       {"chat", "openai", "prod-gpt4o"},
+		"/v1/models": {"", "openai", "gpt"},
+	} {
+		require.Equal(t, want[0], genAIOperation(path), path)
+		require.Equal(t, want[1], genAISystem("", path), path)
+		if want[0] != "" {
+			require.Equal(t, want[2], pathModel(path), path)
+		}
+	}
+	require.Equal(t, "az.ai.openai", genAISystem("azure", "/v1/chat/completions"))
+	require.Equal(t, "ollama", genAISystem("ollama", "/v1/chat/completions"))
+}
+
+func TestHandler_RecordsTokenUsageMetrics(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"model":"gpt-usage-test-2025","usage":{"prompt_tokens":1000,"completion_tokens":500}}`))
+	}))
+	defer upstream.Close()
+
+	binding := accounts.UserAPIKeyBinding{ID: "b-usage", UserID: "user-usage", UserAPIKeyID: "key-usage", UpstreamKeyID: "cred-usage", Service: "openai"}
+	cred := accounts.UpstreamCredential{ID: "cred-usage", UserID: "user-usage", Service: "openai", APIKey: "sk-usage", Enabled: true,
+		Endpoints: datatypes.JSON([]byte(`["` + upstream.URL + `"]`))}
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "usage", Priority: 1, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}}}}
+	h := NewHandler(svc, WithAccountsService(&accountsStub{}), WithModelPricing(usage.Pricing{"gpt-usage-test": {Prompt: 2, Completion: 8}}))
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetBinding(c, binding, cred)
+		c.Next()
+	})
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	model := "gpt-usage-test-2025"
+	prompt := metrics.TokensTotal.WithLabelValues(model, "openai", "anonymous", "prompt")
+	completion := metrics.TokensTotal.WithLabelValues(model, "openai", "anonymous", "completion")
+	cost := metrics.CostUSDTotal.WithLabelValues(model, "openai", "anonymous")
+	promptBefore, completionBefore, costBefore := testutil.ToFloat64(prompt), testutil.ToFloat64(completion), testutil.ToFloat64(cost)
+
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// 用量在代理读完上游响应体时上报，可能晚于客户端读完响应。
+	require.Eventually(t, func() bool { return testutil.ToFloat64(prompt) > promptBefore }, time.Second, 10*time.Millisecond)
+	require.Equal(t, 1000.0, testutil.ToFloat64(prompt)-promptBefore)
+	require.Equal(t, 500.0, testutil.ToFloat64(completion)-completionBefore)
+	require.InDelta(t, 0.006, testutil.ToFloat64(cost)-costBefore, 1e-12)
+}
+
+func TestHandler_LogsRedactedBodies(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"the answer"}}],"usage":{"prompt_tokens":4}}`))
+	}))
+	defer upstream.Close()
+
+	logs := &lockedBuffer{}
+	logger := slog.New(slog.NewJSONHandler(logs, nil))
+	policy, err := bodylog.ParsePolicy([]string{"messages[*].content"})
+	require.NoError(t, err)
+	registry := features.NewRegistry()
+	toggle := registry.Register(features.BodyLogging, "", true)
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "body", Priority: 1, Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: upstream.URL, SetAuthorization: "Bearer sk-upstream"}}}}
+	h := NewHandler(svc, WithLogger(logger), WithBodyLogging(BodyLogOptions{Policy: policy, Toggle: toggle}))
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	send := func() {
+		resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"gpt","messages":[{"role":"user","content":"my password"}]}`))
+		require.NoError(t, err)
+		_, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	bodyLogs := func() []map[string]any {
+		var entries []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+			var entry map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			if entry["msg"] == "proxy body" {
+				entries = append(entries, entry)
+			}
+		}
+		return entries
+	}
+
+	send()
+	// 日志在代理读完上游响应体时写出，可能晚于客户端读完响应。
+	require.Eventually(t, func() bool { return len(bodyLogs()) == 1 }, time.Second, 10*time.Millisecond)
+	entries := bodyLogs()
+	entry := entries[0]
+	require.Equal(t, "body", entry["rule_id"])
+	require.JSONEq(t, `{"model":"gpt","messages":[{"role":"user","content":"[REDACTED]"}]}`, entry["request_body"].(string))
+	require.NotContains(t, entry["request_headers"], "Authorization")
+	require.Equal(t, float64(http.StatusOK), entry["response_status"])
+	require.JSONEq(t, `{"choices":[{"message":{"content":"the answer"}}],"usage":{"prompt_tokens":4}}`, entry["response_body"].(string))
+	require.NotContains(t, logs.String(), "sk-upstream")
+
+	_, err = registry.Set(features.BodyLogging, false)
+	require.NoError(t, err)
+	send()
+	server.Close()
+	require.Len(t, bodyLogs(), 1)
+}
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestHandler_PrefersHealthyEndpoint(t *testing.T) {
+	var badHits, goodHits atomic.Int32
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		badHits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer bad.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		goodHits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer good.Close()
+
+	binding := accounts.UserAPIKeyBinding{ID: "b-ewma", UserID: "user-ewma", UserAPIKeyID: "key-ewma", UpstreamKeyID: "cred-ewma", Service: "openai"}
+	cred := accounts.UpstreamCredential{ID: "cred-ewma", UserID: "user-ewma", Service: "openai", APIKey: "sk-ewma", Enabled: true,
+		Endpoints: datatypes.JSON([]byte(`["::invalid", "` + bad.URL + `", "` + good.URL + `"]`))}
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "ewma", Priority: 1, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}}}}
+	scorer := upstreams.NewEndpointScorer()
+	h := NewHandler(svc, WithAccountsService(&accountsStub{}), WithEndpointScorer(scorer))
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		middleware.SetBinding(c, binding, cred)
+		c.Next()
+	})
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	for i := 0; i < 40; i++ {
+		resp, err := http.Get(server.URL + "/v1/models")
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	require.Equal(t, int32(40), badHits.Load()+goodHits.Load())
+	// 失败端点首次出错后仅保留最低选择权重，绝大多数请求应落到健康端点。
+	require.Less(t, badHits.Load(), int32(15))
+	if badHits.Load() > 0 {
+		require.Less(t, scorer.Score(bad.URL), scorer.Score(good.URL))
+	}
+}
+
+func TestHandler_RecordsSLOOutcomes(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("mode") {
+		case "error":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "slow":
+			time.Sleep(100 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer upstream.Close()
+	target, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "slo-test", Priority: 1, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}}}}
+	h := NewHandler(svc, WithDefaultTarget(target), WithSLOLatencyThreshold(50*time.Millisecond))
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	counter := func(slo, outcome string) prometheus.Counter {
+		return metrics.SLORequestsTotal.WithLabelValues("slo-test", slo, outcome)
+	}
+	before := map[string]float64{}
+	for _, key := range []string{"availability/good", "availability/bad", "latency/good", "latency/bad"} {
+		slo, outcome, _ := strings.Cut(key, "/")
+		before[key] = testutil.ToFloat64(counter(slo, outcome))
+	}
+
+	for _, mode := range []string{"ok", "ok", "slow", "error"} {
+		resp, err := http.Get(server.URL + "/v1/chat?mode=" + mode)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	delta := func(key string) float64 {
+		slo, outcome, _ := strings.Cut(key, "/")
+		return testutil.ToFloat64(counter(slo, outcome)) - before[key]
+	}
+	// SLO 在处理器返回时上报，可能晚于客户端读完响应。
+	require.Eventually(t, func() bool { return delta("availability/good")+delta("availability/bad") == 4 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, 3.0, delta("availability/good"))
+	require.Equal(t, 1.0, delta("availability/bad"))
+	// 不可用的请求不计入延迟 SLO。
+	require.Equal(t, 2.0, delta("latency/good"))
+	require.Equal(t, 1.0, delta("latency/bad"))
+}
+
+type analyticsWriterStub struct {
+	mu     sync.Mutex
+	events []analytics.Event
+}
+
+func (w *analyticsWriterStub) Write(_ context.Context, events []analytics.Event) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.events = append(w.events, events...)
+	return nil
+}
+
+func TestHandler_EmitsAnalyticsEvents(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"model":"gpt-analytics","usage":{"prompt_tokens":100,"completion_tokens":20}}`))
+	}))
+	defer upstream.Close()
+
+	binding := accounts.UserAPIKeyBinding{ID: "b-analytics", UserID: "user-analytics", UserAPIKeyID: "key-analytics", UpstreamKeyID: "cred-analytics", Service: "openai"}
+	cred := accounts.UpstreamCredential{ID: "cred-analytics", UserID: "user-analytics", Service: "openai", APIKey: "sk-analytics", Enabled: true,
+		Endpoints: datatypes.JSON([]byte(`["` + upstream.URL + `"]`))}
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "analytics", Priority: 1, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}}}}
+	writer := &analyticsWriterStub{}
+	sink := analytics.NewSink(writer, analytics.Options{BatchSize: 1})
+	h := NewHandler(svc, WithAccountsService(&accountsStub{}), WithAnalyticsSink(sink),
+		WithModelPricing(usage.Pricing{"gpt-analytics": {Prompt: 10, Completion: 50}}))
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(middleware.RequestID(), func(c *gin.Context) {
+		middleware.SetBinding(c, binding, cred)
+		c.Next()
+	})
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/chat/completions", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	req.Header.Set("X-Request-ID", "req-analytics")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	server.Close()
+	require.NoError(t, sink.Close(context.Background()))
+
+	require.Len(t, writer.events, 1)
+	event := writer.events[0]
+	require.Equal(t, "req-analytics", event.RequestID)
+	require.Equal(t, "analytics", event.RuleID)
+	require.Equal(t, http.MethodPost, event.Method)
+	require.Equal(t, "/v1/chat/completions", event.Path)
+	require.Equal(t, http.StatusOK, event.Status)
+	require.Equal(t, strings.TrimPrefix(upstream.URL, "http://"), event.Upstream)
+	require.Equal(t, "openai", event.Provider)
+	require.Equal(t, "cred-analytics", event.CredentialID)
+	require.Equal(t, "gpt-analytics", event.Model)
+	require.Equal(t, int64(100), event.PromptTokens)
+	require.Equal(t, int64(20), event.CompletionTokens)
+	require.InDelta(t, 0.002, event.CostUSD, 1e-12)
+}
+
+func TestHandler_ReturnsErrorCodesForUpstreamFailures(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("mode") == "slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+	target, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "errcode-test", Priority: 1, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}}}}
+	h := NewHandler(svc, WithDefaultTarget(target), WithTransport(&http.Transport{ResponseHeaderTimeout: 50 * time.Millisecond}))
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	RegisterRoutes(router, h)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	timeouts := metrics.ErrorsTotal.WithLabelValues("YAPI_UPSTREAM_TIMEOUT")
+	before := testutil.ToFloat64(timeouts)
+
+	resp, err := http.Get(server.URL + "/v1/chat?mode=slow")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+	require.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+	var body struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, "YAPI_UPSTREAM_TIMEOUT", body.Code)
+	require.NotEmpty(t, body.Error)
+	require.Equal(t, 1.0, testutil.ToFloat64(timeouts)-before)
+}
+
+func TestHandler_WarmsUpstreamConnections(t *testing.T) {
+	var heads, conns atomic.Int32
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	upstream.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	upstream.Start()
+	defer upstream.Close()
+
+	target, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+	success := metrics.UpstreamWarmupsTotal.WithLabelValues(target.Host, "success")
+	before := testutil.ToFloat64(success)
+
+	h := NewHandler(nil, WithWarmConnections(WarmOptions{Targets: []*url.URL{target}, Conns: 2, Interval: time.Hour}))
+	require.GreaterOrEqual(t, h.warmTransport.(*http.Transport).MaxIdleConnsPerHost, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h.StartWarming(ctx)
+	require.Eventually(t, func() bool { return heads.Load() == 2 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, 2.0, testutil.ToFloat64(success)-before)
+
+	// 后续轮次复用空闲连接池中的连接，不再新建连接。
+	opened := conns.Load()
+	h.warmConnections(ctx)
+	require.Equal(t, int32(4), heads.Load())
+	require.Equal(t, opened, conns.Load())
+}
+
+type replayAccountsStub struct {
+	accountsStub
+	apiKey   accounts.APIKey
+	user     accounts.User
+	binding  accounts.UserAPIKeyBinding
+	upstream accounts.UpstreamCredential
+}
+
+func (s *replayAccountsStub) GetUserAPIKey(ctx context.Context, apiKeyID string) (accounts.APIKey, error) {
+	if apiKeyID != s.apiKey.ID {
+		return accounts.APIKey{}, accounts.ErrNotFound
+	}
+	return s.apiKey, nil
+}
+
+func (s *replayAccountsStub) ResolveBindingByAPIKeyID(ctx context.Context, apiKeyID string) (accounts.UserAPIKeyBinding, accounts.UpstreamCredential, error) {
+	return s.binding, s.upstream, nil
+}
+
+func (s *replayAccountsStub) GetUser(ctx context.Context, id string) (accounts.User, error) {
+	return s.user, nil
+}
+
+func TestHandler_ReplaysRequestWithStoredIdentity(t *testing.T) {
+	var gotAuth, gotBody string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer upstream.Close()
+
+	accountSvc := &replayAccountsStub{
+		apiKey:  accounts.APIKey{ID: "key-1", UserID: "user-1", Enabled: true},
+		user:    accounts.User{ID: "user-1"},
+		binding: accounts.UserAPIKeyBinding{ID: "b-1", UserID: "user-1", UserAPIKeyID: "key-1", UpstreamKeyID: "cred-1", Service: "openai"},
+		upstream: accounts.UpstreamCredential{ID: "cred-1", UserID: "user-1", Service: "openai", APIKey: "sk-replay", Enabled: true,
+			Endpoints: datatypes.JSON([]byte(`["` + upstream.URL + `"]`))},
+	}
+	svc := &ruleServiceStub{rules: []rules.Rule{{
+		ID: "replayed", Priority: 5, Version: 2, Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetHeaders: map[string]string{"X-Team": "search"}},
+	}}}
+	store := reqtrace.NewMemoryStore(10)
+	h := NewHandler(svc, WithAccountsService(accountSvc), WithTraceStore(store))
+
+	result, err := h.Replay(context.Background(), ReplayRequest{
+		Method:   http.MethodPost,
+		Path:     "/v1/chat/completions",
+		Body:     []byte(`{"model":"gpt-4o"}`),
+		APIKeyID: "key-1",
+		ReplayOf: "req-original",
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, result.Status)
+	require.JSONEq(t, `{"ok":true}`, string(result.Body))
+	require.False(t, result.Truncated)
+	require.Equal(t, "Bearer sk-replay", gotAuth)
+	require.JSONEq(t, `{"model":"gpt-4o"}`, gotBody)
+	require.Equal(t, result.RequestID, result.Header.Get("X-Request-ID"))
+
+	require.NotNil(t, result.Trace)
+	require.Equal(t, &reqtrace.RuleMatch{ID: "replayed", Priority: 5, Version: 2}, result.Trace.Rule)
+	require.Equal(t, "user-1", result.Trace.UserID)
+	require.Equal(t, "key-1", result.Trace.APIKeyID)
+	saved, err := store.Get(context.Background(), result.RequestID)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, saved.Status)
+
+	// API Key 停用后重放得到与网关一致的拒绝响应。
+	accountSvc.apiKey.Enabled = false
+	result, err = h.Replay(context.Background(), ReplayRequest{Method: http.MethodPost, Path: "/v1/chat/completions", APIKeyID: "key-1"})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, result.Status)
+	require.Contains(t, string(result.Body), "YAPI_API_KEY_DISABLED")
+	require.Nil(t, result.Trace)
+}
+
+func TestHandler_ReplaySkipsDisabledBindingLikeLiveTraffic(t *testing.T) {
+	var gotAuth string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open("file:replay_bindings?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	accountSvc := accounts.NewService(db)
+	require.NoError(t, accountSvc.AutoMigrate(ctx))
+	user, err := accountSvc.CreateUser(ctx, accounts.CreateUserParams{Name: "replay"})
+	require.NoError(t, err)
+	key, _, err := accountSvc.CreateUserAPIKey(ctx, accounts.CreateAPIKeyParams{UserID: user.ID})
+	require.NoError(t, err)
+	primary, err := accountSvc.CreateUpstreamCredential(ctx, accounts.CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", Plaintext: "sk-primary", Endpoints: []string{upstream.URL}})
+	require.NoError(t, err)
+	backup, err := accountSvc.CreateUpstreamCredential(ctx, accounts.CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", Plaintext: "sk-backup", Endpoints: []string{upstream.URL}})
+	require.NoError(t, err)
+	_, err = accountSvc.BindAPIKey(ctx, accounts.BindAPIKeyParams{UserID: user.ID, UserAPIKeyID: key.ID, UpstreamCredentialID: primary.ID})
+	require.NoError(t, err)
+	_, err = accountSvc.BindAPIKey(ctx, accounts.BindAPIKeyParams{UserID: user.ID, UserAPIKeyID: key.ID, UpstreamCredentialID: backup.ID, Position: 1})
+	require.NoError(t, err)
+	require.NoError(t, accountSvc.SetUpstreamCredentialEnabled(ctx, primary.ID, false))
+
+	svc := &ruleServiceStub{rules: []rules.Rule{{ID: "replayed", Priority: 5, Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}}}}
+	h := NewHandler(svc, WithAccountsService(accountSvc))
+
+	// 首个绑定的上游已停用时与网关一样改用下一个启用的绑定，而不是拒绝。
+	result, err := h.Replay(ctx, ReplayRequest{Method: http.MethodPost, Path: "/v1/chat/completions", Body: []byte(`{}`), APIKeyID: key.ID})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, result.Status, string(result.Body))
+	require.Equal(t, "Bearer sk-backup", gotAuth)
+
+	// 全部绑定停用时仍与网关一致地拒绝。
+	require.NoError(t, accountSvc.SetUpstreamCredentialEnabled(ctx, backup.ID, false))
+	result, err = h.Replay(ctx, ReplayRequest{Method: http.MethodPost, Path: "/v1/chat/completions", Body: []byte(`{}`), APIKeyID: key.ID})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, result.Status)
+	require.Contains(t, string(result.Body), "YAPI_UPSTREAM_CREDENTIAL_DISABLED")
+}
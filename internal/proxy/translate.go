@@ -0,0 +1,43 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/translate"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// newTranslator 返回本次转发使用的协议转换器：规则 translate_protocol 优先，否则按当前上游凭据的
+// Provider（如 gemini、azure）选择适配器；两者都未命中时返回 nil。
+func newTranslator(c *gin.Context, rule rules.Rule) translate.Translator {
+	if rule.Actions.TranslateProtocol != "" {
+		translator, _ := translate.New(rule.Actions.TranslateProtocol)
+		return translator
+	}
+	if info, ok := middleware.CurrentUpstreamInfo(c); ok {
+		if translator, ok := translate.ForCredential(info.Credential); ok {
+			return translator
+		}
+	}
+	return nil
+}
+
+// translateTransport 在 Director 中的请求转换失败时直接返回该错误，不再访问上游。
+// ReverseProxy 的 Director 无法中止请求，只能由传输层把错误交给 ErrorHandler。
+type translateTransport struct {
+	base http.RoundTripper
+	err  *error
+}
+
+func (t *translateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if *t.err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, *t.err
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prehisle/yapi/internal/middleware"
+	"github.com/prehisle/yapi/internal/usage"
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// WithModelPricing 设置估算费用所用的模型价格表，未设置时仅统计 token 用量。
+func WithModelPricing(pricing usage.Pricing) Option {
+	return func(h *Handler) {
+		h.pricing = pricing
+	}
+}
+
+// usageLabels 是用量指标的维度，在转发时从请求上下文中确定。
+type usageLabels struct {
+	provider string
+	user     string
+	// budgetUser 与 budgetOrg 是费用计入预算的主体，匿名请求为空。
+	budgetUser string
+	budgetOrg  string
+}
+
+func currentUsageLabels(c *gin.Context) usageLabels {
+	labels := usageLabels{provider: "default", user: "anonymous"}
+	if info, ok := middleware.CurrentUpstreamInfo(c); ok {
+		if service := strings.TrimSpace(info.Credential.Service); service != "" {
+			labels.provider = service
+		}
+	}
+	if user, ok := middleware.CurrentUser(c); ok && user.ID != "" {
+		labels.user = user.ID
+	}
+	labels.budgetUser, labels.budgetOrg = budgetSubjects(c)
+	return labels
+}
+
+// meterUsage 包装成功响应的响应体，在响应体读完或关闭时上报 token 用量与估算费用，并写入 GenAI 语义约定属性。
+// 压缩的响应体无法在不解压的前提下解析，直接跳过。
+func (h *Handler) meterUsage(resp *http.Response, labels usageLabels, record *analyticsRecord, genAI *genAICall) {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return
+	}
+	resp.Body = &usageBody{
+		ReadCloser: resp.Body,
+		extractor:  usage.NewExtractor(resp.Header.Get("Content-Type")),
+		report: func(u usage.Usage) {
+			model := u.Model
+			if model == "" {
+				model = "unknown"
+			}
+			cost := h.pricing.Cost(u)
+			metrics.ObserveUsage(model, labels.provider, labels.user, u.PromptTokens, u.CompletionTokens, cost)
+			record.setUsage(u, cost)
+			h.recordBudget(labels, cost)
+			genAI.finish(u)
+		},
+	}
+}
+
+// usageBody 在转发响应体的同时把数据交给提取器。
+type usageBody struct {
+	io.ReadCloser
+	extractor *usage.Extractor
+	report    func(usage.Usage)
+	once      sync.Once
+}
+
+func (b *usageBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		_, _ = b.extractor.Write(p[:n])
+	}
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *usageBody) Close() error {
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+func (b *usageBody) finish() {
+	b.once.Do(func() {
+		if u, ok := b.extractor.Result(); ok {
+			b.report(u)
+		}
+	})
+}
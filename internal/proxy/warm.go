@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// warmRequestTimeout 是单次预热请求的超时。
+const warmRequestTimeout = 10 * time.Second
+
+// WarmOptions 描述上游连接预热：每隔 Interval 向每个 Target 并发发送 Conns 个 HEAD 请求，
+// 使传输层的空闲连接池中始终保留已完成 TLS 握手的连接，空闲一段时间后的首个请求无需重新建连。
+// HTTP/2 上游在同一连接上复用请求，只保留一条连接。
+type WarmOptions struct {
+	Targets  []*url.URL
+	Conns    int
+	Interval time.Duration
+}
+
+// WithWarmConnections 启用上游连接预热，需调用 StartWarming 开始；同时把默认传输层每个主机保留的空闲连接数
+// 提高到不少于 Conns，避免预热的连接被立即回收。
+func WithWarmConnections(opts WarmOptions) Option {
+	return func(h *Handler) {
+		h.warm = opts
+	}
+}
+
+// StartWarming 在后台按 WarmOptions 预热上游连接，直到 ctx 结束；未配置预热目标时不做任何事。
+func (h *Handler) StartWarming(ctx context.Context) {
+	if len(h.warm.Targets) == 0 || h.warm.Conns <= 0 || h.warm.Interval <= 0 {
+		return
+	}
+	go func() {
+		h.warmConnections(ctx)
+		ticker := time.NewTicker(h.warm.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.warmConnections(ctx)
+			}
+		}
+	}()
+}
+
+// warmConnections 执行一轮预热。预热请求直接经过底层传输层，不计入上游请求指标。
+func (h *Handler) warmConnections(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, target := range h.warm.Targets {
+		for range h.warm.Conns {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				h.warmOnce(ctx, target)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+func (h *Handler) warmOnce(ctx context.Context, target *url.URL) {
+	ctx, cancel := context.WithTimeout(ctx, warmRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target.String(), nil)
+	if err != nil {
+		return
+	}
+	resp, err := h.warmTransport.RoundTrip(req)
+	if err != nil {
+		metrics.ObserveUpstreamWarm(target.Host, "error")
+		if h.logger != nil && ctx.Err() == nil {
+			h.logger.Debug("upstream warm-up failed", "target", target.Host, "error", err)
+		}
+		return
+	}
+	// 读完响应体后连接才会回到空闲连接池。
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	metrics.ObserveUpstreamWarm(target.Host, "success")
+}
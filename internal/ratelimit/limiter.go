@@ -0,0 +1,38 @@
+// Package ratelimit 提供按用户/API Key 的请求速率与并发限制计数器。
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Window 是速率限制的统计窗口。
+const Window = time.Minute
+
+// Result 描述一次速率检查的结果。
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	ResetAt   time.Time
+}
+
+// Limiter 抽象速率与并发计数器，可由 Redis（多实例共享）或进程内存实现。
+type Limiter interface {
+	// Allow 在固定窗口内为 key 计数一次，超过 limit 时返回 Allowed=false。
+	Allow(ctx context.Context, key string, limit int) (Result, error)
+	// Acquire 占用 key 的一个并发名额；ok=false 表示已达上限。成功时须调用 release 归还。
+	Acquire(ctx context.Context, key string, limit int) (release func(), ok bool, err error)
+}
+
+// windowStart 返回 now 所在固定窗口的起点。
+func windowStart(now time.Time) time.Time {
+	return now.Truncate(Window)
+}
+
+func remaining(limit int, count int64) int {
+	if left := int64(limit) - count; left > 0 {
+		return int(left)
+	}
+	return 0
+}
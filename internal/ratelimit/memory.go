@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryLimiter 在进程内计数，适用于单实例部署或 Redis 不可用时的降级。
+type MemoryLimiter struct {
+	mu       sync.Mutex
+	windows  map[string]memoryWindow
+	inflight map[string]int
+	now      func() time.Time
+}
+
+type memoryWindow struct {
+	start time.Time
+	count int64
+}
+
+// NewMemoryLimiter 创建进程内限流器。
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		windows:  make(map[string]memoryWindow),
+		inflight: make(map[string]int),
+		now:      time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, limit int) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	start := windowStart(l.now())
+	window := l.windows[key]
+	if !window.start.Equal(start) {
+		window = memoryWindow{start: start}
+	}
+	window.count++
+	l.windows[key] = window
+	l.evictExpired(start)
+	return Result{
+		Allowed:   window.count <= int64(limit),
+		Limit:     limit,
+		Remaining: remaining(limit, window.count),
+		ResetAt:   start.Add(Window),
+	}, nil
+}
+
+func (l *MemoryLimiter) Acquire(ctx context.Context, key string, limit int) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight[key] >= limit {
+		return nil, false, nil
+	}
+	l.inflight[key]++
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.inflight[key] <= 1 {
+				delete(l.inflight, key)
+				return
+			}
+			l.inflight[key]--
+		})
+	}
+	return release, true, nil
+}
+
+// evictExpired 清理早于当前窗口的计数，避免 key 无限增长。
+func (l *MemoryLimiter) evictExpired(current time.Time) {
+	for key, window := range l.windows {
+		if window.start.Before(current) {
+			delete(l.windows, key)
+		}
+	}
+}
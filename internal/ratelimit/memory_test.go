@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLimiter_AllowResetsEachWindow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, time.November, 3, 9, 0, 10, 0, time.UTC)
+	limiter := NewMemoryLimiter()
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		result, err := limiter.Allow(ctx, "user:u1", 2)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+	}
+	result, err := limiter.Allow(ctx, "user:u1", 2)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+	require.Equal(t, time.Date(2025, time.November, 3, 9, 1, 0, 0, time.UTC), result.ResetAt)
+
+	other, err := limiter.Allow(ctx, "user:u2", 2)
+	require.NoError(t, err)
+	require.True(t, other.Allowed)
+
+	now = now.Add(time.Minute)
+	result, err = limiter.Allow(ctx, "user:u1", 2)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 1, result.Remaining)
+}
+
+func TestMemoryLimiter_AcquireCapsConcurrency(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewMemoryLimiter()
+
+	release, ok, err := limiter.Acquire(ctx, "api_key:k1", 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, ok, err = limiter.Acquire(ctx, "api_key:k1", 1)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	release()
+	release()
+	_, ok, err = limiter.Acquire(ctx, "api_key:k1", 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// concurrencyTTL 为并发计数设置兜底过期时间，防止实例异常退出后名额永久泄漏。
+const concurrencyTTL = 10 * time.Minute
+
+// acquireScript 原子地检查并占用一个并发名额。
+var acquireScript = redis.NewScript(`
+local current = redis.call("INCR", KEYS[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+if current > tonumber(ARGV[1]) then
+  redis.call("DECR", KEYS[1])
+  return 0
+end
+return 1
+`)
+
+// RedisLimiter 使用 Redis 计数，使多个网关实例共享同一限额。
+type RedisLimiter struct {
+	client redis.UniversalClient
+	prefix string
+	now    func() time.Time
+}
+
+// NewRedisLimiter 创建基于 Redis 的限流器，prefix 为计数 key 的前缀。
+func NewRedisLimiter(client redis.UniversalClient, prefix string) *RedisLimiter {
+	if prefix == "" {
+		prefix = "ratelimit"
+	}
+	return &RedisLimiter{client: client, prefix: prefix, now: time.Now}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit int) (Result, error) {
+	start := windowStart(l.now())
+	counterKey := l.prefix + ":rpm:" + key + ":" + strconv.FormatInt(start.Unix(), 10)
+	pipe := l.client.TxPipeline()
+	incr := pipe.Incr(ctx, counterKey)
+	pipe.Expire(ctx, counterKey, Window+time.Second)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Result{}, err
+	}
+	count := incr.Val()
+	return Result{
+		Allowed:   count <= int64(limit),
+		Limit:     limit,
+		Remaining: remaining(limit, count),
+		ResetAt:   start.Add(Window),
+	}, nil
+}
+
+func (l *RedisLimiter) Acquire(ctx context.Context, key string, limit int) (func(), bool, error) {
+	counterKey := l.prefix + ":concurrency:" + key
+	ok, err := acquireScript.Run(ctx, l.client, []string{counterKey}, limit, concurrencyTTL.Milliseconds()).Int()
+	if err != nil {
+		return nil, false, err
+	}
+	if ok != 1 {
+		return nil, false, nil
+	}
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			// 请求上下文可能已取消，归还名额使用独立的短超时。
+			releaseCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			_ = l.client.Decr(releaseCtx, counterKey).Err()
+		})
+	}
+	return release, true, nil
+}
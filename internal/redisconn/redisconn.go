@@ -0,0 +1,145 @@
+// Package redisconn 把 REDIS_URL 与 REDIS_* 设置解析为 go-redis 的 UniversalOptions，
+// 统一支持单机、Sentinel 与 Cluster 三种部署形态以及密码、DB 编号与 TLS，
+// 规则缓存、事件总线、限流等组件共用同一个客户端。
+package redisconn
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrInvalidSettings 表示 Redis 连接设置无法解析或相互冲突。
+var ErrInvalidSettings = errors.New("invalid redis settings")
+
+// 部署形态，由 Mode 返回。
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
+)
+
+// Settings 是 Redis 连接设置。URL 非空时 Addr 被忽略，其余非零字段覆盖 URL 中的对应部分，
+// 便于把密码放在单独的密钥变量中。
+type Settings struct {
+	// URL 形如 redis://[user:password@]host:port[/db]，rediss:// 启用 TLS；
+	// 多个地址以逗号分隔或通过重复的 addr 查询参数给出，master_name 参数指定 Sentinel 主节点名，cluster=true 启用集群模式。
+	URL string
+	// Addr 为逗号分隔的地址列表，未设置 URL 时使用。
+	Addr             string
+	Username         string
+	Password         string
+	DB               int
+	TLS              bool
+	SentinelMaster   string
+	SentinelPassword string
+	Cluster          bool
+}
+
+// Options 解析 Settings。设置了 Sentinel 主节点名时地址视为 Sentinel 节点；设置 Cluster 或给出多个地址时使用集群模式。
+func Options(s Settings) (*redis.UniversalOptions, error) {
+	opts := &redis.UniversalOptions{}
+	if s.URL != "" {
+		if err := parseURL(s.URL, opts); err != nil {
+			return nil, err
+		}
+	} else {
+		opts.Addrs = splitAddrs(s.Addr)
+	}
+	if s.Username != "" {
+		opts.Username = s.Username
+	}
+	if s.Password != "" {
+		opts.Password = s.Password
+	}
+	if s.DB != 0 {
+		opts.DB = s.DB
+	}
+	if s.TLS && opts.TLSConfig == nil {
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if s.SentinelMaster != "" {
+		opts.MasterName = s.SentinelMaster
+	}
+	if s.SentinelPassword != "" {
+		opts.SentinelPassword = s.SentinelPassword
+	}
+	opts.IsClusterMode = opts.IsClusterMode || s.Cluster
+	if len(opts.Addrs) == 0 {
+		return nil, fmt.Errorf("%w: no address", ErrInvalidSettings)
+	}
+	if opts.MasterName != "" && opts.IsClusterMode {
+		return nil, fmt.Errorf("%w: sentinel master name and cluster mode are mutually exclusive", ErrInvalidSettings)
+	}
+	if Mode(opts) == ModeCluster && opts.DB != 0 {
+		return nil, fmt.Errorf("%w: cluster mode only supports db 0", ErrInvalidSettings)
+	}
+	return opts, nil
+}
+
+// Mode 返回 opts 对应的部署形态，与 redis.NewUniversalClient 的选择一致。
+func Mode(opts *redis.UniversalOptions) string {
+	switch {
+	case opts.MasterName != "":
+		return ModeSentinel
+	case len(opts.Addrs) > 1 || opts.IsClusterMode:
+		return ModeCluster
+	default:
+		return ModeStandalone
+	}
+}
+
+func parseURL(raw string, opts *redis.UniversalOptions) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		// 不回显原始 URL，以免在日志中泄露密码。
+		return fmt.Errorf("%w: malformed REDIS_URL", ErrInvalidSettings)
+	}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	default:
+		return fmt.Errorf("%w: unsupported REDIS_URL scheme %q, want redis or rediss", ErrInvalidSettings, u.Scheme)
+	}
+	if u.User != nil {
+		opts.Username = u.User.Username()
+		opts.Password, _ = u.User.Password()
+	}
+	query := u.Query()
+	opts.Addrs = append(splitAddrs(u.Host), query["addr"]...)
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if opts.DB, err = strconv.Atoi(db); err != nil {
+			return fmt.Errorf("%w: invalid db %q in REDIS_URL", ErrInvalidSettings, db)
+		}
+	}
+	opts.MasterName = query.Get("master_name")
+	if raw := query.Get("cluster"); raw != "" {
+		if opts.IsClusterMode, err = strconv.ParseBool(raw); err != nil {
+			return fmt.Errorf("%w: invalid cluster %q in REDIS_URL", ErrInvalidSettings, raw)
+		}
+	}
+	if opts.TLSConfig != nil {
+		if raw := query.Get("skip_verify"); raw != "" {
+			if opts.TLSConfig.InsecureSkipVerify, err = strconv.ParseBool(raw); err != nil {
+				return fmt.Errorf("%w: invalid skip_verify %q in REDIS_URL", ErrInvalidSettings, raw)
+			}
+		}
+	}
+	return nil
+}
+
+func splitAddrs(raw string) []string {
+	var addrs []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			addrs = append(addrs, part)
+		}
+	}
+	return addrs
+}
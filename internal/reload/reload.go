@@ -0,0 +1,145 @@
+// Package reload 在收到 SIGHUP 或管理端请求时重新读取配置文件，并把变更应用到支持热更新的子系统
+// （日志级别、默认限流、CORS 来源、默认上游等），无需重启进程。其余配置项的变更只会被报告为需要重启。
+package reload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+
+	"github.com/prehisle/yapi/pkg/config"
+)
+
+// ErrNoConfigFile 表示启动时未指定配置文件，无法重新加载。
+var ErrNoConfigFile = errors.New("no config file to reload")
+
+// Apply 把新配置应用到一个子系统，失败时应保持该子系统原状。
+type Apply func(cfg config.Config) error
+
+type subsystem struct {
+	name  string
+	keys  []string
+	apply Apply
+}
+
+// Result 是一次重新加载的结果。
+type Result struct {
+	// Changed 为取值发生变化的配置项（环境变量名）。
+	Changed []string `json:"changed"`
+	// Applied 为已应用新配置的子系统。
+	Applied []string `json:"applied"`
+	// RestartRequired 为发生变化但不支持热更新的配置项，需重启后生效。
+	RestartRequired []string `json:"restart_required"`
+}
+
+// Reloader 保存当前生效的配置与可热更新的子系统。
+type Reloader struct {
+	mu         sync.Mutex
+	path       string
+	current    config.Config
+	subsystems []subsystem
+	load       func(path string) (config.Config, error)
+	logger     *slog.Logger
+}
+
+// Option 配置 Reloader。
+type Option func(*Reloader)
+
+// WithSubsystem 注册一个可热更新的子系统：keys 中任一配置项变化时调用 apply。
+func WithSubsystem(name string, keys []string, apply Apply) Option {
+	return func(r *Reloader) {
+		r.subsystems = append(r.subsystems, subsystem{name: name, keys: keys, apply: apply})
+	}
+}
+
+// WithLogger 设置记录重新加载结果的日志。
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *Reloader) {
+		if logger != nil {
+			r.logger = logger
+		}
+	}
+}
+
+// New 创建 Reloader。path 为启动时使用的配置文件，current 为启动时加载的配置。
+func New(path string, current config.Config, opts ...Option) *Reloader {
+	r := &Reloader{path: path, current: current, load: config.LoadFile, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Current 返回当前生效的配置。
+func (r *Reloader) Current() config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload 重新读取配置文件并应用到受影响的子系统。配置文件无法解析时不做任何修改；
+// 某个子系统应用失败时停止后续子系统并返回错误，已应用的子系统保持新配置，下次重新加载会再次尝试其余部分。
+func (r *Reloader) Reload() (Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.path == "" {
+		return Result{}, ErrNoConfigFile
+	}
+	next, err := r.load(r.path)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{Changed: config.Diff(r.current, next), Applied: []string{}, RestartRequired: []string{}}
+	if result.Changed == nil {
+		result.Changed = []string{}
+	}
+	covered := map[string]bool{}
+	for _, sub := range r.subsystems {
+		affected := false
+		for _, key := range sub.keys {
+			covered[key] = true
+			affected = affected || slices.Contains(result.Changed, key)
+		}
+		if !affected {
+			continue
+		}
+		if err := sub.apply(next); err != nil {
+			r.logger.Error("config reload failed", "subsystem", sub.name, "error", err)
+			return result, fmt.Errorf("apply %s: %w", sub.name, err)
+		}
+		result.Applied = append(result.Applied, sub.name)
+	}
+	for _, key := range result.Changed {
+		if !covered[key] {
+			result.RestartRequired = append(result.RestartRequired, key)
+		}
+	}
+	r.current = next
+	r.logger.Info("config reloaded", "path", r.path, "changed", result.Changed, "applied", result.Applied, "restart_required", result.RestartRequired)
+	return result, nil
+}
+
+// WatchSignals 在收到 SIGHUP 时重新加载配置，直到 ctx 结束。
+func (r *Reloader) WatchSignals(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				if _, err := r.Reload(); err != nil {
+					r.logger.Error("config reload on SIGHUP failed", "error", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,83 @@
+package reload
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/pkg/config"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestReloader_AppliesChangedSubsystems(t *testing.T) {
+	// t.Setenv 保证测试结束后恢复配置文件写入的变量。
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("ADMIN_ALLOWED_ORIGINS", "")
+	t.Setenv("GATEWAY_PORT", "")
+	path := filepath.Join(t.TempDir(), "yapi.yaml")
+	writeConfig(t, path, "log_level: info\nadmin:\n  allowed_origins: [https://a.example.com]\n")
+	cfg, err := config.LoadFile(path)
+	require.NoError(t, err)
+
+	var levels, origins []string
+	reloader := New(path, cfg,
+		WithSubsystem("log_level", []string{"LOG_LEVEL"}, func(next config.Config) error {
+			levels = append(levels, next.LogLevel)
+			return nil
+		}),
+		WithSubsystem("cors", []string{"ADMIN_ALLOWED_ORIGINS"}, func(next config.Config) error {
+			origins = append(origins, next.AdminAllowedOrigins...)
+			return nil
+		}),
+	)
+
+	writeConfig(t, path, "log_level: debug\nadmin:\n  allowed_origins: [https://a.example.com]\ngateway_port: 9000\n")
+	result, err := reloader.Reload()
+	require.NoError(t, err)
+	require.Equal(t, Result{
+		Changed:         []string{"GATEWAY_PORT", "LOG_LEVEL"},
+		Applied:         []string{"log_level"},
+		RestartRequired: []string{"GATEWAY_PORT"},
+	}, result)
+	require.Equal(t, []string{"debug"}, levels)
+	require.Empty(t, origins)
+	require.Equal(t, "debug", reloader.Current().LogLevel)
+
+	result, err = reloader.Reload()
+	require.NoError(t, err)
+	require.Empty(t, result.Changed)
+	require.Empty(t, result.Applied)
+}
+
+func TestReloader_KeepsCurrentConfigOnFailure(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	path := filepath.Join(t.TempDir(), "yapi.yaml")
+	writeConfig(t, path, "log_level: info\n")
+	cfg, err := config.LoadFile(path)
+	require.NoError(t, err)
+	applyErr := errors.New("boom")
+	reloader := New(path, cfg, WithSubsystem("log_level", []string{"LOG_LEVEL"}, func(config.Config) error {
+		return applyErr
+	}))
+
+	writeConfig(t, path, "log_level: [\n")
+	_, err = reloader.Reload()
+	require.ErrorIs(t, err, config.ErrInvalidConfigFile)
+
+	writeConfig(t, path, "log_level: debug\n")
+	_, err = reloader.Reload()
+	require.ErrorIs(t, err, applyErr)
+	require.Equal(t, "info", reloader.Current().LogLevel)
+}
+
+func TestReloader_RequiresConfigFile(t *testing.T) {
+	_, err := New("", config.Config{}).Reload()
+	require.ErrorIs(t, err, ErrNoConfigFile)
+}
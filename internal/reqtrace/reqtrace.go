@@ -0,0 +1,270 @@
+// Package reqtrace 记录代理请求的决策轨迹：原请求的查询串与请求头、命中的规则、执行的动作、请求头与请求体改写、
+// 选用的上游端点、故障转移重试及各次上游耗时，供管理端按请求 ID 排查问题。
+// 敏感请求头与字段的取值在记录前脱敏，轨迹只保留在有界的内存或带过期时间的 Redis 中。
+package reqtrace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNotFound 表示轨迹不存在或已过期。
+var ErrNotFound = errors.New("request trace not found")
+
+// Redacted 是脱敏后的占位值。
+const Redacted = "[REDACTED]"
+
+// Trace 是单个代理请求的完整决策轨迹。Query 与 RequestHeaders 为脱敏后的原请求查询串与请求头，
+// HasBody 表示原请求带有请求体，请求体本身不记录在轨迹中。
+type Trace struct {
+	RequestID      string           `json:"request_id"`
+	Method         string           `json:"method"`
+	Path           string           `json:"path"`
+	Query          string           `json:"query,omitempty"`
+	RequestHeaders http.Header      `json:"request_headers,omitempty"`
+	HasBody        bool             `json:"has_body,omitempty"`
+	UserID         string           `json:"user_id,omitempty"`
+	APIKeyID       string           `json:"api_key_id,omitempty"`
+	Rule           *RuleMatch       `json:"rule,omitempty"`
+	Actions        []string         `json:"actions,omitempty"`
+	Headers        []HeaderMutation `json:"header_mutations,omitempty"`
+	Body           []BodyMutation   `json:"body_mutations,omitempty"`
+	Attempts       []Attempt        `json:"attempts,omitempty"`
+	Status         int              `json:"status"`
+	Error          string           `json:"error,omitempty"`
+	StartedAt      time.Time        `json:"started_at"`
+	DurationMs     int64            `json:"duration_ms"`
+}
+
+// RuleMatch 描述命中的规则；Default 表示未命中任何规则而使用默认上游。
+type RuleMatch struct {
+	ID       string `json:"id"`
+	Priority int    `json:"priority"`
+	Version  int    `json:"version,omitempty"`
+	Default  bool   `json:"default,omitempty"`
+}
+
+// HeaderMutation 记录一次请求头改写，Op 为 set、add 或 remove。
+type HeaderMutation struct {
+	Op    string `json:"op"`
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// BodyMutation 记录一次 JSON 请求体改写，Op 为 override 或 remove。
+type BodyMutation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}
+
+// Attempt 记录一次上游调用。Path 为规则改写后的上游路径；
+// LatencyMs 为收到响应头的耗时，DurationMs 含响应体传输。
+type Attempt struct {
+	Target       string `json:"target"`
+	Path         string `json:"path,omitempty"`
+	Model        string `json:"model,omitempty"`
+	BindingID    string `json:"binding_id,omitempty"`
+	CredentialID string `json:"credential_id,omitempty"`
+	Position     int    `json:"position,omitempty"`
+	Status       int    `json:"status,omitempty"`
+	Error        string `json:"error,omitempty"`
+	Failover     bool   `json:"failover,omitempty"`
+	LatencyMs    int64  `json:"latency_ms"`
+	DurationMs   int64  `json:"duration_ms"`
+}
+
+// ResetMutations 清空已记录的动作与改写。故障转移会重新执行规则动作，轨迹只保留最后一次尝试的改写。
+func (t *Trace) ResetMutations() {
+	if t == nil {
+		return
+	}
+	t.Actions, t.Headers, t.Body = nil, nil, nil
+}
+
+// RecordAction 记录一个已执行的规则动作，同名动作只记录一次。
+func (t *Trace) RecordAction(name string) {
+	if t == nil || slices.Contains(t.Actions, name) {
+		return
+	}
+	t.Actions = append(t.Actions, name)
+}
+
+// RecordHeader 记录一次请求头改写，敏感取值会被脱敏。
+func (t *Trace) RecordHeader(op, name, value string) {
+	if t == nil {
+		return
+	}
+	t.Headers = append(t.Headers, HeaderMutation{Op: op, Name: name, Value: RedactHeader(name, value)})
+}
+
+// RecordBody 记录一次 JSON 请求体改写，敏感取值会被脱敏。
+func (t *Trace) RecordBody(op, path string, value any) {
+	if t == nil {
+		return
+	}
+	t.Body = append(t.Body, BodyMutation{Op: op, Path: path, Value: RedactValue(path, value)})
+}
+
+// Store 保存与查询请求轨迹。
+type Store interface {
+	Save(ctx context.Context, trace Trace) error
+	Get(ctx context.Context, requestID string) (Trace, error)
+}
+
+var sensitiveMarkers = []string{"authorization", "cookie", "key", "token", "secret", "password"}
+
+// Sensitive 判断请求头名或 JSON 路径是否可能携带凭据。
+func Sensitive(name string) bool {
+	lower := strings.ToLower(name)
+	for _, marker := range sensitiveMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactHeader 返回可记录的请求头取值，敏感请求头替换为占位值。
+func RedactHeader(name, value string) string {
+	if value != "" && Sensitive(name) {
+		return Redacted
+	}
+	return value
+}
+
+// RedactHeaders 返回可记录的请求头副本，敏感请求头的取值替换为占位值。
+func RedactHeaders(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+	redacted := make(http.Header, len(header))
+	for name, values := range header {
+		copied := make([]string, len(values))
+		for i, value := range values {
+			copied[i] = RedactHeader(name, value)
+		}
+		redacted[name] = copied
+	}
+	return redacted
+}
+
+// RedactQuery 返回可记录的查询串，名称敏感的参数取值替换为占位值；没有敏感参数时原样返回，无法解析时返回空串。
+func RedactQuery(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return ""
+	}
+	redacted := false
+	for name, list := range values {
+		if !Sensitive(name) {
+			continue
+		}
+		for i := range list {
+			list[i] = Redacted
+		}
+		redacted = true
+	}
+	if !redacted {
+		return raw
+	}
+	return values.Encode()
+}
+
+// RedactValue 返回可记录的 JSON 字段取值，敏感路径替换为占位值。
+func RedactValue(path string, value any) any {
+	if value != nil && Sensitive(path) {
+		return Redacted
+	}
+	return value
+}
+
+// MemoryStore 在进程内保存最近的轨迹，超出容量时淘汰最早的记录。
+type MemoryStore struct {
+	mu       sync.Mutex
+	capacity int
+	order    []string
+	traces   map[string]Trace
+}
+
+// NewMemoryStore 创建最多保存 capacity 条轨迹的内存存储。
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &MemoryStore{capacity: capacity, traces: make(map[string]Trace, capacity)}
+}
+
+func (s *MemoryStore) Save(_ context.Context, trace Trace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.traces[trace.RequestID]; !exists {
+		if len(s.order) >= s.capacity {
+			delete(s.traces, s.order[0])
+			s.order = s.order[1:]
+		}
+		s.order = append(s.order, trace.RequestID)
+	}
+	s.traces[trace.RequestID] = trace
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, requestID string) (Trace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	trace, ok := s.traces[requestID]
+	if !ok {
+		return Trace{}, ErrNotFound
+	}
+	return trace, nil
+}
+
+// RedisStore 将轨迹以 JSON 写入 Redis，多实例共享，键在 ttl 后过期。
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisStore 创建基于 Redis 的轨迹存储，键形如 <prefix>:<request_id>。
+func NewRedisStore(client redis.UniversalClient, prefix string, ttl time.Duration) *RedisStore {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &RedisStore{client: client, prefix: prefix, ttl: ttl}
+}
+
+func (s *RedisStore) Save(ctx context.Context, trace Trace) error {
+	payload, err := json.Marshal(trace)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+":"+trace.RequestID, payload, s.ttl).Err()
+}
+
+func (s *RedisStore) Get(ctx context.Context, requestID string) (Trace, error) {
+	payload, err := s.client.Get(ctx, s.prefix+":"+requestID).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Trace{}, ErrNotFound
+	}
+	if err != nil {
+		return Trace{}, err
+	}
+	var trace Trace
+	if err := json.Unmarshal(payload, &trace); err != nil {
+		return Trace{}, err
+	}
+	return trace, nil
+}
@@ -0,0 +1,65 @@
+package reqtrace
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_EvictsOldest(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(2)
+	for _, id := range []string{"r1", "r2", "r3"} {
+		require.NoError(t, store.Save(ctx, Trace{RequestID: id}))
+	}
+	_, err := store.Get(ctx, "r1")
+	require.ErrorIs(t, err, ErrNotFound)
+	trace, err := store.Get(ctx, "r3")
+	require.NoError(t, err)
+	require.Equal(t, "r3", trace.RequestID)
+
+	// 覆盖已有轨迹不占用额外容量。
+	require.NoError(t, store.Save(ctx, Trace{RequestID: "r3", Status: 200}))
+	_, err = store.Get(ctx, "r2")
+	require.NoError(t, err)
+}
+
+func TestTrace_RedactsSensitiveValues(t *testing.T) {
+	trace := &Trace{}
+	trace.RecordHeader("set", "X-Api-Key", "sk-1")
+	trace.RecordHeader("set", "Cookie", "session=1")
+	trace.RecordHeader("set", "X-Team", "search")
+	trace.RecordBody("override", "metadata.access_token", "t")
+	trace.RecordBody("override", "model", "gpt-4.1")
+	trace.RecordAction("set_headers")
+	trace.RecordAction("set_headers")
+
+	require.Equal(t, []HeaderMutation{
+		{Op: "set", Name: "X-Api-Key", Value: Redacted},
+		{Op: "set", Name: "Cookie", Value: Redacted},
+		{Op: "set", Name: "X-Team", Value: "search"},
+	}, trace.Headers)
+	require.Equal(t, []BodyMutation{
+		{Op: "override", Path: "metadata.access_token", Value: Redacted},
+		{Op: "override", Path: "model", Value: "gpt-4.1"},
+	}, trace.Body)
+	require.Equal(t, []string{"set_headers"}, trace.Actions)
+
+	trace.ResetMutations()
+	require.Empty(t, trace.Headers)
+	var disabled *Trace
+	disabled.RecordHeader("set", "X-Team", "search")
+}
+
+func TestRedactRequest(t *testing.T) {
+	header := http.Header{"Authorization": {"Bearer yapi_1"}, "X-Team": {"search"}}
+	require.Equal(t, http.Header{"Authorization": {Redacted}, "X-Team": {"search"}}, RedactHeaders(header))
+	require.Equal(t, "Bearer yapi_1", header.Get("Authorization"))
+	require.Nil(t, RedactHeaders(nil))
+
+	require.Equal(t, "b=2&a=1", RedactQuery("b=2&a=1"))
+	require.Equal(t, "alt=sse&key=%5BREDACTED%5D", RedactQuery("key=AIza&alt=sse"))
+	require.Empty(t, RedactQuery("a=%zz"))
+}
@@ -0,0 +1,166 @@
+// Package respcache 缓存非流式补全请求的成功响应，供代理的 response_cache 规则动作使用。
+// 缓存键由调用方给出的作用域与归一化后的请求体（模型、提示词与其余参数）计算，
+// 提示词中的连续空白折叠为单个空格，因此仅缩进或换行不同的请求命中同一条缓存。
+// 缓存只保存在本实例内存中，按条目数与总字节数上限淘汰最久未使用的条目。
+package respcache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// promptKeys 是承载提示词的 JSON 字段，覆盖 OpenAI、Anthropic 与 Gemini 的请求格式，其中的字符串参与空白归一化。
+var promptKeys = []string{"messages", "system", "prompt", "input", "contents"}
+
+// Entry 是一条缓存的响应。
+type Entry struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+func (e Entry) size() int64 {
+	size := int64(len(e.Body))
+	for name, values := range e.Header {
+		for _, value := range values {
+			size += int64(len(name) + len(value))
+		}
+	}
+	return size
+}
+
+type item struct {
+	key     string
+	entry   Entry
+	size    int64
+	expires time.Time
+}
+
+// Cache 是并发安全的 LRU 响应缓存。
+type Cache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	order      *list.List
+	items      map[string]*list.Element
+	now        func() time.Time
+}
+
+// New 创建最多保存 maxEntries 条、合计 maxBytes 字节响应的缓存，maxBytes 不大于 0 时不限制总字节数。
+func New(maxEntries int, maxBytes int64) *Cache {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &Cache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// Get 返回未过期的缓存响应，过期条目在读取时删除。
+func (c *Cache) Get(key string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return Entry{}, false
+	}
+	it := elem.Value.(*item)
+	if !c.now().Before(it.expires) {
+		c.remove(elem)
+		return Entry{}, false
+	}
+	c.order.MoveToFront(elem)
+	return Entry{Status: it.entry.Status, Header: it.entry.Header.Clone(), Body: it.entry.Body}, true
+}
+
+// Set 保存响应 ttl 时长，超出上限时淘汰最久未使用的条目；单条响应超过总字节上限时不缓存并返回 false。
+func (c *Cache) Set(key string, entry Entry, ttl time.Duration) bool {
+	size := entry.size()
+	if ttl <= 0 || (c.maxBytes > 0 && size > c.maxBytes) {
+		return false
+	}
+	entry.Header = entry.Header.Clone()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+	c.items[key] = c.order.PushFront(&item{key: key, entry: entry, size: size, expires: c.now().Add(ttl)})
+	c.bytes += size
+	for c.order.Len() > c.maxEntries || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.remove(c.order.Back())
+	}
+	return true
+}
+
+// Len 返回当前缓存的条目数（含尚未清理的过期条目）。
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	it := c.order.Remove(elem).(*item)
+	delete(c.items, it.key)
+	c.bytes -= it.size
+}
+
+// Key 计算请求的缓存键。body 须是 JSON 对象且包含提示词字段；流式请求（stream 为 true）不缓存。
+// 其余参数（如 temperature、max_tokens、tools）按原值参与计算，对象键的顺序不影响结果。
+func Key(scope string, body []byte) (string, bool) {
+	var doc map[string]any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "", false
+	}
+	if stream, _ := doc["stream"].(bool); stream {
+		return "", false
+	}
+	hasPrompt := false
+	for _, key := range promptKeys {
+		if value, ok := doc[key]; ok {
+			doc[key] = normalize(value)
+			hasPrompt = true
+		}
+	}
+	if !hasPrompt {
+		return "", false
+	}
+	canonical, err := json.Marshal(doc)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.New()
+	sum.Write([]byte(scope))
+	sum.Write([]byte{0})
+	sum.Write(canonical)
+	return hex.EncodeToString(sum.Sum(nil)), true
+}
+
+// normalize 把字符串中的连续空白折叠为单个空格并去掉首尾空白。
+func normalize(value any) any {
+	switch typed := value.(type) {
+	case string:
+		return strings.Join(strings.Fields(typed), " ")
+	case []any:
+		for i, child := range typed {
+			typed[i] = normalize(child)
+		}
+	case map[string]any:
+		for key, child := range typed {
+			typed[key] = normalize(child)
+		}
+	}
+	return value
+}
@@ -0,0 +1,75 @@
+package respcache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKey_NormalizesPrompt(t *testing.T) {
+	a, ok := Key("rule", []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hello   world\n"}],"temperature":0}`))
+	require.True(t, ok)
+	b, ok := Key("rule", []byte(`{"temperature":0,"messages":[{"content":" hello\tworld","role":"user"}],"model":"gpt-4o"}`))
+	require.True(t, ok)
+	require.Equal(t, a, b)
+
+	for name, body := range map[string]string{
+		"model":       `{"model":"gpt-4o-mini","messages":[{"role":"user","content":"hello world"}],"temperature":0}`,
+		"temperature": `{"model":"gpt-4o","messages":[{"role":"user","content":"hello world"}],"temperature":1}`,
+		"content":     `{"model":"gpt-4o","messages":[{"role":"user","content":"helloworld"}],"temperature":0}`,
+	} {
+		other, ok := Key("rule", []byte(body))
+		require.True(t, ok, name)
+		require.NotEqual(t, a, other, name)
+	}
+	scoped, _ := Key("other", []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hello world"}],"temperature":0}`))
+	require.NotEqual(t, a, scoped)
+
+	for _, body := range []string{
+		`{"model":"gpt-4o","messages":[],"stream":true}`,
+		`{"model":"gpt-4o"}`,
+		`not json`,
+	} {
+		_, ok := Key("rule", []byte(body))
+		require.False(t, ok, body)
+	}
+}
+
+func TestCache_ExpiresEntries(t *testing.T) {
+	now := time.Now()
+	cache := New(10, 0)
+	cache.now = func() time.Time { return now }
+
+	require.True(t, cache.Set("k", Entry{Status: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}, Body: []byte(`{}`)}, time.Minute))
+	entry, ok := cache.Get("k")
+	require.True(t, ok)
+	require.Equal(t, "application/json", entry.Header.Get("Content-Type"))
+	require.Equal(t, `{}`, string(entry.Body))
+
+	now = now.Add(time.Minute)
+	_, ok = cache.Get("k")
+	require.False(t, ok)
+	require.Zero(t, cache.Len())
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := New(2, 0)
+	cache.Set("a", Entry{Body: []byte("a")}, time.Minute)
+	cache.Set("b", Entry{Body: []byte("b")}, time.Minute)
+	_, _ = cache.Get("a")
+	cache.Set("c", Entry{Body: []byte("c")}, time.Minute)
+	_, ok := cache.Get("b")
+	require.False(t, ok)
+	_, ok = cache.Get("a")
+	require.True(t, ok)
+
+	sized := New(10, 10)
+	require.False(t, sized.Set("big", Entry{Body: make([]byte, 11)}, time.Minute))
+	sized.Set("x", Entry{Body: make([]byte, 6)}, time.Minute)
+	sized.Set("y", Entry{Body: make([]byte, 6)}, time.Minute)
+	_, ok = sized.Get("x")
+	require.False(t, ok)
+	require.Equal(t, 1, sized.Len())
+}
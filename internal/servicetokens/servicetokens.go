@@ -0,0 +1,216 @@
+// Package servicetokens 管理供 CI 与自动化脚本使用的长期管理端令牌。令牌仅保存 SHA-256 摘要，
+// 明文只在创建时返回一次；每个令牌携带固定的权限范围，可随时吊销，与管理员登录会话相互独立。
+package servicetokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound 表示令牌不存在。
+	ErrNotFound = errors.New("service token not found")
+	// ErrInvalidInput 表示参数不合法。
+	ErrInvalidInput = errors.New("invalid service token input")
+	// ErrInvalidToken 表示令牌格式错误、不存在、已吊销或已过期。
+	ErrInvalidToken = errors.New("invalid service token")
+)
+
+const (
+	// TokenPrefix 是服务令牌明文的固定前缀，便于与 JWT 区分及在日志、代码仓库中识别泄露。
+	TokenPrefix      = "yst"
+	prefixBytes      = 4
+	secretBytes      = 24
+	lastUsedTouchGap = time.Minute
+)
+
+// Token 是一个服务令牌的元数据，不含明文。
+type Token struct {
+	ID          string     `gorm:"type:char(36);primaryKey"`
+	Name        string     `gorm:"type:varchar(128)"`
+	Prefix      string     `gorm:"type:char(8);uniqueIndex"`
+	SecretHash  string     `gorm:"type:char(64)"`
+	Permissions []string   `gorm:"serializer:json;type:text"`
+	CreatedBy   string     `gorm:"type:varchar(255)"`
+	ExpiresAt   *time.Time `gorm:"index"`
+	LastUsedAt  *time.Time
+	RevokedAt   *time.Time
+	CreatedAt   time.Time
+}
+
+// TableName 指定表名。
+func (Token) TableName() string {
+	return "admin_service_tokens"
+}
+
+// Active 判断令牌在 now 时刻是否可用。
+func (t Token) Active(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
+
+// CreateParams 描述新建令牌。
+type CreateParams struct {
+	Name        string
+	Permissions []string
+	ExpiresAt   *time.Time
+	CreatedBy   string
+}
+
+// Service 定义服务令牌的存取与校验。
+type Service interface {
+	AutoMigrate(ctx context.Context) error
+	Create(ctx context.Context, params CreateParams) (Token, string, error)
+	List(ctx context.Context) ([]Token, error)
+	Get(ctx context.Context, id string) (Token, error)
+	Revoke(ctx context.Context, id string) (Token, error)
+	Authenticate(ctx context.Context, plaintext string) (Token, error)
+}
+
+type service struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+// NewService 基于 gorm.DB 创建服务令牌存储。
+func NewService(db *gorm.DB) Service {
+	return &service{db: db, now: time.Now}
+}
+
+// IsServiceToken 判断字符串是否具有服务令牌的格式，用于在 Bearer 头中区分服务令牌与 JWT。
+func IsServiceToken(raw string) bool {
+	return strings.HasPrefix(raw, TokenPrefix+"_")
+}
+
+func (s *service) AutoMigrate(ctx context.Context) error {
+	return s.db.WithContext(ctx).AutoMigrate(&Token{})
+}
+
+func (s *service) Create(ctx context.Context, params CreateParams) (Token, string, error) {
+	name := strings.TrimSpace(params.Name)
+	if name == "" || len(name) > 128 {
+		return Token{}, "", fmt.Errorf("%w: name must be 1-128 characters", ErrInvalidInput)
+	}
+	if len(params.Permissions) == 0 {
+		return Token{}, "", fmt.Errorf("%w: permissions must not be empty", ErrInvalidInput)
+	}
+	if params.ExpiresAt != nil && !params.ExpiresAt.After(s.now()) {
+		return Token{}, "", fmt.Errorf("%w: expires_at must be in the future", ErrInvalidInput)
+	}
+	plaintext, prefix, err := generateToken()
+	if err != nil {
+		return Token{}, "", err
+	}
+	token := Token{
+		ID:          uuid.NewString(),
+		Name:        name,
+		Prefix:      prefix,
+		SecretHash:  hashToken(plaintext),
+		Permissions: params.Permissions,
+		CreatedBy:   params.CreatedBy,
+		ExpiresAt:   params.ExpiresAt,
+	}
+	if err := s.db.WithContext(ctx).Create(&token).Error; err != nil {
+		return Token{}, "", err
+	}
+	return token, plaintext, nil
+}
+
+func (s *service) List(ctx context.Context) ([]Token, error) {
+	var tokens []Token
+	if err := s.db.WithContext(ctx).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s *service) Get(ctx context.Context, id string) (Token, error) {
+	return s.first(ctx, "id = ?", id)
+}
+
+// Revoke 吊销令牌；重复吊销保持首次吊销时间不变。
+func (s *service) Revoke(ctx context.Context, id string) (Token, error) {
+	token, err := s.Get(ctx, id)
+	if err != nil {
+		return Token{}, err
+	}
+	if token.RevokedAt != nil {
+		return token, nil
+	}
+	now := s.now()
+	if err := s.db.WithContext(ctx).Model(&Token{}).Where("id = ?", id).Update("revoked_at", now).Error; err != nil {
+		return Token{}, err
+	}
+	token.RevokedAt = &now
+	return token, nil
+}
+
+// Authenticate 校验令牌明文并返回其元数据，同时按分钟粒度记录最近使用时间。
+func (s *service) Authenticate(ctx context.Context, plaintext string) (Token, error) {
+	prefix, ok := parsePrefix(plaintext)
+	if !ok {
+		return Token{}, ErrInvalidToken
+	}
+	token, err := s.first(ctx, "prefix = ?", prefix)
+	if errors.Is(err, ErrNotFound) {
+		return Token{}, ErrInvalidToken
+	}
+	if err != nil {
+		return Token{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(token.SecretHash), []byte(hashToken(plaintext))) != 1 {
+		return Token{}, ErrInvalidToken
+	}
+	now := s.now()
+	if !token.Active(now) {
+		return Token{}, ErrInvalidToken
+	}
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedTouchGap {
+		// 使用时间仅用于展示，写入失败不影响认证。
+		_ = s.db.WithContext(ctx).Model(&Token{}).Where("id = ?", token.ID).Update("last_used_at", now).Error
+		token.LastUsedAt = &now
+	}
+	return token, nil
+}
+
+func (s *service) first(ctx context.Context, query string, arg string) (Token, error) {
+	var token Token
+	err := s.db.WithContext(ctx).Where(query, arg).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Token{}, ErrNotFound
+	}
+	return token, err
+}
+
+// generateToken 生成形如 yst_<8 位十六进制前缀>_<48 位十六进制密钥> 的令牌。
+func generateToken() (string, string, error) {
+	buff := make([]byte, prefixBytes+secretBytes)
+	if _, err := rand.Read(buff); err != nil {
+		return "", "", err
+	}
+	prefix := hex.EncodeToString(buff[:prefixBytes])
+	return fmt.Sprintf("%s_%s_%s", TokenPrefix, prefix, hex.EncodeToString(buff[prefixBytes:])), prefix, nil
+}
+
+func parsePrefix(raw string) (string, bool) {
+	parts := strings.Split(raw, "_")
+	if len(parts) != 3 || parts[0] != TokenPrefix || len(parts[1]) != prefixBytes*2 || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// hashToken 计算令牌摘要。令牌本身具有足够熵值，无需加盐或慢哈希。
+func hashToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
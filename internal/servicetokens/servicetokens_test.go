@@ -0,0 +1,83 @@
+package servicetokens
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupService(t *testing.T, dsn string) *service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	svc := NewService(db).(*service)
+	require.NoError(t, svc.AutoMigrate(context.Background()))
+	return svc
+}
+
+func TestService_CreateAuthenticateRevoke(t *testing.T) {
+	ctx := context.Background()
+	svc := setupService(t, "file:servicetokens_lifecycle?mode=memory&cache=shared")
+
+	token, plaintext, err := svc.Create(ctx, CreateParams{Name: " ci-deploy ", Permissions: []string{"rules:read", "rules:write"}, CreatedBy: "root"})
+	require.NoError(t, err)
+	require.Equal(t, "ci-deploy", token.Name)
+	require.True(t, IsServiceToken(plaintext))
+	require.True(t, strings.HasPrefix(plaintext, "yst_"+token.Prefix+"_"))
+	require.NotContains(t, token.SecretHash, plaintext)
+
+	got, err := svc.Authenticate(ctx, plaintext)
+	require.NoError(t, err)
+	require.Equal(t, []string{"rules:read", "rules:write"}, got.Permissions)
+	require.NotNil(t, got.LastUsedAt)
+
+	_, err = svc.Authenticate(ctx, plaintext[:len(plaintext)-1]+"x")
+	require.ErrorIs(t, err, ErrInvalidToken)
+	_, err = svc.Authenticate(ctx, "yst_deadbeef_secret")
+	require.ErrorIs(t, err, ErrInvalidToken)
+	_, err = svc.Authenticate(ctx, "not-a-token")
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	revoked, err := svc.Revoke(ctx, token.ID)
+	require.NoError(t, err)
+	require.NotNil(t, revoked.RevokedAt)
+	again, err := svc.Revoke(ctx, token.ID)
+	require.NoError(t, err)
+	require.True(t, revoked.RevokedAt.Equal(*again.RevokedAt))
+	_, err = svc.Authenticate(ctx, plaintext)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = svc.Revoke(ctx, "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+	tokens, err := svc.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+}
+
+func TestService_ExpiryAndValidation(t *testing.T) {
+	ctx := context.Background()
+	svc := setupService(t, "file:servicetokens_expiry?mode=memory&cache=shared")
+
+	_, _, err := svc.Create(ctx, CreateParams{Name: "", Permissions: []string{"rules:read"}})
+	require.ErrorIs(t, err, ErrInvalidInput)
+	_, _, err = svc.Create(ctx, CreateParams{Name: "nightly"})
+	require.ErrorIs(t, err, ErrInvalidInput)
+	past := time.Now().Add(-time.Minute)
+	_, _, err = svc.Create(ctx, CreateParams{Name: "nightly", Permissions: []string{"rules:read"}, ExpiresAt: &past})
+	require.ErrorIs(t, err, ErrInvalidInput)
+
+	expires := time.Now().Add(time.Hour)
+	_, plaintext, err := svc.Create(ctx, CreateParams{Name: "nightly", Permissions: []string{"rules:read"}, ExpiresAt: &expires})
+	require.NoError(t, err)
+	_, err = svc.Authenticate(ctx, plaintext)
+	require.NoError(t, err)
+
+	svc.now = func() time.Time { return expires.Add(time.Second) }
+	_, err = svc.Authenticate(ctx, plaintext)
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
@@ -0,0 +1,120 @@
+// Package telemetry 接入 OpenTelemetry 分布式追踪：为每个 HTTP 请求创建服务端 span，
+// 并向上游透传 W3C traceparent，使网关内部耗时（规则匹配、请求体改写、上游调用）可在链路中逐段拆分。
+// 导出器使用 OTLP/HTTP，端点、请求头、超时与采样等均沿用 OTEL_* 标准环境变量。
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/prehisle/yapi/internal/middleware"
+)
+
+// InstrumentationName 是网关创建 span 时使用的 tracer 名称。
+const InstrumentationName = "github.com/prehisle/yapi"
+
+// Config 描述追踪导出配置。
+type Config struct {
+	// Endpoint 与 TracesEndpoint 对应 OTEL_EXPORTER_OTLP_ENDPOINT 与 OTEL_EXPORTER_OTLP_TRACES_ENDPOINT，
+	// 两者均为空时不导出 span。
+	Endpoint       string
+	TracesEndpoint string
+	// ServiceName 写入 service.name 资源属性。
+	ServiceName string
+	// Disabled 对应 OTEL_SDK_DISABLED，为 true 时即使配置了端点也不导出。
+	Disabled bool
+}
+
+// Enabled 报告是否需要创建导出器。
+func (c Config) Enabled() bool {
+	return !c.Disabled && (c.Endpoint != "" || c.TracesEndpoint != "")
+}
+
+// Setup 安装全局传播器，并在配置了 OTLP 端点时安装导出 span 的全局 TracerProvider。
+// 未启用导出时仍会透传入站请求携带的 traceparent，返回的 shutdown 为空操作。
+// 调用方应在退出前调用 shutdown 以刷新缓冲中的 span。
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !cfg.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+	// 端点、请求头、TLS 等由导出器自行读取 OTEL_EXPORTER_OTLP_* 环境变量。
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("create otlp trace exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("build otel resource: %w", err)
+	}
+	// 采样器未显式指定，SDK 会按 OTEL_TRACES_SAMPLER 与 OTEL_TRACES_SAMPLER_ARG 选择，默认 parentbased_always_on。
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer 返回网关使用的 tracer。它始终委托给当前的全局 TracerProvider。
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// Middleware 为每个请求创建服务端 span，并把携带 span 的上下文写回请求，供后续处理器创建子 span。
+// span 名称为“方法 路由模板”，未命中路由（即代理请求）时仅为方法名。
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		route := c.FullPath()
+		name := c.Request.Method
+		attrs := []attribute.KeyValue{
+			semconv.HTTPRequestMethodKey.String(c.Request.Method),
+			semconv.URLPath(c.Request.URL.Path),
+		}
+		if route != "" {
+			name += " " + route
+			attrs = append(attrs, semconv.HTTPRoute(route))
+		}
+		ctx, span := Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if requestID := middleware.RequestIDFromContext(c); requestID != "" {
+			span.SetAttributes(attribute.String("yapi.request_id", requestID))
+		}
+	}
+}
+
+// InjectHeaders 把上下文中的 span 以 traceparent 等请求头写入出站请求。
+func InjectHeaders(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// RecordError 把错误记录到 span 并标记失败状态。
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,61 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func installRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	previous := otel.GetTracerProvider()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	_, err := Setup(context.Background(), Config{})
+	require.NoError(t, err)
+	return recorder
+}
+
+func TestMiddleware_ContinuesIncomingTrace(t *testing.T) {
+	recorder := installRecorder(t)
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/admin/rules/:id", func(c *gin.Context) {
+		require.True(t, trace.SpanContextFromContext(c.Request.Context()).IsValid())
+		c.Status(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/rules/r-1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	require.Equal(t, "GET /admin/rules/:id", span.Name())
+	require.Equal(t, trace.SpanKindServer, span.SpanKind())
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	require.Contains(t, span.Attributes(), attribute.String("http.route", "/admin/rules/:id"))
+	require.Contains(t, span.Attributes(), attribute.Int("http.response.status_code", http.StatusInternalServerError))
+	require.Equal(t, codes.Error, span.Status().Code)
+}
+
+func TestConfig_Enabled(t *testing.T) {
+	require.False(t, Config{}.Enabled())
+	require.True(t, Config{Endpoint: "http://collector:4318"}.Enabled())
+	require.True(t, Config{TracesEndpoint: "http://collector:4318/v1/traces"}.Enabled())
+	require.False(t, Config{Endpoint: "http://collector:4318", Disabled: true}.Enabled())
+}
@@ -0,0 +1,192 @@
+// Package tlsconfig 为网关监听端口构建 TLS 配置，使网关无需前置负载均衡即可直接提供 HTTPS。
+// 证书来源二选一：磁盘上的证书/私钥文件（轮换后自动重新加载），或通过 ACME（Let's Encrypt）自动签发与续期。
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ErrInvalidConfig 表示 TLS 设置不完整或相互冲突。
+var ErrInvalidConfig = errors.New("invalid tls config")
+
+// Options 描述证书来源，CertFile/KeyFile 与 ACMEDomains 只能设置其一，均为空时不启用 TLS。
+type Options struct {
+	// CertFile、KeyFile 为 PEM 格式的证书链与私钥路径。
+	CertFile string
+	KeyFile  string
+	// ReloadInterval 为检查证书文件是否更新的间隔，默认 1 分钟。
+	ReloadInterval time.Duration
+	// ACMEDomains 为通过 ACME 签发证书的域名，仅为这些域名申请证书。
+	ACMEDomains []string
+	// ACMEEmail 为 ACME 账号联系邮箱，可选。
+	ACMEEmail string
+	// ACMECacheDir 保存账号密钥与已签发的证书，重启后复用，默认 acme-cache。
+	ACMECacheDir string
+	// ACMEDirectoryURL 为 ACME 目录地址，默认 Let's Encrypt 生产环境，测试时可改为 staging 地址。
+	ACMEDirectoryURL string
+	Logger           *slog.Logger
+}
+
+// Enabled 报告是否配置了证书来源。
+func (o Options) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != "" || len(o.ACMEDomains) > 0
+}
+
+// New 根据 Options 构建 TLS 配置，未启用时返回 nil。使用证书文件时在后台按 ReloadInterval 检查文件变化直到 ctx 结束；
+// 使用 ACME 时通过 TLS-ALPN-01 完成域名验证，要求域名的 443 端口指向网关监听地址。
+func New(ctx context.Context, opts Options) (*tls.Config, error) {
+	if !opts.Enabled() {
+		return nil, nil
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if len(opts.ACMEDomains) > 0 {
+		if opts.CertFile != "" || opts.KeyFile != "" {
+			return nil, fmt.Errorf("%w: TLS_CERT_FILE/TLS_KEY_FILE and TLS_ACME_DOMAINS are mutually exclusive", ErrInvalidConfig)
+		}
+		return acmeConfig(opts), nil
+	}
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, fmt.Errorf("%w: TLS_CERT_FILE and TLS_KEY_FILE must be set together", ErrInvalidConfig)
+	}
+	reloader, err := NewCertReloader(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	interval := opts.ReloadInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	reloader.Watch(ctx, interval, opts.Logger)
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}, nil
+}
+
+func acmeConfig(opts Options) *tls.Config {
+	cacheDir := opts.ACMECacheDir
+	if cacheDir == "" {
+		cacheDir = "acme-cache"
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(opts.ACMEDomains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      opts.ACMEEmail,
+	}
+	if opts.ACMEDirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: opts.ACMEDirectoryURL}
+	}
+	config := manager.TLSConfig()
+	config.MinVersion = tls.VersionTLS12
+	return config
+}
+
+// CertReloader 从磁盘加载证书，并在证书或私钥文件的修改时间变化后重新加载，
+// 适用于 cert-manager、certbot 等外部工具原地轮换证书的场景。
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime [2]time.Time
+}
+
+// NewCertReloader 加载证书与私钥，文件缺失或不匹配时返回错误。
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate 返回当前证书，用作 tls.Config.GetCertificate。
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload 在文件修改时间变化时重新加载证书，返回是否加载了新证书。加载失败时继续使用原证书。
+func (r *CertReloader) Reload() (bool, error) {
+	modTime, err := r.stat()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	unchanged := r.cert != nil && modTime == r.modTime
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("%w: load certificate %s: %v", ErrInvalidConfig, r.certFile, err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return false, fmt.Errorf("%w: parse certificate %s: %v", ErrInvalidConfig, r.certFile, err)
+		}
+	}
+	r.mu.Lock()
+	r.cert, r.modTime = &cert, modTime
+	r.mu.Unlock()
+	return true, nil
+}
+
+// NotAfter 返回当前证书的过期时间。
+func (r *CertReloader) NotAfter() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert.Leaf.NotAfter
+}
+
+// Watch 每隔 interval 检查一次证书文件，直到 ctx 结束。
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reloaded, err := r.Reload()
+				if err != nil {
+					logger.Error("tls certificate reload failed", "cert_file", r.certFile, "error", err)
+					continue
+				}
+				if reloaded {
+					logger.Info("tls certificate reloaded", "cert_file", r.certFile, "not_after", r.NotAfter())
+				}
+			}
+		}
+	}()
+}
+
+func (r *CertReloader) stat() ([2]time.Time, error) {
+	var modTime [2]time.Time
+	for i, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return modTime, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+		modTime[i] = info.ModTime()
+	}
+	return modTime, nil
+}
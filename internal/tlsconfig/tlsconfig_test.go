@@ -0,0 +1,110 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate 写出自签名证书与私钥，并把文件修改时间设为 modTime。
+func writeCertificate(t *testing.T, dir string, serial int64, modTime time.Time) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "gateway.example.com"},
+		DNSNames:     []string{"gateway.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+	return certFile, keyFile
+}
+
+func TestCertReloader_ReloadsRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Minute)
+	certFile, keyFile := writeCertificate(t, dir, 1, base)
+	reloader, err := NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	cert, err := reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, cert.Leaf.SerialNumber.Int64())
+
+	reloaded, err := reloader.Reload()
+	require.NoError(t, err)
+	require.False(t, reloaded)
+
+	writeCertificate(t, dir, 2, base.Add(time.Second))
+	reloaded, err = reloader.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	cert, err = reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, cert.Leaf.SerialNumber.Int64())
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0o600))
+	_, err = reloader.Reload()
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	cert, err = reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, cert.Leaf.SerialNumber.Int64(), "a broken rotation keeps serving the previous certificate")
+}
+
+func TestNew_ServesCertificateFiles(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir(), 7, time.Now())
+	config, err := New(t.Context(), Options{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.TLS = config
+	server.StartTLS()
+	defer server.Close()
+
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true, ServerName: "gateway.example.com"})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.EqualValues(t, 7, conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64())
+}
+
+func TestNew_ValidatesOptions(t *testing.T) {
+	config, err := New(t.Context(), Options{})
+	require.NoError(t, err)
+	require.Nil(t, config)
+
+	_, err = New(t.Context(), Options{CertFile: "tls.crt"})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	_, err = New(t.Context(), Options{CertFile: "tls.crt", KeyFile: "tls.key", ACMEDomains: []string{"gateway.example.com"}})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	_, err = New(t.Context(), Options{CertFile: filepath.Join(t.TempDir(), "missing.crt"), KeyFile: "tls.key"})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+
+	config, err = New(t.Context(), Options{ACMEDomains: []string{"gateway.example.com"}, ACMECacheDir: t.TempDir()})
+	require.NoError(t, err)
+	require.Contains(t, config.NextProtos, "acme-tls/1")
+	require.NotNil(t, config.GetCertificate)
+}
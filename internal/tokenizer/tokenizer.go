@@ -0,0 +1,229 @@
+// Package tokenizer 在转发前估算请求的提示词 token 数，供代理的 max_prompt_tokens 预检与 X-Estimated-Tokens 响应头使用。
+//
+// 加载 tiktoken 格式的 BPE 词表（如 cl100k_base.tiktoken，每行为 base64 编码的 token 与其合并序号）时，
+// 按 cl100k 的预分词规则切分文本后执行字节对合并，结果与 tiktoken 一致（不处理特殊 token）；
+// 未加载词表时按预分词结果近似估算：ASCII 片段约每 5 字节 1 个 token，其他字符每字符 1 个 token。
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 聊天请求的格式开销，与 OpenAI 对 gpt-3.5-turbo / gpt-4 系列的计数方式一致：
+// 每条消息额外 3 个 token，回复前缀额外 3 个 token。
+const (
+	messageOverhead = 3
+	replyPriming    = 3
+)
+
+// piecePattern 是 cl100k 预分词正则去掉 \s+(?!\S) 分支后的部分，该分支依赖 Go 不支持的零宽断言，由 split 单独处理。
+var piecePattern = regexp.MustCompile(`^(?:(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+)`)
+
+// textKeys 是承载提示词文本的 JSON 字段，覆盖 OpenAI、Anthropic 与 Gemini 的消息格式。
+var textKeys = map[string]bool{"content": true, "text": true, "role": true, "name": true}
+
+// Tokenizer 估算文本的 token 数。零值按近似规则估算。
+type Tokenizer struct {
+	ranks map[string]int
+}
+
+// New 创建按近似规则估算的 Tokenizer。
+func New() *Tokenizer {
+	return &Tokenizer{}
+}
+
+// Load 从 tiktoken 格式的词表文件创建 Tokenizer。
+func Load(path string) (*Tokenizer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+// Parse 解析 tiktoken 格式的词表。
+func Parse(r io.Reader) (*Tokenizer, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		encoded, rank, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"<base64 token> <rank>\"", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		value, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ranks[string(token)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("empty vocabulary")
+	}
+	return &Tokenizer{ranks: ranks}, nil
+}
+
+// Exact 报告是否加载了词表。
+func (t *Tokenizer) Exact() bool {
+	return len(t.ranks) > 0
+}
+
+// Count 返回文本的 token 数。
+func (t *Tokenizer) Count(text string) int {
+	total := 0
+	for _, piece := range split(text) {
+		if t.Exact() {
+			total += t.merge(piece)
+		} else {
+			total += approximate(piece)
+		}
+	}
+	return total
+}
+
+// CountRequest 估算 JSON 请求体的提示词 token 数：messages（OpenAI、Anthropic）与 contents（Gemini）按消息计入格式开销，
+// system、prompt、input 与 systemInstruction 按文本计数，tools 按序列化后的 JSON 计数。
+// body 不是 JSON 对象或不含提示词字段时返回 false。
+func (t *Tokenizer) CountRequest(body []byte) (int, bool) {
+	var doc map[string]any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return 0, false
+	}
+	total, found := 0, false
+	for _, key := range []string{"messages", "contents"} {
+		items, ok := doc[key].([]any)
+		if !ok {
+			continue
+		}
+		found = true
+		total += replyPriming
+		for _, item := range items {
+			total += messageOverhead + t.countText(item, false)
+		}
+	}
+	for _, key := range []string{"system", "prompt", "input", "systemInstruction"} {
+		if value, ok := doc[key]; ok {
+			found = true
+			total += t.countText(value, true)
+		}
+	}
+	if tools, ok := doc["tools"]; ok {
+		if raw, err := json.Marshal(tools); err == nil {
+			total += t.Count(string(raw))
+		}
+	}
+	return total, found
+}
+
+// countText 累加 textKeys 字段下字符串的 token 数：数组继承父字段的判断，对象内的每个字段重新判断，
+// 因此图片等内容块中的 base64 数据不计入。
+func (t *Tokenizer) countText(value any, take bool) int {
+	total := 0
+	switch typed := value.(type) {
+	case string:
+		if take {
+			total += t.Count(typed)
+		}
+	case []any:
+		for _, child := range typed {
+			total += t.countText(child, take)
+		}
+	case map[string]any:
+		for key, child := range typed {
+			total += t.countText(child, textKeys[key])
+		}
+	}
+	return total
+}
+
+// split 按 cl100k 规则预分词。连续空白后跟非空白字符时，最后一个空白字符单独成段，与 \s+(?!\S) 的效果一致。
+func split(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		if loc := piecePattern.FindStringIndex(text); loc != nil && loc[1] > 0 {
+			pieces = append(pieces, text[:loc[1]])
+			text = text[loc[1]:]
+			continue
+		}
+		end, last := 0, 0
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			last = end
+			end += size
+		}
+		if end == 0 {
+			// 无法识别的字节（如非法 UTF-8）单独成段。
+			_, end = utf8.DecodeRuneInString(text)
+		} else if end < len(text) && last > 0 {
+			end = last
+		}
+		pieces = append(pieces, text[:end])
+		text = text[end:]
+	}
+	return pieces
+}
+
+// merge 对一个预分词片段执行字节对合并，返回合并后的 token 数。
+func (t *Tokenizer) merge(piece string) int {
+	if _, ok := t.ranks[piece]; ok {
+		return 1
+	}
+	// bounds 是各 token 的起始偏移，末尾追加片段长度作为哨兵。
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := t.ranks[piece[bounds[i]:bounds[i+2]]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+	return len(bounds) - 1
+}
+
+// approximate 在没有词表时估算片段的 token 数。
+func approximate(piece string) int {
+	ascii, other := 0, 0
+	for _, r := range piece {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+4)/5 + other
+}
@@ -0,0 +1,54 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit_MatchesCL100KPreTokenizer(t *testing.T) {
+	require.Equal(t, []string{"Hello", " world"}, split("Hello world"))
+	require.Equal(t, []string{"a", "  ", " b"}, split("a   b"))
+	require.Equal(t, []string{"I", "'m", " ", "123", "45", "!\n\n", "ok", "  "}, split("I'm 12345!\n\nok  "))
+	require.Equal(t, []string{"你好", "，世界"}, split("你好，世界"))
+}
+
+func TestParse_MergesByRank(t *testing.T) {
+	var vocab strings.Builder
+	for rank, token := range []string{"a", "b", "c", "ab", "bc", "abc"} {
+		fmt.Fprintf(&vocab, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+	}
+	tok, err := Parse(strings.NewReader(vocab.String()))
+	require.NoError(t, err)
+	require.True(t, tok.Exact())
+	require.Equal(t, 1, tok.Count("abc"))
+	require.Equal(t, 2, tok.Count("abab"))
+	require.Equal(t, 3, tok.Count("cba"))
+
+	_, err = Parse(strings.NewReader("YQ==\n"))
+	require.Error(t, err)
+	_, err = Parse(strings.NewReader(""))
+	require.Error(t, err)
+}
+
+func TestCountRequest(t *testing.T) {
+	tok := New()
+	require.False(t, tok.Exact())
+
+	count, ok := tok.CountRequest([]byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hello"}]}`))
+	require.True(t, ok)
+	require.Equal(t, replyPriming+messageOverhead+2, count)
+
+	image := `{"type":"image","source":{"type":"base64","data":"` + strings.Repeat("A", 4000) + `"}}`
+	count, ok = tok.CountRequest([]byte(`{"system":"be brief","messages":[{"role":"user","content":[{"type":"text","text":"hello"},` + image + `]}]}`))
+	require.True(t, ok)
+	require.Equal(t, tok.Count("be brief")+replyPriming+messageOverhead+2, count)
+
+	_, ok = tok.CountRequest([]byte(`{"model":"gpt-4o"}`))
+	require.False(t, ok)
+	_, ok = tok.CountRequest([]byte(`not json`))
+	require.False(t, ok)
+}
@@ -0,0 +1,150 @@
+package traceexport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultLangfuseHost 是未配置 LANGFUSE_HOST 时使用的 Langfuse Cloud 地址。
+const DefaultLangfuseHost = "https://cloud.langfuse.com"
+
+// Langfuse 通过 Ingestion API（POST /api/public/ingestion）导出记录：每条记录写成一个 trace 与其下的一个 generation，
+// trace ID 为记录 ID，userId 为网关用户 ID。
+type Langfuse struct {
+	client    *http.Client
+	url       string
+	publicKey string
+	secretKey string
+}
+
+// NewLangfuse 创建 Langfuse 导出器，host 为空时使用 Langfuse Cloud；client 为 nil 时使用 http.DefaultClient。
+func NewLangfuse(host, publicKey, secretKey string, client *http.Client) *Langfuse {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if host == "" {
+		host = DefaultLangfuseHost
+	}
+	return &Langfuse{
+		client:    client,
+		url:       strings.TrimRight(host, "/") + "/api/public/ingestion",
+		publicKey: publicKey,
+		secretKey: secretKey,
+	}
+}
+
+type langfuseEvent struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Timestamp string `json:"timestamp"`
+	Body      any    `json:"body"`
+}
+
+// Export 以一个批次写入全部记录。Ingestion API 对部分失败返回 207，errors 非空时视为导出失败。
+func (l *Langfuse) Export(ctx context.Context, records []Record) error {
+	batch := make([]langfuseEvent, 0, 2*len(records))
+	for _, r := range records {
+		batch = append(batch, langfuseEvent{
+			ID:        uuid.NewString(),
+			Type:      "trace-create",
+			Timestamp: timestamp(r.Start),
+			Body: map[string]any{
+				"id":        r.ID,
+				"timestamp": timestamp(r.Start),
+				"name":      r.name(),
+				"userId":    r.UserID,
+				"input":     jsonValue(r.Input),
+				"output":    jsonValue(r.Output),
+				"metadata":  r.metadata(),
+				"tags":      []string{"yapi"},
+			},
+		})
+		generation := map[string]any{
+			"id":        r.ID + "-generation",
+			"traceId":   r.ID,
+			"name":      r.name(),
+			"startTime": timestamp(r.Start),
+			"endTime":   timestamp(r.End),
+			"model":     r.Model,
+			"input":     jsonValue(r.Input),
+			"output":    jsonValue(r.Output),
+			"metadata":  r.metadata(),
+			"level":     "DEFAULT",
+		}
+		if r.PromptTokens > 0 || r.CompletionTokens > 0 {
+			generation["usageDetails"] = map[string]int64{
+				"input":  r.PromptTokens,
+				"output": r.CompletionTokens,
+				"total":  r.PromptTokens + r.CompletionTokens,
+			}
+		}
+		if r.CostUSD > 0 {
+			generation["costDetails"] = map[string]float64{"total": r.CostUSD}
+		}
+		if r.Failed() {
+			generation["level"] = "ERROR"
+			generation["statusMessage"] = fmt.Sprintf("upstream status %d", r.Status)
+		}
+		batch = append(batch, langfuseEvent{
+			ID:        uuid.NewString(),
+			Type:      "generation-create",
+			Timestamp: timestamp(r.End),
+			Body:      generation,
+		})
+	}
+	body, err := json.Marshal(map[string]any{"batch": batch})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(l.publicKey, l.secretKey)
+	respBody, err := send(l.client, req)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Errors []struct {
+			ID      string `json:"id"`
+			Status  int    `json:"status"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(respBody, &result) == nil && len(result.Errors) > 0 {
+		first := result.Errors[0]
+		return fmt.Errorf("langfuse rejected %d events: status %d: %s", len(result.Errors), first.Status, first.Message)
+	}
+	return nil
+}
+
+// send 执行请求并读取响应体，非 2xx 响应返回错误。
+func send(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s returned status %d: %s", req.URL.Host, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,105 @@
+package traceexport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DefaultLangSmithEndpoint 是未配置 LANGSMITH_ENDPOINT 时使用的 LangSmith 地址。
+const DefaultLangSmithEndpoint = "https://api.smith.langchain.com"
+
+// LangSmith 通过批量写入接口（POST /runs/batch）导出记录：每条记录写成一个 run_type 为 llm 的根 run，
+// run ID 与 trace ID 均为记录 ID（须为 UUID），写入 project 对应的项目。
+type LangSmith struct {
+	client  *http.Client
+	url     string
+	apiKey  string
+	project string
+}
+
+// NewLangSmith 创建 LangSmith 导出器，endpoint 为空时使用 LangSmith 官方地址，project 为空时写入 default 项目；
+// client 为 nil 时使用 http.DefaultClient。
+func NewLangSmith(endpoint, apiKey, project string, client *http.Client) *LangSmith {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if endpoint == "" {
+		endpoint = DefaultLangSmithEndpoint
+	}
+	if project == "" {
+		project = "default"
+	}
+	return &LangSmith{
+		client:  client,
+		url:     strings.TrimRight(endpoint, "/") + "/runs/batch",
+		apiKey:  apiKey,
+		project: project,
+	}
+}
+
+// Export 以一个批次创建全部 run。
+func (l *LangSmith) Export(ctx context.Context, records []Record) error {
+	runs := make([]map[string]any, 0, len(records))
+	for _, r := range records {
+		outputs := object(r.Output, "output")
+		if r.PromptTokens > 0 || r.CompletionTokens > 0 {
+			outputs["usage_metadata"] = map[string]int64{
+				"input_tokens":  r.PromptTokens,
+				"output_tokens": r.CompletionTokens,
+				"total_tokens":  r.PromptTokens + r.CompletionTokens,
+			}
+		}
+		metadata := r.metadata()
+		metadata["user_id"] = r.UserID
+		if r.Model != "" {
+			metadata["ls_model_name"] = r.Model
+		}
+		run := map[string]any{
+			"id":           r.ID,
+			"trace_id":     r.ID,
+			"dotted_order": r.Start.UTC().Format("20060102T150405.000000Z") + r.ID,
+			"name":         r.name(),
+			"run_type":     "llm",
+			"start_time":   timestamp(r.Start),
+			"end_time":     timestamp(r.End),
+			"inputs":       object(r.Input, "input"),
+			"outputs":      outputs,
+			"session_name": l.project,
+			"extra":        map[string]any{"metadata": metadata},
+			"tags":         []string{"yapi"},
+		}
+		if r.Failed() {
+			run["error"] = fmt.Sprintf("upstream status %d", r.Status)
+		}
+		runs = append(runs, run)
+	}
+	body, err := json.Marshal(map[string]any{"post": runs})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", l.apiKey)
+	_, err = send(l.client, req)
+	return err
+}
+
+// object 把 JSON 对象解码为 map；其余内容（如 SSE 流或 JSON 数组）放在 key 下，LangSmith 要求 inputs 与 outputs 为对象。
+func object(s, key string) map[string]any {
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err == nil && m != nil {
+		return m
+	}
+	m = map[string]any{}
+	if value := jsonValue(s); value != nil {
+		m[key] = value
+	}
+	return m
+}
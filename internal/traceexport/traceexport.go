@@ -0,0 +1,250 @@
+// Package traceexport 把代理转发的提示词与补全（已脱敏）异步导出到 Langfuse 或 LangSmith，
+// 供已使用这些平台做评估与调试的团队查看网关流量。记录先进入有界队列，由后台协程按平台分批导出；
+// 队列满时丢弃新记录，导出失败只记录日志，均不阻塞或影响请求转发。
+package traceexport
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// 支持的导出平台，与规则动作 trace_export.provider 的取值一致。
+const (
+	ProviderLangfuse  = "langfuse"
+	ProviderLangSmith = "langsmith"
+)
+
+// UserMetadataKey 是用户元数据中为该用户的请求开启导出的键，取值为平台名称；规则配置了 trace_export 时以规则为准。
+const UserMetadataKey = "trace_export"
+
+// maxResponseBytes 限制读取的平台响应大小。
+const maxResponseBytes = 1 << 20
+
+// Record 是一次上游调用的导出内容。Input 与 Output 为脱敏后的请求体与响应体（客户端协议），
+// 是合法 JSON 时按 JSON 导出，否则（如 SSE 流）按文本导出。
+type Record struct {
+	ID               string
+	RequestID        string
+	RuleID           string
+	UserID           string
+	APIKeyID         string
+	Method           string
+	Path             string
+	Model            string
+	Input            string
+	Output           string
+	Status           int
+	PromptTokens     int64
+	CompletionTokens int64
+	CostUSD          float64
+	Truncated        bool
+	Start            time.Time
+	End              time.Time
+}
+
+// Failed 报告上游调用是否失败。
+func (r Record) Failed() bool {
+	return r.Status == 0 || r.Status >= 400
+}
+
+// name 是记录在平台中显示的名称，优先使用规则 ID。
+func (r Record) name() string {
+	if r.RuleID != "" {
+		return r.RuleID
+	}
+	return r.Method + " " + r.Path
+}
+
+// metadata 是附加到平台记录上的网关维度。
+func (r Record) metadata() map[string]any {
+	m := map[string]any{
+		"request_id":  r.RequestID,
+		"rule_id":     r.RuleID,
+		"path":        r.Path,
+		"status_code": r.Status,
+	}
+	if r.APIKeyID != "" {
+		m["api_key_id"] = r.APIKeyID
+	}
+	if r.CostUSD > 0 {
+		m["cost_usd"] = r.CostUSD
+	}
+	if r.Truncated {
+		m["truncated"] = true
+	}
+	return m
+}
+
+// jsonValue 把合法 JSON 原样嵌入，其余内容按字符串导出。
+func jsonValue(s string) any {
+	if s == "" {
+		return nil
+	}
+	if json.Valid([]byte(s)) {
+		return json.RawMessage(s)
+	}
+	return s
+}
+
+// Exporter 把一批记录写入外部平台。
+type Exporter interface {
+	Export(ctx context.Context, records []Record) error
+}
+
+// Options 控制批量导出行为，零值字段取默认值。
+type Options struct {
+	// QueueSize 为待导出记录的队列容量，默认 1000，队列满时丢弃新记录。
+	QueueSize int
+	// BatchSize 为单次导出的最大记录数，默认 50。
+	BatchSize int
+	// FlushInterval 为未攒满一批时的最长等待时间，默认 5 秒。
+	FlushInterval time.Duration
+	// ExportTimeout 为单次导出的超时，默认 10 秒。
+	ExportTimeout time.Duration
+	Logger        *slog.Logger
+}
+
+func (o Options) withDefaults() Options {
+	if o.QueueSize <= 0 {
+		o.QueueSize = 1000
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 50
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = 5 * time.Second
+	}
+	if o.ExportTimeout <= 0 {
+		o.ExportTimeout = 10 * time.Second
+	}
+	if o.Logger == nil {
+		o.Logger = slog.Default()
+	}
+	return o
+}
+
+type item struct {
+	provider string
+	record   Record
+}
+
+// Service 按平台缓冲并批量导出记录，可被多个请求协程并发调用 Enqueue。
+type Service struct {
+	exporters map[string]Exporter
+	opts      Options
+	queue     chan item
+	stop      chan struct{}
+	done      chan struct{}
+	closed    atomic.Bool
+	once      sync.Once
+}
+
+// NewService 以平台名称为键创建导出服务并启动后台协程，只有 exporters 中的平台可以接收记录；
+// 调用方需在退出前调用 Close 导出剩余记录。
+func NewService(exporters map[string]Exporter, opts Options) *Service {
+	opts = opts.withDefaults()
+	s := &Service{
+		exporters: exporters,
+		opts:      opts,
+		queue:     make(chan item, opts.QueueSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Enabled 报告是否配置了 provider 的凭据。
+func (s *Service) Enabled(provider string) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := s.exporters[provider]
+	return ok
+}
+
+// Enqueue 把记录放入导出队列，不会阻塞。平台未配置时忽略记录，队列已满或服务已关闭时丢弃记录。
+func (s *Service) Enqueue(provider string, record Record) {
+	if !s.Enabled(provider) {
+		return
+	}
+	if s.closed.Load() {
+		metrics.ObserveTraceExport(provider, "dropped", 1)
+		return
+	}
+	select {
+	case s.queue <- item{provider: provider, record: record}:
+	default:
+		metrics.ObserveTraceExport(provider, "dropped", 1)
+	}
+}
+
+// Close 停止接收记录，导出队列中剩余的记录后返回；ctx 到期时放弃剩余记录。
+func (s *Service) Close(ctx context.Context) error {
+	s.once.Do(func() {
+		s.closed.Store(true)
+		close(s.stop)
+	})
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Service) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+	pending := make(map[string][]Record, len(s.exporters))
+	add := func(it item) {
+		pending[it.provider] = append(pending[it.provider], it.record)
+		if len(pending[it.provider]) >= s.opts.BatchSize {
+			s.export(it.provider, pending[it.provider])
+			delete(pending, it.provider)
+		}
+	}
+	flush := func() {
+		for provider, records := range pending {
+			s.export(provider, records)
+			delete(pending, provider)
+		}
+	}
+	for {
+		select {
+		case it := <-s.queue:
+			add(it)
+		case <-ticker.C:
+			flush()
+		case <-s.stop:
+			for {
+				select {
+				case it := <-s.queue:
+					add(it)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// export 导出一批记录，失败时记录日志后丢弃，不做重试。
+func (s *Service) export(provider string, records []Record) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.ExportTimeout)
+	defer cancel()
+	if err := s.exporters[provider].Export(ctx, records); err != nil {
+		metrics.ObserveTraceExport(provider, "error", len(records))
+		s.opts.Logger.Warn("trace export failed", "provider", provider, "records", len(records), "error", err)
+		return
+	}
+	metrics.ObserveTraceExport(provider, "success", len(records))
+}
@@ -0,0 +1,159 @@
+package traceexport
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testRecord() Record {
+	start := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	return Record{
+		ID:               "6f1c1a52-3b0e-4a55-9f44-3b2a8d0f6c11",
+		RequestID:        "req-1",
+		RuleID:           "chat",
+		UserID:           "alice",
+		Method:           http.MethodPost,
+		Path:             "/v1/chat/completions",
+		Model:            "gpt-4o",
+		Input:            `{"model":"gpt-4o","messages":[{"role":"user","content":"mail [REDACTED]"}]}`,
+		Output:           `{"choices":[{"message":{"content":"ok"}}]}`,
+		Status:           http.StatusOK,
+		PromptTokens:     12,
+		CompletionTokens: 3,
+		CostUSD:          0.01,
+		Start:            start,
+		End:              start.Add(time.Second),
+	}
+}
+
+func capture(t *testing.T, status int, reply string) (*httptest.Server, func() (*http.Request, map[string]any)) {
+	t.Helper()
+	var (
+		mu      sync.Mutex
+		request *http.Request
+		payload map[string]any
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		request = r
+		require.NoError(t, json.Unmarshal(body, &payload))
+		mu.Unlock()
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(reply))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() (*http.Request, map[string]any) {
+		mu.Lock()
+		defer mu.Unlock()
+		return request, payload
+	}
+}
+
+func TestLangfuse_Export(t *testing.T) {
+	server, received := capture(t, http.StatusMultiStatus, `{"successes":[],"errors":[]}`)
+	exporter := NewLangfuse(server.URL+"/", "pk-lf", "sk-lf", server.Client())
+	require.NoError(t, exporter.Export(context.Background(), []Record{testRecord()}))
+
+	req, payload := received()
+	require.Equal(t, "/api/public/ingestion", req.URL.Path)
+	user, pass, ok := req.BasicAuth()
+	require.True(t, ok)
+	require.Equal(t, "pk-lf", user)
+	require.Equal(t, "sk-lf", pass)
+
+	batch := payload["batch"].([]any)
+	require.Len(t, batch, 2)
+	trace := batch[0].(map[string]any)
+	require.Equal(t, "trace-create", trace["type"])
+	traceBody := trace["body"].(map[string]any)
+	require.Equal(t, testRecord().ID, traceBody["id"])
+	require.Equal(t, "alice", traceBody["userId"])
+	require.Equal(t, "chat", traceBody["name"])
+
+	generation := batch[1].(map[string]any)
+	require.Equal(t, "generation-create", generation["type"])
+	body := generation["body"].(map[string]any)
+	require.Equal(t, testRecord().ID, body["traceId"])
+	require.Equal(t, "gpt-4o", body["model"])
+	require.Equal(t, "mail [REDACTED]", body["input"].(map[string]any)["messages"].([]any)[0].(map[string]any)["content"])
+	require.Equal(t, map[string]any{"input": 12.0, "output": 3.0, "total": 15.0}, body["usageDetails"])
+	require.Equal(t, "DEFAULT", body["level"])
+
+	server, _ = capture(t, http.StatusMultiStatus, `{"successes":[],"errors":[{"id":"x","status":400,"message":"invalid body"}]}`)
+	err := NewLangfuse(server.URL, "pk", "sk", server.Client()).Export(context.Background(), []Record{testRecord()})
+	require.ErrorContains(t, err, "invalid body")
+}
+
+func TestLangSmith_Export(t *testing.T) {
+	server, received := capture(t, http.StatusAccepted, `{}`)
+	record := testRecord()
+	record.Output = "data: {\"choices\":[]}\n\n"
+	record.Status = http.StatusBadGateway
+	exporter := NewLangSmith(server.URL, "ls-key", "", server.Client())
+	require.NoError(t, exporter.Export(context.Background(), []Record{record}))
+
+	req, payload := received()
+	require.Equal(t, "/runs/batch", req.URL.Path)
+	require.Equal(t, "ls-key", req.Header.Get("X-API-Key"))
+
+	runs := payload["post"].([]any)
+	require.Len(t, runs, 1)
+	run := runs[0].(map[string]any)
+	require.Equal(t, record.ID, run["id"])
+	require.Equal(t, record.ID, run["trace_id"])
+	require.Equal(t, "20250301T080000.000000Z"+record.ID, run["dotted_order"])
+	require.Equal(t, "llm", run["run_type"])
+	require.Equal(t, "default", run["session_name"])
+	require.Equal(t, "gpt-4o", run["inputs"].(map[string]any)["model"])
+	outputs := run["outputs"].(map[string]any)
+	require.Equal(t, record.Output, outputs["output"])
+	require.Equal(t, 15.0, outputs["usage_metadata"].(map[string]any)["total_tokens"])
+	require.Equal(t, "upstream status 502", run["error"])
+	require.Equal(t, "alice", run["extra"].(map[string]any)["metadata"].(map[string]any)["user_id"])
+
+	server, _ = capture(t, http.StatusUnauthorized, `{"detail":"invalid key"}`)
+	err := NewLangSmith(server.URL, "bad", "p", server.Client()).Export(context.Background(), []Record{record})
+	require.ErrorContains(t, err, "status 401")
+}
+
+type exporterStub struct {
+	mu      sync.Mutex
+	batches [][]Record
+}
+
+func (e *exporterStub) Export(_ context.Context, records []Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.batches = append(e.batches, records)
+	return nil
+}
+
+func TestService_BatchesAndFlushesOnClose(t *testing.T) {
+	stub := &exporterStub{}
+	svc := NewService(map[string]Exporter{ProviderLangfuse: stub}, Options{BatchSize: 2, FlushInterval: time.Hour})
+	require.True(t, svc.Enabled(ProviderLangfuse))
+	require.False(t, svc.Enabled(ProviderLangSmith))
+	svc.Enqueue(ProviderLangSmith, testRecord())
+
+	for i := 0; i < 3; i++ {
+		svc.Enqueue(ProviderLangfuse, testRecord())
+	}
+	require.NoError(t, svc.Close(context.Background()))
+	// 关闭后的记录直接丢弃。
+	svc.Enqueue(ProviderLangfuse, testRecord())
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	require.Len(t, stub.batches, 2)
+	require.Len(t, stub.batches[0], 2)
+	require.Len(t, stub.batches[1], 1)
+}
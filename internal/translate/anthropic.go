@@ -0,0 +1,465 @@
+package translate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// anthropicVersion 是客户端未指定 anthropic-version 时使用的 API 版本。
+	anthropicVersion = "2023-06-01"
+	// defaultMaxTokens 是 OpenAI 请求未指定输出上限时的取值，Anthropic 要求必填。
+	defaultMaxTokens = 4096
+)
+
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	System        string             `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+	Tools         []anthropicTool    `json:"tools,omitempty"`
+	ToolChoice    map[string]any     `json:"tool_choice,omitempty"`
+	Metadata      map[string]string  `json:"metadata,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock 是消息内容块，按 Type 使用不同字段：text、image、tool_use、tool_result。
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Source    *imageSource    `json:"source,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type imageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicResponse struct {
+	ID         string           `json:"id"`
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+}
+
+// promptTokens 返回计入 OpenAI prompt_tokens 的输入 token，包括缓存写入与命中的部分。
+func (u anthropicUsage) promptTokens() int64 {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+type anthropicError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicEvent 是流式响应事件，按 Type 使用不同字段。
+type anthropicEvent struct {
+	Type         string             `json:"type"`
+	Message      *anthropicResponse `json:"message"`
+	Index        int                `json:"index"`
+	ContentBlock *anthropicBlock    `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicTranslator 把 OpenAI Chat Completions 请求转换为 Anthropic Messages 请求，并把响应转换回来。
+type anthropicTranslator struct {
+	includeUsage bool
+}
+
+func (t *anthropicTranslator) Request(req *http.Request) error {
+	if err := requireChatCompletions(req); err != nil {
+		return err
+	}
+	var chat chatRequest
+	if err := readJSON(req, &chat); err != nil {
+		return err
+	}
+	converted, err := toAnthropicRequest(chat)
+	if err != nil {
+		return err
+	}
+	if err := setJSONBody(req, converted); err != nil {
+		return err
+	}
+	t.includeUsage = chat.includeUsage()
+
+	req.URL.Path = strings.TrimSuffix(req.URL.Path, chatCompletionsSuffix) + "/messages"
+	req.URL.RawPath = ""
+	// 上游凭据以 Bearer 令牌注入，始终覆盖 x-api-key：客户端可能以同名请求头携带网关 API Key。
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		if key, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			req.Header.Set("x-api-key", strings.TrimSpace(key))
+		}
+		req.Header.Del("Authorization")
+	}
+	if req.Header.Get("anthropic-version") == "" {
+		req.Header.Set("anthropic-version", anthropicVersion)
+	}
+	return nil
+}
+
+func toAnthropicRequest(chat chatRequest) (anthropicRequest, error) {
+	if chat.N != nil && *chat.N > 1 {
+		return anthropicRequest{}, fmt.Errorf("%w: n > 1 is not supported", ErrInvalidRequest)
+	}
+	out := anthropicRequest{
+		Model:       chat.Model,
+		MaxTokens:   defaultMaxTokens,
+		Temperature: chat.Temperature,
+		TopP:        chat.TopP,
+		Stream:      chat.Stream,
+	}
+	if maxTokens, ok := chat.maxTokens(); ok {
+		out.MaxTokens = maxTokens
+	}
+	stop, err := chat.stopSequences()
+	if err != nil {
+		return anthropicRequest{}, err
+	}
+	out.StopSequences = stop
+	if chat.User != "" {
+		out.Metadata = map[string]string{"user_id": chat.User}
+	}
+
+	var system []string
+	for _, msg := range chat.Messages {
+		if msg.Role == "system" || msg.Role == "developer" {
+			text, err := msg.text()
+			if err != nil {
+				return anthropicRequest{}, err
+			}
+			system = append(system, text)
+			continue
+		}
+		role, blocks, err := toAnthropicBlocks(msg)
+		if err != nil {
+			return anthropicRequest{}, err
+		}
+		// Anthropic 要求 user 与 assistant 交替出现，连续的同角色消息（如多个工具结果）合并为一条。
+		if n := len(out.Messages); n > 0 && out.Messages[n-1].Role == role {
+			out.Messages[n-1].Content = append(out.Messages[n-1].Content, blocks...)
+			continue
+		}
+		out.Messages = append(out.Messages, anthropicMessage{Role: role, Content: blocks})
+	}
+	out.System = strings.Join(system, "\n\n")
+
+	for _, tool := range chat.Tools {
+		if tool.Type != "function" {
+			return anthropicRequest{}, fmt.Errorf("%w: unsupported tool type %q", ErrInvalidRequest, tool.Type)
+		}
+		schema := tool.Function.Parameters
+		if len(schema) == 0 || string(schema) == "null" {
+			schema = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		out.Tools = append(out.Tools, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	choice, err := parseToolChoice(chat.ToolChoice)
+	if err != nil {
+		return anthropicRequest{}, err
+	}
+	switch choice.mode {
+	case "auto":
+		out.ToolChoice = map[string]any{"type": "auto"}
+	case "none":
+		out.ToolChoice = map[string]any{"type": "none"}
+	case "required":
+		out.ToolChoice = map[string]any{"type": "any"}
+	case "function":
+		out.ToolChoice = map[string]any{"type": "tool", "name": choice.name}
+	}
+	if chat.ParallelToolCalls != nil && !*chat.ParallelToolCalls && len(out.Tools) > 0 {
+		if out.ToolChoice == nil {
+			out.ToolChoice = map[string]any{"type": "auto"}
+		}
+		out.ToolChoice["disable_parallel_tool_use"] = true
+	}
+	return out, nil
+}
+
+// toAnthropicBlocks 把一条非 system 消息转换为 Anthropic 角色与内容块，工具结果以 user 角色发送。
+func toAnthropicBlocks(msg chatMessage) (string, []anthropicBlock, error) {
+	switch msg.Role {
+	case "tool":
+		text, err := msg.text()
+		if err != nil {
+			return "", nil, err
+		}
+		return "user", []anthropicBlock{{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: text}}, nil
+	case "user", "assistant":
+	default:
+		return "", nil, fmt.Errorf("%w: unsupported message role %q", ErrInvalidRequest, msg.Role)
+	}
+	parts, err := msg.parts()
+	if err != nil {
+		return "", nil, err
+	}
+	var blocks []anthropicBlock
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			if part.Text != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: part.Text})
+			}
+		case "image_url":
+			if part.ImageURL == nil || part.ImageURL.URL == "" {
+				return "", nil, fmt.Errorf("%w: image_url content part requires a url", ErrInvalidRequest)
+			}
+			blocks = append(blocks, anthropicBlock{Type: "image", Source: toImageSource(part.ImageURL.URL)})
+		default:
+			return "", nil, fmt.Errorf("%w: unsupported content part type %q", ErrInvalidRequest, part.Type)
+		}
+	}
+	for _, call := range msg.ToolCalls {
+		input := json.RawMessage(`{}`)
+		if args := strings.TrimSpace(call.Function.Arguments); args != "" {
+			if !json.Valid([]byte(args)) {
+				return "", nil, fmt.Errorf("%w: tool call %s arguments are not valid JSON", ErrInvalidRequest, call.ID)
+			}
+			input = json.RawMessage(args)
+		}
+		blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: input})
+	}
+	if len(blocks) == 0 {
+		return "", nil, fmt.Errorf("%w: %s message has no content", ErrInvalidRequest, msg.Role)
+	}
+	return msg.Role, blocks, nil
+}
+
+// toImageSource 把 data URL 转换为 base64 图片源，其余 URL 由上游自行下载。
+func toImageSource(url string) *imageSource {
+	if mediaType, data, ok := parseDataURL(url); ok {
+		return &imageSource{Type: "base64", MediaType: mediaType, Data: data}
+	}
+	return &imageSource{Type: "url", URL: url}
+}
+
+func (t *anthropicTranslator) Response(resp *http.Response) error {
+	switch {
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		if !isJSON(resp.Header) {
+			return nil
+		}
+		return rewriteJSONResponse(resp, toChatError)
+	case isEventStream(resp.Header):
+		resp.Body = newAnthropicStream(resp.Body, t.includeUsage)
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+		return nil
+	case isJSON(resp.Header):
+		return rewriteJSONResponse(resp, toChatCompletion)
+	}
+	return nil
+}
+
+func toChatError(body []byte) (any, error) {
+	var upstream anthropicError
+	if err := json.Unmarshal(body, &upstream); err != nil || upstream.Error.Message == "" {
+		return newChatError("upstream_error", strings.TrimSpace(string(body))), nil
+	}
+	return newChatError(upstream.Error.Type, upstream.Error.Message), nil
+}
+
+func toChatCompletion(body []byte) (any, error) {
+	var upstream anthropicResponse
+	if err := json.Unmarshal(body, &upstream); err != nil {
+		return nil, fmt.Errorf("decode anthropic response: %w", err)
+	}
+	message := &chatResponseMessage{Role: "assistant"}
+	var text strings.Builder
+	for _, block := range upstream.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			call := chatToolCall{ID: block.ID, Type: "function"}
+			call.Function.Name = block.Name
+			call.Function.Arguments = compactJSON(block.Input)
+			message.ToolCalls = append(message.ToolCalls, call)
+		}
+	}
+	if text.Len() > 0 || len(message.ToolCalls) == 0 {
+		content := text.String()
+		message.Content = &content
+	}
+	finish := finishReason(upstream.StopReason)
+	return chatCompletion{
+		ID:      upstream.ID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   upstream.Model,
+		Choices: []chatChoice{{Message: message, FinishReason: &finish}},
+		Usage:   newChatUsage(upstream.Usage.promptTokens(), upstream.Usage.OutputTokens),
+	}, nil
+}
+
+// finishReason 把 Anthropic stop_reason 映射为 OpenAI finish_reason。
+func finishReason(stopReason string) string {
+	switch stopReason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+// anthropicStream 把 Anthropic 流式事件转换为 OpenAI chat.completion.chunk 分块。
+type anthropicStream struct {
+	*eventStream
+	includeUsage bool
+	id           string
+	model        string
+	created      int64
+	usage        anthropicUsage
+	// toolIndex 把内容块序号映射为 OpenAI 工具调用序号。
+	toolIndex map[int]int
+	done      bool
+}
+
+func newAnthropicStream(body io.ReadCloser, includeUsage bool) *anthropicStream {
+	s := &anthropicStream{
+		eventStream:  newEventStream(body),
+		includeUsage: includeUsage,
+		created:      time.Now().Unix(),
+		toolIndex:    map[int]int{},
+	}
+	s.onEvent = s.event
+	s.onEnd = s.finish
+	return s
+}
+
+func (s *anthropicStream) chunk(delta chatDelta, finish *string) chatCompletion {
+	return chatCompletion{
+		ID:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []chatChoice{{Delta: &delta, FinishReason: finish}},
+	}
+}
+
+func (s *anthropicStream) event(data []byte) {
+	var event anthropicEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return
+	}
+	switch event.Type {
+	case "message_start":
+		if event.Message != nil {
+			s.id = event.Message.ID
+			s.model = event.Message.Model
+			s.usage = event.Message.Usage
+		}
+		empty := ""
+		s.send(s.chunk(chatDelta{Role: "assistant", Content: &empty}, nil))
+	case "content_block_start":
+		if block := event.ContentBlock; block != nil && block.Type == "tool_use" {
+			index := len(s.toolIndex)
+			s.toolIndex[event.Index] = index
+			call := chatToolCall{Index: &index, ID: block.ID, Type: "function"}
+			call.Function.Name = block.Name
+			s.send(s.chunk(chatDelta{ToolCalls: []chatToolCall{call}}, nil))
+		}
+	case "content_block_delta":
+		switch event.Delta.Type {
+		case "text_delta":
+			text := event.Delta.Text
+			s.send(s.chunk(chatDelta{Content: &text}, nil))
+		case "input_json_delta":
+			index, ok := s.toolIndex[event.Index]
+			if !ok {
+				return
+			}
+			call := chatToolCall{Index: &index}
+			call.Function.Arguments = event.Delta.PartialJSON
+			s.send(s.chunk(chatDelta{ToolCalls: []chatToolCall{call}}, nil))
+		}
+	case "message_delta":
+		if event.Usage != nil {
+			s.usage.OutputTokens = event.Usage.OutputTokens
+		}
+		if event.Delta.StopReason != "" {
+			finish := finishReason(event.Delta.StopReason)
+			s.send(s.chunk(chatDelta{}, &finish))
+		}
+	case "message_stop":
+		s.finish()
+	case "error":
+		if event.Error != nil {
+			s.send(newChatError(event.Error.Type, event.Error.Message))
+		}
+		s.finish()
+	}
+}
+
+// finish 输出用量分块（客户端请求了 stream_options.include_usage 时）与结束标记，只执行一次。
+func (s *anthropicStream) finish() {
+	if s.done {
+		return
+	}
+	s.done = true
+	if s.includeUsage {
+		s.send(chatCompletion{
+			ID:      s.id,
+			Object:  "chat.completion.chunk",
+			Created: s.created,
+			Model:   s.model,
+			Choices: []chatChoice{},
+			Usage:   newChatUsage(s.usage.promptTokens(), s.usage.OutputTokens),
+		})
+	}
+	s.sendDone()
+}
@@ -0,0 +1,242 @@
+package translate
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+func newAnthropicTranslator(t *testing.T) Translator {
+	t.Helper()
+	tr, ok := New(rules.TranslateOpenAIToAnthropic)
+	require.True(t, ok)
+	return tr
+}
+
+func TestNew_UnknownProtocol(t *testing.T) {
+	_, ok := New("openai_to_unknown")
+	require.False(t, ok)
+}
+
+func TestAnthropic_RequestConvertsChatCompletion(t *testing.T) {
+	body := `{
+		"model": "claude-sonnet-4",
+		"messages": [
+			{"role": "system", "content": "be brief"},
+			{"role": "user", "content": [
+				{"type": "text", "text": "what is this?"},
+				{"type": "image_url", "image_url": {"url": "data:image/png;base64,AAAA"}}
+			]},
+			{"role": "assistant", "content": null, "tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "lookup", "arguments": "{\"q\":\"x\"}"}}
+			]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "found"}
+		],
+		"max_completion_tokens": 256,
+		"temperature": 0.2,
+		"stop": "END",
+		"stream": true,
+		"tools": [{"type": "function", "function": {"name": "lookup", "description": "search", "parameters": {"type": "object"}}}],
+		"tool_choice": "required",
+		"parallel_tool_calls": false,
+		"user": "u-1"
+	}`
+	req := httptest.NewRequest(http.MethodPost, "http://upstream/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test")
+	// 客户端以 X-API-Key 携带的网关 API Key 不能透传给上游。
+	req.Header.Set("X-API-Key", "yapi_client")
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	require.NoError(t, newAnthropicTranslator(t).Request(req))
+
+	require.Equal(t, "/v1/messages", req.URL.Path)
+	require.Equal(t, "sk-test", req.Header.Get("x-api-key"))
+	require.Empty(t, req.Header.Get("Authorization"))
+	require.Empty(t, req.Header.Get("Accept-Encoding"))
+	require.Equal(t, anthropicVersion, req.Header.Get("anthropic-version"))
+
+	data, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.EqualValues(t, len(data), req.ContentLength)
+	require.JSONEq(t, `{
+		"model": "claude-sonnet-4",
+		"system": "be brief",
+		"messages": [
+			{"role": "user", "content": [
+				{"type": "text", "text": "what is this?"},
+				{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "AAAA"}}
+			]},
+			{"role": "assistant", "content": [
+				{"type": "tool_use", "id": "call_1", "name": "lookup", "input": {"q": "x"}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "call_1", "content": "found"}
+			]}
+		],
+		"max_tokens": 256,
+		"temperature": 0.2,
+		"stop_sequences": ["END"],
+		"stream": true,
+		"tools": [{"name": "lookup", "description": "search", "input_schema": {"type": "object"}}],
+		"tool_choice": {"type": "any", "disable_parallel_tool_use": true},
+		"metadata": {"user_id": "u-1"}
+	}`, string(data))
+}
+
+func TestAnthropic_RequestDefaultsMaxTokens(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+		strings.NewReader(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`))
+	require.NoError(t, newAnthropicTranslator(t).Request(req))
+
+	var out map[string]any
+	require.NoError(t, json.NewDecoder(req.Body).Decode(&out))
+	require.EqualValues(t, defaultMaxTokens, out["max_tokens"])
+}
+
+func TestAnthropic_RequestRejectsUntranslatable(t *testing.T) {
+	cases := map[string]*http.Request{
+		"other endpoint": httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(`{}`)),
+		"wrong method":   httptest.NewRequest(http.MethodGet, "/v1/chat/completions", nil),
+		"invalid json":   httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{`)),
+		"multiple choices": httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+			strings.NewReader(`{"model":"m","n":2,"messages":[{"role":"user","content":"hi"}]}`)),
+		"unknown role": httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+			strings.NewReader(`{"model":"m","messages":[{"role":"function","content":"hi"}]}`)),
+	}
+	for name, req := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.ErrorIs(t, newAnthropicTranslator(t).Request(req), ErrInvalidRequest)
+		})
+	}
+}
+
+func jsonResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestAnthropic_ResponseConvertsMessage(t *testing.T) {
+	resp := jsonResponse(http.StatusOK, `{
+		"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4",
+		"content": [
+			{"type": "text", "text": "let me check"},
+			{"type": "tool_use", "id": "toolu_1", "name": "lookup", "input": {"q": "x"}}
+		],
+		"stop_reason": "tool_use",
+		"usage": {"input_tokens": 10, "output_tokens": 5, "cache_read_input_tokens": 3}
+	}`)
+	require.NoError(t, newAnthropicTranslator(t).Response(resp))
+
+	var out map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	require.Equal(t, "chat.completion", out["object"])
+	require.Equal(t, "msg_1", out["id"])
+	choice := out["choices"].([]any)[0].(map[string]any)
+	require.Equal(t, "tool_calls", choice["finish_reason"])
+	message := choice["message"].(map[string]any)
+	require.Equal(t, "let me check", message["content"])
+	call := message["tool_calls"].([]any)[0].(map[string]any)
+	require.Equal(t, "toolu_1", call["id"])
+	require.JSONEq(t, `{"name":"lookup","arguments":"{\"q\":\"x\"}"}`, mustJSON(t, call["function"]))
+	require.JSONEq(t, `{"prompt_tokens":13,"completion_tokens":5,"total_tokens":18}`, mustJSON(t, out["usage"]))
+}
+
+func TestAnthropic_ResponseConvertsError(t *testing.T) {
+	resp := jsonResponse(http.StatusTooManyRequests,
+		`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`)
+	require.NoError(t, newAnthropicTranslator(t).Response(resp))
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"error":{"message":"slow down","type":"rate_limit_error","param":null,"code":null}}`, string(data))
+}
+
+func TestAnthropic_ResponseConvertsStream(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(
+		`{"model":"m","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`))
+	tr := newAnthropicTranslator(t)
+	require.NoError(t, tr.Request(req))
+
+	events := strings.Join([]string{
+		`event: message_start`,
+		`data: {"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4","usage":{"input_tokens":7,"output_tokens":1}}}`,
+		``,
+		`event: content_block_start`,
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		``,
+		`event: ping`,
+		`data: {"type":"ping"}`,
+		``,
+		`event: content_block_delta`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+		``,
+		`event: content_block_delta`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`,
+		``,
+		`event: content_block_start`,
+		`data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"lookup","input":{}}}`,
+		``,
+		`event: content_block_delta`,
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"q\":1}"}}`,
+		``,
+		`event: message_delta`,
+		`data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}`,
+		``,
+		`event: message_stop`,
+		`data: {"type":"message_stop"}`,
+		``,
+		``,
+	}, "\n")
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(strings.NewReader(events)),
+	}
+	require.NoError(t, tr.Response(resp))
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var chunks []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if payload, ok := strings.CutPrefix(line, "data: "); ok {
+			chunks = append(chunks, payload)
+		}
+	}
+	require.Len(t, chunks, 8)
+	require.JSONEq(t, `{"role":"assistant","content":""}`, mustJSON(t, chunkDelta(t, chunks[0])))
+	require.JSONEq(t, `{"content":"Hel"}`, mustJSON(t, chunkDelta(t, chunks[1])))
+	require.JSONEq(t, `{"content":"lo"}`, mustJSON(t, chunkDelta(t, chunks[2])))
+	require.JSONEq(t, `{"tool_calls":[{"index":0,"id":"toolu_1","type":"function","function":{"name":"lookup","arguments":""}}]}`,
+		mustJSON(t, chunkDelta(t, chunks[3])))
+	require.JSONEq(t, `{"tool_calls":[{"index":0,"function":{"arguments":"{\"q\":1}"}}]}`, mustJSON(t, chunkDelta(t, chunks[4])))
+	require.Contains(t, chunks[5], `"finish_reason":"tool_calls"`)
+	require.Contains(t, chunks[6], `"usage":{"prompt_tokens":7,"completion_tokens":9,"total_tokens":16}`)
+	require.Equal(t, "[DONE]", chunks[7])
+}
+
+func chunkDelta(t *testing.T, chunk string) any {
+	t.Helper()
+	var out chatCompletion
+	require.NoError(t, json.Unmarshal([]byte(chunk), &out))
+	require.Equal(t, "chat.completion.chunk", out.Object)
+	require.Equal(t, "msg_1", out.ID)
+	require.Len(t, out.Choices, 1)
+	return out.Choices[0].Delta
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(data)
+}
@@ -0,0 +1,131 @@
+package translate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/prehisle/yapi/pkg/accounts"
+)
+
+const (
+	// azureDefaultAPIVersion 是凭据未配置 api_version 时注入的 Azure OpenAI API 版本。
+	azureDefaultAPIVersion = "2024-10-21"
+	// AzureMetadataAPIVersion 是凭据元数据中 Azure OpenAI API 版本的键。
+	AzureMetadataAPIVersion = "api_version"
+	// AzureMetadataDeployments 是凭据元数据中模型到部署名映射的键，值为 JSON 对象，如 {"gpt-4o":"prod-gpt4o"}。
+	AzureMetadataDeployments = "deployments"
+)
+
+// azureTranslator 把 OpenAI API 请求改写为 Azure OpenAI 部署的请求：/v1/<操作> 改为
+// /openai/deployments/<部署名>/<操作>，注入 api-version 查询参数，并以 api-key 头认证。
+// Azure OpenAI 的请求与响应体与 OpenAI 兼容，因此不改写响应。
+type azureTranslator struct {
+	apiVersion  string
+	deployments map[string]string
+}
+
+// AzureAPIVersion 返回 Azure 凭据使用的 API 版本：元数据 api_version，未配置时为默认版本。
+func AzureAPIVersion(cred accounts.UpstreamCredential) string {
+	if version, ok := cred.Metadata[AzureMetadataAPIVersion].(string); ok && strings.TrimSpace(version) != "" {
+		return strings.TrimSpace(version)
+	}
+	return azureDefaultAPIVersion
+}
+
+func newAzureTranslator(cred accounts.UpstreamCredential) Translator {
+	t := &azureTranslator{apiVersion: AzureAPIVersion(cred), deployments: map[string]string{}}
+	if deployments, ok := cred.Metadata[AzureMetadataDeployments].(map[string]any); ok {
+		for model, deployment := range deployments {
+			if name, ok := deployment.(string); ok && strings.TrimSpace(name) != "" {
+				t.deployments[model] = strings.TrimSpace(name)
+			}
+		}
+	}
+	return t
+}
+
+func (t *azureTranslator) Request(req *http.Request) error {
+	// 已经是 Azure 路径（客户端直接使用 Azure 约定）时只补充版本与认证。
+	if !strings.Contains(req.URL.Path, "/openai/") {
+		prefix, operation := splitVersionPath(req.URL.Path)
+		if req.Method == http.MethodGet && operation == "models" {
+			req.URL.Path = prefix + "/openai/models"
+			req.URL.RawPath = ""
+		} else {
+			model, err := peekModel(req)
+			if err != nil {
+				return err
+			}
+			deployment := t.deployment(model)
+			req.URL.Path = prefix + "/openai/deployments/" + deployment + "/" + operation
+			req.URL.RawPath = prefix + "/openai/deployments/" + url.PathEscape(deployment) + "/" + operation
+			if req.URL.RawPath == req.URL.Path {
+				req.URL.RawPath = ""
+			}
+		}
+	}
+	query := req.URL.Query()
+	if query.Get("api-version") == "" {
+		query.Set("api-version", t.apiVersion)
+		req.URL.RawQuery = query.Encode()
+	}
+	// 注入的上游凭据始终覆盖客户端自带的 api-key 请求头。
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		if key, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			req.Header.Set("api-key", strings.TrimSpace(key))
+		}
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
+
+func (t *azureTranslator) Response(*http.Response) error {
+	return nil
+}
+
+// deployment 返回模型对应的部署名，未配置映射时直接使用模型名。
+func (t *azureTranslator) deployment(model string) string {
+	if deployment, ok := t.deployments[model]; ok {
+		return deployment
+	}
+	return model
+}
+
+// splitVersionPath 把 /<前缀>/v1/<操作> 拆分为前缀与操作，路径中没有 /v1/ 时前缀为空。
+func splitVersionPath(path string) (prefix, operation string) {
+	if before, after, ok := strings.Cut(path, "/v1/"); ok {
+		return before, after
+	}
+	return "", strings.TrimPrefix(path, "/")
+}
+
+// peekModel 读取 JSON 请求体中的 model 字段，并保留请求体原样供转发。
+func peekModel(req *http.Request) (string, error) {
+	if !isJSON(req.Header) || req.Body == nil || req.Body == http.NoBody {
+		return "", fmt.Errorf("%w: a JSON body with a model is required to select the Azure deployment", ErrInvalidRequest)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", fmt.Errorf("%w: read body: %v", ErrInvalidRequest, err)
+	}
+	_ = req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	var payload struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if strings.TrimSpace(payload.Model) == "" {
+		return "", fmt.Errorf("%w: model is required to select the Azure deployment", ErrInvalidRequest)
+	}
+	return strings.TrimSpace(payload.Model), nil
+}
@@ -0,0 +1,68 @@
+package translate
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+
+	"github.com/prehisle/yapi/pkg/accounts"
+)
+
+func newAzure(t *testing.T, metadata datatypes.JSONMap) Translator {
+	t.Helper()
+	tr, ok := ForCredential(accounts.UpstreamCredential{Service: "azure", Metadata: metadata})
+	require.True(t, ok)
+	return tr
+}
+
+func TestAzure_RequestMapsModelToDeployment(t *testing.T) {
+	tr := newAzure(t, datatypes.JSONMap{
+		AzureMetadataAPIVersion:  "2025-01-01-preview",
+		AzureMetadataDeployments: map[string]any{"gpt-4o": "prod-gpt4o"},
+	})
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "http://res.openai.azure.com/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer az-key")
+	req.Header.Set("Api-Key", "yapi_client")
+
+	require.NoError(t, tr.Request(req))
+
+	require.Equal(t, "/openai/deployments/prod-gpt4o/chat/completions", req.URL.Path)
+	require.Equal(t, "2025-01-01-preview", req.URL.Query().Get("api-version"))
+	require.Equal(t, "az-key", req.Header.Get("api-key"))
+	require.Empty(t, req.Header.Get("Authorization"))
+	data, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, body, string(data))
+}
+
+func TestAzure_RequestDefaults(t *testing.T) {
+	tr := newAzure(t, nil)
+	req := httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(`{"model":"text-embedding-3-small","input":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	require.NoError(t, tr.Request(req))
+	require.Equal(t, "/openai/deployments/text-embedding-3-small/embeddings", req.URL.Path)
+	require.Equal(t, azureDefaultAPIVersion, req.URL.Query().Get("api-version"))
+
+	// 模型列表与已是 Azure 约定的路径不需要部署名。
+	req = httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	require.NoError(t, tr.Request(req))
+	require.Equal(t, "/openai/models", req.URL.Path)
+
+	req = httptest.NewRequest(http.MethodPost, "/openai/deployments/d1/chat/completions?api-version=2024-06-01", strings.NewReader(`{}`))
+	require.NoError(t, tr.Request(req))
+	require.Equal(t, "/openai/deployments/d1/chat/completions", req.URL.Path)
+	require.Equal(t, "2024-06-01", req.URL.Query().Get("api-version"))
+}
+
+func TestAzure_RequestRequiresModel(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	require.ErrorIs(t, newAzure(t, nil).Request(req), ErrInvalidRequest)
+}
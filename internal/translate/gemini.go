@@ -0,0 +1,532 @@
+package translate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type geminiRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+	Tools             []geminiTool            `json:"tools,omitempty"`
+	ToolConfig        *geminiToolConfig       `json:"toolConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiPart 是内容的一段，按出现的字段区分文本、内联数据、函数调用与函数结果。
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *geminiInlineData       `json:"inlineData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type geminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+type geminiGenerationConfig struct {
+	MaxOutputTokens *int     `json:"maxOutputTokens,omitempty"`
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type geminiToolConfig struct {
+	FunctionCallingConfig struct {
+		Mode                 string   `json:"mode"`
+		AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+	} `json:"functionCallingConfig"`
+}
+
+// geminiResponse 既是非流式响应，也是流式响应中的每个分块（分块只包含增量内容）。
+type geminiResponse struct {
+	ResponseID    string            `json:"responseId"`
+	ModelVersion  string            `json:"modelVersion"`
+	Candidates    []geminiCandidate `json:"candidates"`
+	UsageMetadata *struct {
+		PromptTokenCount     int64 `json:"promptTokenCount"`
+		CandidatesTokenCount int64 `json:"candidatesTokenCount"`
+		ThoughtsTokenCount   int64 `json:"thoughtsTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+type geminiCandidate struct {
+	Content      geminiContent `json:"content"`
+	FinishReason string        `json:"finishReason"`
+}
+
+// usage 返回 OpenAI 格式的用量，思考 token 计入 completion_tokens。
+func (r geminiResponse) usage() *chatUsage {
+	if r.UsageMetadata == nil {
+		return nil
+	}
+	return newChatUsage(r.UsageMetadata.PromptTokenCount, r.UsageMetadata.CandidatesTokenCount+r.UsageMetadata.ThoughtsTokenCount)
+}
+
+type geminiError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// geminiTranslator 把 OpenAI Chat Completions 请求转换为 Gemini generateContent 请求，并把响应转换回来。
+type geminiTranslator struct {
+	model        string
+	includeUsage bool
+}
+
+func (t *geminiTranslator) Request(req *http.Request) error {
+	if err := requireChatCompletions(req); err != nil {
+		return err
+	}
+	var chat chatRequest
+	if err := readJSON(req, &chat); err != nil {
+		return err
+	}
+	model := strings.TrimPrefix(strings.TrimSpace(chat.Model), "models/")
+	if model == "" {
+		return fmt.Errorf("%w: model is required", ErrInvalidRequest)
+	}
+	converted, err := toGeminiRequest(chat)
+	if err != nil {
+		return err
+	}
+	if err := setJSONBody(req, converted); err != nil {
+		return err
+	}
+	t.model = model
+	t.includeUsage = chat.includeUsage()
+
+	// 模型在 Gemini 中是路径的一部分：.../chat/completions 改写为 .../models/<model>:generateContent。
+	method := ":generateContent"
+	query := req.URL.Query()
+	if chat.Stream {
+		method = ":streamGenerateContent"
+		query.Set("alt", "sse")
+	}
+	req.URL.Path = strings.TrimSuffix(req.URL.Path, chatCompletionsSuffix) + "/models/" + model + method
+	req.URL.RawPath = strings.TrimSuffix(req.URL.EscapedPath(), chatCompletionsSuffix) + "/models/" + url.PathEscape(model) + method
+	if req.URL.RawPath == req.URL.Path {
+		req.URL.RawPath = ""
+	}
+	// Gemini 以查询参数 key 认证，上游凭据注入的 Bearer 令牌转为该参数，并覆盖客户端自带的 key。
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		if key, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			query.Set("key", strings.TrimSpace(key))
+		}
+		req.Header.Del("Authorization")
+	}
+	req.URL.RawQuery = query.Encode()
+	return nil
+}
+
+func toGeminiRequest(chat chatRequest) (geminiRequest, error) {
+	if chat.N != nil && *chat.N > 1 {
+		return geminiRequest{}, fmt.Errorf("%w: n > 1 is not supported", ErrInvalidRequest)
+	}
+	var out geminiRequest
+	config := geminiGenerationConfig{Temperature: chat.Temperature, TopP: chat.TopP}
+	if maxTokens, ok := chat.maxTokens(); ok {
+		config.MaxOutputTokens = &maxTokens
+	}
+	stop, err := chat.stopSequences()
+	if err != nil {
+		return geminiRequest{}, err
+	}
+	config.StopSequences = stop
+	if config.MaxOutputTokens != nil || config.Temperature != nil || config.TopP != nil || len(stop) > 0 {
+		out.GenerationConfig = &config
+	}
+
+	// 工具结果只携带 tool_call_id，而 Gemini 的 functionResponse 需要函数名，按先前的工具调用查找。
+	toolNames := map[string]string{}
+	var system []geminiPart
+	for _, msg := range chat.Messages {
+		var role string
+		var parts []geminiPart
+		switch msg.Role {
+		case "system", "developer":
+			text, err := msg.text()
+			if err != nil {
+				return geminiRequest{}, err
+			}
+			system = append(system, geminiPart{Text: text})
+			continue
+		case "tool":
+			name, ok := toolNames[msg.ToolCallID]
+			if !ok {
+				return geminiRequest{}, fmt.Errorf("%w: tool message references unknown tool_call_id %q", ErrInvalidRequest, msg.ToolCallID)
+			}
+			text, err := msg.text()
+			if err != nil {
+				return geminiRequest{}, err
+			}
+			role = "user"
+			parts = []geminiPart{{FunctionResponse: &geminiFunctionResponse{Name: name, Response: functionResponse(text)}}}
+		case "user", "assistant":
+			role = "user"
+			if msg.Role == "assistant" {
+				role = "model"
+			}
+			if parts, err = toGeminiParts(msg); err != nil {
+				return geminiRequest{}, err
+			}
+			for _, call := range msg.ToolCalls {
+				toolNames[call.ID] = call.Function.Name
+			}
+		default:
+			return geminiRequest{}, fmt.Errorf("%w: unsupported message role %q", ErrInvalidRequest, msg.Role)
+		}
+		// Gemini 要求 user 与 model 交替出现，连续的同角色消息合并为一条。
+		if n := len(out.Contents); n > 0 && out.Contents[n-1].Role == role {
+			out.Contents[n-1].Parts = append(out.Contents[n-1].Parts, parts...)
+			continue
+		}
+		out.Contents = append(out.Contents, geminiContent{Role: role, Parts: parts})
+	}
+	if len(system) > 0 {
+		out.SystemInstruction = &geminiContent{Parts: system}
+	}
+
+	if len(chat.Tools) > 0 {
+		tool := geminiTool{}
+		for _, t := range chat.Tools {
+			if t.Type != "function" {
+				return geminiRequest{}, fmt.Errorf("%w: unsupported tool type %q", ErrInvalidRequest, t.Type)
+			}
+			declaration := geminiFunctionDeclaration{Name: t.Function.Name, Description: t.Function.Description}
+			if len(t.Function.Parameters) > 0 && string(t.Function.Parameters) != "null" {
+				declaration.Parameters = t.Function.Parameters
+			}
+			tool.FunctionDeclarations = append(tool.FunctionDeclarations, declaration)
+		}
+		out.Tools = []geminiTool{tool}
+	}
+	choice, err := parseToolChoice(chat.ToolChoice)
+	if err != nil {
+		return geminiRequest{}, err
+	}
+	if choice.mode != "" {
+		toolConfig := &geminiToolConfig{}
+		switch choice.mode {
+		case "auto":
+			toolConfig.FunctionCallingConfig.Mode = "AUTO"
+		case "none":
+			toolConfig.FunctionCallingConfig.Mode = "NONE"
+		case "required":
+			toolConfig.FunctionCallingConfig.Mode = "ANY"
+		case "function":
+			toolConfig.FunctionCallingConfig.Mode = "ANY"
+			toolConfig.FunctionCallingConfig.AllowedFunctionNames = []string{choice.name}
+		}
+		out.ToolConfig = toolConfig
+	}
+	return out, nil
+}
+
+// toGeminiParts 转换 user 或 assistant 消息的内容与工具调用。
+func toGeminiParts(msg chatMessage) ([]geminiPart, error) {
+	contentParts, err := msg.parts()
+	if err != nil {
+		return nil, err
+	}
+	var parts []geminiPart
+	for _, part := range contentParts {
+		switch part.Type {
+		case "text":
+			if part.Text != "" {
+				parts = append(parts, geminiPart{Text: part.Text})
+			}
+		case "image_url":
+			if part.ImageURL == nil {
+				return nil, fmt.Errorf("%w: image_url content part requires a url", ErrInvalidRequest)
+			}
+			mediaType, data, ok := parseDataURL(part.ImageURL.URL)
+			if !ok {
+				return nil, fmt.Errorf("%w: only base64 data URLs are supported for images", ErrInvalidRequest)
+			}
+			parts = append(parts, geminiPart{InlineData: &geminiInlineData{MimeType: mediaType, Data: data}})
+		default:
+			return nil, fmt.Errorf("%w: unsupported content part type %q", ErrInvalidRequest, part.Type)
+		}
+	}
+	for _, call := range msg.ToolCalls {
+		args := json.RawMessage(`{}`)
+		if raw := strings.TrimSpace(call.Function.Arguments); raw != "" {
+			if !json.Valid([]byte(raw)) {
+				return nil, fmt.Errorf("%w: tool call %s arguments are not valid JSON", ErrInvalidRequest, call.ID)
+			}
+			args = json.RawMessage(raw)
+		}
+		parts = append(parts, geminiPart{FunctionCall: &geminiFunctionCall{Name: call.Function.Name, Args: args}})
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("%w: %s message has no content", ErrInvalidRequest, msg.Role)
+	}
+	return parts, nil
+}
+
+// functionResponse 返回工具结果的 JSON 对象形式：结果本身是 JSON 对象时原样使用，否则包装为 {"content": ...}。
+func functionResponse(text string) json.RawMessage {
+	trimmed := strings.TrimSpace(text)
+	if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		return json.RawMessage(trimmed)
+	}
+	wrapped, _ := json.Marshal(map[string]string{"content": text})
+	return wrapped
+}
+
+func (t *geminiTranslator) Response(resp *http.Response) error {
+	switch {
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		if !isJSON(resp.Header) {
+			return nil
+		}
+		return rewriteJSONResponse(resp, toChatErrorFromGemini)
+	case isEventStream(resp.Header):
+		resp.Body = newGeminiStream(resp.Body, t.model, t.includeUsage)
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+		return nil
+	case isJSON(resp.Header):
+		return rewriteJSONResponse(resp, func(body []byte) (any, error) {
+			return toChatCompletionFromGemini(body, t.model)
+		})
+	}
+	return nil
+}
+
+func toChatErrorFromGemini(body []byte) (any, error) {
+	// 部分 Gemini 端点把错误包装在数组中。
+	var list []geminiError
+	var upstream geminiError
+	if json.Unmarshal(body, &list) == nil && len(list) > 0 {
+		upstream = list[0]
+	} else if err := json.Unmarshal(body, &upstream); err != nil || upstream.Error.Message == "" {
+		return newChatError("upstream_error", strings.TrimSpace(string(body))), nil
+	}
+	out := newChatError(geminiErrorType(upstream.Error.Status), upstream.Error.Message)
+	if status := upstream.Error.Status; status != "" {
+		out.Error.Code = &status
+	}
+	return out, nil
+}
+
+// geminiErrorType 把 Google API 错误状态映射为 OpenAI 错误类型。
+func geminiErrorType(status string) string {
+	switch status {
+	case "INVALID_ARGUMENT", "FAILED_PRECONDITION", "NOT_FOUND", "OUT_OF_RANGE":
+		return "invalid_request_error"
+	case "UNAUTHENTICATED":
+		return "authentication_error"
+	case "PERMISSION_DENIED":
+		return "permission_error"
+	case "RESOURCE_EXHAUSTED":
+		return "rate_limit_error"
+	default:
+		return "api_error"
+	}
+}
+
+func toChatCompletionFromGemini(body []byte, model string) (any, error) {
+	var upstream geminiResponse
+	if err := json.Unmarshal(body, &upstream); err != nil {
+		return nil, fmt.Errorf("decode gemini response: %w", err)
+	}
+	message := &chatResponseMessage{Role: "assistant"}
+	finish := "stop"
+	if len(upstream.Candidates) > 0 {
+		candidate := upstream.Candidates[0]
+		var text strings.Builder
+		for _, part := range candidate.Content.Parts {
+			if part.FunctionCall != nil {
+				message.ToolCalls = append(message.ToolCalls, toChatToolCall(*part.FunctionCall, nil))
+				continue
+			}
+			text.WriteString(part.Text)
+		}
+		if text.Len() > 0 || len(message.ToolCalls) == 0 {
+			content := text.String()
+			message.Content = &content
+		}
+		finish = geminiFinishReason(candidate.FinishReason, len(message.ToolCalls) > 0)
+	} else {
+		// 没有候选结果通常表示提示词被安全策略拦截。
+		finish = "content_filter"
+		empty := ""
+		message.Content = &empty
+	}
+	return chatCompletion{
+		ID:      responseID(upstream.ResponseID),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   firstNonEmpty(upstream.ModelVersion, model),
+		Choices: []chatChoice{{Message: message, FinishReason: &finish}},
+		Usage:   upstream.usage(),
+	}, nil
+}
+
+// toChatToolCall 把 Gemini 函数调用转换为 OpenAI 工具调用。Gemini 通常不返回调用 ID，此时生成一个。
+func toChatToolCall(call geminiFunctionCall, index *int) chatToolCall {
+	out := chatToolCall{Index: index, ID: call.ID, Type: "function"}
+	if out.ID == "" {
+		out.ID = "call_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	}
+	out.Function.Name = call.Name
+	out.Function.Arguments = compactJSON(call.Args)
+	return out
+}
+
+// geminiFinishReason 把 Gemini finishReason 映射为 OpenAI finish_reason，返回了函数调用时为 tool_calls。
+func geminiFinishReason(reason string, toolCalls bool) string {
+	switch reason {
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return "content_filter"
+	}
+	if toolCalls {
+		return "tool_calls"
+	}
+	return "stop"
+}
+
+func responseID(id string) string {
+	if id == "" {
+		return "chatcmpl-" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	}
+	return id
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// geminiStream 把 Gemini 流式分块转换为 OpenAI chat.completion.chunk 分块。Gemini 没有显式的结束事件，
+// 在上游流结束时输出用量与结束标记。
+type geminiStream struct {
+	*eventStream
+	includeUsage bool
+	id           string
+	model        string
+	created      int64
+	started      bool
+	toolCalls    int
+	usage        *chatUsage
+}
+
+func newGeminiStream(body io.ReadCloser, model string, includeUsage bool) *geminiStream {
+	s := &geminiStream{
+		eventStream:  newEventStream(body),
+		includeUsage: includeUsage,
+		model:        model,
+		created:      time.Now().Unix(),
+	}
+	s.onEvent = s.event
+	s.onEnd = s.finish
+	return s
+}
+
+func (s *geminiStream) chunk(delta chatDelta, finish *string) chatCompletion {
+	return chatCompletion{
+		ID:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []chatChoice{{Delta: &delta, FinishReason: finish}},
+	}
+}
+
+func (s *geminiStream) event(data []byte) {
+	var chunk geminiResponse
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return
+	}
+	if !s.started {
+		s.started = true
+		s.id = responseID(chunk.ResponseID)
+		s.model = firstNonEmpty(chunk.ModelVersion, s.model)
+		empty := ""
+		s.send(s.chunk(chatDelta{Role: "assistant", Content: &empty}, nil))
+	}
+	if usage := chunk.usage(); usage != nil {
+		s.usage = usage
+	}
+	if len(chunk.Candidates) == 0 {
+		return
+	}
+	candidate := chunk.Candidates[0]
+	for _, part := range candidate.Content.Parts {
+		if part.FunctionCall != nil {
+			index := s.toolCalls
+			s.toolCalls++
+			s.send(s.chunk(chatDelta{ToolCalls: []chatToolCall{toChatToolCall(*part.FunctionCall, &index)}}, nil))
+			continue
+		}
+		if part.Text != "" {
+			text := part.Text
+			s.send(s.chunk(chatDelta{Content: &text}, nil))
+		}
+	}
+	if candidate.FinishReason != "" {
+		finish := geminiFinishReason(candidate.FinishReason, s.toolCalls > 0)
+		s.send(s.chunk(chatDelta{}, &finish))
+	}
+}
+
+// finish 输出用量分块（客户端请求了 stream_options.include_usage 时）与结束标记。
+func (s *geminiStream) finish() {
+	if s.includeUsage && s.usage != nil {
+		s.send(chatCompletion{
+			ID:      s.id,
+			Object:  "chat.completion.chunk",
+			Created: s.created,
+			Model:   s.model,
+			Choices: []chatChoice{},
+			Usage:   s.usage,
+		})
+	}
+	s.sendDone()
+}
@@ -0,0 +1,179 @@
+package translate
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+func newGeminiTranslator(t *testing.T) Translator {
+	t.Helper()
+	tr, ok := New(rules.TranslateOpenAIToGemini)
+	require.True(t, ok)
+	return tr
+}
+
+func TestGemini_RequestConvertsChatCompletion(t *testing.T) {
+	body := `{
+		"model": "gemini-2.5-flash",
+		"messages": [
+			{"role": "system", "content": "be brief"},
+			{"role": "user", "content": [
+				{"type": "text", "text": "what is this?"},
+				{"type": "image_url", "image_url": {"url": "data:image/png;base64,AAAA"}}
+			]},
+			{"role": "assistant", "content": null, "tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "lookup", "arguments": "{\"q\":\"x\"}"}}
+			]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "found"}
+		],
+		"max_tokens": 256,
+		"stop": ["END"],
+		"stream": true,
+		"tools": [{"type": "function", "function": {"name": "lookup", "description": "search", "parameters": {"type": "object"}}}],
+		"tool_choice": {"type": "function", "function": {"name": "lookup"}}
+	}`
+	req := httptest.NewRequest(http.MethodPost, "http://upstream/v1beta/chat/completions?key=client-key", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer gm-key")
+
+	require.NoError(t, newGeminiTranslator(t).Request(req))
+
+	require.Equal(t, "/v1beta/models/gemini-2.5-flash:streamGenerateContent", req.URL.Path)
+	require.Equal(t, "sse", req.URL.Query().Get("alt"))
+	require.Equal(t, "gm-key", req.URL.Query().Get("key"))
+	require.Empty(t, req.Header.Get("Authorization"))
+
+	data, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"contents": [
+			{"role": "user", "parts": [
+				{"text": "what is this?"},
+				{"inlineData": {"mimeType": "image/png", "data": "AAAA"}}
+			]},
+			{"role": "model", "parts": [{"functionCall": {"name": "lookup", "args": {"q": "x"}}}]},
+			{"role": "user", "parts": [{"functionResponse": {"name": "lookup", "response": {"content": "found"}}}]}
+		],
+		"systemInstruction": {"parts": [{"text": "be brief"}]},
+		"generationConfig": {"maxOutputTokens": 256, "stopSequences": ["END"]},
+		"tools": [{"functionDeclarations": [{"name": "lookup", "description": "search", "parameters": {"type": "object"}}]}],
+		"toolConfig": {"functionCallingConfig": {"mode": "ANY", "allowedFunctionNames": ["lookup"]}}
+	}`, string(data))
+}
+
+func TestGemini_RequestRejectsUntranslatable(t *testing.T) {
+	cases := map[string]string{
+		"remote image":      `{"model":"m","messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]}]}`,
+		"unknown tool call": `{"model":"m","messages":[{"role":"tool","tool_call_id":"call_9","content":"x"}]}`,
+		"missing model":     `{"messages":[{"role":"user","content":"hi"}]}`,
+	}
+	for name, body := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1beta/chat/completions", strings.NewReader(body))
+			require.ErrorIs(t, newGeminiTranslator(t).Request(req), ErrInvalidRequest)
+		})
+	}
+}
+
+func TestGemini_ResponseConvertsContent(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/chat/completions",
+		strings.NewReader(`{"model":"gemini-2.5-flash","messages":[{"role":"user","content":"hi"}]}`))
+	tr := newGeminiTranslator(t)
+	require.NoError(t, tr.Request(req))
+
+	resp := jsonResponse(http.StatusOK, `{
+		"candidates": [{"content": {"role": "model", "parts": [
+			{"text": "checking"},
+			{"functionCall": {"name": "lookup", "args": {"q": "x"}}}
+		]}, "finishReason": "STOP"}],
+		"usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 5},
+		"modelVersion": "gemini-2.5-flash-001",
+		"responseId": "resp-1"
+	}`)
+	require.NoError(t, tr.Response(resp))
+
+	var out chatCompletion
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &out))
+	require.Equal(t, "resp-1", out.ID)
+	require.Equal(t, "gemini-2.5-flash-001", out.Model)
+	require.Equal(t, "tool_calls", *out.Choices[0].FinishReason)
+	message := out.Choices[0].Message
+	require.Equal(t, "checking", *message.Content)
+	require.Len(t, message.ToolCalls, 1)
+	require.True(t, strings.HasPrefix(message.ToolCalls[0].ID, "call_"))
+	require.Equal(t, `{"q":"x"}`, message.ToolCalls[0].Function.Arguments)
+	require.Equal(t, chatUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, *out.Usage)
+}
+
+func TestGemini_ResponseConvertsError(t *testing.T) {
+	resp := jsonResponse(http.StatusBadRequest,
+		`{"error":{"code":400,"message":"API key not valid","status":"INVALID_ARGUMENT"}}`)
+	require.NoError(t, newGeminiTranslator(t).Response(resp))
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"error":{"message":"API key not valid","type":"invalid_request_error","param":null,"code":"INVALID_ARGUMENT"}}`, string(data))
+}
+
+func TestGemini_ResponseConvertsStream(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/chat/completions", strings.NewReader(
+		`{"model":"gemini-2.5-flash","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`))
+	tr := newGeminiTranslator(t)
+	require.NoError(t, tr.Request(req))
+
+	events := `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Hel"}]}}],"usageMetadata":{"promptTokenCount":7},"responseId":"resp-1"}` + "\r\n\r\n" +
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"lo"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":7,"candidatesTokenCount":2},"responseId":"resp-1"}` + "\r\n\r\n"
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(strings.NewReader(events)),
+	}
+	require.NoError(t, tr.Response(resp))
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var chunks []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if payload, ok := strings.CutPrefix(line, "data: "); ok {
+			chunks = append(chunks, payload)
+		}
+	}
+	require.Len(t, chunks, 6)
+	require.Contains(t, chunks[0], `"role":"assistant"`)
+	require.Contains(t, chunks[1], `"content":"Hel"`)
+	require.Contains(t, chunks[2], `"content":"lo"`)
+	require.Contains(t, chunks[3], `"finish_reason":"stop"`)
+	require.Contains(t, chunks[4], `"usage":{"prompt_tokens":7,"completion_tokens":2,"total_tokens":9}`)
+	require.Equal(t, "[DONE]", chunks[5])
+}
+
+func TestForCredential_GeminiOnlyTranslatesChatCompletions(t *testing.T) {
+	_, ok := ForCredential(accounts.UpstreamCredential{Service: "openai"})
+	require.False(t, ok)
+
+	tr, ok := ForCredential(accounts.UpstreamCredential{Service: "Gemini"})
+	require.True(t, ok)
+	native := `{"contents":[{"parts":[{"text":"hi"}]}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-2.5-flash:generateContent", strings.NewReader(native))
+	require.NoError(t, tr.Request(req))
+	require.Equal(t, "/v1beta/models/gemini-2.5-flash:generateContent", req.URL.Path)
+	data, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, native, string(data))
+
+	resp := jsonResponse(http.StatusOK, `{"candidates":[]}`)
+	require.NoError(t, tr.Response(resp))
+	data, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"candidates":[]}`, string(data))
+}
@@ -0,0 +1,210 @@
+package translate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// chatRequest 是 OpenAI Chat Completions 请求中可转换的字段，其余字段（如 frequency_penalty、logprobs）被忽略。
+type chatRequest struct {
+	Model               string          `json:"model"`
+	Messages            []chatMessage   `json:"messages"`
+	MaxTokens           *int            `json:"max_tokens"`
+	MaxCompletionTokens *int            `json:"max_completion_tokens"`
+	Temperature         *float64        `json:"temperature"`
+	TopP                *float64        `json:"top_p"`
+	Stop                json.RawMessage `json:"stop"`
+	Stream              bool            `json:"stream"`
+	StreamOptions       *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+	Tools             []chatTool      `json:"tools"`
+	ToolChoice        json.RawMessage `json:"tool_choice"`
+	ParallelToolCalls *bool           `json:"parallel_tool_calls"`
+	User              string          `json:"user"`
+	N                 *int            `json:"n"`
+}
+
+// maxTokens 返回请求的输出上限，max_completion_tokens 优先。
+func (r chatRequest) maxTokens() (int, bool) {
+	if r.MaxCompletionTokens != nil {
+		return *r.MaxCompletionTokens, true
+	}
+	if r.MaxTokens != nil {
+		return *r.MaxTokens, true
+	}
+	return 0, false
+}
+
+func (r chatRequest) includeUsage() bool {
+	return r.StreamOptions != nil && r.StreamOptions.IncludeUsage
+}
+
+// stopSequences 解析字符串或字符串数组形式的 stop。
+func (r chatRequest) stopSequences() ([]string, error) {
+	if len(r.Stop) == 0 || string(r.Stop) == "null" {
+		return nil, nil
+	}
+	var single string
+	if json.Unmarshal(r.Stop, &single) == nil {
+		return []string{single}, nil
+	}
+	var list []string
+	if err := json.Unmarshal(r.Stop, &list); err != nil {
+		return nil, fmt.Errorf("%w: stop must be a string or an array of strings", ErrInvalidRequest)
+	}
+	return list, nil
+}
+
+type chatMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content"`
+	ToolCalls  []chatToolCall  `json:"tool_calls"`
+	ToolCallID string          `json:"tool_call_id"`
+}
+
+// contentPart 是多模态消息内容的一段，目前支持 text 与 image_url。
+type contentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+}
+
+// parts 把字符串或内容数组形式的 content 统一为内容段列表，null 返回空列表。
+func (m chatMessage) parts() ([]contentPart, error) {
+	if len(m.Content) == 0 || string(m.Content) == "null" {
+		return nil, nil
+	}
+	var text string
+	if json.Unmarshal(m.Content, &text) == nil {
+		return []contentPart{{Type: "text", Text: text}}, nil
+	}
+	var parts []contentPart
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return nil, fmt.Errorf("%w: %s message content must be a string or an array of content parts", ErrInvalidRequest, m.Role)
+	}
+	return parts, nil
+}
+
+// text 返回消息中全部文本段的拼接，用于 system 与工具结果等只接受文本的位置。
+func (m chatMessage) text() (string, error) {
+	parts, err := m.parts()
+	if err != nil {
+		return "", err
+	}
+	var texts []string
+	for _, part := range parts {
+		if part.Type != "text" {
+			return "", fmt.Errorf("%w: %s message only supports text content", ErrInvalidRequest, m.Role)
+		}
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+type chatToolCall struct {
+	Index    *int   `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Parameters  json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+// toolChoice 是 tool_choice 的归一化形式：mode 为 auto、none、required 或 function（此时 name 为函数名）。
+type toolChoice struct {
+	mode string
+	name string
+}
+
+func parseToolChoice(raw json.RawMessage) (toolChoice, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return toolChoice{}, nil
+	}
+	var mode string
+	if json.Unmarshal(raw, &mode) == nil {
+		switch mode {
+		case "auto", "none", "required":
+			return toolChoice{mode: mode}, nil
+		}
+		return toolChoice{}, fmt.Errorf("%w: unsupported tool_choice %q", ErrInvalidRequest, mode)
+	}
+	var named struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(raw, &named); err != nil || named.Type != "function" || named.Function.Name == "" {
+		return toolChoice{}, fmt.Errorf("%w: tool_choice must be auto, none, required or a function", ErrInvalidRequest)
+	}
+	return toolChoice{mode: "function", name: named.Function.Name}, nil
+}
+
+// chatCompletion 是非流式响应与流式分块（object 为 chat.completion.chunk）的共同结构。
+type chatCompletion struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage,omitempty"`
+}
+
+type chatChoice struct {
+	Index        int                  `json:"index"`
+	Message      *chatResponseMessage `json:"message,omitempty"`
+	Delta        *chatDelta           `json:"delta,omitempty"`
+	FinishReason *string              `json:"finish_reason"`
+}
+
+type chatResponseMessage struct {
+	Role      string         `json:"role"`
+	Content   *string        `json:"content"`
+	ToolCalls []chatToolCall `json:"tool_calls,omitempty"`
+}
+
+type chatDelta struct {
+	Role      string         `json:"role,omitempty"`
+	Content   *string        `json:"content,omitempty"`
+	ToolCalls []chatToolCall `json:"tool_calls,omitempty"`
+}
+
+type chatUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+func newChatUsage(prompt, completion int64) *chatUsage {
+	return &chatUsage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+}
+
+// chatError 是 OpenAI 格式的错误响应。
+type chatError struct {
+	Error chatErrorDetail `json:"error"`
+}
+
+type chatErrorDetail struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
+func newChatError(errType, message string) chatError {
+	return chatError{Error: chatErrorDetail{Message: message, Type: errType}}
+}
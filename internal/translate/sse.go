@@ -0,0 +1,92 @@
+package translate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// eventStream 在读取时把上游 SSE 事件逐个转换为客户端事件：每读完一个事件（空行结尾）就把其 data 交给 onEvent，
+// onEvent 通过 send / sendDone 写出转换后的事件。上游结束时调用 onEnd。
+type eventStream struct {
+	src     io.ReadCloser
+	reader  *bufio.Reader
+	data    bytes.Buffer
+	out     bytes.Buffer
+	eof     bool
+	onEvent func(data []byte)
+	onEnd   func()
+}
+
+func newEventStream(src io.ReadCloser) *eventStream {
+	return &eventStream{src: src, reader: bufio.NewReader(src)}
+}
+
+func (s *eventStream) Read(p []byte) (int, error) {
+	for s.out.Len() == 0 {
+		if s.eof {
+			return 0, io.EOF
+		}
+		line, err := s.reader.ReadBytes('\n')
+		if len(line) > 0 {
+			s.line(line)
+		}
+		if err == io.EOF {
+			s.dispatch()
+			if s.onEnd != nil {
+				s.onEnd()
+			}
+			s.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	return s.out.Read(p)
+}
+
+func (s *eventStream) Close() error {
+	return s.src.Close()
+}
+
+// line 处理一行：空行结束当前事件，data 行累积事件数据，event、id 与注释行被忽略（事件类型由 data 中的字段给出）。
+func (s *eventStream) line(line []byte) {
+	line = bytes.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		s.dispatch()
+		return
+	}
+	data, ok := bytes.CutPrefix(line, []byte("data:"))
+	if !ok {
+		return
+	}
+	if s.data.Len() > 0 {
+		s.data.WriteByte('\n')
+	}
+	s.data.Write(bytes.TrimPrefix(data, []byte(" ")))
+}
+
+func (s *eventStream) dispatch() {
+	if s.data.Len() == 0 {
+		return
+	}
+	data := bytes.Clone(s.data.Bytes())
+	s.data.Reset()
+	s.onEvent(data)
+}
+
+// send 写出一个 data 为 v 的 JSON 编码的事件。
+func (s *eventStream) send(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	s.out.WriteString("data: ")
+	s.out.Write(data)
+	s.out.WriteString("\n\n")
+}
+
+// sendDone 写出 OpenAI 流式响应的结束标记。
+func (s *eventStream) sendDone() {
+	s.out.WriteString("data: [DONE]\n\n")
+}
@@ -0,0 +1,188 @@
+// Package translate 在不同 LLM 提供方的 API 协议之间转换请求与响应（含 SSE 流式响应），
+// 使按 OpenAI Chat Completions 编写的客户端无需修改即可使用其他提供方的上游。
+// 每种转换对应规则动作 translate_protocol 的一个取值，由代理在转发时按请求创建 Translator。
+package translate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+const chatCompletionsSuffix = "/chat/completions"
+
+// ErrInvalidRequest 表示客户端请求无法转换为上游协议，例如请求体不是合法 JSON、端点不受支持或使用了上游无法表达的参数。
+var ErrInvalidRequest = errors.New("request cannot be translated")
+
+// Translator 转换一次请求及其响应。每个请求使用新的实例，以便保存请求中影响响应转换的参数（如是否回传用量）。
+type Translator interface {
+	// Request 在转发前把客户端请求改写为上游协议：请求体、路径与认证头。
+	Request(req *http.Request) error
+	// Response 把上游响应改写回客户端协议；流式响应在读取时逐个事件转换。
+	Response(resp *http.Response) error
+}
+
+var factories = map[string]func() Translator{
+	rules.TranslateOpenAIToAnthropic: func() Translator { return &anthropicTranslator{} },
+	rules.TranslateOpenAIToGemini:    func() Translator { return &geminiTranslator{} },
+}
+
+// providers 按上游凭据的 Provider（即 Service）创建默认使用的适配器，规则未指定 translate_protocol 时生效。
+var providers = map[string]func(cred accounts.UpstreamCredential) Translator{
+	"gemini": func(accounts.UpstreamCredential) Translator {
+		return &optionalTranslator{inner: &geminiTranslator{}}
+	},
+	"azure": newAzureTranslator,
+}
+
+// New 返回 protocol（translate_protocol 的取值）对应的转换器，取值未知时 ok 为 false。
+func New(protocol string) (Translator, bool) {
+	factory, ok := factories[protocol]
+	if !ok {
+		return nil, false
+	}
+	return factory(), true
+}
+
+// ForCredential 返回上游凭据 Provider 对应的适配器，适配器可读取凭据元数据中的 Provider 专属配置；
+// 未知 Provider 时 ok 为 false。与规则显式指定不同，协议转换类适配器（如 gemini）只转换 OpenAI
+// Chat Completions 请求，其他请求（如直接调用提供方原生 API）原样转发。
+func ForCredential(cred accounts.UpstreamCredential) (Translator, bool) {
+	factory, ok := providers[strings.ToLower(strings.TrimSpace(cred.Service))]
+	if !ok {
+		return nil, false
+	}
+	return factory(cred), true
+}
+
+// optionalTranslator 仅在请求为 Chat Completions 时委托给 inner。
+type optionalTranslator struct {
+	inner  Translator
+	active bool
+}
+
+func (t *optionalTranslator) Request(req *http.Request) error {
+	t.active = isChatCompletions(req)
+	if !t.active {
+		return nil
+	}
+	return t.inner.Request(req)
+}
+
+func (t *optionalTranslator) Response(resp *http.Response) error {
+	if !t.active {
+		return nil
+	}
+	return t.inner.Response(resp)
+}
+
+// isChatCompletions 报告请求是否为 OpenAI Chat Completions 调用（POST .../chat/completions）。
+func isChatCompletions(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, chatCompletionsSuffix)
+}
+
+// requireChatCompletions 在请求不是 Chat Completions 调用时返回 ErrInvalidRequest。
+func requireChatCompletions(req *http.Request) error {
+	if !isChatCompletions(req) {
+		return fmt.Errorf("%w: only POST .../chat/completions is supported, got %s %s", ErrInvalidRequest, req.Method, req.URL.Path)
+	}
+	return nil
+}
+
+// readJSON 读取并解析请求体。
+func readJSON(req *http.Request, v any) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return fmt.Errorf("%w: missing request body", ErrInvalidRequest)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return fmt.Errorf("%w: read body: %v", ErrInvalidRequest, err)
+	}
+	_ = req.Body.Close()
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	return nil
+}
+
+// setJSONBody 以 v 的 JSON 编码替换请求体并更新长度相关字段。
+func setJSONBody(req *http.Request, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	req.Header.Set("Content-Type", "application/json")
+	// 由 Transport 自行协商压缩并透明解压，否则无法解析上游响应。
+	req.Header.Del("Accept-Encoding")
+	return nil
+}
+
+// rewriteJSONResponse 读取整个响应体，经 convert 转换后替换。
+func rewriteJSONResponse(resp *http.Response, convert func(body []byte) (any, error)) error {
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	converted, err := convert(body)
+	if err != nil {
+		return err
+	}
+	out, err := json.Marshal(converted)
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(out))
+	resp.ContentLength = int64(len(out))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(out)))
+	resp.Header.Set("Content-Type", "application/json")
+	return nil
+}
+
+// parseDataURL 解析 base64 编码的 data URL（data:<media type>;base64,<data>），其他形式的 URL 返回 ok 为 false。
+func parseDataURL(url string) (mediaType, data string, ok bool) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return "", "", false
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	if !ok {
+		return "", "", false
+	}
+	mediaType, ok = strings.CutSuffix(meta, ";base64")
+	return mediaType, data, ok
+}
+
+// compactJSON 返回 raw 的紧凑形式，raw 为空或不是合法 JSON 时返回 "{}"，用于工具调用参数。
+func compactJSON(raw json.RawMessage) string {
+	var out bytes.Buffer
+	if len(raw) == 0 || json.Compact(&out, raw) != nil {
+		return "{}"
+	}
+	return out.String()
+}
+
+func isJSON(header http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+func isEventStream(header http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
@@ -0,0 +1,259 @@
+package upstreams
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prehisle/yapi/internal/translate"
+	"github.com/prehisle/yapi/pkg/accounts"
+	"github.com/prehisle/yapi/pkg/secrets"
+)
+
+// defaultProviderEndpoints 记录常见 Provider 的默认 API 根地址，凭据未配置 Endpoint 时使用。
+var defaultProviderEndpoints = map[string]string{
+	"openai":    "https://api.openai.com/v1",
+	"anthropic": "https://api.anthropic.com/v1",
+	"gemini":    "https://generativelanguage.googleapis.com/v1beta",
+}
+
+// HealthStore 定义检查器读写凭据健康状态所需的账户能力。
+type HealthStore interface {
+	ListEnabledUpstreamCredentials(ctx context.Context) ([]accounts.UpstreamCredential, error)
+	RecordUpstreamHealth(ctx context.Context, credentialID string, health accounts.UpstreamHealth) error
+}
+
+// Checker 通过轻量级的实时调用（GET /models）校验上游凭据是否可用。
+type Checker struct {
+	store   HealthStore
+	secrets secrets.Resolver
+	client  *http.Client
+	logger  *slog.Logger
+	now     func() time.Time
+}
+
+// CheckerOption 定义 Checker 可选项。
+type CheckerOption func(*Checker)
+
+// WithHTTPClient 自定义探测请求使用的 HTTP 客户端。
+func WithHTTPClient(client *http.Client) CheckerOption {
+	return func(c *Checker) {
+		c.client = client
+	}
+}
+
+// WithSecretResolver 设置外部密钥解析器，用于探测使用 secret_ref 的凭据。
+func WithSecretResolver(resolver secrets.Resolver) CheckerOption {
+	return func(c *Checker) {
+		c.secrets = resolver
+	}
+}
+
+// WithLogger 设置结构化日志记录器。
+func WithLogger(logger *slog.Logger) CheckerOption {
+	return func(c *Checker) {
+		c.logger = logger
+	}
+}
+
+// NewChecker 创建凭据检查器。
+func NewChecker(store HealthStore, opts ...CheckerOption) *Checker {
+	c := &Checker{
+		store:  store,
+		client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.logger == nil {
+		c.logger = slog.Default()
+	}
+	return c
+}
+
+// Check 对单个凭据执行一次实时探测，不落库。
+func (c *Checker) Check(ctx context.Context, cred accounts.UpstreamCredential) accounts.UpstreamHealth {
+	health := accounts.UpstreamHealth{Status: accounts.UpstreamHealthUnknown, CheckedAt: c.now()}
+	target, err := modelsURL(cred)
+	if err != nil {
+		health.Detail = err.Error()
+		return health
+	}
+	apiKey, err := c.secretFor(ctx, cred)
+	if err != nil {
+		health.Detail = "secret unavailable: " + err.Error()
+		return health
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		health.Detail = err.Error()
+		return health
+	}
+	applyProviderAuth(req, cred.Service, apiKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		health.Status = accounts.UpstreamHealthUnreachable
+		health.Detail = err.Error()
+		return health
+	}
+	defer resp.Body.Close()
+	health.HTTPStatus = resp.StatusCode
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		health.Status = accounts.UpstreamHealthValid
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		health.Status = accounts.UpstreamHealthInvalid
+		health.Detail = "key rejected (expired, revoked or invalid): " + upstreamErrorMessage(resp.Body)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		health.Status = accounts.UpstreamHealthRateLimited
+		health.Detail = "rate limited"
+		if retry := resp.Header.Get("Retry-After"); retry != "" {
+			health.Detail += ", retry after " + retry
+		}
+	default:
+		health.Status = accounts.UpstreamHealthUnreachable
+		health.Detail = fmt.Sprintf("unexpected status %d: %s", resp.StatusCode, upstreamErrorMessage(resp.Body))
+	}
+	return health
+}
+
+// Verify 探测凭据并记录结果。
+func (c *Checker) Verify(ctx context.Context, cred accounts.UpstreamCredential) (accounts.UpstreamHealth, error) {
+	health := c.Check(ctx, cred)
+	if c.store == nil {
+		return health, nil
+	}
+	if err := c.store.RecordUpstreamHealth(ctx, cred.ID, health); err != nil {
+		return health, err
+	}
+	return health, nil
+}
+
+// CheckAll 依次探测所有启用的凭据。
+func (c *Checker) CheckAll(ctx context.Context) error {
+	if c.store == nil {
+		return errors.New("health store not configured")
+	}
+	creds, err := c.store.ListEnabledUpstreamCredentials(ctx)
+	if err != nil {
+		return err
+	}
+	for _, cred := range creds {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		health, err := c.Verify(ctx, cred)
+		if err != nil {
+			c.logger.Warn("record upstream health failed", "error", err, "credential", cred.ID)
+			continue
+		}
+		if health.Status != accounts.UpstreamHealthValid {
+			c.logger.Warn("upstream credential unhealthy",
+				"credential", cred.ID,
+				"service", cred.Service,
+				"status", health.Status,
+				"detail", health.Detail,
+			)
+		}
+	}
+	return nil
+}
+
+func (c *Checker) secretFor(ctx context.Context, cred accounts.UpstreamCredential) (string, error) {
+	if apiKey := strings.TrimSpace(cred.APIKey); apiKey != "" {
+		return apiKey, nil
+	}
+	if !cred.UsesSecretRef() {
+		return "", errors.New("credential has no secret")
+	}
+	if c.secrets == nil {
+		return "", errors.New("secret resolver not configured")
+	}
+	return c.secrets.Resolve(ctx, cred.SecretRef)
+}
+
+// modelsURL 推导凭据对应 Provider 的模型列表地址。
+func modelsURL(cred accounts.UpstreamCredential) (string, error) {
+	base := ""
+	if endpoints := decodeEndpoints(cred.Endpoints); len(endpoints) > 0 {
+		base = strings.TrimSpace(endpoints[0])
+	}
+	if base == "" {
+		base = defaultProviderEndpoints[strings.ToLower(strings.TrimSpace(cred.Service))]
+	}
+	if base == "" {
+		return "", fmt.Errorf("no endpoint known for provider %q", cred.Service)
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	path := strings.TrimRight(u.Path, "/")
+	if isAzure(cred.Service) {
+		// Azure OpenAI 的模型列表位于资源根地址下，且必须指定 api-version。
+		u.Path = path + "/openai/models"
+		u.RawQuery = url.Values{"api-version": {translate.AzureAPIVersion(cred)}}.Encode()
+		return u.String(), nil
+	}
+	if path == "" {
+		path = "/v1"
+	}
+	u.Path = path + "/models"
+	return u.String(), nil
+}
+
+// applyProviderAuth 按 Provider 约定注入认证头。Gemini 也接受查询参数 key，但 http.Client 的错误信息包含完整 URL，
+// 会把密钥写入健康详情，因此使用请求头。
+func applyProviderAuth(req *http.Request, provider, apiKey string) {
+	switch strings.ToLower(strings.TrimSpace(provider)) {
+	case "anthropic":
+		req.Header.Set("x-api-key", apiKey)
+		req.Header.Set("anthropic-version", "2023-06-01")
+	case "gemini":
+		req.Header.Set("x-goog-api-key", apiKey)
+	case "azure":
+		req.Header.Set("api-key", apiKey)
+	default:
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+}
+
+func isAzure(provider string) bool {
+	return strings.EqualFold(strings.TrimSpace(provider), "azure")
+}
+
+func upstreamErrorMessage(body io.Reader) string {
+	raw, err := io.ReadAll(io.LimitReader(body, 4096))
+	if err != nil || len(raw) == 0 {
+		return ""
+	}
+	var payload struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(raw, &payload) == nil && payload.Error.Message != "" {
+		return payload.Error.Message
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+func decodeEndpoints(raw []byte) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var endpoints []string
+	if err := json.Unmarshal(raw, &endpoints); err != nil {
+		return nil
+	}
+	return endpoints
+}
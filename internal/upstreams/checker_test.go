@@ -0,0 +1,122 @@
+package upstreams
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+
+	"github.com/prehisle/yapi/pkg/accounts"
+)
+
+type healthStoreStub struct {
+	creds    []accounts.UpstreamCredential
+	recorded map[string]accounts.UpstreamHealth
+}
+
+func (s *healthStoreStub) ListEnabledUpstreamCredentials(ctx context.Context) ([]accounts.UpstreamCredential, error) {
+	return s.creds, nil
+}
+
+func (s *healthStoreStub) RecordUpstreamHealth(ctx context.Context, credentialID string, health accounts.UpstreamHealth) error {
+	if s.recorded == nil {
+		s.recorded = make(map[string]accounts.UpstreamHealth)
+	}
+	s.recorded[credentialID] = health
+	return nil
+}
+
+func TestChecker_CheckAll_ClassifiesResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/models", r.URL.Path)
+		switch r.Header.Get("Authorization") {
+		case "Bearer good":
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`{"data":[]}`))
+		case "Bearer limited":
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"message":"invalid api key"}}`))
+		}
+	}))
+	defer server.Close()
+
+	endpoints := datatypes.JSON([]byte(`["` + server.URL + `"]`))
+	store := &healthStoreStub{creds: []accounts.UpstreamCredential{
+		{ID: "good", Service: "openai", APIKey: "good", Endpoints: endpoints},
+		{ID: "limited", Service: "openai", APIKey: "limited", Endpoints: endpoints},
+		{ID: "expired", Service: "openai", APIKey: "expired", Endpoints: endpoints},
+	}}
+	checker := NewChecker(store, WithHTTPClient(server.Client()))
+
+	require.NoError(t, checker.CheckAll(context.Background()))
+	require.Equal(t, accounts.UpstreamHealthValid, store.recorded["good"].Status)
+	require.Equal(t, accounts.UpstreamHealthRateLimited, store.recorded["limited"].Status)
+	require.Contains(t, store.recorded["limited"].Detail, "30")
+	require.Equal(t, accounts.UpstreamHealthInvalid, store.recorded["expired"].Status)
+	require.Contains(t, store.recorded["expired"].Detail, "invalid api key")
+}
+
+func TestChecker_Check_AnthropicHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/models", r.URL.Path)
+		require.Equal(t, "sk-ant", r.Header.Get("x-api-key"))
+		require.NotEmpty(t, r.Header.Get("anthropic-version"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	checker := NewChecker(nil, WithHTTPClient(server.Client()))
+	health := checker.Check(context.Background(), accounts.UpstreamCredential{
+		ID:        "cred-1",
+		Service:   "anthropic",
+		APIKey:    "sk-ant",
+		Endpoints: datatypes.JSON([]byte(`["` + server.URL + `/v1/"]`)),
+	})
+	require.Equal(t, accounts.UpstreamHealthValid, health.Status)
+	require.Equal(t, http.StatusOK, health.HTTPStatus)
+}
+
+func TestChecker_Check_GeminiHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1beta/models", r.URL.Path)
+		require.Equal(t, "gm-key", r.Header.Get("x-goog-api-key"))
+		require.Empty(t, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	checker := NewChecker(nil, WithHTTPClient(server.Client()))
+	health := checker.Check(context.Background(), accounts.UpstreamCredential{
+		ID:        "cred-1",
+		Service:   "gemini",
+		APIKey:    "gm-key",
+		Endpoints: datatypes.JSON([]byte(`["` + server.URL + `/v1beta"]`)),
+	})
+	require.Equal(t, accounts.UpstreamHealthValid, health.Status)
+}
+
+func TestChecker_Check_AzureModelsURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/openai/models", r.URL.Path)
+		require.Equal(t, "2025-01-01-preview", r.URL.Query().Get("api-version"))
+		require.Equal(t, "az-key", r.Header.Get("api-key"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	checker := NewChecker(nil, WithHTTPClient(server.Client()))
+	health := checker.Check(context.Background(), accounts.UpstreamCredential{
+		ID:        "cred-1",
+		Service:   "azure",
+		APIKey:    "az-key",
+		Endpoints: datatypes.JSON([]byte(`["` + server.URL + `"]`)),
+		Metadata:  datatypes.JSONMap{"api_version": "2025-01-01-preview"},
+	})
+	require.Equal(t, accounts.UpstreamHealthValid, health.Status)
+}
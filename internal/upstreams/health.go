@@ -0,0 +1,143 @@
+package upstreams
+
+import (
+	"math/rand/v2"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+const (
+	// defaultEWMAAlpha 是新样本的权重，约等于最近 10 次调用的滑动平均。
+	defaultEWMAAlpha = 0.2
+	// defaultReferenceLatency 是延迟折算为得分的参考值：延迟等于该值时延迟因子为 0.5。
+	defaultReferenceLatency = time.Second
+	// minSelectionWeight 是任一端点的最低选择权重，保证低分端点仍有少量流量，使得分可以恢复。
+	minSelectionWeight = 0.05
+)
+
+// EndpointScorer 按上游端点维护延迟与错误率的指数加权移动平均（EWMA），
+// 并据此在凭据的多个端点间按得分加权随机选择，优先使用更健康的端点。
+//
+// 状态仅保存在进程内存中，多实例部署时各实例独立统计。
+type EndpointScorer struct {
+	mu        sync.Mutex
+	stats     map[string]*endpointStats
+	alpha     float64
+	reference time.Duration
+	random    func() float64
+}
+
+type endpointStats struct {
+	latency   float64 // 秒
+	errorRate float64
+}
+
+// ScorerOption 自定义 EndpointScorer。
+type ScorerOption func(*EndpointScorer)
+
+// WithEWMAAlpha 设置新样本的权重，取值 (0, 1]，越大越敏感。
+func WithEWMAAlpha(alpha float64) ScorerOption {
+	return func(s *EndpointScorer) {
+		if alpha > 0 && alpha <= 1 {
+			s.alpha = alpha
+		}
+	}
+}
+
+// WithReferenceLatency 设置延迟折算为得分的参考值。
+func WithReferenceLatency(latency time.Duration) ScorerOption {
+	return func(s *EndpointScorer) {
+		if latency > 0 {
+			s.reference = latency
+		}
+	}
+}
+
+// NewEndpointScorer 创建端点健康评分器。
+func NewEndpointScorer(opts ...ScorerOption) *EndpointScorer {
+	s := &EndpointScorer{
+		stats:     make(map[string]*endpointStats),
+		alpha:     defaultEWMAAlpha,
+		reference: defaultReferenceLatency,
+		random:    rand.Float64,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// EndpointKey 返回端点的统计键（scheme://host/path），不含查询参数与用户信息。
+func EndpointKey(u *url.URL) string {
+	return u.Scheme + "://" + u.Host + u.Path
+}
+
+// Observe 记录一次调用结果。latency 为收到响应头的耗时；failed 表示网络错误或 5xx。
+func (s *EndpointScorer) Observe(endpoint string, latency time.Duration, failed bool) {
+	if endpoint == "" {
+		return
+	}
+	errorSample := 0.0
+	if failed {
+		errorSample = 1
+	}
+	s.mu.Lock()
+	stats, ok := s.stats[endpoint]
+	if !ok {
+		stats = &endpointStats{latency: latency.Seconds(), errorRate: errorSample}
+		s.stats[endpoint] = stats
+	} else {
+		stats.latency += s.alpha * (latency.Seconds() - stats.latency)
+		stats.errorRate += s.alpha * (errorSample - stats.errorRate)
+	}
+	snapshot := *stats
+	score := s.score(snapshot)
+	s.mu.Unlock()
+	metrics.ObserveEndpointHealth(endpoint, snapshot.latency, snapshot.errorRate, score)
+}
+
+// Score 返回端点得分，范围 [0, 1]，越高越健康；尚无样本的端点为 1。
+func (s *EndpointScorer) Score(endpoint string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats, ok := s.stats[endpoint]
+	if !ok {
+		return 1
+	}
+	return s.score(*stats)
+}
+
+// score 将成功率与延迟因子相乘：reference / (reference + latency)。
+func (s *EndpointScorer) score(stats endpointStats) float64 {
+	reference := s.reference.Seconds()
+	return (1 - stats.errorRate) * reference / (reference + stats.latency)
+}
+
+// Select 按得分加权随机返回端点下标，得分越高被选中的概率越大；endpoints 为空时返回 -1。
+func (s *EndpointScorer) Select(endpoints []string) int {
+	switch len(endpoints) {
+	case 0:
+		return -1
+	case 1:
+		return 0
+	}
+	weights := make([]float64, len(endpoints))
+	total := 0.0
+	for i, endpoint := range endpoints {
+		weights[i] = max(s.Score(endpoint), minSelectionWeight)
+		total += weights[i]
+	}
+	s.mu.Lock()
+	target := s.random() * total
+	s.mu.Unlock()
+	for i, weight := range weights {
+		if target < weight {
+			return i
+		}
+		target -= weight
+	}
+	return len(endpoints) - 1
+}
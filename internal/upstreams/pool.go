@@ -0,0 +1,99 @@
+package upstreams
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prehisle/yapi/pkg/accounts"
+)
+
+// defaultPoolCooldown 是上游返回 429 但未携带 Retry-After 时的默认冷却时长。
+const defaultPoolCooldown = time.Minute
+
+// PoolSelector 在 Key 池成员间轮换，并暂时移除被限流或额度耗尽的 Key。
+//
+// 状态仅保存在进程内存中，多实例部署时各实例独立统计。
+type PoolSelector struct {
+	mu       sync.Mutex
+	cursors  map[string]int
+	lastUsed map[string]time.Time
+	quotas   *QuotaTracker
+	now      func() time.Time
+}
+
+// NewPoolSelector 创建 Key 池选择器。
+func NewPoolSelector() *PoolSelector {
+	s := &PoolSelector{
+		cursors:  make(map[string]int),
+		lastUsed: make(map[string]time.Time),
+		quotas:   NewQuotaTracker(),
+		now:      time.Now,
+	}
+	s.quotas.now = func() time.Time { return s.now() }
+	return s
+}
+
+// Select 按池策略从可用成员中选出一个 Key；所有成员均被暂时移除时返回 false。
+func (s *PoolSelector) Select(pool accounts.UpstreamKeyPool, members []accounts.UpstreamCredential) (accounts.UpstreamCredential, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	available := make([]accounts.UpstreamCredential, 0, len(members))
+	for _, member := range members {
+		if _, exhausted := s.quotas.ExhaustedUntil(member.ID); exhausted {
+			continue
+		}
+		available = append(available, member)
+	}
+	if len(available) == 0 {
+		return accounts.UpstreamCredential{}, false
+	}
+
+	var chosen accounts.UpstreamCredential
+	switch pool.Strategy {
+	case accounts.PoolStrategyLeastRecentlyUsed:
+		chosen = available[0]
+		for _, member := range available[1:] {
+			if s.lastUsed[member.ID].Before(s.lastUsed[chosen.ID]) {
+				chosen = member
+			}
+		}
+	default:
+		cursor := s.cursors[pool.ID] % len(available)
+		chosen = available[cursor]
+		s.cursors[pool.ID] = cursor + 1
+	}
+	s.lastUsed[chosen.ID] = now
+	return chosen, true
+}
+
+// Observe 根据上游响应更新凭据的额度状态，规则见 QuotaTracker.Observe；不属于 Key 池的凭据同样跟踪，供故障转移参考。
+func (s *PoolSelector) Observe(credentialID string, resp *http.Response) {
+	s.quotas.Observe(credentialID, resp)
+}
+
+// ExhaustedUntil 返回 Key 被暂时移除的截止时间。
+func (s *PoolSelector) ExhaustedUntil(credentialID string) (time.Time, bool) {
+	return s.quotas.ExhaustedUntil(credentialID)
+}
+
+// retryAfter 解析 Retry-After 头（秒数或 HTTP 日期）。
+func retryAfter(value string, now time.Time, fallback time.Duration) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fallback
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := at.Sub(now); wait > 0 {
+			return wait
+		}
+		return 0
+	}
+	return fallback
+}
@@ -0,0 +1,66 @@
+package upstreams
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/pkg/accounts"
+)
+
+func TestPoolSelector_RoundRobinSkipsExhausted(t *testing.T) {
+	now := time.Date(2025, time.November, 4, 10, 0, 0, 0, time.UTC)
+	selector := NewPoolSelector()
+	selector.now = func() time.Time { return now }
+	pool := accounts.UpstreamKeyPool{ID: "pool-1", Strategy: accounts.PoolStrategyRoundRobin}
+	members := []accounts.UpstreamCredential{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	var picked []string
+	for i := 0; i < 3; i++ {
+		member, ok := selector.Select(pool, members)
+		require.True(t, ok)
+		picked = append(picked, member.ID)
+	}
+	require.Equal(t, []string{"a", "b", "c"}, picked)
+
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"30"}}}
+	selector.Observe("b", resp)
+	until, ok := selector.ExhaustedUntil("b")
+	require.True(t, ok)
+	require.Equal(t, now.Add(30*time.Second), until)
+
+	for i := 0; i < 4; i++ {
+		member, ok := selector.Select(pool, members)
+		require.True(t, ok)
+		require.NotEqual(t, "b", member.ID)
+	}
+
+	now = now.Add(31 * time.Second)
+	_, ok = selector.ExhaustedUntil("b")
+	require.False(t, ok)
+}
+
+func TestPoolSelector_LeastRecentlyUsed(t *testing.T) {
+	now := time.Date(2025, time.November, 4, 10, 0, 0, 0, time.UTC)
+	selector := NewPoolSelector()
+	selector.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	pool := accounts.UpstreamKeyPool{ID: "pool-1", Strategy: accounts.PoolStrategyLeastRecentlyUsed}
+	members := []accounts.UpstreamCredential{{ID: "a"}, {ID: "b"}}
+
+	first, _ := selector.Select(pool, members)
+	second, _ := selector.Select(pool, members)
+	third, _ := selector.Select(pool, members)
+	require.Equal(t, "a", first.ID)
+	require.Equal(t, "b", second.ID)
+	require.Equal(t, "a", third.ID)
+
+	selector.Observe("a", &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}})
+	selector.Observe("b", &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}})
+	_, ok := selector.Select(pool, members)
+	require.False(t, ok)
+}
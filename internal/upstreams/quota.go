@@ -0,0 +1,139 @@
+package upstreams
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+// 剩余额度的种类，对应指标 gateway_upstream_ratelimit_remaining 的 limit 标签。
+const (
+	LimitRequests = "requests"
+	LimitTokens   = "tokens"
+)
+
+// rateLimitHeaders 按厂商列出剩余额度与重置时间的响应头：OpenAI 的 reset 为时长（如 6m0s），
+// Anthropic 为 RFC 3339 时间，通用的 x-ratelimit-reset 为秒数或 Unix 时间戳。
+var rateLimitHeaders = []struct {
+	limit     string
+	remaining string
+	reset     string
+}{
+	{LimitRequests, "X-Ratelimit-Remaining-Requests", "X-Ratelimit-Reset-Requests"},
+	{LimitTokens, "X-Ratelimit-Remaining-Tokens", "X-Ratelimit-Reset-Tokens"},
+	{LimitRequests, "Anthropic-Ratelimit-Requests-Remaining", "Anthropic-Ratelimit-Requests-Reset"},
+	{LimitTokens, "Anthropic-Ratelimit-Tokens-Remaining", "Anthropic-Ratelimit-Tokens-Reset"},
+	{LimitTokens, "Anthropic-Ratelimit-Input-Tokens-Remaining", "Anthropic-Ratelimit-Input-Tokens-Reset"},
+	{LimitTokens, "Anthropic-Ratelimit-Output-Tokens-Remaining", "Anthropic-Ratelimit-Output-Tokens-Reset"},
+	{LimitRequests, "X-Ratelimit-Remaining", "X-Ratelimit-Reset"},
+}
+
+// QuotaTracker 根据上游响应的 Retry-After 与限流响应头跟踪每个凭据的剩余额度，
+// 凭据返回 429 或报告剩余额度为 0 时在重置前视为耗尽，供 Key 池与故障转移提前绕开。
+//
+// 状态仅保存在进程内存中，多实例部署时各实例独立统计。
+type QuotaTracker struct {
+	mu        sync.Mutex
+	exhausted map[string]time.Time
+	cooldown  time.Duration
+	now       func() time.Time
+}
+
+// NewQuotaTracker 创建额度跟踪器。
+func NewQuotaTracker() *QuotaTracker {
+	return &QuotaTracker{
+		exhausted: make(map[string]time.Time),
+		cooldown:  defaultPoolCooldown,
+		now:       time.Now,
+	}
+}
+
+// Observe 根据上游响应更新凭据状态并上报剩余额度：429 时按 Retry-After（缺省一分钟）视为耗尽；
+// 任一限流响应头报告剩余额度为 0 时视为耗尽到对应的重置时间；其余成功响应清除耗尽状态。
+func (q *QuotaTracker) Observe(credentialID string, resp *http.Response) {
+	if credentialID == "" || resp == nil {
+		return
+	}
+	now := q.now()
+	var until time.Time
+	for _, h := range rateLimitHeaders {
+		raw := strings.TrimSpace(resp.Header.Get(h.remaining))
+		if raw == "" {
+			continue
+		}
+		remaining, err := strconv.Atoi(raw)
+		if err != nil {
+			continue
+		}
+		metrics.ObserveUpstreamRemaining(credentialID, h.limit, remaining)
+		if remaining <= 0 {
+			if reset := now.Add(resetAfter(resp.Header.Get(h.reset), now, q.cooldown)); reset.After(until) {
+				until = reset
+			}
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if retry := now.Add(retryAfter(resp.Header.Get("Retry-After"), now, q.cooldown)); retry.After(until) {
+			until = retry
+		}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	switch {
+	case until.After(now):
+		if prev, already := q.exhausted[credentialID]; !already || !now.Before(prev) {
+			metrics.ObserveUpstreamRateLimited(credentialID)
+		}
+		q.exhausted[credentialID] = until
+	case resp.StatusCode < http.StatusBadRequest:
+		delete(q.exhausted, credentialID)
+	}
+}
+
+// ExhaustedUntil 返回凭据额度耗尽的截止时间，已过期或未耗尽时返回 false。
+func (q *QuotaTracker) ExhaustedUntil(credentialID string) (time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	until, ok := q.exhausted[credentialID]
+	if !ok {
+		return time.Time{}, false
+	}
+	if !q.now().Before(until) {
+		delete(q.exhausted, credentialID)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// RetryAfter 解析 Retry-After 头（秒数或 HTTP 日期），缺失或无法解析时返回 false。
+func RetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	const unset = -1
+	wait := retryAfter(header.Get("Retry-After"), now, unset)
+	return max(wait, 0), wait != unset
+}
+
+// resetAfter 解析限流重置时间：时长（6m0s、20ms）、RFC 3339 时间、秒数或 Unix 时间戳。
+func resetAfter(value string, now time.Time, fallback time.Duration) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fallback
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return d
+	}
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		// 超过十亿的取值视为 Unix 时间戳。
+		if seconds > 1e9 {
+			return max(time.Unix(int64(seconds), 0).Sub(now), 0)
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return fallback
+}
@@ -0,0 +1,60 @@
+package upstreams
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/pkg/metrics"
+)
+
+func TestQuotaTracker_ExhaustedByRemainingHeaders(t *testing.T) {
+	now := time.Date(2025, time.November, 4, 10, 0, 0, 0, time.UTC)
+	tracker := NewQuotaTracker()
+	tracker.now = func() time.Time { return now }
+
+	tracker.Observe("anthropic", &http.Response{StatusCode: http.StatusOK, Header: http.Header{
+		"Anthropic-Ratelimit-Requests-Remaining": {"12"},
+		"Anthropic-Ratelimit-Tokens-Remaining":   {"0"},
+		"Anthropic-Ratelimit-Tokens-Reset":       {now.Add(20 * time.Second).Format(time.RFC3339)},
+	}})
+	until, ok := tracker.ExhaustedUntil("anthropic")
+	require.True(t, ok)
+	require.Equal(t, now.Add(20*time.Second), until)
+	require.Equal(t, 12.0, testutil.ToFloat64(metrics.UpstreamRateLimitRemaining.WithLabelValues("anthropic", LimitRequests)))
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.UpstreamRateLimitRemaining.WithLabelValues("anthropic", LimitTokens)))
+
+	tracker.Observe("openai", &http.Response{StatusCode: http.StatusOK, Header: http.Header{
+		"X-Ratelimit-Remaining-Requests": {"0"},
+		"X-Ratelimit-Reset-Requests":     {"1.5s"},
+	}})
+	until, ok = tracker.ExhaustedUntil("openai")
+	require.True(t, ok)
+	require.Equal(t, now.Add(1500*time.Millisecond), until)
+
+	// 额度恢复后的成功响应立即清除耗尽状态。
+	tracker.Observe("openai", &http.Response{StatusCode: http.StatusOK, Header: http.Header{"X-Ratelimit-Remaining-Requests": {"99"}}})
+	_, ok = tracker.ExhaustedUntil("openai")
+	require.False(t, ok)
+
+	now = now.Add(21 * time.Second)
+	_, ok = tracker.ExhaustedUntil("anthropic")
+	require.False(t, ok)
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, time.November, 4, 10, 0, 0, 0, time.UTC)
+	wait, ok := RetryAfter(http.Header{"Retry-After": {"7"}}, now)
+	require.True(t, ok)
+	require.Equal(t, 7*time.Second, wait)
+
+	wait, ok = RetryAfter(http.Header{"Retry-After": {now.Add(time.Minute).Format(http.TimeFormat)}}, now)
+	require.True(t, ok)
+	require.Equal(t, time.Minute, wait)
+
+	_, ok = RetryAfter(http.Header{}, now)
+	require.False(t, ok)
+}
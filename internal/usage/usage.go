@@ -0,0 +1,260 @@
+// Package usage 从上游 LLM 响应中提取 token 用量，并按模型价格表估算费用。
+// 支持 OpenAI（prompt_tokens / completion_tokens）、Anthropic（input_tokens / output_tokens）与
+// Gemini（usageMetadata）格式，
+// 覆盖普通 JSON 响应与 SSE 流式响应；OpenAI 流式响应仅在请求设置 stream_options.include_usage 时携带用量。
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxBodyBytes 是非流式响应缓冲的上限，超出后放弃提取，避免大响应占用内存。
+const maxBodyBytes = 4 << 20
+
+// Usage 是一次调用的 token 用量。ResponseID 与 FinishReasons 为响应中附带的标识与结束原因，
+// 供追踪按 GenAI 语义约定标注，不参与计费。
+type Usage struct {
+	Model            string
+	PromptTokens     int64
+	CompletionTokens int64
+	ResponseID       string
+	FinishReasons    []string
+}
+
+// Empty 报告是否未提取到任何用量。
+func (u Usage) Empty() bool {
+	return u.PromptTokens == 0 && u.CompletionTokens == 0
+}
+
+// Extractor 以流式方式接收响应体并提取用量，零值不可用，需通过 NewExtractor 创建。
+type Extractor struct {
+	stream   bool
+	buf      bytes.Buffer
+	overflow bool
+	usage    Usage
+}
+
+// NewExtractor 按响应的 Content-Type 创建提取器，text/event-stream 按 SSE 事件逐条解析。
+func NewExtractor(contentType string) *Extractor {
+	return &Extractor{stream: strings.HasPrefix(strings.ToLower(strings.TrimSpace(contentType)), "text/event-stream")}
+}
+
+// Write 接收一段响应体，始终返回 len(p)，解析失败不影响响应转发。
+func (e *Extractor) Write(p []byte) (int, error) {
+	if e.overflow {
+		return len(p), nil
+	}
+	if !e.stream {
+		if e.buf.Len()+len(p) > maxBodyBytes {
+			e.overflow = true
+			e.buf.Reset()
+			return len(p), nil
+		}
+		e.buf.Write(p)
+		return len(p), nil
+	}
+	e.buf.Write(p)
+	for {
+		line, err := e.buf.ReadBytes('\n')
+		if err != nil {
+			// 不完整的行放回缓冲，等待后续数据。
+			rest := append([]byte(nil), line...)
+			e.buf.Reset()
+			e.buf.Write(rest)
+			if e.buf.Len() > maxBodyBytes {
+				e.overflow = true
+				e.buf.Reset()
+			}
+			return len(p), nil
+		}
+		e.parseEvent(line)
+	}
+}
+
+// Result 返回提取到的用量，未提取到任何 token 数时 ok 为 false。
+func (e *Extractor) Result() (Usage, bool) {
+	if e.overflow {
+		return Usage{}, false
+	}
+	if e.stream {
+		e.parseEvent(e.buf.Bytes())
+		e.buf.Reset()
+	} else if e.buf.Len() > 0 {
+		var body payload
+		if json.Unmarshal(e.buf.Bytes(), &body) == nil {
+			e.apply(body)
+		}
+		e.buf.Reset()
+	}
+	return e.usage, !e.usage.Empty()
+}
+
+func (e *Extractor) parseEvent(line []byte) {
+	line = bytes.TrimSpace(line)
+	data, ok := bytes.CutPrefix(line, []byte("data:"))
+	if !ok {
+		return
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return
+	}
+	var event payload
+	if json.Unmarshal(data, &event) == nil {
+		e.apply(event)
+	}
+}
+
+// payload 覆盖各家响应与流式事件中与用量相关的字段。
+// Anthropic 流式响应在 message_start 的 message 中给出模型与输入 token，在 message_delta 中给出累计输出 token；
+// Gemini 在 modelVersion 与 usageMetadata 中给出模型与累计用量，思考 token 计入输出。
+// 结束原因分别取自 OpenAI 的 choices[].finish_reason、Anthropic 的 stop_reason（流式在 message_delta 的 delta 中）
+// 与 Gemini 的 candidates[].finishReason。
+type payload struct {
+	ID      string      `json:"id"`
+	Model   string      `json:"model"`
+	Usage   *tokenCount `json:"usage"`
+	Message *struct {
+		ID    string      `json:"id"`
+		Model string      `json:"model"`
+		Usage *tokenCount `json:"usage"`
+	} `json:"message"`
+	Choices []struct {
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	StopReason string `json:"stop_reason"`
+	Delta      *struct {
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	ResponseID string `json:"responseId"`
+	Candidates []struct {
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	ModelVersion  string `json:"modelVersion"`
+	UsageMetadata *struct {
+		PromptTokenCount     int64 `json:"promptTokenCount"`
+		CandidatesTokenCount int64 `json:"candidatesTokenCount"`
+		ThoughtsTokenCount   int64 `json:"thoughtsTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+type tokenCount struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	InputTokens      int64 `json:"input_tokens"`
+	OutputTokens     int64 `json:"output_tokens"`
+}
+
+func (e *Extractor) apply(p payload) {
+	if p.Message != nil {
+		e.apply(payload{ID: p.Message.ID, Model: p.Message.Model, Usage: p.Message.Usage})
+	}
+	if p.ModelVersion != "" || p.UsageMetadata != nil {
+		converted := payload{ID: p.ResponseID, Model: p.ModelVersion}
+		if meta := p.UsageMetadata; meta != nil {
+			converted.Usage = &tokenCount{PromptTokens: meta.PromptTokenCount, CompletionTokens: meta.CandidatesTokenCount + meta.ThoughtsTokenCount}
+		}
+		e.apply(converted)
+	}
+	if p.ID != "" && e.usage.ResponseID == "" {
+		e.usage.ResponseID = p.ID
+	}
+	if p.Model != "" {
+		e.usage.Model = p.Model
+	}
+	if reasons := p.finishReasons(); len(reasons) > 0 {
+		e.usage.FinishReasons = reasons
+	}
+	if p.Usage == nil {
+		return
+	}
+	// 流式事件中的用量为累计值，后到的非零值覆盖先前的值。
+	if prompt := max(p.Usage.PromptTokens, p.Usage.InputTokens); prompt > 0 {
+		e.usage.PromptTokens = prompt
+	}
+	if completion := max(p.Usage.CompletionTokens, p.Usage.OutputTokens); completion > 0 {
+		e.usage.CompletionTokens = completion
+	}
+}
+
+// finishReasons 返回事件中非空的结束原因，流式响应只有最后的事件携带。
+func (p payload) finishReasons() []string {
+	var reasons []string
+	for _, choice := range p.Choices {
+		if choice.FinishReason != "" {
+			reasons = append(reasons, choice.FinishReason)
+		}
+	}
+	for _, candidate := range p.Candidates {
+		if candidate.FinishReason != "" {
+			reasons = append(reasons, candidate.FinishReason)
+		}
+	}
+	if p.StopReason != "" {
+		reasons = append(reasons, p.StopReason)
+	}
+	if p.Delta != nil && p.Delta.StopReason != "" {
+		reasons = append(reasons, p.Delta.StopReason)
+	}
+	return reasons
+}
+
+// Price 是模型每百万 token 的美元单价。
+type Price struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// Pricing 是按模型名索引的价格表。
+type Pricing map[string]Price
+
+// ErrInvalidPricing 表示价格表格式错误。
+var ErrInvalidPricing = errors.New("invalid model pricing")
+
+// ParsePricing 解析 JSON 价格表，例如 {"gpt-4o":{"prompt":2.5,"completion":10}}，空字符串返回空表。
+func ParsePricing(raw string) (Pricing, error) {
+	if strings.TrimSpace(raw) == "" {
+		return Pricing{}, nil
+	}
+	var pricing Pricing
+	if err := json.Unmarshal([]byte(raw), &pricing); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPricing, err)
+	}
+	for model, price := range pricing {
+		if price.Prompt < 0 || price.Completion < 0 {
+			return nil, fmt.Errorf("%w: negative price for %q", ErrInvalidPricing, model)
+		}
+	}
+	return pricing, nil
+}
+
+// Lookup 返回模型单价。先精确匹配，再取作为模型名前缀的最长条目，
+// 使 "gpt-4o" 覆盖 "gpt-4o-2024-08-06" 等带日期的版本。
+func (p Pricing) Lookup(model string) (Price, bool) {
+	if price, ok := p[model]; ok {
+		return price, true
+	}
+	best := ""
+	for name := range p {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return p[best], true
+}
+
+// Cost 估算一次调用的美元费用，模型未定价时返回 0。
+func (p Pricing) Cost(u Usage) float64 {
+	price, ok := p.Lookup(u.Model)
+	if !ok {
+		return 0
+	}
+	return (float64(u.PromptTokens)*price.Prompt + float64(u.CompletionTokens)*price.Completion) / 1e6
+}
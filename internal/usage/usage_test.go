@@ -0,0 +1,75 @@
+package usage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractor_OpenAIJSON(t *testing.T) {
+	e := NewExtractor("application/json; charset=utf-8")
+	_, _ = e.Write([]byte(`{"id":"chatcmpl-1","model":"gpt-4o-2024-08-06",`))
+	_, _ = e.Write([]byte(`"choices":[{"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":30,"total_tokens":42}}`))
+	got, ok := e.Result()
+	require.True(t, ok)
+	require.Equal(t, Usage{Model: "gpt-4o-2024-08-06", PromptTokens: 12, CompletionTokens: 30, ResponseID: "chatcmpl-1", FinishReasons: []string{"stop"}}, got)
+}
+
+func TestExtractor_AnthropicStream(t *testing.T) {
+	e := NewExtractor("text/event-stream")
+	stream := "event: message_start\n" +
+		`data: {"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4","usage":{"input_tokens":25,"output_tokens":1}}}` + "\n\n" +
+		"event: content_block_delta\n" +
+		`data: {"type":"content_block_delta","delta":{"text":"hi"}}` + "\n\n" +
+		"event: message_delta\n" +
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":15}}` + "\n\n"
+	// 按任意位置切分，验证跨块的事件行能被正确拼接。
+	for _, chunk := range []string{stream[:40], stream[40:170], stream[170:]} {
+		_, _ = e.Write([]byte(chunk))
+	}
+	got, ok := e.Result()
+	require.True(t, ok)
+	require.Equal(t, Usage{Model: "claude-sonnet-4", PromptTokens: 25, CompletionTokens: 15, ResponseID: "msg_1", FinishReasons: []string{"end_turn"}}, got)
+}
+
+func TestExtractor_GeminiStream(t *testing.T) {
+	e := NewExtractor("text/event-stream")
+	_, _ = e.Write([]byte(`data: {"candidates":[{"content":{"parts":[{"text":"he"}]}}],"usageMetadata":{"promptTokenCount":8,"candidatesTokenCount":1},"modelVersion":"gemini-2.5-flash"}` + "\r\n\r\n"))
+	_, _ = e.Write([]byte(`data: {"candidates":[{"content":{"parts":[{"text":"llo"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":8,"candidatesTokenCount":4,"thoughtsTokenCount":6},"modelVersion":"gemini-2.5-flash","responseId":"resp-1"}` + "\r\n\r\n"))
+	got, ok := e.Result()
+	require.True(t, ok)
+	require.Equal(t, Usage{Model: "gemini-2.5-flash", PromptTokens: 8, CompletionTokens: 10, ResponseID: "resp-1", FinishReasons: []string{"STOP"}}, got)
+}
+
+func TestExtractor_OpenAIStreamWithoutUsage(t *testing.T) {
+	e := NewExtractor("text/event-stream")
+	_, _ = e.Write([]byte("data: {\"model\":\"gpt-4o\",\"choices\":[]}\n\ndata: [DONE]\n\n"))
+	got, ok := e.Result()
+	require.False(t, ok)
+	require.Equal(t, "gpt-4o", got.Model)
+}
+
+func TestExtractor_IgnoresOversizedBody(t *testing.T) {
+	e := NewExtractor("application/json")
+	_, _ = e.Write(make([]byte, maxBodyBytes+1))
+	_, _ = e.Write([]byte(`{"usage":{"prompt_tokens":1}}`))
+	_, ok := e.Result()
+	require.False(t, ok)
+}
+
+func TestPricing_Cost(t *testing.T) {
+	pricing, err := ParsePricing(`{"gpt-4o":{"prompt":2.5,"completion":10},"gpt-4o-mini":{"prompt":0.15,"completion":0.6}}`)
+	require.NoError(t, err)
+
+	require.InDelta(t, 0.0035, pricing.Cost(Usage{Model: "gpt-4o-2024-08-06", PromptTokens: 1000, CompletionTokens: 100}), 1e-12)
+	require.InDelta(t, 0.00021, pricing.Cost(Usage{Model: "gpt-4o-mini", PromptTokens: 1000, CompletionTokens: 100}), 1e-12)
+	require.Zero(t, pricing.Cost(Usage{Model: "claude-sonnet-4", PromptTokens: 1000}))
+
+	_, err = ParsePricing(`{"gpt-4o":{"prompt":-1}}`)
+	require.ErrorIs(t, err, ErrInvalidPricing)
+	_, err = ParsePricing(`not json`)
+	require.ErrorIs(t, err, ErrInvalidPricing)
+	empty, err := ParsePricing("")
+	require.NoError(t, err)
+	require.Empty(t, empty)
+}
@@ -0,0 +1,213 @@
+package accounts
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported secret hashing algorithms.
+const (
+	HashAlgorithmBcrypt     = "bcrypt"
+	HashAlgorithmArgon2id   = "argon2id"
+	HashAlgorithmHMACSHA256 = "hmac-sha256"
+)
+
+const (
+	argon2idPrefix   = "$argon2id$"
+	hmacSHA256Prefix = "$hmac-sha256$"
+	argon2SaltLength = 16
+)
+
+// SecretHasher hashes and verifies API key secrets.
+//
+// Verify must accept hashes produced by any supported algorithm so that
+// switching the configured algorithm does not invalidate existing keys;
+// NeedsRehash reports whether a stored hash should be upgraded to the
+// current algorithm/parameters after a successful verification.
+type SecretHasher interface {
+	Algorithm() string
+	Hash(secret string) (string, error)
+	Verify(hash, secret string) bool
+	NeedsRehash(hash string) bool
+}
+
+// HashConfig selects and tunes the secret hashing algorithm.
+type HashConfig struct {
+	Algorithm      string
+	BcryptCost     int
+	Argon2Time     uint32
+	Argon2MemoryKB uint32
+	Argon2Threads  uint8
+	HMACKey        []byte
+}
+
+// NewSecretHasher builds a SecretHasher from the given config.
+func NewSecretHasher(cfg HashConfig) (SecretHasher, error) {
+	base := multiHasher{
+		bcryptCost: cfg.BcryptCost,
+		argon2: argon2Params{
+			time:    cfg.Argon2Time,
+			memory:  cfg.Argon2MemoryKB,
+			threads: cfg.Argon2Threads,
+		},
+		hmacKey: cfg.HMACKey,
+	}
+	if base.bcryptCost == 0 {
+		base.bcryptCost = bcrypt.DefaultCost
+	}
+	if base.bcryptCost < bcrypt.MinCost || base.bcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("%w: bcrypt cost %d out of range", ErrInvalidInput, base.bcryptCost)
+	}
+	if base.argon2.time == 0 {
+		base.argon2.time = 1
+	}
+	if base.argon2.memory == 0 {
+		base.argon2.memory = 64 * 1024
+	}
+	if base.argon2.threads == 0 {
+		base.argon2.threads = 4
+	}
+
+	switch strings.ToLower(strings.TrimSpace(cfg.Algorithm)) {
+	case "", HashAlgorithmBcrypt:
+		base.algorithm = HashAlgorithmBcrypt
+	case HashAlgorithmArgon2id:
+		base.algorithm = HashAlgorithmArgon2id
+	case HashAlgorithmHMACSHA256:
+		if len(cfg.HMACKey) == 0 {
+			return nil, fmt.Errorf("%w: hmac-sha256 requires a key", ErrInvalidInput)
+		}
+		base.algorithm = HashAlgorithmHMACSHA256
+	default:
+		return nil, fmt.Errorf("%w: unsupported hash algorithm %q", ErrInvalidInput, cfg.Algorithm)
+	}
+	return &base, nil
+}
+
+// DefaultSecretHasher returns the bcrypt hasher with the default cost.
+func DefaultSecretHasher() SecretHasher {
+	return &multiHasher{
+		algorithm:  HashAlgorithmBcrypt,
+		bcryptCost: bcrypt.DefaultCost,
+		argon2:     argon2Params{time: 1, memory: 64 * 1024, threads: 4},
+	}
+}
+
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+// multiHasher hashes with the configured algorithm and verifies any known format.
+type multiHasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2     argon2Params
+	hmacKey    []byte
+}
+
+func (h *multiHasher) Algorithm() string {
+	return h.algorithm
+}
+
+func (h *multiHasher) Hash(secret string) (string, error) {
+	if strings.TrimSpace(secret) == "" {
+		return "", fmt.Errorf("%w: secret empty", ErrInvalidInput)
+	}
+	switch h.algorithm {
+	case HashAlgorithmArgon2id:
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		sum := argon2.IDKey([]byte(secret), salt, h.argon2.time, h.argon2.memory, h.argon2.threads, 32)
+		return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+			h.argon2.memory, h.argon2.time, h.argon2.threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(sum)), nil
+	case HashAlgorithmHMACSHA256:
+		return hmacSHA256Prefix + h.hmacHex(secret), nil
+	default:
+		hash, err := bcrypt.GenerateFromPassword([]byte(secret), h.bcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+}
+
+func (h *multiHasher) Verify(hash, secret string) bool {
+	switch {
+	case strings.HasPrefix(hash, argon2idPrefix):
+		params, salt, sum, err := parseArgon2idHash(hash)
+		if err != nil {
+			return false
+		}
+		candidate := argon2.IDKey([]byte(secret), salt, params.time, params.memory, params.threads, uint32(len(sum)))
+		return subtle.ConstantTimeCompare(candidate, sum) == 1
+	case strings.HasPrefix(hash, hmacSHA256Prefix):
+		if len(h.hmacKey) == 0 {
+			return false
+		}
+		expected := strings.TrimPrefix(hash, hmacSHA256Prefix)
+		return subtle.ConstantTimeCompare([]byte(h.hmacHex(secret)), []byte(expected)) == 1
+	default:
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil
+	}
+}
+
+func (h *multiHasher) NeedsRehash(hash string) bool {
+	switch h.algorithm {
+	case HashAlgorithmArgon2id:
+		if !strings.HasPrefix(hash, argon2idPrefix) {
+			return true
+		}
+		params, _, _, err := parseArgon2idHash(hash)
+		return err != nil || params != h.argon2
+	case HashAlgorithmHMACSHA256:
+		return !strings.HasPrefix(hash, hmacSHA256Prefix)
+	default:
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != h.bcryptCost
+	}
+}
+
+func (h *multiHasher) hmacHex(secret string) string {
+	mac := hmac.New(sha256.New, h.hmacKey)
+	mac.Write([]byte(secret))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func parseArgon2idHash(hash string) (argon2Params, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(hash, argon2idPrefix), "$")
+	if len(parts) != 4 {
+		return argon2Params{}, nil, nil, fmt.Errorf("%w: argon2id hash malformed", ErrInvalidInput)
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[0], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2Params{}, nil, nil, fmt.Errorf("%w: argon2id version unsupported", ErrInvalidInput)
+	}
+	var params argon2Params
+	if _, err := fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return argon2Params{}, nil, nil, fmt.Errorf("%w: argon2id params malformed", ErrInvalidInput)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return argon2Params{}, nil, nil, fmt.Errorf("%w: argon2id salt malformed", ErrInvalidInput)
+	}
+	sum, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(sum) == 0 {
+		return argon2Params{}, nil, nil, fmt.Errorf("%w: argon2id digest malformed", ErrInvalidInput)
+	}
+	return params, salt, sum, nil
+}
@@ -0,0 +1,102 @@
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RateLimits caps request throughput. Zero means unlimited on a user and
+// "inherit from the owning user" on an API key.
+type RateLimits struct {
+	MaxRequestsPerMinute  int `gorm:"type:int;default:0"`
+	MaxConcurrentRequests int `gorm:"type:int;default:0"`
+}
+
+// Validate rejects negative limits.
+func (l RateLimits) Validate() error {
+	if l.MaxRequestsPerMinute < 0 {
+		return fmt.Errorf("%w: max_requests_per_minute must not be negative", ErrInvalidInput)
+	}
+	if l.MaxConcurrentRequests < 0 {
+		return fmt.Errorf("%w: max_concurrent_requests must not be negative", ErrInvalidInput)
+	}
+	return nil
+}
+
+// RateLimitScope identifies which entity owns an effective limit so that
+// counters are shared by every key that inherits it.
+type RateLimitScope struct {
+	Kind string
+	ID   string
+}
+
+// Rate limit scope kinds.
+const (
+	RateLimitScopeUser   = "user"
+	RateLimitScopeAPIKey = "api_key"
+)
+
+// EffectiveLimit is a single resolved limit and the scope its counter belongs to.
+type EffectiveLimit struct {
+	Limit int
+	Scope RateLimitScope
+}
+
+// EffectiveRateLimits resolves the limits that apply to a request made with
+// key on behalf of user. Non-zero API key limits override the user's.
+func EffectiveRateLimits(user User, key APIKey) (rpm EffectiveLimit, concurrency EffectiveLimit) {
+	userScope := RateLimitScope{Kind: RateLimitScopeUser, ID: user.ID}
+	keyScope := RateLimitScope{Kind: RateLimitScopeAPIKey, ID: key.ID}
+	rpm = EffectiveLimit{Limit: user.MaxRequestsPerMinute, Scope: userScope}
+	if key.ID != "" && key.MaxRequestsPerMinute > 0 {
+		rpm = EffectiveLimit{Limit: key.MaxRequestsPerMinute, Scope: keyScope}
+	}
+	concurrency = EffectiveLimit{Limit: user.MaxConcurrentRequests, Scope: userScope}
+	if key.ID != "" && key.MaxConcurrentRequests > 0 {
+		concurrency = EffectiveLimit{Limit: key.MaxConcurrentRequests, Scope: keyScope}
+	}
+	return rpm, concurrency
+}
+
+func (s *service) SetUserRateLimits(ctx context.Context, userID string, limits RateLimits) (User, error) {
+	if strings.TrimSpace(userID) == "" {
+		return User{}, fmt.Errorf("%w: user_id required", ErrInvalidInput)
+	}
+	if err := limits.Validate(); err != nil {
+		return User{}, err
+	}
+	if err := s.updateRateLimits(ctx, &User{}, userID, limits); err != nil {
+		return User{}, err
+	}
+	return s.GetUser(ctx, userID)
+}
+
+func (s *service) SetAPIKeyRateLimits(ctx context.Context, apiKeyID string, limits RateLimits) (APIKey, error) {
+	if strings.TrimSpace(apiKeyID) == "" {
+		return APIKey{}, fmt.Errorf("%w: api_key_id required", ErrInvalidInput)
+	}
+	if err := limits.Validate(); err != nil {
+		return APIKey{}, err
+	}
+	if err := s.updateRateLimits(ctx, &APIKey{}, apiKeyID, limits); err != nil {
+		return APIKey{}, err
+	}
+	return s.GetUserAPIKey(ctx, apiKeyID)
+}
+
+func (s *service) updateRateLimits(ctx context.Context, model any, id string, limits RateLimits) error {
+	result := s.db.WithContext(ctx).Model(model).Where("id = ?", id).Updates(map[string]any{
+		"max_requests_per_minute": limits.MaxRequestsPerMinute,
+		"max_concurrent_requests": limits.MaxConcurrentRequests,
+		"updated_at":              time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
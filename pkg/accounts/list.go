@@ -0,0 +1,49 @@
+package accounts
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ListOptions controls pagination and keyword filtering for list queries.
+// A zero Limit returns all matching rows.
+type ListOptions struct {
+	Limit  int
+	Offset int
+	Search string
+}
+
+// paginate counts the rows matching query, then applies search, ordering and
+// limit/offset. Search is a case-insensitive substring match on columns.
+func paginate(query *gorm.DB, opts ListOptions, dest any, columns ...string) (int64, error) {
+	if keyword := strings.ToLower(strings.TrimSpace(opts.Search)); keyword != "" && len(columns) > 0 {
+		pattern := "%" + escapeLike(keyword) + "%"
+		clauses := make([]string, 0, len(columns))
+		args := make([]any, 0, len(columns))
+		for _, column := range columns {
+			clauses = append(clauses, "LOWER("+column+") LIKE ? ESCAPE '\\'")
+			args = append(args, pattern)
+		}
+		query = query.Where(strings.Join(clauses, " OR "), args...)
+	}
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	query = query.Order("created_at ASC")
+	if opts.Offset > 0 {
+		query = query.Offset(opts.Offset)
+	}
+	if opts.Limit > 0 {
+		query = query.Limit(opts.Limit)
+	}
+	if err := query.Find(dest).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,123 @@
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// ModelAccess restricts which models a user or API key may request. Entries
+// match a model exactly (case-insensitive) or, when ending in "*", by prefix.
+// An empty allowlist permits every model that is not denied.
+type ModelAccess struct {
+	AllowedModels datatypes.JSONSlice[string]
+	DeniedModels  datatypes.JSONSlice[string]
+}
+
+// Validate rejects blank patterns and wildcards anywhere but the end.
+func (a ModelAccess) Validate() error {
+	for field, patterns := range map[string][]string{"allowed_models": a.AllowedModels, "denied_models": a.DeniedModels} {
+		for i, pattern := range patterns {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				return fmt.Errorf("%w: %s[%d] must not be empty", ErrInvalidInput, field, i)
+			}
+			if strings.Contains(strings.TrimSuffix(pattern, "*"), "*") {
+				return fmt.Errorf("%w: %s[%d] may only use * as a trailing wildcard", ErrInvalidInput, field, i)
+			}
+		}
+	}
+	return nil
+}
+
+// Permits reports whether model passes this access list: it must not be
+// denied and, when an allowlist is set, must be allowed.
+func (a ModelAccess) Permits(model string) bool {
+	if matchesModel(a.DeniedModels, model) {
+		return false
+	}
+	return len(a.AllowedModels) == 0 || matchesModel(a.AllowedModels, model)
+}
+
+// ModelPermitted reports whether a request for model made with key on behalf
+// of user is allowed. Both lists apply: an API key can only narrow what its
+// owning user may request.
+func ModelPermitted(user User, key APIKey, model string) bool {
+	return user.ModelAccess.Permits(model) && key.ModelAccess.Permits(model)
+}
+
+func matchesModel(patterns []string, model string) bool {
+	model = strings.ToLower(strings.TrimSpace(model))
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(model, prefix) {
+				return true
+			}
+			continue
+		}
+		if pattern == model {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeModelAccess trims patterns and drops empty lists so they are stored as null.
+func normalizeModelAccess(access ModelAccess) ModelAccess {
+	trim := func(patterns []string) datatypes.JSONSlice[string] {
+		if len(patterns) == 0 {
+			return nil
+		}
+		out := make(datatypes.JSONSlice[string], 0, len(patterns))
+		for _, pattern := range patterns {
+			out = append(out, strings.TrimSpace(pattern))
+		}
+		return out
+	}
+	return ModelAccess{AllowedModels: trim(access.AllowedModels), DeniedModels: trim(access.DeniedModels)}
+}
+
+func (s *service) SetUserModelAccess(ctx context.Context, userID string, access ModelAccess) (User, error) {
+	if strings.TrimSpace(userID) == "" {
+		return User{}, fmt.Errorf("%w: user_id required", ErrInvalidInput)
+	}
+	if err := access.Validate(); err != nil {
+		return User{}, err
+	}
+	if err := s.updateModelAccess(ctx, &User{}, userID, normalizeModelAccess(access)); err != nil {
+		return User{}, err
+	}
+	return s.GetUser(ctx, userID)
+}
+
+func (s *service) SetAPIKeyModelAccess(ctx context.Context, apiKeyID string, access ModelAccess) (APIKey, error) {
+	if strings.TrimSpace(apiKeyID) == "" {
+		return APIKey{}, fmt.Errorf("%w: api_key_id required", ErrInvalidInput)
+	}
+	if err := access.Validate(); err != nil {
+		return APIKey{}, err
+	}
+	if err := s.updateModelAccess(ctx, &APIKey{}, apiKeyID, normalizeModelAccess(access)); err != nil {
+		return APIKey{}, err
+	}
+	return s.GetUserAPIKey(ctx, apiKeyID)
+}
+
+func (s *service) updateModelAccess(ctx context.Context, model any, id string, access ModelAccess) error {
+	result := s.db.WithContext(ctx).Model(model).Where("id = ?", id).Updates(map[string]any{
+		"allowed_models": access.AllowedModels,
+		"denied_models":  access.DeniedModels,
+		"updated_at":     time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
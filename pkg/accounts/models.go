@@ -7,6 +7,8 @@ import (
 
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
+
+	"github.com/prehisle/yapi/pkg/secrets"
 )
 
 const (
@@ -21,6 +23,8 @@ type User struct {
 	Name        string            `gorm:"type:varchar(128);uniqueIndex"`
 	Description string            `gorm:"type:varchar(512)"`
 	Metadata    datatypes.JSONMap `gorm:"type:jsonb"`
+	RateLimits  `gorm:"embedded"`
+	ModelAccess `gorm:"embedded"`
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	DeletedAt   gorm.DeletedAt `gorm:"index"`
@@ -37,7 +41,10 @@ func (u User) Validate() error {
 	if len(u.Description) > maxDescriptionLength {
 		return fmt.Errorf("%w: user description too long", ErrInvalidInput)
 	}
-	return nil
+	if err := u.RateLimits.Validate(); err != nil {
+		return err
+	}
+	return u.ModelAccess.Validate()
 }
 
 // APIKey represents a generated access token bound to a user.
@@ -50,9 +57,13 @@ type APIKey struct {
 	Enabled    bool   `gorm:"type:boolean;default:true"`
 	LastUsedAt *time.Time
 	Metadata   datatypes.JSONMap `gorm:"type:jsonb"`
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
-	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	// RateLimits overrides the owning user's limits when non-zero.
+	RateLimits `gorm:"embedded"`
+	// ModelAccess further restricts the owning user's models.
+	ModelAccess `gorm:"embedded"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
 }
 
 // Validate ensures APIKey has the required attributes.
@@ -69,7 +80,10 @@ func (k APIKey) Validate() error {
 	if strings.TrimSpace(k.SecretHash) == "" {
 		return fmt.Errorf("%w: api key secret hash empty", ErrInvalidInput)
 	}
-	return nil
+	if err := k.RateLimits.Validate(); err != nil {
+		return err
+	}
+	return k.ModelAccess.Validate()
 }
 
 // UpstreamKey stores upstream secrets, endpoints, and service metadata.
@@ -79,12 +93,38 @@ type UpstreamKey struct {
 	Service   string            `gorm:"type:varchar(64);index;column:provider"`
 	Name      string            `gorm:"type:varchar(128);column:label"`
 	APIKey    string            `gorm:"type:varchar(255)"`
+	SecretRef string            `gorm:"type:varchar(512)"`
 	Endpoints datatypes.JSON    `gorm:"type:jsonb"`
 	Metadata  datatypes.JSONMap `gorm:"type:jsonb"`
 	Enabled   bool              `gorm:"type:boolean;default:true"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+	PoolID    string            `gorm:"type:char(36);index"`
+	// IsDefault marks the user's default key for its provider; API keys
+	// without an explicit binding resolve to it.
+	IsDefault bool `gorm:"type:boolean;default:false;index"`
+	// Health fields are maintained by the upstream credential checker.
+	HealthStatus    string `gorm:"type:varchar(32)"`
+	HealthDetail    string `gorm:"type:varchar(255)"`
+	HealthCheckedAt *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	DeletedAt       gorm.DeletedAt `gorm:"index"`
+}
+
+// Upstream credential health statuses.
+const (
+	UpstreamHealthUnknown     = "unknown"
+	UpstreamHealthValid       = "valid"
+	UpstreamHealthInvalid     = "invalid"
+	UpstreamHealthRateLimited = "rate_limited"
+	UpstreamHealthUnreachable = "unreachable"
+)
+
+// UpstreamHealth is the outcome of a live credential check.
+type UpstreamHealth struct {
+	Status     string
+	Detail     string
+	HTTPStatus int
+	CheckedAt  time.Time
 }
 
 // TableName retains the legacy table name.
@@ -106,20 +146,31 @@ func (k UpstreamKey) Validate() error {
 	if len(k.Name) > maxNameLength {
 		return fmt.Errorf("%w: upstream credential name too long", ErrInvalidInput)
 	}
-	if strings.TrimSpace(k.APIKey) == "" {
+	ref := strings.TrimSpace(k.SecretRef)
+	if strings.TrimSpace(k.APIKey) == "" && ref == "" {
 		return fmt.Errorf("%w: upstream credential api key empty", ErrInvalidInput)
 	}
+	if ref != "" && !secrets.IsReference(ref) {
+		return fmt.Errorf("%w: upstream credential secret_ref malformed", ErrInvalidInput)
+	}
 	return nil
 }
 
+// UsesSecretRef reports whether the credential secret lives in an external backend.
+func (k UpstreamKey) UsesSecretRef() bool {
+	return strings.TrimSpace(k.APIKey) == "" && strings.TrimSpace(k.SecretRef) != ""
+}
+
 // UserKeyBinding maps a user API key to upstream keys per service.
+// A key may hold several bindings for the same service; Position orders them,
+// the lowest being the primary and the rest used as fallbacks.
 type UserKeyBinding struct {
 	ID            string            `gorm:"type:char(36);primaryKey"`
 	UserID        string            `gorm:"type:char(36);index"`
-	UserAPIKeyID  string            `gorm:"type:char(36);index;uniqueIndex:user_key_service"`
+	UserAPIKeyID  string            `gorm:"type:char(36);index;uniqueIndex:user_key_service_position"`
 	UpstreamKeyID string            `gorm:"type:char(36);index;column:upstream_credential_id"`
-	Service       string            `gorm:"type:varchar(64);index;uniqueIndex:user_key_service"`
-	Position      int               `gorm:"type:int;default:0"`
+	Service       string            `gorm:"type:varchar(64);index;uniqueIndex:user_key_service_position"`
+	Position      int               `gorm:"type:int;default:0;uniqueIndex:user_key_service_position"`
 	Metadata      datatypes.JSONMap `gorm:"type:jsonb"`
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
@@ -144,6 +195,9 @@ func (b UserKeyBinding) Validate() error {
 	if strings.TrimSpace(b.Service) == "" {
 		return fmt.Errorf("%w: binding service empty", ErrInvalidInput)
 	}
+	if b.Position < 0 {
+		return fmt.Errorf("%w: binding position must not be negative", ErrInvalidInput)
+	}
 	return nil
 }
 
@@ -0,0 +1,297 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Key pool selection strategies.
+const (
+	PoolStrategyRoundRobin         = "round_robin"
+	PoolStrategyLeastRecentlyUsed  = "least_recently_used"
+	defaultUpstreamKeyPoolStrategy = PoolStrategyRoundRobin
+)
+
+// UpstreamKeyPool groups upstream keys of one provider so requests can rotate
+// across them. Membership is stored on UpstreamKey.PoolID.
+type UpstreamKeyPool struct {
+	ID        string            `gorm:"type:char(36);primaryKey"`
+	UserID    string            `gorm:"type:char(36);index;uniqueIndex:user_pool_name"`
+	Name      string            `gorm:"type:varchar(128);uniqueIndex:user_pool_name"`
+	Service   string            `gorm:"type:varchar(64);index;column:provider"`
+	Strategy  string            `gorm:"type:varchar(32)"`
+	Metadata  datatypes.JSONMap `gorm:"type:jsonb"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	// CredentialIDs lists member keys; populated on reads only.
+	CredentialIDs []string `gorm:"-"`
+}
+
+// TableName pins the pool table name.
+func (UpstreamKeyPool) TableName() string {
+	return "upstream_key_pools"
+}
+
+// Validate ensures the pool is well formed.
+func (p UpstreamKeyPool) Validate() error {
+	if strings.TrimSpace(p.UserID) == "" {
+		return fmt.Errorf("%w: pool user_id empty", ErrInvalidInput)
+	}
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("%w: pool name empty", ErrInvalidInput)
+	}
+	if len(p.Name) > maxNameLength {
+		return fmt.Errorf("%w: pool name too long", ErrInvalidInput)
+	}
+	if strings.TrimSpace(p.Service) == "" {
+		return fmt.Errorf("%w: pool service empty", ErrInvalidInput)
+	}
+	switch p.Strategy {
+	case PoolStrategyRoundRobin, PoolStrategyLeastRecentlyUsed:
+	default:
+		return fmt.Errorf("%w: unsupported pool strategy %q", ErrInvalidInput, p.Strategy)
+	}
+	return nil
+}
+
+// CreateUpstreamKeyPoolParams describes a key pool creation.
+type CreateUpstreamKeyPoolParams struct {
+	UserID        string
+	Name          string
+	Provider      string
+	Strategy      string
+	CredentialIDs []string
+	Metadata      map[string]any
+}
+
+// UpdateUpstreamKeyPoolParams captures mutable pool fields; nil leaves a field untouched.
+type UpdateUpstreamKeyPoolParams struct {
+	PoolID        string
+	Name          *string
+	Strategy      *string
+	CredentialIDs *[]string
+	Metadata      map[string]any
+}
+
+func (s *service) CreateUpstreamKeyPool(ctx context.Context, params CreateUpstreamKeyPoolParams) (UpstreamKeyPool, error) {
+	pool := UpstreamKeyPool{
+		ID:       uuid.NewString(),
+		UserID:   strings.TrimSpace(params.UserID),
+		Name:     strings.TrimSpace(params.Name),
+		Service:  strings.TrimSpace(params.Provider),
+		Strategy: normalizePoolStrategy(params.Strategy),
+	}
+	if params.Metadata != nil {
+		pool.Metadata = datatypes.JSONMap(params.Metadata)
+	}
+	if err := pool.Validate(); err != nil {
+		return UpstreamKeyPool{}, err
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&UpstreamKeyPool{}).Where("user_id = ? AND name = ?", pool.UserID, pool.Name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: pool name already exists", ErrConflict)
+		}
+		if err := tx.Create(&pool).Error; err != nil {
+			return err
+		}
+		return assignPoolMembers(tx, pool, params.CredentialIDs)
+	})
+	if err != nil {
+		return UpstreamKeyPool{}, err
+	}
+	pool.CredentialIDs = uniqueTrimmed(params.CredentialIDs)
+	return pool, nil
+}
+
+func (s *service) ListUpstreamKeyPools(ctx context.Context, userID string) ([]UpstreamKeyPool, error) {
+	var pools []UpstreamKeyPool
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&pools).Error; err != nil {
+		return nil, err
+	}
+	if len(pools) == 0 {
+		return pools, nil
+	}
+	ids := make([]string, 0, len(pools))
+	for _, pool := range pools {
+		ids = append(ids, pool.ID)
+	}
+	var members []UpstreamKey
+	if err := s.db.WithContext(ctx).Select("id", "pool_id").Where("pool_id IN ?", ids).Order("created_at ASC").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	byPool := make(map[string][]string, len(pools))
+	for _, member := range members {
+		byPool[member.PoolID] = append(byPool[member.PoolID], member.ID)
+	}
+	for i := range pools {
+		pools[i].CredentialIDs = byPool[pools[i].ID]
+	}
+	return pools, nil
+}
+
+func (s *service) GetUpstreamKeyPool(ctx context.Context, poolID string) (UpstreamKeyPool, error) {
+	if strings.TrimSpace(poolID) == "" {
+		return UpstreamKeyPool{}, fmt.Errorf("%w: pool_id required", ErrInvalidInput)
+	}
+	var pool UpstreamKeyPool
+	err := s.db.WithContext(ctx).First(&pool, "id = ?", poolID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return UpstreamKeyPool{}, ErrNotFound
+	}
+	if err != nil {
+		return UpstreamKeyPool{}, err
+	}
+	var ids []string
+	if err := s.db.WithContext(ctx).Model(&UpstreamKey{}).Where("pool_id = ?", poolID).Order("created_at ASC").Pluck("id", &ids).Error; err != nil {
+		return UpstreamKeyPool{}, err
+	}
+	pool.CredentialIDs = ids
+	return pool, nil
+}
+
+func (s *service) UpdateUpstreamKeyPool(ctx context.Context, params UpdateUpstreamKeyPoolParams) (UpstreamKeyPool, error) {
+	pool, err := s.GetUpstreamKeyPool(ctx, params.PoolID)
+	if err != nil {
+		return UpstreamKeyPool{}, err
+	}
+	if params.Name != nil {
+		pool.Name = strings.TrimSpace(*params.Name)
+	}
+	if params.Strategy != nil {
+		pool.Strategy = normalizePoolStrategy(*params.Strategy)
+	}
+	if params.Metadata != nil {
+		pool.Metadata = datatypes.JSONMap(params.Metadata)
+	}
+	if err := pool.Validate(); err != nil {
+		return UpstreamKeyPool{}, err
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&UpstreamKeyPool{}).
+			Where("user_id = ? AND name = ? AND id <> ?", pool.UserID, pool.Name, pool.ID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: pool name already exists", ErrConflict)
+		}
+		if err := tx.Model(&UpstreamKeyPool{}).Where("id = ?", pool.ID).Updates(map[string]any{
+			"name":       pool.Name,
+			"strategy":   pool.Strategy,
+			"metadata":   pool.Metadata,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		if params.CredentialIDs == nil {
+			return nil
+		}
+		if err := tx.Model(&UpstreamKey{}).Where("pool_id = ?", pool.ID).Update("pool_id", "").Error; err != nil {
+			return err
+		}
+		return assignPoolMembers(tx, pool, *params.CredentialIDs)
+	})
+	if err != nil {
+		return UpstreamKeyPool{}, err
+	}
+	return s.GetUpstreamKeyPool(ctx, pool.ID)
+}
+
+func (s *service) DeleteUpstreamKeyPool(ctx context.Context, poolID string) error {
+	if strings.TrimSpace(poolID) == "" {
+		return fmt.Errorf("%w: pool_id required", ErrInvalidInput)
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", poolID).Delete(&UpstreamKeyPool{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Model(&UpstreamKey{}).Where("pool_id = ?", poolID).Update("pool_id", "").Error
+	})
+}
+
+func (s *service) ListUpstreamKeyPoolMembers(ctx context.Context, poolID string) ([]UpstreamCredential, error) {
+	if strings.TrimSpace(poolID) == "" {
+		return nil, fmt.Errorf("%w: pool_id required", ErrInvalidInput)
+	}
+	var members []UpstreamCredential
+	if err := s.db.WithContext(ctx).
+		Where("pool_id = ? AND enabled = ?", poolID, true).
+		Order("created_at ASC").
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// assignPoolMembers attaches credentials to a pool after checking they share
+// the pool's owner and provider.
+func assignPoolMembers(tx *gorm.DB, pool UpstreamKeyPool, credentialIDs []string) error {
+	ids := uniqueTrimmed(credentialIDs)
+	if len(ids) == 0 {
+		return nil
+	}
+	var keys []UpstreamKey
+	if err := tx.Where("id IN ?", ids).Find(&keys).Error; err != nil {
+		return err
+	}
+	if len(keys) != len(ids) {
+		return fmt.Errorf("%w: upstream key not found", ErrNotFound)
+	}
+	for _, key := range keys {
+		if key.UserID != pool.UserID {
+			return fmt.Errorf("%w: upstream key %s belongs to another user", ErrConflict, key.ID)
+		}
+		if !strings.EqualFold(key.Service, pool.Service) {
+			return fmt.Errorf("%w: upstream key %s provider %q does not match pool", ErrInvalidInput, key.ID, key.Service)
+		}
+		if key.PoolID != "" && key.PoolID != pool.ID {
+			return fmt.Errorf("%w: upstream key %s already belongs to another pool", ErrConflict, key.ID)
+		}
+	}
+	return tx.Model(&UpstreamKey{}).Where("id IN ?", ids).Update("pool_id", pool.ID).Error
+}
+
+func normalizePoolStrategy(strategy string) string {
+	strategy = strings.ToLower(strings.TrimSpace(strategy))
+	switch strategy {
+	case "":
+		return defaultUpstreamKeyPoolStrategy
+	case "lru":
+		return PoolStrategyLeastRecentlyUsed
+	}
+	return strategy
+}
+
+func uniqueTrimmed(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		out = append(out, value)
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SelectUpstreamByMetadata returns the user's oldest enabled upstream key whose
+// metadata contains every filter pair. Service narrows the search to one
+// provider when non-empty. Metadata values are compared as strings.
+func (s *service) SelectUpstreamByMetadata(ctx context.Context, userID, service string, filters map[string]string) (UpstreamCredential, error) {
+	if strings.TrimSpace(userID) == "" {
+		return UpstreamCredential{}, fmt.Errorf("%w: user_id required", ErrInvalidInput)
+	}
+	query := s.db.WithContext(ctx).Where("user_id = ? AND enabled = ?", userID, true)
+	if service = strings.TrimSpace(service); service != "" {
+		query = query.Where("provider = ?", service)
+	}
+	var candidates []UpstreamKey
+	if err := query.Order("created_at ASC").Find(&candidates).Error; err != nil {
+		return UpstreamCredential{}, err
+	}
+	for _, candidate := range candidates {
+		if metadataMatches(candidate.Metadata, filters) {
+			return UpstreamCredential(candidate), nil
+		}
+	}
+	return UpstreamCredential{}, ErrNotFound
+}
+
+func metadataMatches(metadata map[string]any, filters map[string]string) bool {
+	for key, want := range filters {
+		value, ok := metadata[key]
+		if !ok || value == nil {
+			return false
+		}
+		if strings.TrimSpace(fmt.Sprint(value)) != strings.TrimSpace(want) {
+			return false
+		}
+	}
+	return true
+}
@@ -9,17 +9,18 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
+
+	"github.com/prehisle/yapi/pkg/dbreplica"
 )
 
 const (
 	apiKeyPrefix               = "yapi"
 	apiKeySecretBytes          = 24
-	userAPIKeySecretHashCost   = bcrypt.DefaultCost
 	defaultAPIKeyPrefixSegment = 4
 )
 
@@ -28,28 +29,52 @@ type Service interface {
 	AutoMigrate(ctx context.Context) error
 
 	CreateUser(ctx context.Context, params CreateUserParams) (User, error)
-	ListUsers(ctx context.Context) ([]User, error)
+	ListUsers(ctx context.Context, opts ListOptions) ([]User, int64, error)
 	GetUser(ctx context.Context, id string) (User, error)
 	DeleteUser(ctx context.Context, id string) error
+	UpdateUser(ctx context.Context, params UpdateUserParams) (User, error)
+	SetUserRateLimits(ctx context.Context, userID string, limits RateLimits) (User, error)
+	SetUserModelAccess(ctx context.Context, userID string, access ModelAccess) (User, error)
 
 	CreateUserAPIKey(ctx context.Context, params CreateAPIKeyParams) (APIKey, string, error)
-	ListUserAPIKeys(ctx context.Context, userID string) ([]APIKey, error)
+	ListUserAPIKeys(ctx context.Context, userID string, opts ListOptions) ([]APIKey, int64, error)
+	GetUserAPIKey(ctx context.Context, apiKeyID string) (APIKey, error)
 	SetUserAPIKeyEnabled(ctx context.Context, apiKeyID string, enabled bool) error
+	SetAPIKeyRateLimits(ctx context.Context, apiKeyID string, limits RateLimits) (APIKey, error)
+	SetAPIKeyModelAccess(ctx context.Context, apiKeyID string, access ModelAccess) (APIKey, error)
 	RevokeUserAPIKey(ctx context.Context, apiKeyID string) error
 
 	CreateUpstreamCredential(ctx context.Context, params CreateUpstreamCredentialParams) (UpstreamCredential, error)
-	ListUpstreamCredentials(ctx context.Context, userID string) ([]UpstreamCredential, error)
+	ListUpstreamCredentials(ctx context.Context, userID string, opts ListOptions) ([]UpstreamCredential, int64, error)
+	GetUpstreamCredential(ctx context.Context, credentialID string) (UpstreamCredential, error)
+	SelectUpstreamByMetadata(ctx context.Context, userID, service string, filters map[string]string) (UpstreamCredential, error)
+	ListEnabledUpstreamCredentials(ctx context.Context) ([]UpstreamCredential, error)
+	RecordUpstreamHealth(ctx context.Context, credentialID string, health UpstreamHealth) error
 	UpdateUpstreamCredential(ctx context.Context, params UpdateUpstreamCredentialParams) (UpstreamCredential, error)
 	SetUpstreamCredentialEnabled(ctx context.Context, credentialID string, enabled bool) error
+	SetUpstreamCredentialDefault(ctx context.Context, credentialID string, isDefault bool) (UpstreamCredential, error)
 	DeleteUpstreamCredential(ctx context.Context, credentialID string) error
 
+	CreateUpstreamKeyPool(ctx context.Context, params CreateUpstreamKeyPoolParams) (UpstreamKeyPool, error)
+	ListUpstreamKeyPools(ctx context.Context, userID string) ([]UpstreamKeyPool, error)
+	GetUpstreamKeyPool(ctx context.Context, poolID string) (UpstreamKeyPool, error)
+	UpdateUpstreamKeyPool(ctx context.Context, params UpdateUpstreamKeyPoolParams) (UpstreamKeyPool, error)
+	DeleteUpstreamKeyPool(ctx context.Context, poolID string) error
+	ListUpstreamKeyPoolMembers(ctx context.Context, poolID string) ([]UpstreamCredential, error)
+
 	BindAPIKey(ctx context.Context, params BindAPIKeyParams) (UserAPIKeyBinding, error)
 	ListBindingsByAPIKey(ctx context.Context, apiKeyID string) ([]BindingWithUpstream, error)
+	ListBindingsByUser(ctx context.Context, userID string) ([]BindingWithUpstream, error)
 	GetBindingByAPIKeyID(ctx context.Context, apiKeyID string) (UserAPIKeyBinding, UpstreamCredential, error)
 	DeleteBinding(ctx context.Context, bindingID string) error
 
 	ResolveAPIKey(ctx context.Context, rawKey string) (APIKey, error)
 	ResolveBindingByRawKey(ctx context.Context, rawKey string) (UserAPIKeyBinding, UpstreamCredential, error)
+	ResolveBindingByAPIKeyID(ctx context.Context, apiKeyID string) (UserAPIKeyBinding, UpstreamCredential, error)
+	ResolveDefaultBinding(ctx context.Context, apiKeyID, service string) (UserAPIKeyBinding, UpstreamCredential, error)
+
+	ExportSnapshot(ctx context.Context) (Snapshot, error)
+	ImportSnapshot(ctx context.Context, snapshot Snapshot) error
 }
 
 // CreateUserParams defines the payload for user creation.
@@ -57,12 +82,28 @@ type CreateUserParams struct {
 	Name        string
 	Description string
 	Metadata    map[string]any
+	RateLimits  RateLimits
+	ModelAccess ModelAccess
+}
+
+// UpdateUserParams replaces the mutable fields of a user; the name is immutable.
+type UpdateUserParams struct {
+	UserID      string
+	Description string
+	Metadata    map[string]any
+	RateLimits  RateLimits
+	ModelAccess ModelAccess
 }
 
 // CreateAPIKeyParams defines the payload for API key generation.
 type CreateAPIKeyParams struct {
-	UserID string
-	Label  string
+	UserID      string
+	Label       string
+	RateLimits  RateLimits
+	ModelAccess ModelAccess
+	// Plaintext imports an existing key in the generated yapi_<prefix>_<secret>
+	// format instead of generating one, e.g. from a bootstrap manifest.
+	Plaintext string
 }
 
 // CreateUpstreamCredentialParams describes an upstream credential creation.
@@ -73,6 +114,7 @@ type CreateUpstreamCredentialParams struct {
 	Service   string
 	Name      string
 	Plaintext string
+	SecretRef string
 	Endpoints []string
 	Metadata  map[string]any
 }
@@ -86,12 +128,14 @@ type UpdateUpstreamCredentialParams struct {
 	Service      string
 	Name         string
 	Plaintext    *string
+	SecretRef    *string
 	Endpoints    []string
 	Metadata     map[string]any
 	Enabled      *bool
 }
 
 // BindAPIKeyParams maps a user API key to an upstream key for a service.
+// Binding the same (service, position) pair again replaces the upstream key.
 type BindAPIKeyParams struct {
 	UserID               string
 	UserAPIKeyID         string
@@ -108,21 +152,50 @@ type BindingWithUpstream struct {
 }
 
 type service struct {
-	db *gorm.DB
+	db     *gorm.DB
+	hasher SecretHasher
+}
+
+// ServiceOption customizes the account service.
+type ServiceOption func(*service)
+
+// WithSecretHasher overrides the API key secret hasher (bcrypt by default).
+func WithSecretHasher(hasher SecretHasher) ServiceOption {
+	return func(s *service) {
+		if hasher != nil {
+			s.hasher = hasher
+		}
+	}
 }
 
 // NewService constructs a Service backed by the provided gorm DB.
-func NewService(db *gorm.DB) Service {
-	return &service{db: db}
+func NewService(db *gorm.DB, opts ...ServiceOption) Service {
+	s := &service{db: db, hasher: DefaultSecretHasher()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *service) AutoMigrate(ctx context.Context) error {
-	return s.db.WithContext(ctx).AutoMigrate(
+	if err := s.db.WithContext(ctx).AutoMigrate(
 		&User{},
 		&APIKey{},
 		&UpstreamKey{},
+		&UpstreamKeyPool{},
 		&UserKeyBinding{},
-	)
+	); err != nil {
+		return err
+	}
+	// The legacy (api key, service) unique index allowed a single binding per
+	// service; it is superseded by user_key_service_position.
+	migrator := s.db.WithContext(ctx).Migrator()
+	if migrator.HasIndex(&UserKeyBinding{}, "user_key_service") {
+		if err := migrator.DropIndex(&UserKeyBinding{}, "user_key_service"); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *service) CreateUser(ctx context.Context, params CreateUserParams) (User, error) {
@@ -130,6 +203,8 @@ func (s *service) CreateUser(ctx context.Context, params CreateUserParams) (User
 		ID:          uuid.NewString(),
 		Name:        strings.TrimSpace(params.Name),
 		Description: strings.TrimSpace(params.Description),
+		RateLimits:  params.RateLimits,
+		ModelAccess: normalizeModelAccess(params.ModelAccess),
 	}
 	if params.Metadata != nil {
 		user.Metadata = datatypes.JSONMap(params.Metadata)
@@ -146,12 +221,13 @@ func (s *service) CreateUser(ctx context.Context, params CreateUserParams) (User
 	return user, nil
 }
 
-func (s *service) ListUsers(ctx context.Context) ([]User, error) {
+func (s *service) ListUsers(ctx context.Context, opts ListOptions) ([]User, int64, error) {
 	var users []User
-	if err := s.db.WithContext(ctx).Order("created_at ASC").Find(&users).Error; err != nil {
-		return nil, err
+	total, err := paginate(dbreplica.Read(s.db.WithContext(ctx)).Model(&User{}), opts, &users, "name", "description")
+	if err != nil {
+		return nil, 0, err
 	}
-	return users, nil
+	return users, total, nil
 }
 
 func (s *service) GetUser(ctx context.Context, id string) (User, error) {
@@ -170,6 +246,38 @@ func (s *service) DeleteUser(ctx context.Context, id string) error {
 	return nil
 }
 
+func (s *service) UpdateUser(ctx context.Context, params UpdateUserParams) (User, error) {
+	if strings.TrimSpace(params.UserID) == "" {
+		return User{}, fmt.Errorf("%w: user_id required", ErrInvalidInput)
+	}
+	user, err := s.GetUser(ctx, params.UserID)
+	if err != nil {
+		return User{}, err
+	}
+	user.Description = strings.TrimSpace(params.Description)
+	user.Metadata = nil
+	if params.Metadata != nil {
+		user.Metadata = datatypes.JSONMap(params.Metadata)
+	}
+	user.RateLimits = params.RateLimits
+	user.ModelAccess = normalizeModelAccess(params.ModelAccess)
+	if err := user.Validate(); err != nil {
+		return User{}, err
+	}
+	if err := s.db.WithContext(ctx).Model(&User{}).Where("id = ?", user.ID).Updates(map[string]any{
+		"description":             user.Description,
+		"metadata":                user.Metadata,
+		"max_requests_per_minute": user.MaxRequestsPerMinute,
+		"max_concurrent_requests": user.MaxConcurrentRequests,
+		"allowed_models":          user.AllowedModels,
+		"denied_models":           user.DeniedModels,
+		"updated_at":              time.Now(),
+	}).Error; err != nil {
+		return User{}, err
+	}
+	return s.GetUser(ctx, user.ID)
+}
+
 func (s *service) CreateUserAPIKey(ctx context.Context, params CreateAPIKeyParams) (APIKey, string, error) {
 	if strings.TrimSpace(params.UserID) == "" {
 		return APIKey{}, "", fmt.Errorf("%w: user_id required", ErrInvalidInput)
@@ -178,21 +286,27 @@ func (s *service) CreateUserAPIKey(ctx context.Context, params CreateAPIKeyParam
 		return APIKey{}, "", err
 	}
 	plain, prefix, err := generateAPIKey()
+	if params.Plaintext != "" {
+		plain = params.Plaintext
+		prefix, err = importedAPIKeyPrefix(plain)
+	}
 	if err != nil {
 		return APIKey{}, "", err
 	}
-	hash, err := hashSecret(plain, userAPIKeySecretHashCost)
+	hash, err := s.hasher.Hash(plain)
 	if err != nil {
 		return APIKey{}, "", err
 	}
 
 	key := APIKey{
-		ID:         uuid.NewString(),
-		UserID:     params.UserID,
-		Label:      strings.TrimSpace(params.Label),
-		Prefix:     prefix,
-		SecretHash: hash,
-		Enabled:    true,
+		ID:          uuid.NewString(),
+		UserID:      params.UserID,
+		Label:       strings.TrimSpace(params.Label),
+		Prefix:      prefix,
+		SecretHash:  hash,
+		Enabled:     true,
+		RateLimits:  params.RateLimits,
+		ModelAccess: normalizeModelAccess(params.ModelAccess),
 	}
 	if err := key.Validate(); err != nil {
 		return APIKey{}, "", err
@@ -203,12 +317,26 @@ func (s *service) CreateUserAPIKey(ctx context.Context, params CreateAPIKeyParam
 	return key, plain, nil
 }
 
-func (s *service) ListUserAPIKeys(ctx context.Context, userID string) ([]APIKey, error) {
+func (s *service) ListUserAPIKeys(ctx context.Context, userID string, opts ListOptions) ([]APIKey, int64, error) {
 	var keys []APIKey
-	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&keys).Error; err != nil {
-		return nil, err
+	query := s.db.WithContext(ctx).Model(&APIKey{}).Where("user_id = ?", userID)
+	total, err := paginate(query, opts, &keys, "label", "prefix")
+	if err != nil {
+		return nil, 0, err
 	}
-	return keys, nil
+	return keys, total, nil
+}
+
+func (s *service) GetUserAPIKey(ctx context.Context, apiKeyID string) (APIKey, error) {
+	if strings.TrimSpace(apiKeyID) == "" {
+		return APIKey{}, fmt.Errorf("%w: api_key_id required", ErrInvalidInput)
+	}
+	var key APIKey
+	err := s.db.WithContext(ctx).First(&key, "id = ?", apiKeyID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return APIKey{}, ErrNotFound
+	}
+	return key, err
 }
 
 func (s *service) SetUserAPIKeyEnabled(ctx context.Context, apiKeyID string, enabled bool) error {
@@ -250,13 +378,17 @@ func (s *service) CreateUpstreamCredential(ctx context.Context, params CreateUps
 	serviceName := firstNonEmpty(params.Service, params.Provider)
 	label := firstNonEmpty(params.Name, params.Label)
 	secret := strings.TrimSpace(params.Plaintext)
+	if secret != "" && strings.TrimSpace(params.SecretRef) != "" {
+		return UpstreamCredential{}, fmt.Errorf("%w: plaintext and secret_ref are mutually exclusive", ErrInvalidInput)
+	}
 	key := UpstreamCredential{
-		ID:      uuid.NewString(),
-		UserID:  params.UserID,
-		Service: serviceName,
-		Name:    label,
-		APIKey:  secret,
-		Enabled: true,
+		ID:        uuid.NewString(),
+		UserID:    params.UserID,
+		Service:   serviceName,
+		Name:      label,
+		APIKey:    secret,
+		SecretRef: strings.TrimSpace(params.SecretRef),
+		Enabled:   true,
 	}
 	if len(params.Endpoints) > 0 {
 		endpointsJSON, encodeErr := json.Marshal(params.Endpoints)
@@ -277,14 +409,60 @@ func (s *service) CreateUpstreamCredential(ctx context.Context, params CreateUps
 	return key, nil
 }
 
-func (s *service) ListUpstreamCredentials(ctx context.Context, userID string) ([]UpstreamCredential, error) {
+func (s *service) ListUpstreamCredentials(ctx context.Context, userID string, opts ListOptions) ([]UpstreamCredential, int64, error) {
 	var keys []UpstreamCredential
-	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&keys).Error; err != nil {
+	query := s.db.WithContext(ctx).Model(&UpstreamKey{}).Where("user_id = ?", userID)
+	total, err := paginate(query, opts, &keys, "label", "provider")
+	if err != nil {
+		return nil, 0, err
+	}
+	return keys, total, nil
+}
+
+func (s *service) GetUpstreamCredential(ctx context.Context, credentialID string) (UpstreamCredential, error) {
+	if strings.TrimSpace(credentialID) == "" {
+		return UpstreamCredential{}, fmt.Errorf("%w: credential_id required", ErrInvalidInput)
+	}
+	var key UpstreamKey
+	err := s.db.WithContext(ctx).First(&key, "id = ?", credentialID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return UpstreamCredential{}, ErrNotFound
+	}
+	return key, err
+}
+
+func (s *service) ListEnabledUpstreamCredentials(ctx context.Context) ([]UpstreamCredential, error) {
+	var keys []UpstreamCredential
+	if err := s.db.WithContext(ctx).Where("enabled = ?", true).Order("created_at ASC").Find(&keys).Error; err != nil {
 		return nil, err
 	}
 	return keys, nil
 }
 
+func (s *service) RecordUpstreamHealth(ctx context.Context, credentialID string, health UpstreamHealth) error {
+	if strings.TrimSpace(credentialID) == "" {
+		return fmt.Errorf("%w: credential_id required", ErrInvalidInput)
+	}
+	checkedAt := health.CheckedAt
+	if checkedAt.IsZero() {
+		checkedAt = time.Now()
+	}
+	detail := truncateUTF8(health.Detail, 255)
+	// UpdateColumns keeps updated_at untouched: health is not an admin edit.
+	result := s.db.WithContext(ctx).Model(&UpstreamKey{}).Where("id = ?", credentialID).UpdateColumns(map[string]any{
+		"health_status":     health.Status,
+		"health_detail":     detail,
+		"health_checked_at": checkedAt,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 func (s *service) SetUpstreamCredentialEnabled(ctx context.Context, credentialID string, enabled bool) error {
 	if strings.TrimSpace(credentialID) == "" {
 		return fmt.Errorf("%w: upstream_key_id required", ErrInvalidInput)
@@ -302,6 +480,41 @@ func (s *service) SetUpstreamCredentialEnabled(ctx context.Context, credentialID
 	return nil
 }
 
+// SetUpstreamCredentialDefault marks (or unmarks) a credential as the owner's
+// default for its provider. Marking clears any previous default of that provider.
+func (s *service) SetUpstreamCredentialDefault(ctx context.Context, credentialID string, isDefault bool) (UpstreamCredential, error) {
+	if strings.TrimSpace(credentialID) == "" {
+		return UpstreamCredential{}, fmt.Errorf("%w: credential_id required", ErrInvalidInput)
+	}
+	var key UpstreamKey
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&key, "id = ?", credentialID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		now := time.Now()
+		if isDefault {
+			if err := tx.Model(&UpstreamKey{}).
+				Where("user_id = ? AND provider = ? AND id <> ? AND is_default = ?", key.UserID, key.Service, key.ID, true).
+				Updates(map[string]any{"is_default": false, "updated_at": now}).Error; err != nil {
+				return err
+			}
+		}
+		key.IsDefault = isDefault
+		key.UpdatedAt = now
+		return tx.Model(&UpstreamKey{}).Where("id = ?", key.ID).Updates(map[string]any{
+			"is_default": isDefault,
+			"updated_at": now,
+		}).Error
+	})
+	if err != nil {
+		return UpstreamCredential{}, err
+	}
+	return UpstreamCredential(key), nil
+}
+
 func (s *service) DeleteUpstreamCredential(ctx context.Context, credentialID string) error {
 	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if err := tx.WithContext(ctx).Where("upstream_credential_id = ?", credentialID).Delete(&UserKeyBinding{}).Error; err != nil {
@@ -346,7 +559,8 @@ func (s *service) BindAPIKey(ctx context.Context, params BindAPIKeyParams) (User
 		}
 
 		var binding UserKeyBinding
-		err := tx.WithContext(ctx).Where("user_api_key_id = ? AND service = ?", params.UserAPIKeyID, targetService).
+		err := tx.WithContext(ctx).
+			Where("user_api_key_id = ? AND service = ? AND position = ?", params.UserAPIKeyID, targetService, params.Position).
 			First(&binding).Error
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			binding = UserKeyBinding{
@@ -374,7 +588,6 @@ func (s *service) BindAPIKey(ctx context.Context, params BindAPIKeyParams) (User
 		}
 
 		binding.UpstreamKeyID = params.UpstreamCredentialID
-		binding.Position = params.Position
 		if params.Metadata != nil {
 			binding.Metadata = datatypes.JSONMap(params.Metadata)
 		}
@@ -383,7 +596,6 @@ func (s *service) BindAPIKey(ctx context.Context, params BindAPIKeyParams) (User
 		}
 		if err := tx.WithContext(ctx).Model(&UserKeyBinding{}).Where("id = ?", binding.ID).Updates(map[string]any{
 			"upstream_credential_id": binding.UpstreamKeyID,
-			"position":               binding.Position,
 			"metadata":               binding.Metadata,
 			"updated_at":             time.Now(),
 		}).Error; err != nil {
@@ -418,12 +630,24 @@ func (s *service) UpdateUpstreamCredential(ctx context.Context, params UpdateUps
 	if label := firstNonEmpty(params.Name, params.Label); label != "" {
 		key.Name = label
 	}
-	if params.Plaintext != nil {
+	if params.Plaintext != nil && params.SecretRef != nil &&
+		strings.TrimSpace(*params.Plaintext) != "" && strings.TrimSpace(*params.SecretRef) != "" {
+		return UpstreamCredential{}, fmt.Errorf("%w: plaintext and secret_ref are mutually exclusive", ErrInvalidInput)
+	}
+	if params.SecretRef != nil {
+		// Switching to an external reference drops the stored plaintext.
+		key.SecretRef = strings.TrimSpace(*params.SecretRef)
+		if key.SecretRef != "" {
+			key.APIKey = ""
+		}
+	}
+	if params.Plaintext != nil && (params.SecretRef == nil || key.SecretRef == "") {
 		secret := strings.TrimSpace(*params.Plaintext)
 		if secret == "" {
 			return UpstreamCredential{}, fmt.Errorf("%w: upstream credential api key empty", ErrInvalidInput)
 		}
 		key.APIKey = secret
+		key.SecretRef = ""
 	}
 	if params.Endpoints != nil {
 		if len(params.Endpoints) == 0 {
@@ -507,6 +731,42 @@ func (s *service) ListBindingsByAPIKey(ctx context.Context, apiKeyID string) ([]
 	return result, nil
 }
 
+func (s *service) ListBindingsByUser(ctx context.Context, userID string) ([]BindingWithUpstream, error) {
+	if strings.TrimSpace(userID) == "" {
+		return nil, fmt.Errorf("%w: user_id required", ErrInvalidInput)
+	}
+	var bindings []UserKeyBinding
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("user_api_key_id ASC, position ASC, created_at ASC").
+		Find(&bindings).Error; err != nil {
+		return nil, err
+	}
+	if len(bindings) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, 0, len(bindings))
+	for _, binding := range bindings {
+		ids = append(ids, binding.UpstreamKeyID)
+	}
+	var upstreams []UpstreamKey
+	if err := s.db.WithContext(ctx).Where("id IN ?", uniqueTrimmed(ids)).Find(&upstreams).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[string]UpstreamKey, len(upstreams))
+	for _, upstream := range upstreams {
+		byID[upstream.ID] = upstream
+	}
+	result := make([]BindingWithUpstream, 0, len(bindings))
+	for _, binding := range bindings {
+		result = append(result, BindingWithUpstream{
+			Binding:  UserAPIKeyBinding(binding),
+			Upstream: UpstreamCredential(byID[binding.UpstreamKeyID]),
+		})
+	}
+	return result, nil
+}
+
 func (s *service) DeleteBinding(ctx context.Context, bindingID string) error {
 	if strings.TrimSpace(bindingID) == "" {
 		return fmt.Errorf("%w: binding_id required", ErrInvalidInput)
@@ -534,18 +794,105 @@ func (s *service) ResolveAPIKey(ctx context.Context, rawKey string) (APIKey, err
 	if err != nil {
 		return APIKey{}, err
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(key.SecretHash), []byte(secret)); err != nil {
+	if !s.hasher.Verify(key.SecretHash, secret) {
 		return APIKey{}, ErrNotFound
 	}
+	if s.hasher.NeedsRehash(key.SecretHash) {
+		s.upgradeSecretHash(ctx, &key, secret)
+	}
 	return key, nil
 }
 
+// upgradeSecretHash re-hashes a verified secret with the current algorithm.
+// Failures are ignored so that authentication is never blocked by an upgrade.
+func (s *service) upgradeSecretHash(ctx context.Context, key *APIKey, secret string) {
+	upgraded, err := s.hasher.Hash(secret)
+	if err != nil {
+		return
+	}
+	result := s.db.WithContext(ctx).Model(&APIKey{}).
+		Where("id = ? AND secret_hash = ?", key.ID, key.SecretHash).
+		Update("secret_hash", upgraded)
+	if result.Error == nil && result.RowsAffected > 0 {
+		key.SecretHash = upgraded
+	}
+}
+
 func (s *service) ResolveBindingByRawKey(ctx context.Context, rawKey string) (UserAPIKeyBinding, UpstreamCredential, error) {
 	key, err := s.ResolveAPIKey(ctx, rawKey)
 	if err != nil {
 		return UserAPIKeyBinding{}, UpstreamCredential{}, err
 	}
-	return s.GetBindingByAPIKeyID(ctx, key.ID)
+	return s.resolveBinding(ctx, key)
+}
+
+// ResolveBindingByAPIKeyID selects the binding for an already identified key
+// the same way ResolveBindingByRawKey does for live traffic.
+func (s *service) ResolveBindingByAPIKeyID(ctx context.Context, apiKeyID string) (UserAPIKeyBinding, UpstreamCredential, error) {
+	key, err := s.GetUserAPIKey(ctx, apiKeyID)
+	if err != nil {
+		return UserAPIKeyBinding{}, UpstreamCredential{}, err
+	}
+	return s.resolveBinding(ctx, key)
+}
+
+// resolveBinding returns the first binding with an enabled upstream, falling
+// back to the user's default credential.
+func (s *service) resolveBinding(ctx context.Context, key APIKey) (UserAPIKeyBinding, UpstreamCredential, error) {
+	bindings, err := s.ListBindingsByAPIKey(ctx, key.ID)
+	if err != nil {
+		return UserAPIKeyBinding{}, UpstreamCredential{}, err
+	}
+	// Skip bindings whose upstream key has been disabled.
+	for _, item := range bindings {
+		if item.Upstream.Enabled {
+			return item.Binding, item.Upstream, nil
+		}
+	}
+	binding, upstream, err := s.defaultBinding(ctx, key, "")
+	if errors.Is(err, ErrNotFound) && len(bindings) > 0 {
+		// Surface the disabled primary binding so callers can reject it explicitly.
+		return bindings[0].Binding, bindings[0].Upstream, nil
+	}
+	return binding, upstream, err
+}
+
+// ResolveDefaultBinding returns an unsaved binding of the key to its user's
+// enabled default credential for service, used when a rule targets a
+// provider none of the key's explicit bindings cover.
+func (s *service) ResolveDefaultBinding(ctx context.Context, apiKeyID, service string) (UserAPIKeyBinding, UpstreamCredential, error) {
+	key, err := s.GetUserAPIKey(ctx, apiKeyID)
+	if err != nil {
+		return UserAPIKeyBinding{}, UpstreamCredential{}, err
+	}
+	return s.defaultBinding(ctx, key, service)
+}
+
+// defaultBinding synthesizes an unsaved binding to the user's default
+// credential for keys that have no explicit binding. Defaults are kept per
+// provider; an empty provider picks the earliest default of any provider.
+func (s *service) defaultBinding(ctx context.Context, key APIKey, provider string) (UserAPIKeyBinding, UpstreamCredential, error) {
+	query := s.db.WithContext(ctx).
+		Where("user_id = ? AND is_default = ? AND enabled = ?", key.UserID, true, true)
+	if provider = strings.TrimSpace(provider); provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+	var upstream UpstreamKey
+	err := query.Order("created_at ASC").First(&upstream).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return UserAPIKeyBinding{}, UpstreamCredential{}, ErrNotFound
+	}
+	if err != nil {
+		return UserAPIKeyBinding{}, UpstreamCredential{}, err
+	}
+	binding := UserKeyBinding{
+		UserID:        key.UserID,
+		UserAPIKeyID:  key.ID,
+		UpstreamKeyID: upstream.ID,
+		Service:       upstream.Service,
+		Metadata:      datatypes.JSONMap{"source": "user_default"},
+	}
+	return UserAPIKeyBinding(binding), UpstreamCredential(upstream), nil
 }
 
 func firstNonEmpty(values ...string) string {
@@ -568,6 +915,25 @@ func generateAPIKey() (string, string, error) {
 	return plain, prefix, nil
 }
 
+// importedAPIKeyPrefix validates a caller-supplied key, which must be as long
+// and as random-looking as a generated one, and returns its lookup prefix.
+func importedAPIKeyPrefix(raw string) (string, error) {
+	prefix, _, err := splitAPIKey(raw)
+	if err != nil {
+		return "", err
+	}
+	secret := raw[strings.LastIndex(raw, "_")+1:]
+	if len(secret) != 2*(apiKeySecretBytes-defaultAPIKeyPrefixSegment) || !isHex(prefix+secret) {
+		return "", fmt.Errorf("%w: api key must use the yapi_<8 hex>_<40 hex> format", ErrInvalidInput)
+	}
+	return prefix, nil
+}
+
+func isHex(value string) bool {
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
+
 func splitAPIKey(raw string) (string, string, error) {
 	if strings.TrimSpace(raw) == "" {
 		return "", "", fmt.Errorf("%w: api key empty", ErrInvalidInput)
@@ -579,13 +945,14 @@ func splitAPIKey(raw string) (string, string, error) {
 	return parts[1], raw, nil
 }
 
-func hashSecret(secret string, cost int) (string, error) {
-	if strings.TrimSpace(secret) == "" {
-		return "", fmt.Errorf("%w: secret empty", ErrInvalidInput)
+// truncateUTF8 cuts s to at most n bytes without splitting a multi-byte
+// character, which Postgres would reject as invalid UTF-8.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
 	}
-	hash, err := bcrypt.GenerateFromPassword([]byte(secret), cost)
-	if err != nil {
-		return "", err
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
 	}
-	return string(hash), nil
+	return s[:n]
 }
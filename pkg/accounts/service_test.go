@@ -2,7 +2,9 @@ package accounts
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
@@ -31,7 +33,7 @@ func TestService_UserAPIKeyLifecycle(t *testing.T) {
 	require.NotEmpty(t, plain)
 	require.Len(t, key.Prefix, apiKeyPrefixLength)
 
-	keys, err := svc.ListUserAPIKeys(ctx, user.ID)
+	keys, _, err := svc.ListUserAPIKeys(ctx, user.ID, ListOptions{})
 	require.NoError(t, err)
 	require.Len(t, keys, 1)
 
@@ -46,7 +48,7 @@ func TestService_UserAPIKeyLifecycle(t *testing.T) {
 	require.Equal(t, "openai", cred.Service)
 	require.Equal(t, "primary", cred.Name)
 
-	creds, err := svc.ListUpstreamCredentials(ctx, user.ID)
+	creds, _, err := svc.ListUpstreamCredentials(ctx, user.ID, ListOptions{})
 	require.NoError(t, err)
 	require.Len(t, creds, 1)
 
@@ -99,3 +101,381 @@ func TestService_BindOwnershipValidation(t *testing.T) {
 	})
 	require.ErrorIs(t, err, ErrConflict)
 }
+
+func TestService_ResolveAPIKey_UpgradesHash(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open("file:hash_upgrade?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	legacy := NewService(db)
+	require.NoError(t, legacy.AutoMigrate(ctx))
+
+	user, err := legacy.CreateUser(ctx, CreateUserParams{Name: "hash-upgrade"})
+	require.NoError(t, err)
+	key, plain, err := legacy.CreateUserAPIKey(ctx, CreateAPIKeyParams{UserID: user.ID})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(key.SecretHash, "$2"))
+
+	hasher, err := NewSecretHasher(HashConfig{Algorithm: HashAlgorithmArgon2id, Argon2MemoryKB: 1024})
+	require.NoError(t, err)
+	upgraded := NewService(db, WithSecretHasher(hasher))
+
+	resolved, err := upgraded.ResolveAPIKey(ctx, plain)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(resolved.SecretHash, "$argon2id$"))
+
+	var stored APIKey
+	require.NoError(t, db.First(&stored, "id = ?", key.ID).Error)
+	require.Equal(t, resolved.SecretHash, stored.SecretHash)
+
+	// 旧算法的服务仍可验证升级后的哈希，便于滚动发布。
+	_, err = legacy.ResolveAPIKey(ctx, plain)
+	require.NoError(t, err)
+}
+
+func TestSecretHasher_HMACSHA256(t *testing.T) {
+	_, err := NewSecretHasher(HashConfig{Algorithm: HashAlgorithmHMACSHA256})
+	require.ErrorIs(t, err, ErrInvalidInput)
+
+	hasher, err := NewSecretHasher(HashConfig{Algorithm: HashAlgorithmHMACSHA256, HMACKey: []byte("pepper")})
+	require.NoError(t, err)
+	hash, err := hasher.Hash("yapi_abcd1234_secret")
+	require.NoError(t, err)
+	require.True(t, hasher.Verify(hash, "yapi_abcd1234_secret"))
+	require.False(t, hasher.Verify(hash, "yapi_abcd1234_other"))
+	require.False(t, hasher.NeedsRehash(hash))
+
+	bcryptHash, err := DefaultSecretHasher().Hash("yapi_abcd1234_secret")
+	require.NoError(t, err)
+	require.True(t, hasher.Verify(bcryptHash, "yapi_abcd1234_secret"))
+	require.True(t, hasher.NeedsRehash(bcryptHash))
+}
+
+func TestService_BindAPIKey_OrderedFallbacks(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open("file:binding_fallbacks?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	svc := NewService(db)
+	require.NoError(t, svc.AutoMigrate(ctx))
+
+	user, err := svc.CreateUser(ctx, CreateUserParams{Name: "fallbacks"})
+	require.NoError(t, err)
+	key, plain, err := svc.CreateUserAPIKey(ctx, CreateAPIKeyParams{UserID: user.ID})
+	require.NoError(t, err)
+	primary, err := svc.CreateUpstreamCredential(ctx, CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", Plaintext: "sk-primary"})
+	require.NoError(t, err)
+	backup, err := svc.CreateUpstreamCredential(ctx, CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", Plaintext: "sk-backup"})
+	require.NoError(t, err)
+
+	_, err = svc.BindAPIKey(ctx, BindAPIKeyParams{UserID: user.ID, UserAPIKeyID: key.ID, UpstreamCredentialID: backup.ID, Position: 1})
+	require.NoError(t, err)
+	first, err := svc.BindAPIKey(ctx, BindAPIKeyParams{UserID: user.ID, UserAPIKeyID: key.ID, UpstreamCredentialID: backup.ID})
+	require.NoError(t, err)
+	// Rebinding the same service/position replaces the upstream in place.
+	rebound, err := svc.BindAPIKey(ctx, BindAPIKeyParams{UserID: user.ID, UserAPIKeyID: key.ID, UpstreamCredentialID: primary.ID})
+	require.NoError(t, err)
+	require.Equal(t, first.ID, rebound.ID)
+
+	bindings, err := svc.ListBindingsByAPIKey(ctx, key.ID)
+	require.NoError(t, err)
+	require.Len(t, bindings, 2)
+	require.Equal(t, primary.ID, bindings[0].Upstream.ID)
+	require.Equal(t, 0, bindings[0].Binding.Position)
+	require.Equal(t, backup.ID, bindings[1].Upstream.ID)
+	require.Equal(t, "openai", bindings[1].Binding.Service)
+
+	resolved, cred, err := svc.ResolveBindingByRawKey(ctx, plain)
+	require.NoError(t, err)
+	require.Equal(t, rebound.ID, resolved.ID)
+	require.Equal(t, primary.ID, cred.ID)
+
+	_, err = svc.BindAPIKey(ctx, BindAPIKeyParams{UserID: user.ID, UserAPIKeyID: key.ID, UpstreamCredentialID: primary.ID, Position: -1})
+	require.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func TestService_UpstreamKeyPoolMembership(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open("file:key_pools?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	svc := NewService(db)
+	require.NoError(t, svc.AutoMigrate(ctx))
+
+	user, err := svc.CreateUser(ctx, CreateUserParams{Name: "pools"})
+	require.NoError(t, err)
+	first, err := svc.CreateUpstreamCredential(ctx, CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", Plaintext: "sk-1"})
+	require.NoError(t, err)
+	second, err := svc.CreateUpstreamCredential(ctx, CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", Plaintext: "sk-2"})
+	require.NoError(t, err)
+	other, err := svc.CreateUpstreamCredential(ctx, CreateUpstreamCredentialParams{UserID: user.ID, Provider: "anthropic", Plaintext: "sk-ant"})
+	require.NoError(t, err)
+
+	_, err = svc.CreateUpstreamKeyPool(ctx, CreateUpstreamKeyPoolParams{
+		UserID: user.ID, Name: "mixed", Provider: "openai", CredentialIDs: []string{first.ID, other.ID},
+	})
+	require.ErrorIs(t, err, ErrInvalidInput)
+
+	pool, err := svc.CreateUpstreamKeyPool(ctx, CreateUpstreamKeyPoolParams{
+		UserID: user.ID, Name: "openai", Provider: "openai", Strategy: "lru", CredentialIDs: []string{first.ID, second.ID},
+	})
+	require.NoError(t, err)
+	require.Equal(t, PoolStrategyLeastRecentlyUsed, pool.Strategy)
+
+	members, err := svc.ListUpstreamKeyPoolMembers(ctx, pool.ID)
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+
+	only := []string{second.ID}
+	updated, err := svc.UpdateUpstreamKeyPool(ctx, UpdateUpstreamKeyPoolParams{PoolID: pool.ID, CredentialIDs: &only})
+	require.NoError(t, err)
+	require.Equal(t, only, updated.CredentialIDs)
+	reloaded, err := svc.GetUpstreamCredential(ctx, first.ID)
+	require.NoError(t, err)
+	require.Empty(t, reloaded.PoolID)
+
+	require.NoError(t, svc.DeleteUpstreamKeyPool(ctx, pool.ID))
+	reloaded, err = svc.GetUpstreamCredential(ctx, second.ID)
+	require.NoError(t, err)
+	require.Empty(t, reloaded.PoolID)
+}
+
+func TestService_ListUsers_PaginationAndSearch(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open("file:list_users?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	svc := NewService(db)
+	require.NoError(t, svc.AutoMigrate(ctx))
+
+	for _, name := range []string{"alice", "Alicia", "bob", "carol", "al_x"} {
+		_, err := svc.CreateUser(ctx, CreateUserParams{Name: name})
+		require.NoError(t, err)
+	}
+
+	page, total, err := svc.ListUsers(ctx, ListOptions{Limit: 2, Offset: 1})
+	require.NoError(t, err)
+	require.EqualValues(t, 5, total)
+	require.Len(t, page, 2)
+
+	matched, total, err := svc.ListUsers(ctx, ListOptions{Search: "ALI"})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.Len(t, matched, 2)
+
+	// LIKE wildcards in the keyword are matched literally.
+	matched, total, err = svc.ListUsers(ctx, ListOptions{Search: "l_"})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	require.Equal(t, "al_x", matched[0].Name)
+}
+
+func TestService_ResolveBindingByRawKey_FallsBackToDefault(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open("file:default_upstream?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	svc := NewService(db)
+	require.NoError(t, svc.AutoMigrate(ctx))
+
+	user, err := svc.CreateUser(ctx, CreateUserParams{Name: "defaults"})
+	require.NoError(t, err)
+	_, plain, err := svc.CreateUserAPIKey(ctx, CreateAPIKeyParams{UserID: user.ID})
+	require.NoError(t, err)
+	first, err := svc.CreateUpstreamCredential(ctx, CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", Plaintext: "sk-1"})
+	require.NoError(t, err)
+	second, err := svc.CreateUpstreamCredential(ctx, CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", Plaintext: "sk-2"})
+	require.NoError(t, err)
+
+	_, _, err = svc.ResolveBindingByRawKey(ctx, plain)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = svc.SetUpstreamCredentialDefault(ctx, first.ID, true)
+	require.NoError(t, err)
+	marked, err := svc.SetUpstreamCredentialDefault(ctx, second.ID, true)
+	require.NoError(t, err)
+	require.True(t, marked.IsDefault)
+
+	reloaded, err := svc.GetUpstreamCredential(ctx, first.ID)
+	require.NoError(t, err)
+	require.False(t, reloaded.IsDefault, "only one default per provider")
+
+	binding, cred, err := svc.ResolveBindingByRawKey(ctx, plain)
+	require.NoError(t, err)
+	require.Equal(t, second.ID, cred.ID)
+	require.Equal(t, second.ID, binding.UpstreamKeyID)
+	require.Equal(t, user.ID, binding.UserID)
+	require.Equal(t, "openai", binding.Service)
+
+	// 默认凭据按供应商区分，按服务查找时只返回该供应商的默认凭据。
+	claude, err := svc.CreateUpstreamCredential(ctx, CreateUpstreamCredentialParams{UserID: user.ID, Provider: "anthropic", Plaintext: "sk-ant"})
+	require.NoError(t, err)
+	_, err = svc.SetUpstreamCredentialDefault(ctx, claude.ID, true)
+	require.NoError(t, err)
+	binding, cred, err = svc.ResolveDefaultBinding(ctx, binding.UserAPIKeyID, "anthropic")
+	require.NoError(t, err)
+	require.Equal(t, claude.ID, cred.ID)
+	require.Equal(t, "anthropic", binding.Service)
+	_, cred, err = svc.ResolveDefaultBinding(ctx, binding.UserAPIKeyID, "openai")
+	require.NoError(t, err)
+	require.Equal(t, second.ID, cred.ID)
+	_, _, err = svc.ResolveDefaultBinding(ctx, binding.UserAPIKeyID, "gemini")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestService_ResolveBindingByRawKey_SkipsDisabledUpstream(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open("file:disabled_upstream?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	svc := NewService(db)
+	require.NoError(t, svc.AutoMigrate(ctx))
+
+	user, err := svc.CreateUser(ctx, CreateUserParams{Name: "toggles"})
+	require.NoError(t, err)
+	key, plain, err := svc.CreateUserAPIKey(ctx, CreateAPIKeyParams{UserID: user.ID})
+	require.NoError(t, err)
+	primary, err := svc.CreateUpstreamCredential(ctx, CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", Plaintext: "sk-1"})
+	require.NoError(t, err)
+	backup, err := svc.CreateUpstreamCredential(ctx, CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", Plaintext: "sk-2"})
+	require.NoError(t, err)
+	_, err = svc.BindAPIKey(ctx, BindAPIKeyParams{UserID: user.ID, UserAPIKeyID: key.ID, UpstreamCredentialID: primary.ID})
+	require.NoError(t, err)
+	_, err = svc.BindAPIKey(ctx, BindAPIKeyParams{UserID: user.ID, UserAPIKeyID: key.ID, UpstreamCredentialID: backup.ID, Position: 1})
+	require.NoError(t, err)
+
+	require.NoError(t, svc.SetUpstreamCredentialEnabled(ctx, primary.ID, false))
+	_, cred, err := svc.ResolveBindingByRawKey(ctx, plain)
+	require.NoError(t, err)
+	require.Equal(t, backup.ID, cred.ID)
+	_, cred, err = svc.ResolveBindingByAPIKeyID(ctx, key.ID)
+	require.NoError(t, err)
+	require.Equal(t, backup.ID, cred.ID)
+
+	require.NoError(t, svc.SetUpstreamCredentialEnabled(ctx, backup.ID, false))
+	_, cred, err = svc.ResolveBindingByRawKey(ctx, plain)
+	require.NoError(t, err)
+	require.Equal(t, primary.ID, cred.ID)
+	require.False(t, cred.Enabled)
+
+	userBindings, err := svc.ListBindingsByUser(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, userBindings, 2)
+	require.Equal(t, backup.ID, userBindings[1].Upstream.ID)
+
+	require.NoError(t, svc.SetUserAPIKeyEnabled(ctx, key.ID, false))
+	reloaded, err := svc.GetUserAPIKey(ctx, key.ID)
+	require.NoError(t, err)
+	require.False(t, reloaded.Enabled)
+}
+
+func TestService_SelectUpstreamByMetadata(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open("file:metadata_select?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	svc := NewService(db)
+	require.NoError(t, svc.AutoMigrate(ctx))
+
+	user, err := svc.CreateUser(ctx, CreateUserParams{Name: "regions"})
+	require.NoError(t, err)
+	_, err = svc.CreateUpstreamCredential(ctx, CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", Plaintext: "sk-us", Metadata: map[string]any{"region": "us"}})
+	require.NoError(t, err)
+	eu, err := svc.CreateUpstreamCredential(ctx, CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", Plaintext: "sk-eu", Metadata: map[string]any{"region": "eu", "tier": 2}})
+	require.NoError(t, err)
+
+	got, err := svc.SelectUpstreamByMetadata(ctx, user.ID, "openai", map[string]string{"region": "eu", "tier": "2"})
+	require.NoError(t, err)
+	require.Equal(t, eu.ID, got.ID)
+
+	_, err = svc.SelectUpstreamByMetadata(ctx, user.ID, "anthropic", map[string]string{"region": "eu"})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, svc.SetUpstreamCredentialEnabled(ctx, eu.ID, false))
+	_, err = svc.SelectUpstreamByMetadata(ctx, user.ID, "", map[string]string{"region": "eu"})
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestService_RateLimits_KeyOverridesUser(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open("file:rate_limits?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	svc := NewService(db)
+	require.NoError(t, svc.AutoMigrate(ctx))
+
+	user, err := svc.CreateUser(ctx, CreateUserParams{Name: "limited", RateLimits: RateLimits{MaxRequestsPerMinute: 60, MaxConcurrentRequests: 2}})
+	require.NoError(t, err)
+	key, _, err := svc.CreateUserAPIKey(ctx, CreateAPIKeyParams{UserID: user.ID})
+	require.NoError(t, err)
+
+	rpm, concurrency := EffectiveRateLimits(user, key)
+	require.Equal(t, EffectiveLimit{Limit: 60, Scope: RateLimitScope{Kind: RateLimitScopeUser, ID: user.ID}}, rpm)
+	require.Equal(t, 2, concurrency.Limit)
+
+	key, err = svc.SetAPIKeyRateLimits(ctx, key.ID, RateLimits{MaxRequestsPerMinute: 10})
+	require.NoError(t, err)
+	rpm, concurrency = EffectiveRateLimits(user, key)
+	require.Equal(t, EffectiveLimit{Limit: 10, Scope: RateLimitScope{Kind: RateLimitScopeAPIKey, ID: key.ID}}, rpm)
+	require.Equal(t, RateLimitScopeUser, concurrency.Scope.Kind)
+
+	_, err = svc.SetUserRateLimits(ctx, user.ID, RateLimits{MaxRequestsPerMinute: -1})
+	require.ErrorIs(t, err, ErrInvalidInput)
+	reloaded, err := svc.SetUserRateLimits(ctx, user.ID, RateLimits{})
+	require.NoError(t, err)
+	require.Zero(t, reloaded.MaxRequestsPerMinute)
+}
+
+func TestService_ModelAccess_KeyNarrowsUser(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open("file:model_access?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	svc := NewService(db)
+	require.NoError(t, svc.AutoMigrate(ctx))
+
+	user, err := svc.CreateUser(ctx, CreateUserParams{Name: "cheap", ModelAccess: ModelAccess{DeniedModels: []string{" o1* "}}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"o1*"}, []string(user.DeniedModels))
+	key, _, err := svc.CreateUserAPIKey(ctx, CreateAPIKeyParams{UserID: user.ID})
+	require.NoError(t, err)
+	require.True(t, ModelPermitted(user, key, "gpt-4o"))
+	require.False(t, ModelPermitted(user, key, "O1-preview"))
+
+	key, err = svc.SetAPIKeyModelAccess(ctx, key.ID, ModelAccess{AllowedModels: []string{"gpt-4o-mini", "o1-mini"}})
+	require.NoError(t, err)
+	require.True(t, ModelPermitted(user, key, "gpt-4o-mini"))
+	require.False(t, ModelPermitted(user, key, "gpt-4o"))
+	require.False(t, ModelPermitted(user, key, "o1-mini"))
+
+	_, err = svc.SetUserModelAccess(ctx, user.ID, ModelAccess{AllowedModels: []string{"gpt-*-mini*"}})
+	require.ErrorIs(t, err, ErrInvalidInput)
+	user, err = svc.SetUserModelAccess(ctx, user.ID, ModelAccess{})
+	require.NoError(t, err)
+	require.Empty(t, user.DeniedModels)
+	_, err = svc.SetAPIKeyModelAccess(ctx, "missing", ModelAccess{})
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestService_UpdateUser_ReplacesMutableFields(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open("file:update_user?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	svc := NewService(db)
+	require.NoError(t, svc.AutoMigrate(ctx))
+
+	user, err := svc.CreateUser(ctx, CreateUserParams{Name: "bob", Description: "old", Metadata: map[string]any{"team": "a"}})
+	require.NoError(t, err)
+
+	updated, err := svc.UpdateUser(ctx, UpdateUserParams{UserID: user.ID, Description: " new ", RateLimits: RateLimits{MaxRequestsPerMinute: 30}})
+	require.NoError(t, err)
+	require.Equal(t, "bob", updated.Name)
+	require.Equal(t, "new", updated.Description)
+	require.Empty(t, updated.Metadata)
+	require.Equal(t, 30, updated.MaxRequestsPerMinute)
+
+	_, err = svc.UpdateUser(ctx, UpdateUserParams{UserID: user.ID, RateLimits: RateLimits{MaxConcurrentRequests: -1}})
+	require.ErrorIs(t, err, ErrInvalidInput)
+	_, err = svc.UpdateUser(ctx, UpdateUserParams{UserID: "missing"})
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	require.Equal(t, "short", truncateUTF8("short", 255))
+	detail := strings.Repeat("a", 254) + "连接超时"
+	truncated := truncateUTF8(detail, 255)
+	require.True(t, utf8.ValidString(truncated))
+	require.Equal(t, strings.Repeat("a", 254), truncated)
+	require.Equal(t, "连接", truncateUTF8("连接超时", 7))
+}
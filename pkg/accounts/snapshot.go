@@ -0,0 +1,197 @@
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Snapshot is a point-in-time copy of all live account records. Records keep
+// their original IDs and API key hashes so that a restored snapshot accepts
+// the same client keys as the source deployment.
+type Snapshot struct {
+	Users     []User
+	APIKeys   []APIKey
+	Upstreams []UpstreamCredential
+	Pools     []UpstreamKeyPool
+	Bindings  []UserAPIKeyBinding
+}
+
+// ExportSnapshot reads every live user, API key, upstream credential, key pool
+// and binding, ordered by ID.
+func (s *service) ExportSnapshot(ctx context.Context) (Snapshot, error) {
+	var snapshot Snapshot
+	db := s.db.WithContext(ctx)
+	for _, target := range []any{&snapshot.Users, &snapshot.APIKeys, &snapshot.Upstreams, &snapshot.Pools, &snapshot.Bindings} {
+		if err := db.Order("id").Find(target).Error; err != nil {
+			return Snapshot{}, err
+		}
+	}
+	return snapshot, nil
+}
+
+// ImportSnapshot upserts the snapshot by ID in a single transaction. Existing
+// records with the same ID are overwritten (soft-deleted ones are revived);
+// records absent from the snapshot are left untouched. A disabled upstream
+// credential may omit its secret so that backups without secrets can still be
+// restored as placeholders.
+func (s *service) ImportSnapshot(ctx context.Context, snapshot Snapshot) error {
+	if err := snapshot.validate(); err != nil {
+		return err
+	}
+	// Create skips zero-valued fields that carry a column default, so disabled
+	// records are collected here and switched off after the upsert.
+	var disabledKeys, disabledUpstreams []string
+	for _, key := range snapshot.APIKeys {
+		if !key.Enabled {
+			disabledKeys = append(disabledKeys, key.ID)
+		}
+	}
+	for _, upstream := range snapshot.Upstreams {
+		if !upstream.Enabled {
+			disabledUpstreams = append(disabledUpstreams, upstream.ID)
+		}
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		checks := make([]uniqueCheck, 0, len(snapshot.Users)+len(snapshot.APIKeys)+len(snapshot.Pools)+len(snapshot.Bindings))
+		for _, user := range snapshot.Users {
+			checks = append(checks, uniqueCheck{&User{}, user.ID, "user name " + user.Name, "name = ?", []any{user.Name}})
+		}
+		for _, key := range snapshot.APIKeys {
+			checks = append(checks, uniqueCheck{&APIKey{}, key.ID, "api key prefix " + key.Prefix, "prefix = ?", []any{key.Prefix}})
+		}
+		for _, pool := range snapshot.Pools {
+			checks = append(checks, uniqueCheck{&UpstreamKeyPool{}, pool.ID, "pool name " + pool.Name, "user_id = ? AND name = ?", []any{pool.UserID, pool.Name}})
+		}
+		for _, binding := range snapshot.Bindings {
+			checks = append(checks, uniqueCheck{&UserKeyBinding{}, binding.ID,
+				fmt.Sprintf("binding %s/%s/%d", binding.UserAPIKeyID, binding.Service, binding.Position),
+				"user_api_key_id = ? AND service = ? AND position = ?", []any{binding.UserAPIKeyID, binding.Service, binding.Position}})
+		}
+		for _, check := range checks {
+			if err := check.run(tx); err != nil {
+				return err
+			}
+		}
+		upserts := []struct {
+			count int
+			rows  any
+		}{
+			{len(snapshot.Users), ptr(slices.Clone(snapshot.Users))},
+			{len(snapshot.APIKeys), ptr(slices.Clone(snapshot.APIKeys))},
+			{len(snapshot.Pools), ptr(slices.Clone(snapshot.Pools))},
+			{len(snapshot.Upstreams), ptr(slices.Clone(snapshot.Upstreams))},
+			{len(snapshot.Bindings), ptr(slices.Clone(snapshot.Bindings))},
+		}
+		for _, upsert := range upserts {
+			if upsert.count == 0 {
+				continue
+			}
+			// Copies are inserted because Create back-fills column defaults
+			// into the rows, which must not leak into the caller's snapshot.
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(upsert.rows).Error; err != nil {
+				return err
+			}
+		}
+		if len(disabledKeys) > 0 {
+			if err := tx.Model(&APIKey{}).Where("id IN ?", disabledKeys).Update("enabled", false).Error; err != nil {
+				return err
+			}
+		}
+		if len(disabledUpstreams) > 0 {
+			if err := tx.Model(&UpstreamKey{}).Where("id IN ?", disabledUpstreams).Update("enabled", false).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// validate checks every record and that references resolve within the snapshot.
+func (snapshot Snapshot) validate() error {
+	users := make(map[string]bool, len(snapshot.Users))
+	for _, user := range snapshot.Users {
+		if strings.TrimSpace(user.ID) == "" {
+			return fmt.Errorf("%w: user id empty", ErrInvalidInput)
+		}
+		if err := user.Validate(); err != nil {
+			return err
+		}
+		users[user.ID] = true
+	}
+	keys := make(map[string]string, len(snapshot.APIKeys))
+	for _, key := range snapshot.APIKeys {
+		if err := key.Validate(); err != nil {
+			return err
+		}
+		if key.ID == "" || !users[key.UserID] {
+			return fmt.Errorf("%w: api key %q references unknown user", ErrInvalidInput, key.ID)
+		}
+		keys[key.ID] = key.UserID
+	}
+	pools := make(map[string]string, len(snapshot.Pools))
+	for _, pool := range snapshot.Pools {
+		if err := pool.Validate(); err != nil {
+			return err
+		}
+		if pool.ID == "" || !users[pool.UserID] {
+			return fmt.Errorf("%w: pool %q references unknown user", ErrInvalidInput, pool.ID)
+		}
+		pools[pool.ID] = pool.UserID
+	}
+	upstreams := make(map[string]string, len(snapshot.Upstreams))
+	for _, upstream := range snapshot.Upstreams {
+		placeholder := !upstream.Enabled && strings.TrimSpace(upstream.APIKey) == "" && strings.TrimSpace(upstream.SecretRef) == ""
+		if placeholder {
+			upstream.APIKey = "-"
+		}
+		if err := upstream.Validate(); err != nil {
+			return err
+		}
+		if upstream.ID == "" || !users[upstream.UserID] {
+			return fmt.Errorf("%w: upstream credential %q references unknown user", ErrInvalidInput, upstream.ID)
+		}
+		if upstream.PoolID != "" && pools[upstream.PoolID] != upstream.UserID {
+			return fmt.Errorf("%w: upstream credential %q references unknown pool", ErrInvalidInput, upstream.ID)
+		}
+		upstreams[upstream.ID] = upstream.UserID
+	}
+	for _, binding := range snapshot.Bindings {
+		if err := binding.Validate(); err != nil {
+			return err
+		}
+		if binding.ID == "" || keys[binding.UserAPIKeyID] != binding.UserID || upstreams[binding.UpstreamKeyID] != binding.UserID {
+			return fmt.Errorf("%w: binding %q references unknown api key or upstream credential", ErrInvalidInput, binding.ID)
+		}
+	}
+	return nil
+}
+
+// uniqueCheck guards a unique column: the value must not already be held by a
+// record with another ID, including a soft-deleted one.
+type uniqueCheck struct {
+	model any
+	id    string
+	label string
+	query string
+	args  []any
+}
+
+func (c uniqueCheck) run(tx *gorm.DB) error {
+	var count int64
+	if err := tx.Unscoped().Model(c.model).Where(c.query, c.args...).Where("id <> ?", c.id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %s already used by another record", ErrConflict, c.label)
+	}
+	return nil
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
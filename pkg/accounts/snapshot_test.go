@@ -0,0 +1,64 @@
+package accounts
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openSnapshotService(t *testing.T, name string) Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	svc := NewService(db)
+	require.NoError(t, svc.AutoMigrate(context.Background()))
+	return svc
+}
+
+func TestService_SnapshotRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := openSnapshotService(t, "snapshot_source")
+	user, err := source.CreateUser(ctx, CreateUserParams{Name: "alice"})
+	require.NoError(t, err)
+	key, plain, err := source.CreateUserAPIKey(ctx, CreateAPIKeyParams{UserID: user.ID, Label: "default"})
+	require.NoError(t, err)
+	disabledKey, _, err := source.CreateUserAPIKey(ctx, CreateAPIKeyParams{UserID: user.ID, Label: "old"})
+	require.NoError(t, err)
+	require.NoError(t, source.SetUserAPIKeyEnabled(ctx, disabledKey.ID, false))
+	cred, err := source.CreateUpstreamCredential(ctx, CreateUpstreamCredentialParams{UserID: user.ID, Provider: "openai", Plaintext: "sk-test"})
+	require.NoError(t, err)
+	_, err = source.BindAPIKey(ctx, BindAPIKeyParams{UserID: user.ID, UserAPIKeyID: key.ID, UpstreamCredentialID: cred.ID})
+	require.NoError(t, err)
+
+	snapshot, err := source.ExportSnapshot(ctx)
+	require.NoError(t, err)
+	require.Len(t, snapshot.APIKeys, 2)
+
+	target := openSnapshotService(t, "snapshot_target")
+	require.NoError(t, target.ImportSnapshot(ctx, snapshot))
+	// 重复导入是幂等的。
+	require.NoError(t, target.ImportSnapshot(ctx, snapshot))
+
+	// 恢复后原有的 API Key 仍可解析到同一绑定。
+	binding, upstream, err := target.ResolveBindingByRawKey(ctx, plain)
+	require.NoError(t, err)
+	require.Equal(t, key.ID, binding.UserAPIKeyID)
+	require.Equal(t, "sk-test", upstream.APIKey)
+	restored, err := target.GetUserAPIKey(ctx, disabledKey.ID)
+	require.NoError(t, err)
+	require.False(t, restored.Enabled)
+
+	// 同名用户占用不同 ID 时拒绝导入，且不写入任何记录。
+	other := openSnapshotService(t, "snapshot_conflict")
+	_, err = other.CreateUser(ctx, CreateUserParams{Name: "alice"})
+	require.NoError(t, err)
+	require.ErrorIs(t, other.ImportSnapshot(ctx, snapshot), ErrConflict)
+	_, err = other.GetUserAPIKey(ctx, key.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	snapshot.Bindings[0].UpstreamKeyID = "missing"
+	require.ErrorIs(t, target.ImportSnapshot(ctx, snapshot), ErrInvalidInput)
+}
@@ -0,0 +1,708 @@
+// Package adminclient 提供 yapi 管理端 API 的类型化 Go 客户端，接口与
+// GET /admin/openapi.json 返回的 OpenAPI 文档保持一致，供自动化脚本与运维工具直接调用。
+package adminclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// basePath 是管理端 API 相对网关根地址的路径前缀，客户端始终使用带版本的路径。
+const basePath = "/admin/v1"
+
+// APIError 表示管理端返回的非 2xx 响应。
+type APIError struct {
+	StatusCode int
+	// Code 为网关错误码，如 YAPI_NOT_FOUND。
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("admin api: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("admin api: status %d: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound 判断错误是否为 404。
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsPreconditionFailed 判断错误是否为 412，即资源在读取后已被修改。
+func IsPreconditionFailed(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusPreconditionFailed
+}
+
+// Client 是管理端 API 客户端，可并发使用。
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+}
+
+// Option 定义客户端可选项。
+type Option func(*Client)
+
+// WithHTTPClient 指定底层 HTTP 客户端，默认使用 http.DefaultClient。
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithToken 指定访问令牌或服务令牌，未指定时可通过 Login 获取访问令牌。
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// New 创建客户端，baseURL 为网关根地址（如 http://localhost:8080）。
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Healthz 调用管理端存活检查。
+func (c *Client) Healthz(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/healthz", nil, nil, nil)
+}
+
+// Login 使用管理员账号换取访问令牌，可配合 WithToken 创建已认证的客户端。
+// 传入 permissions（如 "rules:read"）时令牌仅携带这些权限，适合只读看板与自动化脚本。
+func (c *Client) Login(ctx context.Context, username, password string, permissions ...string) (LoginResponse, error) {
+	var resp LoginResponse
+	body := map[string]any{"username": username, "password": password}
+	if len(permissions) > 0 {
+		body["permissions"] = permissions
+	}
+	err := c.do(ctx, http.MethodPost, "/login", nil, body, &resp)
+	return resp, err
+}
+
+// RefreshToken 以刷新令牌换取新的令牌对，旧刷新令牌随即失效。
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (LoginResponse, error) {
+	var resp LoginResponse
+	body := map[string]string{"refresh_token": refreshToken}
+	err := c.do(ctx, http.MethodPost, "/token/refresh", nil, body, &resp)
+	return resp, err
+}
+
+// Logout 吊销客户端当前的访问令牌，refreshToken 非空时一并吊销。
+func (c *Client) Logout(ctx context.Context, refreshToken string) error {
+	body := map[string]string{"refresh_token": refreshToken}
+	return c.do(ctx, http.MethodPost, "/logout", nil, body, nil)
+}
+
+// ListRules 分页列出规则。
+func (c *Client) ListRules(ctx context.Context, opts ListRulesOptions) (RuleList, error) {
+	query := url.Values{}
+	setPositive(query, "page", opts.Page)
+	setPositive(query, "page_size", opts.PageSize)
+	if opts.Search != "" {
+		query.Set("q", opts.Search)
+	}
+	if opts.Enabled != nil {
+		query.Set("enabled", strconv.FormatBool(*opts.Enabled))
+	}
+	if opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+	}
+	if opts.Order != "" {
+		query.Set("order", opts.Order)
+	}
+	var resp RuleList
+	err := c.do(ctx, http.MethodGet, "/rules", query, nil, &resp)
+	return resp, err
+}
+
+// DiffRules 比较候选规则集与当前规则（或 req.Base），只返回差异，不做修改。
+func (c *Client) DiffRules(ctx context.Context, req RulesDiffRequest) (RulesDiff, error) {
+	var resp RulesDiff
+	err := c.do(ctx, http.MethodPost, "/rules/diff", nil, req, &resp)
+	return resp, err
+}
+
+// ExplainRule 用样例请求逐条评估规则的匹配条件，不做修改。
+func (c *Client) ExplainRule(ctx context.Context, id string, req RuleExplainRequest) (RuleExplanation, error) {
+	var resp RuleExplanation
+	err := c.do(ctx, http.MethodPost, "/rules/"+url.PathEscape(id)+"/explain", nil, req, &resp)
+	return resp, err
+}
+
+// GetRule 获取规则详情。
+func (c *Client) GetRule(ctx context.Context, id string) (RuleDetail, error) {
+	var resp RuleDetail
+	err := c.do(ctx, http.MethodGet, "/rules/"+url.PathEscape(id), nil, nil, &resp)
+	return resp, err
+}
+
+// CreateRule 创建规则。
+func (c *Client) CreateRule(ctx context.Context, rule rules.Rule) (rules.Rule, error) {
+	var resp rules.Rule
+	err := c.do(ctx, http.MethodPost, "/rules", nil, rule, &resp)
+	return resp, err
+}
+
+// UpdateRule 无条件地整体更新已有规则（If-Match: *），会覆盖他人在此期间的修改；规则不存在时返回 412，创建请使用 CreateRule。
+func (c *Client) UpdateRule(ctx context.Context, id string, rule rules.Rule) (rules.Rule, error) {
+	return c.UpdateRuleIfMatch(ctx, id, "*", rule)
+}
+
+// UpdateRuleIfMatch 仅在规则的 ETag 仍为 etag（取自 GetRule 或 ListRules）时整体更新规则，
+// 规则已被他人修改时返回 412，可用 IsPreconditionFailed 判断。
+func (c *Client) UpdateRuleIfMatch(ctx context.Context, id, etag string, rule rules.Rule) (rules.Rule, error) {
+	req, err := c.newRequest(ctx, http.MethodPut, "/rules/"+url.PathEscape(id), nil, rule)
+	if err != nil {
+		return rules.Rule{}, err
+	}
+	req.Header.Set("If-Match", etag)
+	var resp rules.Rule
+	err = c.send(req, &resp)
+	return resp, err
+}
+
+// PatchRule 按 JSON Merge Patch 局部更新规则（If-Match: *），patch 中值为 nil 的字段会被删除。
+func (c *Client) PatchRule(ctx context.Context, id string, patch map[string]any) (RuleDetail, error) {
+	return c.PatchRuleIfMatch(ctx, id, "*", patch)
+}
+
+// PatchRuleIfMatch 仅在规则的 ETag 仍为 etag 时局部更新规则，规则已被他人修改时返回 412。
+func (c *Client) PatchRuleIfMatch(ctx context.Context, id, etag string, patch map[string]any) (RuleDetail, error) {
+	req, err := c.newRequest(ctx, http.MethodPatch, "/rules/"+url.PathEscape(id), nil, patch)
+	if err != nil {
+		return RuleDetail{}, err
+	}
+	req.Header.Set("If-Match", etag)
+	var resp RuleDetail
+	err = c.send(req, &resp)
+	return resp, err
+}
+
+// DeleteRule 无条件地删除规则（If-Match: *）。
+func (c *Client) DeleteRule(ctx context.Context, id string) error {
+	return c.DeleteRuleIfMatch(ctx, id, "*")
+}
+
+// DeleteRuleIfMatch 仅在规则的 ETag 仍为 etag 时删除规则，规则已被他人修改时返回 412。
+func (c *Client) DeleteRuleIfMatch(ctx context.Context, id, etag string) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, "/rules/"+url.PathEscape(id), nil, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("If-Match", etag)
+	return c.send(req, nil)
+}
+
+// EnableRule 无条件地启用规则（If-Match: *）。
+func (c *Client) EnableRule(ctx context.Context, id string) (RuleDetail, error) {
+	return c.setRuleEnabled(ctx, id, "*", "/enable")
+}
+
+// EnableRuleIfMatch 仅在规则的 ETag 仍为 etag 时启用规则，规则已被他人修改时返回 412。
+func (c *Client) EnableRuleIfMatch(ctx context.Context, id, etag string) (RuleDetail, error) {
+	return c.setRuleEnabled(ctx, id, etag, "/enable")
+}
+
+// DisableRule 无条件地停用规则（If-Match: *）。
+func (c *Client) DisableRule(ctx context.Context, id string) (RuleDetail, error) {
+	return c.setRuleEnabled(ctx, id, "*", "/disable")
+}
+
+// DisableRuleIfMatch 仅在规则的 ETag 仍为 etag 时停用规则，规则已被他人修改时返回 412。
+func (c *Client) DisableRuleIfMatch(ctx context.Context, id, etag string) (RuleDetail, error) {
+	return c.setRuleEnabled(ctx, id, etag, "/disable")
+}
+
+func (c *Client) setRuleEnabled(ctx context.Context, id, etag, suffix string) (RuleDetail, error) {
+	req, err := c.newRequest(ctx, http.MethodPost, "/rules/"+url.PathEscape(id)+suffix, nil, nil)
+	if err != nil {
+		return RuleDetail{}, err
+	}
+	req.Header.Set("If-Match", etag)
+	var resp RuleDetail
+	err = c.send(req, &resp)
+	return resp, err
+}
+
+// ListUsers 分页列出用户。
+func (c *Client) ListUsers(ctx context.Context, opts ListOptions) (UserList, error) {
+	var resp UserList
+	err := c.do(ctx, http.MethodGet, "/users", opts.values(), nil, &resp)
+	return resp, err
+}
+
+// CreateUser 创建用户。
+func (c *Client) CreateUser(ctx context.Context, req CreateUserRequest) (User, error) {
+	var resp User
+	err := c.do(ctx, http.MethodPost, "/users", nil, req, &resp)
+	return resp, err
+}
+
+// GetUser 获取用户及名下资源摘要。
+func (c *Client) GetUser(ctx context.Context, id string) (UserDetail, error) {
+	var resp UserDetail
+	err := c.do(ctx, http.MethodGet, "/users/"+url.PathEscape(id), nil, nil, &resp)
+	return resp, err
+}
+
+// PatchUser 更新用户限流与模型访问配置。
+func (c *Client) PatchUser(ctx context.Context, id string, req PatchUserRequest) (User, error) {
+	var resp User
+	err := c.do(ctx, http.MethodPatch, "/users/"+url.PathEscape(id), nil, req, &resp)
+	return resp, err
+}
+
+// DeleteUser 删除用户。
+func (c *Client) DeleteUser(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/users/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// ListUserAPIKeys 分页列出用户的 API Key。
+func (c *Client) ListUserAPIKeys(ctx context.Context, userID string, opts ListOptions) (APIKeyList, error) {
+	var resp APIKeyList
+	err := c.do(ctx, http.MethodGet, "/users/"+url.PathEscape(userID)+"/api-keys", opts.values(), nil, &resp)
+	return resp, err
+}
+
+// CreateUserAPIKey 为用户签发 API Key，返回值中的 Secret 仅此一次可见。
+func (c *Client) CreateUserAPIKey(ctx context.Context, userID string, req CreateAPIKeyRequest) (CreatedAPIKey, error) {
+	var resp CreatedAPIKey
+	err := c.do(ctx, http.MethodPost, "/users/"+url.PathEscape(userID)+"/api-keys", nil, req, &resp)
+	return resp, err
+}
+
+// PatchUserAPIKey 启用/停用 API Key 或调整其限流配置。
+func (c *Client) PatchUserAPIKey(ctx context.Context, id string, req PatchAPIKeyRequest) (APIKey, error) {
+	var resp APIKey
+	err := c.do(ctx, http.MethodPatch, "/api-keys/"+url.PathEscape(id), nil, req, &resp)
+	return resp, err
+}
+
+// DeleteUserAPIKey 吊销 API Key。
+func (c *Client) DeleteUserAPIKey(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api-keys/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// ListUpstreamCredentials 分页列出用户的上游凭据。
+func (c *Client) ListUpstreamCredentials(ctx context.Context, userID string, opts ListOptions) (UpstreamCredentialList, error) {
+	var resp UpstreamCredentialList
+	err := c.do(ctx, http.MethodGet, "/users/"+url.PathEscape(userID)+"/upstreams", opts.values(), nil, &resp)
+	return resp, err
+}
+
+// CreateUpstreamCredential 为用户创建上游凭据。
+func (c *Client) CreateUpstreamCredential(ctx context.Context, userID string, req CreateUpstreamCredentialRequest) (UpstreamCredential, error) {
+	var resp UpstreamCredential
+	err := c.do(ctx, http.MethodPost, "/users/"+url.PathEscape(userID)+"/upstreams", nil, req, &resp)
+	return resp, err
+}
+
+// UpdateUpstreamCredential 更新上游凭据。
+func (c *Client) UpdateUpstreamCredential(ctx context.Context, id string, req UpdateUpstreamCredentialRequest) (UpstreamCredential, error) {
+	var resp UpstreamCredential
+	err := c.do(ctx, http.MethodPut, "/upstreams/"+url.PathEscape(id), nil, req, &resp)
+	return resp, err
+}
+
+// PatchUpstreamCredential 启用/停用凭据或切换默认凭据。
+func (c *Client) PatchUpstreamCredential(ctx context.Context, id string, req PatchUpstreamCredentialRequest) (UpstreamCredential, error) {
+	var resp UpstreamCredential
+	err := c.do(ctx, http.MethodPatch, "/upstreams/"+url.PathEscape(id), nil, req, &resp)
+	return resp, err
+}
+
+// DeleteUpstreamCredential 删除上游凭据。
+func (c *Client) DeleteUpstreamCredential(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/upstreams/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// VerifyUpstreamCredential 立即验证上游凭据可用性。
+func (c *Client) VerifyUpstreamCredential(ctx context.Context, id string) (UpstreamHealth, error) {
+	var resp UpstreamHealth
+	err := c.do(ctx, http.MethodPost, "/upstreams/"+url.PathEscape(id)+"/verify", nil, nil, &resp)
+	return resp, err
+}
+
+// ListUpstreamKeyPools 列出用户的上游 Key 池。
+func (c *Client) ListUpstreamKeyPools(ctx context.Context, userID string) ([]UpstreamKeyPool, error) {
+	var resp struct {
+		Items []UpstreamKeyPool `json:"items"`
+	}
+	err := c.do(ctx, http.MethodGet, "/users/"+url.PathEscape(userID)+"/upstream-pools", nil, nil, &resp)
+	return resp.Items, err
+}
+
+// CreateUpstreamKeyPool 为用户创建上游 Key 池。
+func (c *Client) CreateUpstreamKeyPool(ctx context.Context, userID string, req CreateUpstreamKeyPoolRequest) (UpstreamKeyPool, error) {
+	var resp UpstreamKeyPool
+	err := c.do(ctx, http.MethodPost, "/users/"+url.PathEscape(userID)+"/upstream-pools", nil, req, &resp)
+	return resp, err
+}
+
+// UpdateUpstreamKeyPool 更新 Key 池。
+func (c *Client) UpdateUpstreamKeyPool(ctx context.Context, id string, req UpdateUpstreamKeyPoolRequest) (UpstreamKeyPool, error) {
+	var resp UpstreamKeyPool
+	err := c.do(ctx, http.MethodPut, "/upstream-pools/"+url.PathEscape(id), nil, req, &resp)
+	return resp, err
+}
+
+// DeleteUpstreamKeyPool 删除 Key 池。
+func (c *Client) DeleteUpstreamKeyPool(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/upstream-pools/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// BindAPIKey 将 API Key 绑定到上游凭据。
+func (c *Client) BindAPIKey(ctx context.Context, apiKeyID string, req BindAPIKeyRequest) (APIKeyBinding, error) {
+	var resp APIKeyBinding
+	err := c.do(ctx, http.MethodPost, "/api-keys/"+url.PathEscape(apiKeyID)+"/binding", nil, req, &resp)
+	return resp, err
+}
+
+// GetAPIKeyBinding 获取 API Key 的主绑定。
+func (c *Client) GetAPIKeyBinding(ctx context.Context, apiKeyID string) (APIKeyBinding, error) {
+	var resp APIKeyBinding
+	err := c.do(ctx, http.MethodGet, "/api-keys/"+url.PathEscape(apiKeyID)+"/binding", nil, nil, &resp)
+	return resp, err
+}
+
+// ListAPIKeyBindings 按顺位列出 API Key 的全部绑定。
+func (c *Client) ListAPIKeyBindings(ctx context.Context, apiKeyID string) ([]APIKeyBinding, error) {
+	var resp struct {
+		Items []APIKeyBinding `json:"items"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api-keys/"+url.PathEscape(apiKeyID)+"/bindings", nil, nil, &resp)
+	return resp.Items, err
+}
+
+// ListUserBindings 列出用户名下全部绑定。
+func (c *Client) ListUserBindings(ctx context.Context, userID string) ([]APIKeyBinding, error) {
+	var resp struct {
+		Items []APIKeyBinding `json:"items"`
+	}
+	err := c.do(ctx, http.MethodGet, "/users/"+url.PathEscape(userID)+"/bindings", nil, nil, &resp)
+	return resp.Items, err
+}
+
+// DeleteBinding 删除绑定。
+func (c *Client) DeleteBinding(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/bindings/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// PlanApply 预览期望状态与当前状态的差异，不做任何修改。
+func (c *Client) PlanApply(ctx context.Context, req ApplyRequest) (ApplyPlan, error) {
+	var resp ApplyPlan
+	err := c.do(ctx, http.MethodPost, "/apply", url.Values{"dry_run": {"true"}}, req, &resp)
+	return resp, err
+}
+
+// Apply 执行期望状态；建议先调用 PlanApply 并将返回的指纹填入 req.Fingerprint。
+func (c *Client) Apply(ctx context.Context, req ApplyRequest) (ApplyPlan, error) {
+	var resp ApplyPlan
+	err := c.do(ctx, http.MethodPost, "/apply", nil, req, &resp)
+	return resp, err
+}
+
+// ListAdminUsers 列出管理员账号，需要 owner 角色。
+func (c *Client) ListAdminUsers(ctx context.Context) ([]AdminUser, error) {
+	var resp struct {
+		Items []AdminUser `json:"items"`
+	}
+	err := c.do(ctx, http.MethodGet, "/admin-users", nil, nil, &resp)
+	return resp.Items, err
+}
+
+// CreateAdminUser 创建管理员账号，需要 owner 角色。
+func (c *Client) CreateAdminUser(ctx context.Context, req CreateAdminUserRequest) (AdminUser, error) {
+	var resp AdminUser
+	err := c.do(ctx, http.MethodPost, "/admin-users", nil, req, &resp)
+	return resp, err
+}
+
+// PatchAdminUser 修改管理员的密码、角色或停用状态，需要 owner 角色。
+func (c *Client) PatchAdminUser(ctx context.Context, id string, req PatchAdminUserRequest) (AdminUser, error) {
+	var resp AdminUser
+	err := c.do(ctx, http.MethodPatch, "/admin-users/"+url.PathEscape(id), nil, req, &resp)
+	return resp, err
+}
+
+// DeleteAdminUser 删除管理员账号，需要 owner 角色。
+func (c *Client) DeleteAdminUser(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/admin-users/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// ListServiceTokens 列出服务令牌，不含令牌明文。
+func (c *Client) ListServiceTokens(ctx context.Context) ([]ServiceToken, error) {
+	var resp struct {
+		Items []ServiceToken `json:"items"`
+	}
+	err := c.do(ctx, http.MethodGet, "/service-tokens", nil, nil, &resp)
+	return resp.Items, err
+}
+
+// CreateServiceToken 创建服务令牌，返回值中的 Token 为明文且仅此一次可见，可直接用于 WithToken。
+func (c *Client) CreateServiceToken(ctx context.Context, req CreateServiceTokenRequest) (CreatedServiceToken, error) {
+	var resp CreatedServiceToken
+	err := c.do(ctx, http.MethodPost, "/service-tokens", nil, req, &resp)
+	return resp, err
+}
+
+// RevokeServiceToken 吊销服务令牌。
+func (c *Client) RevokeServiceToken(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/service-tokens/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// GetRequestTrace 按请求 ID（代理响应头 X-Request-ID）查询代理请求的决策轨迹。
+func (c *Client) GetRequestTrace(ctx context.Context, requestID string) (RequestTrace, error) {
+	var resp RequestTrace
+	err := c.do(ctx, http.MethodGet, "/requests/"+url.PathEscape(requestID), nil, nil, &resp)
+	return resp, err
+}
+
+// ReplayRequest 以原请求的身份按当前规则重新执行请求，返回原结果与新结果。重放会真实请求上游。
+func (c *Client) ReplayRequest(ctx context.Context, requestID string, req ReplayRequest) (ReplayResult, error) {
+	var resp ReplayResult
+	err := c.do(ctx, http.MethodPost, "/requests/"+url.PathEscape(requestID)+"/replay", nil, req, &resp)
+	return resp, err
+}
+
+// Backup 导出规则与账户数据。passphrase 非空时上游密钥以该口令加密写入备份，否则备份不含上游明文密钥。
+func (c *Client) Backup(ctx context.Context, passphrase string) (Backup, error) {
+	var resp Backup
+	req, err := c.newRequest(ctx, http.MethodGet, "/backup", nil, nil)
+	if err != nil {
+		return resp, err
+	}
+	setPassphrase(req, passphrase)
+	err = c.send(req, &resp)
+	return resp, err
+}
+
+// Restore 按 ID 恢复备份，备份已加密时需提供导出时使用的口令。
+func (c *Client) Restore(ctx context.Context, backup Backup, passphrase string) (RestoreResult, error) {
+	var resp RestoreResult
+	req, err := c.newRequest(ctx, http.MethodPost, "/restore", nil, backup)
+	if err != nil {
+		return resp, err
+	}
+	setPassphrase(req, passphrase)
+	err = c.send(req, &resp)
+	return resp, err
+}
+
+// setPassphrase 通过请求头传递备份口令，避免口令出现在 URL 中。
+func setPassphrase(req *http.Request, passphrase string) {
+	if passphrase != "" {
+		req.Header.Set("X-Backup-Passphrase", passphrase)
+	}
+}
+
+// GetConfig 返回网关生效配置（敏感项已脱敏）与各组件实际使用的后端。
+func (c *Client) GetConfig(ctx context.Context) (RuntimeConfig, error) {
+	var resp RuntimeConfig
+	err := c.do(ctx, http.MethodGet, "/config", nil, nil, &resp)
+	return resp, err
+}
+
+// GetLogLevel 返回所连接实例当前的日志级别。
+func (c *Client) GetLogLevel(ctx context.Context) (string, error) {
+	var resp LogLevel
+	err := c.do(ctx, http.MethodGet, "/loglevel", nil, nil, &resp)
+	return resp.Level, err
+}
+
+// SetLogLevel 调整所连接实例的日志级别（debug、info、warn、error），返回调整后的级别。
+func (c *Client) SetLogLevel(ctx context.Context, level string) (string, error) {
+	var resp LogLevel
+	err := c.do(ctx, http.MethodPut, "/loglevel", nil, LogLevel{Level: level}, &resp)
+	return resp.Level, err
+}
+
+// ListFeatureFlags 列出所连接实例的功能开关。
+func (c *Client) ListFeatureFlags(ctx context.Context) ([]FeatureFlag, error) {
+	var resp FeatureFlagList
+	err := c.do(ctx, http.MethodGet, "/features", nil, nil, &resp)
+	return resp.Items, err
+}
+
+// SetFeatureFlag 开启或关闭所连接实例的功能开关。
+func (c *Client) SetFeatureFlag(ctx context.Context, name string, enabled bool) (FeatureFlag, error) {
+	var resp FeatureFlag
+	body := map[string]bool{"enabled": enabled}
+	err := c.do(ctx, http.MethodPut, "/features/"+url.PathEscape(name), nil, body, &resp)
+	return resp, err
+}
+
+// ListAuditLogs 按条件查询管理端变更记录，按时间倒序返回。
+func (c *Client) ListAuditLogs(ctx context.Context, filter AuditLogFilter) (AuditLogList, error) {
+	query := ListOptions{Limit: filter.Limit, Offset: filter.Offset}.values()
+	for key, value := range map[string]string{
+		"actor":         filter.Actor,
+		"action":        filter.Action,
+		"resource_type": filter.ResourceType,
+		"resource_id":   filter.ResourceID,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if !filter.Since.IsZero() {
+		query.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	if !filter.Until.IsZero() {
+		query.Set("until", filter.Until.Format(time.RFC3339))
+	}
+	var resp AuditLogList
+	err := c.do(ctx, http.MethodGet, "/audit-logs", query, nil, &resp)
+	return resp, err
+}
+
+// StreamEvents 订阅 GET /events，对每条变更事件调用 fn，直到 ctx 取消、连接断开或 fn 返回错误。
+func (c *Client) StreamEvents(ctx context.Context, fn func(ChangeEvent) error) error {
+	req, err := c.newRequest(ctx, http.MethodGet, "/events", nil, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return decodeAPIError(resp)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var evt ChangeEvent
+		if err := json.Unmarshal([]byte(data), &evt); err != nil {
+			return fmt.Errorf("decode event: %w", err)
+		}
+		if err := fn(evt); err != nil {
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return scanner.Err()
+}
+
+func (o ListOptions) values() url.Values {
+	query := url.Values{}
+	setPositive(query, "limit", o.Limit)
+	setPositive(query, "offset", o.Offset)
+	if o.Search != "" {
+		query.Set("q", o.Search)
+	}
+	return query
+}
+
+func setPositive(query url.Values, key string, value int) {
+	if value > 0 {
+		query.Set(key, strconv.Itoa(value))
+	}
+}
+
+// do 发送请求并将 2xx 响应的 data 字段解码到 out；非 2xx 响应转换为 *APIError。
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	req, err := c.newRequest(ctx, method, path, query, in)
+	if err != nil {
+		return err
+	}
+	return c.send(req, out)
+}
+
+// send 发送请求并将 {data} 信封中的数据解码到 out。
+func (c *Client) send(req *http.Request, out any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return decodeAPIError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	envelope := struct {
+		Data any `json:"data"`
+	}{Data: out}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, query url.Values, in any) (*http.Request, error) {
+	target := c.baseURL + basePath + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("encode request: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+// decodeAPIError 将错误响应体中的 {"error": {"code", "message"}} 转换为 *APIError。
+func decodeAPIError(resp *http.Response) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	var payload struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(raw, &payload) == nil && payload.Error.Message != "" {
+		apiErr.Code = payload.Error.Code
+		apiErr.Message = payload.Error.Message
+	} else {
+		apiErr.Message = strings.TrimSpace(string(raw))
+	}
+	return apiErr
+}
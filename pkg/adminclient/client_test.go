@@ -0,0 +1,460 @@
+package adminclient
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/prehisle/yapi/internal/admin"
+	"github.com/prehisle/yapi/internal/adminusers"
+	"github.com/prehisle/yapi/internal/audit"
+	"github.com/prehisle/yapi/internal/features"
+	"github.com/prehisle/yapi/internal/proxy"
+	"github.com/prehisle/yapi/internal/reqtrace"
+	"github.com/prehisle/yapi/internal/servicetokens"
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+func newAdminServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	auth := admin.NewAuthenticator("admin", "secret", "signing-key", time.Hour)
+	bus := rules.NewMemoryEventBus()
+	ruleService := rules.NewService(rules.NewMemoryStore(), rules.WithEventBus(bus))
+	handler := admin.NewHandler(admin.NewService(ruleService, nil), auth, admin.WithAuditStore(audit.NewMemoryStore()), admin.WithEventBus(bus), admin.WithRequireIfMatch())
+	router := gin.New()
+	group := router.Group(admin.V1Prefix)
+	group.Use(admin.Envelope())
+	admin.Mount(group, handler, auth.Middleware())
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_RuleLifecycle(t *testing.T) {
+	server := newAdminServer(t)
+	ctx := context.Background()
+
+	anonymous := New(server.URL)
+	require.NoError(t, anonymous.Healthz(ctx))
+	_, err := anonymous.ListRules(ctx, ListRulesOptions{})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	require.Equal(t, "YAPI_UNAUTHORIZED", apiErr.Code)
+
+	login, err := anonymous.Login(ctx, "admin", "secret")
+	require.NoError(t, err)
+	require.Equal(t, "Bearer", login.TokenType)
+	client := New(server.URL+"/", WithToken(login.AccessToken))
+
+	_, err = client.CreateRule(ctx, rules.Rule{
+		ID:      "client-rule",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: "https://example.com"},
+	})
+	require.NoError(t, err)
+
+	detail, err := client.DisableRule(ctx, "client-rule")
+	require.NoError(t, err)
+	require.False(t, detail.Enabled)
+
+	stale := detail.ETag
+	detail, err = client.PatchRule(ctx, "client-rule", map[string]any{"priority": 7})
+	require.NoError(t, err)
+	require.Equal(t, 7, detail.Priority)
+	require.NotEqual(t, stale, detail.ETag)
+
+	_, err = client.UpdateRuleIfMatch(ctx, "client-rule", stale, detail.Rule)
+	require.True(t, IsPreconditionFailed(err))
+	require.True(t, IsPreconditionFailed(client.DeleteRuleIfMatch(ctx, "client-rule", stale)))
+	_, err = client.UpdateRuleIfMatch(ctx, "client-rule", detail.ETag, detail.Rule)
+	require.NoError(t, err)
+
+	enabled := false
+	list, err := client.ListRules(ctx, ListRulesOptions{Enabled: &enabled})
+	require.NoError(t, err)
+	require.Equal(t, 1, list.Total)
+	diff, err := client.DiffRules(ctx, RulesDiffRequest{Candidate: []rules.Rule{{
+		ID:       "client-rule",
+		Priority: 8,
+		Matcher:  rules.Matcher{PathPrefix: "/v1"},
+		Actions:  rules.Actions{SetTargetURL: "https://example.com"},
+	}}})
+	require.NoError(t, err)
+	require.Len(t, diff.Changed, 1)
+	require.Equal(t, AuditChange{Before: float64(7), After: float64(8)}, diff.Changed[0].Fields["priority"])
+
+	explanation, err := client.ExplainRule(ctx, "client-rule", RuleExplainRequest{Method: "POST", Path: "/v1/chat/completions"})
+	require.NoError(t, err)
+	require.True(t, explanation.Matched)
+	require.False(t, explanation.Fires)
+	require.Equal(t, []rules.ConditionResult{{Condition: "path_prefix", Expected: "/v1", Actual: "/v1/chat/completions", Matched: true}}, explanation.Conditions)
+
+	list, err = client.ListRules(ctx, ListRulesOptions{Sort: "updated_at", Order: "asc"})
+	require.NoError(t, err)
+	require.Equal(t, "client-rule", list.Items[0].ID)
+	current, err := client.GetRule(ctx, "client-rule")
+	require.NoError(t, err)
+	require.Equal(t, current.ETag, list.Items[0].ETag)
+	_, err = client.ListRules(ctx, ListRulesOptions{Sort: "name"})
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+
+	logs, err := client.ListAuditLogs(ctx, AuditLogFilter{Action: "rules.disable", Since: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, logs.Total)
+	require.Equal(t, "admin", logs.Items[0].Actor)
+	require.Equal(t, AuditChange{Before: true, After: false}, logs.Items[0].Diff["enabled"])
+
+	require.NoError(t, client.DeleteRule(ctx, "client-rule"))
+
+	refreshed, err := anonymous.RefreshToken(ctx, login.RefreshToken)
+	require.NoError(t, err)
+	client = New(server.URL, WithToken(refreshed.AccessToken))
+	require.NoError(t, client.Logout(ctx, refreshed.RefreshToken))
+	_, err = client.ListRules(ctx, ListRulesOptions{})
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	client = New(server.URL, WithToken(login.AccessToken))
+	_, err = client.GetRule(ctx, "client-rule")
+	require.True(t, IsNotFound(err))
+
+	_, err = client.ListUsers(ctx, ListOptions{})
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusNotImplemented, apiErr.StatusCode)
+}
+
+func TestClient_StreamEvents(t *testing.T) {
+	server := newAdminServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	login, err := New(server.URL).Login(ctx, "admin", "secret")
+	require.NoError(t, err)
+	client := New(server.URL, WithToken(login.AccessToken))
+
+	received := make(chan ChangeEvent, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.StreamEvents(ctx, func(evt ChangeEvent) error {
+			received <- evt
+			return errStop
+		})
+	}()
+
+	// 订阅建立前发布的事件会丢失，因此反复触发变更直到收到事件。
+	rule := rules.Rule{ID: "evt-rule", Enabled: true, Matcher: rules.Matcher{PathPrefix: "/v1"}, Actions: rules.Actions{SetTargetURL: "https://example.com"}}
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case evt := <-received:
+			require.Equal(t, "rules_changed", evt.Type)
+			require.ErrorIs(t, <-done, errStop)
+			return
+		case <-ticker.C:
+			_, err := client.CreateRule(ctx, rule)
+			require.NoError(t, err)
+		case <-deadline:
+			t.Fatal("no event received")
+		}
+	}
+}
+
+func TestClient_ApplyRules(t *testing.T) {
+	server := newAdminServer(t)
+	ctx := context.Background()
+	login, err := New(server.URL).Login(ctx, "admin", "secret")
+	require.NoError(t, err)
+	client := New(server.URL, WithToken(login.AccessToken))
+
+	req := ApplyRequest{Rules: []rules.Rule{{
+		ID:      "applied",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: "https://example.com"},
+	}}}
+	plan, err := client.PlanApply(ctx, req)
+	require.NoError(t, err)
+	require.False(t, plan.Applied)
+	require.Len(t, plan.Changes, 1)
+	require.Equal(t, "create", plan.Changes[0].Action)
+
+	req.Fingerprint = plan.Fingerprint
+	applied, err := client.Apply(ctx, req)
+	require.NoError(t, err)
+	require.True(t, applied.Applied)
+	detail, err := client.GetRule(ctx, "applied")
+	require.NoError(t, err)
+	require.Equal(t, "admin", detail.UpdatedBy)
+
+	_, err = client.Apply(ctx, req)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusConflict, apiErr.StatusCode)
+}
+
+var errStop = errors.New("stop")
+
+func TestClient_AdminUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open("file:adminclient_admin_users?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	users := adminusers.NewService(db)
+	require.NoError(t, users.AutoMigrate(ctx))
+	auth := admin.NewAuthenticator("admin", "secret", "signing-key", time.Hour, admin.WithUserStore(users))
+	router := gin.New()
+	group := router.Group(admin.V1Prefix)
+	group.Use(admin.Envelope())
+	admin.Mount(group, admin.NewHandler(admin.NewService(rules.NewService(rules.NewMemoryStore()), nil), auth, admin.WithAdminUsers(users)), auth.Middleware())
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	login, err := New(server.URL).Login(ctx, "admin", "secret")
+	require.NoError(t, err)
+	owner := New(server.URL, WithToken(login.AccessToken))
+	created, err := owner.CreateAdminUser(ctx, CreateAdminUserRequest{Username: "dashboard", Password: "dashboard-pass", Role: "viewer"})
+	require.NoError(t, err)
+	require.Equal(t, "viewer", created.Role)
+
+	viewerLogin, err := New(server.URL).Login(ctx, "dashboard", "dashboard-pass")
+	require.NoError(t, err)
+	viewer := New(server.URL, WithToken(viewerLogin.AccessToken))
+	_, err = viewer.ListRules(ctx, ListRulesOptions{})
+	require.NoError(t, err)
+	_, err = viewer.ListAdminUsers(ctx)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+
+	scoped, err := New(server.URL).Login(ctx, "admin", "secret", "rules:read")
+	require.NoError(t, err)
+	require.Equal(t, []string{"rules:read"}, scoped.Permissions)
+	_, err = New(server.URL, WithToken(scoped.AccessToken)).ListAdminUsers(ctx)
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+
+	role := "editor"
+	updated, err := owner.PatchAdminUser(ctx, created.ID, PatchAdminUserRequest{Role: &role})
+	require.NoError(t, err)
+	require.Equal(t, "editor", updated.Role)
+	list, err := owner.ListAdminUsers(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.NoError(t, owner.DeleteAdminUser(ctx, created.ID))
+	_, err = viewer.ListRules(ctx, ListRulesOptions{})
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
+func TestClient_ServiceTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open("file:adminclient_service_tokens?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	tokens := servicetokens.NewService(db)
+	require.NoError(t, tokens.AutoMigrate(ctx))
+	auth := admin.NewAuthenticator("admin", "secret", "signing-key", time.Hour, admin.WithServiceTokenStore(tokens))
+	router := gin.New()
+	group := router.Group(admin.V1Prefix)
+	group.Use(admin.Envelope())
+	admin.Mount(group, admin.NewHandler(admin.NewService(rules.NewService(rules.NewMemoryStore()), nil), auth, admin.WithServiceTokens(tokens)), auth.Middleware())
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	login, err := New(server.URL).Login(ctx, "admin", "secret")
+	require.NoError(t, err)
+	owner := New(server.URL, WithToken(login.AccessToken))
+	created, err := owner.CreateServiceToken(ctx, CreateServiceTokenRequest{Name: "ci", Permissions: []string{"rules:read"}})
+	require.NoError(t, err)
+	require.Equal(t, "admin", created.CreatedBy)
+
+	ci := New(server.URL, WithToken(created.Token))
+	_, err = ci.ListRules(ctx, ListRulesOptions{})
+	require.NoError(t, err)
+	_, err = ci.ListServiceTokens(ctx)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+
+	list, err := owner.ListServiceTokens(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.NoError(t, owner.RevokeServiceToken(ctx, created.ID))
+	_, err = ci.ListRules(ctx, ListRulesOptions{})
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
+func TestClient_GetRequestTrace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	traces := reqtrace.NewMemoryStore(10)
+	require.NoError(t, traces.Save(ctx, reqtrace.Trace{
+		RequestID: "req-1",
+		Rule:      &reqtrace.RuleMatch{ID: "chat", Priority: 10},
+		Body:      []reqtrace.BodyMutation{{Op: "override", Path: "model", Value: "gpt-4.1"}},
+		Attempts:  []reqtrace.Attempt{{Target: "https://api.example.com", Status: http.StatusOK, LatencyMs: 12}},
+		Status:    http.StatusOK,
+	}))
+	auth := admin.NewAuthenticator("admin", "secret", "signing-key", time.Hour)
+	router := gin.New()
+	group := router.Group(admin.V1Prefix)
+	group.Use(admin.Envelope())
+	admin.Mount(group, admin.NewHandler(admin.NewService(rules.NewService(rules.NewMemoryStore()), nil), auth, admin.WithTraceStore(traces)), auth.Middleware())
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	login, err := New(server.URL).Login(ctx, "admin", "secret")
+	require.NoError(t, err)
+	client := New(server.URL, WithToken(login.AccessToken))
+	trace, err := client.GetRequestTrace(ctx, "req-1")
+	require.NoError(t, err)
+	require.Equal(t, "chat", trace.Rule.ID)
+	require.JSONEq(t, `"gpt-4.1"`, string(trace.BodyMutations[0].Value))
+	require.Equal(t, int64(12), trace.Attempts[0].LatencyMs)
+
+	_, err = client.GetRequestTrace(ctx, "missing")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+type replayerStub struct{}
+
+func (replayerStub) Replay(ctx context.Context, req proxy.ReplayRequest) (proxy.ReplayResult, error) {
+	return proxy.ReplayResult{RequestID: "req-2", Status: http.StatusOK, Body: req.Body,
+		Trace: &reqtrace.Trace{Rule: &reqtrace.RuleMatch{ID: "chat", Priority: 10, Version: 2}}}, nil
+}
+
+func TestClient_ReplayRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	traces := reqtrace.NewMemoryStore(10)
+	require.NoError(t, traces.Save(ctx, reqtrace.Trace{
+		RequestID: "req-1",
+		Method:    http.MethodPost,
+		Path:      "/v1/chat/completions",
+		Rule:      &reqtrace.RuleMatch{ID: "chat", Priority: 10, Version: 1},
+		Status:    http.StatusOK,
+	}))
+	auth := admin.NewAuthenticator("admin", "secret", "signing-key", time.Hour)
+	router := gin.New()
+	group := router.Group(admin.V1Prefix)
+	group.Use(admin.Envelope())
+	admin.Mount(group, admin.NewHandler(admin.NewService(rules.NewService(rules.NewMemoryStore()), nil), auth,
+		admin.WithTraceStore(traces), admin.WithRequestReplayer(replayerStub{})), auth.Middleware())
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	login, err := New(server.URL).Login(ctx, "admin", "secret")
+	require.NoError(t, err)
+	client := New(server.URL, WithToken(login.AccessToken))
+	body := `{"model":"gpt-4o"}`
+	result, err := client.ReplayRequest(ctx, "req-1", ReplayRequest{Body: &body})
+	require.NoError(t, err)
+	require.Equal(t, "request", result.BodySource)
+	require.Equal(t, "req-2", result.Replay.RequestID)
+	require.Equal(t, body, result.Replay.Response)
+	require.False(t, result.StatusChanged)
+	require.True(t, result.RuleChanged)
+}
+
+func TestClient_BackupRestore(t *testing.T) {
+	ctx := context.Background()
+	login := func(server *httptest.Server) *Client {
+		resp, err := New(server.URL).Login(ctx, "admin", "secret")
+		require.NoError(t, err)
+		return New(server.URL, WithToken(resp.AccessToken))
+	}
+	source := login(newAdminServer(t))
+	_, err := source.CreateRule(ctx, rules.Rule{ID: "backed-up", Priority: 5, Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"}, Actions: rules.Actions{SetTargetURL: "https://api.example.com"}})
+	require.NoError(t, err)
+
+	backup, err := source.Backup(ctx, "passphrase")
+	require.NoError(t, err)
+	require.Equal(t, 1, backup.Version)
+	require.Len(t, backup.Rules, 1)
+	require.Empty(t, backup.Users)
+
+	target := login(newAdminServer(t))
+	result, err := target.Restore(ctx, backup, "passphrase")
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Rules)
+	detail, err := target.GetRule(ctx, "backed-up")
+	require.NoError(t, err)
+	require.Equal(t, "https://api.example.com", detail.Actions.SetTargetURL)
+}
+
+func TestClient_GetConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	auth := admin.NewAuthenticator("admin", "secret", "signing-key", time.Hour)
+	router := gin.New()
+	group := router.Group(admin.V1Prefix)
+	group.Use(admin.Envelope())
+	admin.Mount(group, admin.NewHandler(admin.NewService(rules.NewService(rules.NewMemoryStore()), nil), auth, admin.WithRuntimeConfig(admin.RuntimeConfig{
+		Settings: map[string]string{"REDIS_ADDR": "redis:6379"},
+		Backends: map[string]string{"event_bus": "memory"},
+		Warnings: []string{"redis unavailable"},
+	})), auth.Middleware())
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	login, err := New(server.URL).Login(ctx, "admin", "secret")
+	require.NoError(t, err)
+	cfg, err := New(server.URL, WithToken(login.AccessToken)).GetConfig(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "redis:6379", cfg.Settings["REDIS_ADDR"])
+	require.Equal(t, "memory", cfg.Backends["event_bus"])
+	require.Equal(t, []string{"redis unavailable"}, cfg.Warnings)
+}
+
+func TestClient_RuntimeControls(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	registry := features.NewRegistry()
+	registry.Register(features.RequestTraces, "record request traces", true)
+	auth := admin.NewAuthenticator("admin", "secret", "signing-key", time.Hour)
+	router := gin.New()
+	group := router.Group(admin.V1Prefix)
+	group.Use(admin.Envelope())
+	admin.Mount(group, admin.NewHandler(admin.NewService(rules.NewService(rules.NewMemoryStore()), nil), auth,
+		admin.WithLogLevel(new(slog.LevelVar)), admin.WithFeatureFlags(registry)), auth.Middleware())
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	login, err := New(server.URL).Login(ctx, "admin", "secret")
+	require.NoError(t, err)
+	client := New(server.URL, WithToken(login.AccessToken))
+
+	level, err := client.SetLogLevel(ctx, "warn")
+	require.NoError(t, err)
+	require.Equal(t, "warn", level)
+	level, err = client.GetLogLevel(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "warn", level)
+
+	flag, err := client.SetFeatureFlag(ctx, features.RequestTraces, false)
+	require.NoError(t, err)
+	require.False(t, flag.Enabled)
+	flags, err := client.ListFeatureFlags(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []FeatureFlag{{Name: features.RequestTraces, Description: "record request traces", Default: true}}, flags)
+	_, err = client.SetFeatureFlag(ctx, "missing", true)
+	require.True(t, IsNotFound(err))
+}
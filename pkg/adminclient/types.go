@@ -0,0 +1,710 @@
+package adminclient
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+// 以下类型与 internal/admin/openapi.json 中的 components.schemas 一一对应。
+
+// LoginResponse 对应 LoginResponse。
+type LoginResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	// RefreshToken 仅可使用一次，刷新后须改用响应中的新令牌。
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int    `json:"refresh_expires_in"`
+	// Permissions 是令牌的有效权限。
+	Permissions []string `json:"permissions"`
+}
+
+// ListOptions 为账户类列表的分页与搜索参数，零值表示使用服务端默认值。
+type ListOptions struct {
+	Limit  int
+	Offset int
+	Search string
+}
+
+// ListRulesOptions 为规则列表的分页与过滤参数，零值表示使用服务端默认值。
+type ListRulesOptions struct {
+	Page     int
+	PageSize int
+	Search   string
+	Enabled  *bool
+	// Sort 为 priority、updated_at 或 id，Order 为 asc 或 desc。
+	Sort  string
+	Order string
+}
+
+// RuleList 对应 RuleList。
+type RuleList struct {
+	Items        []RuleListItem `json:"items"`
+	Total        int            `json:"total"`
+	EnabledTotal int            `json:"enabled_total"`
+	Page         int            `json:"page"`
+	PageSize     int            `json:"page_size"`
+}
+
+// RuleListItem 为列表中的规则及其 ETag。
+type RuleListItem struct {
+	rules.Rule
+	ETag string `json:"etag"`
+}
+
+// RuleDetail 对应 RuleDetail。ETag 用于 UpdateRuleIfMatch / DeleteRuleIfMatch。
+type RuleDetail struct {
+	rules.Rule
+	ETag         string           `json:"etag"`
+	Stats        RuleStats        `json:"stats"`
+	LastModified RuleLastModified `json:"last_modified"`
+}
+
+// RuleStats 为规则在所查询实例上的命中统计。
+type RuleStats struct {
+	Matches       uint64     `json:"matches"`
+	LastMatchedAt *time.Time `json:"last_matched_at,omitempty"`
+}
+
+// RuleLastModified 描述规则最近一次修改。
+type RuleLastModified struct {
+	At      *time.Time `json:"at,omitempty"`
+	By      string     `json:"by,omitempty"`
+	Version int        `json:"version"`
+}
+
+// RateLimits 对应 RateLimits，0 表示不限制。
+type RateLimits struct {
+	MaxRequestsPerMinute  int `json:"max_requests_per_minute"`
+	MaxConcurrentRequests int `json:"max_concurrent_requests"`
+}
+
+// PatchRateLimitsRequest 对应 PatchRateLimitsRequest，nil 字段保持不变。
+type PatchRateLimitsRequest struct {
+	MaxRequestsPerMinute  *int `json:"max_requests_per_minute,omitempty"`
+	MaxConcurrentRequests *int `json:"max_concurrent_requests,omitempty"`
+}
+
+// ModelAccess 对应模型白名单与黑名单，条目以 * 结尾时按前缀匹配，空白名单表示不限制。
+type ModelAccess struct {
+	AllowedModels []string `json:"allowed_models,omitempty"`
+	DeniedModels  []string `json:"denied_models,omitempty"`
+}
+
+// PatchModelAccessRequest 对应 patchModelAccessRequest，nil 字段保持不变，空切片表示清空。
+type PatchModelAccessRequest struct {
+	AllowedModels *[]string `json:"allowed_models,omitempty"`
+	DeniedModels  *[]string `json:"denied_models,omitempty"`
+}
+
+// PatchUserRequest 对应 patchUserRequest，nil 字段保持不变。
+type PatchUserRequest struct {
+	PatchRateLimitsRequest
+	PatchModelAccessRequest
+}
+
+// User 对应 User。
+type User struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Metadata    map[string]any `json:"metadata,omitempty"`
+	RateLimits
+	ModelAccess
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UserList 对应 UserList。
+type UserList struct {
+	Items  []User `json:"items"`
+	Total  int64  `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+// CreateUserRequest 对应 CreateUserRequest。
+type CreateUserRequest struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Metadata    map[string]any `json:"metadata,omitempty"`
+	RateLimits
+	ModelAccess
+}
+
+// ResourceSummary 为用户详情中某类资源的计数与列表。
+type ResourceSummary[T any] struct {
+	Total   int `json:"total"`
+	Enabled int `json:"enabled"`
+	Items   []T `json:"items"`
+}
+
+// BindingSummary 对应 BindingSummary。
+type BindingSummary struct {
+	ID                   string `json:"id"`
+	UserAPIKeyID         string `json:"user_api_key_id"`
+	UpstreamCredentialID string `json:"upstream_credential_id"`
+	Service              string `json:"service"`
+	Position             int    `json:"position"`
+	UpstreamEnabled      bool   `json:"upstream_enabled"`
+}
+
+// UserDetail 对应 UserDetail。
+type UserDetail struct {
+	User
+	APIKeys   ResourceSummary[APIKey]             `json:"api_keys"`
+	Upstreams ResourceSummary[UpstreamCredential] `json:"upstreams"`
+	Bindings  ResourceSummary[BindingSummary]     `json:"bindings"`
+}
+
+// APIKey 对应 APIKey。
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Label      string     `json:"label"`
+	Prefix     string     `json:"prefix"`
+	Enabled    bool       `json:"enabled"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RateLimits
+	ModelAccess
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// APIKeyList 对应 APIKeyList。
+type APIKeyList struct {
+	Items  []APIKey `json:"items"`
+	Total  int64    `json:"total"`
+	Limit  int      `json:"limit"`
+	Offset int      `json:"offset"`
+}
+
+// CreateAPIKeyRequest 对应 CreateAPIKeyRequest。
+type CreateAPIKeyRequest struct {
+	Label string `json:"label,omitempty"`
+	RateLimits
+	ModelAccess
+}
+
+// CreatedAPIKey 对应 CreatedAPIKey，Secret 为仅返回一次的明文。
+type CreatedAPIKey struct {
+	APIKey APIKey `json:"api_key"`
+	Secret string `json:"secret"`
+}
+
+// PatchAPIKeyRequest 对应 PatchAPIKeyRequest，nil 字段保持不变。
+type PatchAPIKeyRequest struct {
+	Enabled *bool `json:"enabled,omitempty"`
+	PatchRateLimitsRequest
+	PatchModelAccessRequest
+}
+
+// UpstreamHealth 对应 UpstreamHealth。
+type UpstreamHealth struct {
+	Status     string     `json:"status"`
+	Detail     string     `json:"detail,omitempty"`
+	HTTPStatus int        `json:"http_status,omitempty"`
+	CheckedAt  *time.Time `json:"checked_at,omitempty"`
+}
+
+// UpstreamCredential 对应 UpstreamCredential。
+type UpstreamCredential struct {
+	ID        string          `json:"id"`
+	UserID    string          `json:"user_id"`
+	Provider  string          `json:"provider"`
+	Service   string          `json:"service"`
+	Label     string          `json:"label"`
+	Name      string          `json:"name"`
+	SecretRef string          `json:"secret_ref,omitempty"`
+	PoolID    string          `json:"pool_id,omitempty"`
+	Enabled   bool            `json:"enabled"`
+	IsDefault bool            `json:"is_default"`
+	Endpoints []string        `json:"endpoints,omitempty"`
+	Metadata  map[string]any  `json:"metadata,omitempty"`
+	Health    *UpstreamHealth `json:"health,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// UpstreamCredentialList 对应 UpstreamCredentialList。
+type UpstreamCredentialList struct {
+	Items  []UpstreamCredential `json:"items"`
+	Total  int64                `json:"total"`
+	Limit  int                  `json:"limit"`
+	Offset int                  `json:"offset"`
+}
+
+// CreateUpstreamCredentialRequest 对应 CreateUpstreamCredentialRequest。
+type CreateUpstreamCredentialRequest struct {
+	Provider  string         `json:"provider"`
+	Service   string         `json:"service,omitempty"`
+	Label     string         `json:"label,omitempty"`
+	Name      string         `json:"name,omitempty"`
+	Plaintext string         `json:"plaintext,omitempty"`
+	SecretRef string         `json:"secret_ref,omitempty"`
+	Endpoints []string       `json:"endpoints,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+// UpdateUpstreamCredentialRequest 对应 UpdateUpstreamCredentialRequest，nil 字段保持不变。
+type UpdateUpstreamCredentialRequest struct {
+	UserID    *string        `json:"user_id,omitempty"`
+	Provider  *string        `json:"provider,omitempty"`
+	Service   *string        `json:"service,omitempty"`
+	Label     *string        `json:"label,omitempty"`
+	Name      *string        `json:"name,omitempty"`
+	Plaintext *string        `json:"plaintext,omitempty"`
+	SecretRef *string        `json:"secret_ref,omitempty"`
+	Endpoints *[]string      `json:"endpoints,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	Enabled   *bool          `json:"enabled,omitempty"`
+}
+
+// PatchUpstreamCredentialRequest 对应 PatchUpstreamCredentialRequest，nil 字段保持不变。
+type PatchUpstreamCredentialRequest struct {
+	Enabled   *bool `json:"enabled,omitempty"`
+	IsDefault *bool `json:"is_default,omitempty"`
+}
+
+// UpstreamKeyPool 对应 UpstreamKeyPool。
+type UpstreamKeyPool struct {
+	ID            string         `json:"id"`
+	UserID        string         `json:"user_id"`
+	Name          string         `json:"name"`
+	Provider      string         `json:"provider"`
+	Strategy      string         `json:"strategy"`
+	CredentialIDs []string       `json:"credential_ids"`
+	Metadata      map[string]any `json:"metadata,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// CreateUpstreamKeyPoolRequest 对应 CreateUpstreamKeyPoolRequest。
+type CreateUpstreamKeyPoolRequest struct {
+	Name          string         `json:"name"`
+	Provider      string         `json:"provider"`
+	Strategy      string         `json:"strategy,omitempty"`
+	CredentialIDs []string       `json:"credential_ids,omitempty"`
+	Metadata      map[string]any `json:"metadata,omitempty"`
+}
+
+// UpdateUpstreamKeyPoolRequest 对应 UpdateUpstreamKeyPoolRequest，nil 字段保持不变。
+type UpdateUpstreamKeyPoolRequest struct {
+	Name          *string        `json:"name,omitempty"`
+	Strategy      *string        `json:"strategy,omitempty"`
+	CredentialIDs *[]string      `json:"credential_ids,omitempty"`
+	Metadata      map[string]any `json:"metadata,omitempty"`
+}
+
+// BindAPIKeyRequest 对应 BindAPIKeyRequest。
+type BindAPIKeyRequest struct {
+	UserID               string         `json:"user_id"`
+	UpstreamCredentialID string         `json:"upstream_credential_id"`
+	Service              string         `json:"service,omitempty"`
+	Position             int            `json:"position"`
+	Metadata             map[string]any `json:"metadata,omitempty"`
+}
+
+// APIKeyBinding 对应 APIKeyBinding。
+type APIKeyBinding struct {
+	ID                   string             `json:"id"`
+	UserID               string             `json:"user_id"`
+	UserAPIKeyID         string             `json:"user_api_key_id"`
+	UpstreamCredentialID string             `json:"upstream_credential_id"`
+	Service              string             `json:"service"`
+	Position             int                `json:"position"`
+	Metadata             map[string]any     `json:"metadata,omitempty"`
+	CreatedAt            time.Time          `json:"created_at"`
+	UpdatedAt            time.Time          `json:"updated_at"`
+	Upstream             UpstreamCredential `json:"upstream"`
+}
+
+// AuditLogFilter 为审计日志查询条件，零值字段表示不过滤。
+type AuditLogFilter struct {
+	Actor        string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Since        time.Time
+	Until        time.Time
+	Limit        int
+	Offset       int
+}
+
+// AuditLogEntry 对应 AuditLogEntry。
+type AuditLogEntry struct {
+	ID           string                 `json:"id"`
+	Actor        string                 `json:"actor"`
+	Action       string                 `json:"action"`
+	ResourceType string                 `json:"resource_type"`
+	ResourceID   string                 `json:"resource_id"`
+	RequestID    string                 `json:"request_id,omitempty"`
+	Before       json.RawMessage        `json:"before,omitempty"`
+	After        json.RawMessage        `json:"after,omitempty"`
+	Diff         map[string]AuditChange `json:"diff,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+// AuditChange 描述单个字段在变更前后的取值。
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditLogList 对应 AuditLogList。
+type AuditLogList struct {
+	Items  []AuditLogEntry `json:"items"`
+	Total  int64           `json:"total"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
+}
+
+// ChangeEvent 是 GET /events 推送的单条变更事件。
+type ChangeEvent struct {
+	Type string    `json:"type"`
+	At   time.Time `json:"at"`
+}
+
+// ApplyRequest 是 POST /apply 的期望状态文档。
+type ApplyRequest struct {
+	Rules    []rules.Rule     `json:"rules,omitempty"`
+	Users    []DesiredUser    `json:"users,omitempty"`
+	Bindings []DesiredBinding `json:"bindings,omitempty"`
+	// Prune 为 true 时删除文档中未声明的规则，以及已声明用户名下未声明的绑定。
+	Prune bool `json:"prune,omitempty"`
+	// Fingerprint 为 PlanApply 返回的指纹，提供时若计划已变化则返回 409。
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// DesiredUser 以用户名标识期望存在的用户。
+type DesiredUser struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Metadata    map[string]any `json:"metadata,omitempty"`
+	RateLimits
+	ModelAccess
+}
+
+// DesiredBinding 描述 API Key 在某个 service + position 上应绑定的上游凭据，User 为用户名。
+type DesiredBinding struct {
+	User       string `json:"user"`
+	APIKeyID   string `json:"api_key_id"`
+	UpstreamID string `json:"upstream_id"`
+	Service    string `json:"service,omitempty"`
+	Position   int    `json:"position"`
+}
+
+// ApplyChange 是计划中的单项变更。
+type ApplyChange struct {
+	ResourceType string          `json:"resource_type"`
+	Action       string          `json:"action"`
+	ID           string          `json:"id"`
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+}
+
+// ApplyPlan 是期望状态与当前状态的差异及执行结果。
+type ApplyPlan struct {
+	Fingerprint string        `json:"fingerprint"`
+	Changes     []ApplyChange `json:"changes"`
+	Applied     bool          `json:"applied"`
+}
+
+// AdminUser 对应 AdminUser，Role 取值为 viewer、editor 或 owner。
+type AdminUser struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateAdminUserRequest 对应 CreateAdminUserRequest。
+type CreateAdminUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// PatchAdminUserRequest 对应 PatchAdminUserRequest，nil 字段保持不变。
+type PatchAdminUserRequest struct {
+	Password *string `json:"password,omitempty"`
+	Role     *string `json:"role,omitempty"`
+	Disabled *bool   `json:"disabled,omitempty"`
+}
+
+// ServiceToken 对应 ServiceToken，RevokedAt 非空表示已吊销。
+type ServiceToken struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Permissions []string   `json:"permissions"`
+	CreatedBy   string     `json:"created_by,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// CreatedServiceToken 对应 CreatedServiceToken，Token 为仅返回一次的明文。
+type CreatedServiceToken struct {
+	ServiceToken
+	Token string `json:"token"`
+}
+
+// CreateServiceTokenRequest 对应 CreateServiceTokenRequest，ExpiresAt 为空表示永不过期。
+type CreateServiceTokenRequest struct {
+	Name        string     `json:"name"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// RequestTrace 对应 RequestTrace，是单个代理请求的决策轨迹，敏感取值已脱敏为 [REDACTED]。
+type RequestTrace struct {
+	RequestID       string                 `json:"request_id"`
+	Method          string                 `json:"method"`
+	Path            string                 `json:"path"`
+	UserID          string                 `json:"user_id,omitempty"`
+	APIKeyID        string                 `json:"api_key_id,omitempty"`
+	Rule            *TraceRule             `json:"rule,omitempty"`
+	Actions         []string               `json:"actions,omitempty"`
+	HeaderMutations []TraceHeaderMutation  `json:"header_mutations,omitempty"`
+	BodyMutations   []TraceBodyMutation    `json:"body_mutations,omitempty"`
+	Attempts        []TraceUpstreamAttempt `json:"attempts,omitempty"`
+	Status          int                    `json:"status"`
+	Error           string                 `json:"error,omitempty"`
+	StartedAt       time.Time              `json:"started_at"`
+	DurationMs      int64                  `json:"duration_ms"`
+}
+
+// TraceRule 是轨迹中命中的规则，Default 表示使用默认上游。
+type TraceRule struct {
+	ID       string `json:"id"`
+	Priority int    `json:"priority"`
+	Version  int    `json:"version,omitempty"`
+	Default  bool   `json:"default,omitempty"`
+}
+
+// TraceHeaderMutation 是一次请求头改写，Op 为 set、add 或 remove。
+type TraceHeaderMutation struct {
+	Op    string `json:"op"`
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// TraceBodyMutation 是一次 JSON 请求体改写，Op 为 override 或 remove。
+type TraceBodyMutation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// TraceUpstreamAttempt 是一次上游调用，Failover 表示由故障转移切换而来。
+type TraceUpstreamAttempt struct {
+	Target       string `json:"target"`
+	Path         string `json:"path,omitempty"`
+	BindingID    string `json:"binding_id,omitempty"`
+	CredentialID string `json:"credential_id,omitempty"`
+	Position     int    `json:"position,omitempty"`
+	Status       int    `json:"status,omitempty"`
+	Error        string `json:"error,omitempty"`
+	Failover     bool   `json:"failover,omitempty"`
+	LatencyMs    int64  `json:"latency_ms"`
+	DurationMs   int64  `json:"duration_ms"`
+}
+
+// ReplayRequest 对应 ReplayRequest，Body 为 nil 时使用对话归档中的请求体。
+type ReplayRequest struct {
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    *string           `json:"body,omitempty"`
+}
+
+// ReplayOutcome 对应 ReplayOutcome，是原请求或重放请求的执行结果。
+type ReplayOutcome struct {
+	RequestID  string                 `json:"request_id"`
+	Status     int                    `json:"status"`
+	Rule       *TraceRule             `json:"rule,omitempty"`
+	Actions    []string               `json:"actions,omitempty"`
+	Attempts   []TraceUpstreamAttempt `json:"attempts,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMs int64                  `json:"duration_ms"`
+	Response   string                 `json:"response,omitempty"`
+	Truncated  bool                   `json:"truncated,omitempty"`
+}
+
+// ReplayResult 对应 ReplayResult，BodySource 为 request、archive 或 none。
+type ReplayResult struct {
+	RequestID     string        `json:"request_id"`
+	BodySource    string        `json:"body_source"`
+	Original      ReplayOutcome `json:"original"`
+	Replay        ReplayOutcome `json:"replay"`
+	StatusChanged bool          `json:"status_changed"`
+	RuleChanged   bool          `json:"rule_changed"`
+}
+
+// Backup 对应 Backup。记录保留原始 ID 与 API Key 哈希，可原样传给 Restore。
+type Backup struct {
+	Version    int               `json:"version"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Encryption *BackupEncryption `json:"encryption,omitempty"`
+	Rules      []rules.Rule      `json:"rules"`
+	Users      []BackupUser      `json:"users"`
+	APIKeys    []BackupAPIKey    `json:"api_keys"`
+	Upstreams  []BackupUpstream  `json:"upstreams"`
+	Pools      []BackupPool      `json:"pools"`
+	Bindings   []BackupBinding   `json:"bindings"`
+}
+
+// BackupEncryption 描述上游密钥的加密参数，Salt 为 base64 编码。
+type BackupEncryption struct {
+	Cipher string `json:"cipher"`
+	Salt   string `json:"salt"`
+}
+
+// BackupUser 是备份中的用户。
+type BackupUser struct {
+	ID                    string         `json:"id"`
+	Name                  string         `json:"name"`
+	Description           string         `json:"description,omitempty"`
+	Metadata              map[string]any `json:"metadata,omitempty"`
+	MaxRequestsPerMinute  int            `json:"max_requests_per_minute,omitempty"`
+	MaxConcurrentRequests int            `json:"max_concurrent_requests,omitempty"`
+	CreatedAt             time.Time      `json:"created_at"`
+}
+
+// BackupAPIKey 是备份中的 API Key，SecretHash 为密钥哈希而非明文。
+type BackupAPIKey struct {
+	ID                    string         `json:"id"`
+	UserID                string         `json:"user_id"`
+	Label                 string         `json:"label,omitempty"`
+	Prefix                string         `json:"prefix"`
+	SecretHash            string         `json:"secret_hash"`
+	Enabled               bool           `json:"enabled"`
+	Metadata              map[string]any `json:"metadata,omitempty"`
+	MaxRequestsPerMinute  int            `json:"max_requests_per_minute,omitempty"`
+	MaxConcurrentRequests int            `json:"max_concurrent_requests,omitempty"`
+	CreatedAt             time.Time      `json:"created_at"`
+}
+
+// BackupUpstream 是备份中的上游凭据，EncryptedAPIKey 仅在导出时提供口令才会写入。
+type BackupUpstream struct {
+	ID              string         `json:"id"`
+	UserID          string         `json:"user_id"`
+	Service         string         `json:"service"`
+	Name            string         `json:"name,omitempty"`
+	EncryptedAPIKey string         `json:"encrypted_api_key,omitempty"`
+	SecretRef       string         `json:"secret_ref,omitempty"`
+	Endpoints       []string       `json:"endpoints,omitempty"`
+	Metadata        map[string]any `json:"metadata,omitempty"`
+	Enabled         bool           `json:"enabled"`
+	IsDefault       bool           `json:"is_default,omitempty"`
+	PoolID          string         `json:"pool_id,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+// BackupPool 是备份中的上游 Key 池。
+type BackupPool struct {
+	ID        string         `json:"id"`
+	UserID    string         `json:"user_id"`
+	Name      string         `json:"name"`
+	Service   string         `json:"service"`
+	Strategy  string         `json:"strategy"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// BackupBinding 是备份中的 API Key 绑定。
+type BackupBinding struct {
+	ID         string         `json:"id"`
+	UserID     string         `json:"user_id"`
+	APIKeyID   string         `json:"api_key_id"`
+	UpstreamID string         `json:"upstream_id"`
+	Service    string         `json:"service"`
+	Position   int            `json:"position"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// RestoreResult 对应 RestoreResult，MissingSecrets 中的上游凭据以停用状态恢复。
+type RestoreResult struct {
+	Rules          int      `json:"rules"`
+	Users          int      `json:"users"`
+	APIKeys        int      `json:"api_keys"`
+	Upstreams      int      `json:"upstreams"`
+	Pools          int      `json:"pools"`
+	Bindings       int      `json:"bindings"`
+	MissingSecrets []string `json:"missing_secrets"`
+}
+
+// RuntimeConfig 对应 RuntimeConfig。Settings 以环境变量名为键，Backends 为组件到实现的映射（如 event_bus: memory）。
+type RuntimeConfig struct {
+	Settings map[string]string `json:"settings"`
+	Backends map[string]string `json:"backends"`
+	Warnings []string          `json:"warnings"`
+}
+
+// LogLevel 对应 LogLevel。
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+// FeatureFlag 对应 FeatureFlag。
+type FeatureFlag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Default     bool   `json:"default"`
+}
+
+// FeatureFlagList 对应 FeatureFlagList。
+type FeatureFlagList struct {
+	Items []FeatureFlag `json:"items"`
+}
+
+// RulesDiffRequest 对应 RulesDiffRequest，Base 为 nil 时与当前生效的规则比较。
+type RulesDiffRequest struct {
+	Base      *[]rules.Rule `json:"base,omitempty"`
+	Candidate []rules.Rule  `json:"candidate"`
+}
+
+// RulesDiff 对应 RulesDiff。
+type RulesDiff struct {
+	Added     []rules.Rule `json:"added"`
+	Removed   []rules.Rule `json:"removed"`
+	Changed   []RuleChange `json:"changed"`
+	Unchanged int          `json:"unchanged"`
+}
+
+// RuleExplainRequest 对应 RuleExplainRequest，是用于解释规则匹配的样例请求。
+type RuleExplainRequest struct {
+	Method            string            `json:"method"`
+	Path              string            `json:"path"`
+	Headers           map[string]string `json:"headers,omitempty"`
+	APIKeyID          string            `json:"api_key_id,omitempty"`
+	APIKeyPrefix      string            `json:"api_key_prefix,omitempty"`
+	UserID            string            `json:"user_id,omitempty"`
+	UserMetadata      map[string]any    `json:"user_metadata,omitempty"`
+	BindingUpstreamID string            `json:"binding_upstream_id,omitempty"`
+	Provider          string            `json:"provider,omitempty"`
+}
+
+// RuleExplanation 对应 RuleExplanation。
+type RuleExplanation struct {
+	RuleID          string                  `json:"rule_id"`
+	Enabled         bool                    `json:"enabled"`
+	Matched         bool                    `json:"matched"`
+	Fires           bool                    `json:"fires"`
+	EffectiveRuleID string                  `json:"effective_rule_id,omitempty"`
+	Conditions      []rules.ConditionResult `json:"conditions"`
+}
+
+// RuleChange 是 RulesDiff 中单条规则的变更，Fields 以点分路径为键。
+type RuleChange struct {
+	ID     string                 `json:"id"`
+	Before rules.Rule             `json:"before"`
+	After  rules.Rule             `json:"after"`
+	Fields map[string]AuditChange `json:"fields"`
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
@@ -8,19 +10,176 @@ import (
 	"time"
 )
 
-// Config 汇总服务运行所需的环境变量配置。
+// Config 汇总服务运行所需的环境变量配置。env 标签记录字段对应的环境变量，
+// 标记为 secret 或 dsn 的字段在 Settings 中脱敏。
 type Config struct {
-	GatewayPort         string
-	UpstreamBaseURL     string
-	DatabaseDSN         string
-	RedisAddr           string
-	RedisChannel        string
-	RedisMaintMode      string
-	AdminUsername       string
-	AdminPassword       string
-	AdminTokenSecret    string
-	AdminTokenTTL       time.Duration
-	AdminAllowedOrigins []string
+	GatewayPort                 string        `env:"GATEWAY_PORT"`
+	AdminPort                   string        `env:"ADMIN_PORT"`
+	ServerReadTimeout           time.Duration `env:"SERVER_READ_TIMEOUT"`
+	ServerReadHeaderTimeout     time.Duration `env:"SERVER_READ_HEADER_TIMEOUT"`
+	ServerWriteTimeout          time.Duration `env:"SERVER_WRITE_TIMEOUT"`
+	ServerIdleTimeout           time.Duration `env:"SERVER_IDLE_TIMEOUT"`
+	ServerMaxHeaderBytes        int           `env:"SERVER_MAX_HEADER_BYTES"`
+	ShutdownGracePeriod         time.Duration `env:"SHUTDOWN_GRACE_PERIOD"`
+	UpstreamBaseURL             string        `env:"UPSTREAM_BASE_URL"`
+	RequireClientAuth           bool          `env:"REQUIRE_CLIENT_AUTH"`
+	DefaultRuleMode             string        `env:"DEFAULT_RULE_MODE"`
+	DefaultRuleMethods          []string      `env:"DEFAULT_RULE_METHODS"`
+	DefaultRulePathPrefixes     []string      `env:"DEFAULT_RULE_PATH_PREFIXES"`
+	InternalHeaders             []string      `env:"INTERNAL_HEADERS"`
+	TrustedProxies              []string      `env:"TRUSTED_PROXIES"`
+	IPAllowlist                 []string      `env:"IP_ALLOWLIST"`
+	IPDenylist                  []string      `env:"IP_DENYLIST"`
+	AdminIPAllowlist            []string      `env:"ADMIN_IP_ALLOWLIST"`
+	AdminIPDenylist             []string      `env:"ADMIN_IP_DENYLIST"`
+	ProxyIPAllowlist            []string      `env:"PROXY_IP_ALLOWLIST"`
+	ProxyIPDenylist             []string      `env:"PROXY_IP_DENYLIST"`
+	MaxInflightRequests         int           `env:"MAX_INFLIGHT_REQUESTS"`
+	AdminMaxInflightRequests    int           `env:"ADMIN_MAX_INFLIGHT_REQUESTS"`
+	MaxRequestHeaders           int           `env:"MAX_REQUEST_HEADERS"`
+	MaxRequestHeaderBytes       int           `env:"MAX_REQUEST_HEADER_BYTES"`
+	MaxRequestBodyBytes         int           `env:"MAX_REQUEST_BODY_BYTES"`
+	DatabaseDSN                 string        `env:"DATABASE_DSN,dsn"`
+	DatabaseReplicaDSNs         []string      `env:"DATABASE_REPLICA_DSNS,dsn"`
+	BootstrapManifest           string        `env:"BOOTSTRAP_MANIFEST,secret"`
+	DBMaxOpenConns              int           `env:"DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns              int           `env:"DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetime           time.Duration `env:"DB_CONN_MAX_LIFETIME"`
+	DBConnMaxIdleTime           time.Duration `env:"DB_CONN_MAX_IDLE_TIME"`
+	DBStartupRetries            int           `env:"DB_STARTUP_RETRIES"`
+	DBStartupRetryBackoff       time.Duration `env:"DB_STARTUP_RETRY_BACKOFF"`
+	DBDegradedMode              bool          `env:"DB_DEGRADED_MODE"`
+	RedisAddr                   string        `env:"REDIS_ADDR"`
+	RedisChannel                string        `env:"REDIS_CHANNEL"`
+	RedisMaintMode              string        `env:"REDIS_MAINT_NOTIFICATIONS_MODE"`
+	RedisURL                    string        `env:"REDIS_URL,dsn"`
+	RedisUsername               string        `env:"REDIS_USERNAME"`
+	RedisPassword               string        `env:"REDIS_PASSWORD,secret"`
+	RedisDB                     int           `env:"REDIS_DB"`
+	RedisTLS                    bool          `env:"REDIS_TLS"`
+	RedisSentinelMaster         string        `env:"REDIS_SENTINEL_MASTER"`
+	RedisSentinelPassword       string        `env:"REDIS_SENTINEL_PASSWORD,secret"`
+	RedisCluster                bool          `env:"REDIS_CLUSTER"`
+	AdminUsername               string        `env:"ADMIN_USERNAME"`
+	AdminPassword               string        `env:"ADMIN_PASSWORD,secret"`
+	AdminTokenSecret            string        `env:"ADMIN_TOKEN_SECRET,secret"`
+	AdminTokenTTL               time.Duration `env:"ADMIN_TOKEN_TTL"`
+	AdminRefreshTokenTTL        time.Duration `env:"ADMIN_REFRESH_TOKEN_TTL"`
+	AdminAllowedOrigins         []string      `env:"ADMIN_ALLOWED_ORIGINS"`
+	APIKeyHashAlgorithm         string        `env:"API_KEY_HASH_ALGORITHM"`
+	APIKeyBcryptCost            int           `env:"API_KEY_BCRYPT_COST"`
+	APIKeyHMACSecret            string        `env:"API_KEY_HMAC_SECRET,secret"`
+	SecretsCacheTTL             time.Duration `env:"SECRETS_CACHE_TTL"`
+	SecretsEnvAllowlist         []string      `env:"SECRETS_ENV_ALLOWLIST"`
+	SecretsVaultAllowlist       []string      `env:"SECRETS_VAULT_ALLOWLIST"`
+	SecretsAWSAllowlist         []string      `env:"SECRETS_AWS_ALLOWLIST"`
+	VaultAddr                   string        `env:"VAULT_ADDR"`
+	VaultToken                  string        `env:"VAULT_TOKEN,secret"`
+	VaultNamespace              string        `env:"VAULT_NAMESPACE"`
+	AWSRegion                   string        `env:"AWS_REGION"`
+	AWSSecretsEndpoint          string        `env:"AWS_SECRETS_MANAGER_ENDPOINT"`
+	AWSAccessKeyID              string        `env:"AWS_ACCESS_KEY_ID,secret"`
+	AWSSecretAccessKey          string        `env:"AWS_SECRET_ACCESS_KEY,secret"`
+	AWSSessionToken             string        `env:"AWS_SESSION_TOKEN,secret"`
+	UpstreamHealthCheckInterval time.Duration `env:"UPSTREAM_HEALTHCHECK_INTERVAL"`
+	UpstreamRetryAfterMax       time.Duration `env:"UPSTREAM_RETRY_AFTER_MAX"`
+	UpstreamDNSCacheTTL         time.Duration `env:"UPSTREAM_DNS_CACHE_TTL"`
+	UpstreamIPPreference        string        `env:"UPSTREAM_IP_PREFERENCE"`
+	UpstreamDialTimeout         time.Duration `env:"UPSTREAM_DIAL_TIMEOUT"`
+	UpstreamDialFallbackDelay   time.Duration `env:"UPSTREAM_DIAL_FALLBACK_DELAY"`
+	UpstreamWarmTargets         []string      `env:"UPSTREAM_WARM_TARGETS"`
+	UpstreamWarmConnections     int           `env:"UPSTREAM_WARM_CONNECTIONS"`
+	UpstreamWarmInterval        time.Duration `env:"UPSTREAM_WARM_INTERVAL"`
+	StreamHeartbeatInterval     time.Duration `env:"STREAM_HEARTBEAT_INTERVAL"`
+	StreamIdleTimeout           time.Duration `env:"STREAM_IDLE_TIMEOUT"`
+	UpstreamMaxConcurrency      int           `env:"UPSTREAM_MAX_CONCURRENCY"`
+	UpstreamQueueDepth          int           `env:"UPSTREAM_QUEUE_DEPTH"`
+	UpstreamQueueTimeout        time.Duration `env:"UPSTREAM_QUEUE_TIMEOUT"`
+	UpstreamQueueTiers          string        `env:"UPSTREAM_QUEUE_TIERS"`
+	BudgetSyncInterval          time.Duration `env:"BUDGET_SYNC_INTERVAL"`
+	LeaderLeaseTTL              time.Duration `env:"LEADER_LEASE_TTL"`
+	SMTPAddr                    string        `env:"SMTP_ADDR"`
+	SMTPFrom                    string        `env:"SMTP_FROM"`
+	SMTPUsername                string        `env:"SMTP_USERNAME"`
+	SMTPPassword                string        `env:"SMTP_PASSWORD,secret"`
+	ArchiveEncryptionKey        string        `env:"ARCHIVE_ENCRYPTION_KEY,secret"`
+	ArchiveRetention            time.Duration `env:"ARCHIVE_RETENTION"`
+	ArchiveMaxBytes             int           `env:"ARCHIVE_MAX_BYTES"`
+	LangfuseHost                string        `env:"LANGFUSE_HOST"`
+	LangfusePublicKey           string        `env:"LANGFUSE_PUBLIC_KEY"`
+	LangfuseSecretKey           string        `env:"LANGFUSE_SECRET_KEY,secret"`
+	LangSmithEndpoint           string        `env:"LANGSMITH_ENDPOINT"`
+	LangSmithAPIKey             string        `env:"LANGSMITH_API_KEY,secret"`
+	LangSmithProject            string        `env:"LANGSMITH_PROJECT"`
+	TraceExportQueueSize        int           `env:"TRACE_EXPORT_QUEUE_SIZE"`
+	TraceExportBatchSize        int           `env:"TRACE_EXPORT_BATCH_SIZE"`
+	TraceExportFlushInterval    time.Duration `env:"TRACE_EXPORT_FLUSH_INTERVAL"`
+	TraceExportMaxBytes         int           `env:"TRACE_EXPORT_MAX_BYTES"`
+	AdminOIDCIssuerURL          string        `env:"ADMIN_OIDC_ISSUER_URL"`
+	AdminOIDCClientID           string        `env:"ADMIN_OIDC_CLIENT_ID"`
+	AdminOIDCClientSecret       string        `env:"ADMIN_OIDC_CLIENT_SECRET,secret"`
+	AdminOIDCRedirectURL        string        `env:"ADMIN_OIDC_REDIRECT_URL"`
+	AdminOIDCScopes             []string      `env:"ADMIN_OIDC_SCOPES"`
+	AdminOIDCGroupsClaim        string        `env:"ADMIN_OIDC_GROUPS_CLAIM"`
+	AdminOIDCRoleMapping        string        `env:"ADMIN_OIDC_ROLE_MAPPING"`
+	AdminOIDCPostLoginURL       string        `env:"ADMIN_OIDC_POST_LOGIN_URL"`
+	AdminOIDCSessionTTL         time.Duration `env:"ADMIN_OIDC_SESSION_TTL"`
+	AdminLoginMaxAttempts       int           `env:"ADMIN_LOGIN_MAX_ATTEMPTS"`
+	AdminLoginIPMaxAttempts     int           `env:"ADMIN_LOGIN_IP_MAX_ATTEMPTS"`
+	AdminLoginFailureWindow     time.Duration `env:"ADMIN_LOGIN_FAILURE_WINDOW"`
+	AdminLoginLockout           time.Duration `env:"ADMIN_LOGIN_LOCKOUT"`
+	AdminLoginMaxLockout        time.Duration `env:"ADMIN_LOGIN_MAX_LOCKOUT"`
+	AdminSessionCookieDomain    string        `env:"ADMIN_SESSION_COOKIE_DOMAIN"`
+	AdminSessionCookieSameSite  string        `env:"ADMIN_SESSION_COOKIE_SAMESITE"`
+	AdminSessionCookieInsecure  bool          `env:"ADMIN_SESSION_COOKIE_INSECURE"`
+	AdminRequireIfMatch         bool          `env:"ADMIN_REQUIRE_IF_MATCH"`
+	RequestTraceCapacity        int           `env:"REQUEST_TRACE_CAPACITY"`
+	RequestTraceTTL             time.Duration `env:"REQUEST_TRACE_TTL"`
+	LogLevel                    string        `env:"LOG_LEVEL"`
+	StrictConfig                bool          `env:"STRICT_CONFIG"`
+	FeatureFlags                string        `env:"FEATURE_FLAGS"`
+	OTelExporterEndpoint        string        `env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	OTelTracesEndpoint          string        `env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"`
+	OTelServiceName             string        `env:"OTEL_SERVICE_NAME"`
+	OTelSDKDisabled             bool          `env:"OTEL_SDK_DISABLED"`
+	ModelPricing                string        `env:"MODEL_PRICING"`
+	TokenizerBPEFile            string        `env:"TOKENIZER_BPE_FILE"`
+	AccessLogSinks              string        `env:"ACCESS_LOG_SINKS"`
+	AccessLogSampleN            int           `env:"ACCESS_LOG_SAMPLE_N"`
+	AccessLogSlowThreshold      time.Duration `env:"ACCESS_LOG_SLOW_THRESHOLD"`
+	AccessLogSlowOnly           bool          `env:"ACCESS_LOG_SLOW_ONLY"`
+	BodyLogEnabled              bool          `env:"BODY_LOG_ENABLED"`
+	BodyLogMaxBytes             int           `env:"BODY_LOG_MAX_BYTES"`
+	BodyLogRedactPaths          []string      `env:"BODY_LOG_REDACT_PATHS"`
+	ResponseCacheMaxEntries     int           `env:"RESPONSE_CACHE_MAX_ENTRIES"`
+	ResponseCacheMaxBytes       int           `env:"RESPONSE_CACHE_MAX_BYTES"`
+	MetricsNamespace            string        `env:"METRICS_NAMESPACE"`
+	MetricsSubsystem            string        `env:"METRICS_SUBSYSTEM"`
+	MetricsHTTPBuckets          []float64     `env:"METRICS_HTTP_BUCKETS"`
+	MetricsUpstreamBuckets      []float64     `env:"METRICS_UPSTREAM_BUCKETS"`
+	MetricsMaxRuleLabels        int           `env:"METRICS_MAX_RULE_LABELS"`
+	MetricsMaxModelLabels       int           `env:"METRICS_MAX_MODEL_LABELS"`
+	MetricsListenAddr           string        `env:"METRICS_LISTEN_ADDR"`
+	MetricsAuthToken            string        `env:"METRICS_AUTH_TOKEN,secret"`
+	PprofEnabled                bool          `env:"PPROF_ENABLED"`
+	SLOLatencyThreshold         time.Duration `env:"SLO_LATENCY_THRESHOLD"`
+	AnalyticsSink               string        `env:"ANALYTICS_SINK"`
+	AnalyticsDSN                string        `env:"ANALYTICS_DSN,dsn"`
+	AnalyticsTable              string        `env:"ANALYTICS_TABLE"`
+	AnalyticsBatchSize          int           `env:"ANALYTICS_BATCH_SIZE"`
+	AnalyticsFlushInterval      time.Duration `env:"ANALYTICS_FLUSH_INTERVAL"`
+	AnalyticsQueueSize          int           `env:"ANALYTICS_QUEUE_SIZE"`
+	AnalyticsDropPolicy         string        `env:"ANALYTICS_DROP_POLICY"`
+	AnalyticsBlockTimeout       time.Duration `env:"ANALYTICS_BLOCK_TIMEOUT"`
+	RateLimitDefaultRPM         int           `env:"RATE_LIMIT_DEFAULT_RPM"`
+	RateLimitDefaultConcurrency int           `env:"RATE_LIMIT_DEFAULT_CONCURRENCY"`
+	TLSCertFile                 string        `env:"TLS_CERT_FILE"`
+	TLSKeyFile                  string        `env:"TLS_KEY_FILE"`
+	TLSCertReloadInterval       time.Duration `env:"TLS_CERT_RELOAD_INTERVAL"`
+	TLSACMEDomains              []string      `env:"TLS_ACME_DOMAINS"`
+	TLSACMEEmail                string        `env:"TLS_ACME_EMAIL"`
+	TLSACMECacheDir             string        `env:"TLS_ACME_CACHE_DIR"`
+	TLSACMEDirectoryURL         string        `env:"TLS_ACME_DIRECTORY_URL"`
 }
 
 const (
@@ -32,24 +191,190 @@ const (
 	RedisMaintModeEnabled  = "enabled"
 )
 
+// DEFAULT_RULE_MODE 的取值，控制未命中规则的请求是否转发到 UPSTREAM_BASE_URL。
+const (
+	// DefaultRuleOpen 转发全部未命中规则的请求（默认）。
+	DefaultRuleOpen = "open"
+	// DefaultRuleAuthenticated 只转发携带有效 API Key 的请求。
+	DefaultRuleAuthenticated = "authenticated"
+	// DefaultRuleDisabled 不再兜底转发，未命中规则的请求返回 404。
+	DefaultRuleDisabled = "disabled"
+)
+
 // Load 从环境变量解析配置。
 func Load() Config {
 	cfg := Config{
-		GatewayPort:      lookupEnvOrDefault("GATEWAY_PORT", defaultGatewayPort),
-		UpstreamBaseURL:  os.Getenv("UPSTREAM_BASE_URL"),
-		DatabaseDSN:      os.Getenv("DATABASE_DSN"),
-		RedisAddr:        lookupEnvOrDefault("REDIS_ADDR", defaultRedisAddr),
-		RedisChannel:     lookupEnvOrDefault("REDIS_CHANNEL", defaultRedisChannel),
-		RedisMaintMode:   lookupEnvOrDefault("REDIS_MAINT_NOTIFICATIONS_MODE", RedisMaintModeDisabled),
-		AdminUsername:    os.Getenv("ADMIN_USERNAME"),
-		AdminPassword:    os.Getenv("ADMIN_PASSWORD"),
-		AdminTokenSecret: os.Getenv("ADMIN_TOKEN_SECRET"),
-	}
-	if rawAllowed := os.Getenv("ADMIN_ALLOWED_ORIGINS"); rawAllowed != "" {
+		GatewayPort:                 lookupEnvOrDefault("GATEWAY_PORT", defaultGatewayPort),
+		AdminPort:                   getenv("ADMIN_PORT"),
+		ServerReadTimeout:           lookupEnvDuration("SERVER_READ_TIMEOUT", 0),
+		ServerReadHeaderTimeout:     lookupEnvDuration("SERVER_READ_HEADER_TIMEOUT", 5*time.Second),
+		ServerWriteTimeout:          lookupEnvDuration("SERVER_WRITE_TIMEOUT", 0),
+		ServerIdleTimeout:           lookupEnvDuration("SERVER_IDLE_TIMEOUT", 2*time.Minute),
+		ServerMaxHeaderBytes:        lookupEnvInt("SERVER_MAX_HEADER_BYTES", 1<<20),
+		ShutdownGracePeriod:         lookupEnvDuration("SHUTDOWN_GRACE_PERIOD", 10*time.Second),
+		UpstreamBaseURL:             getenv("UPSTREAM_BASE_URL"),
+		RequireClientAuth:           lookupEnvBool("REQUIRE_CLIENT_AUTH", false),
+		DefaultRuleMode:             strings.ToLower(lookupEnvOrDefault("DEFAULT_RULE_MODE", DefaultRuleOpen)),
+		DefaultRuleMethods:          parseCSV(getenv("DEFAULT_RULE_METHODS")),
+		DefaultRulePathPrefixes:     parseCSV(getenv("DEFAULT_RULE_PATH_PREFIXES")),
+		InternalHeaders:             parseCSV(getenv("INTERNAL_HEADERS")),
+		TrustedProxies:              parseCSV(getenv("TRUSTED_PROXIES")),
+		IPAllowlist:                 parseCSV(getenv("IP_ALLOWLIST")),
+		IPDenylist:                  parseCSV(getenv("IP_DENYLIST")),
+		AdminIPAllowlist:            parseCSV(getenv("ADMIN_IP_ALLOWLIST")),
+		AdminIPDenylist:             parseCSV(getenv("ADMIN_IP_DENYLIST")),
+		ProxyIPAllowlist:            parseCSV(getenv("PROXY_IP_ALLOWLIST")),
+		ProxyIPDenylist:             parseCSV(getenv("PROXY_IP_DENYLIST")),
+		MaxInflightRequests:         lookupEnvInt("MAX_INFLIGHT_REQUESTS", 0),
+		AdminMaxInflightRequests:    lookupEnvInt("ADMIN_MAX_INFLIGHT_REQUESTS", 0),
+		MaxRequestHeaders:           lookupEnvInt("MAX_REQUEST_HEADERS", 0),
+		MaxRequestHeaderBytes:       lookupEnvInt("MAX_REQUEST_HEADER_BYTES", 0),
+		MaxRequestBodyBytes:         lookupEnvInt("MAX_REQUEST_BODY_BYTES", 0),
+		DatabaseDSN:                 getenv("DATABASE_DSN"),
+		DatabaseReplicaDSNs:         parseCSV(getenv("DATABASE_REPLICA_DSNS")),
+		BootstrapManifest:           getenv("BOOTSTRAP_MANIFEST"),
+		DBMaxOpenConns:              lookupEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:              lookupEnvInt("DB_MAX_IDLE_CONNS", 5),
+		DBConnMaxLifetime:           lookupEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DBConnMaxIdleTime:           lookupEnvDuration("DB_CONN_MAX_IDLE_TIME", 0),
+		DBStartupRetries:            lookupEnvInt("DB_STARTUP_RETRIES", 0),
+		DBStartupRetryBackoff:       lookupEnvDuration("DB_STARTUP_RETRY_BACKOFF", time.Second),
+		DBDegradedMode:              lookupEnvBool("DB_DEGRADED_MODE", false),
+		RedisAddr:                   lookupEnvOrDefault("REDIS_ADDR", defaultRedisAddr),
+		RedisChannel:                lookupEnvOrDefault("REDIS_CHANNEL", defaultRedisChannel),
+		RedisMaintMode:              lookupEnvOrDefault("REDIS_MAINT_NOTIFICATIONS_MODE", RedisMaintModeDisabled),
+		RedisURL:                    getenv("REDIS_URL"),
+		RedisUsername:               getenv("REDIS_USERNAME"),
+		RedisPassword:               getenv("REDIS_PASSWORD"),
+		RedisDB:                     lookupEnvInt("REDIS_DB", 0),
+		RedisTLS:                    lookupEnvBool("REDIS_TLS", false),
+		RedisSentinelMaster:         getenv("REDIS_SENTINEL_MASTER"),
+		RedisSentinelPassword:       getenv("REDIS_SENTINEL_PASSWORD"),
+		RedisCluster:                lookupEnvBool("REDIS_CLUSTER", false),
+		AdminUsername:               getenv("ADMIN_USERNAME"),
+		AdminPassword:               getenv("ADMIN_PASSWORD"),
+		AdminTokenSecret:            getenv("ADMIN_TOKEN_SECRET"),
+		APIKeyHashAlgorithm:         strings.ToLower(lookupEnvOrDefault("API_KEY_HASH_ALGORITHM", "bcrypt")),
+		APIKeyBcryptCost:            lookupEnvInt("API_KEY_BCRYPT_COST", 0),
+		APIKeyHMACSecret:            getenv("API_KEY_HMAC_SECRET"),
+		SecretsCacheTTL:             lookupEnvDuration("SECRETS_CACHE_TTL", 5*time.Minute),
+		SecretsEnvAllowlist:         parseCSV(lookupEnvOrDefault("SECRETS_ENV_ALLOWLIST", "YAPI_SECRET_*")),
+		SecretsVaultAllowlist:       parseCSV(getenv("SECRETS_VAULT_ALLOWLIST")),
+		SecretsAWSAllowlist:         parseCSV(getenv("SECRETS_AWS_ALLOWLIST")),
+		VaultAddr:                   getenv("VAULT_ADDR"),
+		VaultToken:                  getenv("VAULT_TOKEN"),
+		VaultNamespace:              getenv("VAULT_NAMESPACE"),
+		AWSRegion:                   firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		AWSSecretsEndpoint:          getenv("AWS_SECRETS_MANAGER_ENDPOINT"),
+		AWSAccessKeyID:              getenv("AWS_ACCESS_KEY_ID"),
+		AWSSecretAccessKey:          getenv("AWS_SECRET_ACCESS_KEY"),
+		AWSSessionToken:             getenv("AWS_SESSION_TOKEN"),
+		UpstreamHealthCheckInterval: lookupEnvDuration("UPSTREAM_HEALTHCHECK_INTERVAL", 30*time.Minute),
+		UpstreamRetryAfterMax:       lookupEnvDuration("UPSTREAM_RETRY_AFTER_MAX", 0),
+		UpstreamDNSCacheTTL:         lookupEnvDuration("UPSTREAM_DNS_CACHE_TTL", 0),
+		UpstreamIPPreference:        lookupEnvOrDefault("UPSTREAM_IP_PREFERENCE", "auto"),
+		UpstreamDialTimeout:         lookupEnvDuration("UPSTREAM_DIAL_TIMEOUT", 30*time.Second),
+		UpstreamDialFallbackDelay:   lookupEnvDuration("UPSTREAM_DIAL_FALLBACK_DELAY", 300*time.Millisecond),
+		UpstreamWarmTargets:         parseCSV(getenv("UPSTREAM_WARM_TARGETS")),
+		UpstreamWarmConnections:     lookupEnvInt("UPSTREAM_WARM_CONNECTIONS", 2),
+		UpstreamWarmInterval:        lookupEnvDuration("UPSTREAM_WARM_INTERVAL", 30*time.Second),
+		StreamHeartbeatInterval:     lookupEnvDuration("STREAM_HEARTBEAT_INTERVAL", 15*time.Second),
+		StreamIdleTimeout:           lookupEnvDuration("STREAM_IDLE_TIMEOUT", 0),
+		UpstreamMaxConcurrency:      lookupEnvInt("UPSTREAM_MAX_CONCURRENCY", 0),
+		UpstreamQueueDepth:          lookupEnvInt("UPSTREAM_QUEUE_DEPTH", 100),
+		UpstreamQueueTimeout:        lookupEnvDuration("UPSTREAM_QUEUE_TIMEOUT", 30*time.Second),
+		UpstreamQueueTiers:          getenv("UPSTREAM_QUEUE_TIERS"),
+		BudgetSyncInterval:          lookupEnvDuration("BUDGET_SYNC_INTERVAL", 30*time.Second),
+		LeaderLeaseTTL:              lookupEnvDuration("LEADER_LEASE_TTL", 15*time.Second),
+		SMTPAddr:                    getenv("SMTP_ADDR"),
+		SMTPFrom:                    getenv("SMTP_FROM"),
+		SMTPUsername:                getenv("SMTP_USERNAME"),
+		SMTPPassword:                getenv("SMTP_PASSWORD"),
+		ArchiveEncryptionKey:        getenv("ARCHIVE_ENCRYPTION_KEY"),
+		ArchiveRetention:            lookupEnvDuration("ARCHIVE_RETENTION", 30*24*time.Hour),
+		ArchiveMaxBytes:             lookupEnvInt("ARCHIVE_MAX_BYTES", 256<<10),
+		LangfuseHost:                lookupEnvOrDefault("LANGFUSE_HOST", "https://cloud.langfuse.com"),
+		LangfusePublicKey:           getenv("LANGFUSE_PUBLIC_KEY"),
+		LangfuseSecretKey:           getenv("LANGFUSE_SECRET_KEY"),
+		LangSmithEndpoint:           lookupEnvOrDefault("LANGSMITH_ENDPOINT", "https://api.smith.langchain.com"),
+		LangSmithAPIKey:             getenv("LANGSMITH_API_KEY"),
+		LangSmithProject:            lookupEnvOrDefault("LANGSMITH_PROJECT", "default"),
+		TraceExportQueueSize:        lookupEnvInt("TRACE_EXPORT_QUEUE_SIZE", 1000),
+		TraceExportBatchSize:        lookupEnvInt("TRACE_EXPORT_BATCH_SIZE", 50),
+		TraceExportFlushInterval:    lookupEnvDuration("TRACE_EXPORT_FLUSH_INTERVAL", 5*time.Second),
+		TraceExportMaxBytes:         lookupEnvInt("TRACE_EXPORT_MAX_BYTES", 64<<10),
+		AdminRefreshTokenTTL:        lookupEnvDuration("ADMIN_REFRESH_TOKEN_TTL", 7*24*time.Hour),
+		AdminOIDCIssuerURL:          getenv("ADMIN_OIDC_ISSUER_URL"),
+		AdminOIDCClientID:           getenv("ADMIN_OIDC_CLIENT_ID"),
+		AdminOIDCClientSecret:       getenv("ADMIN_OIDC_CLIENT_SECRET"),
+		AdminOIDCRedirectURL:        getenv("ADMIN_OIDC_REDIRECT_URL"),
+		AdminOIDCScopes:             strings.Fields(strings.ReplaceAll(getenv("ADMIN_OIDC_SCOPES"), ",", " ")),
+		AdminOIDCGroupsClaim:        lookupEnvOrDefault("ADMIN_OIDC_GROUPS_CLAIM", "groups"),
+		AdminOIDCRoleMapping:        getenv("ADMIN_OIDC_ROLE_MAPPING"),
+		AdminOIDCPostLoginURL:       getenv("ADMIN_OIDC_POST_LOGIN_URL"),
+		AdminOIDCSessionTTL:         lookupEnvDuration("ADMIN_OIDC_SESSION_TTL", 12*time.Hour),
+		AdminLoginMaxAttempts:       lookupEnvInt("ADMIN_LOGIN_MAX_ATTEMPTS", 5),
+		AdminLoginIPMaxAttempts:     lookupEnvInt("ADMIN_LOGIN_IP_MAX_ATTEMPTS", 20),
+		AdminLoginFailureWindow:     lookupEnvDuration("ADMIN_LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		AdminLoginLockout:           lookupEnvDuration("ADMIN_LOGIN_LOCKOUT", time.Minute),
+		AdminLoginMaxLockout:        lookupEnvDuration("ADMIN_LOGIN_MAX_LOCKOUT", time.Hour),
+		AdminSessionCookieDomain:    getenv("ADMIN_SESSION_COOKIE_DOMAIN"),
+		AdminSessionCookieSameSite:  lookupEnvOrDefault("ADMIN_SESSION_COOKIE_SAMESITE", "strict"),
+		AdminSessionCookieInsecure:  lookupEnvBool("ADMIN_SESSION_COOKIE_INSECURE", false),
+		AdminRequireIfMatch:         lookupEnvBool("ADMIN_REQUIRE_IF_MATCH", true),
+		RequestTraceCapacity:        lookupEnvInt("REQUEST_TRACE_CAPACITY", 1000),
+		RequestTraceTTL:             lookupEnvDuration("REQUEST_TRACE_TTL", time.Hour),
+		LogLevel:                    lookupEnvOrDefault("LOG_LEVEL", "info"),
+		StrictConfig:                lookupEnvBool("STRICT_CONFIG", false),
+		FeatureFlags:                getenv("FEATURE_FLAGS"),
+		OTelExporterEndpoint:        getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		OTelTracesEndpoint:          getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"),
+		OTelServiceName:             lookupEnvOrDefault("OTEL_SERVICE_NAME", "yapi"),
+		OTelSDKDisabled:             lookupEnvBool("OTEL_SDK_DISABLED", false),
+		ModelPricing:                getenv("MODEL_PRICING"),
+		TokenizerBPEFile:            getenv("TOKENIZER_BPE_FILE"),
+		AccessLogSinks:              getenv("ACCESS_LOG_SINKS"),
+		AccessLogSampleN:            lookupEnvInt("ACCESS_LOG_SAMPLE_N", 1),
+		AccessLogSlowThreshold:      lookupEnvDuration("ACCESS_LOG_SLOW_THRESHOLD", 0),
+		AccessLogSlowOnly:           lookupEnvBool("ACCESS_LOG_SLOW_ONLY", false),
+		BodyLogEnabled:              lookupEnvBool("BODY_LOG_ENABLED", false),
+		BodyLogMaxBytes:             lookupEnvInt("BODY_LOG_MAX_BYTES", 64<<10),
+		BodyLogRedactPaths:          parseCSV(getenv("BODY_LOG_REDACT_PATHS")),
+		ResponseCacheMaxEntries:     lookupEnvInt("RESPONSE_CACHE_MAX_ENTRIES", 1000),
+		ResponseCacheMaxBytes:       lookupEnvInt("RESPONSE_CACHE_MAX_BYTES", 64<<20),
+		MetricsNamespace:            lookupEnvOrDefault("METRICS_NAMESPACE", "gateway"),
+		MetricsSubsystem:            getenv("METRICS_SUBSYSTEM"),
+		MetricsHTTPBuckets:          lookupEnvFloats("METRICS_HTTP_BUCKETS"),
+		MetricsUpstreamBuckets:      lookupEnvFloats("METRICS_UPSTREAM_BUCKETS"),
+		MetricsMaxRuleLabels:        lookupEnvInt("METRICS_MAX_RULE_LABELS", 500),
+		MetricsMaxModelLabels:       lookupEnvInt("METRICS_MAX_MODEL_LABELS", 200),
+		MetricsListenAddr:           getenv("METRICS_LISTEN_ADDR"),
+		MetricsAuthToken:            getenv("METRICS_AUTH_TOKEN"),
+		PprofEnabled:                lookupEnvBool("PPROF_ENABLED", false),
+		SLOLatencyThreshold:         lookupEnvDuration("SLO_LATENCY_THRESHOLD", 5*time.Second),
+		AnalyticsSink:               strings.ToLower(strings.TrimSpace(getenv("ANALYTICS_SINK"))),
+		AnalyticsDSN:                getenv("ANALYTICS_DSN"),
+		AnalyticsTable:              lookupEnvOrDefault("ANALYTICS_TABLE", "request_events"),
+		AnalyticsBatchSize:          lookupEnvInt("ANALYTICS_BATCH_SIZE", 500),
+		AnalyticsFlushInterval:      lookupEnvDuration("ANALYTICS_FLUSH_INTERVAL", 5*time.Second),
+		AnalyticsQueueSize:          lookupEnvInt("ANALYTICS_QUEUE_SIZE", 10000),
+		AnalyticsDropPolicy:         lookupEnvOrDefault("ANALYTICS_DROP_POLICY", "drop_newest"),
+		AnalyticsBlockTimeout:       lookupEnvDuration("ANALYTICS_BLOCK_TIMEOUT", 50*time.Millisecond),
+		RateLimitDefaultRPM:         lookupEnvInt("RATE_LIMIT_DEFAULT_RPM", 0),
+		RateLimitDefaultConcurrency: lookupEnvInt("RATE_LIMIT_DEFAULT_CONCURRENCY", 0),
+		TLSCertFile:                 getenv("TLS_CERT_FILE"),
+		TLSKeyFile:                  getenv("TLS_KEY_FILE"),
+		TLSCertReloadInterval:       lookupEnvDuration("TLS_CERT_RELOAD_INTERVAL", time.Minute),
+		TLSACMEDomains:              parseCSV(getenv("TLS_ACME_DOMAINS")),
+		TLSACMEEmail:                getenv("TLS_ACME_EMAIL"),
+		TLSACMECacheDir:             lookupEnvOrDefault("TLS_ACME_CACHE_DIR", "acme-cache"),
+		TLSACMEDirectoryURL:         getenv("TLS_ACME_DIRECTORY_URL"),
+	}
+	if rawAllowed := getenv("ADMIN_ALLOWED_ORIGINS"); rawAllowed != "" {
 		cfg.AdminAllowedOrigins = parseCSV(rawAllowed)
 	}
 	cfg.RedisMaintMode = normalizeMaintMode(cfg.RedisMaintMode)
-	if ttl := os.Getenv("ADMIN_TOKEN_TTL"); ttl != "" {
+	if ttl := getenv("ADMIN_TOKEN_TTL"); ttl != "" {
 		if parsed, err := time.ParseDuration(ttl); err == nil {
 			cfg.AdminTokenTTL = parsed
 		} else if seconds, err := strconv.Atoi(ttl); err == nil {
@@ -73,13 +398,119 @@ func Load() Config {
 	return cfg
 }
 
+// FileSuffix 是从文件读取设置的环境变量后缀：NAME 未设置时读取 NAME_FILE 指向的文件内容，
+// 便于以 Docker / Kubernetes Secret 挂载密码、DSN 等凭据，而不是放在环境变量中。
+const FileSuffix = "_FILE"
+
+// ErrSecretFile 表示 NAME_FILE 指向的文件无法读取。
+var ErrSecretFile = errors.New("unreadable setting file")
+
+// getenv 返回环境变量 key 的值；未设置时读取 key_FILE 指向的文件，去掉末尾换行。
+// 文件无法读取时告警并视为未设置，启动时由 CheckFiles 提前报错。
+func getenv(key string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	path := os.Getenv(key + FileSuffix)
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("warning: %s%s=%q 无法读取: %v", key, FileSuffix, path, err)
+		return ""
+	}
+	return strings.TrimRight(string(data), "\r\n")
+}
+
+// CheckFiles 检查全部设置的 NAME_FILE 变量：对应的 NAME 未设置且文件无法读取时返回错误，
+// 避免密码文件挂载失败后以空密码（如允许匿名访问管理端）启动。
+func CheckFiles() error {
+	var errs []error
+	for name := range settingNames() {
+		path := os.Getenv(name + FileSuffix)
+		if path == "" || os.Getenv(name) != "" {
+			continue
+		}
+		if _, err := os.ReadFile(path); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s%s: %v", ErrSecretFile, name, FileSuffix, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func lookupEnvOrDefault(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok && value != "" {
+	if value := getenv(key); value != "" {
 		return value
 	}
 	return fallback
 }
 
+func lookupEnvDuration(key string, fallback time.Duration) time.Duration {
+	raw := strings.TrimSpace(getenv(key))
+	if raw == "" {
+		return fallback
+	}
+	if parsed, err := time.ParseDuration(raw); err == nil {
+		return parsed
+	}
+	if seconds, err := strconv.Atoi(raw); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	log.Printf("warning: %s=%q 无法解析，使用默认值", key, raw)
+	return fallback
+}
+
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if value := strings.TrimSpace(getenv(key)); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func lookupEnvInt(key string, fallback int) int {
+	raw := strings.TrimSpace(getenv(key))
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Printf("warning: %s=%q 无法解析为整数，使用默认值", key, raw)
+		return fallback
+	}
+	return value
+}
+
+func lookupEnvBool(key string, fallback bool) bool {
+	raw := strings.TrimSpace(getenv(key))
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Printf("warning: %s=%q 无法解析为布尔值，使用默认值", key, raw)
+		return fallback
+	}
+	return value
+}
+
+// lookupEnvFloats 解析逗号分隔的浮点数列表，任一项无法解析时告警并返回 nil（使用默认值）。
+func lookupEnvFloats(key string) []float64 {
+	raw := getenv(key)
+	var values []float64
+	for _, part := range parseCSV(raw) {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			log.Printf("warning: %s=%q 无法解析为数字列表，使用默认值", key, raw)
+			return nil
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
 func parseCSV(raw string) []string {
 	parts := strings.Split(raw, ",")
 	var values []string
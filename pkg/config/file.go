@@ -0,0 +1,298 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv 是指定配置文件路径的环境变量，命令行参数 --config 优先。
+const FileEnv = "CONFIG_FILE"
+
+// passthroughSection 是配置文件中原样设置任意环境变量的段落，用于 OTEL_TRACES_SAMPLER 等不属于 Config 的变量。
+const passthroughSection = "env"
+
+// ProfileEnv 是选择配置文件中环境 profile（如 dev、staging、prod）的环境变量。
+const ProfileEnv = "YAPI_ENV"
+
+// profilesSection 是配置文件中定义各环境 profile 的段落，extendsKey 指定 profile 继承的另一个 profile。
+const (
+	profilesSection = "profiles"
+	extendsKey      = "extends"
+)
+
+// ErrInvalidConfigFile 表示配置文件无法解析或包含未知设置。
+var ErrInvalidConfigFile = errors.New("invalid config file")
+
+// applied 记录上一次由配置文件写入的环境变量，重新加载时据此区分配置文件的取值与真实环境变量。
+var applied struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// LoadFile 读取 YAML（.yaml / .yml）或 TOML（.toml）配置文件并将其中的设置作为环境变量的默认值，再调用 Load。
+// 非空的环境变量（含 .env 文件加载的变量）优先于配置文件，空值视为未设置，
+// 以免照抄 .env.example 留下的空变量覆盖配置文件；path 为空时等同于 Load。
+// 环境变量中的 NAME_FILE 同样优先于配置文件中的 NAME；NAME_FILE 指向的文件无法读取时返回 ErrSecretFile。
+//
+// 可重复调用以重新加载：此前由配置文件写入的变量按新文件更新，文件中已删除的设置恢复为未设置。
+func LoadFile(path string) (Config, error) {
+	if path == "" {
+		if err := CheckFiles(); err != nil {
+			return Config{}, err
+		}
+		return Load(), nil
+	}
+	values, err := ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	applied.mu.Lock()
+	defer applied.mu.Unlock()
+	fromFile := func(key string) bool {
+		previous, ok := applied.values[key]
+		return ok && os.Getenv(key) == previous
+	}
+	for key := range applied.values {
+		if _, ok := values[key]; !ok && fromFile(key) {
+			_ = os.Unsetenv(key)
+		}
+	}
+	next := make(map[string]string, len(values))
+	for key, value := range values {
+		if os.Getenv(key) != "" && !fromFile(key) {
+			continue
+		}
+		if file := key + FileSuffix; os.Getenv(file) != "" && !fromFile(file) {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return Config{}, fmt.Errorf("%w %s: set %s: %v", ErrInvalidConfigFile, path, key, err)
+		}
+		next[key] = value
+	}
+	applied.values = next
+	if err := CheckFiles(); err != nil {
+		return Config{}, err
+	}
+	return Load(), nil
+}
+
+// ReadFile 解析配置文件，返回以环境变量名为键的设置。
+//
+// 嵌套的键以下划线连接并转为大写后对应环境变量名，例如 metrics.namespace 对应 METRICS_NAMESPACE，
+// 同一设置也可直接写作 metrics_namespace。取值按以下规则转换：标量取字符串形式；
+// 标量列表以逗号连接（如 ADMIN_ALLOWED_ORIGINS）；对象或对象列表编码为 JSON（如 MODEL_PRICING 价格表）。
+// env 段落中的键值原样作为环境变量。不对应任何设置的键视为错误，以便发现拼写错误；
+// 设置名加 _file 后缀（如 admin.password_file）指定从文件读取该设置。
+//
+// profiles 段落按名称定义各环境的覆盖项，写法与顶层相同，YAPI_ENV 选择其中之一：先取顶层设置，
+// 再依次应用 extends 继承链上的 profile，最后应用所选 profile，后者覆盖前者；取值为 null 时恢复默认值。
+// YAPI_ENV 为空时只使用顶层设置，指定了未定义的 profile 时返回错误。
+func ReadFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrInvalidConfigFile, path, err)
+	}
+	doc := map[string]any{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("%w %s: unsupported extension %q, want .yaml, .yml or .toml", ErrInvalidConfigFile, path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrInvalidConfigFile, path, err)
+	}
+	var profiles map[string]any
+	if section, ok := doc[profilesSection]; ok {
+		if profiles, ok = section.(map[string]any); !ok {
+			return nil, fmt.Errorf("%w %s: %s must be a table of profiles", ErrInvalidConfigFile, path, profilesSection)
+		}
+		delete(doc, profilesSection)
+	}
+	values, err := settings(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrInvalidConfigFile, path, err)
+	}
+	profile := os.Getenv(ProfileEnv)
+	if profile == "" {
+		return values, nil
+	}
+	chain, err := profileChain(profiles, profile)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrInvalidConfigFile, path, err)
+	}
+	for _, name := range chain {
+		overrides, err := settings(profiles[name].(map[string]any))
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: profile %s: %v", ErrInvalidConfigFile, path, name, err)
+		}
+		maps.Copy(values, overrides)
+	}
+	return values, nil
+}
+
+// settings 把一层配置（顶层或某个 profile，不含 extends）转换为以环境变量名为键的设置。
+func settings(doc map[string]any) (map[string]string, error) {
+	known := settingNames()
+	values := make(map[string]string)
+	for key, value := range doc {
+		switch key {
+		case extendsKey:
+			continue
+		case passthroughSection:
+			if err := passthrough(value, values); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := flatten(settingName(key), value, known, values); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// profileChain 返回应用 profile 的顺序：从继承链最顶端的 profile 到 name 本身。
+func profileChain(profiles map[string]any, name string) ([]string, error) {
+	var chain []string
+	for name != "" {
+		if slices.Contains(chain, name) {
+			return nil, fmt.Errorf("profile %s: circular %s", name, extendsKey)
+		}
+		section, ok := profiles[name]
+		if !ok && len(chain) == 0 {
+			return nil, fmt.Errorf("unknown profile %q selected by %s", name, ProfileEnv)
+		}
+		if !ok {
+			return nil, fmt.Errorf("profile %s %s unknown profile %q", chain[len(chain)-1], extendsKey, name)
+		}
+		profile, ok := section.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("profile %s must be a table of settings", name)
+		}
+		chain = append(chain, name)
+		parent, ok := profile[extendsKey].(string)
+		if _, set := profile[extendsKey]; set && !ok {
+			return nil, fmt.Errorf("profile %s: %s must be a profile name", name, extendsKey)
+		}
+		name = parent
+	}
+	slices.Reverse(chain)
+	return chain, nil
+}
+
+// settingNames 返回 Config 中全部设置对应的环境变量名。
+func settingNames() map[string]bool {
+	fields := reflect.TypeOf(Config{})
+	names := make(map[string]bool, fields.NumField())
+	for i := range fields.NumField() {
+		if name, _, _ := strings.Cut(fields.Field(i).Tag.Get("env"), ","); name != "" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+func settingName(key string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+}
+
+func flatten(name string, value any, known map[string]bool, out map[string]string) error {
+	if known[name] || known[strings.TrimSuffix(name, FileSuffix)] {
+		formatted, err := formatValue(value)
+		if err != nil {
+			return fmt.Errorf("setting %s: %v", name, err)
+		}
+		out[name] = formatted
+		return nil
+	}
+	section, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("unknown setting %s", name)
+	}
+	for key, child := range section {
+		if err := flatten(name+"_"+settingName(key), child, known, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func passthrough(value any, out map[string]string) error {
+	section, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s must be a table of variables", passthroughSection)
+	}
+	for key, child := range section {
+		formatted, err := formatValue(child)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", passthroughSection, key, err)
+		}
+		out[key] = formatted
+	}
+	return nil
+}
+
+func formatValue(value any) (string, error) {
+	switch v := value.(type) {
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			scalar, ok := formatScalar(item)
+			if !ok {
+				return marshalJSON(v)
+			}
+			parts[i] = scalar
+		}
+		return strings.Join(parts, ","), nil
+	case map[string]any:
+		return marshalJSON(v)
+	}
+	if scalar, ok := formatScalar(value); ok {
+		return scalar, nil
+	}
+	return "", fmt.Errorf("unsupported value type %T", value)
+}
+
+func formatScalar(value any) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	}
+	return "", false
+}
+
+func marshalJSON(value any) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
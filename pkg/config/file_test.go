@@ -0,0 +1,251 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestReadFile_FlattensNestedYAML(t *testing.T) {
+	path := writeConfigFile(t, "yapi.yaml", `
+gateway_port: 9000
+metrics:
+  namespace: yapi
+  http_buckets: [0.1, 0.5, 2]
+admin:
+  allowed_origins:
+    - https://a.example.com
+    - https://b.example.com
+  token_ttl: 45m
+model_pricing:
+  gpt-4o: {prompt: 2.5, completion: 10}
+access_log:
+  sinks: ["stdout?level=warn", "file:/var/log/yapi/access.log"]
+env:
+  OTEL_TRACES_SAMPLER: parentbased_traceidratio
+`)
+	values, err := ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"GATEWAY_PORT":          "9000",
+		"METRICS_NAMESPACE":     "yapi",
+		"METRICS_HTTP_BUCKETS":  "0.1,0.5,2",
+		"ADMIN_ALLOWED_ORIGINS": "https://a.example.com,https://b.example.com",
+		"ADMIN_TOKEN_TTL":       "45m",
+		"MODEL_PRICING":         `{"gpt-4o":{"completion":10,"prompt":2.5}}`,
+		"ACCESS_LOG_SINKS":      "stdout?level=warn,file:/var/log/yapi/access.log",
+		"OTEL_TRACES_SAMPLER":   "parentbased_traceidratio",
+	}, values)
+}
+
+func TestReadFile_TOML(t *testing.T) {
+	path := writeConfigFile(t, "yapi.toml", `
+upstream_base_url = "https://api.openai.com"
+
+[analytics]
+sink = "postgres"
+batch_size = 200
+
+[model_pricing.gpt-4o-mini]
+prompt = 0.15
+completion = 0.6
+`)
+	values, err := ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "https://api.openai.com", values["UPSTREAM_BASE_URL"])
+	require.Equal(t, "postgres", values["ANALYTICS_SINK"])
+	require.Equal(t, "200", values["ANALYTICS_BATCH_SIZE"])
+	require.JSONEq(t, `{"gpt-4o-mini":{"prompt":0.15,"completion":0.6}}`, values["MODEL_PRICING"])
+}
+
+func TestReadFile_RejectsUnknownSettingsAndFormats(t *testing.T) {
+	_, err := ReadFile(writeConfigFile(t, "typo.yaml", "metrics:\n  namespce: yapi\n"))
+	require.ErrorIs(t, err, ErrInvalidConfigFile)
+	require.ErrorContains(t, err, "unknown setting METRICS_NAMESPCE")
+
+	_, err = ReadFile(writeConfigFile(t, "yapi.json", "{}"))
+	require.ErrorIs(t, err, ErrInvalidConfigFile)
+
+	_, err = ReadFile(writeConfigFile(t, "broken.yaml", "metrics: [\n"))
+	require.ErrorIs(t, err, ErrInvalidConfigFile)
+
+	_, err = ReadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.ErrorIs(t, err, ErrInvalidConfigFile)
+}
+
+func TestLoadFile_EnvironmentOverridesFile(t *testing.T) {
+	path := writeConfigFile(t, "yapi.yaml", `
+metrics:
+  namespace: from_file
+  subsystem: edge
+slo_latency_threshold: 2s
+`)
+	t.Setenv("METRICS_NAMESPACE", "from_env")
+	// 空值视为未设置；t.Setenv 同时保证测试结束后恢复 LoadFile 写入的变量。
+	t.Setenv("METRICS_SUBSYSTEM", "")
+	t.Setenv("SLO_LATENCY_THRESHOLD", "")
+
+	cfg, err := LoadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "from_env", cfg.MetricsNamespace)
+	require.Equal(t, "edge", cfg.MetricsSubsystem)
+	require.Equal(t, 2*time.Second, cfg.SLOLatencyThreshold)
+}
+
+func TestLoadFile_ReloadTracksFileChanges(t *testing.T) {
+	path := writeConfigFile(t, "yapi.yaml", `
+log_level: debug
+metrics:
+  subsystem: edge
+`)
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("METRICS_SUBSYSTEM", "")
+	t.Setenv("METRICS_NAMESPACE", "from_env")
+
+	before, err := LoadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "debug", before.LogLevel)
+	require.Equal(t, "edge", before.MetricsSubsystem)
+
+	require.NoError(t, os.WriteFile(path, []byte("log_level: warn\nmetrics:\n  namespace: from_file\n"), 0o600))
+	after, err := LoadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "warn", after.LogLevel)
+	require.Empty(t, after.MetricsSubsystem, "settings removed from the file fall back to defaults")
+	require.Equal(t, "from_env", after.MetricsNamespace, "environment variables keep precedence on reload")
+	require.Equal(t, []string{"LOG_LEVEL", "METRICS_SUBSYSTEM"}, Diff(before, after))
+}
+
+func TestLoadFile_ReadsSettingsFromFiles(t *testing.T) {
+	dir := t.TempDir()
+	password := filepath.Join(dir, "admin_password")
+	require.NoError(t, os.WriteFile(password, []byte("s3cret\n"), 0o600))
+	dsn := filepath.Join(dir, "database_dsn")
+	require.NoError(t, os.WriteFile(dsn, []byte("postgres://file"), 0o600))
+	path := writeConfigFile(t, "yapi.yaml", `
+database:
+  dsn: postgres://config
+admin:
+  password_file: `+password+`
+`)
+	t.Setenv("ADMIN_PASSWORD", "")
+	t.Setenv("ADMIN_PASSWORD_FILE", "")
+	t.Setenv("DATABASE_DSN", "")
+	t.Setenv("DATABASE_DSN_FILE", dsn)
+	t.Setenv("ADMIN_TOKEN_SECRET", "from_env")
+	t.Setenv("ADMIN_TOKEN_SECRET_FILE", filepath.Join(dir, "ignored"))
+
+	cfg, err := LoadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", cfg.AdminPassword, "trailing newline is trimmed")
+	require.Equal(t, "postgres://file", cfg.DatabaseDSN, "NAME_FILE in the environment overrides the config file")
+	require.Equal(t, "from_env", cfg.AdminTokenSecret, "NAME takes precedence over NAME_FILE")
+
+	require.NoError(t, os.WriteFile(password, []byte("rotated"), 0o600))
+	cfg, err = LoadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "rotated", cfg.AdminPassword, "files are re-read on reload")
+}
+
+func TestLoadFile_UnreadableSettingFile(t *testing.T) {
+	t.Setenv("ADMIN_PASSWORD", "")
+	t.Setenv("ADMIN_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	_, err := LoadFile("")
+	require.ErrorIs(t, err, ErrSecretFile)
+	require.ErrorContains(t, err, "ADMIN_PASSWORD_FILE")
+}
+
+func TestReadFile_ProfilesOverrideAndInherit(t *testing.T) {
+	path := writeConfigFile(t, "yapi.yaml", `
+log_level: debug
+metrics:
+  namespace: yapi
+access_log:
+  sinks: stdout
+profiles:
+  staging:
+    log_level: info
+    upstream_base_url: https://staging.example.com
+  prod:
+    extends: staging
+    upstream_base_url: https://api.example.com
+    metrics_namespace: yapi_prod
+    access_log: {sinks: null}
+    env:
+      OTEL_TRACES_SAMPLER: parentbased_traceidratio
+`)
+	t.Setenv(ProfileEnv, "")
+	values, err := ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"LOG_LEVEL":         "debug",
+		"METRICS_NAMESPACE": "yapi",
+		"ACCESS_LOG_SINKS":  "stdout",
+	}, values)
+
+	t.Setenv(ProfileEnv, "prod")
+	values, err = ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"LOG_LEVEL":           "info",
+		"UPSTREAM_BASE_URL":   "https://api.example.com",
+		"METRICS_NAMESPACE":   "yapi_prod",
+		"ACCESS_LOG_SINKS":    "",
+		"OTEL_TRACES_SAMPLER": "parentbased_traceidratio",
+	}, values)
+}
+
+func TestReadFile_RejectsInvalidProfiles(t *testing.T) {
+	path := writeConfigFile(t, "yapi.toml", `
+log_level = "info"
+
+[profiles.a]
+extends = "b"
+
+[profiles.b]
+extends = "a"
+
+[profiles.c]
+extends = "missing"
+
+[profiles.d]
+metrics = {namespace = "x", typo = 1}
+`)
+	for profile, want := range map[string]string{
+		"prod": `unknown profile "prod" selected by YAPI_ENV`,
+		"a":    "profile a: circular extends",
+		"c":    `profile c extends unknown profile "missing"`,
+		"d":    "profile d: unknown setting METRICS_TYPO",
+	} {
+		t.Setenv(ProfileEnv, profile)
+		_, err := ReadFile(path)
+		require.ErrorIs(t, err, ErrInvalidConfigFile, profile)
+		require.ErrorContains(t, err, want, profile)
+	}
+}
+
+func TestReadFile_ExampleConfig(t *testing.T) {
+	path := filepath.Join("..", "..", "deploy", "yapi.example.yaml")
+	t.Setenv(ProfileEnv, "")
+	values, err := ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "5", values["ADMIN_LOGIN_MAX_ATTEMPTS"])
+	require.Equal(t, ":9090", values["METRICS_LISTEN_ADDR"])
+
+	for _, profile := range []string{"dev", "staging", "prod"} {
+		t.Setenv(ProfileEnv, profile)
+		_, err := ReadFile(path)
+		require.NoError(t, err, profile)
+	}
+}
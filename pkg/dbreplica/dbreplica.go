@@ -0,0 +1,51 @@
+// Package dbreplica 通过 gorm dbresolver 把选定的只读查询（如规则列表、用户列表）路由到只读副本。
+// 副本以命名解析器注册，未显式调用 Read 的查询与全部写操作仍在主库执行，避免写后读到复制延迟前的旧数据。
+package dbreplica
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// resolverName 是只读副本在 dbresolver 中的注册名。
+const resolverName = "read_replicas"
+
+// Pool 描述连接池设置，零值字段不修改对应设置。
+type Pool struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// Register 为 db 注册只读副本，多个副本随机选择；replicas 为空时不做任何修改。
+func Register(db *gorm.DB, replicas []gorm.Dialector, pool Pool) error {
+	if len(replicas) == 0 {
+		return nil
+	}
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	}, resolverName)
+	if pool.MaxOpenConns > 0 {
+		resolver.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		resolver.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		resolver.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		resolver.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+	return db.Use(resolver)
+}
+
+// Read 返回在只读副本上执行查询的会话，未注册副本时仍使用主库。
+// 只适用于可容忍复制延迟的查询；写入后需要立即读到结果的查询应直接使用 db。
+func Read(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Use(resolverName))
+}
@@ -0,0 +1,48 @@
+package dbreplica
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type item struct {
+	ID   uint
+	Name string
+}
+
+// openSQLite 创建只含一行（名称为 name）的 SQLite 数据库，返回连接与文件路径。
+func openSQLite(t *testing.T, name string) (*gorm.DB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&item{}))
+	require.NoError(t, db.Create(&item{Name: name}).Error)
+	return db, path
+}
+
+func TestRegister_RoutesOnlyExplicitReadsToReplicas(t *testing.T) {
+	db, _ := openSQLite(t, "primary.db")
+	_, replicaPath := openSQLite(t, "replica.db")
+
+	var items []item
+	require.NoError(t, Read(db).Find(&items).Error)
+	require.Equal(t, "primary.db", items[0].Name, "without replicas reads stay on the primary")
+
+	require.NoError(t, Register(db, []gorm.Dialector{sqlite.Open(replicaPath)}, Pool{MaxOpenConns: 2}))
+	require.NoError(t, Read(db).Find(&items).Error)
+	require.Equal(t, "replica.db", items[0].Name)
+	var total int64
+	require.NoError(t, Read(db).Model(&item{}).Count(&total).Error)
+	require.EqualValues(t, 1, total)
+
+	require.NoError(t, db.Find(&items).Error)
+	require.Equal(t, "primary.db", items[0].Name, "unmarked queries stay on the primary")
+	require.NoError(t, db.Create(&item{Name: "written"}).Error)
+	require.NoError(t, db.Model(&item{}).Count(&total).Error)
+	require.EqualValues(t, 2, total)
+}
@@ -2,17 +2,31 @@ package metrics
 
 import "github.com/prometheus/client_golang/prometheus"
 
-// AdminActionsTotal tracks administrative CRUD operations and their outcomes.
-var AdminActionsTotal = prometheus.NewCounterVec(
-	prometheus.CounterOpts{
-		Name: "gateway_admin_actions_total",
-		Help: "Total number of admin actions grouped by action and outcome.",
-	},
-	[]string{"action", "outcome"},
+var (
+	// AdminActionsTotal tracks administrative CRUD operations and their outcomes.
+	AdminActionsTotal *prometheus.CounterVec
+
+	// AdminLoginFailuresTotal counts rejected admin logins by reason (invalid_credential or locked).
+	AdminLoginFailuresTotal *prometheus.CounterVec
+
+	// AdminLoginLockoutsTotal counts lockouts triggered by repeated login failures, by scope (ip or username).
+	AdminLoginLockoutsTotal *prometheus.CounterVec
 )
 
-func init() {
-	prometheus.MustRegister(AdminActionsTotal)
+func buildAdminMetrics(o Options) []prometheus.Collector {
+	AdminActionsTotal = prometheus.NewCounterVec(
+		o.counterOpts("admin_actions_total", "Total number of admin actions grouped by action and outcome."),
+		[]string{"action", "outcome"},
+	)
+	AdminLoginFailuresTotal = prometheus.NewCounterVec(
+		o.counterOpts("admin_login_failures_total", "Total number of rejected admin login attempts grouped by reason."),
+		[]string{"reason"},
+	)
+	AdminLoginLockoutsTotal = prometheus.NewCounterVec(
+		o.counterOpts("admin_login_lockouts_total", "Total number of admin login lockouts grouped by scope."),
+		[]string{"scope"},
+	)
+	return []prometheus.Collector{AdminActionsTotal, AdminLoginFailuresTotal, AdminLoginLockoutsTotal}
 }
 
 // ObserveAdminAction records the outcome of an admin action.
@@ -23,3 +37,13 @@ func ObserveAdminAction(action string, success bool) {
 	}
 	AdminActionsTotal.WithLabelValues(action, outcome).Inc()
 }
+
+// ObserveAdminLoginFailure records a rejected admin login attempt.
+func ObserveAdminLoginFailure(reason string) {
+	AdminLoginFailuresTotal.WithLabelValues(reason).Inc()
+}
+
+// ObserveAdminLoginLockout records a lockout triggered for the given scope.
+func ObserveAdminLoginLockout(scope string) {
+	AdminLoginLockoutsTotal.WithLabelValues(scope).Inc()
+}
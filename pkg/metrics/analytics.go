@@ -0,0 +1,19 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// AnalyticsEventsTotal 统计分析事件的处理结果：written 为已写入，dropped 为队列满或关闭后丢弃，failed 为重试后仍写入失败。
+var AnalyticsEventsTotal *prometheus.CounterVec
+
+func buildAnalyticsMetrics(o Options) []prometheus.Collector {
+	AnalyticsEventsTotal = prometheus.NewCounterVec(
+		o.counterOpts("analytics_events_total", "Total number of analytics events by outcome (written, dropped, failed)."),
+		[]string{"outcome"},
+	)
+	return []prometheus.Collector{AnalyticsEventsTotal}
+}
+
+// ObserveAnalyticsEvents 累加分析事件的处理结果。
+func ObserveAnalyticsEvents(outcome string, count int) {
+	AnalyticsEventsTotal.WithLabelValues(outcome).Add(float64(count))
+}
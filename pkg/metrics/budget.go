@@ -0,0 +1,37 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// BudgetAlertsTotal 按主体类型、通知渠道与结果（success / error）统计预算告警的发送。
+	BudgetAlertsTotal *prometheus.CounterVec
+
+	// BudgetRejectedTotal 按主体类型统计因 hard_stop 预算用尽被拒绝的请求。
+	BudgetRejectedTotal *prometheus.CounterVec
+)
+
+func buildBudgetMetrics(o Options) []prometheus.Collector {
+	BudgetAlertsTotal = prometheus.NewCounterVec(
+		o.counterOpts("budget_alerts_total", "Total number of budget threshold notifications, by scope, channel and outcome."),
+		[]string{"scope", "channel", "outcome"},
+	)
+	BudgetRejectedTotal = prometheus.NewCounterVec(
+		o.counterOpts("budget_rejected_total", "Total number of requests rejected because a hard-stop budget was exceeded, by scope."),
+		[]string{"scope"},
+	)
+	return []prometheus.Collector{BudgetAlertsTotal, BudgetRejectedTotal}
+}
+
+// ObserveBudgetAlert 记录一次预算告警的发送结果，channel 为 webhook 或 email。
+func ObserveBudgetAlert(scope, channel string, success bool) {
+	outcome := "success"
+	if !success {
+		outcome = "error"
+	}
+	BudgetAlertsTotal.WithLabelValues(scope, channel, outcome).Inc()
+}
+
+// ObserveBudgetRejected 记录一次因预算用尽被拒绝的请求。
+func ObserveBudgetRejected(scope string) {
+	BudgetRejectedTotal.WithLabelValues(scope).Inc()
+}
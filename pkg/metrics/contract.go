@@ -0,0 +1,19 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// ResponseContractViolationsTotal 按规则与检查项统计违反 expect_response 约定的上游响应。
+var ResponseContractViolationsTotal *prometheus.CounterVec
+
+func buildContractMetrics(o Options) []prometheus.Collector {
+	ResponseContractViolationsTotal = prometheus.NewCounterVec(
+		o.counterOpts("response_contract_violations_total", "Total number of upstream responses violating the rule's expected response contract, by rule and check."),
+		[]string{"rule", "check"},
+	)
+	return []prometheus.Collector{ResponseContractViolationsTotal}
+}
+
+// ObserveContractViolation 记录一次约定违规，check 为 status、header 或 schema。
+func ObserveContractViolation(ruleID, check string) {
+	ResponseContractViolationsTotal.WithLabelValues(ruleLabel(ruleID), check).Inc()
+}
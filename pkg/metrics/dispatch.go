@@ -0,0 +1,39 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// DispatchQueueWaiting 是等待转发名额的排队请求数。
+	DispatchQueueWaiting prometheus.Gauge
+
+	// DispatchInFlight 是占用转发名额的请求数。
+	DispatchInFlight prometheus.Gauge
+
+	// DispatchQueueTotal 按结果统计进入调度队列的请求：dispatched、rejected（队列已满）、timeout 或 canceled。
+	DispatchQueueTotal *prometheus.CounterVec
+)
+
+func buildDispatchMetrics(o Options) []prometheus.Collector {
+	DispatchQueueWaiting = prometheus.NewGauge(
+		o.gaugeOpts("dispatch_queue_waiting", "Number of requests waiting in the upstream dispatch queue."),
+	)
+	DispatchInFlight = prometheus.NewGauge(
+		o.gaugeOpts("dispatch_in_flight", "Number of requests holding an upstream dispatch slot."),
+	)
+	DispatchQueueTotal = prometheus.NewCounterVec(
+		o.counterOpts("dispatch_queue_total", "Total number of requests passing the upstream dispatch queue, by outcome."),
+		[]string{"outcome"},
+	)
+	return []prometheus.Collector{DispatchQueueWaiting, DispatchInFlight, DispatchQueueTotal}
+}
+
+// ObserveDispatchQueue 更新调度队列的占用与排队数。
+func ObserveDispatchQueue(inFlight, waiting int) {
+	DispatchInFlight.Set(float64(inFlight))
+	DispatchQueueWaiting.Set(float64(waiting))
+}
+
+// ObserveDispatchOutcome 记录一次请求通过调度队列的结果。
+func ObserveDispatchOutcome(outcome string) {
+	DispatchQueueTotal.WithLabelValues(outcome).Inc()
+}
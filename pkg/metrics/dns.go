@@ -0,0 +1,20 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// DNSLookupsTotal 按结果统计上游主机名的缓存解析：hit 命中缓存，miss 重新解析成功，
+// stale 重新解析失败而沿用过期结果，error 解析失败且没有可用结果。
+var DNSLookupsTotal *prometheus.CounterVec
+
+func buildDNSMetrics(o Options) []prometheus.Collector {
+	DNSLookupsTotal = prometheus.NewCounterVec(
+		o.counterOpts("upstream_dns_lookups_total", "Total number of cached upstream DNS lookups, by result."),
+		[]string{"result"},
+	)
+	return []prometheus.Collector{DNSLookupsTotal}
+}
+
+// ObserveDNSLookup 记录一次上游主机名解析。
+func ObserveDNSLookup(result string) {
+	DNSLookupsTotal.WithLabelValues(result).Inc()
+}
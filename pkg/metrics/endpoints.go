@@ -0,0 +1,37 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// EndpointLatencyEWMA 是上游端点响应延迟的指数加权移动平均（秒）。
+	EndpointLatencyEWMA *prometheus.GaugeVec
+
+	// EndpointErrorRateEWMA 是上游端点错误率（网络错误或 5xx）的指数加权移动平均。
+	EndpointErrorRateEWMA *prometheus.GaugeVec
+
+	// EndpointScore 是上游端点的健康得分，范围 [0, 1]，用于端点选择。
+	EndpointScore *prometheus.GaugeVec
+)
+
+func buildEndpointMetrics(o Options) []prometheus.Collector {
+	EndpointLatencyEWMA = prometheus.NewGaugeVec(
+		o.gaugeOpts("upstream_endpoint_latency_ewma_seconds", "Exponentially weighted moving average of upstream endpoint latency in seconds."),
+		[]string{"endpoint"},
+	)
+	EndpointErrorRateEWMA = prometheus.NewGaugeVec(
+		o.gaugeOpts("upstream_endpoint_error_rate_ewma", "Exponentially weighted moving average of upstream endpoint error rate."),
+		[]string{"endpoint"},
+	)
+	EndpointScore = prometheus.NewGaugeVec(
+		o.gaugeOpts("upstream_endpoint_score", "Health score in [0, 1] used to prefer upstream endpoints."),
+		[]string{"endpoint"},
+	)
+	return []prometheus.Collector{EndpointLatencyEWMA, EndpointErrorRateEWMA, EndpointScore}
+}
+
+// ObserveEndpointHealth 更新端点的健康指标。
+func ObserveEndpointHealth(endpoint string, latencySeconds, errorRate, score float64) {
+	EndpointLatencyEWMA.WithLabelValues(endpoint).Set(latencySeconds)
+	EndpointErrorRateEWMA.WithLabelValues(endpoint).Set(errorRate)
+	EndpointScore.WithLabelValues(endpoint).Set(score)
+}
@@ -0,0 +1,19 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// ErrorsTotal 按网关错误码统计返回给客户端的错误响应。
+var ErrorsTotal *prometheus.CounterVec
+
+func buildErrorMetrics(o Options) []prometheus.Collector {
+	ErrorsTotal = prometheus.NewCounterVec(
+		o.counterOpts("errors_total", "Total number of error responses returned by the gateway, by error code."),
+		[]string{"code"},
+	)
+	return []prometheus.Collector{ErrorsTotal}
+}
+
+// ObserveError 记录一次错误响应。
+func ObserveError(code string) {
+	ErrorsTotal.WithLabelValues(code).Inc()
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// genAITokenBuckets 与 genAIDurationBuckets 取自 OpenTelemetry GenAI 语义约定建议的分桶。
+var (
+	genAITokenBuckets    = []float64{1, 4, 16, 64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216, 67108864}
+	genAIDurationBuckets = []float64{0.01, 0.02, 0.04, 0.08, 0.16, 0.32, 0.64, 1.28, 2.56, 5.12, 10.24, 20.48, 40.96, 81.92}
+)
+
+var (
+	// GenAIClientTokenUsage 对应语义约定的 gen_ai.client.token.usage：每次调用的输入 / 输出 token 数分布。
+	GenAIClientTokenUsage *prometheus.HistogramVec
+
+	// GenAIClientOperationDuration 对应语义约定的 gen_ai.client.operation.duration：一次模型调用（含流式响应）的耗时。
+	GenAIClientOperationDuration *prometheus.HistogramVec
+)
+
+func buildGenAIMetrics(o Options) []prometheus.Collector {
+	GenAIClientTokenUsage = prometheus.NewHistogramVec(
+		o.histogramOpts("gen_ai_client_token_usage", "Number of input and output tokens used per GenAI operation.", genAITokenBuckets),
+		[]string{"gen_ai_operation_name", "gen_ai_system", "gen_ai_request_model", "gen_ai_response_model", "gen_ai_token_type"},
+	)
+	GenAIClientOperationDuration = prometheus.NewHistogramVec(
+		o.histogramOpts("gen_ai_client_operation_duration_seconds", "Duration of GenAI operations proxied to upstream providers.", genAIDurationBuckets),
+		[]string{"gen_ai_operation_name", "gen_ai_system", "gen_ai_request_model", "error_type"},
+	)
+	return []prometheus.Collector{GenAIClientTokenUsage, GenAIClientOperationDuration}
+}
+
+// ObserveGenAITokens 记录一次调用的输入与输出 token 数，token 数为 0 的类型不记录。
+// 只应在上游成功返回用量时调用，请求与响应模型据此获得独立标签（受 MaxModelLabels 限制）。
+func ObserveGenAITokens(operation, system, requestModel, responseModel string, input, output int64) {
+	requestModel, responseModel = modelLabel(requestModel, true), modelLabel(responseModel, true)
+	if input > 0 {
+		GenAIClientTokenUsage.WithLabelValues(operation, system, requestModel, responseModel, "input").Observe(float64(input))
+	}
+	if output > 0 {
+		GenAIClientTokenUsage.WithLabelValues(operation, system, requestModel, responseModel, "output").Observe(float64(output))
+	}
+}
+
+// ObserveGenAIDuration 记录一次调用的耗时，errorType 在成功时为空串；失败调用中尚未分配标签的模型计入 OtherModelLabel。
+func ObserveGenAIDuration(operation, system, requestModel, errorType string, seconds float64) {
+	requestModel = modelLabel(requestModel, errorType == "")
+	GenAIClientOperationDuration.WithLabelValues(operation, system, requestModel, errorType).Observe(seconds)
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// InflightRequests 按作用范围（proxy、admin）统计正在处理的请求数。
+	InflightRequests *prometheus.GaugeVec
+
+	// LoadShedTotal 按作用范围统计因超出 MAX_INFLIGHT_REQUESTS / ADMIN_MAX_INFLIGHT_REQUESTS 被拒绝的请求。
+	LoadShedTotal *prometheus.CounterVec
+)
+
+func buildInflightMetrics(o Options) []prometheus.Collector {
+	InflightRequests = prometheus.NewGaugeVec(
+		o.gaugeOpts("inflight_requests", "Number of requests currently being served, by scope."),
+		[]string{"scope"},
+	)
+	LoadShedTotal = prometheus.NewCounterVec(
+		o.counterOpts("load_shed_total", "Total number of requests rejected because the in-flight limit was reached, by scope."),
+		[]string{"scope"},
+	)
+	return []prometheus.Collector{InflightRequests, LoadShedTotal}
+}
+
+// ObserveInflight 按请求开始（+1）或结束（-1）调整作用范围内正在处理的请求数。
+func ObserveInflight(scope string, delta int) {
+	InflightRequests.WithLabelValues(scope).Add(float64(delta))
+}
+
+// ObserveLoadShed 记录一次因超出并发上限被拒绝的请求。
+func ObserveLoadShed(scope string) {
+	LoadShedTotal.WithLabelValues(scope).Inc()
+}
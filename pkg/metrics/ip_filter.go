@@ -0,0 +1,19 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// IPFilterRejectionsTotal 按作用范围（global、admin、proxy）与原因（denylist、not_allowlisted）统计被 IP 过滤拒绝的请求。
+var IPFilterRejectionsTotal *prometheus.CounterVec
+
+func buildIPFilterMetrics(o Options) []prometheus.Collector {
+	IPFilterRejectionsTotal = prometheus.NewCounterVec(
+		o.counterOpts("ip_filter_rejections_total", "Total number of requests rejected by the client IP filter, by scope and reason."),
+		[]string{"scope", "reason"},
+	)
+	return []prometheus.Collector{IPFilterRejectionsTotal}
+}
+
+// ObserveIPFilterRejection 记录一次被 IP 过滤拒绝的请求。
+func ObserveIPFilterRejection(scope, reason string) {
+	IPFilterRejectionsTotal.WithLabelValues(scope, reason).Inc()
+}
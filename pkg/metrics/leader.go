@@ -0,0 +1,37 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// Leader 为 1 时本实例是执行周期任务的领导者。
+	Leader prometheus.Gauge
+
+	// BackgroundJobRunsTotal 按任务与结果（success、error、skipped）统计周期任务的执行次数，
+	// skipped 表示本实例不是领导者而跳过。
+	BackgroundJobRunsTotal *prometheus.CounterVec
+)
+
+func buildLeaderMetrics(o Options) []prometheus.Collector {
+	Leader = prometheus.NewGauge(
+		o.gaugeOpts("leader", "Whether this instance currently holds leadership for background jobs (1) or not (0)."),
+	)
+	BackgroundJobRunsTotal = prometheus.NewCounterVec(
+		o.counterOpts("background_job_runs_total", "Total number of periodic background job runs, by job and result."),
+		[]string{"job", "result"},
+	)
+	return []prometheus.Collector{Leader, BackgroundJobRunsTotal}
+}
+
+// ObserveLeader 记录本实例是否为领导者。
+func ObserveLeader(leader bool) {
+	if leader {
+		Leader.Set(1)
+		return
+	}
+	Leader.Set(0)
+}
+
+// ObserveBackgroundJob 记录一次周期任务的执行结果。
+func ObserveBackgroundJob(job, result string) {
+	BackgroundJobRunsTotal.WithLabelValues(job, result).Inc()
+}
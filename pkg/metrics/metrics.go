@@ -1,45 +1,191 @@
 package metrics
 
 import (
+	"errors"
+	"fmt"
+	"slices"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// DefaultNamespace 是指标名称的默认前缀，与历史指标名（gateway_*）保持一致。
+const DefaultNamespace = "gateway"
+
+var (
+	// DefaultHTTPBuckets 是 HTTP 请求耗时直方图的默认分桶（秒）。
+	DefaultHTTPBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5}
+	// DefaultUpstreamBuckets 是上游调用耗时直方图的默认分桶（秒）。
+	DefaultUpstreamBuckets = []float64{0.02, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10}
+)
+
+// ErrInvalidOptions 表示指标配置无效。
+var ErrInvalidOptions = errors.New("invalid metrics options")
+
+// Options 控制指标名称前缀与直方图分桶，零值字段取默认值。
+// 指标全名为 namespace_subsystem_name，例如 gateway_http_requests_total。
+type Options struct {
+	Namespace       string
+	Subsystem       string
+	HTTPBuckets     []float64
+	UpstreamBuckets []float64
+	// MaxRuleLabels 限制按规则区分的指标中 rule 标签的取值数，超出的规则合并为 OtherRuleLabel，闲置的规则让出标签；为 0 时不限制。
+	MaxRuleLabels int
+	// MaxModelLabels 限制 gen_ai_* 指标中模型标签的取值数，超出或未被上游成功处理的模型合并为 OtherModelLabel；为 0 时不限制。
+	MaxModelLabels int
+}
+
+func (o Options) withDefaults() Options {
+	if o.Namespace == "" {
+		o.Namespace = DefaultNamespace
+	}
+	if len(o.HTTPBuckets) == 0 {
+		o.HTTPBuckets = DefaultHTTPBuckets
+	}
+	if len(o.UpstreamBuckets) == 0 {
+		o.UpstreamBuckets = DefaultUpstreamBuckets
+	}
+	return o
+}
+
+func (o Options) counterOpts(name, help string) prometheus.CounterOpts {
+	return prometheus.CounterOpts{Namespace: o.Namespace, Subsystem: o.Subsystem, Name: name, Help: help}
+}
+
+func (o Options) gaugeOpts(name, help string) prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{Namespace: o.Namespace, Subsystem: o.Subsystem, Name: name, Help: help}
+}
+
+func (o Options) histogramOpts(name, help string, buckets []float64) prometheus.HistogramOpts {
+	return prometheus.HistogramOpts{Namespace: o.Namespace, Subsystem: o.Subsystem, Name: name, Help: help, Buckets: buckets}
+}
+
+// builders 按配置创建各组指标并赋值给对应的包级变量。
+var builders = []func(Options) []prometheus.Collector{
+	buildHTTPMetrics,
+	buildRuleMetrics,
+	buildAdminMetrics,
+	buildUsageMetrics,
+	buildEndpointMetrics,
+	buildDNSMetrics,
+	buildWarmMetrics,
+	buildSLOMetrics,
+	buildAnalyticsMetrics,
+	buildErrorMetrics,
+	buildRedactionMetrics,
+	buildModerationMetrics,
+	buildContractMetrics,
+	buildResponseCacheMetrics,
+	buildRateLimitMetrics,
+	buildStreamMetrics,
+	buildDispatchMetrics,
+	buildBudgetMetrics,
+	buildGenAIMetrics,
+	buildTraceExportMetrics,
+	buildSmartRouteMetrics,
+	buildIPFilterMetrics,
+	buildInflightMetrics,
+	buildLeaderMetrics,
+}
+
+var state struct {
+	mu         sync.Mutex
+	options    Options
+	collectors []prometheus.Collector
+}
+
+func init() {
+	if err := Configure(Options{}); err != nil {
+		panic(err)
+	}
+}
+
+// Configure 按 opts 重新创建全部指标并注册到默认注册表，替换此前注册的指标。
+// 应在开始处理请求之前调用；配置无效时保留原有指标并返回错误。
+func Configure(opts Options) error {
+	opts = opts.withDefaults()
+	for _, buckets := range [][]float64{opts.HTTPBuckets, opts.UpstreamBuckets} {
+		if !slices.IsSorted(buckets) || len(slices.Compact(slices.Clone(buckets))) != len(buckets) {
+			return fmt.Errorf("%w: histogram buckets must be strictly increasing: %v", ErrInvalidOptions, buckets)
+		}
+	}
+	if opts.MaxRuleLabels < 0 {
+		return fmt.Errorf("%w: max rule labels must not be negative", ErrInvalidOptions)
+	}
+	if opts.MaxModelLabels < 0 {
+		return fmt.Errorf("%w: max model labels must not be negative", ErrInvalidOptions)
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	previous := state.collectors
+	unregister(previous)
+	collectors, err := register(opts)
+	if err != nil {
+		if previous != nil {
+			state.collectors, _ = register(state.options)
+		}
+		return fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+	state.options, state.collectors = opts, collectors
+	resetRuleLabels(opts.MaxRuleLabels)
+	resetModelLabels(opts.MaxModelLabels)
+	return nil
+}
+
+// Name 返回按当前配置拼接前缀后的指标全名，例如 Name("http_requests_total") 默认为 gateway_http_requests_total。
+func Name(name string) string {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return prometheus.BuildFQName(state.options.Namespace, state.options.Subsystem, name)
+}
+
+func register(opts Options) ([]prometheus.Collector, error) {
+	var collectors []prometheus.Collector
+	for _, build := range builders {
+		collectors = append(collectors, build(opts)...)
+	}
+	for i, collector := range collectors {
+		if err := prometheus.Register(collector); err != nil {
+			unregister(collectors[:i])
+			return nil, err
+		}
+	}
+	return collectors, nil
+}
+
+func unregister(collectors []prometheus.Collector) {
+	for _, collector := range collectors {
+		prometheus.Unregister(collector)
+	}
+}
+
 var (
 	// HTTPRequestsTotal 统计网关接收的 HTTP 请求总数，按照方法、路由与状态码区分。
+	HTTPRequestsTotal *prometheus.CounterVec
+
+	// HTTPRequestDuration 记录 HTTP 请求处理耗时，用于 SLI 统计。
+	HTTPRequestDuration *prometheus.HistogramVec
+
+	// UpstreamLatency 统计与上游 LLM 服务交互的耗时及结果。
+	UpstreamLatency *prometheus.HistogramVec
+)
+
+func buildHTTPMetrics(o Options) []prometheus.Collector {
 	HTTPRequestsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "gateway_http_requests_total",
-			Help: "Total number of HTTP requests processed by the gateway.",
-		},
+		o.counterOpts("http_requests_total", "Total number of HTTP requests processed by the gateway."),
 		[]string{"method", "route", "status"},
 	)
-
-	// HTTPRequestDuration 记录 HTTP 请求处理耗时，用于 SLI 统计。
 	HTTPRequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "gateway_http_request_duration_seconds",
-			Help:    "Histogram of HTTP request latencies in seconds.",
-			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5},
-		},
+		o.histogramOpts("http_request_duration_seconds", "Histogram of HTTP request latencies in seconds.", o.HTTPBuckets),
 		[]string{"method", "route"},
 	)
-
-	// UpstreamLatency 统计与上游 LLM 服务交互的耗时及结果。
 	UpstreamLatency = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "gateway_upstream_latency_seconds",
-			Help:    "Latency histogram for upstream LLM requests.",
-			Buckets: []float64{0.02, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10},
-		},
+		o.histogramOpts("upstream_latency_seconds", "Latency histogram for upstream LLM requests.", o.UpstreamBuckets),
 		[]string{"upstream", "outcome", "status"},
 	)
-)
-
-func init() {
-	prometheus.MustRegister(HTTPRequestsTotal, HTTPRequestDuration, UpstreamLatency)
+	return []prometheus.Collector{HTTPRequestsTotal, HTTPRequestDuration, UpstreamLatency}
 }
 
 // ObserveHTTPRequest 记录一次网关 HTTP 请求的处理情况。
@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func findFamily(t *testing.T, name string) *dto.MetricFamily {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == name {
+			return family
+		}
+	}
+	return nil
+}
+
+func TestConfigure_NamespaceSubsystemAndBuckets(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, Configure(Options{})) })
+
+	require.NoError(t, Configure(Options{
+		Namespace:       "yapi",
+		Subsystem:       "edge",
+		HTTPBuckets:     []float64{0.1, 0.3, 1.2},
+		UpstreamBuckets: []float64{1, 10},
+	}))
+	ObserveHTTPRequest("GET", "/v1/models", 200, 200*time.Millisecond)
+	ObserveUpstream("api.example.com", 200, 2*time.Second, false)
+	ObserveRuleMatch("r-1")
+
+	require.Equal(t, "yapi_edge_http_requests_total", Name("http_requests_total"))
+	require.Nil(t, findFamily(t, "gateway_http_request_duration_seconds"))
+	require.NotNil(t, findFamily(t, "yapi_edge_rule_matches_total"))
+	family := findFamily(t, "yapi_edge_http_request_duration_seconds")
+	require.NotNil(t, family)
+	var bounds []float64
+	for _, bucket := range family.GetMetric()[0].GetHistogram().GetBucket() {
+		bounds = append(bounds, bucket.GetUpperBound())
+	}
+	require.Equal(t, []float64{0.1, 0.3, 1.2}, bounds)
+	upstream := findFamily(t, "yapi_edge_upstream_latency_seconds")
+	require.NotNil(t, upstream)
+	require.Len(t, upstream.GetMetric()[0].GetHistogram().GetBucket(), 2)
+}
+
+func TestConfigure_RejectsInvalidOptionsAndKeepsMetrics(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, Configure(Options{})) })
+	require.NoError(t, Configure(Options{}))
+
+	require.ErrorIs(t, Configure(Options{HTTPBuckets: []float64{1, 0.5}}), ErrInvalidOptions)
+	require.ErrorIs(t, Configure(Options{UpstreamBuckets: []float64{1, 1}}), ErrInvalidOptions)
+	require.ErrorIs(t, Configure(Options{Namespace: "bad-name"}), ErrInvalidOptions)
+
+	require.Equal(t, "gateway_admin_actions_total", Name("admin_actions_total"))
+	ObserveAdminAction("rules.create", true)
+	require.NotNil(t, findFamily(t, "gateway_admin_actions_total"))
+}
+
+func TestConfigure_MaxRuleLabelsFoldsExtraRulesIntoOther(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, Configure(Options{})) })
+	require.ErrorIs(t, Configure(Options{MaxRuleLabels: -1}), ErrInvalidOptions)
+	require.NoError(t, Configure(Options{MaxRuleLabels: 2}))
+	statsBefore := RuleMatchStats("r-3").Matches
+
+	for _, rule := range []string{"r-1", "r-2", "r-3", "r-1", "r-4"} {
+		ObserveRuleMatch(rule)
+	}
+	ObserveSLO("r-2", true, true)
+	ObserveSLO("r-5", false, false)
+
+	matches := map[string]float64{}
+	for _, metric := range findFamily(t, "gateway_rule_matches_total").GetMetric() {
+		matches[metric.GetLabel()[0].GetValue()] = metric.GetCounter().GetValue()
+	}
+	require.Equal(t, map[string]float64{"r-1": 2, "r-2": 1, OtherRuleLabel: 2}, matches)
+	require.Equal(t, statsBefore+1, RuleMatchStats("r-3").Matches, "admin match stats keep the real rule ID")
+
+	var sloRules []string
+	for _, metric := range findFamily(t, "gateway_slo_requests_total").GetMetric() {
+		for _, label := range metric.GetLabel() {
+			if label.GetName() == "rule" {
+				sloRules = append(sloRules, label.GetValue())
+			}
+		}
+	}
+	require.ElementsMatch(t, []string{"r-2", "r-2", OtherRuleLabel}, sloRules)
+
+	// 重新配置时清空已分配的标签。
+	require.NoError(t, Configure(Options{MaxRuleLabels: 1}))
+	ObserveRuleMatch("r-3")
+	require.Equal(t, "r-3", findFamily(t, "gateway_rule_matches_total").GetMetric()[0].GetLabel()[0].GetValue())
+}
+
+func TestConfigure_IdleRulesYieldLabels(t *testing.T) {
+	now := time.Unix(0, 0)
+	ruleLabels.now = func() time.Time { return now }
+	t.Cleanup(func() {
+		ruleLabels.now = nil
+		require.NoError(t, Configure(Options{}))
+	})
+	require.NoError(t, Configure(Options{MaxRuleLabels: 1}))
+
+	ruleMatches := func() map[string]float64 {
+		matches := map[string]float64{}
+		for _, metric := range findFamily(t, "gateway_rule_matches_total").GetMetric() {
+			matches[metric.GetLabel()[0].GetValue()] = metric.GetCounter().GetValue()
+		}
+		return matches
+	}
+	ObserveRuleMatch("stale")
+	ObserveSmartRoute("stale", "openai", "cheapest")
+	ObserveRuleMatch("live")
+	require.Equal(t, map[string]float64{"stale": 1, OtherRuleLabel: 1}, ruleMatches())
+
+	// 闲置超过期限的规则让出标签，其序列一并删除。
+	now = now.Add(ruleLabelIdle)
+	ObserveRuleMatch("live")
+	require.Equal(t, map[string]float64{"live": 1, OtherRuleLabel: 1}, ruleMatches())
+	require.Nil(t, findFamily(t, "gateway_smart_route_decisions_total"))
+
+	// 持续有流量的规则不会被替换。
+	now = now.Add(ruleLabelIdle / 2)
+	ObserveRuleMatch("live")
+	now = now.Add(ruleLabelIdle / 2)
+	ObserveRuleMatch("new")
+	require.Equal(t, map[string]float64{"live": 2, OtherRuleLabel: 2}, ruleMatches())
+}
+
+func TestObserveRuleMatch_BoundsRuleStats(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, Configure(Options{})) })
+	require.NoError(t, Configure(Options{MaxRuleLabels: 1}))
+	ObserveRuleMatch("bounded-first")
+	for i := 0; i < maxRuleStats; i++ {
+		ObserveRuleMatch(fmt.Sprintf("bounded-%d", i))
+	}
+	ruleStats.mu.RLock()
+	size := len(ruleStats.stats)
+	ruleStats.mu.RUnlock()
+	require.LessOrEqual(t, size, maxRuleStats)
+	require.Zero(t, RuleMatchStats("bounded-first").Matches)
+	require.Equal(t, uint64(1), RuleMatchStats(fmt.Sprintf("bounded-%d", maxRuleStats-1)).Matches)
+}
+
+func TestConfigure_MaxModelLabelsOnlyAdmitsAcceptedModels(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, Configure(Options{})) })
+	require.ErrorIs(t, Configure(Options{MaxModelLabels: -1}), ErrInvalidOptions)
+	require.NoError(t, Configure(Options{MaxModelLabels: 2}))
+
+	// 失败请求里的任意模型名不分配标签；成功过的模型之后失败时仍保留原标签。
+	ObserveGenAIDuration("chat", "openai", "made-up-1", "404", 0.1)
+	ObserveGenAIDuration("chat", "openai", "gpt-4o", "", 0.1)
+	ObserveGenAIDuration("chat", "openai", "gpt-4o", "500", 0.1)
+	ObserveGenAITokens("chat", "openai", "gpt-4o-mini", "gpt-4o-mini-2024-07-18", 10, 5)
+	ObserveGenAIDuration("chat", "openai", "o3", "", 0.1)
+
+	models := map[string]bool{}
+	for _, name := range []string{"gateway_gen_ai_client_operation_duration_seconds", "gateway_gen_ai_client_token_usage"} {
+		for _, metric := range findFamily(t, name).GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "gen_ai_request_model" || label.GetName() == "gen_ai_response_model" {
+					models[label.GetValue()] = true
+				}
+			}
+		}
+	}
+	require.Equal(t, map[string]bool{"gpt-4o": true, "gpt-4o-mini": true, OtherModelLabel: true}, models)
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import "time"
+
+// OtherModelLabel 是未获准使用独立标签的模型在 gen_ai_* 指标中共用的模型标签值。
+const OtherModelLabel = "other"
+
+// modelLabels 记录已分配独立标签的模型。请求中的模型名由客户端决定，只有上游成功处理过的模型才分配标签，
+// 且总数不超过 Options.MaxModelLabels。
+var modelLabels = &labelSet{other: OtherModelLabel, seen: make(map[string]time.Time)}
+
+// resetModelLabels 设置模型标签上限并清空已分配的标签，随 Configure 重建指标时调用。
+func resetModelLabels(max int) {
+	modelLabels.reset(max)
+}
+
+// modelLabel 返回模型在 gen_ai_* 指标中的标签值。空模型名原样返回；accepted 表示上游已成功处理该模型的请求，
+// 为 false 时尚未分配标签的模型计入 OtherModelLabel，客户端无法以失败请求中的任意模型名制造新序列。
+func modelLabel(model string, accepted bool) string {
+	if model == "" {
+		return ""
+	}
+	return modelLabels.label(model, accepted)
+}
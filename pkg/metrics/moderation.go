@@ -0,0 +1,19 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// ModerationChecksTotal 按规则、审核阶段与结果统计内容审核调用。
+var ModerationChecksTotal *prometheus.CounterVec
+
+func buildModerationMetrics(o Options) []prometheus.Collector {
+	ModerationChecksTotal = prometheus.NewCounterVec(
+		o.counterOpts("moderation_checks_total", "Total number of content moderation checks, by rule, stage and outcome."),
+		[]string{"rule", "stage", "outcome"},
+	)
+	return []prometheus.Collector{ModerationChecksTotal}
+}
+
+// ObserveModeration 记录一次内容审核，outcome 为 passed、flagged 或 error。
+func ObserveModeration(ruleID, stage, outcome string) {
+	ModerationChecksTotal.WithLabelValues(ruleLabel(ruleID), stage, outcome).Inc()
+}
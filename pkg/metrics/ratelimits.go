@@ -0,0 +1,33 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// UpstreamRateLimitRemaining 是上游凭据最近一次响应报告的剩余额度，limit 为 requests 或 tokens。
+	UpstreamRateLimitRemaining *prometheus.GaugeVec
+
+	// UpstreamRateLimitedTotal 统计上游凭据被判定为额度耗尽（429 或剩余额度为 0）的次数。
+	UpstreamRateLimitedTotal *prometheus.CounterVec
+)
+
+func buildRateLimitMetrics(o Options) []prometheus.Collector {
+	UpstreamRateLimitRemaining = prometheus.NewGaugeVec(
+		o.gaugeOpts("upstream_ratelimit_remaining", "Remaining upstream quota reported by provider rate-limit headers, by credential and limit."),
+		[]string{"credential", "limit"},
+	)
+	UpstreamRateLimitedTotal = prometheus.NewCounterVec(
+		o.counterOpts("upstream_ratelimited_total", "Total number of times an upstream credential was marked exhausted."),
+		[]string{"credential"},
+	)
+	return []prometheus.Collector{UpstreamRateLimitRemaining, UpstreamRateLimitedTotal}
+}
+
+// ObserveUpstreamRemaining 更新上游凭据的剩余额度。
+func ObserveUpstreamRemaining(credentialID, limit string, remaining int) {
+	UpstreamRateLimitRemaining.WithLabelValues(credentialID, limit).Set(float64(remaining))
+}
+
+// ObserveUpstreamRateLimited 记录一次上游凭据额度耗尽。
+func ObserveUpstreamRateLimited(credentialID string) {
+	UpstreamRateLimitedTotal.WithLabelValues(credentialID).Inc()
+}
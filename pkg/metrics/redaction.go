@@ -0,0 +1,19 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// RedactionsTotal 按规则、检测器与处理方式统计请求体中命中的敏感信息。
+var RedactionsTotal *prometheus.CounterVec
+
+func buildRedactionMetrics(o Options) []prometheus.Collector {
+	RedactionsTotal = prometheus.NewCounterVec(
+		o.counterOpts("redactions_total", "Total number of sensitive values detected in request bodies, by rule, detector and action."),
+		[]string{"rule", "detector", "action"},
+	)
+	return []prometheus.Collector{RedactionsTotal}
+}
+
+// ObserveRedactions 记录一次请求中某个检测器的命中次数，action 为 mask 或 reject。
+func ObserveRedactions(ruleID, detector, action string, count int) {
+	RedactionsTotal.WithLabelValues(ruleLabel(ruleID), detector, action).Add(float64(count))
+}
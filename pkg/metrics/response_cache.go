@@ -0,0 +1,19 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// ResponseCacheTotal 按规则与结果统计响应缓存查询。
+var ResponseCacheTotal *prometheus.CounterVec
+
+func buildResponseCacheMetrics(o Options) []prometheus.Collector {
+	ResponseCacheTotal = prometheus.NewCounterVec(
+		o.counterOpts("response_cache_total", "Total number of response cache lookups, by rule and result."),
+		[]string{"rule", "result"},
+	)
+	return []prometheus.Collector{ResponseCacheTotal}
+}
+
+// ObserveResponseCache 记录一次响应缓存查询，result 为 hit 或 miss。
+func ObserveResponseCache(ruleID, result string) {
+	ResponseCacheTotal.WithLabelValues(ruleLabel(ruleID), result).Inc()
+}
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// OtherRuleLabel 是超出 Options.MaxRuleLabels 的规则在按规则区分的指标中共用的 rule 标签值。
+const OtherRuleLabel = "other"
+
+// ruleLabelIdle 是规则标签的闲置期限：标签已满时，超过该时长没有流量的规则（通常已删除或停用）让出标签，
+// 其在各指标中的序列一并删除，仍有流量的规则得以使用独立标签。
+const ruleLabelIdle = 15 * time.Minute
+
+// labelSet 为一个标签维度分配取值：已分配的取值保留原值，其余合并为 other，
+// 防止来自规则或客户端请求的取值使指标序列数无限增长。idle 大于 0 时，标签已满后闲置超过 idle 的取值
+// 让出位置并交给 evict 删除其序列；idle 为 0 时最先出现的 max 个取值一直保留。
+type labelSet struct {
+	mu    sync.Mutex
+	max   int
+	other string
+	idle  time.Duration
+	evict func(value string)
+	now   func() time.Time
+	// seen 记录已分配的取值及其最近一次出现的时间。
+	seen map[string]time.Time
+	// oldest 是 seen 中最早出现时间的下界，未到闲置期限时跳过扫描。
+	oldest time.Time
+}
+
+// reset 设置取值上限并清空已分配的取值，随 Configure 重建指标时调用。
+func (s *labelSet) reset(max int) {
+	s.mu.Lock()
+	s.max = max
+	s.seen = make(map[string]time.Time)
+	s.oldest = time.Time{}
+	s.mu.Unlock()
+}
+
+// label 返回 value 在指标中的标签值：上限为 0 时不限制；已分配的取值原样返回；admit 为 false，
+// 或已达上限且没有可让出的闲置取值时，未分配的取值合并为 other。
+func (s *labelSet) label(value string, admit bool) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.max <= 0 {
+		return value
+	}
+	now := s.clock()
+	if _, ok := s.seen[value]; ok {
+		s.seen[value] = now
+		return value
+	}
+	if !admit || (len(s.seen) >= s.max && !s.evictIdle(now)) {
+		return s.other
+	}
+	s.seen[value] = now
+	return value
+}
+
+// evictIdle 让出最久未出现且闲置超过 idle 的取值，没有可让出的取值时返回 false。调用方需持有锁。
+func (s *labelSet) evictIdle(now time.Time) bool {
+	if s.idle <= 0 || now.Sub(s.oldest) < s.idle {
+		return false
+	}
+	var victim string
+	var oldest time.Time
+	for value, last := range s.seen {
+		if oldest.IsZero() || last.Before(oldest) {
+			victim, oldest = value, last
+		}
+	}
+	s.oldest = oldest
+	if now.Sub(oldest) < s.idle {
+		return false
+	}
+	delete(s.seen, victim)
+	if s.evict != nil {
+		s.evict(victim)
+	}
+	return true
+}
+
+func (s *labelSet) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// ruleLabels 记录已分配独立标签的规则，防止批量创建规则的脚本使按规则区分的指标序列数无限增长。
+var ruleLabels = &labelSet{other: OtherRuleLabel, idle: ruleLabelIdle, evict: deleteRuleSeries, seen: make(map[string]time.Time)}
+
+// resetRuleLabels 设置规则标签上限并清空已分配的标签，随 Configure 重建指标时调用。
+func resetRuleLabels(max int) {
+	ruleLabels.reset(max)
+}
+
+// ruleLabel 返回规则在指标中的 rule 标签值：上限为 0 时不限制；否则至多 MaxRuleLabels 条规则使用规则 ID，
+// 其余规则合并为 OtherRuleLabel，闲置超过 ruleLabelIdle 的规则在标签已满时让出位置。
+func ruleLabel(ruleID string) string {
+	return ruleLabels.label(ruleID, true)
+}
+
+// deleteRuleSeries 删除让出标签的规则在各按规则区分的指标中的序列。
+func deleteRuleSeries(ruleID string) {
+	for _, series := range []struct {
+		vec   *prometheus.CounterVec
+		label string
+	}{
+		{RuleMatchesTotal, "rule"},
+		{SLORequestsTotal, "rule"},
+		{ResponseContractViolationsTotal, "rule"},
+		{ModerationChecksTotal, "rule"},
+		{RedactionsTotal, "rule"},
+		{ResponseCacheTotal, "rule"},
+		{StreamIdleTimeoutsTotal, "rule"},
+		{SmartRouteDecisionsTotal, "rule_id"},
+	} {
+		if series.vec != nil {
+			series.vec.DeletePartialMatch(prometheus.Labels{series.label: ruleID})
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RuleMatchesTotal 统计各规则命中次数。
+var RuleMatchesTotal *prometheus.CounterVec
+
+func buildRuleMetrics(o Options) []prometheus.Collector {
+	RuleMatchesTotal = prometheus.NewCounterVec(
+		o.counterOpts("rule_matches_total", "Total number of proxied requests matched by each rule."),
+		[]string{"rule"},
+	)
+	return []prometheus.Collector{RuleMatchesTotal}
+}
+
+// RuleMatchStat 是本实例自启动以来某条规则的命中统计。
+type RuleMatchStat struct {
+	Matches       uint64
+	LastMatchedAt time.Time
+}
+
+// maxRuleStats 限制保留命中统计的规则数，超出时淘汰最久未命中的规则，已删除的规则不会一直占用内存。
+const maxRuleStats = 10000
+
+var ruleStats = struct {
+	mu    sync.RWMutex
+	stats map[string]RuleMatchStat
+}{stats: make(map[string]RuleMatchStat)}
+
+// ObserveRuleMatch 记录一次规则命中。
+func ObserveRuleMatch(ruleID string) {
+	RuleMatchesTotal.WithLabelValues(ruleLabel(ruleID)).Inc()
+	ruleStats.mu.Lock()
+	stat, ok := ruleStats.stats[ruleID]
+	if !ok && len(ruleStats.stats) >= maxRuleStats {
+		evictOldestRuleStat()
+	}
+	stat.Matches++
+	stat.LastMatchedAt = time.Now()
+	ruleStats.stats[ruleID] = stat
+	ruleStats.mu.Unlock()
+}
+
+// evictOldestRuleStat 删除最久未命中的规则统计。调用方需持有写锁。
+func evictOldestRuleStat() {
+	var oldestID string
+	var oldest time.Time
+	for id, stat := range ruleStats.stats {
+		if oldestID == "" || stat.LastMatchedAt.Before(oldest) {
+			oldestID, oldest = id, stat.LastMatchedAt
+		}
+	}
+	delete(ruleStats.stats, oldestID)
+}
+
+// RuleMatchStats 返回规则在本实例的命中统计，未命中过或统计已被淘汰时返回零值。
+func RuleMatchStats(ruleID string) RuleMatchStat {
+	ruleStats.mu.RLock()
+	defer ruleStats.mu.RUnlock()
+	return ruleStats.stats[ruleID]
+}
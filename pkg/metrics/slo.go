@@ -0,0 +1,31 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// SLORequestsTotal 按规则统计代理请求在各 SLO 下的好/坏结果，
+// slo 为 availability（5xx 为坏）或 latency（响应头耗时超过阈值为坏，仅统计可用请求）。
+var SLORequestsTotal *prometheus.CounterVec
+
+func buildSLOMetrics(o Options) []prometheus.Collector {
+	SLORequestsTotal = prometheus.NewCounterVec(
+		o.counterOpts("slo_requests_total", "Total number of proxied requests classified as good or bad against availability and latency SLOs, by rule."),
+		[]string{"rule", "slo", "outcome"},
+	)
+	return []prometheus.Collector{SLORequestsTotal}
+}
+
+// ObserveSLO 记录一次代理请求的 SLO 结果；不可用的请求不计入延迟 SLO。
+func ObserveSLO(rule string, available, fast bool) {
+	rule = ruleLabel(rule)
+	SLORequestsTotal.WithLabelValues(rule, "availability", sloOutcome(available)).Inc()
+	if available {
+		SLORequestsTotal.WithLabelValues(rule, "latency", sloOutcome(fast)).Inc()
+	}
+}
+
+func sloOutcome(good bool) string {
+	if good {
+		return "good"
+	}
+	return "bad"
+}
@@ -0,0 +1,20 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// SmartRouteDecisionsTotal 按规则、选中的服务与依据统计 smart_route 的选择：cheapest、fastest、sticky（沿用粘性选择），
+// 没有健康候选时 service 为空、reason 为 unavailable。
+var SmartRouteDecisionsTotal *prometheus.CounterVec
+
+func buildSmartRouteMetrics(o Options) []prometheus.Collector {
+	SmartRouteDecisionsTotal = prometheus.NewCounterVec(
+		o.counterOpts("smart_route_decisions_total", "Total number of smart routing decisions, by rule, selected service and reason."),
+		[]string{"rule_id", "service", "reason"},
+	)
+	return []prometheus.Collector{SmartRouteDecisionsTotal}
+}
+
+// ObserveSmartRoute 记录一次 smart_route 选择。
+func ObserveSmartRoute(ruleID, service, reason string) {
+	SmartRouteDecisionsTotal.WithLabelValues(ruleLabel(ruleID), service, reason).Inc()
+}
@@ -0,0 +1,19 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// StreamIdleTimeoutsTotal 按规则统计因上游静默超时而被网关结束的 SSE 响应。
+var StreamIdleTimeoutsTotal *prometheus.CounterVec
+
+func buildStreamMetrics(o Options) []prometheus.Collector {
+	StreamIdleTimeoutsTotal = prometheus.NewCounterVec(
+		o.counterOpts("stream_idle_timeouts_total", "Total number of streaming responses terminated after the upstream stayed idle, by rule."),
+		[]string{"rule"},
+	)
+	return []prometheus.Collector{StreamIdleTimeoutsTotal}
+}
+
+// ObserveStreamIdleTimeout 记录一次 SSE 响应的空闲超时。
+func ObserveStreamIdleTimeout(ruleID string) {
+	StreamIdleTimeoutsTotal.WithLabelValues(ruleLabel(ruleID)).Inc()
+}
@@ -0,0 +1,19 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// TraceExportsTotal 按平台与结果统计导出到 Langfuse / LangSmith 的记录：success、error 或 dropped（队列已满）。
+var TraceExportsTotal *prometheus.CounterVec
+
+func buildTraceExportMetrics(o Options) []prometheus.Collector {
+	TraceExportsTotal = prometheus.NewCounterVec(
+		o.counterOpts("trace_exports_total", "Total number of prompt/completion records exported to LLM observability platforms, by provider and outcome."),
+		[]string{"provider", "outcome"},
+	)
+	return []prometheus.Collector{TraceExportsTotal}
+}
+
+// ObserveTraceExport 记录 count 条记录的导出结果。
+func ObserveTraceExport(provider, outcome string, count int) {
+	TraceExportsTotal.WithLabelValues(provider, outcome).Add(float64(count))
+}
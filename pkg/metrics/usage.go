@@ -0,0 +1,36 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// TokensTotal 统计上游响应中报告的 token 用量，type 为 prompt 或 completion。
+	TokensTotal *prometheus.CounterVec
+
+	// CostUSDTotal 按模型价格表估算的调用费用（美元）。
+	CostUSDTotal *prometheus.CounterVec
+)
+
+func buildUsageMetrics(o Options) []prometheus.Collector {
+	TokensTotal = prometheus.NewCounterVec(
+		o.counterOpts("tokens_total", "Total number of LLM tokens reported by upstream responses, by model, provider, user and type."),
+		[]string{"model", "provider", "user", "type"},
+	)
+	CostUSDTotal = prometheus.NewCounterVec(
+		o.counterOpts("cost_usd_total", "Estimated cost in USD of proxied LLM requests, by model, provider and user."),
+		[]string{"model", "provider", "user"},
+	)
+	return []prometheus.Collector{TokensTotal, CostUSDTotal}
+}
+
+// ObserveUsage 记录一次上游调用的 token 用量与估算费用。cost 为 0 时（模型未定价）不累加费用指标。
+func ObserveUsage(model, provider, user string, promptTokens, completionTokens int64, cost float64) {
+	if promptTokens > 0 {
+		TokensTotal.WithLabelValues(model, provider, user, "prompt").Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		TokensTotal.WithLabelValues(model, provider, user, "completion").Add(float64(completionTokens))
+	}
+	if cost > 0 {
+		CostUSDTotal.WithLabelValues(model, provider, user).Add(cost)
+	}
+}
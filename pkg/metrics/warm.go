@@ -0,0 +1,19 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// UpstreamWarmupsTotal 按上游主机与结果（success、error）统计连接预热请求。
+var UpstreamWarmupsTotal *prometheus.CounterVec
+
+func buildWarmMetrics(o Options) []prometheus.Collector {
+	UpstreamWarmupsTotal = prometheus.NewCounterVec(
+		o.counterOpts("upstream_warmups_total", "Total number of upstream connection warm-up requests, by target host and result."),
+		[]string{"target", "result"},
+	)
+	return []prometheus.Collector{UpstreamWarmupsTotal}
+}
+
+// ObserveUpstreamWarm 记录一次连接预热请求。
+func ObserveUpstreamWarm(target, result string) {
+	UpstreamWarmupsTotal.WithLabelValues(target, result).Inc()
+}
@@ -21,13 +21,13 @@ type Cache interface {
 
 // RedisCache 使用 Redis 作为规则缓存。
 type RedisCache struct {
-	client *redis.Client
+	client redis.UniversalClient
 	key    string
 	ttl    time.Duration
 }
 
 // NewRedisCache 创建 Redis 缓存适配器。
-func NewRedisCache(client *redis.Client, key string, ttl time.Duration) *RedisCache {
+func NewRedisCache(client redis.UniversalClient, key string, ttl time.Duration) *RedisCache {
 	return &RedisCache{
 		client: client,
 		key:    key,
@@ -3,6 +3,7 @@ package rules
 import (
 	"context"
 	"errors"
+	"sync"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -13,6 +14,10 @@ type Event string
 const (
 	// EventRulesChanged 当规则发生增删改时发布。
 	EventRulesChanged Event = "rules_changed"
+	// EventAccountsChanged 当用户、API Key、上游凭据、Key 池或绑定发生变更时由管理端发布。
+	EventAccountsChanged Event = "accounts_changed"
+	// EventModelAliasesChanged 当模型别名发生增删改时由别名服务发布。
+	EventModelAliasesChanged Event = "model_aliases_changed"
 )
 
 // EventBus 用于广播和订阅规则变更。
@@ -23,12 +28,12 @@ type EventBus interface {
 
 // RedisEventBus 基于 Redis Pub/Sub 的事件总线。
 type RedisEventBus struct {
-	client  *redis.Client
+	client  redis.UniversalClient
 	channel string
 }
 
 // NewRedisEventBus 创建事件总线。
-func NewRedisEventBus(client *redis.Client, channel string) *RedisEventBus {
+func NewRedisEventBus(client redis.UniversalClient, channel string) *RedisEventBus {
 	return &RedisEventBus{client: client, channel: channel}
 }
 
@@ -44,6 +49,7 @@ func (b *RedisEventBus) Subscribe(ctx context.Context) (<-chan Event, error) {
 	ch := make(chan Event)
 	go func() {
 		defer close(ch)
+		defer sub.Close()
 		for {
 			msg, err := sub.ReceiveMessage(ctx)
 			if err != nil {
@@ -53,8 +59,54 @@ func (b *RedisEventBus) Subscribe(ctx context.Context) (<-chan Event, error) {
 				// 订阅失败时退出，由调用方决定是否重试。
 				return
 			}
-			ch <- Event(msg.Payload)
+			select {
+			case ch <- Event(msg.Payload):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// memoryEventBufferSize 是每个内存订阅者的缓冲长度，缓冲写满时丢弃新事件而不阻塞发布方。
+const memoryEventBufferSize = 16
+
+// MemoryEventBus 是进程内的事件总线，适用于未配置 Redis 的单实例部署。
+type MemoryEventBus struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+// NewMemoryEventBus 创建进程内事件总线。
+func NewMemoryEventBus() *MemoryEventBus {
+	return &MemoryEventBus{subscribers: make(map[chan Event]struct{})}
+}
+
+func (b *MemoryEventBus) Publish(ctx context.Context, evt Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- evt:
+		default:
 		}
+	}
+	return nil
+}
+
+// Subscribe 注册订阅者，ctx 结束时自动注销并关闭通道。
+func (b *MemoryEventBus) Subscribe(ctx context.Context) (<-chan Event, error) {
+	ch := make(chan Event, memoryEventBufferSize)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+		close(ch)
 	}()
 	return ch, nil
 }
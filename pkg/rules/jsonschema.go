@@ -0,0 +1,186 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidJSONSchema 表示 JSON Schema 使用了不支持的关键字或取值不合法。
+var ErrInvalidJSONSchema = errors.New("invalid json schema")
+
+// JSONSchemaTypes 列出 type 关键字支持的取值。
+var JSONSchemaTypes = []string{"object", "array", "string", "number", "integer", "boolean", "null"}
+
+// jsonSchemaKeywords 是支持的 JSON Schema 子集：结构约束 type、properties、required、items、enum、
+// additionalProperties（仅布尔值），以及不参与校验的注释关键字。
+var jsonSchemaKeywords = map[string]bool{
+	"type": true, "properties": true, "required": true, "items": true, "enum": true, "additionalProperties": true,
+	"$schema": false, "$id": false, "title": false, "description": false, "examples": false, "default": false,
+}
+
+// CheckJSONSchema 检查 schema 只使用了支持的关键字，避免不受支持的约束被静默忽略。
+func CheckJSONSchema(schema map[string]any) error {
+	return checkJSONSchema(schema, "$")
+}
+
+func checkJSONSchema(schema map[string]any, at string) error {
+	for keyword, value := range schema {
+		if _, ok := jsonSchemaKeywords[keyword]; !ok {
+			return fmt.Errorf("%w: %s: unsupported keyword %q", ErrInvalidJSONSchema, at, keyword)
+		}
+		switch keyword {
+		case "type":
+			types, ok := schemaTypes(value)
+			if !ok || len(types) == 0 {
+				return fmt.Errorf("%w: %s: type must be a string or an array of strings", ErrInvalidJSONSchema, at)
+			}
+			for _, t := range types {
+				if !slices.Contains(JSONSchemaTypes, t) {
+					return fmt.Errorf("%w: %s: type %q must be one of %s", ErrInvalidJSONSchema, at, t, strings.Join(JSONSchemaTypes, ", "))
+				}
+			}
+		case "properties":
+			properties, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%w: %s: properties must be an object", ErrInvalidJSONSchema, at)
+			}
+			for name, sub := range properties {
+				subSchema, ok := sub.(map[string]any)
+				if !ok {
+					return fmt.Errorf("%w: %s.%s: schema must be an object", ErrInvalidJSONSchema, at, name)
+				}
+				if err := checkJSONSchema(subSchema, at+"."+name); err != nil {
+					return err
+				}
+			}
+		case "items":
+			items, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%w: %s: items must be an object", ErrInvalidJSONSchema, at)
+			}
+			if err := checkJSONSchema(items, at+"[]"); err != nil {
+				return err
+			}
+		case "required":
+			if _, ok := stringList(value); !ok {
+				return fmt.Errorf("%w: %s: required must be an array of strings", ErrInvalidJSONSchema, at)
+			}
+		case "enum":
+			if values, ok := value.([]any); !ok || len(values) == 0 {
+				return fmt.Errorf("%w: %s: enum must be a non-empty array", ErrInvalidJSONSchema, at)
+			}
+		case "additionalProperties":
+			if _, ok := value.(bool); !ok {
+				return fmt.Errorf("%w: %s: additionalProperties must be a boolean", ErrInvalidJSONSchema, at)
+			}
+		}
+	}
+	return nil
+}
+
+// MatchJSONSchema 按 CheckJSONSchema 支持的子集校验 value（encoding/json 解码的结果），
+// 返回按位置排序的违规描述，为空表示符合。
+func MatchJSONSchema(schema map[string]any, value any) []string {
+	var violations []string
+	matchJSONSchema(schema, value, "$", &violations)
+	sort.Strings(violations)
+	return violations
+}
+
+func matchJSONSchema(schema map[string]any, value any, at string, violations *[]string) {
+	if types, ok := schemaTypes(schema["type"]); ok && !slices.ContainsFunc(types, func(t string) bool { return jsonTypeMatches(t, value) }) {
+		*violations = append(*violations, fmt.Sprintf("%s: want %s, got %s", at, strings.Join(types, " or "), jsonTypeName(value)))
+		return
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(candidate any) bool { return reflect.DeepEqual(candidate, value) }) {
+		*violations = append(*violations, fmt.Sprintf("%s: value not in enum", at))
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		required, _ := stringList(schema["required"])
+		for _, name := range required {
+			if _, ok := v[name]; !ok {
+				*violations = append(*violations, fmt.Sprintf("%s.%s: required property missing", at, name))
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		for name, field := range v {
+			if sub, ok := properties[name].(map[string]any); ok {
+				matchJSONSchema(sub, field, at+"."+name, violations)
+			} else if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+				*violations = append(*violations, fmt.Sprintf("%s.%s: additional property not allowed", at, name))
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				matchJSONSchema(items, item, fmt.Sprintf("%s[%d]", at, i), violations)
+			}
+		}
+	}
+}
+
+func schemaTypes(value any) ([]string, bool) {
+	if single, ok := value.(string); ok {
+		return []string{single}, true
+	}
+	return stringList(value)
+}
+
+func stringList(value any) ([]string, bool) {
+	switch list := value.(type) {
+	case []string:
+		return list, true
+	case []any:
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			out = append(out, s)
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+func jsonTypeMatches(want string, value any) bool {
+	got := jsonTypeName(value)
+	if want == "number" && got == "integer" {
+		return true
+	}
+	return want == got
+}
+
+func jsonTypeName(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
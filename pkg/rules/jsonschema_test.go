@@ -0,0 +1,49 @@
+package rules_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+func TestMatchJSONSchema(t *testing.T) {
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"type": "object",
+		"required": ["id", "choices"],
+		"properties": {
+			"id": {"type": "string"},
+			"object": {"enum": ["chat.completion"]},
+			"choices": {"type": "array", "items": {"type": "object", "required": ["index"], "properties": {"index": {"type": "integer"}}}},
+			"usage": {"type": ["object", "null"], "additionalProperties": false, "properties": {"total_tokens": {"type": "number"}}}
+		}
+	}`), &schema))
+	require.NoError(t, rules.CheckJSONSchema(schema))
+
+	decode := func(body string) any {
+		var value any
+		require.NoError(t, json.Unmarshal([]byte(body), &value))
+		return value
+	}
+	require.Empty(t, rules.MatchJSONSchema(schema, decode(`{"id":"x","object":"chat.completion","choices":[{"index":0}],"usage":{"total_tokens":3}}`)))
+	require.Empty(t, rules.MatchJSONSchema(schema, decode(`{"id":"x","choices":[],"usage":null}`)))
+
+	require.Equal(t, []string{
+		"$.choices[0].index: want integer, got number",
+		"$.id: required property missing",
+		"$.object: value not in enum",
+		"$.usage.cached: additional property not allowed",
+	}, rules.MatchJSONSchema(schema, decode(`{"object":"list","choices":[{"index":0.5}],"usage":{"cached":1}}`)))
+	require.Equal(t, []string{"$: want object, got array"}, rules.MatchJSONSchema(schema, decode(`[]`)))
+}
+
+func TestCheckJSONSchema_Nested(t *testing.T) {
+	err := rules.CheckJSONSchema(map[string]any{
+		"properties": map[string]any{"choices": map[string]any{"items": map[string]any{"minItems": 1}}},
+	})
+	require.ErrorIs(t, err, rules.ErrInvalidJSONSchema)
+	require.Contains(t, err.Error(), `$.choices[]: unsupported keyword "minItems"`)
+}
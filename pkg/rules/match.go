@@ -0,0 +1,170 @@
+package rules
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MatchRequest 是评估匹配条件所需的请求属性，由代理从当前请求与认证上下文中提取，
+// 也可由管理端根据样例请求构造。
+type MatchRequest struct {
+	// Path 为请求路径；RoutePath 为路由模板（如 /v1/*path），二者任一满足 path_prefix 即可。
+	Path      string
+	RoutePath string
+	Method    string
+	Header    http.Header
+
+	APIKeyID     string
+	APIKeyPrefix string
+	UserID       string
+	UserMetadata map[string]any
+	// HasBinding 表示请求携带用户与上游凭据的绑定，BindingUpstreamID 为绑定的上游凭据 ID。
+	HasBinding        bool
+	BindingUpstreamID string
+	// Provider 为绑定的上游凭据所属服务（如 openai）。
+	Provider string
+}
+
+// ConditionResult 是单个匹配条件的评估结果。Condition 为条件名称，请求头与用户元数据条件
+// 以点分路径区分键（如 headers.X-Env、user_metadata.tier）。
+type ConditionResult struct {
+	Condition string `json:"condition"`
+	Expected  any    `json:"expected"`
+	Actual    string `json:"actual"`
+	Matched   bool   `json:"matched"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Matches 判断请求是否满足全部匹配条件，遇到第一个不满足的条件即返回。
+func (m Matcher) Matches(req MatchRequest) bool {
+	matched := true
+	m.evaluate(req, func(result ConditionResult) bool {
+		matched = result.Matched
+		return matched
+	})
+	return matched
+}
+
+// Explain 逐项评估已配置的匹配条件，不在第一个失败处停止，便于排查规则未命中的原因。
+// 未配置的条件不出现在结果中；结果全部满足时规则匹配该请求。
+func (m Matcher) Explain(req MatchRequest) []ConditionResult {
+	results := []ConditionResult{}
+	m.evaluate(req, func(result ConditionResult) bool {
+		results = append(results, result)
+		return true
+	})
+	return results
+}
+
+// evaluate 按固定顺序评估已配置的条件并交给 visit，visit 返回 false 时停止。
+func (m Matcher) evaluate(req MatchRequest, visit func(ConditionResult) bool) {
+	if m.PathPrefix != "" {
+		matched := strings.HasPrefix(req.RoutePath, m.PathPrefix) || strings.HasPrefix(req.Path, m.PathPrefix)
+		if !visit(ConditionResult{Condition: "path_prefix", Expected: m.PathPrefix, Actual: req.Path, Matched: matched}) {
+			return
+		}
+	}
+	if len(m.Methods) > 0 {
+		matched := false
+		for _, method := range m.Methods {
+			if strings.EqualFold(req.Method, method) {
+				matched = true
+				break
+			}
+		}
+		if !visit(ConditionResult{Condition: "methods", Expected: m.Methods, Actual: req.Method, Matched: matched}) {
+			return
+		}
+	}
+	for _, key := range sortedKeys(m.Headers) {
+		pattern := m.Headers[key]
+		value := req.Header.Get(key)
+		result := ConditionResult{Condition: "headers." + key, Expected: pattern, Actual: value, Matched: pattern == "" && value == ""}
+		if !result.Matched {
+			matched, err := regexp.MatchString(pattern, value)
+			result.Matched = err == nil && matched
+			if err != nil {
+				result.Reason = fmt.Sprintf("invalid pattern: %v", err)
+			}
+		}
+		if !visit(result) {
+			return
+		}
+	}
+	if m.RequireBinding {
+		if !visit(ConditionResult{Condition: "require_binding", Expected: true, Actual: fmt.Sprint(req.HasBinding), Matched: req.HasBinding}) {
+			return
+		}
+	}
+	if len(m.APIKeyIDs) > 0 {
+		if !visit(ConditionResult{Condition: "api_key_ids", Expected: m.APIKeyIDs, Actual: req.APIKeyID, Matched: containsTrimmed(m.APIKeyIDs, req.APIKeyID, false)}) {
+			return
+		}
+	}
+	if len(m.APIKeyPrefixes) > 0 {
+		if !visit(ConditionResult{Condition: "api_key_prefixes", Expected: m.APIKeyPrefixes, Actual: req.APIKeyPrefix, Matched: containsTrimmed(m.APIKeyPrefixes, req.APIKeyPrefix, true)}) {
+			return
+		}
+	}
+	if len(m.UserIDs) > 0 {
+		if !visit(ConditionResult{Condition: "user_ids", Expected: m.UserIDs, Actual: req.UserID, Matched: containsTrimmed(m.UserIDs, req.UserID, false)}) {
+			return
+		}
+	}
+	for _, key := range sortedKeys(m.UserMetadata) {
+		expected := m.UserMetadata[key]
+		result := ConditionResult{Condition: "user_metadata." + key, Expected: expected}
+		if actual, ok := req.UserMetadata[key]; ok {
+			result.Actual = strings.TrimSpace(fmt.Sprint(actual))
+			result.Matched = result.Actual == strings.TrimSpace(expected)
+		} else {
+			result.Reason = "key missing"
+		}
+		if !visit(result) {
+			return
+		}
+	}
+	if len(m.BindingUpstreamIDs) > 0 {
+		matched := req.HasBinding && containsTrimmed(m.BindingUpstreamIDs, req.BindingUpstreamID, false)
+		if !visit(ConditionResult{Condition: "binding_upstream_ids", Expected: m.BindingUpstreamIDs, Actual: req.BindingUpstreamID, Matched: matched}) {
+			return
+		}
+	}
+	if len(m.BindingProviders) > 0 {
+		visit(ConditionResult{Condition: "binding_providers", Expected: m.BindingProviders, Actual: req.Provider, Matched: containsTrimmed(m.BindingProviders, req.Provider, true)})
+	}
+}
+
+// containsTrimmed 判断去除首尾空白后的 target 是否在 list 中，空值不匹配任何条目。
+func containsTrimmed(list []string, target string, caseInsensitive bool) bool {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return false
+	}
+	for _, item := range list {
+		trimmed := strings.TrimSpace(item)
+		if trimmed == "" {
+			continue
+		}
+		if caseInsensitive {
+			if strings.EqualFold(trimmed, target) {
+				return true
+			}
+		} else if trimmed == target {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
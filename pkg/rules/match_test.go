@@ -0,0 +1,47 @@
+package rules_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/pkg/rules"
+)
+
+func TestMatcher_Explain(t *testing.T) {
+	matcher := rules.Matcher{
+		PathPrefix:       "/v1",
+		Headers:          map[string]string{"X-Env": "(", "X-Team": "^core$"},
+		UserMetadata:     map[string]string{"tier": "gold"},
+		BindingProviders: []string{"OpenAI"},
+		RequireBinding:   true,
+	}
+	req := rules.MatchRequest{
+		Path:         "/v1/chat/completions",
+		Header:       http.Header{"X-Team": []string{"core"}},
+		UserMetadata: map[string]any{"tier": "silver"},
+		HasBinding:   true,
+		Provider:     "openai",
+	}
+	results := matcher.Explain(req)
+	conditions := make([]string, 0, len(results))
+	failed := map[string]string{}
+	for _, result := range results {
+		conditions = append(conditions, result.Condition)
+		if !result.Matched {
+			failed[result.Condition] = result.Reason
+		}
+	}
+	require.Equal(t, []string{"path_prefix", "headers.X-Env", "headers.X-Team", "require_binding", "user_metadata.tier", "binding_providers"}, conditions)
+	require.Len(t, failed, 2)
+	require.Contains(t, failed["headers.X-Env"], "invalid pattern")
+	require.Contains(t, failed, "user_metadata.tier")
+	require.False(t, matcher.Matches(req))
+
+	req.UserMetadata["tier"] = " gold "
+	matcher.Headers["X-Env"] = ""
+	require.True(t, matcher.Matches(req))
+	require.Empty(t, rules.Matcher{}.Explain(req))
+	require.True(t, rules.Matcher{}.Matches(req))
+}
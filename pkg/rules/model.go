@@ -3,7 +3,9 @@ package rules
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 )
@@ -50,14 +52,315 @@ type Actions struct {
 	RemoveJSON       []string               `json:"remove_json,omitempty"`
 	RewritePathRegex *RewritePathExpression `json:"rewrite_path_regex,omitempty"`
 	Script           string                 `json:"script,omitempty"`
+	// UpstreamService 指定规则面向的上游服务（如 openai、anthropic），代理据此选用同一服务的绑定；
+	// 为空时按 set_target_url 的主机名推断。
+	UpstreamService string `json:"upstream_service,omitempty"`
+	// SelectUpstreamByMetadata 按元数据过滤（如 region=eu）在用户的多个上游凭据中选出本次请求使用的凭据。
+	SelectUpstreamByMetadata map[string]string `json:"select_upstream_by_metadata,omitempty"`
+	// TranslateProtocol 在客户端与上游使用不同 API 协议时转换请求与响应（含 SSE 流），取值见 TranslateProtocols。
+	TranslateProtocol string `json:"translate_protocol,omitempty"`
+	// FallbackModels 是请求模型因限流或上游错误（429、5xx）失败后依次改用的模型，
+	// 每个模型先用尽同一 Service 下的备用绑定，再切换到下一个模型。
+	FallbackModels []string `json:"fallback_models,omitempty"`
+	// ClampParams 以 JSON 路径（如 max_tokens、temperature、n）为键，把请求体中的数值参数限制在给定范围内，
+	// 缺失时按 default 注入，防止客户端配置失误带来失控的开销。
+	ClampParams map[string]ParamBound `json:"clamp_params,omitempty"`
+	// SystemPrompt 在聊天请求的 system 消息前追加或直接替换为管理员维护的模板，确保组织级约束提示始终存在。
+	SystemPrompt *SystemPromptAction `json:"system_prompt,omitempty"`
+	// RedactPII 在转发前扫描 JSON 请求体中的字符串，命中邮箱、电话、银行卡号或自定义正则时打码或拒绝请求。
+	RedactPII *RedactionAction `json:"redact_pii,omitempty"`
+	// Moderation 调用外部内容审核服务检查提示词与（非流式）模型输出，按配置拦截、标记或仅记录违规内容。
+	Moderation *ModerationAction `json:"moderation,omitempty"`
+	// ResponseCache 缓存非流式补全的成功响应，模型与空白归一化后的提示词相同的请求在有效期内直接返回缓存，不再访问上游。
+	ResponseCache *ResponseCacheAction `json:"response_cache,omitempty"`
+	// MaxPromptTokens 在转发前按分词器估算请求的提示词 token 数，超过上限的请求直接拒绝，不再消耗上游配额。
+	MaxPromptTokens int `json:"max_prompt_tokens,omitempty"`
+	// ToolPolicy 按名称注入、移除或重命名聊天请求中的工具（函数），并过滤响应中调用未授权工具的 tool_calls，
+	// 集中控制模型可以调用的工具。
+	ToolPolicy *ToolPolicyAction `json:"tool_policy,omitempty"`
+	// BetaHeaders 以请求头名称（如 anthropic-beta、openai-beta）为键，控制客户端传入的 provider beta 特性
+	// （提示词缓存、扩展思考等）：只放行白名单中的特性、丢弃客户端取值或强制附加特性，避免原样透传导致上游行为不一致。
+	BetaHeaders map[string]BetaHeaderPolicy `json:"beta_headers,omitempty"`
+	// TraceExport 把脱敏后的提示词与补全异步导出到 Langfuse 或 LangSmith，平台凭据由网关配置提供。
+	TraceExport *TraceExportAction `json:"trace_export,omitempty"`
+	// SmartRoute 在用户的多个上游服务凭据中，按实时价格或延迟为同一类模型选出最便宜或最快的健康凭据，
+	// 并把请求模型改写为所选服务对应的模型，可按会话或用户保持选择。
+	SmartRoute *SmartRouteAction `json:"smart_route,omitempty"`
+	// Affinity 让携带相同会话 ID 的请求固定使用同一上游凭据与端点，适合保存服务端状态或提示词缓存的上游。
+	Affinity *AffinityAction `json:"affinity,omitempty"`
+	// ExpectResponse 声明上游响应应满足的约定（状态码类别、必需响应头、JSON Schema），
+	// 违规时记录指标与日志，可选地改为返回 502，及早发现上游接口漂移。
+	ExpectResponse *ResponseContractAction `json:"expect_response,omitempty"`
 }
 
+// translate_protocol 支持的取值，形如 <客户端协议>_to_<上游协议>。
+const (
+	// TranslateOpenAIToAnthropic 把 OpenAI Chat Completions 请求转换为 Anthropic Messages 请求，并把响应转换回来。
+	TranslateOpenAIToAnthropic = "openai_to_anthropic"
+	// TranslateOpenAIToGemini 把 OpenAI Chat Completions 请求转换为 Google Gemini generateContent 请求，并把响应转换回来。
+	TranslateOpenAIToGemini = "openai_to_gemini"
+)
+
+// TranslateProtocols 列出 translate_protocol 支持的全部取值。
+var TranslateProtocols = []string{TranslateOpenAIToAnthropic, TranslateOpenAIToGemini}
+
 // RewritePathExpression 封装重写路径所需的正则参数。
 type RewritePathExpression struct {
 	Pattern string `json:"pattern"`
 	Replace string `json:"replace"`
 }
 
+// ParamBound 描述 clamp_params 中单个数值参数的取值范围，min/max 缺省表示该侧不限制，
+// default 仅在请求未携带该参数时注入。
+type ParamBound struct {
+	Min     *float64 `json:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty"`
+	Default *float64 `json:"default,omitempty"`
+}
+
+// Clamp 把 value 限制在 [Min, Max] 内。
+func (b ParamBound) Clamp(value float64) float64 {
+	if b.Min != nil && value < *b.Min {
+		value = *b.Min
+	}
+	if b.Max != nil && value > *b.Max {
+		value = *b.Max
+	}
+	return value
+}
+
+// system_prompt 支持的模式。
+const (
+	// SystemPromptPrepend 把模板放在已有 system 消息之前，没有 system 消息时新增一条。
+	SystemPromptPrepend = "prepend"
+	// SystemPromptReplace 丢弃客户端提供的 system 消息，只保留模板。
+	SystemPromptReplace = "replace"
+)
+
+// SystemPromptModes 列出 system_prompt.mode 支持的全部取值。
+var SystemPromptModes = []string{SystemPromptPrepend, SystemPromptReplace}
+
+// SystemPromptAction 描述 system 消息注入。Template 支持 {{user.id}}、{{user.name}} 与
+// {{user.metadata.<key>}} 变量，缺失的变量替换为空字符串；Mode 为空时按 prepend 处理。
+type SystemPromptAction struct {
+	Mode     string `json:"mode,omitempty"`
+	Template string `json:"template"`
+}
+
+// redact_pii 内置的检测器。
+const (
+	DetectorEmail      = "email"
+	DetectorPhone      = "phone"
+	DetectorCreditCard = "credit_card"
+)
+
+// RedactionDetectors 列出 redact_pii.detectors 支持的全部取值。
+var RedactionDetectors = []string{DetectorEmail, DetectorPhone, DetectorCreditCard}
+
+// redact_pii 的处理方式。
+const (
+	// RedactionMask 把命中的内容替换为 Mask 后继续转发。
+	RedactionMask = "mask"
+	// RedactionReject 拒绝请求，不访问上游。
+	RedactionReject = "reject"
+)
+
+// RedactionModes 列出 redact_pii.mode 支持的全部取值。
+var RedactionModes = []string{RedactionMask, RedactionReject}
+
+// RedactionAction 描述敏感信息过滤。Patterns 以名称为键配置自定义正则，名称与内置检测器一起出现在指标标签中；
+// Mode 为空时按 mask 处理，Mask 为空时使用 [REDACTED]。
+type RedactionAction struct {
+	Detectors []string          `json:"detectors,omitempty"`
+	Patterns  map[string]string `json:"patterns,omitempty"`
+	Mode      string            `json:"mode,omitempty"`
+	Mask      string            `json:"mask,omitempty"`
+}
+
+// moderation 支持的审核服务。
+const (
+	// ModerationOpenAI 调用 OpenAI Moderation API（或兼容的端点）。
+	ModerationOpenAI = "openai"
+	// ModerationWebhook 调用自定义审核端点，约定见 internal/moderation。
+	ModerationWebhook = "webhook"
+)
+
+// ModerationProviders 列出 moderation.provider 支持的全部取值。
+var ModerationProviders = []string{ModerationOpenAI, ModerationWebhook}
+
+// moderation 检查的阶段。
+const (
+	ModerationStagePrompt     = "prompt"
+	ModerationStageCompletion = "completion"
+)
+
+// ModerationStages 列出 moderation.stages 支持的全部取值。
+var ModerationStages = []string{ModerationStagePrompt, ModerationStageCompletion}
+
+// moderation 命中后的处理方式。
+const (
+	// ModerationBlock 拒绝请求或丢弃模型输出。
+	ModerationBlock = "block"
+	// ModerationFlag 照常转发，并在响应头 X-YAPI-Moderation 中标注命中的类别。
+	ModerationFlag = "flag"
+	// ModerationLog 照常转发，只记录日志与指标。
+	ModerationLog = "log"
+)
+
+// ModerationOutcomes 列出 moderation.on_flagged 支持的全部取值。
+var ModerationOutcomes = []string{ModerationBlock, ModerationFlag, ModerationLog}
+
+// ModerationAction 描述内容审核。Provider 为空时按 openai 处理，URL 为空时使用 OpenAI 官方端点；
+// APIKey 可以是密钥引用（如 env://YAPI_SECRET_OPENAI_API_KEY）。Stages 为空时只检查 prompt，OnFlagged 为空时按 block 处理；
+// 审核服务出错或超时（TimeoutMS，默认 5000）时默认放行，FailClosed 为 true 时拒绝。
+type ModerationAction struct {
+	Provider   string   `json:"provider,omitempty"`
+	URL        string   `json:"url,omitempty"`
+	APIKey     string   `json:"api_key,omitempty"`
+	Model      string   `json:"model,omitempty"`
+	Stages     []string `json:"stages,omitempty"`
+	OnFlagged  string   `json:"on_flagged,omitempty"`
+	FailClosed bool     `json:"fail_closed,omitempty"`
+	TimeoutMS  int      `json:"timeout_ms,omitempty"`
+}
+
+// ResponseCacheAction 描述响应缓存。TTLSeconds 为缓存有效期；缓存默认按用户隔离，Shared 为 true 时同一规则的全部用户共享缓存，
+// 按用户渲染的 system_prompt 不同的用户仍互相隔离。
+type ResponseCacheAction struct {
+	TTLSeconds int  `json:"ttl_seconds"`
+	Shared     bool `json:"shared,omitempty"`
+}
+
+// ToolPolicyAction 描述工具调用策略，名称均指客户端看到的工具名。Allow 非空时只保留其中列出的客户端工具，
+// Remove 中的工具总被移除；Rename 把客户端工具名改为上游看到的名称，响应中的调用再改回客户端名称；
+// Inject 追加管理员定义的工具，同名的客户端工具被替换，注入的工具不受 Allow 与 Remove 限制。
+type ToolPolicyAction struct {
+	Allow  []string          `json:"allow,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+	Rename map[string]string `json:"rename,omitempty"`
+	Inject []ToolDefinition  `json:"inject,omitempty"`
+}
+
+// ToolDefinition 是 tool_policy 注入的工具，代理按请求的协议写成 OpenAI function 或 Anthropic tool。
+// Parameters 为参数的 JSON Schema，缺省为不带参数的对象。
+type ToolDefinition struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+// BetaHeaderPolicy 描述单个逗号分隔的特性请求头的处理方式。Strip 为 true 时丢弃客户端的全部取值；否则 Allow 非空时
+// 只保留其中列出的特性（以 * 结尾表示前缀匹配，不区分大小写），为空时保留全部。Set 中的特性总被附加在末尾；
+// 处理后没有任何特性时删除该请求头。
+type BetaHeaderPolicy struct {
+	Allow []string `json:"allow,omitempty"`
+	Strip bool     `json:"strip,omitempty"`
+	Set   []string `json:"set,omitempty"`
+}
+
+// trace_export 支持的平台。
+const (
+	TraceExportLangfuse  = "langfuse"
+	TraceExportLangSmith = "langsmith"
+)
+
+// TraceExportProviders 列出 trace_export.provider 支持的全部取值。
+var TraceExportProviders = []string{TraceExportLangfuse, TraceExportLangSmith}
+
+// TraceExportAction 描述提示词与补全的导出。Redact 为导出前的脱敏配置，只支持 mask 模式；
+// 为空时按全部内置检测器打码。
+type TraceExportAction struct {
+	Provider string           `json:"provider"`
+	Redact   *RedactionAction `json:"redact,omitempty"`
+}
+
+// Redaction 返回导出前使用的脱敏配置。
+func (t TraceExportAction) Redaction() RedactionAction {
+	if t.Redact != nil {
+		return *t.Redact
+	}
+	return RedactionAction{Detectors: RedactionDetectors, Mode: RedactionMask}
+}
+
+// smart_route 支持的选择策略。
+const (
+	// SmartRouteCheapest 选择按价格表计算的每百万 token 单价（提示词与补全之和）最低的候选，未定价的候选排在最后。
+	SmartRouteCheapest = "cheapest"
+	// SmartRouteFastest 选择端点健康得分（综合 EWMA 延迟与错误率）最高的候选。
+	SmartRouteFastest = "fastest"
+)
+
+// SmartRouteStrategies 列出 smart_route.strategy 支持的全部取值。
+var SmartRouteStrategies = []string{SmartRouteCheapest, SmartRouteFastest}
+
+// smart_route 支持的粘性范围。
+const (
+	// SmartRouteStickyConversation 让会话 ID 相同的请求沿用首次选出的凭据，会话 ID 的来源与 affinity 相同。
+	SmartRouteStickyConversation = "conversation"
+	// SmartRouteStickyUser 让同一用户的请求沿用首次选出的凭据。
+	SmartRouteStickyUser = "user"
+)
+
+// SmartRouteStickyScopes 列出 smart_route.sticky 支持的全部取值。
+var SmartRouteStickyScopes = []string{SmartRouteStickyConversation, SmartRouteStickyUser}
+
+// SmartRouteAction 描述按价格或延迟的多服务路由。Models 为参与路由的请求模型（即模型类别），为空时不限；
+// Candidates 按顺序列出可选的上游服务及其对应模型，得分相同时靠前的优先。Sticky 为空时每次请求重新选择，
+// 否则在 StickyTTLSeconds（默认 1800）内沿用上次的选择，直到该凭据不再健康。
+type SmartRouteAction struct {
+	Strategy         string                `json:"strategy"`
+	Models           []string              `json:"models,omitempty"`
+	Candidates       []SmartRouteCandidate `json:"candidates"`
+	Sticky           string                `json:"sticky,omitempty"`
+	StickyTTLSeconds int                   `json:"sticky_ttl_seconds,omitempty"`
+}
+
+// SmartRouteCandidate 是 smart_route 的一个候选服务。Service 对应上游凭据的服务（如 openai、anthropic），
+// 该服务下的每个启用凭据都参与选择；Model 为转发到该服务时使用的模型，为空时保留请求模型。
+type SmartRouteCandidate struct {
+	Service string `json:"service"`
+	Model   string `json:"model,omitempty"`
+}
+
+// DefaultConversationHeader 是未配置 affinity.header 时读取会话 ID 的请求头。
+const DefaultConversationHeader = "X-Conversation-ID"
+
+// AffinityAction 描述会话亲和。会话 ID 先取请求头 Header（默认 X-Conversation-ID），缺失时再取 JSON 请求体中
+// JSONField 路径（如 metadata.conversation_id）的字符串值，都缺失时不固定。同一会话成功转发后记录所用的凭据与端点，
+// 后续请求在凭据仍可用且与当前凭据属于同一服务时改用该凭据，端点仍在凭据的端点列表中时改用该端点；
+// 会话超过 TTLSeconds（默认 3600）没有新请求后解除固定。
+type AffinityAction struct {
+	Header     string `json:"header,omitempty"`
+	JSONField  string `json:"json_field,omitempty"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty"`
+}
+
+// ResponseContractAction 描述上游响应约定。StatusClasses 为允许的状态码类别（如 2xx、4xx），为空时不限；
+// RequiredHeaders 为响应必须携带的头；JSONSchema 只对非流式、未压缩的 2xx application/json 响应校验，
+// 支持的关键字见 CheckJSONSchema。Enforce 为 true 时违规响应替换为 502，否则原样返回、只记录违规。
+type ResponseContractAction struct {
+	StatusClasses   []string       `json:"status_classes,omitempty"`
+	RequiredHeaders []string       `json:"required_headers,omitempty"`
+	JSONSchema      map[string]any `json:"json_schema,omitempty"`
+	Enforce         bool           `json:"enforce,omitempty"`
+}
+
+// AllowsStatus 判断状态码是否属于允许的类别，未配置类别时总是允许。
+func (c ResponseContractAction) AllowsStatus(status int) bool {
+	if len(c.StatusClasses) == 0 {
+		return true
+	}
+	return slices.Contains(c.StatusClasses, fmt.Sprintf("%dxx", status/100))
+}
+
+// Checks 判断是否需要审核指定阶段。
+func (m ModerationAction) Checks(stage string) bool {
+	if len(m.Stages) == 0 {
+		return stage == ModerationStagePrompt
+	}
+	return slices.Contains(m.Stages, stage)
+}
+
 // Validate 检查规则定义是否符合要求。
 func (r Rule) Validate() error {
 	if strings.TrimSpace(r.ID) == "" {
@@ -137,9 +440,18 @@ func validateActions(a Actions) error {
 		len(a.AddHeaders) == 0 && len(a.RemoveHeaders) == 0 &&
 		strings.TrimSpace(a.SetAuthorization) == "" &&
 		len(a.OverrideJSON) == 0 && len(a.RemoveJSON) == 0 &&
-		a.RewritePathRegex == nil && strings.TrimSpace(a.Script) == "" {
+		a.RewritePathRegex == nil && strings.TrimSpace(a.Script) == "" &&
+		len(a.SelectUpstreamByMetadata) == 0 && strings.TrimSpace(a.UpstreamService) == "" &&
+		a.TranslateProtocol == "" && len(a.FallbackModels) == 0 && len(a.ClampParams) == 0 &&
+		a.SystemPrompt == nil && a.RedactPII == nil && a.Moderation == nil && a.ResponseCache == nil &&
+		a.MaxPromptTokens == 0 && a.ToolPolicy == nil && a.TraceExport == nil &&
+		len(a.BetaHeaders) == 0 && a.SmartRoute == nil && a.Affinity == nil &&
+		a.ExpectResponse == nil {
 		return fmt.Errorf("%w: actions must not be empty", ErrInvalidRule)
 	}
+	if a.TranslateProtocol != "" && !slices.Contains(TranslateProtocols, a.TranslateProtocol) {
+		return fmt.Errorf("%w: translate_protocol %q must be one of %s", ErrInvalidRule, a.TranslateProtocol, strings.Join(TranslateProtocols, ", "))
+	}
 	if a.RewritePathRegex != nil {
 		if _, err := regexp.Compile(a.RewritePathRegex.Pattern); err != nil {
 			return fmt.Errorf("%w: invalid rewrite regex pattern: %v", ErrInvalidRule, err)
@@ -153,6 +465,87 @@ func validateActions(a Actions) error {
 			return fmt.Errorf("%w: override_json path %q invalid: %v", ErrInvalidRule, key, err)
 		}
 	}
+	for i, model := range a.FallbackModels {
+		if strings.TrimSpace(model) == "" {
+			return fmt.Errorf("%w: fallback_models[%d] must not be empty", ErrInvalidRule, i)
+		}
+	}
+	if prompt := a.SystemPrompt; prompt != nil {
+		if strings.TrimSpace(prompt.Template) == "" {
+			return fmt.Errorf("%w: system_prompt.template must not be empty", ErrInvalidRule)
+		}
+		if prompt.Mode != "" && !slices.Contains(SystemPromptModes, prompt.Mode) {
+			return fmt.Errorf("%w: system_prompt.mode %q must be one of %s", ErrInvalidRule, prompt.Mode, strings.Join(SystemPromptModes, ", "))
+		}
+	}
+	if a.MaxPromptTokens < 0 {
+		return fmt.Errorf("%w: max_prompt_tokens must not be negative", ErrInvalidRule)
+	}
+	if cache := a.ResponseCache; cache != nil && cache.TTLSeconds <= 0 {
+		return fmt.Errorf("%w: response_cache.ttl_seconds must be positive", ErrInvalidRule)
+	}
+	if redact := a.RedactPII; redact != nil {
+		if err := validateRedaction("redact_pii", *redact); err != nil {
+			return err
+		}
+	}
+	if moderation := a.Moderation; moderation != nil {
+		if err := validateModeration(*moderation); err != nil {
+			return err
+		}
+	}
+	if policy := a.ToolPolicy; policy != nil {
+		if err := validateToolPolicy(*policy); err != nil {
+			return err
+		}
+	}
+	for name, policy := range a.BetaHeaders {
+		if err := validateBetaHeader(name, policy); err != nil {
+			return err
+		}
+	}
+	if export := a.TraceExport; export != nil {
+		if err := validateTraceExport(*export); err != nil {
+			return err
+		}
+	}
+	if route := a.SmartRoute; route != nil {
+		if err := validateSmartRoute(*route); err != nil {
+			return err
+		}
+	}
+	if affinity := a.Affinity; affinity != nil {
+		if err := validateAffinity(*affinity); err != nil {
+			return err
+		}
+	}
+	if contract := a.ExpectResponse; contract != nil {
+		if err := validateResponseContract(*contract); err != nil {
+			return err
+		}
+	}
+	for key, bound := range a.ClampParams {
+		if _, err := ParseJSONPath(key); err != nil {
+			return fmt.Errorf("%w: clamp_params path %q invalid: %v", ErrInvalidRule, key, err)
+		}
+		if bound.Min == nil && bound.Max == nil && bound.Default == nil {
+			return fmt.Errorf("%w: clamp_params[%q] requires min, max or default", ErrInvalidRule, key)
+		}
+		if bound.Min != nil && bound.Max != nil && *bound.Min > *bound.Max {
+			return fmt.Errorf("%w: clamp_params[%q] min must not exceed max", ErrInvalidRule, key)
+		}
+		if bound.Default != nil && bound.Clamp(*bound.Default) != *bound.Default {
+			return fmt.Errorf("%w: clamp_params[%q] default must lie within min and max", ErrInvalidRule, key)
+		}
+	}
+	for key, value := range a.SelectUpstreamByMetadata {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("%w: select_upstream_by_metadata key must not be empty", ErrInvalidRule)
+		}
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("%w: select_upstream_by_metadata[%q] value must not be empty", ErrInvalidRule, key)
+		}
+	}
 	for i, key := range a.RemoveJSON {
 		if strings.TrimSpace(key) == "" {
 			return fmt.Errorf("%w: remove_json[%d] must not be empty", ErrInvalidRule, i)
@@ -163,3 +556,204 @@ func validateActions(a Actions) error {
 	}
 	return nil
 }
+
+// validateRedaction 校验脱敏配置，field 为错误信息中的字段前缀。
+func validateRedaction(field string, r RedactionAction) error {
+	if len(r.Detectors) == 0 && len(r.Patterns) == 0 {
+		return fmt.Errorf("%w: %s requires detectors or patterns", ErrInvalidRule, field)
+	}
+	for i, detector := range r.Detectors {
+		if !slices.Contains(RedactionDetectors, detector) {
+			return fmt.Errorf("%w: %s.detectors[%d] %q must be one of %s", ErrInvalidRule, field, i, detector, strings.Join(RedactionDetectors, ", "))
+		}
+	}
+	for name, pattern := range r.Patterns {
+		if strings.TrimSpace(name) == "" || slices.Contains(RedactionDetectors, name) {
+			return fmt.Errorf("%w: %s pattern name %q must be non-empty and differ from built-in detectors", ErrInvalidRule, field, name)
+		}
+		if pattern == "" {
+			return fmt.Errorf("%w: %s.patterns[%q] must not be empty", ErrInvalidRule, field, name)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%w: %s.patterns[%q] invalid: %v", ErrInvalidRule, field, name, err)
+		}
+	}
+	if r.Mode != "" && !slices.Contains(RedactionModes, r.Mode) {
+		return fmt.Errorf("%w: %s.mode %q must be one of %s", ErrInvalidRule, field, r.Mode, strings.Join(RedactionModes, ", "))
+	}
+	return nil
+}
+
+func validateBetaHeader(name string, p BetaHeaderPolicy) error {
+	if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " \t:") {
+		return fmt.Errorf("%w: beta_headers key %q must be a header name", ErrInvalidRule, name)
+	}
+	if len(p.Allow) == 0 && !p.Strip && len(p.Set) == 0 {
+		return fmt.Errorf("%w: beta_headers[%q] requires allow, strip or set", ErrInvalidRule, name)
+	}
+	if p.Strip && len(p.Allow) > 0 {
+		return fmt.Errorf("%w: beta_headers[%q] allow has no effect with strip", ErrInvalidRule, name)
+	}
+	for _, list := range []struct {
+		field    string
+		features []string
+	}{{"allow", p.Allow}, {"set", p.Set}} {
+		field := list.field
+		for i, feature := range list.features {
+			feature = strings.TrimSpace(feature)
+			if feature == "" || strings.Contains(feature, ",") {
+				return fmt.Errorf("%w: beta_headers[%q].%s[%d] must be a single non-empty feature", ErrInvalidRule, name, field, i)
+			}
+			if star := strings.Index(feature, "*"); star >= 0 && (field == "set" || star != len(feature)-1) {
+				return fmt.Errorf("%w: beta_headers[%q].%s[%d] %q: * is only allowed at the end of allow entries", ErrInvalidRule, name, field, i, feature)
+			}
+		}
+	}
+	return nil
+}
+
+func validateTraceExport(t TraceExportAction) error {
+	if !slices.Contains(TraceExportProviders, t.Provider) {
+		return fmt.Errorf("%w: trace_export.provider %q must be one of %s", ErrInvalidRule, t.Provider, strings.Join(TraceExportProviders, ", "))
+	}
+	if t.Redact != nil {
+		if err := validateRedaction("trace_export.redact", *t.Redact); err != nil {
+			return err
+		}
+		// 导出发生在响应之后，无法再拒绝请求。
+		if t.Redact.Mode == RedactionReject {
+			return fmt.Errorf("%w: trace_export.redact.mode must be %s", ErrInvalidRule, RedactionMask)
+		}
+	}
+	return nil
+}
+
+func validateSmartRoute(r SmartRouteAction) error {
+	if !slices.Contains(SmartRouteStrategies, r.Strategy) {
+		return fmt.Errorf("%w: smart_route.strategy %q must be one of %s", ErrInvalidRule, r.Strategy, strings.Join(SmartRouteStrategies, ", "))
+	}
+	if len(r.Candidates) == 0 {
+		return fmt.Errorf("%w: smart_route.candidates must not be empty", ErrInvalidRule)
+	}
+	for i, candidate := range r.Candidates {
+		if strings.TrimSpace(candidate.Service) == "" {
+			return fmt.Errorf("%w: smart_route.candidates[%d].service must not be empty", ErrInvalidRule, i)
+		}
+	}
+	for i, model := range r.Models {
+		if strings.TrimSpace(model) == "" {
+			return fmt.Errorf("%w: smart_route.models[%d] must not be empty", ErrInvalidRule, i)
+		}
+	}
+	if r.Sticky != "" && !slices.Contains(SmartRouteStickyScopes, r.Sticky) {
+		return fmt.Errorf("%w: smart_route.sticky %q must be one of %s", ErrInvalidRule, r.Sticky, strings.Join(SmartRouteStickyScopes, ", "))
+	}
+	if r.StickyTTLSeconds < 0 {
+		return fmt.Errorf("%w: smart_route.sticky_ttl_seconds must not be negative", ErrInvalidRule)
+	}
+	return nil
+}
+
+func validateAffinity(a AffinityAction) error {
+	if strings.ContainsAny(a.Header, " \t:") {
+		return fmt.Errorf("%w: affinity.header %q must be a header name", ErrInvalidRule, a.Header)
+	}
+	if a.JSONField != "" {
+		if _, err := ParseJSONPath(a.JSONField); err != nil {
+			return fmt.Errorf("%w: affinity.json_field %q invalid: %v", ErrInvalidRule, a.JSONField, err)
+		}
+	}
+	if a.TTLSeconds < 0 {
+		return fmt.Errorf("%w: affinity.ttl_seconds must not be negative", ErrInvalidRule)
+	}
+	return nil
+}
+
+func validateResponseContract(c ResponseContractAction) error {
+	if len(c.StatusClasses) == 0 && len(c.RequiredHeaders) == 0 && len(c.JSONSchema) == 0 {
+		return fmt.Errorf("%w: expect_response requires status_classes, required_headers or json_schema", ErrInvalidRule)
+	}
+	for i, class := range c.StatusClasses {
+		if len(class) != 3 || class[0] < '1' || class[0] > '5' || class[1:] != "xx" {
+			return fmt.Errorf("%w: expect_response.status_classes[%d] %q must be one of 1xx, 2xx, 3xx, 4xx, 5xx", ErrInvalidRule, i, class)
+		}
+	}
+	for i, name := range c.RequiredHeaders {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " \t:") {
+			return fmt.Errorf("%w: expect_response.required_headers[%d] %q must be a header name", ErrInvalidRule, i, name)
+		}
+	}
+	if err := CheckJSONSchema(c.JSONSchema); err != nil {
+		return fmt.Errorf("%w: expect_response.json_schema: %v", ErrInvalidRule, err)
+	}
+	return nil
+}
+
+func validateModeration(m ModerationAction) error {
+	if m.Provider != "" && !slices.Contains(ModerationProviders, m.Provider) {
+		return fmt.Errorf("%w: moderation.provider %q must be one of %s", ErrInvalidRule, m.Provider, strings.Join(ModerationProviders, ", "))
+	}
+	if m.Provider == ModerationWebhook && strings.TrimSpace(m.URL) == "" {
+		return fmt.Errorf("%w: moderation.url is required for webhook provider", ErrInvalidRule)
+	}
+	if m.URL != "" {
+		u, err := url.Parse(m.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: moderation.url must be an absolute http(s) URL", ErrInvalidRule)
+		}
+	}
+	for i, stage := range m.Stages {
+		if !slices.Contains(ModerationStages, stage) {
+			return fmt.Errorf("%w: moderation.stages[%d] %q must be one of %s", ErrInvalidRule, i, stage, strings.Join(ModerationStages, ", "))
+		}
+	}
+	if m.OnFlagged != "" && !slices.Contains(ModerationOutcomes, m.OnFlagged) {
+		return fmt.Errorf("%w: moderation.on_flagged %q must be one of %s", ErrInvalidRule, m.OnFlagged, strings.Join(ModerationOutcomes, ", "))
+	}
+	if m.TimeoutMS < 0 {
+		return fmt.Errorf("%w: moderation.timeout_ms must not be negative", ErrInvalidRule)
+	}
+	return nil
+}
+
+func validateToolPolicy(p ToolPolicyAction) error {
+	if len(p.Allow) == 0 && len(p.Remove) == 0 && len(p.Rename) == 0 && len(p.Inject) == 0 {
+		return fmt.Errorf("%w: tool_policy requires allow, remove, rename or inject", ErrInvalidRule)
+	}
+	for i, name := range p.Allow {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%w: tool_policy.allow[%d] must not be empty", ErrInvalidRule, i)
+		}
+	}
+	for i, name := range p.Remove {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%w: tool_policy.remove[%d] must not be empty", ErrInvalidRule, i)
+		}
+	}
+	targets := make(map[string]string, len(p.Rename))
+	for from, to := range p.Rename {
+		if strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" {
+			return fmt.Errorf("%w: tool_policy.rename names must not be empty", ErrInvalidRule)
+		}
+		// 响应中的调用按上游名称改回客户端名称，多个工具改成同一名称时无法还原。
+		if other, ok := targets[to]; ok {
+			return fmt.Errorf("%w: tool_policy.rename maps both %q and %q to %q", ErrInvalidRule, other, from, to)
+		}
+		targets[to] = from
+	}
+	injected := make(map[string]bool, len(p.Inject))
+	for i, tool := range p.Inject {
+		name := strings.TrimSpace(tool.Name)
+		if name == "" {
+			return fmt.Errorf("%w: tool_policy.inject[%d].name must not be empty", ErrInvalidRule, i)
+		}
+		if injected[name] {
+			return fmt.Errorf("%w: tool_policy.inject[%d].name %q is duplicated", ErrInvalidRule, i, name)
+		}
+		if _, ok := targets[name]; ok {
+			return fmt.Errorf("%w: tool_policy.inject[%d].name %q conflicts with a rename target", ErrInvalidRule, i, name)
+		}
+		injected[name] = true
+	}
+	return nil
+}
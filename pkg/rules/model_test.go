@@ -46,3 +46,357 @@ func TestMatcherValidation_InvalidPrefix(t *testing.T) {
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "api_key_prefixes")
 }
+
+func TestActionsValidation_SelectUpstreamByMetadata(t *testing.T) {
+	rule := rules.Rule{
+		ID:       "region-rule",
+		Priority: 1,
+		Enabled:  true,
+		Matcher:  rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{
+			SelectUpstreamByMetadata: map[string]string{"region": "eu"},
+		},
+	}
+	require.NoError(t, rule.Validate())
+
+	rule.Actions.SelectUpstreamByMetadata = map[string]string{"region": " "}
+	err := rule.Validate()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "select_upstream_by_metadata")
+}
+
+func TestActionsValidation_UpstreamServiceOnly(t *testing.T) {
+	rule := rules.Rule{
+		ID:      "service-rule",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1/messages"},
+		Actions: rules.Actions{UpstreamService: "anthropic"},
+	}
+	require.NoError(t, rule.Validate())
+}
+
+func TestActionsValidation_TranslateProtocol(t *testing.T) {
+	rule := rules.Rule{
+		ID:      "claude-rule",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1/chat/completions"},
+		Actions: rules.Actions{TranslateProtocol: rules.TranslateOpenAIToAnthropic},
+	}
+	require.NoError(t, rule.Validate())
+
+	rule.Actions.TranslateProtocol = "openai_to_cobol"
+	err := rule.Validate()
+	require.ErrorIs(t, err, rules.ErrInvalidRule)
+	require.Contains(t, err.Error(), "translate_protocol")
+}
+
+func TestActionsValidation_FallbackModels(t *testing.T) {
+	rule := rules.Rule{
+		ID:      "fallback-rule",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1/chat/completions"},
+		Actions: rules.Actions{FallbackModels: []string{"gpt-4o-mini", "gpt-3.5-turbo"}},
+	}
+	require.NoError(t, rule.Validate())
+
+	rule.Actions.FallbackModels = []string{"gpt-4o-mini", " "}
+	err := rule.Validate()
+	require.ErrorIs(t, err, rules.ErrInvalidRule)
+	require.Contains(t, err.Error(), "fallback_models[1]")
+}
+
+func TestActionsValidation_ClampParams(t *testing.T) {
+	one, two, four := 1.0, 2.0, 4.0
+	rule := rules.Rule{
+		ID:      "clamp-rule",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1/chat/completions"},
+		Actions: rules.Actions{ClampParams: map[string]rules.ParamBound{
+			"max_tokens":  {Max: &four, Default: &two},
+			"temperature": {Min: &one},
+		}},
+	}
+	require.NoError(t, rule.Validate())
+	require.Equal(t, 4.0, rule.Actions.ClampParams["max_tokens"].Clamp(4096))
+	require.Equal(t, 1.0, rule.Actions.ClampParams["temperature"].Clamp(0.2))
+
+	for name, bound := range map[string]rules.ParamBound{
+		"requires min, max or default": {},
+		"min must not exceed max":      {Min: &four, Max: &one},
+		"default must lie within":      {Max: &one, Default: &two},
+	} {
+		rule.Actions.ClampParams = map[string]rules.ParamBound{"n": bound}
+		err := rule.Validate()
+		require.ErrorIs(t, err, rules.ErrInvalidRule)
+		require.Contains(t, err.Error(), name)
+	}
+}
+
+func TestActionsValidation_SystemPrompt(t *testing.T) {
+	rule := rules.Rule{
+		ID:      "prompt-rule",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1/chat/completions"},
+		Actions: rules.Actions{SystemPrompt: &rules.SystemPromptAction{Template: "Follow the {{user.metadata.team}} policy."}},
+	}
+	require.NoError(t, rule.Validate())
+
+	rule.Actions.SystemPrompt = &rules.SystemPromptAction{Mode: "append", Template: "x"}
+	err := rule.Validate()
+	require.ErrorIs(t, err, rules.ErrInvalidRule)
+	require.Contains(t, err.Error(), "system_prompt.mode")
+
+	rule.Actions.SystemPrompt = &rules.SystemPromptAction{Mode: rules.SystemPromptReplace, Template: " "}
+	err = rule.Validate()
+	require.ErrorIs(t, err, rules.ErrInvalidRule)
+	require.Contains(t, err.Error(), "system_prompt.template")
+}
+
+func TestActionsValidation_RedactPII(t *testing.T) {
+	rule := rules.Rule{
+		ID:      "redact-rule",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{RedactPII: &rules.RedactionAction{
+			Detectors: []string{rules.DetectorEmail, rules.DetectorCreditCard},
+			Patterns:  map[string]string{"employee_id": `EMP-\d{6}`},
+			Mode:      rules.RedactionReject,
+		}},
+	}
+	require.NoError(t, rule.Validate())
+
+	for want, action := range map[string]rules.RedactionAction{
+		"requires detectors or patterns": {},
+		"detectors[0]":                   {Detectors: []string{"ssn"}},
+		"differ from built-in":           {Patterns: map[string]string{"email": `x`}},
+		"patterns[\"bad\"] invalid":      {Patterns: map[string]string{"bad": `(`}},
+		"redact_pii.mode":                {Detectors: []string{rules.DetectorPhone}, Mode: "drop"},
+	} {
+		rule.Actions.RedactPII = &action
+		err := rule.Validate()
+		require.ErrorIs(t, err, rules.ErrInvalidRule)
+		require.Contains(t, err.Error(), want)
+	}
+}
+
+func TestActionsValidation_Moderation(t *testing.T) {
+	rule := rules.Rule{
+		ID:      "moderation-rule",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{Moderation: &rules.ModerationAction{APIKey: "env://OPENAI_API_KEY"}},
+	}
+	require.NoError(t, rule.Validate())
+	require.True(t, rule.Actions.Moderation.Checks(rules.ModerationStagePrompt))
+	require.False(t, rule.Actions.Moderation.Checks(rules.ModerationStageCompletion))
+
+	for want, action := range map[string]rules.ModerationAction{
+		"moderation.provider":     {Provider: "azure"},
+		"url is required":         {Provider: rules.ModerationWebhook},
+		"absolute http(s) URL":    {URL: "moderation.internal/check"},
+		"moderation.stages[1]":    {Stages: []string{rules.ModerationStagePrompt, "tool"}},
+		"moderation.on_flagged":   {OnFlagged: "drop"},
+		"timeout_ms must not be ": {TimeoutMS: -1},
+	} {
+		rule.Actions.Moderation = &action
+		err := rule.Validate()
+		require.ErrorIs(t, err, rules.ErrInvalidRule)
+		require.Contains(t, err.Error(), want)
+	}
+}
+
+func TestActionsValidation_ResponseCache(t *testing.T) {
+	rule := rules.Rule{
+		ID:      "cache-rule",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{ResponseCache: &rules.ResponseCacheAction{TTLSeconds: 300}},
+	}
+	require.NoError(t, rule.Validate())
+
+	rule.Actions.ResponseCache.TTLSeconds = 0
+	err := rule.Validate()
+	require.ErrorIs(t, err, rules.ErrInvalidRule)
+	require.Contains(t, err.Error(), "response_cache.ttl_seconds")
+}
+
+func TestActionsValidation_MaxPromptTokens(t *testing.T) {
+	rule := rules.Rule{
+		ID:      "token-limit",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{MaxPromptTokens: 8000},
+	}
+	require.NoError(t, rule.Validate())
+
+	rule.Actions.MaxPromptTokens = -1
+	err := rule.Validate()
+	require.ErrorIs(t, err, rules.ErrInvalidRule)
+	require.Contains(t, err.Error(), "max_prompt_tokens")
+}
+
+func TestActionsValidation_ToolPolicy(t *testing.T) {
+	rule := rules.Rule{
+		ID:      "tools",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{ToolPolicy: &rules.ToolPolicyAction{
+			Allow:  []string{"search"},
+			Rename: map[string]string{"search": "web_search"},
+			Inject: []rules.ToolDefinition{{Name: "audit_log"}},
+		}},
+	}
+	require.NoError(t, rule.Validate())
+
+	for want, policy := range map[string]rules.ToolPolicyAction{
+		"requires allow":      {},
+		"allow[0]":            {Allow: []string{" "}},
+		"remove[0]":           {Remove: []string{""}},
+		"rename names":        {Rename: map[string]string{"search": ""}},
+		"inject[0].name must": {Inject: []rules.ToolDefinition{{}}},
+		"is duplicated":       {Inject: []rules.ToolDefinition{{Name: "a"}, {Name: "a"}}},
+		"conflicts with":      {Rename: map[string]string{"search": "lookup"}, Inject: []rules.ToolDefinition{{Name: "lookup"}}},
+		"to \"lookup\"":       {Rename: map[string]string{"a": "lookup", "b": "lookup"}},
+	} {
+		rule.Actions.ToolPolicy = &policy
+		err := rule.Validate()
+		require.ErrorIs(t, err, rules.ErrInvalidRule)
+		require.Contains(t, err.Error(), want)
+	}
+}
+
+func TestActionsValidation_TraceExport(t *testing.T) {
+	rule := rules.Rule{
+		ID:      "export",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{TraceExport: &rules.TraceExportAction{Provider: rules.TraceExportLangfuse}},
+	}
+	require.NoError(t, rule.Validate())
+	require.Equal(t, rules.RedactionDetectors, rule.Actions.TraceExport.Redaction().Detectors)
+
+	for want, action := range map[string]rules.TraceExportAction{
+		"trace_export.provider":                  {Provider: "datadog"},
+		"trace_export.redact requires detectors": {Provider: rules.TraceExportLangSmith, Redact: &rules.RedactionAction{}},
+		"trace_export.redact.detectors[0]":       {Provider: rules.TraceExportLangSmith, Redact: &rules.RedactionAction{Detectors: []string{"ssn"}}},
+		"trace_export.redact.mode must be mask":  {Provider: rules.TraceExportLangfuse, Redact: &rules.RedactionAction{Detectors: []string{rules.DetectorEmail}, Mode: rules.RedactionReject}},
+	} {
+		rule.Actions.TraceExport = &action
+		err := rule.Validate()
+		require.ErrorIs(t, err, rules.ErrInvalidRule)
+		require.Contains(t, err.Error(), want)
+	}
+}
+
+func TestActionsValidation_BetaHeaders(t *testing.T) {
+	rule := rules.Rule{
+		ID:      "beta",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1/messages"},
+		Actions: rules.Actions{BetaHeaders: map[string]rules.BetaHeaderPolicy{
+			"anthropic-beta": {Allow: []string{"prompt-caching-*"}, Set: []string{"token-efficient-tools-2025-02-19"}},
+			"openai-beta":    {Strip: true},
+		}},
+	}
+	require.NoError(t, rule.Validate())
+
+	for want, policies := range map[string]map[string]rules.BetaHeaderPolicy{
+		"must be a header name":        {"anthropic beta": {Strip: true}},
+		"requires allow, strip or set": {"anthropic-beta": {}},
+		"no effect with strip":         {"anthropic-beta": {Strip: true, Allow: []string{"a"}}},
+		".allow[0] must be a single":   {"anthropic-beta": {Allow: []string{"a,b"}}},
+		".set[0] \"x-*\"":              {"anthropic-beta": {Set: []string{"x-*"}}},
+		".allow[1] \"*-x\"":            {"anthropic-beta": {Allow: []string{"a", "*-x"}}},
+	} {
+		rule.Actions.BetaHeaders = policies
+		err := rule.Validate()
+		require.ErrorIs(t, err, rules.ErrInvalidRule)
+		require.Contains(t, err.Error(), want)
+	}
+}
+
+func TestActionsValidation_SmartRoute(t *testing.T) {
+	rule := rules.Rule{
+		ID:      "route",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1/chat/completions"},
+		Actions: rules.Actions{SmartRoute: &rules.SmartRouteAction{
+			Strategy: rules.SmartRouteCheapest,
+			Models:   []string{"gpt-4o-mini"},
+			Candidates: []rules.SmartRouteCandidate{
+				{Service: "openai"},
+				{Service: "deepseek", Model: "deepseek-chat"},
+			},
+			Sticky:           rules.SmartRouteStickyConversation,
+			StickyTTLSeconds: 600,
+		}},
+	}
+	require.NoError(t, rule.Validate())
+
+	for want, route := range map[string]rules.SmartRouteAction{
+		"strategy \"random\" must be one of":      {Strategy: "random", Candidates: []rules.SmartRouteCandidate{{Service: "openai"}}},
+		"candidates must not be empty":            {Strategy: rules.SmartRouteFastest},
+		"candidates[1].service must not be empty": {Strategy: rules.SmartRouteFastest, Candidates: []rules.SmartRouteCandidate{{Service: "openai"}, {Model: "x"}}},
+		"models[0] must not be empty":             {Strategy: rules.SmartRouteFastest, Models: []string{" "}, Candidates: []rules.SmartRouteCandidate{{Service: "openai"}}},
+		"sticky \"session\" must be one of":       {Strategy: rules.SmartRouteFastest, Sticky: "session", Candidates: []rules.SmartRouteCandidate{{Service: "openai"}}},
+		"sticky_ttl_seconds must not be negative": {Strategy: rules.SmartRouteFastest, StickyTTLSeconds: -1, Candidates: []rules.SmartRouteCandidate{{Service: "openai"}}},
+	} {
+		rule.Actions.SmartRoute = &route
+		err := rule.Validate()
+		require.ErrorIs(t, err, rules.ErrInvalidRule)
+		require.Contains(t, err.Error(), want)
+	}
+}
+
+func TestActionsValidation_Affinity(t *testing.T) {
+	rule := rules.Rule{
+		ID:      "affinity",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{Affinity: &rules.AffinityAction{}},
+	}
+	require.NoError(t, rule.Validate())
+	rule.Actions.Affinity = &rules.AffinityAction{Header: "X-Session-ID", JSONField: "metadata.conversation_id", TTLSeconds: 600}
+	require.NoError(t, rule.Validate())
+
+	for want, affinity := range map[string]rules.AffinityAction{
+		"must be a header name":            {Header: "X Session"},
+		"json_field \"a..b\" invalid":      {JSONField: "a..b"},
+		"ttl_seconds must not be negative": {TTLSeconds: -1},
+	} {
+		rule.Actions.Affinity = &affinity
+		err := rule.Validate()
+		require.ErrorIs(t, err, rules.ErrInvalidRule)
+		require.Contains(t, err.Error(), want)
+	}
+}
+
+func TestActionsValidation_ExpectResponse(t *testing.T) {
+	rule := rules.Rule{
+		ID:      "contract",
+		Enabled: true,
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{ExpectResponse: &rules.ResponseContractAction{
+			StatusClasses:   []string{"2xx", "4xx"},
+			RequiredHeaders: []string{"X-Request-ID"},
+			JSONSchema:      map[string]any{"type": "object", "required": []any{"choices"}},
+		}},
+	}
+	require.NoError(t, rule.Validate())
+	require.True(t, rule.Actions.ExpectResponse.AllowsStatus(201))
+	require.False(t, rule.Actions.ExpectResponse.AllowsStatus(502))
+
+	for want, contract := range map[string]rules.ResponseContractAction{
+		"requires status_classes":             {},
+		"status_classes[0] \"200\"":           {StatusClasses: []string{"200"}},
+		"required_headers[0] \"X Id\"":        {RequiredHeaders: []string{"X Id"}},
+		"unsupported keyword \"pattern\"":     {JSONSchema: map[string]any{"pattern": "^a"}},
+		"type \"map\" must be one of":         {JSONSchema: map[string]any{"type": "map"}},
+		"additionalProperties must be a bool": {JSONSchema: map[string]any{"additionalProperties": map[string]any{}}},
+	} {
+		rule.Actions.ExpectResponse = &contract
+		err := rule.Validate()
+		require.ErrorIs(t, err, rules.ErrInvalidRule)
+		require.Contains(t, err.Error(), want)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+
+	"github.com/prehisle/yapi/pkg/dbreplica"
 )
 
 // DBStore 基于关系型数据库（PostgreSQL）的规则存储。
@@ -28,8 +30,17 @@ func (s *DBStore) AutoMigrate(ctx context.Context) error {
 
 // List 查询所有规则，按优先级降序排列。
 func (s *DBStore) List(ctx context.Context) ([]Rule, error) {
+	return s.list(s.db.WithContext(ctx))
+}
+
+// ListReplica 与 List 相同，但在已注册的只读副本上查询，未配置副本时读取主库。
+func (s *DBStore) ListReplica(ctx context.Context) ([]Rule, error) {
+	return s.list(dbreplica.Read(s.db.WithContext(ctx)))
+}
+
+func (s *DBStore) list(db *gorm.DB) ([]Rule, error) {
 	var records []ruleRecord
-	if err := s.db.WithContext(ctx).Order("priority DESC").Find(&records).Error; err != nil {
+	if err := db.Order("priority DESC").Find(&records).Error; err != nil {
 		return nil, err
 	}
 	result := make([]Rule, 0, len(records))
@@ -83,12 +94,58 @@ func (s *DBStore) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// SaveIfVersion 以 UPDATE ... WHERE id = ? AND version = ? 更新规则，未命中任何行时返回 ErrVersionConflict。
+func (s *DBStore) SaveIfVersion(ctx context.Context, rule Rule, version int) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	rec, err := newRuleRecord(rule)
+	if err != nil {
+		return err
+	}
+	result := s.db.WithContext(ctx).Model(&ruleRecord{}).
+		Where("id = ? AND version = ?", rule.ID, version).
+		UpdateColumns(map[string]any{
+			"priority":   rec.Priority,
+			"matcher":    rec.Matcher,
+			"actions":    rec.Actions,
+			"enabled":    rec.Enabled,
+			"version":    rec.Version,
+			"created_by": rec.CreatedBy,
+			"updated_by": rec.UpdatedBy,
+			"created_at": rec.CreatedAt,
+			"updated_at": rec.UpdatedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// DeleteIfVersion 以 DELETE ... WHERE id = ? AND version = ? 删除规则，未命中任何行时返回 ErrVersionConflict。
+func (s *DBStore) DeleteIfVersion(ctx context.Context, id string, version int) error {
+	result := s.db.WithContext(ctx).Delete(&ruleRecord{}, "id = ? AND version = ?", id, version)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
 type ruleRecord struct {
 	ID        string         `gorm:"primaryKey;type:varchar(64)"`
 	Priority  int            `gorm:"index"`
 	Matcher   datatypes.JSON `gorm:"type:jsonb"`
 	Actions   datatypes.JSON `gorm:"type:jsonb"`
 	Enabled   bool
+	Version   int    `gorm:"default:0"`
+	CreatedBy string `gorm:"type:varchar(128)"`
+	UpdatedBy string `gorm:"type:varchar(128)"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -103,11 +160,16 @@ func newRuleRecord(rule Rule) (ruleRecord, error) {
 		return ruleRecord{}, err
 	}
 	return ruleRecord{
-		ID:       rule.ID,
-		Priority: rule.Priority,
-		Matcher:  datatypes.JSON(matcherJSON),
-		Actions:  datatypes.JSON(actionsJSON),
-		Enabled:  rule.Enabled,
+		ID:        rule.ID,
+		Priority:  rule.Priority,
+		Matcher:   datatypes.JSON(matcherJSON),
+		Actions:   datatypes.JSON(actionsJSON),
+		Enabled:   rule.Enabled,
+		Version:   rule.Version,
+		CreatedBy: rule.CreatedBy,
+		UpdatedBy: rule.UpdatedBy,
+		CreatedAt: rule.CreatedAt,
+		UpdatedAt: rule.UpdatedAt,
 	}, nil
 }
 
@@ -121,10 +183,15 @@ func (r ruleRecord) toDomain() (Rule, error) {
 		return Rule{}, err
 	}
 	return Rule{
-		ID:       r.ID,
-		Priority: r.Priority,
-		Matcher:  matcher,
-		Actions:  actions,
-		Enabled:  r.Enabled,
+		ID:        r.ID,
+		Priority:  r.Priority,
+		Matcher:   matcher,
+		Actions:   actions,
+		Enabled:   r.Enabled,
+		Version:   r.Version,
+		CreatedBy: r.CreatedBy,
+		UpdatedBy: r.UpdatedBy,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
 	}, nil
 }
@@ -59,3 +59,38 @@ func TestDBStore_CRUD(t *testing.T) {
 	_, err = store.Get(ctx, "high")
 	require.ErrorIs(t, err, rules.ErrRuleNotFound)
 }
+
+func TestDBStore_ConditionalWrites(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:rules_conditional?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+
+	store := rules.NewDBStore(db)
+	ctx := context.Background()
+	require.NoError(t, store.AutoMigrate(ctx))
+
+	rule := rules.Rule{
+		ID:      "cond",
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: "https://example.com"},
+		Enabled: true,
+		Version: 1,
+	}
+	require.NoError(t, store.Save(ctx, rule))
+
+	rule.Version = 2
+	rule.Priority = 7
+	require.NoError(t, store.SaveIfVersion(ctx, rule, 1))
+	// 持有旧版本号的第二次写入不命中任何行。
+	rule.Priority = 9
+	require.ErrorIs(t, store.SaveIfVersion(ctx, rule, 1), rules.ErrVersionConflict)
+	got, err := store.Get(ctx, "cond")
+	require.NoError(t, err)
+	require.Equal(t, 2, got.Version)
+	require.Equal(t, 7, got.Priority)
+
+	require.ErrorIs(t, store.DeleteIfVersion(ctx, "cond", 1), rules.ErrVersionConflict)
+	require.NoError(t, store.DeleteIfVersion(ctx, "cond", 2))
+	require.ErrorIs(t, store.SaveIfVersion(ctx, rule, 2), rules.ErrVersionConflict)
+	_, err = store.Get(ctx, "cond")
+	require.ErrorIs(t, err, rules.ErrRuleNotFound)
+}
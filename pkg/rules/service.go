@@ -3,8 +3,10 @@ package rules
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"sync"
+	"time"
 )
 
 // Service 封装业务层逻辑，支持缓存与事件通知。
@@ -13,7 +15,34 @@ type Service interface {
 	GetRule(ctx context.Context, id string) (Rule, error)
 	UpsertRule(ctx context.Context, rule Rule) error
 	DeleteRule(ctx context.Context, id string) error
+	// UpsertRuleIfVersion 与 DeleteRuleIfVersion 仅当规则当前版本号等于 version 时写入，否则返回 ErrVersionConflict。
+	UpsertRuleIfVersion(ctx context.Context, rule Rule, version int) error
+	DeleteRuleIfVersion(ctx context.Context, id string, version int) error
 	StartBackgroundSync(ctx context.Context)
+	SyncStatus() SyncStatus
+}
+
+// SyncStatus 描述本实例规则缓存的同步状态。
+type SyncStatus struct {
+	// LoadedAt 为最近一次成功加载规则的时间，零值表示尚未加载。
+	LoadedAt time.Time
+	// LastError 为最近一次加载失败的原因，成功加载后清空。
+	LastError   error
+	LastErrorAt time.Time
+}
+
+// CheckSynced 供就绪探针使用：规则尚未加载时先尝试加载，最近一次同步失败时返回错误。
+func CheckSynced(ctx context.Context, svc Service) error {
+	if svc.SyncStatus().LoadedAt.IsZero() {
+		if _, err := svc.ListRules(ctx); err != nil {
+			return fmt.Errorf("rules not loaded: %w", err)
+		}
+	}
+	status := svc.SyncStatus()
+	if status.LastError != nil {
+		return fmt.Errorf("rules sync failed at %s: %w", status.LastErrorAt.UTC().Format(time.RFC3339), status.LastError)
+	}
+	return nil
 }
 
 // ServiceOption 用于配置 service。
@@ -46,9 +75,13 @@ type service struct {
 	cache    Cache
 	eventBus EventBus
 
-	mu     sync.RWMutex
-	cached []Rule
-	logger *log.Logger
+	mu        sync.RWMutex
+	cached    []Rule
+	loadedAt  time.Time
+	syncErr   error
+	syncErrAt time.Time
+	logger    *log.Logger
+	now       func() time.Time
 }
 
 // NewService 返回默认实现。
@@ -56,6 +89,7 @@ func NewService(store Store, opts ...ServiceOption) Service {
 	s := &service{
 		store:  store,
 		logger: log.Default(),
+		now:    time.Now,
 	}
 	for _, opt := range opts {
 		opt(s)
@@ -75,8 +109,13 @@ func (s *service) ListRules(ctx context.Context) ([]Rule, error) {
 			s.logger.Printf("rules cache get failed: %v", err)
 		}
 	}
-	rules, err := s.store.List(ctx)
+	list := s.store.List
+	if replica, ok := s.store.(ReplicaLister); ok {
+		list = replica.ListReplica
+	}
+	rules, err := list(ctx)
 	if err != nil {
+		s.recordSyncError(err)
 		return nil, err
 	}
 	s.setCachedRules(rules)
@@ -93,6 +132,9 @@ func (s *service) GetRule(ctx context.Context, id string) (Rule, error) {
 }
 
 func (s *service) UpsertRule(ctx context.Context, rule Rule) error {
+	if err := s.stampRevision(ctx, &rule); err != nil {
+		return err
+	}
 	if err := s.store.Save(ctx, rule); err != nil {
 		return err
 	}
@@ -103,6 +145,47 @@ func (s *service) UpsertRule(ctx context.Context, rule Rule) error {
 	return nil
 }
 
+func (s *service) UpsertRuleIfVersion(ctx context.Context, rule Rule, version int) error {
+	if err := s.stampRevision(ctx, &rule); err != nil {
+		return err
+	}
+	// 版本号以调用方确认过的版本为准，由存储在写入时比较，而非 stampRevision 读到的版本。
+	rule.Version = version + 1
+	if err := s.store.SaveIfVersion(ctx, rule, version); err != nil {
+		return err
+	}
+	if err := s.refreshCache(ctx); err != nil {
+		return err
+	}
+	s.broadcast(ctx)
+	return nil
+}
+
+// stampRevision 维护规则的创建信息与版本号：更新时沿用原创建人/创建时间并递增版本。
+func (s *service) stampRevision(ctx context.Context, rule *Rule) error {
+	now := s.now().UTC()
+	existing, err := s.store.Get(ctx, rule.ID)
+	switch {
+	case err == nil:
+		rule.CreatedAt = existing.CreatedAt
+		rule.CreatedBy = existing.CreatedBy
+		rule.Version = existing.Version + 1
+	case errors.Is(err, ErrRuleNotFound):
+		rule.CreatedAt = now
+		if rule.CreatedBy == "" {
+			rule.CreatedBy = rule.UpdatedBy
+		}
+		rule.Version = 1
+	default:
+		return err
+	}
+	if rule.CreatedAt.IsZero() {
+		rule.CreatedAt = now
+	}
+	rule.UpdatedAt = now
+	return nil
+}
+
 func (s *service) DeleteRule(ctx context.Context, id string) error {
 	if err := s.store.Delete(ctx, id); err != nil {
 		return err
@@ -114,6 +197,17 @@ func (s *service) DeleteRule(ctx context.Context, id string) error {
 	return nil
 }
 
+func (s *service) DeleteRuleIfVersion(ctx context.Context, id string, version int) error {
+	if err := s.store.DeleteIfVersion(ctx, id, version); err != nil {
+		return err
+	}
+	if err := s.refreshCache(ctx); err != nil {
+		return err
+	}
+	s.broadcast(ctx)
+	return nil
+}
+
 func (s *service) StartBackgroundSync(ctx context.Context) {
 	if s.eventBus == nil {
 		return
@@ -124,24 +218,38 @@ func (s *service) StartBackgroundSync(ctx context.Context) {
 		return
 	}
 	go func() {
+		var retry <-chan time.Time
 		for {
 			select {
 			case <-ctx.Done():
 				return
+			case <-retry:
+				retry = s.syncOnce(ctx)
 			case evt, ok := <-events:
 				if !ok {
 					return
 				}
 				if evt == EventRulesChanged {
-					if err := s.reload(ctx); err != nil {
-						s.logger.Printf("rules reload failed: %v", err)
-					}
+					retry = s.syncOnce(ctx)
 				}
 			}
 		}
 	}()
 }
 
+// syncRetryInterval 是同步失败后的重试间隔；重试成功前 SyncStatus 持续报告错误。
+const syncRetryInterval = 5 * time.Second
+
+// syncOnce 重新加载规则，失败时返回重试定时器。
+func (s *service) syncOnce(ctx context.Context) <-chan time.Time {
+	if err := s.reload(ctx); err != nil {
+		s.recordSyncError(err)
+		s.logger.Printf("rules reload failed: %v", err)
+		return time.After(syncRetryInterval)
+	}
+	return nil
+}
+
 func (s *service) broadcast(ctx context.Context) {
 	if s.eventBus == nil {
 		return
@@ -198,6 +306,21 @@ func (s *service) setCachedRules(rules []Rule) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.cached = cloneRules(rules)
+	s.loadedAt = s.now()
+	s.syncErr = nil
+}
+
+func (s *service) recordSyncError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncErr = err
+	s.syncErrAt = s.now()
+}
+
+func (s *service) SyncStatus() SyncStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return SyncStatus{LoadedAt: s.loadedAt, LastError: s.syncErr, LastErrorAt: s.syncErrAt}
 }
 
 func cloneRules(src []Rule) []Rule {
@@ -234,6 +357,54 @@ func cloneRule(r Rule) Rule {
 		cloned.Actions.OverrideJSON = cloneMapAny(r.Actions.OverrideJSON)
 	}
 	cloned.Actions.RemoveJSON = append([]string(nil), r.Actions.RemoveJSON...)
+	cloned.Actions.FallbackModels = append([]string(nil), r.Actions.FallbackModels...)
+	if len(r.Actions.ClampParams) > 0 {
+		cloned.Actions.ClampParams = make(map[string]ParamBound, len(r.Actions.ClampParams))
+		for k, v := range r.Actions.ClampParams {
+			cloned.Actions.ClampParams[k] = v.clone()
+		}
+	}
+	if r.Actions.SystemPrompt != nil {
+		prompt := *r.Actions.SystemPrompt
+		cloned.Actions.SystemPrompt = &prompt
+	}
+	if r.Actions.RedactPII != nil {
+		redact := *r.Actions.RedactPII
+		redact.Detectors = append([]string(nil), redact.Detectors...)
+		if len(redact.Patterns) > 0 {
+			redact.Patterns = make(map[string]string, len(r.Actions.RedactPII.Patterns))
+			for k, v := range r.Actions.RedactPII.Patterns {
+				redact.Patterns[k] = v
+			}
+		}
+		cloned.Actions.RedactPII = &redact
+	}
+	if r.Actions.Moderation != nil {
+		moderation := *r.Actions.Moderation
+		moderation.Stages = append([]string(nil), moderation.Stages...)
+		cloned.Actions.Moderation = &moderation
+	}
+	if r.Actions.ResponseCache != nil {
+		cache := *r.Actions.ResponseCache
+		cloned.Actions.ResponseCache = &cache
+	}
+	if r.Actions.ToolPolicy != nil {
+		policy := *r.Actions.ToolPolicy
+		policy.Allow = append([]string(nil), policy.Allow...)
+		policy.Remove = append([]string(nil), policy.Remove...)
+		if len(policy.Rename) > 0 {
+			policy.Rename = make(map[string]string, len(r.Actions.ToolPolicy.Rename))
+			for k, v := range r.Actions.ToolPolicy.Rename {
+				policy.Rename[k] = v
+			}
+		}
+		policy.Inject = make([]ToolDefinition, len(r.Actions.ToolPolicy.Inject))
+		for i, tool := range r.Actions.ToolPolicy.Inject {
+			tool.Parameters = cloneMapAny(tool.Parameters)
+			policy.Inject[i] = tool
+		}
+		cloned.Actions.ToolPolicy = &policy
+	}
 	if r.Actions.RewritePathRegex != nil {
 		rewrite := *r.Actions.RewritePathRegex
 		cloned.Actions.RewritePathRegex = &rewrite
@@ -241,6 +412,17 @@ func cloneRule(r Rule) Rule {
 	return cloned
 }
 
+func (b ParamBound) clone() ParamBound {
+	copyFloat := func(v *float64) *float64 {
+		if v == nil {
+			return nil
+		}
+		dup := *v
+		return &dup
+	}
+	return ParamBound{Min: copyFloat(b.Min), Max: copyFloat(b.Max), Default: copyFloat(b.Default)}
+}
+
 func cloneMapAny(src map[string]any) map[string]any {
 	if src == nil {
 		return nil
@@ -2,7 +2,12 @@ package rules_test
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -54,3 +59,166 @@ func TestService_UpsertAndList(t *testing.T) {
 	_, err = svc.GetRule(ctx, "rule-b")
 	require.ErrorIs(t, err, rules.ErrRuleNotFound)
 }
+
+func TestService_UpsertRule_TracksRevision(t *testing.T) {
+	ctx := context.Background()
+	svc := rules.NewService(rules.NewMemoryStore())
+
+	rule := rules.Rule{
+		ID:        "rule-rev",
+		Matcher:   rules.Matcher{PathPrefix: "/v1"},
+		Actions:   rules.Actions{SetTargetURL: "https://example.com"},
+		Enabled:   true,
+		UpdatedBy: "alice",
+	}
+	require.NoError(t, svc.UpsertRule(ctx, rule))
+	created, err := svc.GetRule(ctx, rule.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, created.Version)
+	require.Equal(t, "alice", created.CreatedBy)
+	require.False(t, created.CreatedAt.IsZero())
+
+	rule.UpdatedBy = "bob"
+	rule.Priority = 5
+	require.NoError(t, svc.UpsertRule(ctx, rule))
+	updated, err := svc.GetRule(ctx, rule.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, updated.Version)
+	require.Equal(t, "alice", updated.CreatedBy)
+	require.Equal(t, "bob", updated.UpdatedBy)
+	require.Equal(t, created.CreatedAt, updated.CreatedAt)
+}
+
+func TestService_UpsertRuleIfVersion_OnlyOneWriterWins(t *testing.T) {
+	ctx := context.Background()
+	svc := rules.NewService(rules.NewMemoryStore())
+	rule := rules.Rule{
+		ID:      "rule-race",
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: "https://example.com"},
+		Enabled: true,
+	}
+	require.NoError(t, svc.UpsertRule(ctx, rule))
+
+	// 多个写入者基于同一版本并发保存，只有一个成功，其余得到 ErrVersionConflict。
+	const writers = 8
+	var wins, conflicts atomic.Int32
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			update := rule
+			update.Priority = i + 1
+			err := svc.UpsertRuleIfVersion(ctx, update, 1)
+			switch {
+			case err == nil:
+				wins.Add(1)
+			case errors.Is(err, rules.ErrVersionConflict):
+				conflicts.Add(1)
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	require.EqualValues(t, 1, wins.Load())
+	require.EqualValues(t, writers-1, conflicts.Load())
+	saved, err := svc.GetRule(ctx, rule.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, saved.Version)
+
+	require.ErrorIs(t, svc.DeleteRuleIfVersion(ctx, rule.ID, 1), rules.ErrVersionConflict)
+	require.NoError(t, svc.DeleteRuleIfVersion(ctx, rule.ID, 2))
+}
+
+func TestMemoryEventBus_FanOutAndUnsubscribe(t *testing.T) {
+	bus := rules.NewMemoryEventBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	first, err := bus.Subscribe(ctx)
+	require.NoError(t, err)
+	second, err := bus.Subscribe(context.Background())
+	require.NoError(t, err)
+
+	svc := rules.NewService(rules.NewMemoryStore(), rules.WithEventBus(bus))
+	require.NoError(t, svc.UpsertRule(context.Background(), rules.Rule{
+		ID:      "evt",
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: "https://example.com"},
+	}))
+	require.Equal(t, rules.EventRulesChanged, <-first)
+	require.Equal(t, rules.EventRulesChanged, <-second)
+
+	cancel()
+	_, open := <-first
+	require.False(t, open)
+	require.NoError(t, bus.Publish(context.Background(), rules.EventAccountsChanged))
+	require.Equal(t, rules.EventAccountsChanged, <-second)
+}
+
+type flakyStore struct {
+	*rules.MemoryStore
+	fail atomic.Bool
+}
+
+func (s *flakyStore) List(ctx context.Context) ([]rules.Rule, error) {
+	if s.fail.Load() {
+		return nil, errors.New("database unavailable")
+	}
+	return s.MemoryStore.List(ctx)
+}
+
+func TestCheckSynced_ReportsFailedReload(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &flakyStore{MemoryStore: rules.NewMemoryStore()}
+	bus := rules.NewMemoryEventBus()
+	svc := rules.NewService(store, rules.WithEventBus(bus))
+	svc.StartBackgroundSync(ctx)
+
+	require.NoError(t, rules.CheckSynced(ctx, svc))
+	require.False(t, svc.SyncStatus().LoadedAt.IsZero())
+
+	store.fail.Store(true)
+	require.NoError(t, bus.Publish(ctx, rules.EventRulesChanged))
+	require.Eventually(t, func() bool {
+		err := rules.CheckSynced(ctx, svc)
+		return err != nil && strings.Contains(err.Error(), "database unavailable")
+	}, time.Second, 10*time.Millisecond)
+
+	store.fail.Store(false)
+	require.NoError(t, bus.Publish(ctx, rules.EventRulesChanged))
+	require.Eventually(t, func() bool { return rules.CheckSynced(ctx, svc) == nil }, time.Second, 10*time.Millisecond)
+}
+
+// laggingReplicaStore 的只读副本始终返回空列表，模拟复制延迟。
+type laggingReplicaStore struct {
+	*rules.MemoryStore
+	replicaReads atomic.Int32
+}
+
+func (s *laggingReplicaStore) ListReplica(context.Context) ([]rules.Rule, error) {
+	s.replicaReads.Add(1)
+	return nil, nil
+}
+
+func TestService_ListRulesReadsReplicaOnlyOnColdCache(t *testing.T) {
+	ctx := context.Background()
+	store := &laggingReplicaStore{MemoryStore: rules.NewMemoryStore()}
+	svc := rules.NewService(store)
+
+	list, err := svc.ListRules(ctx)
+	require.NoError(t, err)
+	require.Empty(t, list)
+	require.EqualValues(t, 1, store.replicaReads.Load())
+
+	require.NoError(t, svc.UpsertRule(ctx, rules.Rule{
+		ID:      "fresh",
+		Matcher: rules.Matcher{PathPrefix: "/v1"},
+		Actions: rules.Actions{SetTargetURL: "https://example.com"},
+	}))
+	list, err = svc.ListRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1, "the post-write refresh reads the primary, not the lagging replica")
+	require.EqualValues(t, 1, store.replicaReads.Load())
+}
@@ -10,12 +10,25 @@ import (
 // ErrRuleNotFound indicates a rule lookup failed.
 var ErrRuleNotFound = errors.New("rule not found")
 
+// ErrVersionConflict indicates a conditional write found the rule missing or at another version.
+var ErrVersionConflict = errors.New("rule version conflict")
+
 // Store 抽象出规则数据的读取与持久化。
 type Store interface {
 	List(ctx context.Context) ([]Rule, error)
 	Get(ctx context.Context, id string) (Rule, error)
 	Save(ctx context.Context, rule Rule) error
 	Delete(ctx context.Context, id string) error
+	// SaveIfVersion 仅当规则存在且当前版本号等于 version 时更新，否则返回 ErrVersionConflict；比较与写入原子完成。
+	SaveIfVersion(ctx context.Context, rule Rule, version int) error
+	// DeleteIfVersion 仅当规则存在且当前版本号等于 version 时删除，否则返回 ErrVersionConflict。
+	DeleteIfVersion(ctx context.Context, id string, version int) error
+}
+
+// ReplicaLister 由支持只读副本的存储实现。ListRules 在缓存未命中时优先从副本读取；
+// 写入后的缓存刷新与跨实例同步仍调用 List 读取主库，避免缓存复制延迟前的旧规则。
+type ReplicaLister interface {
+	ListReplica(ctx context.Context) ([]Rule, error)
 }
 
 // MemoryStore 基于内存的简单实现，便于本地开发与测试。
@@ -78,3 +91,28 @@ func (s *MemoryStore) Delete(_ context.Context, id string) error {
 	delete(s.rules, id)
 	return nil
 }
+
+// SaveIfVersion 在持有写锁时比较版本号后更新规则。
+func (s *MemoryStore) SaveIfVersion(_ context.Context, rule Rule, version int) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.rules[rule.ID]; !ok || existing.Version != version {
+		return ErrVersionConflict
+	}
+	s.rules[rule.ID] = rule
+	return nil
+}
+
+// DeleteIfVersion 在持有写锁时比较版本号后删除规则。
+func (s *MemoryStore) DeleteIfVersion(_ context.Context, id string, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.rules[id]; !ok || existing.Version != version {
+		return ErrVersionConflict
+	}
+	delete(s.rules, id)
+	return nil
+}
@@ -0,0 +1,178 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AWSCredentials 描述访问 AWS API 所需的静态凭据。
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// AWSSecretsManagerProvider 通过 GetSecretValue 读取 AWS Secrets Manager 中的密钥。
+//
+// 引用格式：`aws-sm://<secret-id>[#json-field]`。若密钥为 JSON 对象，可通过 field 取出子字段。
+// 只能读取 secret-id（名称或 ARN）命中允许列表的密钥，规则与 EnvProvider.Allow 相同，列表为空时拒绝全部引用。
+type AWSSecretsManagerProvider struct {
+	allow    []string
+	region   string
+	endpoint string
+	creds    AWSCredentials
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewAWSSecretsManagerProvider 创建 AWS Secrets Manager 后端；endpoint 为空时使用区域默认地址，allow 为允许读取的 secret-id 列表。
+func NewAWSSecretsManagerProvider(region, endpoint string, creds AWSCredentials, allow []string) *AWSSecretsManagerProvider {
+	region = strings.TrimSpace(region)
+	endpoint = strings.TrimRight(strings.TrimSpace(endpoint), "/")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region)
+	}
+	return &AWSSecretsManagerProvider{
+		allow:    allow,
+		region:   region,
+		endpoint: endpoint,
+		creds:    creds,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		now:      time.Now,
+	}
+}
+
+// Scheme 实现 Provider 接口。
+func (p *AWSSecretsManagerProvider) Scheme() string { return "aws-sm" }
+
+// Authorize 实现 Authorizer 接口。
+func (p *AWSSecretsManagerProvider) Authorize(ref Reference) error {
+	if !allowed(p.allow, ref.Path) {
+		return fmt.Errorf("%w: aws-sm %s", ErrSecretForbidden, ref.Path)
+	}
+	return nil
+}
+
+// Fetch 实现 Provider 接口。
+func (p *AWSSecretsManagerProvider) Fetch(ctx context.Context, ref Reference) (string, error) {
+	if err := p.Authorize(ref); err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(map[string]string{"SecretId": ref.Path})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	p.sign(req, body)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("secrets manager request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if strings.Contains(string(raw), "ResourceNotFoundException") {
+			return "", fmt.Errorf("%w: %s", ErrSecretNotFound, ref.Raw)
+		}
+		return "", fmt.Errorf("secrets manager responded with status %d", resp.StatusCode)
+	}
+	var payload struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return "", fmt.Errorf("secrets manager response decode failed: %w", err)
+	}
+	if ref.Field == "" {
+		return payload.SecretString, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(payload.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("%w: secret %s is not a JSON object", ErrInvalidReference, ref.Path)
+	}
+	value, ok := fields[ref.Field].(string)
+	if !ok {
+		return "", fmt.Errorf("%w: field %q in %s", ErrSecretNotFound, ref.Field, ref.Raw)
+	}
+	return value, nil
+}
+
+// sign 为请求追加 AWS Signature Version 4 头。
+func (p *AWSSecretsManagerProvider) sign(req *http.Request, body []byte) {
+	now := p.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if p.creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", p.creds.SessionToken)
+	}
+
+	signedHeaders := []string{"content-type", "host", "x-amz-date", "x-amz-target"}
+	if p.creds.SessionToken != "" {
+		signedHeaders = append(signedHeaders, "x-amz-security-token")
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaderList := strings.Join(signedHeaders, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		"",
+		canonicalHeaders.String(),
+		signedHeaderList,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/secretsmanager/aws4_request", dateStamp, p.region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+p.creds.SecretAccessKey), dateStamp)
+	key = hmacSHA256(key, p.region)
+	key = hmacSHA256(key, "secretsmanager")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.creds.AccessKeyID, scope, signedHeaderList, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
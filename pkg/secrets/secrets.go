@@ -0,0 +1,224 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnsupportedScheme 表示引用使用了未注册的后端。
+	ErrUnsupportedScheme = errors.New("secrets: unsupported reference scheme")
+	// ErrInvalidReference 表示密钥引用格式不合法。
+	ErrInvalidReference = errors.New("secrets: invalid reference")
+	// ErrSecretNotFound 表示后端中不存在目标密钥或字段。
+	ErrSecretNotFound = errors.New("secrets: secret not found")
+	// ErrSecretForbidden 表示引用的密钥不在允许读取的范围内。
+	ErrSecretForbidden = errors.New("secrets: secret not allowed")
+)
+
+// Provider 定义外部密钥后端，负责解析单个引用。
+type Provider interface {
+	// Scheme 返回该后端处理的引用前缀，例如 vault、aws-sm。
+	Scheme() string
+	// Fetch 根据解析后的引用读取明文密钥。
+	Fetch(ctx context.Context, ref Reference) (string, error)
+}
+
+// Authorizer 由限定可读取范围的 Provider 实现：Fetch 前先检查引用，写入凭据时也据此提前拒绝越界引用。
+type Authorizer interface {
+	// Authorize 在引用不在允许读取的范围内时返回 ErrSecretForbidden。
+	Authorize(ref Reference) error
+}
+
+// Resolver 将形如 `vault://kv/openai#api_key` 的引用解析为明文密钥。
+type Resolver interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// Reference 表示拆分后的密钥引用。
+type Reference struct {
+	Raw    string
+	Scheme string
+	Path   string
+	Field  string
+}
+
+// ParseReference 解析 `<scheme>://<path>[#field]` 格式的引用。
+func ParseReference(raw string) (Reference, error) {
+	trimmed := strings.TrimSpace(raw)
+	scheme, rest, ok := strings.Cut(trimmed, "://")
+	if !ok || scheme == "" || rest == "" {
+		return Reference{}, fmt.Errorf("%w: %q", ErrInvalidReference, raw)
+	}
+	path, field, _ := strings.Cut(rest, "#")
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return Reference{}, fmt.Errorf("%w: empty path in %q", ErrInvalidReference, raw)
+	}
+	return Reference{
+		Raw:    trimmed,
+		Scheme: strings.ToLower(scheme),
+		Path:   path,
+		Field:  field,
+	}, nil
+}
+
+// IsReference 判断字符串是否为受支持格式的密钥引用。
+func IsReference(raw string) bool {
+	_, err := ParseReference(raw)
+	return err == nil
+}
+
+// CachingResolver 按 scheme 分发引用并在内存中缓存解析结果。
+type CachingResolver struct {
+	providers map[string]Provider
+	ttl       time.Duration
+	now       func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// NewCachingResolver 创建带缓存的解析器；ttl <= 0 时不缓存。
+func NewCachingResolver(ttl time.Duration, providers ...Provider) *CachingResolver {
+	r := &CachingResolver{
+		providers: make(map[string]Provider, len(providers)),
+		ttl:       ttl,
+		now:       time.Now,
+		entries:   make(map[string]cacheEntry),
+	}
+	for _, p := range providers {
+		if p != nil {
+			r.providers[strings.ToLower(p.Scheme())] = p
+		}
+	}
+	return r
+}
+
+// Resolve 实现 Resolver 接口。
+func (r *CachingResolver) Resolve(ctx context.Context, raw string) (string, error) {
+	ref, err := ParseReference(raw)
+	if err != nil {
+		return "", err
+	}
+	if value, ok := r.lookup(ref.Raw); ok {
+		return value, nil
+	}
+	provider, ok := r.providers[ref.Scheme]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedScheme, ref.Scheme)
+	}
+	value, err := provider.Fetch(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, ref.Raw)
+	}
+	r.store(ref.Raw, value)
+	return value, nil
+}
+
+// Check 校验引用格式，并由实现 Authorizer 的后端检查引用是否在允许读取的范围内，
+// 供创建、更新凭据时拒绝越界引用；未注册的后端不在此拒绝，解析时再报告 ErrUnsupportedScheme。
+func (r *CachingResolver) Check(raw string) error {
+	ref, err := ParseReference(raw)
+	if err != nil {
+		return err
+	}
+	if authorizer, ok := r.providers[ref.Scheme].(Authorizer); ok {
+		return authorizer.Authorize(ref)
+	}
+	return nil
+}
+
+// Invalidate 清除指定引用的缓存，传空字符串时清空全部。
+func (r *CachingResolver) Invalidate(raw string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if raw == "" {
+		r.entries = make(map[string]cacheEntry)
+		return
+	}
+	delete(r.entries, strings.TrimSpace(raw))
+}
+
+func (r *CachingResolver) lookup(key string) (string, bool) {
+	if r.ttl <= 0 {
+		return "", false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.entries[key]
+	if !ok {
+		return "", false
+	}
+	if r.now().After(entry.expiresAt) {
+		delete(r.entries, key)
+		return "", false
+	}
+	return entry.value, true
+}
+
+func (r *CachingResolver) store(key, value string) {
+	if r.ttl <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[key] = cacheEntry{value: value, expiresAt: r.now().Add(r.ttl)}
+}
+
+// EnvProvider 从进程环境变量读取密钥，引用格式 `env://NAME`。只能读取 Allow 列出的变量，
+// 以 `*` 结尾的条目按前缀匹配；Allow 为空时拒绝全部引用，防止通过凭据引用读出网关自身的密钥。
+type EnvProvider struct {
+	Allow []string
+}
+
+// Scheme 实现 Provider 接口。
+func (EnvProvider) Scheme() string { return "env" }
+
+// Authorize 实现 Authorizer 接口。
+func (p EnvProvider) Authorize(ref Reference) error {
+	if !allowed(p.Allow, ref.Path) {
+		return fmt.Errorf("%w: env %s", ErrSecretForbidden, ref.Path)
+	}
+	return nil
+}
+
+// Fetch 实现 Provider 接口。
+func (p EnvProvider) Fetch(_ context.Context, ref Reference) (string, error) {
+	if err := p.Authorize(ref); err != nil {
+		return "", err
+	}
+	value, ok := os.LookupEnv(ref.Path)
+	if !ok {
+		return "", fmt.Errorf("%w: env %s", ErrSecretNotFound, ref.Path)
+	}
+	return value, nil
+}
+
+// allowed 判断 name 是否命中允许列表：以 `*` 结尾的条目按前缀匹配，其余条目要求完全相同；列表为空时一律拒绝。
+func allowed(allow []string, name string) bool {
+	for _, pattern := range allow {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
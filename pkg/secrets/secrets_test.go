@@ -0,0 +1,137 @@
+package secrets_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/prehisle/yapi/pkg/secrets"
+)
+
+func TestParseReference(t *testing.T) {
+	ref, err := secrets.ParseReference("vault://secret/llm/openai#api_key")
+	require.NoError(t, err)
+	require.Equal(t, "vault", ref.Scheme)
+	require.Equal(t, "secret/llm/openai", ref.Path)
+	require.Equal(t, "api_key", ref.Field)
+
+	_, err = secrets.ParseReference("sk-plaintext")
+	require.ErrorIs(t, err, secrets.ErrInvalidReference)
+	_, err = secrets.ParseReference("vault://")
+	require.ErrorIs(t, err, secrets.ErrInvalidReference)
+}
+
+func TestEnvProvider_OnlyReadsAllowedVariables(t *testing.T) {
+	t.Setenv("YAPI_SECRET_OPENAI", "sk-allowed")
+	t.Setenv("ADMIN_TOKEN_SECRET", "signing-key")
+	t.Setenv("UPSTREAM_KEY", "sk-exact")
+	resolver := secrets.NewCachingResolver(time.Minute, secrets.EnvProvider{Allow: []string{"YAPI_SECRET_*", "UPSTREAM_KEY"}})
+	ctx := context.Background()
+
+	value, err := resolver.Resolve(ctx, "env://YAPI_SECRET_OPENAI")
+	require.NoError(t, err)
+	require.Equal(t, "sk-allowed", value)
+	value, err = resolver.Resolve(ctx, "env://UPSTREAM_KEY")
+	require.NoError(t, err)
+	require.Equal(t, "sk-exact", value)
+
+	_, err = resolver.Resolve(ctx, "env://ADMIN_TOKEN_SECRET")
+	require.ErrorIs(t, err, secrets.ErrSecretForbidden)
+	_, err = resolver.Resolve(ctx, "env://UPSTREAM_KEY_2")
+	require.ErrorIs(t, err, secrets.ErrSecretForbidden)
+
+	_, err = secrets.EnvProvider{}.Fetch(ctx, secrets.Reference{Scheme: "env", Path: "YAPI_SECRET_OPENAI"})
+	require.ErrorIs(t, err, secrets.ErrSecretForbidden)
+}
+
+func TestCachingResolver_VaultWithCache(t *testing.T) {
+	calls := 0
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		require.Equal(t, "/v1/secret/data/llm/openai", r.URL.Path)
+		require.Equal(t, "root-token", r.Header.Get("X-Vault-Token"))
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{"data": map[string]any{"api_key": "sk-from-vault"}},
+		})
+	}))
+	defer vault.Close()
+
+	resolver := secrets.NewCachingResolver(time.Minute, secrets.NewVaultProvider(vault.URL, "root-token", "", []string{"secret/llm/*"}))
+	for i := 0; i < 3; i++ {
+		value, err := resolver.Resolve(context.Background(), "vault://secret/llm/openai#api_key")
+		require.NoError(t, err)
+		require.Equal(t, "sk-from-vault", value)
+	}
+	require.Equal(t, 1, calls)
+
+	resolver.Invalidate("")
+	_, err := resolver.Resolve(context.Background(), "vault://secret/llm/openai#api_key")
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+
+	_, err = resolver.Resolve(context.Background(), "aws-sm://prod/openai")
+	require.ErrorIs(t, err, secrets.ErrUnsupportedScheme)
+}
+
+func TestCachingResolver_BackendsOnlyReadAllowedPaths(t *testing.T) {
+	calls := 0
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+
+	resolver := secrets.NewCachingResolver(0,
+		secrets.NewVaultProvider(backend.URL, "root-token", "", []string{"secret/yapi/*"}),
+		secrets.NewAWSSecretsManagerProvider("us-east-1", backend.URL, secrets.AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+			[]string{"arn:aws:secretsmanager:us-east-1:123456789012:secret:yapi/*"}),
+	)
+	ctx := context.Background()
+	for _, ref := range []string{
+		"vault://secret/admin/root#token",
+		"vault://secret/yapi/../admin#token",
+		"aws-sm://prod/database",
+		"aws-sm://arn:aws:secretsmanager:us-east-1:123456789012:secret:prod/database",
+	} {
+		require.ErrorIs(t, resolver.Check(ref), secrets.ErrSecretForbidden, ref)
+		_, err := resolver.Resolve(ctx, ref)
+		require.ErrorIs(t, err, secrets.ErrSecretForbidden, ref)
+	}
+	require.Zero(t, calls)
+
+	require.NoError(t, resolver.Check("vault://secret/yapi/openai#api_key"))
+	require.NoError(t, resolver.Check("aws-sm://arn:aws:secretsmanager:us-east-1:123456789012:secret:yapi/openai"))
+	require.NoError(t, resolver.Check("gcp-sm://projects/p/secrets/s"))
+	require.ErrorIs(t, resolver.Check("sk-plaintext"), secrets.ErrInvalidReference)
+
+	_, err := secrets.NewVaultProvider(backend.URL, "root-token", "", nil).Fetch(ctx, secrets.Reference{Scheme: "vault", Path: "secret/yapi/openai"})
+	require.ErrorIs(t, err, secrets.ErrSecretForbidden)
+}
+
+func TestAWSSecretsManagerProvider_Fetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		require.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		require.Contains(t, r.Header.Get("Authorization"), "/us-east-1/secretsmanager/aws4_request")
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, "prod/openai", body["SecretId"])
+		_ = json.NewEncoder(w).Encode(map[string]string{"SecretString": `{"api_key":"sk-from-aws"}`})
+	}))
+	defer server.Close()
+
+	provider := secrets.NewAWSSecretsManagerProvider("us-east-1", server.URL, secrets.AWSCredentials{
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+	}, []string{"prod/*"})
+	resolver := secrets.NewCachingResolver(0, provider)
+	value, err := resolver.Resolve(context.Background(), "aws-sm://prod/openai#api_key")
+	require.NoError(t, err)
+	require.Equal(t, "sk-from-aws", value)
+}
@@ -18,6 +18,21 @@ type DialogState =
 
 type StatusFilter = 'all' | 'enabled' | 'disabled'
 
+// 排序选项的取值为 "<sort>:<order>"，空串表示服务端默认顺序（按优先级降序）。
+const sortOptions = [
+  { value: '', label: '默认排序' },
+  { value: 'priority:asc', label: '优先级从低到高' },
+  { value: 'updated_at:desc', label: '最近更新' },
+  { value: 'updated_at:asc', label: '最早更新' },
+  { value: 'id:asc', label: 'ID 升序' },
+  { value: 'id:desc', label: 'ID 降序' },
+]
+
+// 修改与删除时携带列表返回的 ETag，规则在此期间被他人修改时后端返回 412，需刷新后重试。
+const ifMatch = (rule: Rule): RequestInit => ({
+  headers: rule.etag ? { 'If-Match': rule.etag } : {},
+})
+
 const RulesPage = () => {
   const { logout } = useAuth()
   const { showSuccess, showError, confirm } = useUIContext()
@@ -31,6 +46,7 @@ const RulesPage = () => {
   const [updatingId, setUpdatingId] = useState<string | null>(null)
   const [search, setSearch] = useState('')
   const [statusFilter, setStatusFilter] = useState<StatusFilter>('all')
+  const [sort, setSort] = useState('')
   const [page, setPage] = useState(1)
   const [pageSize, setPageSize] = useState(10)
   const [dialogState, setDialogState] = useState<DialogState>(null)
@@ -50,6 +66,11 @@ const RulesPage = () => {
       if (statusFilter !== 'all') {
         params.set('enabled', statusFilter === 'enabled' ? 'true' : 'false')
       }
+      if (sort) {
+        const [field, order] = sort.split(':')
+        params.set('sort', field)
+        params.set('order', order)
+      }
       const query = params.toString()
       const path = query ? `/admin/rules?${query}` : '/admin/rules'
       const data = await apiClient.get<RuleListResponse>(path)
@@ -71,7 +92,7 @@ const RulesPage = () => {
     } finally {
       setLoading(false)
     }
-  }, [page, pageSize, search, statusFilter, logout, navigate, showError])
+  }, [page, pageSize, search, statusFilter, sort, logout, navigate, showError])
 
   useEffect(() => {
     void fetchRules()
@@ -79,7 +100,7 @@ const RulesPage = () => {
 
   useEffect(() => {
     setPage((prev) => (prev === 1 ? prev : 1))
-  }, [search, pageSize, statusFilter])
+  }, [search, pageSize, statusFilter, sort])
 
   const totalPages = useMemo(() => Math.max(1, Math.ceil(total / pageSize)), [total, pageSize])
 
@@ -109,7 +130,7 @@ const RulesPage = () => {
     async (payload: Rule) => {
       try {
         if (dialogState?.mode === 'edit') {
-          await apiClient.put(`/admin/rules/${dialogState.rule.id}`, payload)
+          await apiClient.put(`/admin/rules/${dialogState.rule.id}`, payload, ifMatch(dialogState.rule))
         } else {
           await apiClient.post('/admin/rules', payload)
         }
@@ -133,7 +154,7 @@ const RulesPage = () => {
       setUpdatingId(rule.id)
       try {
         const payload: Rule = { ...rule, enabled: !rule.enabled }
-        await apiClient.put(`/admin/rules/${rule.id}`, payload)
+        await apiClient.put(`/admin/rules/${rule.id}`, payload, ifMatch(rule))
         await fetchRules()
         showSuccess(`规则 ${rule.id} 已${rule.enabled ? '禁用' : '启用'}`)
       } catch (err) {
@@ -159,7 +180,7 @@ const RulesPage = () => {
         onConfirm: async () => {
           setUpdatingId(rule.id)
           try {
-            await apiClient.delete(`/admin/rules/${rule.id}`)
+            await apiClient.delete(`/admin/rules/${rule.id}`, ifMatch(rule))
             await fetchRules()
             showSuccess(`规则 ${rule.id} 已删除`)
           } catch (err) {
@@ -220,6 +241,8 @@ const RulesPage = () => {
             ]}
           />
 
+          <Select value={sort} onChange={(event) => setSort(event.target.value)} options={sortOptions} />
+
           <Select
             value={String(pageSize)}
             onChange={(event) => setPageSize(Number(event.target.value))}
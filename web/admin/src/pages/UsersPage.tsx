@@ -22,6 +22,19 @@ type DialogState =
   | { type: 'edit-upstream' }
   | null
 
+// 用户列表分页大小，与服务端默认的 limit 一致。
+const usersPageSize = 100
+
+// 用户名下 API Key 与上游凭据每次加载的条数，超出部分通过“加载更多”追加。
+const detailsPageSize = 100
+
+const detailsQuery = (offset: number) => {
+  const params = new URLSearchParams()
+  params.set('limit', String(detailsPageSize))
+  params.set('offset', String(offset))
+  return params.toString()
+}
+
 const emptyUserForm = { name: '', description: '' }
 type UpstreamFormState = {
   service: string
@@ -43,13 +56,18 @@ const UsersPage = () => {
   const { showSuccess, showError, confirm } = useUIContext()
 
   const [users, setUsers] = useState<User[]>([])
+  const [usersTotal, setUsersTotal] = useState(0)
+  const [usersPage, setUsersPage] = useState(1)
   const [loadingUsers, setLoadingUsers] = useState(true)
   const [selectedUserId, setSelectedUserId] = useState<string | null>(null)
 
   const [apiKeys, setApiKeys] = useState<APIKey[]>([])
+  const [apiKeysTotal, setApiKeysTotal] = useState(0)
   const [bindings, setBindings] = useState<Record<string, APIKeyBinding | null>>({})
   const [upstreams, setUpstreams] = useState<UpstreamCredential[]>([])
+  const [upstreamsTotal, setUpstreamsTotal] = useState(0)
   const [loadingDetails, setLoadingDetails] = useState(false)
+  const [loadingMore, setLoadingMore] = useState<'api-keys' | 'upstreams' | null>(null)
   const [apiKeySecret, setApiKeySecret] = useState<string | null>(null)
 
   const [dialog, setDialog] = useState<DialogState>(null)
@@ -73,8 +91,17 @@ const UsersPage = () => {
   const loadUsers = useCallback(async () => {
     setLoadingUsers(true)
     try {
-      const response = await apiClient.get<UserListResponse>('/admin/users')
+      const params = new URLSearchParams()
+      params.set('limit', String(usersPageSize))
+      params.set('offset', String((usersPage - 1) * usersPageSize))
+      const response = await apiClient.get<UserListResponse>(`/admin/users?${params.toString()}`)
+      if (response.items.length === 0 && usersPage > 1) {
+        // 删除当前页最后一个用户后回到上一页。
+        setUsersPage(usersPage - 1)
+        return
+      }
       setUsers(response.items)
+      setUsersTotal(response.total)
       if (!selectedUserId && response.items.length > 0) {
         setSelectedUserId(response.items[0].id)
       }
@@ -87,10 +114,13 @@ const UsersPage = () => {
     } finally {
       setLoadingUsers(false)
     }
-  }, [selectedUserId, handleUnauthorized, showError])
+  }, [usersPage, selectedUserId, handleUnauthorized, showError])
+
+  const usersTotalPages = useMemo(() => Math.max(1, Math.ceil(usersTotal / usersPageSize)), [usersTotal])
 
+  // loadBindingsForKeys 加载 keys 的绑定；append 为 true 时合并到已加载的绑定中（加载更多），否则整体替换。
   const loadBindingsForKeys = useCallback(
-    async (keys: APIKey[]) => {
+    async (keys: APIKey[], append = false) => {
       const nextBindings: Record<string, APIKeyBinding | null> = {}
       await Promise.all(
         keys.map(async (key) => {
@@ -110,7 +140,7 @@ const UsersPage = () => {
           }
         }),
       )
-      setBindings(nextBindings)
+      setBindings((prev) => (append ? { ...prev, ...nextBindings } : nextBindings))
     },
     [handleUnauthorized, showError],
   )
@@ -120,11 +150,13 @@ const UsersPage = () => {
       setLoadingDetails(true)
       try {
         const [keyResp, upstreamResp] = await Promise.all([
-          apiClient.get<APIKeyListResponse>(`/admin/users/${userId}/api-keys`),
-          apiClient.get<UpstreamCredentialListResponse>(`/admin/users/${userId}/upstreams`),
+          apiClient.get<APIKeyListResponse>(`/admin/users/${userId}/api-keys?${detailsQuery(0)}`),
+          apiClient.get<UpstreamCredentialListResponse>(`/admin/users/${userId}/upstreams?${detailsQuery(0)}`),
         ])
         setApiKeys(keyResp.items)
+        setApiKeysTotal(keyResp.total)
         setUpstreams(upstreamResp.items)
+        setUpstreamsTotal(upstreamResp.total)
         await loadBindingsForKeys(keyResp.items)
       } catch (err) {
         if (err instanceof UnauthorizedError) {
@@ -139,6 +171,51 @@ const UsersPage = () => {
     [handleUnauthorized, loadBindingsForKeys, showError],
   )
 
+  const loadMoreAPIKeys = async () => {
+    if (!selectedUserId) {
+      return
+    }
+    setLoadingMore('api-keys')
+    try {
+      const response = await apiClient.get<APIKeyListResponse>(
+        `/admin/users/${selectedUserId}/api-keys?${detailsQuery(apiKeys.length)}`,
+      )
+      setApiKeys((prev) => [...prev, ...response.items])
+      setApiKeysTotal(response.total)
+      await loadBindingsForKeys(response.items, true)
+    } catch (err) {
+      if (err instanceof UnauthorizedError) {
+        handleUnauthorized()
+        return
+      }
+      showError((err as Error).message ?? '加载 API Key 失败')
+    } finally {
+      setLoadingMore(null)
+    }
+  }
+
+  const loadMoreUpstreams = async () => {
+    if (!selectedUserId) {
+      return
+    }
+    setLoadingMore('upstreams')
+    try {
+      const response = await apiClient.get<UpstreamCredentialListResponse>(
+        `/admin/users/${selectedUserId}/upstreams?${detailsQuery(upstreams.length)}`,
+      )
+      setUpstreams((prev) => [...prev, ...response.items])
+      setUpstreamsTotal(response.total)
+    } catch (err) {
+      if (err instanceof UnauthorizedError) {
+        handleUnauthorized()
+        return
+      }
+      showError((err as Error).message ?? '加载上游凭据失败')
+    } finally {
+      setLoadingMore(null)
+    }
+  }
+
   useEffect(() => {
     void loadUsers()
   }, [loadUsers])
@@ -148,8 +225,10 @@ const UsersPage = () => {
       void loadUserDetails(selectedUserId)
     } else {
       setApiKeys([])
+      setApiKeysTotal(0)
       setBindings({})
       setUpstreams([])
+      setUpstreamsTotal(0)
     }
   }, [selectedUserId, loadUserDetails])
 
@@ -434,6 +513,30 @@ const closeDialog = () => {
         </div>
       )}
 
+      {usersTotal > usersPageSize ? (
+        <div className="pagination" style={{ marginBottom: 24 }}>
+          <div className="pagination__info">
+            第 {usersPage} / {usersTotalPages} 页，共 {usersTotal} 个用户
+          </div>
+          <div className="pagination__controls">
+            <button
+              className="button button--ghost"
+              onClick={() => setUsersPage((prev) => Math.max(1, prev - 1))}
+              disabled={usersPage === 1 || loadingUsers}
+            >
+              上一页
+            </button>
+            <button
+              className="button button--ghost"
+              onClick={() => setUsersPage((prev) => Math.min(usersTotalPages, prev + 1))}
+              disabled={usersPage >= usersTotalPages || loadingUsers}
+            >
+              下一页
+            </button>
+          </div>
+        </div>
+      ) : null}
+
       {selectedUser ? (
         <section style={{ display: 'grid', gap: '24px' }}>
           <div className="card">
@@ -520,6 +623,22 @@ const closeDialog = () => {
                 </table>
               </div>
             )}
+            {!loadingDetails && apiKeysTotal > apiKeys.length ? (
+              <div className="pagination" style={{ marginTop: 16 }}>
+                <div className="pagination__info">
+                  已显示 {apiKeys.length} / 共 {apiKeysTotal} 个 API Key
+                </div>
+                <div className="pagination__controls">
+                  <button
+                    className="button button--ghost"
+                    onClick={() => void loadMoreAPIKeys()}
+                    disabled={loadingMore !== null}
+                  >
+                    {loadingMore === 'api-keys' ? '加载中...' : '加载更多'}
+                  </button>
+                </div>
+              </div>
+            ) : null}
             {apiKeySecret ? (
               <div className="alert alert--info" style={{ marginTop: 16 }}>
                 <strong>请立即保存：</strong> 新生成的 API Key 为 <code>{apiKeySecret}</code>
@@ -589,6 +708,22 @@ const closeDialog = () => {
                 </table>
               </div>
             )}
+            {!loadingDetails && upstreamsTotal > upstreams.length ? (
+              <div className="pagination" style={{ marginTop: 16 }}>
+                <div className="pagination__info">
+                  已显示 {upstreams.length} / 共 {upstreamsTotal} 个上游凭据
+                </div>
+                <div className="pagination__controls">
+                  <button
+                    className="button button--ghost"
+                    onClick={() => void loadMoreUpstreams()}
+                    disabled={loadingMore !== null}
+                  >
+                    {loadingMore === 'upstreams' ? '加载中...' : '加载更多'}
+                  </button>
+                </div>
+              </div>
+            ) : null}
           </div>
         </section>
       ) : null}
@@ -655,6 +790,22 @@ const closeDialog = () => {
                 生成
               </button>
             </div>
+            {!loadingDetails && apiKeysTotal > apiKeys.length ? (
+              <div className="pagination" style={{ marginTop: 16 }}>
+                <div className="pagination__info">
+                  已显示 {apiKeys.length} / 共 {apiKeysTotal} 个 API Key
+                </div>
+                <div className="pagination__controls">
+                  <button
+                    className="button button--ghost"
+                    onClick={() => void loadMoreAPIKeys()}
+                    disabled={loadingMore !== null}
+                  >
+                    {loadingMore === 'api-keys' ? '加载中...' : '加载更多'}
+                  </button>
+                </div>
+              </div>
+            ) : null}
             {apiKeySecret ? (
               <div className="alert alert--info" style={{ marginTop: 16 }}>
                 <strong>请立即保存：</strong> 新生成的 API Key 为 <code>{apiKeySecret}</code>
@@ -9,6 +9,9 @@ export type User = {
 
 export type UserListResponse = {
   items: User[]
+  total: number
+  limit: number
+  offset: number
 }
 
 export type APIKey = {
@@ -23,6 +26,9 @@ export type APIKey = {
 
 export type APIKeyListResponse = {
   items: APIKey[]
+  total: number
+  limit: number
+  offset: number
 }
 
 export type APIKeyCreateResponse = {
@@ -46,6 +52,9 @@ export type UpstreamCredential = {
 
 export type UpstreamCredentialListResponse = {
   items: UpstreamCredential[]
+  total: number
+  limit: number
+  offset: number
 }
 
 export type APIKeyBinding = {
@@ -34,6 +34,7 @@ export interface Rule {
   matcher: RuleMatcher
   actions: RuleActions
   enabled: boolean
+  etag?: string
 }
 
 export interface RuleListResponse {
@@ -157,7 +157,7 @@ async function loginViaAPIOrSkip(request: APIRequestContext): Promise<string> {
 async function ensureRuleAbsent(request: APIRequestContext, token: string, ruleId: string) {
   try {
     const response = await request.delete(`${backendBaseURL}/admin/rules/${ruleId}`, {
-      headers: { Authorization: `Bearer ${token}` },
+      headers: { Authorization: `Bearer ${token}`, 'If-Match': '*' },
     })
     if (response.status() === 404) {
       return